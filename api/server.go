@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -13,7 +14,9 @@ import (
 	"nofx/crypto"
 	"nofx/decision"
 	"nofx/hook"
+	"nofx/logger"
 	"nofx/manager"
+	"nofx/market"
 	"nofx/trader"
 	"strconv"
 	"strings"
@@ -150,10 +153,18 @@ func (s *Server) setupRoutes() {
 			protected.GET("/status", s.handleStatus)
 			protected.GET("/account", s.handleAccount)
 			protected.GET("/positions", s.handlePositions)
+			protected.GET("/positions/history", s.handlePositionHistory)
 			protected.GET("/decisions", s.handleDecisions)
 			protected.GET("/decisions/latest", s.handleLatestDecisions)
+			protected.GET("/decisions/export", s.handleExportDecisions)
 			protected.GET("/statistics", s.handleStatistics)
 			protected.GET("/performance", s.handlePerformance)
+
+			// 市场特征与警报（来自WebSocket行情监控）
+			protected.GET("/market/features", s.handleMarketFeatures)
+			protected.GET("/market/alerts", s.handleMarketAlerts)
+			protected.GET("/market/klines", s.handleMarketKlines)
+			protected.GET("/market/funding", s.handleMarketFunding)
 		}
 	}
 }
@@ -396,6 +407,20 @@ type CreateTraderRequest struct {
 	IsCrossMargin        *bool   `json:"is_cross_margin"`        // 指针类型，nil表示使用默认值true
 	UseCoinPool          bool    `json:"use_coin_pool"`
 	UseOITop             bool    `json:"use_oi_top"`
+	MaxDailyLoss         float64 `json:"max_daily_loss"`         // 最大日亏损百分比，0表示使用系统默认
+	MaxDrawdown          float64 `json:"max_drawdown"`           // 最大回撤百分比，0表示使用系统默认
+	StopTradingMinutes   int     `json:"stop_trading_minutes"`   // 触发风控后暂停分钟数，0表示使用系统默认
+	FlattenOnRiskBreach  bool    `json:"flatten_on_risk_breach"` // 触发风控时是否强制平仓
+	PositionProtection   string  `json:"position_protection"`    // 持仓保护规则JSON，空表示默认规则
+	MarketData           string  `json:"market_data"`            // 附加时间框架与指标配置JSON，空表示仅默认数据
+	UseAlertCoins        bool    `json:"use_alert_coins"`        // 是否将市场警报币种加入候选
+	AlertWakeup          bool    `json:"alert_wakeup"`           // 是否由市场警报提前触发决策周期
+	DecisionTriggers     string  `json:"decision_triggers"`      // 事件触发决策周期配置JSON，空表示仅定时扫描
+	MaxDepthFraction     float64 `json:"max_depth_fraction"`     // 开仓名义价值占 ±1% 盘口深度的上限(0-1]，0表示使用默认值
+	CrossVenueFunding    bool    `json:"cross_venue_funding"`    // 是否向AI提供跨交易所资金费率差异
+	EnsembleConfig       string  `json:"ensemble_config"`        // 多模型集成决策配置JSON，空表示仅使用主模型
+	FallbackModels       string  `json:"fallback_models"`        // AI故障转移备用模型ID列表JSON，空表示不启用故障转移
+	DecisionRetention    string  `json:"decision_retention"`     // 决策日志保留策略JSON，空表示只做系统提示词去重
 }
 
 type ModelConfig struct {
@@ -492,6 +517,48 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		}
 	}
 
+	// 校验持仓保护规则
+	if _, err := trader.ParseProtectionConfig(req.PositionProtection); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验市场数据配置
+	if _, err := market.ParseDataConfig(req.MarketData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验事件触发配置
+	if _, err := trader.ParseTriggerConfig(req.DecisionTriggers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验盘口深度比例
+	if req.MaxDepthFraction < 0 || req.MaxDepthFraction > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "盘口深度比例必须在0-1之间"})
+		return
+	}
+
+	// 校验多模型集成配置
+	if err := s.validateEnsembleConfig(userID, req.EnsembleConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验AI故障转移备用模型
+	if err := s.validateFallbackModels(userID, req.FallbackModels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验决策日志保留策略
+	if _, err := logger.ParseRetentionPolicy(req.DecisionRetention); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 生成交易员ID (使用 UUID 确保唯一性，解决 Issue #893)
 	// 保留前缀以便调试和日志追踪
 	traderID := fmt.Sprintf("%s_%s_%s", req.ExchangeID, req.AIModelID, uuid.New().String())
@@ -578,6 +645,9 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 				exchangeCfg.AsterSigner,
 				exchangeCfg.AsterPrivateKey,
 			)
+		case "paper":
+			// 模拟盘直接以用户输入的初始资金作为模拟本金
+			log.Printf("📄 模拟盘交易员，使用用户输入的初始资金: %.2f USDT", req.InitialBalance)
 		default:
 			log.Printf("⚠️ 不支持的交易所类型: %s，使用用户输入的初始资金", req.ExchangeID)
 		}
@@ -592,27 +662,9 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 			} else {
 				// 🔧 计算Total Equity = Wallet Balance + Unrealized Profit
 				// 这是账户的真实净值，用作Initial Balance的基准
-				var totalWalletBalance float64
-				var totalUnrealizedProfit float64
-
-				// 提取钱包余额
-				if wb, ok := balanceInfo["totalWalletBalance"].(float64); ok {
-					totalWalletBalance = wb
-				} else if wb, ok := balanceInfo["wallet_balance"].(float64); ok {
-					totalWalletBalance = wb
-				} else if wb, ok := balanceInfo["balance"].(float64); ok {
-					totalWalletBalance = wb
-				}
-
-				// 提取未实现盈亏
-				if up, ok := balanceInfo["totalUnrealizedProfit"].(float64); ok {
-					totalUnrealizedProfit = up
-				} else if up, ok := balanceInfo["unrealized_profit"].(float64); ok {
-					totalUnrealizedProfit = up
-				}
-
-				// 计算总净值
-				totalEquity := totalWalletBalance + totalUnrealizedProfit
+				totalWalletBalance := balanceInfo.TotalWalletBalance
+				totalUnrealizedProfit := balanceInfo.TotalUnrealizedProfit
+				totalEquity := balanceInfo.Equity()
 
 				if totalEquity > 0 {
 					actualBalance = totalEquity
@@ -643,6 +695,20 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		SystemPromptTemplate: systemPromptTemplate,
 		IsCrossMargin:        isCrossMargin,
 		ScanIntervalMinutes:  scanIntervalMinutes,
+		MaxDailyLoss:         math.Max(req.MaxDailyLoss, 0),
+		MaxDrawdown:          math.Max(req.MaxDrawdown, 0),
+		StopTradingMinutes:   max(req.StopTradingMinutes, 0),
+		FlattenOnRiskBreach:  req.FlattenOnRiskBreach,
+		PositionProtection:   req.PositionProtection,
+		MarketData:           req.MarketData,
+		UseAlertCoins:        req.UseAlertCoins,
+		AlertWakeup:          req.AlertWakeup,
+		DecisionTriggers:     req.DecisionTriggers,
+		MaxDepthFraction:     req.MaxDepthFraction,
+		CrossVenueFunding:    req.CrossVenueFunding,
+		EnsembleConfig:       req.EnsembleConfig,
+		FallbackModels:       req.FallbackModels,
+		DecisionRetention:    req.DecisionRetention,
 		IsRunning:            false,
 	}
 
@@ -672,18 +738,32 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 
 // UpdateTraderRequest 更新交易员请求
 type UpdateTraderRequest struct {
-	Name                 string  `json:"name" binding:"required"`
-	AIModelID            string  `json:"ai_model_id" binding:"required"`
-	ExchangeID           string  `json:"exchange_id" binding:"required"`
-	InitialBalance       float64 `json:"initial_balance"`
-	ScanIntervalMinutes  int     `json:"scan_interval_minutes"`
-	BTCETHLeverage       int     `json:"btc_eth_leverage"`
-	AltcoinLeverage      int     `json:"altcoin_leverage"`
-	TradingSymbols       string  `json:"trading_symbols"`
-	CustomPrompt         string  `json:"custom_prompt"`
-	OverrideBasePrompt   bool    `json:"override_base_prompt"`
-	SystemPromptTemplate string  `json:"system_prompt_template"`
-	IsCrossMargin        *bool   `json:"is_cross_margin"`
+	Name                 string   `json:"name" binding:"required"`
+	AIModelID            string   `json:"ai_model_id" binding:"required"`
+	ExchangeID           string   `json:"exchange_id" binding:"required"`
+	InitialBalance       float64  `json:"initial_balance"`
+	ScanIntervalMinutes  int      `json:"scan_interval_minutes"`
+	BTCETHLeverage       int      `json:"btc_eth_leverage"`
+	AltcoinLeverage      int      `json:"altcoin_leverage"`
+	TradingSymbols       string   `json:"trading_symbols"`
+	CustomPrompt         string   `json:"custom_prompt"`
+	OverrideBasePrompt   bool     `json:"override_base_prompt"`
+	SystemPromptTemplate string   `json:"system_prompt_template"`
+	IsCrossMargin        *bool    `json:"is_cross_margin"`
+	MaxDailyLoss         *float64 `json:"max_daily_loss"`         // 指针类型，nil表示保持原值
+	MaxDrawdown          *float64 `json:"max_drawdown"`           // 指针类型，nil表示保持原值
+	StopTradingMinutes   *int     `json:"stop_trading_minutes"`   // 指针类型，nil表示保持原值
+	FlattenOnRiskBreach  *bool    `json:"flatten_on_risk_breach"` // 指针类型，nil表示保持原值
+	PositionProtection   *string  `json:"position_protection"`    // 指针类型，nil表示保持原值
+	MarketData           *string  `json:"market_data"`            // 指针类型，nil表示保持原值
+	UseAlertCoins        *bool    `json:"use_alert_coins"`        // 指针类型，nil表示保持原值
+	AlertWakeup          *bool    `json:"alert_wakeup"`           // 指针类型，nil表示保持原值
+	DecisionTriggers     *string  `json:"decision_triggers"`      // 指针类型，nil表示保持原值
+	MaxDepthFraction     *float64 `json:"max_depth_fraction"`     // 指针类型，nil表示保持原值
+	CrossVenueFunding    *bool    `json:"cross_venue_funding"`    // 指针类型，nil表示保持原值
+	EnsembleConfig       *string  `json:"ensemble_config"`        // 指针类型，nil表示保持原值
+	FallbackModels       *string  `json:"fallback_models"`        // 指针类型，nil表示保持原值
+	DecisionRetention    *string  `json:"decision_retention"`     // 指针类型，nil表示保持原值
 }
 
 // handleUpdateTrader 更新交易员配置
@@ -747,6 +827,109 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		systemPromptTemplate = existingTrader.SystemPromptTemplate // 如果请求中没有提供，保持原值
 	}
 
+	// 风控参数，未提供时保持原值
+	maxDailyLoss := existingTrader.MaxDailyLoss
+	if req.MaxDailyLoss != nil {
+		maxDailyLoss = math.Max(*req.MaxDailyLoss, 0)
+	}
+	maxDrawdown := existingTrader.MaxDrawdown
+	if req.MaxDrawdown != nil {
+		maxDrawdown = math.Max(*req.MaxDrawdown, 0)
+	}
+	stopTradingMinutes := existingTrader.StopTradingMinutes
+	if req.StopTradingMinutes != nil {
+		stopTradingMinutes = max(*req.StopTradingMinutes, 0)
+	}
+	flattenOnRiskBreach := existingTrader.FlattenOnRiskBreach
+	if req.FlattenOnRiskBreach != nil {
+		flattenOnRiskBreach = *req.FlattenOnRiskBreach
+	}
+
+	// 持仓保护规则，提供时需校验
+	positionProtection := existingTrader.PositionProtection
+	if req.PositionProtection != nil {
+		if _, err := trader.ParseProtectionConfig(*req.PositionProtection); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		positionProtection = *req.PositionProtection
+	}
+
+	// 市场数据配置，提供时需校验
+	marketData := existingTrader.MarketData
+	if req.MarketData != nil {
+		if _, err := market.ParseDataConfig(*req.MarketData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		marketData = *req.MarketData
+	}
+
+	// 市场警报开关，nil表示保持原值
+	useAlertCoins := existingTrader.UseAlertCoins
+	if req.UseAlertCoins != nil {
+		useAlertCoins = *req.UseAlertCoins
+	}
+	alertWakeup := existingTrader.AlertWakeup
+	if req.AlertWakeup != nil {
+		alertWakeup = *req.AlertWakeup
+	}
+
+	// 事件触发配置，提供时需校验
+	decisionTriggers := existingTrader.DecisionTriggers
+	if req.DecisionTriggers != nil {
+		if _, err := trader.ParseTriggerConfig(*req.DecisionTriggers); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		decisionTriggers = *req.DecisionTriggers
+	}
+
+	// 盘口深度比例，提供时需校验
+	maxDepthFraction := existingTrader.MaxDepthFraction
+	if req.MaxDepthFraction != nil {
+		if *req.MaxDepthFraction < 0 || *req.MaxDepthFraction > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "盘口深度比例必须在0-1之间"})
+			return
+		}
+		maxDepthFraction = *req.MaxDepthFraction
+	}
+
+	crossVenueFunding := existingTrader.CrossVenueFunding
+	if req.CrossVenueFunding != nil {
+		crossVenueFunding = *req.CrossVenueFunding
+	}
+
+	// 多模型集成配置，提供时需校验
+	ensembleConfig := existingTrader.EnsembleConfig
+	if req.EnsembleConfig != nil {
+		if err := s.validateEnsembleConfig(userID, *req.EnsembleConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ensembleConfig = *req.EnsembleConfig
+	}
+
+	// AI故障转移备用模型，提供时需校验
+	fallbackModels := existingTrader.FallbackModels
+	if req.FallbackModels != nil {
+		if err := s.validateFallbackModels(userID, *req.FallbackModels); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		fallbackModels = *req.FallbackModels
+	}
+
+	// 决策日志保留策略，提供时需校验
+	decisionRetention := existingTrader.DecisionRetention
+	if req.DecisionRetention != nil {
+		if _, err := logger.ParseRetentionPolicy(*req.DecisionRetention); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		decisionRetention = *req.DecisionRetention
+	}
+
 	// 更新交易员配置
 	trader := &config.TraderRecord{
 		ID:                   traderID,
@@ -763,6 +946,20 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		SystemPromptTemplate: systemPromptTemplate,
 		IsCrossMargin:        isCrossMargin,
 		ScanIntervalMinutes:  scanIntervalMinutes,
+		MaxDailyLoss:         maxDailyLoss,
+		MaxDrawdown:          maxDrawdown,
+		StopTradingMinutes:   stopTradingMinutes,
+		FlattenOnRiskBreach:  flattenOnRiskBreach,
+		PositionProtection:   positionProtection,
+		MarketData:           marketData,
+		UseAlertCoins:        useAlertCoins,
+		AlertWakeup:          alertWakeup,
+		DecisionTriggers:     decisionTriggers,
+		MaxDepthFraction:     maxDepthFraction,
+		CrossVenueFunding:    crossVenueFunding,
+		EnsembleConfig:       ensembleConfig,
+		FallbackModels:       fallbackModels,
+		DecisionRetention:    decisionRetention,
 		IsRunning:            existingTrader.IsRunning, // 保持原值
 	}
 
@@ -916,6 +1113,46 @@ func (s *Server) handleStopTrader(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "交易员已停止"})
 }
 
+// validateEnsembleConfig 校验多模型集成配置，引用的AI模型必须属于当前用户
+func (s *Server) validateEnsembleConfig(userID, raw string) error {
+	cfg, err := trader.ParseEnsembleConfig(raw)
+	if err != nil || !cfg.Enabled() {
+		return err
+	}
+	ids := cfg.Models
+	if cfg.Judge != "" {
+		ids = append(append([]string(nil), ids...), cfg.Judge)
+	}
+	return s.checkAIModelsOwned(userID, ids)
+}
+
+// validateFallbackModels 校验AI故障转移备用模型列表，引用的AI模型必须属于当前用户
+func (s *Server) validateFallbackModels(userID, raw string) error {
+	ids, err := trader.ParseFallbackModels(raw)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return s.checkAIModelsOwned(userID, ids)
+}
+
+// checkAIModelsOwned 检查AI模型ID均属于当前用户
+func (s *Server) checkAIModelsOwned(userID string, ids []string) error {
+	models, err := s.database.GetAIModels(userID)
+	if err != nil {
+		return fmt.Errorf("获取AI模型失败: %w", err)
+	}
+	owned := make(map[string]bool, len(models))
+	for _, model := range models {
+		owned[model.ID] = true
+	}
+	for _, id := range ids {
+		if !owned[id] {
+			return fmt.Errorf("AI模型不存在: %s", id)
+		}
+	}
+	return nil
+}
+
 // handleUpdateTraderPrompt 更新交易员自定义Prompt
 func (s *Server) handleUpdateTraderPrompt(c *gin.Context) {
 	traderID := c.Param("id")
@@ -1263,6 +1500,20 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
 		"is_cross_margin":        traderConfig.IsCrossMargin,
 		"use_coin_pool":          traderConfig.UseCoinPool,
 		"use_oi_top":             traderConfig.UseOITop,
+		"max_daily_loss":         traderConfig.MaxDailyLoss,
+		"max_drawdown":           traderConfig.MaxDrawdown,
+		"stop_trading_minutes":   traderConfig.StopTradingMinutes,
+		"flatten_on_risk_breach": traderConfig.FlattenOnRiskBreach,
+		"position_protection":    traderConfig.PositionProtection,
+		"market_data":            traderConfig.MarketData,
+		"use_alert_coins":        traderConfig.UseAlertCoins,
+		"alert_wakeup":           traderConfig.AlertWakeup,
+		"decision_triggers":      traderConfig.DecisionTriggers,
+		"max_depth_fraction":     traderConfig.MaxDepthFraction,
+		"cross_venue_funding":    traderConfig.CrossVenueFunding,
+		"ensemble_config":        traderConfig.EnsembleConfig,
+		"fallback_models":        traderConfig.FallbackModels,
+		"decision_retention":     traderConfig.DecisionRetention,
 		"is_running":             isRunning,
 	}
 
@@ -1345,6 +1596,58 @@ func (s *Server) handlePositions(c *gin.Context) {
 	c.JSON(http.StatusOK, positions)
 }
 
+// handlePositionHistory 持仓生命周期历史（最新开仓的在前，支持 status/symbol/limit 过滤）
+func (s *Server) handlePositionHistory(c *gin.Context) {
+	_, traderID, err := s.getTraderFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && status != logger.PositionStatusOpen && status != logger.PositionStatusClosed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status 只能是 open 或 closed"})
+		return
+	}
+	symbol := strings.ToUpper(c.Query("symbol"))
+
+	// 从 query 参数读取 limit，默认 100，最大 1000
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 || l > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须在 1-1000 之间"})
+			return
+		}
+		limit = l
+	}
+
+	positions, err := trader.PositionHistory()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("获取持仓历史失败: %v", err),
+		})
+		return
+	}
+
+	result := make([]*logger.PositionLifecycle, 0, limit)
+	for i := len(positions) - 1; i >= 0 && len(result) < limit; i-- {
+		pos := positions[i]
+		if (status != "" && pos.Status != status) || (symbol != "" && pos.Symbol != symbol) {
+			continue
+		}
+		result = append(result, pos)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
 // handleDecisions 决策日志列表
 func (s *Server) handleDecisions(c *gin.Context) {
 	_, traderID, err := s.getTraderFromQuery(c)
@@ -1359,6 +1662,24 @@ func (s *Server) handleDecisions(c *gin.Context) {
 		return
 	}
 
+	// 带过滤条件时服务端过滤并分页（按时间倒序），返回 {records, next_cursor}
+	query, filtered, err := parseDecisionQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filtered {
+		page, err := trader.GetDecisionLogger().QueryRecords(query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("查询决策日志失败: %v", err),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, page)
+		return
+	}
+
 	// 获取所有历史决策记录（无限制）
 	records, err := trader.GetDecisionLogger().GetLatestRecords(10000)
 	if err != nil {
@@ -1393,6 +1714,25 @@ func (s *Server) handleLatestDecisions(c *gin.Context) {
 		}
 	}
 
+	// 带过滤条件时返回满足条件的最新记录
+	query, filtered, err := parseDecisionQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filtered {
+		query.Limit = limit
+		page, err := trader.GetDecisionLogger().QueryRecords(query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("查询决策日志失败: %v", err),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, page.Records)
+		return
+	}
+
 	records, err := trader.GetDecisionLogger().GetLatestRecords(limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -1410,6 +1750,146 @@ func (s *Server) handleLatestDecisions(c *gin.Context) {
 	c.JSON(http.StatusOK, records)
 }
 
+// handleExportDecisions 导出决策日志表（table=cycles/actions/account_snapshots/trades，format=csv/parquet）
+// trader_id 支持逗号分隔多个，为空时导出当前用户的全部交易员
+func (s *Server) handleExportDecisions(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	table := c.Query("table")
+	if !logger.ValidExportTable(table) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("table 参数必须为 %s 之一", strings.Join(logger.ExportTableNames, " / "))})
+		return
+	}
+	format := c.DefaultQuery("format", logger.ExportFormatCSV)
+	if !logger.ValidExportFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 参数必须为 csv 或 parquet"})
+		return
+	}
+	start, err := parseQueryTime(c.Query("start"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("start 参数无效: %v", err)})
+		return
+	}
+	end, err := parseQueryTime(c.Query("end"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("end 参数无效: %v", err)})
+		return
+	}
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end 必须晚于 start"})
+		return
+	}
+
+	// 只允许导出当前用户自己的交易员
+	userTraders, err := s.database.GetTraders(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取交易员列表失败: %v", err)})
+		return
+	}
+	owned := make(map[string]bool, len(userTraders))
+	var traderIDs []string
+	for _, t := range userTraders {
+		owned[t.ID] = true
+		traderIDs = append(traderIDs, t.ID)
+	}
+	if raw := c.Query("trader_id"); raw != "" {
+		traderIDs = nil
+		for _, id := range strings.Split(raw, ",") {
+			if id = strings.TrimSpace(id); id == "" {
+				continue
+			}
+			if !owned[id] {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("交易员不存在: %s", id)})
+				return
+			}
+			traderIDs = append(traderIDs, id)
+		}
+	}
+	if len(traderIDs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "没有可导出的交易员"})
+		return
+	}
+
+	if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+		log.Printf("⚠️ 加载用户 %s 的交易员失败: %v", userID, err)
+	}
+	sources := make([]logger.ExportSource, 0, len(traderIDs))
+	for _, id := range traderIDs {
+		at, err := s.traderManager.GetTrader(id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		sources = append(sources, logger.ExportSource{TraderID: id, Logger: at.GetDecisionLogger()})
+	}
+
+	tables, err := logger.BuildExportTables(sources, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("导出决策日志失败: %v", err)})
+		return
+	}
+	var buf bytes.Buffer
+	if err := logger.WriteExportTable(&buf, tables[table], format); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("导出决策日志失败: %v", err)})
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == logger.ExportFormatParquet {
+		contentType = "application/vnd.apache.parquet"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", logger.ExportFileName(table, format)))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// parseDecisionQuery 解析决策日志过滤参数：start/end（RFC3339 或 2006-01-02）、symbol、action、success、cursor、limit
+// 没有任何过滤参数时 filtered 为 false
+func parseDecisionQuery(c *gin.Context) (query logger.DecisionQuery, filtered bool, err error) {
+	for _, key := range []string{"start", "end", "symbol", "action", "success", "cursor"} {
+		if c.Query(key) != "" {
+			filtered = true
+		}
+	}
+	if query.Start, err = parseQueryTime(c.Query("start")); err != nil {
+		return query, filtered, fmt.Errorf("start 参数无效: %w", err)
+	}
+	if query.End, err = parseQueryTime(c.Query("end")); err != nil {
+		return query, filtered, fmt.Errorf("end 参数无效: %w", err)
+	}
+	if !query.Start.IsZero() && !query.End.IsZero() && !query.End.After(query.Start) {
+		return query, filtered, fmt.Errorf("end 必须晚于 start")
+	}
+	query.Symbol = strings.ToUpper(strings.TrimSpace(c.Query("symbol")))
+	query.Action = strings.TrimSpace(c.Query("action"))
+	if successStr := c.Query("success"); successStr != "" {
+		success, err := strconv.ParseBool(successStr)
+		if err != nil {
+			return query, filtered, fmt.Errorf("success 参数必须为 true 或 false")
+		}
+		query.Success = &success
+	}
+	query.Cursor = c.Query("cursor")
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > logger.MaxDecisionQueryLimit {
+			return query, filtered, fmt.Errorf("limit 必须在1-%d之间", logger.MaxDecisionQueryLimit)
+		}
+		query.Limit = limit
+	}
+	return query, filtered, nil
+}
+
+// parseQueryTime 解析时间参数（RFC3339 或 本地日期 2006-01-02），空字符串返回零值
+func parseQueryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
 // handleStatistics 统计信息
 func (s *Server) handleStatistics(c *gin.Context) {
 	_, traderID, err := s.getTraderFromQuery(c)
@@ -1471,8 +1951,8 @@ func (s *Server) handleEquityHistory(c *gin.Context) {
 	}
 
 	// 获取尽可能多的历史数据（几天的数据）
-	// 每3分钟一个周期：10000条 = 约20天的数据
-	records, err := trader.GetDecisionLogger().GetLatestRecords(10000)
+	// 每3分钟一个周期：10000条 = 约20天的数据；更早的记录已归档时使用保留的降采样净值快照
+	records, err := logger.GetEquityHistory(trader.GetDecisionLogger(), 10000)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("获取历史数据失败: %v", err),
@@ -1560,9 +2040,9 @@ func (s *Server) handlePerformance(c *gin.Context) {
 		return
 	}
 
-	// 分析最近100个周期的交易表现（避免长期持仓的交易记录丢失）
-	// 假设每3分钟一个周期，100个周期 = 5小时，足够覆盖大部分交易
-	performance, err := trader.GetDecisionLogger().AnalyzePerformance(100)
+	// 交易统计来自订单/成交账本（包含交易所止损、持仓保护和人工平仓）
+	// 夏普比率基于最近100个周期的净值曲线；账本暂无已完成交易时退回决策日志配对
+	performance, err := trader.AnalyzePerformance(100)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("分析历史表现失败: %v", err),
@@ -1573,6 +2053,184 @@ func (s *Server) handlePerformance(c *gin.Context) {
 	c.JSON(http.StatusOK, performance)
 }
 
+// handleMarketFeatures 币种最新特征（?symbols=BTCUSDT,ETHUSDT）
+func (s *Server) handleMarketFeatures(c *gin.Context) {
+	if market.WSMonitorCli == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "行情监控未启动"})
+		return
+	}
+
+	symbolsParam := c.Query("symbols")
+	if symbolsParam == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少symbols参数"})
+		return
+	}
+
+	features := make(map[string]*market.SymbolFeatures)
+	for _, symbol := range strings.Split(symbolsParam, ",") {
+		symbol = market.Normalize(strings.TrimSpace(symbol))
+		if f, ok := market.WSMonitorCli.GetFeatures(symbol); ok {
+			features[symbol] = f
+		}
+	}
+	c.JSON(http.StatusOK, features)
+}
+
+// handleMarketAlerts 近期市场警报（?minutes=60，默认最近60分钟）
+func (s *Server) handleMarketAlerts(c *gin.Context) {
+	if market.WSMonitorCli == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "行情监控未启动"})
+		return
+	}
+
+	minutes := 60
+	if minutesStr := c.Query("minutes"); minutesStr != "" {
+		if m, err := strconv.Atoi(minutesStr); err == nil && m > 0 {
+			minutes = m
+		}
+	}
+
+	since := time.Now().Add(-time.Duration(minutes) * time.Minute)
+	alerts := market.WSMonitorCli.RecentAlerts(since)
+	if alerts == nil {
+		alerts = []market.Alert{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"alerts":  alerts,
+		"symbols": market.WSMonitorCli.AlertSymbols(since, 0), // 按活跃度评分排序
+	})
+}
+
+// maxKlinesPerRequest 单次查询返回的最大K线数量
+const maxKlinesPerRequest = 5000
+
+// handleMarketKlines 历史K线（?symbol=BTCUSDT&interval=3m&start=&end=&limit=）
+// start/end 为毫秒时间戳；未指定 start 时返回 end 之前最近 limit 根（默认500）
+func (s *Server) handleMarketKlines(c *gin.Context) {
+	symbol := strings.TrimSpace(c.Query("symbol"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少symbol参数"})
+		return
+	}
+	interval := c.DefaultQuery("interval", "3m")
+	step, ok := market.IntervalDuration(interval)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("不支持的K线周期: %s", interval)})
+		return
+	}
+
+	limit := 500
+	if limitStr := c.Query("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 || l > maxKlinesPerRequest {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit必须在 1-%d 之间", maxKlinesPerRequest)})
+			return
+		}
+		limit = l
+	}
+
+	parseMillis := func(name string) (time.Time, bool) {
+		value := c.Query(name)
+		if value == "" {
+			return time.Time{}, true
+		}
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || ms < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s必须为毫秒时间戳", name)})
+			return time.Time{}, false
+		}
+		return time.UnixMilli(ms), true
+	}
+	start, ok := parseMillis("start")
+	if !ok {
+		return
+	}
+	end, ok := parseMillis("end")
+	if !ok {
+		return
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if start.IsZero() {
+		start = end.Add(-step * time.Duration(limit))
+	}
+	if !start.Before(end) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start必须早于end"})
+		return
+	}
+	if end.Sub(start)/step > maxKlinesPerRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("查询区间过大，单次最多 %d 根K线", maxKlinesPerRequest)})
+		return
+	}
+
+	klines, err := market.LoadKlineRange(symbol, interval, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取K线失败: %v", err)})
+		return
+	}
+	if len(klines) > limit {
+		klines = klines[len(klines)-limit:]
+	}
+	if klines == nil {
+		klines = []market.Kline{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"symbol":   market.Normalize(symbol),
+		"interval": interval,
+		"klines":   klines,
+	})
+}
+
+// handleMarketFunding 跨交易所资金费率与基差（?symbols=BTCUSDT,ETHUSDT&hours=24，symbols 为空时返回所有采集中的币种）
+func (s *Server) handleMarketFunding(c *gin.Context) {
+	collector := market.GetFundingCollector()
+	if collector == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "资金费率采集器未启用"})
+		return
+	}
+
+	hours := 24
+	if hoursStr := c.Query("hours"); hoursStr != "" {
+		h, err := strconv.Atoi(hoursStr)
+		if err != nil || h < 0 || h > 72 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hours必须在 0-72 之间"})
+			return
+		}
+		hours = h
+	}
+
+	var symbols []string
+	for _, symbol := range strings.Split(c.Query("symbols"), ",") {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			symbols = append(symbols, market.Normalize(symbol))
+		}
+	}
+	var untracked []string
+	if len(symbols) == 0 {
+		symbols = collector.Symbols()
+	} else {
+		// 新增的币种从下次采样开始记录（数量有上限，长时间不再请求后停止采集）
+		untracked = collector.TrackRequested(symbols...)
+	}
+
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+	result := make([]gin.H, 0, len(symbols))
+	for _, symbol := range symbols {
+		result = append(result, gin.H{
+			"symbol":     symbol,
+			"latest":     collector.Latest(symbol),
+			"divergence": collector.Divergence(symbol),
+			"history":    collector.History(symbol, since),
+		})
+	}
+	response := gin.H{"symbols": result}
+	if len(untracked) > 0 {
+		response["untracked"] = untracked
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 // authMiddleware JWT认证中间件
 func (s *Server) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -2012,10 +2670,16 @@ func (s *Server) Start() error {
 	log.Printf("  • GET  /api/status?trader_id=xxx     - 指定trader的系统状态")
 	log.Printf("  • GET  /api/account?trader_id=xxx    - 指定trader的账户信息")
 	log.Printf("  • GET  /api/positions?trader_id=xxx  - 指定trader的持仓列表")
-	log.Printf("  • GET  /api/decisions?trader_id=xxx  - 指定trader的决策日志")
+	log.Printf("  • GET  /api/positions/history?trader_id=xxx - 持仓生命周期（开仓理由、止损止盈调整、部分平仓与退出原因）")
+	log.Printf("  • GET  /api/decisions?trader_id=xxx  - 指定trader的决策日志（支持 start/end/symbol/action/success/cursor/limit 过滤）")
 	log.Printf("  • GET  /api/decisions/latest?trader_id=xxx - 指定trader的最新决策")
+	log.Printf("  • GET  /api/decisions/export?table=trades&format=csv - 导出决策日志表（csv/parquet，支持 trader_id/start/end）")
 	log.Printf("  • GET  /api/statistics?trader_id=xxx - 指定trader的统计信息")
 	log.Printf("  • GET  /api/performance?trader_id=xxx - 指定trader的AI学习表现分析")
+	log.Printf("  • GET  /api/market/features?symbols=BTCUSDT - 币种最新行情特征")
+	log.Printf("  • GET  /api/market/alerts?minutes=60 - 近期市场警报")
+	log.Printf("  • GET  /api/market/klines?symbol=BTCUSDT&interval=1h&start=&end= - 历史K线（优先读取本地K线库）")
+	log.Printf("  • GET  /api/market/funding?symbols=BTCUSDT&hours=24 - 跨交易所资金费率与基差")
 	log.Println()
 
 	// 创建 http.Server 以支持 graceful shutdown
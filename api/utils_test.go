@@ -1,7 +1,12 @@
 package api
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
 func TestMaskSensitiveString(t *testing.T) {
@@ -191,3 +196,33 @@ func TestMaskEmail(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDecisionQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newContext := func(rawQuery string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/decisions?"+rawQuery, nil)
+		return c
+	}
+
+	if _, filtered, err := parseDecisionQuery(newContext("trader_id=t1&limit=10")); err != nil || filtered {
+		t.Errorf("trader_id and limit alone should keep legacy behaviour: filtered=%v, err=%v", filtered, err)
+	}
+
+	q, filtered, err := parseDecisionQuery(newContext("symbol=solusdt&action=open_short&success=false&start=2025-03-04&end=2025-03-06T00:00:00Z&limit=20"))
+	if err != nil || !filtered {
+		t.Fatalf("parseDecisionQuery returned filtered=%v, err=%v", filtered, err)
+	}
+	if q.Symbol != "SOLUSDT" || q.Action != "open_short" || q.Success == nil || *q.Success || q.Limit != 20 {
+		t.Errorf("unexpected query: %+v", q)
+	}
+	if !q.Start.Equal(time.Date(2025, 3, 4, 0, 0, 0, 0, time.Local)) || !q.End.Equal(time.Date(2025, 3, 6, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time range: %v - %v", q.Start, q.End)
+	}
+
+	for _, rawQuery := range []string{"success=maybe", "start=yesterday", "start=2025-03-05&end=2025-03-04", "symbol=BTCUSDT&limit=1000"} {
+		if _, _, err := parseDecisionQuery(newContext(rawQuery)); err == nil {
+			t.Errorf("%s should be rejected", rawQuery)
+		}
+	}
+}
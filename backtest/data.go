@@ -0,0 +1,114 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/market"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// 计算指标所需的预热K线数量（与 WSMonitor 缓存长度一致）
+const warmupBars = 100
+
+// KlineDownloader 历史K线下载接口（便于测试替换）
+type KlineDownloader interface {
+	GetKlinesRange(symbol, interval string, startTime, endTime int64) ([]market.Kline, error)
+}
+
+// klineFile 返回K线缓存文件路径：<dataDir>/<SYMBOL>_<interval>.json
+func klineFile(dataDir, symbol, interval string) string {
+	return filepath.Join(dataDir, fmt.Sprintf("%s_%s.json", symbol, interval))
+}
+
+// LoadKlines 从本地文件读取K线
+func LoadKlines(path string) ([]market.Kline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var klines []market.Kline
+	if err := json.Unmarshal(data, &klines); err != nil {
+		return nil, fmt.Errorf("解析K线文件失败 %s: %w", path, err)
+	}
+	sort.Slice(klines, func(i, j int) bool { return klines[i].OpenTime < klines[j].OpenTime })
+	return klines, nil
+}
+
+// SaveKlines 保存K线到本地文件
+func SaveKlines(path string, klines []market.Kline) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %w", err)
+	}
+
+	data, err := json.Marshal(klines)
+	if err != nil {
+		return fmt.Errorf("序列化K线失败: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("写入K线文件失败: %w", err)
+	}
+	return nil
+}
+
+// PrepareKlines 准备回测区间（含预热）的K线
+// 优先使用本地缓存，缓存不覆盖回测区间且 downloader 不为空时重新下载并保存
+func PrepareKlines(dataDir string, downloader KlineDownloader, symbol, interval string, start, end time.Time) ([]market.Kline, error) {
+	return prepareKlines(dataDir, downloader, symbol, interval, start, end, warmupBars)
+}
+
+// PrepareTimeframeKlines 准备附加时间框架的K线（预热长度与实盘附加时间框架一致）
+func PrepareTimeframeKlines(dataDir string, downloader KlineDownloader, symbol, interval string, start, end time.Time) ([]market.Kline, error) {
+	return prepareKlines(dataDir, downloader, symbol, interval, start, end, market.TimeframeHistoryLimit)
+}
+
+// prepareKlines 准备 [start-warmup根, end] 的K线
+func prepareKlines(dataDir string, downloader KlineDownloader, symbol, interval string, start, end time.Time, warmup int) ([]market.Kline, error) {
+	step, ok := market.IntervalDuration(interval)
+	if !ok {
+		return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+	}
+	from := start.Add(-step * time.Duration(warmup))
+	path := klineFile(dataDir, symbol, interval)
+
+	klines, err := LoadKlines(path)
+	if err == nil && covers(klines, from, end) {
+		return klines, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if downloader == nil {
+		if err == nil {
+			log.Printf("⚠️  %s 本地K线未完整覆盖回测区间，使用现有数据", filepath.Base(path))
+			return klines, nil
+		}
+		return nil, fmt.Errorf("缺少K线数据文件: %s", path)
+	}
+
+	log.Printf("📥 下载 %s %s K线: %s ~ %s", symbol, interval, from.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"))
+	klines, err = downloader.GetKlinesRange(symbol, interval, from.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("下载 %s %s K线失败: %w", symbol, interval, err)
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("%s %s 在回测区间内没有K线", symbol, interval)
+	}
+	if err := SaveKlines(path, klines); err != nil {
+		return nil, err
+	}
+	return klines, nil
+}
+
+// covers 判断K线是否覆盖 [from, to]
+func covers(klines []market.Kline, from, to time.Time) bool {
+	if len(klines) == 0 {
+		return false
+	}
+	return klines[0].OpenTime <= from.UnixMilli() && klines[len(klines)-1].CloseTime >= to.UnixMilli()-1
+}
@@ -0,0 +1,264 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/logger"
+	"nofx/market"
+	"nofx/mcp"
+	"nofx/trader"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Config 回测配置
+type Config struct {
+	Symbols      []string      // 回测币种
+	Start        time.Time     // 回测开始时间
+	End          time.Time     // 回测结束时间
+	ScanInterval time.Duration // 决策周期间隔（默认3分钟）
+
+	InitialBalance  float64 // 初始资金
+	FeeRate         float64 // 手续费率
+	Slippage        float64 // 滑点比例
+	BTCETHLeverage  int     // BTC/ETH杠杆上限
+	AltcoinLeverage int     // 山寨币杠杆上限
+	IsCrossMargin   bool    // 是否全仓
+
+	SystemPromptTemplate string // 系统提示词模板
+	CustomPrompt         string // 自定义提示词
+	OverrideBasePrompt   bool   // 是否覆盖基础提示词
+
+	OutputDir string // 输出目录（决策记录、资金曲线、统计）
+}
+
+// EquityPoint 资金曲线上的一个点
+type EquityPoint struct {
+	Time          time.Time `json:"time"`
+	Cycle         int       `json:"cycle"`
+	Equity        float64   `json:"equity"`
+	WalletBalance float64   `json:"wallet_balance"`
+	UnrealizedPnL float64   `json:"unrealized_pnl"`
+	PositionCount int       `json:"position_count"`
+}
+
+// Result 回测结果
+type Result struct {
+	Summary     *Summary      `json:"summary"`
+	EquityCurve []EquityPoint `json:"equity_curve"`
+}
+
+// Engine 回测引擎：按模拟时间回放K线，驱动与实盘相同的 AutoTrader 决策与执行流程
+type Engine struct {
+	config   Config
+	feed     *Feed
+	aiClient mcp.AIClient
+	now      time.Time
+}
+
+// NewEngine 创建回测引擎
+func NewEngine(config Config, feed *Feed, aiClient mcp.AIClient) (*Engine, error) {
+	if len(config.Symbols) == 0 {
+		return nil, fmt.Errorf("回测币种不能为空")
+	}
+	if !config.End.After(config.Start) {
+		return nil, fmt.Errorf("回测结束时间必须晚于开始时间")
+	}
+	if config.OutputDir == "" {
+		return nil, fmt.Errorf("回测输出目录不能为空")
+	}
+	if config.ScanInterval <= 0 {
+		config.ScanInterval = 3 * time.Minute
+	}
+	if config.InitialBalance <= 0 {
+		config.InitialBalance = 10000
+	}
+	if config.BTCETHLeverage <= 0 {
+		config.BTCETHLeverage = 5
+	}
+	if config.AltcoinLeverage <= 0 {
+		config.AltcoinLeverage = 5
+	}
+	if aiClient == nil {
+		aiClient = NewStubAIClient()
+	}
+
+	for i, symbol := range config.Symbols {
+		config.Symbols[i] = market.Normalize(symbol)
+		if _, ok := feed.klines3m[config.Symbols[i]]; !ok {
+			return nil, fmt.Errorf("回测数据中没有 %s", config.Symbols[i])
+		}
+		if interval := feed.missingTimeframe(config.Symbols[i]); interval != "" {
+			return nil, fmt.Errorf("回测数据中没有 %s 的 %s K线", config.Symbols[i], interval)
+		}
+	}
+
+	return &Engine{
+		config:   config,
+		feed:     feed,
+		aiClient: aiClient,
+		now:      config.Start,
+	}, nil
+}
+
+// clock 模拟时钟
+func (e *Engine) clock() time.Time {
+	return e.now
+}
+
+// Run 执行回测并写出结果
+func (e *Engine) Run() (*Result, error) {
+	cfg := e.config
+
+	paper, err := trader.NewPaperTrader(trader.PaperTraderConfig{
+		InitialBalance: cfg.InitialBalance,
+		FeeRate:        cfg.FeeRate,
+		Slippage:       cfg.Slippage,
+		PriceFunc: func(symbol string) (float64, error) {
+			return e.feed.Price(symbol, e.now)
+		},
+		Clock: e.clock,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("初始化模拟撮合失败: %w", err)
+	}
+
+	// 清理上次回测的决策记录，避免统计混入旧数据
+	decisionDir := filepath.Join(cfg.OutputDir, "decisions")
+	if err := os.RemoveAll(decisionDir); err != nil {
+		return nil, fmt.Errorf("清理回测决策目录失败: %w", err)
+	}
+	decisionLogger := logger.NewDecisionLoggerWithClock(decisionDir, e.clock)
+
+	at, err := trader.NewBacktestAutoTrader(trader.AutoTraderConfig{
+		ID:                   "backtest",
+		Name:                 "Backtest",
+		AIModel:              "backtest",
+		Exchange:             "paper",
+		ScanInterval:         cfg.ScanInterval,
+		InitialBalance:       cfg.InitialBalance,
+		BTCETHLeverage:       cfg.BTCETHLeverage,
+		AltcoinLeverage:      cfg.AltcoinLeverage,
+		IsCrossMargin:        cfg.IsCrossMargin,
+		TradingCoins:         cfg.Symbols,
+		SystemPromptTemplate: cfg.SystemPromptTemplate,
+	}, trader.BacktestDeps{
+		Trader:         paper,
+		AIClient:       e.aiClient,
+		DecisionLogger: decisionLogger,
+		MarketDataFunc: func(symbol string) (*market.Data, error) {
+			return e.feed.Data(symbol, e.now)
+		},
+		Clock: e.clock,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("初始化回测交易器失败: %w", err)
+	}
+	at.SetCustomPrompt(cfg.CustomPrompt)
+	at.SetOverrideBasePrompt(cfg.OverrideBasePrompt)
+
+	log.Printf("🧪 开始回测: %v | %s ~ %s | 周期 %v | 初始资金 %.2f",
+		cfg.Symbols, cfg.Start.Format("2006-01-02 15:04"), cfg.End.Format("2006-01-02 15:04"),
+		cfg.ScanInterval, cfg.InitialBalance)
+
+	var curve []EquityPoint
+	cycles, failedCycles := 0, 0
+	prev := cfg.Start
+	for t := cfg.Start; !t.After(cfg.End); t = t.Add(cfg.ScanInterval) {
+		// 逐根回放两次决策之间的K线，触发止盈止损和强平
+		for _, symbol := range cfg.Symbols {
+			for _, k := range e.feed.Bars(symbol, prev, t) {
+				e.now = time.UnixMilli(k.CloseTime)
+				replayBar(paper, symbol, k)
+			}
+		}
+		e.now = t
+		prev = t
+
+		cycles++
+		if err := at.RunCycle(); err != nil {
+			failedCycles++
+			log.Printf("⚠️  回测周期 #%d 失败: %v", cycles, err)
+		}
+
+		point, err := equityPoint(paper, t, cycles)
+		if err != nil {
+			return nil, err
+		}
+		curve = append(curve, point)
+	}
+
+	realizedPnL, totalFees := paper.Totals()
+	performance, err := decisionLogger.AnalyzePerformance(cycles)
+	if err != nil {
+		log.Printf("⚠️  分析回测交易表现失败: %v", err)
+		performance = nil
+	}
+
+	summary := buildSummary(cfg, curve, performance)
+	summary.Cycles = cycles
+	summary.FailedCycles = failedCycles
+	summary.RealizedPnL = realizedPnL
+	summary.TotalFees = totalFees
+
+	result := &Result{Summary: summary, EquityCurve: curve}
+	if err := writeJSON(filepath.Join(cfg.OutputDir, "equity_curve.json"), curve); err != nil {
+		return nil, err
+	}
+	if err := writeJSON(filepath.Join(cfg.OutputDir, "summary.json"), summary); err != nil {
+		return nil, err
+	}
+
+	log.Printf("✅ 回测完成: %d 个周期 | 最终净值 %.2f | 收益率 %.2f%% | 最大回撤 %.2f%%",
+		cycles, summary.FinalEquity, summary.TotalReturnPct, summary.MaxDrawdownPct)
+	return result, nil
+}
+
+// replayBar 按 开→低→高→收（阳线）或 开→高→低→收（阴线）的顺序回放一根K线
+func replayBar(paper *trader.PaperTrader, symbol string, k market.Kline) {
+	path := []float64{k.Open, k.Low, k.High, k.Close}
+	if k.Close < k.Open {
+		path = []float64{k.Open, k.High, k.Low, k.Close}
+	}
+	for _, price := range path {
+		paper.OnPrice(symbol, price)
+	}
+}
+
+// equityPoint 记录当前账户净值
+func equityPoint(paper *trader.PaperTrader, t time.Time, cycle int) (EquityPoint, error) {
+	balance, err := paper.GetBalance()
+	if err != nil {
+		return EquityPoint{}, fmt.Errorf("获取回测账户余额失败: %w", err)
+	}
+	positions, err := paper.GetPositions()
+	if err != nil {
+		return EquityPoint{}, fmt.Errorf("获取回测持仓失败: %w", err)
+	}
+
+	return EquityPoint{
+		Time:          t,
+		Cycle:         cycle,
+		Equity:        balance.Equity(),
+		WalletBalance: balance.TotalWalletBalance,
+		UnrealizedPnL: balance.TotalUnrealizedProfit,
+		PositionCount: len(positions),
+	}, nil
+}
+
+// writeJSON 写出带缩进的JSON文件
+func writeJSON(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 %s 失败: %w", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package backtest
+
+import (
+	"fmt"
+	"nofx/market"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syntheticKlines 生成线性变化的K线（每根变化 step）
+func syntheticKlines(start time.Time, interval time.Duration, n int, open, step float64) []market.Kline {
+	klines := make([]market.Kline, 0, n)
+	price := open
+	for i := 0; i < n; i++ {
+		openTime := start.Add(time.Duration(i) * interval)
+		closePrice := price + step
+		klines = append(klines, market.Kline{
+			OpenTime:  openTime.UnixMilli(),
+			Open:      price,
+			High:      max(price, closePrice) + 1,
+			Low:       min(price, closePrice) - 1,
+			Close:     closePrice,
+			Volume:    100,
+			CloseTime: openTime.Add(interval).UnixMilli() - 1,
+		})
+		price = closePrice
+	}
+	return klines
+}
+
+// newTestFeed 构建回测起点前有足够预热数据的行情
+func newTestFeed(start time.Time, step float64) *Feed {
+	feed := NewFeed()
+	dataStart := start.Add(-warmupBars * 4 * time.Hour)
+	klines3m := syntheticKlines(start.Add(-warmupBars*3*time.Minute), 3*time.Minute, warmupBars+200, 50000, step)
+	klines4h := syntheticKlines(dataStart, 4*time.Hour, warmupBars+5, 50000, step)
+	feed.Add("BTCUSDT", klines3m, klines4h)
+	return feed
+}
+
+// openLongOnce 第一次调用时按当前价开多，之后观望
+func openLongOnce(price, stopPct, takePct float64) *StubAIClient {
+	open := fmt.Sprintf(`<reasoning>趋势向上，开多</reasoning>
+<decision>
+[{"symbol": "BTCUSDT", "action": "open_long", "leverage": 5, "position_size_usd": 1000, "stop_loss": %.2f, "take_profit": %.2f, "confidence": 80, "risk_usd": 20, "reasoning": "test"}]
+</decision>`, price*(1-stopPct), price*(1+takePct))
+	return NewStubAIClient(open, DefaultStubResponse)
+}
+
+func TestFeed_NoLookahead(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	feed := newTestFeed(start, 10)
+
+	price, err := feed.Price("BTCUSDT", start)
+	require.NoError(t, err)
+	// 起点之前恰好收盘 warmupBars 根K线
+	assert.InDelta(t, 50000+10*warmupBars, price, 1e-9)
+
+	data, err := feed.Data("BTCUSDT", start)
+	require.NoError(t, err)
+	assert.Equal(t, price, data.CurrentPrice)
+	assert.Nil(t, data.OpenInterest)
+
+	bars := feed.Bars("BTCUSDT", start, start.Add(9*time.Minute))
+	require.Len(t, bars, 3)
+	assert.GreaterOrEqual(t, bars[0].CloseTime, start.UnixMilli())
+}
+
+// TestFeed_Timeframes 附加时间框架与实盘使用相同的配置，只包含模拟时刻之前收盘的K线
+func TestFeed_Timeframes(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := newTestFeed(start, 10)
+	feed := NewFeedWithConfig(market.DataConfig{Timeframes: []market.TimeframeSpec{market.DefaultTimeframe("1h")}})
+	feed.Add("BTCUSDT", base.klines3m["BTCUSDT"], base.klines4h["BTCUSDT"])
+
+	_, err := NewEngine(Config{Symbols: []string{"BTCUSDT"}, Start: start, End: start.Add(time.Hour), OutputDir: t.TempDir()}, feed, nil)
+	require.Error(t, err, "缺少附加周期K线时应拒绝回测")
+
+	feed.AddTimeframe("BTCUSDT", "1h", syntheticKlines(start.Add(-60*time.Hour), time.Hour, 70, 40000, 5))
+	data, err := feed.Data("BTCUSDT", start)
+	require.NoError(t, err)
+	require.Len(t, data.Timeframes, 1)
+	tf := data.Timeframes[0]
+	assert.Equal(t, "1h", tf.Interval)
+	require.Len(t, tf.Closes, market.DefaultSeriesLength)
+	// 起点之前恰好收盘 60 根1小时K线
+	assert.InDelta(t, 40000+5*60, tf.Closes[len(tf.Closes)-1], 1e-9)
+	assert.NotEmpty(t, tf.Indicators)
+}
+
+func TestEngine_RunWritesResults(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	feed := newTestFeed(start, 10)
+	price, err := feed.Price("BTCUSDT", start)
+	require.NoError(t, err)
+
+	ai := openLongOnce(price, 0.02, 0.10)
+	outDir := t.TempDir()
+	engine, err := NewEngine(Config{
+		Symbols:        []string{"btcusdt"},
+		Start:          start,
+		End:            end,
+		ScanInterval:   15 * time.Minute,
+		InitialBalance: 10000,
+		FeeRate:        0.0004,
+		Slippage:       0.0005,
+		OutputDir:      outDir,
+	}, feed, ai)
+	require.NoError(t, err)
+
+	result, err := engine.Run()
+	require.NoError(t, err)
+
+	assert.Equal(t, 9, result.Summary.Cycles)
+	assert.Equal(t, 9, ai.Calls())
+	require.Len(t, result.EquityCurve, 9)
+	assert.Equal(t, 1, result.EquityCurve[len(result.EquityCurve)-1].PositionCount)
+	assert.Greater(t, result.Summary.FinalEquity, 10000.0, "上涨行情中持有多单应盈利")
+	assert.Greater(t, result.Summary.TotalFees, 0.0)
+
+	decisions, err := os.ReadDir(filepath.Join(outDir, "decisions"))
+	require.NoError(t, err)
+	assert.Len(t, decisions, 9)
+	assert.Contains(t, decisions[0].Name(), "decision_20240101_000000_cycle1")
+	assert.FileExists(t, filepath.Join(outDir, "equity_curve.json"))
+	assert.FileExists(t, filepath.Join(outDir, "summary.json"))
+}
+
+func TestEngine_StopLossTriggeredBetweenCycles(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	feed := newTestFeed(start, -20) // 持续下跌
+	price, err := feed.Price("BTCUSDT", start)
+	require.NoError(t, err)
+
+	engine, err := NewEngine(Config{
+		Symbols:        []string{"BTCUSDT"},
+		Start:          start,
+		End:            start.Add(3 * time.Hour),
+		ScanInterval:   time.Hour,
+		InitialBalance: 10000,
+		OutputDir:      t.TempDir(),
+	}, feed, openLongOnce(price, 0.005, 0.05))
+	require.NoError(t, err)
+
+	result, err := engine.Run()
+	require.NoError(t, err)
+
+	last := result.EquityCurve[len(result.EquityCurve)-1]
+	assert.Equal(t, 0, last.PositionCount, "止损应在两次决策之间触发")
+	assert.Less(t, result.Summary.RealizedPnL, 0.0)
+	assert.Greater(t, result.Summary.MaxDrawdownPct, 0.0)
+}
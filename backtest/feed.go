@@ -0,0 +1,120 @@
+package backtest
+
+import (
+	"fmt"
+	"log"
+	"nofx/market"
+	"sort"
+	"time"
+)
+
+// Feed 历史行情回放源
+// 在模拟时刻 t 只暴露 CloseTime < t 的已收盘K线，避免未来函数
+type Feed struct {
+	klines3m   map[string][]market.Kline
+	klines4h   map[string][]market.Kline
+	config     market.DataConfig                    // 附加时间框架配置（与实盘交易员的 market_data 相同）
+	timeframes map[string]map[string][]market.Kline // 币种 -> 附加周期 -> K线
+}
+
+// NewFeed 创建行情回放源（仅默认的3分钟/4小时数据）
+func NewFeed() *Feed {
+	return NewFeedWithConfig(market.DataConfig{})
+}
+
+// NewFeedWithConfig 创建行情回放源，并按配置追加附加时间框架（K线通过 AddTimeframe 添加）
+func NewFeedWithConfig(cfg market.DataConfig) *Feed {
+	return &Feed{
+		klines3m:   make(map[string][]market.Kline),
+		klines4h:   make(map[string][]market.Kline),
+		config:     cfg,
+		timeframes: make(map[string]map[string][]market.Kline),
+	}
+}
+
+// Add 添加某个币种的3分钟与4小时K线
+func (f *Feed) Add(symbol string, klines3m, klines4h []market.Kline) {
+	f.klines3m[symbol] = klines3m
+	f.klines4h[symbol] = klines4h
+}
+
+// AddTimeframe 添加某个币种附加时间框架的K线
+func (f *Feed) AddTimeframe(symbol, interval string, klines []market.Kline) {
+	if f.timeframes[symbol] == nil {
+		f.timeframes[symbol] = make(map[string][]market.Kline)
+	}
+	f.timeframes[symbol][interval] = klines
+}
+
+// missingTimeframe 返回币种缺少K线的第一个附加周期（全部齐全时返回空字符串）
+func (f *Feed) missingTimeframe(symbol string) string {
+	for _, interval := range f.config.Intervals() {
+		if _, ok := f.timeframes[symbol][interval]; !ok {
+			return interval
+		}
+	}
+	return ""
+}
+
+// Symbols 返回已加载的币种
+func (f *Feed) Symbols() []string {
+	symbols := make([]string, 0, len(f.klines3m))
+	for symbol := range f.klines3m {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Data 构建模拟时刻 at 的市场数据（与 market.GetWithConfig 同一套指标计算）
+// 附加周期在 at 之前没有K线时跳过该周期，与实盘获取失败时的处理一致
+func (f *Feed) Data(symbol string, at time.Time) (*market.Data, error) {
+	symbol = market.Normalize(symbol)
+	klines3m, ok := f.klines3m[symbol]
+	if !ok {
+		return nil, fmt.Errorf("回测数据中没有 %s", symbol)
+	}
+
+	data, err := market.BuildData(symbol,
+		window(klines3m, at, warmupBars),
+		window(f.klines4h[symbol], at, warmupBars))
+	if err != nil {
+		return nil, err
+	}
+	for _, spec := range f.config.Timeframes {
+		tf, err := market.BuildTimeframe(spec, window(f.timeframes[symbol][spec.Interval], at, market.TimeframeHistoryLimit))
+		if err != nil {
+			log.Printf("⚠️ 计算 %s %s 指标失败，跳过该周期: %v", symbol, spec.Interval, err)
+			continue
+		}
+		data.Timeframes = append(data.Timeframes, tf)
+	}
+	return data, nil
+}
+
+// Price 返回模拟时刻 at 的最新价格（最近一根已收盘3分钟K线的收盘价）
+func (f *Feed) Price(symbol string, at time.Time) (float64, error) {
+	klines := window(f.klines3m[market.Normalize(symbol)], at, 1)
+	if len(klines) == 0 {
+		return 0, fmt.Errorf("%s 在 %s 之前没有K线", symbol, at.Format("2006-01-02 15:04"))
+	}
+	return klines[0].Close, nil
+}
+
+// Bars 返回在 from 时刻不可见、to 时刻可见的3分钟K线（用于逐根撮合止盈止损）
+func (f *Feed) Bars(symbol string, from, to time.Time) []market.Kline {
+	klines := f.klines3m[symbol]
+	lo := sort.Search(len(klines), func(i int) bool { return klines[i].CloseTime >= from.UnixMilli() })
+	hi := sort.Search(len(klines), func(i int) bool { return klines[i].CloseTime >= to.UnixMilli() })
+	return klines[lo:hi]
+}
+
+// window 返回 CloseTime < at 的最近 n 根K线
+func window(klines []market.Kline, at time.Time, n int) []market.Kline {
+	end := sort.Search(len(klines), func(i int) bool { return klines[i].CloseTime >= at.UnixMilli() })
+	start := end - n
+	if start < 0 {
+		start = 0
+	}
+	return klines[start:end]
+}
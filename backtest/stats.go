@@ -0,0 +1,103 @@
+package backtest
+
+import (
+	"math"
+	"nofx/logger"
+	"time"
+)
+
+// Summary 回测统计
+type Summary struct {
+	Symbols      []string  `json:"symbols"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	ScanInterval string    `json:"scan_interval"`
+	Cycles       int       `json:"cycles"`
+	FailedCycles int       `json:"failed_cycles"`
+
+	InitialBalance float64 `json:"initial_balance"`
+	FinalEquity    float64 `json:"final_equity"`
+	TotalReturnPct float64 `json:"total_return_pct"`
+	MaxDrawdownPct float64 `json:"max_drawdown_pct"`
+	SharpeRatio    float64 `json:"sharpe_ratio"` // 按周期收益率年化
+	RealizedPnL    float64 `json:"realized_pnl"` // 含止盈止损、强平的全部已实现盈亏
+	TotalFees      float64 `json:"total_fees"`
+
+	// 基于决策记录的交易表现（仅包含AI主动平仓的交易）
+	Performance *logger.PerformanceAnalysis `json:"performance,omitempty"`
+}
+
+// buildSummary 根据资金曲线计算统计指标
+func buildSummary(cfg Config, curve []EquityPoint, performance *logger.PerformanceAnalysis) *Summary {
+	summary := &Summary{
+		Symbols:        cfg.Symbols,
+		Start:          cfg.Start,
+		End:            cfg.End,
+		ScanInterval:   cfg.ScanInterval.String(),
+		InitialBalance: cfg.InitialBalance,
+		FinalEquity:    cfg.InitialBalance,
+		Performance:    performance,
+	}
+	if len(curve) == 0 {
+		return summary
+	}
+
+	summary.FinalEquity = curve[len(curve)-1].Equity
+	if cfg.InitialBalance > 0 {
+		summary.TotalReturnPct = (summary.FinalEquity - cfg.InitialBalance) / cfg.InitialBalance * 100
+	}
+	summary.MaxDrawdownPct = maxDrawdownPct(cfg.InitialBalance, curve)
+	summary.SharpeRatio = sharpeRatio(cfg.InitialBalance, curve, cfg.ScanInterval)
+	return summary
+}
+
+// maxDrawdownPct 计算最大回撤百分比
+func maxDrawdownPct(initial float64, curve []EquityPoint) float64 {
+	peak := initial
+	maxDD := 0.0
+	for _, p := range curve {
+		if p.Equity > peak {
+			peak = p.Equity
+		}
+		if peak > 0 {
+			if dd := (peak - p.Equity) / peak * 100; dd > maxDD {
+				maxDD = dd
+			}
+		}
+	}
+	return maxDD
+}
+
+// sharpeRatio 计算年化夏普比率（无风险利率按0计）
+func sharpeRatio(initial float64, curve []EquityPoint, interval time.Duration) float64 {
+	if len(curve) < 2 || interval <= 0 {
+		return 0
+	}
+
+	var returns []float64
+	prev := initial
+	for _, p := range curve {
+		if prev > 0 {
+			returns = append(returns, (p.Equity-prev)/prev)
+		}
+		prev = p.Equity
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	stdDev := math.Sqrt(variance / float64(len(returns)))
+	if stdDev == 0 {
+		return 0
+	}
+
+	periodsPerYear := float64(365*24*time.Hour) / float64(interval)
+	return mean / stdDev * math.Sqrt(periodsPerYear)
+}
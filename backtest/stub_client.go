@@ -0,0 +1,79 @@
+package backtest
+
+import (
+	"fmt"
+	"nofx/mcp"
+	"sync"
+	"time"
+)
+
+// DefaultStubResponse 桩客户端默认响应（始终观望）
+const DefaultStubResponse = `<reasoning>回测桩客户端：不做任何操作</reasoning>
+<decision>
+[{"symbol": "BTCUSDT", "action": "wait", "reasoning": "stub"}]
+</decision>`
+
+// StubAIClient 离线AI客户端，用于无需调用真实模型的回测
+// 优先使用 ResponseFunc；否则按顺序返回 Responses，用尽后重复最后一条
+type StubAIClient struct {
+	Responses    []string
+	ResponseFunc func(systemPrompt, userPrompt string) (string, error)
+
+	mu    sync.Mutex
+	calls int
+}
+
+// NewStubAIClient 创建桩客户端（无响应时使用 DefaultStubResponse）
+func NewStubAIClient(responses ...string) *StubAIClient {
+	if len(responses) == 0 {
+		responses = []string{DefaultStubResponse}
+	}
+	return &StubAIClient{Responses: responses}
+}
+
+// SetAPIKey 桩客户端无需API密钥
+func (c *StubAIClient) SetAPIKey(apiKey string, customURL string, customModel string) {}
+
+// SetTimeout 桩客户端无需超时设置
+func (c *StubAIClient) SetTimeout(timeout time.Duration) {}
+
+// CallWithMessages 返回预设响应
+func (c *StubAIClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx := c.calls
+	c.calls++
+
+	if c.ResponseFunc != nil {
+		return c.ResponseFunc(systemPrompt, userPrompt)
+	}
+	if len(c.Responses) == 0 {
+		return "", fmt.Errorf("桩客户端没有预设响应")
+	}
+	if idx >= len(c.Responses) {
+		idx = len(c.Responses) - 1
+	}
+	return c.Responses[idx], nil
+}
+
+// CallWithRequest 返回预设响应
+func (c *StubAIClient) CallWithRequest(req *mcp.Request) (string, error) {
+	var systemPrompt, userPrompt string
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system":
+			systemPrompt = msg.Content
+		case "user":
+			userPrompt = msg.Content
+		}
+	}
+	return c.CallWithMessages(systemPrompt, userPrompt)
+}
+
+// Calls 返回调用次数
+func (c *StubAIClient) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"nofx/backtest"
+	"nofx/market"
+	"nofx/mcp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runBacktestCommand 执行历史回测子命令：nofx backtest [flags]
+func runBacktestCommand(args []string) error {
+	fs := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	symbols := fs.String("symbols", "BTCUSDT", "回测币种，逗号分隔")
+	startStr := fs.String("start", "", "开始时间（UTC），如 2024-01-01 或 2024-01-01T08:00")
+	endStr := fs.String("end", "", "结束时间（UTC）")
+	interval := fs.Duration("interval", 3*time.Minute, "决策周期间隔")
+	balance := fs.Float64("balance", 10000, "初始资金（USDT）")
+	feeRate := fs.Float64("fee", 0.0004, "手续费率")
+	slippage := fs.Float64("slippage", 0.0005, "滑点比例")
+	btcEthLeverage := fs.Int("btc-eth-leverage", 5, "BTC/ETH杠杆上限")
+	altcoinLeverage := fs.Int("altcoin-leverage", 5, "山寨币杠杆上限")
+	crossMargin := fs.Bool("cross", true, "是否全仓模式")
+	template := fs.String("template", "adaptive", "系统提示词模板")
+	promptFile := fs.String("prompt-file", "", "自定义提示词文件")
+	overridePrompt := fs.Bool("override-prompt", false, "自定义提示词是否覆盖基础提示词")
+	dataDir := fs.String("data", "backtest_data", "K线数据目录")
+	offline := fs.Bool("offline", false, "仅使用本地K线，不从交易所下载")
+	klineDB := fs.String("kline-db", "market_data/klines.db", "本地K线库（与实盘行情监控共用），为空表示不使用")
+	marketData := fs.String("market-data", "", "附加时间框架与指标配置JSON（与交易员的 market_data 相同），为空表示仅默认数据")
+	outDir := fs.String("out", "", "结果输出目录（默认 backtest_results/<时间戳>）")
+	aiMode := fs.String("ai", "stub", "AI客户端: stub 或 replay")
+	stubResponse := fs.String("stub-response", "", "桩客户端响应文件（默认始终观望）")
+	recordingDir := fs.String("recordings", "", "replay 模式使用的AI录制目录")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	start, err := parseBacktestTime(*startStr)
+	if err != nil {
+		return fmt.Errorf("解析开始时间失败: %w", err)
+	}
+	end, err := parseBacktestTime(*endStr)
+	if err != nil {
+		return fmt.Errorf("解析结束时间失败: %w", err)
+	}
+
+	var symbolList []string
+	for _, s := range strings.Split(*symbols, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			symbolList = append(symbolList, market.Normalize(s))
+		}
+	}
+
+	dataConfig, err := market.ParseDataConfig(*marketData)
+	if err != nil {
+		return err
+	}
+
+	customPrompt := ""
+	if *promptFile != "" {
+		content, err := os.ReadFile(*promptFile)
+		if err != nil {
+			return fmt.Errorf("读取自定义提示词失败: %w", err)
+		}
+		customPrompt = string(content)
+	}
+
+	var aiClient mcp.AIClient
+	switch *aiMode {
+	case "stub":
+		stub := backtest.NewStubAIClient()
+		if *stubResponse != "" {
+			content, err := os.ReadFile(*stubResponse)
+			if err != nil {
+				return fmt.Errorf("读取桩响应失败: %w", err)
+			}
+			stub = backtest.NewStubAIClient(string(content))
+		}
+		aiClient = stub
+	case "replay":
+		if *recordingDir == "" {
+			return fmt.Errorf("replay 模式必须指定 -recordings")
+		}
+		replay, err := mcp.NewReplayClient(*recordingDir)
+		if err != nil {
+			return err
+		}
+		aiClient = replay
+	default:
+		return fmt.Errorf("不支持的AI客户端: %s", *aiMode)
+	}
+
+	// 准备K线数据
+	// 优先读取本地K线库，缺失的区间再从交易所下载并写回K线库
+	var downloader backtest.KlineDownloader
+	var client *market.APIClient
+	if !*offline {
+		client = market.NewAPIClient()
+		downloader = client
+	}
+	if *klineDB != "" {
+		if _, err := os.Stat(*klineDB); err == nil || !*offline {
+			store, err := market.OpenKlineStore(*klineDB)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			downloader = &market.StoredKlineSource{Store: store, Client: client}
+		}
+	}
+	feed := backtest.NewFeedWithConfig(dataConfig)
+	for _, symbol := range symbolList {
+		klines3m, err := backtest.PrepareKlines(*dataDir, downloader, symbol, "3m", start, end)
+		if err != nil {
+			return err
+		}
+		klines4h, err := backtest.PrepareKlines(*dataDir, downloader, symbol, "4h", start, end)
+		if err != nil {
+			return err
+		}
+		feed.Add(symbol, klines3m, klines4h)
+		for _, interval := range dataConfig.Intervals() {
+			klines, err := backtest.PrepareTimeframeKlines(*dataDir, downloader, symbol, interval, start, end)
+			if err != nil {
+				return err
+			}
+			feed.AddTimeframe(symbol, interval, klines)
+		}
+	}
+
+	if *outDir == "" {
+		*outDir = filepath.Join("backtest_results", time.Now().Format("20060102_150405"))
+	}
+
+	engine, err := backtest.NewEngine(backtest.Config{
+		Symbols:              symbolList,
+		Start:                start,
+		End:                  end,
+		ScanInterval:         *interval,
+		InitialBalance:       *balance,
+		FeeRate:              *feeRate,
+		Slippage:             *slippage,
+		BTCETHLeverage:       *btcEthLeverage,
+		AltcoinLeverage:      *altcoinLeverage,
+		IsCrossMargin:        *crossMargin,
+		SystemPromptTemplate: *template,
+		CustomPrompt:         customPrompt,
+		OverrideBasePrompt:   *overridePrompt,
+		OutputDir:            *outDir,
+	}, feed, aiClient)
+	if err != nil {
+		return err
+	}
+
+	result, err := engine.Run()
+	if err != nil {
+		return err
+	}
+
+	s := result.Summary
+	log.Printf("📊 回测结果已保存到 %s", *outDir)
+	log.Printf("   净值: %.2f → %.2f (%.2f%%) | 最大回撤: %.2f%% | 夏普: %.2f",
+		s.InitialBalance, s.FinalEquity, s.TotalReturnPct, s.MaxDrawdownPct, s.SharpeRatio)
+	log.Printf("   已实现盈亏: %.2f | 手续费: %.2f | 周期: %d (失败 %d)",
+		s.RealizedPnL, s.TotalFees, s.Cycles, s.FailedCycles)
+	return nil
+}
+
+// parseBacktestTime 解析回测时间参数（按UTC）
+func parseBacktestTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("时间不能为空")
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法识别的时间格式: %s", value)
+}
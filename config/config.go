@@ -13,6 +13,12 @@ type LeverageConfig struct {
 	AltcoinLeverage int `json:"altcoin_leverage"` // 山寨币的杠杆倍数（主账户建议5-20，子账户≤5）
 }
 
+// PaperTradingConfig 模拟盘配置
+type PaperTradingConfig struct {
+	FeeRate  float64 `json:"fee_rate"` // 手续费率（默认0.0004，即0.04%）
+	Slippage float64 `json:"slippage"` // 滑点比例（默认0.0005，即0.05%）
+}
+
 // LogConfig 日志配置
 type LogConfig struct {
 	Level    string          `json:"level"`    // 日志级别: debug, info, warn, error (默认: info)
@@ -29,19 +35,23 @@ type TelegramConfig struct {
 
 // Config 总配置
 type Config struct {
-	BetaMode           bool           `json:"beta_mode"`
-	APIServerPort      int            `json:"api_server_port"`
-	UseDefaultCoins    bool           `json:"use_default_coins"`
-	DefaultCoins       []string       `json:"default_coins"`
-	CoinPoolAPIURL     string         `json:"coin_pool_api_url"`
-	OITopAPIURL        string         `json:"oi_top_api_url"`
-	MaxDailyLoss       float64        `json:"max_daily_loss"`
-	MaxDrawdown        float64        `json:"max_drawdown"`
-	StopTradingMinutes int            `json:"stop_trading_minutes"`
-	Leverage           LeverageConfig `json:"leverage"`
-	JWTSecret          string         `json:"jwt_secret"`
-	DataKLineTime      string         `json:"data_k_line_time"`
-	Log                *LogConfig     `json:"log"` // 日志配置
+	BetaMode           bool                `json:"beta_mode"`
+	APIServerPort      int                 `json:"api_server_port"`
+	UseDefaultCoins    bool                `json:"use_default_coins"`
+	DefaultCoins       []string            `json:"default_coins"`
+	CoinPoolAPIURL     string              `json:"coin_pool_api_url"`
+	OITopAPIURL        string              `json:"oi_top_api_url"`
+	MaxDailyLoss       float64             `json:"max_daily_loss"`
+	MaxDrawdown        float64             `json:"max_drawdown"`
+	StopTradingMinutes int                 `json:"stop_trading_minutes"`
+	Leverage           LeverageConfig      `json:"leverage"`
+	PaperTrading       *PaperTradingConfig `json:"paper_trading"`       // 模拟盘配置（可选）
+	RecordAIResponses  bool                `json:"record_ai_responses"` // 是否录制AI请求与响应（用于离线回放）
+	JWTSecret          string              `json:"jwt_secret"`
+	KlineStorePath     string              `json:"kline_store_path"`     // 本地K线库路径（SQLite）
+	DecisionLogBackend string              `json:"decision_log_backend"` // 决策日志存储后端：file（默认）/ sqlite
+	DecisionLogDBPath  string              `json:"decision_log_db_path"` // SQLite决策日志库路径
+	Log                *LogConfig          `json:"log"`                  // 日志配置
 }
 
 // LoadConfig 从文件加载配置
@@ -194,6 +194,48 @@ func (d *Database) createTables() error {
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
 
+		// 订单账本表（系统下单与对账发现的订单，时间为毫秒时间戳）
+		`CREATE TABLE IF NOT EXISTS ledger_orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			trader_id TEXT NOT NULL,
+			exchange_order_id TEXT,
+			symbol TEXT NOT NULL,
+			side TEXT NOT NULL,
+			position_side TEXT NOT NULL,
+			order_type TEXT DEFAULT 'MARKET',
+			quantity REAL DEFAULT 0,
+			price REAL DEFAULT 0,
+			fee REAL DEFAULT 0,
+			leverage INTEGER DEFAULT 0,
+			reduce_only BOOLEAN DEFAULT 0,
+			origin TEXT NOT NULL,
+			status TEXT DEFAULT '',
+			order_time INTEGER NOT NULL,
+			UNIQUE (trader_id, exchange_order_id)
+		)`,
+
+		// 成交账本表（从交易所对账拉取的逐笔成交）
+		`CREATE TABLE IF NOT EXISTS ledger_fills (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			trader_id TEXT NOT NULL,
+			exchange_trade_id TEXT NOT NULL,
+			exchange_order_id TEXT DEFAULT '',
+			symbol TEXT NOT NULL,
+			side TEXT NOT NULL,
+			position_side TEXT NOT NULL,
+			quantity REAL NOT NULL,
+			price REAL NOT NULL,
+			fee REAL DEFAULT 0,
+			realized_pnl REAL DEFAULT 0,
+			reduce_only BOOLEAN DEFAULT 0,
+			origin TEXT NOT NULL,
+			fill_time INTEGER NOT NULL,
+			UNIQUE (trader_id, exchange_trade_id)
+		)`,
+
+		`CREATE INDEX IF NOT EXISTS idx_ledger_orders_trader_time ON ledger_orders(trader_id, order_time)`,
+		`CREATE INDEX IF NOT EXISTS idx_ledger_fills_trader_time ON ledger_fills(trader_id, fill_time)`,
+
 		// 触发器：自动更新 updated_at
 		`CREATE TRIGGER IF NOT EXISTS update_users_updated_at
 			AFTER UPDATE ON users
@@ -255,6 +297,20 @@ func (d *Database) createTables() error {
 		`ALTER TABLE traders ADD COLUMN use_coin_pool BOOLEAN DEFAULT 0`,               // 是否使用COIN POOL信号源
 		`ALTER TABLE traders ADD COLUMN use_oi_top BOOLEAN DEFAULT 0`,                  // 是否使用OI TOP信号源
 		`ALTER TABLE traders ADD COLUMN system_prompt_template TEXT DEFAULT 'default'`, // 系统提示词模板名称
+		`ALTER TABLE traders ADD COLUMN max_daily_loss REAL DEFAULT 0`,                 // 最大日亏损百分比（0=使用系统默认）
+		`ALTER TABLE traders ADD COLUMN max_drawdown REAL DEFAULT 0`,                   // 最大回撤百分比（0=使用系统默认）
+		`ALTER TABLE traders ADD COLUMN stop_trading_minutes INTEGER DEFAULT 0`,        // 触发风控后暂停分钟数（0=使用系统默认）
+		`ALTER TABLE traders ADD COLUMN flatten_on_risk_breach BOOLEAN DEFAULT 0`,      // 触发风控时是否强制平仓
+		`ALTER TABLE traders ADD COLUMN position_protection TEXT DEFAULT ''`,           // 持仓保护规则（JSON，空表示默认规则）
+		`ALTER TABLE traders ADD COLUMN market_data TEXT DEFAULT ''`,                   // 附加时间框架与指标配置（JSON，空表示仅默认数据）
+		`ALTER TABLE traders ADD COLUMN use_alert_coins BOOLEAN DEFAULT 0`,             // 是否将市场警报币种加入候选
+		`ALTER TABLE traders ADD COLUMN alert_wakeup BOOLEAN DEFAULT 0`,                // 是否由市场警报提前触发决策周期
+		`ALTER TABLE traders ADD COLUMN decision_triggers TEXT DEFAULT ''`,             // 事件触发决策周期配置（JSON，空表示仅定时扫描）
+		`ALTER TABLE traders ADD COLUMN max_depth_fraction REAL DEFAULT 0`,             // 开仓名义价值占 ±1% 盘口深度的上限（0=默认）
+		`ALTER TABLE traders ADD COLUMN cross_venue_funding BOOLEAN DEFAULT 0`,         // 是否向AI提供跨交易所资金费率差异
+		`ALTER TABLE traders ADD COLUMN ensemble_config TEXT DEFAULT ''`,               // 多模型集成决策配置（JSON，空表示仅使用主模型）
+		`ALTER TABLE traders ADD COLUMN fallback_models TEXT DEFAULT ''`,               // AI故障转移备用模型ID列表（JSON数组，按顺序尝试）
+		`ALTER TABLE traders ADD COLUMN decision_retention TEXT DEFAULT ''`,            // 决策日志保留策略（JSON，空表示只做系统提示词去重）
 		`ALTER TABLE ai_models ADD COLUMN custom_api_url TEXT DEFAULT ''`,              // 自定义API地址
 		`ALTER TABLE ai_models ADD COLUMN custom_model_name TEXT DEFAULT ''`,           // 自定义模型名称
 	}
@@ -300,6 +356,7 @@ func (d *Database) initDefaultData() error {
 		{"binance", "Binance Futures", "binance"},
 		{"hyperliquid", "Hyperliquid", "hyperliquid"},
 		{"aster", "Aster DEX", "aster"},
+		{"paper", "Paper Trading", "paper"},
 	}
 
 	for _, exchange := range exchanges {
@@ -325,6 +382,12 @@ func (d *Database) initDefaultData() error {
 		"altcoin_leverage":     "5",                                                                                   // 山寨币杠杆倍数
 		"jwt_secret":           "",                                                                                    // JWT密钥，默认为空，由config.json或系统生成
 		"registration_enabled": "true",                                                                                // 默认允许注册
+		"paper_fee_rate":       "0.0004",                                                                              // 模拟盘手续费率
+		"paper_slippage":       "0.0005",                                                                              // 模拟盘滑点比例
+		"record_ai_responses":  "false",                                                                               // 默认不录制AI请求与响应
+		"kline_store_path":     "market_data/klines.db",                                                               // 本地K线库路径，为空表示不持久化K线
+		"decision_log_backend": "file",                                                                                // 决策日志存储后端：file（JSON文件）/ sqlite
+		"decision_log_db_path": "decision_logs/decisions.db",                                                          // SQLite决策日志库路径
 	}
 
 	for key, value := range systemConfigs {
@@ -487,6 +550,20 @@ type TraderRecord struct {
 	OverrideBasePrompt   bool      `json:"override_base_prompt"`   // 是否覆盖基础prompt
 	SystemPromptTemplate string    `json:"system_prompt_template"` // 系统提示词模板名称
 	IsCrossMargin        bool      `json:"is_cross_margin"`        // 是否为全仓模式（true=全仓，false=逐仓）
+	MaxDailyLoss         float64   `json:"max_daily_loss"`         // 最大日亏损百分比（0=使用系统默认）
+	MaxDrawdown          float64   `json:"max_drawdown"`           // 最大回撤百分比（0=使用系统默认）
+	StopTradingMinutes   int       `json:"stop_trading_minutes"`   // 触发风控后暂停分钟数（0=使用系统默认）
+	FlattenOnRiskBreach  bool      `json:"flatten_on_risk_breach"` // 触发风控时是否强制平仓（否则仅拦截开仓）
+	PositionProtection   string    `json:"position_protection"`    // 持仓保护规则（JSON，空表示默认规则）
+	MarketData           string    `json:"market_data"`            // 附加时间框架与指标配置（JSON，空表示仅默认数据）
+	UseAlertCoins        bool      `json:"use_alert_coins"`        // 是否将市场警报币种加入候选
+	AlertWakeup          bool      `json:"alert_wakeup"`           // 是否由市场警报提前触发决策周期
+	DecisionTriggers     string    `json:"decision_triggers"`      // 事件触发决策周期配置（JSON，空表示仅定时扫描）
+	MaxDepthFraction     float64   `json:"max_depth_fraction"`     // 开仓名义价值占 ±1% 盘口深度的上限（0=默认）
+	CrossVenueFunding    bool      `json:"cross_venue_funding"`    // 是否向AI提供跨交易所资金费率差异
+	EnsembleConfig       string    `json:"ensemble_config"`        // 多模型集成决策配置（JSON，空表示仅使用主模型）
+	FallbackModels       string    `json:"fallback_models"`        // AI故障转移备用模型ID列表（JSON数组，按顺序尝试）
+	DecisionRetention    string    `json:"decision_retention"`     // 决策日志保留策略（JSON，空表示只做系统提示词去重）
 	CreatedAt            time.Time `json:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at"`
 }
@@ -849,6 +926,9 @@ func (d *Database) UpdateExchange(userID, id string, enabled bool, apiKey, secre
 		} else if id == "aster" {
 			name = "Aster DEX"
 			typ = "dex"
+		} else if id == "paper" {
+			name = "Paper Trading"
+			typ = "paper"
 		} else {
 			name = id + " Exchange"
 			typ = "cex"
@@ -901,9 +981,9 @@ func (d *Database) CreateExchange(userID, id, name, typ string, enabled bool, ap
 // CreateTrader 创建交易员
 func (d *Database) CreateTrader(trader *TraderRecord) error {
 	_, err := d.db.Exec(`
-		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin)
+		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin, max_daily_loss, max_drawdown, stop_trading_minutes, flatten_on_risk_breach, position_protection, market_data, use_alert_coins, alert_wakeup, decision_triggers, max_depth_fraction, cross_venue_funding, ensemble_config, fallback_models, decision_retention)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin, trader.MaxDailyLoss, trader.MaxDrawdown, trader.StopTradingMinutes, trader.FlattenOnRiskBreach, trader.PositionProtection, trader.MarketData, trader.UseAlertCoins, trader.AlertWakeup, trader.DecisionTriggers, trader.MaxDepthFraction, trader.CrossVenueFunding, trader.EnsembleConfig, trader.FallbackModels, trader.DecisionRetention)
 	return err
 }
 
@@ -916,7 +996,16 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 		       COALESCE(use_coin_pool, 0) as use_coin_pool, COALESCE(use_oi_top, 0) as use_oi_top,
 		       COALESCE(custom_prompt, '') as custom_prompt, COALESCE(override_base_prompt, 0) as override_base_prompt,
 		       COALESCE(system_prompt_template, 'default') as system_prompt_template,
-		       COALESCE(is_cross_margin, 1) as is_cross_margin, created_at, updated_at
+		       COALESCE(is_cross_margin, 1) as is_cross_margin,
+		       COALESCE(max_daily_loss, 0) as max_daily_loss, COALESCE(max_drawdown, 0) as max_drawdown,
+		       COALESCE(stop_trading_minutes, 0) as stop_trading_minutes,
+		       COALESCE(flatten_on_risk_breach, 0) as flatten_on_risk_breach,
+		       COALESCE(position_protection, '') as position_protection,
+		       COALESCE(market_data, '') as market_data,
+		       COALESCE(use_alert_coins, 0) as use_alert_coins, COALESCE(alert_wakeup, 0) as alert_wakeup,
+		       COALESCE(decision_triggers, '') as decision_triggers, COALESCE(max_depth_fraction, 0) as max_depth_fraction,
+		       COALESCE(cross_venue_funding, 0) as cross_venue_funding, COALESCE(ensemble_config, '') as ensemble_config, COALESCE(fallback_models, '') as fallback_models, COALESCE(decision_retention, '') as decision_retention,
+		       created_at, updated_at
 		FROM traders WHERE user_id = ? ORDER BY created_at DESC
 	`, userID)
 	if err != nil {
@@ -934,6 +1023,8 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 			&trader.UseCoinPool, &trader.UseOITop,
 			&trader.CustomPrompt, &trader.OverrideBasePrompt, &trader.SystemPromptTemplate,
 			&trader.IsCrossMargin,
+			&trader.MaxDailyLoss, &trader.MaxDrawdown, &trader.StopTradingMinutes, &trader.FlattenOnRiskBreach,
+			&trader.PositionProtection, &trader.MarketData, &trader.UseAlertCoins, &trader.AlertWakeup, &trader.DecisionTriggers, &trader.MaxDepthFraction, &trader.CrossVenueFunding, &trader.EnsembleConfig, &trader.FallbackModels, &trader.DecisionRetention,
 			&trader.CreatedAt, &trader.UpdatedAt,
 		)
 		if err != nil {
@@ -958,12 +1049,17 @@ func (d *Database) UpdateTrader(trader *TraderRecord) error {
 			name = ?, ai_model_id = ?, exchange_id = ?,
 			scan_interval_minutes = ?, btc_eth_leverage = ?, altcoin_leverage = ?,
 			trading_symbols = ?, custom_prompt = ?, override_base_prompt = ?,
-			system_prompt_template = ?, is_cross_margin = ?, updated_at = CURRENT_TIMESTAMP
+			system_prompt_template = ?, is_cross_margin = ?,
+			max_daily_loss = ?, max_drawdown = ?, stop_trading_minutes = ?, flatten_on_risk_breach = ?,
+			position_protection = ?, market_data = ?, use_alert_coins = ?, alert_wakeup = ?, decision_triggers = ?, max_depth_fraction = ?, cross_venue_funding = ?, ensemble_config = ?, fallback_models = ?, decision_retention = ?,
+			updated_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND user_id = ?
 	`, trader.Name, trader.AIModelID, trader.ExchangeID,
 		trader.ScanIntervalMinutes, trader.BTCETHLeverage, trader.AltcoinLeverage,
 		trader.TradingSymbols, trader.CustomPrompt, trader.OverrideBasePrompt,
-		trader.SystemPromptTemplate, trader.IsCrossMargin, trader.ID, trader.UserID)
+		trader.SystemPromptTemplate, trader.IsCrossMargin,
+		trader.MaxDailyLoss, trader.MaxDrawdown, trader.StopTradingMinutes, trader.FlattenOnRiskBreach,
+		trader.PositionProtection, trader.MarketData, trader.UseAlertCoins, trader.AlertWakeup, trader.DecisionTriggers, trader.MaxDepthFraction, trader.CrossVenueFunding, trader.EnsembleConfig, trader.FallbackModels, trader.DecisionRetention, trader.ID, trader.UserID)
 	return err
 }
 
@@ -1004,6 +1100,20 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 			COALESCE(t.override_base_prompt, 0) as override_base_prompt,
 			COALESCE(t.system_prompt_template, 'default') as system_prompt_template,
 			COALESCE(t.is_cross_margin, 1) as is_cross_margin,
+			COALESCE(t.max_daily_loss, 0) as max_daily_loss,
+			COALESCE(t.max_drawdown, 0) as max_drawdown,
+			COALESCE(t.stop_trading_minutes, 0) as stop_trading_minutes,
+			COALESCE(t.flatten_on_risk_breach, 0) as flatten_on_risk_breach,
+			COALESCE(t.position_protection, '') as position_protection,
+			COALESCE(t.market_data, '') as market_data,
+			COALESCE(t.use_alert_coins, 0) as use_alert_coins,
+			COALESCE(t.alert_wakeup, 0) as alert_wakeup,
+			COALESCE(t.decision_triggers, '') as decision_triggers,
+			COALESCE(t.max_depth_fraction, 0) as max_depth_fraction,
+			COALESCE(t.cross_venue_funding, 0) as cross_venue_funding,
+			COALESCE(t.ensemble_config, '') as ensemble_config,
+			COALESCE(t.fallback_models, '') as fallback_models,
+			COALESCE(t.decision_retention, '') as decision_retention,
 			t.created_at, t.updated_at,
 			a.id, a.user_id, a.name, a.provider, a.enabled, a.api_key,
 			COALESCE(a.custom_api_url, '') as custom_api_url,
@@ -1026,6 +1136,8 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 		&trader.UseCoinPool, &trader.UseOITop,
 		&trader.CustomPrompt, &trader.OverrideBasePrompt, &trader.SystemPromptTemplate,
 		&trader.IsCrossMargin,
+		&trader.MaxDailyLoss, &trader.MaxDrawdown, &trader.StopTradingMinutes, &trader.FlattenOnRiskBreach,
+		&trader.PositionProtection, &trader.MarketData, &trader.UseAlertCoins, &trader.AlertWakeup, &trader.DecisionTriggers, &trader.MaxDepthFraction, &trader.CrossVenueFunding, &trader.EnsembleConfig, &trader.FallbackModels, &trader.DecisionRetention,
 		&trader.CreatedAt, &trader.UpdatedAt,
 		&aiModel.ID, &aiModel.UserID, &aiModel.Name, &aiModel.Provider, &aiModel.Enabled, &aiModel.APIKey,
 		&aiModel.CustomAPIURL, &aiModel.CustomModelName,
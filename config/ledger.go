@@ -0,0 +1,197 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// 订单来源
+const (
+	OrderOriginAI      = "ai"      // AI决策下单
+	OrderOriginStop    = "stop"    // 交易所侧止损/止盈/强平
+	OrderOriginMonitor = "monitor" // 持仓保护监控平仓
+	OrderOriginManual  = "manual"  // 系统外的人工操作
+)
+
+// LedgerOrder 订单账本记录
+type LedgerOrder struct {
+	ID              int64     `json:"id"`
+	TraderID        string    `json:"trader_id"`
+	ExchangeOrderID string    `json:"exchange_order_id"` // 交易所订单ID（交易所未返回时为空）
+	Symbol          string    `json:"symbol"`
+	Side            string    `json:"side"`          // BUY / SELL
+	PositionSide    string    `json:"position_side"` // LONG / SHORT
+	OrderType       string    `json:"order_type"`    // MARKET / STOP_MARKET / TAKE_PROFIT_MARKET ...
+	Quantity        float64   `json:"quantity"`      // 下单数量（全部平仓且数量未知时为0）
+	Price           float64   `json:"price"`         // 成交均价（未知时为下单时的市价）
+	Fee             float64   `json:"fee"`
+	Leverage        int       `json:"leverage"` // 开仓杠杆（未知时为0）
+	ReduceOnly      bool      `json:"reduce_only"`
+	Origin          string    `json:"origin"` // ai / stop / monitor / manual
+	Status          string    `json:"status"`
+	OrderTime       time.Time `json:"order_time"`
+}
+
+// LedgerFill 成交账本记录（对账时从交易所拉取）
+type LedgerFill struct {
+	ID              int64     `json:"id"`
+	TraderID        string    `json:"trader_id"`
+	ExchangeTradeID string    `json:"exchange_trade_id"`
+	ExchangeOrderID string    `json:"exchange_order_id"`
+	Symbol          string    `json:"symbol"`
+	Side            string    `json:"side"`          // BUY / SELL
+	PositionSide    string    `json:"position_side"` // LONG / SHORT
+	Quantity        float64   `json:"quantity"`
+	Price           float64   `json:"price"`
+	Fee             float64   `json:"fee"`
+	RealizedPnL     float64   `json:"realized_pnl"` // 交易所结算的已实现盈亏（不含手续费）
+	ReduceOnly      bool      `json:"reduce_only"`
+	Origin          string    `json:"origin"`
+	FillTime        time.Time `json:"fill_time"`
+}
+
+// RecordOrder 写入订单（同一交易所订单ID重复写入时更新成交信息）
+func (d *Database) RecordOrder(order *LedgerOrder) error {
+	var exchangeOrderID interface{}
+	if order.ExchangeOrderID != "" {
+		exchangeOrderID = order.ExchangeOrderID
+	}
+
+	result, err := d.db.Exec(`
+		INSERT INTO ledger_orders (trader_id, exchange_order_id, symbol, side, position_side, order_type, quantity, price, fee, leverage, reduce_only, origin, status, order_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(trader_id, exchange_order_id) DO UPDATE SET
+			quantity = CASE WHEN excluded.quantity > 0 THEN excluded.quantity ELSE quantity END,
+			price = CASE WHEN excluded.price > 0 THEN excluded.price ELSE price END,
+			fee = CASE WHEN excluded.fee > 0 THEN excluded.fee ELSE fee END,
+			leverage = CASE WHEN excluded.leverage > 0 THEN excluded.leverage ELSE leverage END,
+			status = CASE WHEN excluded.status != '' THEN excluded.status ELSE status END
+	`, order.TraderID, exchangeOrderID, order.Symbol, order.Side, order.PositionSide, order.OrderType,
+		order.Quantity, order.Price, order.Fee, order.Leverage, order.ReduceOnly, order.Origin, order.Status, order.OrderTime.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("写入订单账本失败: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		order.ID = id
+	}
+	return nil
+}
+
+// RecordFill 写入成交（按交易所成交ID去重），返回是否为新成交
+func (d *Database) RecordFill(fill *LedgerFill) (bool, error) {
+	result, err := d.db.Exec(`
+		INSERT OR IGNORE INTO ledger_fills (trader_id, exchange_trade_id, exchange_order_id, symbol, side, position_side, quantity, price, fee, realized_pnl, reduce_only, origin, fill_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, fill.TraderID, fill.ExchangeTradeID, fill.ExchangeOrderID, fill.Symbol, fill.Side, fill.PositionSide,
+		fill.Quantity, fill.Price, fill.Fee, fill.RealizedPnL, fill.ReduceOnly, fill.Origin, fill.FillTime.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("写入成交账本失败: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		if id, err := result.LastInsertId(); err == nil {
+			fill.ID = id
+		}
+	}
+	return affected > 0, nil
+}
+
+// GetLedgerOrder 按交易所订单ID查询订单（不存在时返回nil）
+func (d *Database) GetLedgerOrder(traderID, exchangeOrderID string) (*LedgerOrder, error) {
+	rows, err := d.db.Query(ledgerOrderColumns+` WHERE trader_id = ? AND exchange_order_id = ?`, traderID, exchangeOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("查询订单账本失败: %w", err)
+	}
+	defer rows.Close()
+
+	orders, err := scanLedgerOrders(rows)
+	if err != nil || len(orders) == 0 {
+		return nil, err
+	}
+	return orders[0], nil
+}
+
+// GetLedgerOrders 获取 since 之后的订单（按时间正序）
+func (d *Database) GetLedgerOrders(traderID string, since time.Time) ([]*LedgerOrder, error) {
+	rows, err := d.db.Query(ledgerOrderColumns+` WHERE trader_id = ? AND order_time >= ? ORDER BY order_time, id`,
+		traderID, since.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("查询订单账本失败: %w", err)
+	}
+	defer rows.Close()
+	return scanLedgerOrders(rows)
+}
+
+// GetLedgerFills 获取 since 之后的成交（按时间正序）
+func (d *Database) GetLedgerFills(traderID string, since time.Time) ([]*LedgerFill, error) {
+	rows, err := d.db.Query(`
+		SELECT id, trader_id, exchange_trade_id, COALESCE(exchange_order_id, ''), symbol, side, position_side,
+		       quantity, price, COALESCE(fee, 0), COALESCE(realized_pnl, 0), COALESCE(reduce_only, 0), origin, fill_time
+		FROM ledger_fills WHERE trader_id = ? AND fill_time >= ? ORDER BY fill_time, id
+	`, traderID, since.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("查询成交账本失败: %w", err)
+	}
+	defer rows.Close()
+
+	var fills []*LedgerFill
+	for rows.Next() {
+		var fill LedgerFill
+		var fillTime int64
+		if err := rows.Scan(&fill.ID, &fill.TraderID, &fill.ExchangeTradeID, &fill.ExchangeOrderID,
+			&fill.Symbol, &fill.Side, &fill.PositionSide, &fill.Quantity, &fill.Price,
+			&fill.Fee, &fill.RealizedPnL, &fill.ReduceOnly, &fill.Origin, &fillTime); err != nil {
+			return nil, err
+		}
+		fill.FillTime = time.UnixMilli(fillTime)
+		fills = append(fills, &fill)
+	}
+	return fills, rows.Err()
+}
+
+// GetLatestFillTime 获取某币种最近一笔成交的时间（symbol 为空时不区分币种，没有成交时返回零值）
+func (d *Database) GetLatestFillTime(traderID, symbol string) (time.Time, error) {
+	var latest sql.NullInt64
+	var err error
+	if symbol == "" {
+		err = d.db.QueryRow(`SELECT MAX(fill_time) FROM ledger_fills WHERE trader_id = ?`, traderID).Scan(&latest)
+	} else {
+		err = d.db.QueryRow(`SELECT MAX(fill_time) FROM ledger_fills WHERE trader_id = ? AND symbol = ?`,
+			traderID, symbol).Scan(&latest)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("查询最近成交时间失败: %w", err)
+	}
+	if !latest.Valid {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(latest.Int64), nil
+}
+
+// ledgerOrderColumns 订单账本查询列
+const ledgerOrderColumns = `
+	SELECT id, trader_id, COALESCE(exchange_order_id, ''), symbol, side, position_side, COALESCE(order_type, 'MARKET'),
+	       COALESCE(quantity, 0), COALESCE(price, 0), COALESCE(fee, 0), COALESCE(leverage, 0),
+	       COALESCE(reduce_only, 0), origin, COALESCE(status, ''), order_time
+	FROM ledger_orders`
+
+// scanLedgerOrders 扫描订单账本查询结果
+func scanLedgerOrders(rows *sql.Rows) ([]*LedgerOrder, error) {
+	var orders []*LedgerOrder
+	for rows.Next() {
+		var order LedgerOrder
+		var orderTime int64
+		if err := rows.Scan(&order.ID, &order.TraderID, &order.ExchangeOrderID, &order.Symbol, &order.Side,
+			&order.PositionSide, &order.OrderType, &order.Quantity, &order.Price, &order.Fee,
+			&order.Leverage, &order.ReduceOnly, &order.Origin, &order.Status, &orderTime); err != nil {
+			return nil, err
+		}
+		order.OrderTime = time.UnixMilli(orderTime)
+		orders = append(orders, &order)
+	}
+	return orders, rows.Err()
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLedgerOrders 测试订单写入与按交易所订单ID更新
+func TestLedgerOrders(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now().Truncate(time.Millisecond)
+	order := &LedgerOrder{
+		TraderID:        "trader-1",
+		ExchangeOrderID: "1001",
+		Symbol:          "BTCUSDT",
+		Side:            "BUY",
+		PositionSide:    "LONG",
+		OrderType:       "MARKET",
+		Quantity:        0.1,
+		Price:           50000,
+		Leverage:        5,
+		Origin:          OrderOriginAI,
+		OrderTime:       now,
+	}
+	if err := db.RecordOrder(order); err != nil {
+		t.Fatalf("写入订单失败: %v", err)
+	}
+
+	// 对账时用实际成交价更新，来源与杠杆保持不变
+	if err := db.RecordOrder(&LedgerOrder{
+		TraderID:        "trader-1",
+		ExchangeOrderID: "1001",
+		Symbol:          "BTCUSDT",
+		Side:            "BUY",
+		PositionSide:    "LONG",
+		Quantity:        0.1,
+		Price:           50010,
+		Fee:             2,
+		Origin:          OrderOriginManual,
+		OrderTime:       now.Add(time.Second),
+	}); err != nil {
+		t.Fatalf("更新订单失败: %v", err)
+	}
+
+	// 交易所未返回订单ID的订单可以重复写入
+	for i := 0; i < 2; i++ {
+		if err := db.RecordOrder(&LedgerOrder{TraderID: "trader-1", Symbol: "ETHUSDT", Side: "SELL", PositionSide: "LONG",
+			ReduceOnly: true, Origin: OrderOriginMonitor, OrderTime: now.Add(time.Minute)}); err != nil {
+			t.Fatalf("写入无ID订单失败: %v", err)
+		}
+	}
+
+	got, err := db.GetLedgerOrder("trader-1", "1001")
+	if err != nil || got == nil {
+		t.Fatalf("查询订单失败: %v", err)
+	}
+	if got.Price != 50010 || got.Fee != 2 || got.Leverage != 5 || got.Origin != OrderOriginAI || !got.OrderTime.Equal(now) {
+		t.Errorf("订单更新不正确: %+v", got)
+	}
+
+	orders, err := db.GetLedgerOrders("trader-1", time.Time{})
+	if err != nil {
+		t.Fatalf("查询订单列表失败: %v", err)
+	}
+	if len(orders) != 3 {
+		t.Fatalf("期望3个订单，实际 %d", len(orders))
+	}
+	if orders[2].ExchangeOrderID != "" || !orders[2].ReduceOnly {
+		t.Errorf("无ID订单读取不正确: %+v", orders[2])
+	}
+
+	if missing, err := db.GetLedgerOrder("trader-2", "1001"); err != nil || missing != nil {
+		t.Errorf("其他交易员不应查到订单: %+v, %v", missing, err)
+	}
+}
+
+// TestLedgerFills 测试成交去重与最近成交时间
+func TestLedgerFills(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	latest, err := db.GetLatestFillTime("trader-1", "")
+	if err != nil || !latest.IsZero() {
+		t.Fatalf("空账本应返回零值: %v, %v", latest, err)
+	}
+
+	base := time.Now().Truncate(time.Millisecond)
+	fills := []*LedgerFill{
+		{TraderID: "trader-1", ExchangeTradeID: "t1", ExchangeOrderID: "1001", Symbol: "BTCUSDT", Side: "BUY",
+			PositionSide: "LONG", Quantity: 0.1, Price: 50000, Origin: OrderOriginAI, FillTime: base},
+		{TraderID: "trader-1", ExchangeTradeID: "t2", ExchangeOrderID: "1002", Symbol: "BTCUSDT", Side: "SELL",
+			PositionSide: "LONG", Quantity: 0.1, Price: 49000, RealizedPnL: -100, ReduceOnly: true,
+			Origin: OrderOriginStop, FillTime: base.Add(time.Hour)},
+	}
+	for _, fill := range fills {
+		inserted, err := db.RecordFill(fill)
+		if err != nil || !inserted {
+			t.Fatalf("写入成交失败: %v", err)
+		}
+	}
+
+	inserted, err := db.RecordFill(&LedgerFill{TraderID: "trader-1", ExchangeTradeID: "t1", Symbol: "BTCUSDT",
+		Side: "BUY", PositionSide: "LONG", Quantity: 1, Price: 1, Origin: OrderOriginAI, FillTime: base})
+	if err != nil || inserted {
+		t.Errorf("重复成交应被忽略: inserted=%v err=%v", inserted, err)
+	}
+
+	got, err := db.GetLedgerFills("trader-1", base.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("查询成交失败: %v", err)
+	}
+	if len(got) != 1 || got[0].ExchangeTradeID != "t2" || got[0].RealizedPnL != -100 || !got[0].ReduceOnly {
+		t.Errorf("成交读取不正确: %+v", got)
+	}
+
+	latest, err = db.GetLatestFillTime("trader-1", "")
+	if err != nil || !latest.Equal(base.Add(time.Hour)) {
+		t.Errorf("最近成交时间不正确: %v, %v", latest, err)
+	}
+
+	// 按币种统计：其他币种的成交不推进该币种的对账游标
+	if _, err := db.RecordFill(&LedgerFill{TraderID: "trader-1", ExchangeTradeID: "t3", ExchangeOrderID: "1003",
+		Symbol: "ETHUSDT", Side: "BUY", PositionSide: "LONG", Quantity: 1, Price: 3000, Origin: OrderOriginAI,
+		FillTime: base.Add(2 * time.Hour)}); err != nil {
+		t.Fatalf("写入成交失败: %v", err)
+	}
+	latest, err = db.GetLatestFillTime("trader-1", "BTCUSDT")
+	if err != nil || !latest.Equal(base.Add(time.Hour)) {
+		t.Errorf("BTCUSDT 最近成交时间不正确: %v, %v", latest, err)
+	}
+	latest, err = db.GetLatestFillTime("trader-1", "SOLUSDT")
+	if err != nil || !latest.IsZero() {
+		t.Errorf("没有成交的币种应返回零值: %v, %v", latest, err)
+	}
+}
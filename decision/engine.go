@@ -9,6 +9,7 @@ import (
 	"nofx/mcp"
 	"nofx/pool"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
@@ -59,7 +60,8 @@ type AccountInfo struct {
 // CandidateCoin 候选币种（来自币种池）
 type CandidateCoin struct {
 	Symbol  string   `json:"symbol"`
-	Sources []string `json:"sources"` // 来源: "ai500" 和/或 "oi_top"
+	Sources []string `json:"sources"`          // 来源: "ai500" 和/或 "oi_top"，近期触发警报时含 "alert"
+	Alerts  []string `json:"alerts,omitempty"` // 近期触发的市场警报
 }
 
 // OITopData 持仓量增长Top数据（用于AI决策参考）
@@ -72,6 +74,18 @@ type OITopData struct {
 	NetShort          float64 // 净空仓
 }
 
+// PendingOrderInfo 未成交的限价开仓单
+type PendingOrderInfo struct {
+	Symbol     string  `json:"symbol"`
+	Side       string  `json:"side"`       // "long" or "short"
+	OrderType  string  `json:"order_type"` // limit / post_only
+	Price      float64 `json:"price"`
+	Quantity   float64 `json:"quantity"`
+	StopLoss   float64 `json:"stop_loss"`
+	TakeProfit float64 `json:"take_profit"`
+	PlacedTime int64   `json:"placed_time"` // 挂单时间戳（毫秒）
+}
+
 // Context 交易上下文（传递给AI的完整信息）
 type Context struct {
 	CurrentTime     string                  `json:"current_time"`
@@ -79,14 +93,48 @@ type Context struct {
 	CallCount       int                     `json:"call_count"`
 	Account         AccountInfo             `json:"account"`
 	Positions       []PositionInfo          `json:"positions"`
+	PendingOrders   []PendingOrderInfo      `json:"pending_orders"`
 	CandidateCoins  []CandidateCoin         `json:"candidate_coins"`
 	MarketDataMap   map[string]*market.Data `json:"-"` // 不序列化，但内部使用
 	OITopDataMap    map[string]*OITopData   `json:"-"` // OI Top数据映射
 	Performance     interface{}             `json:"-"` // 历史表现分析（logger.PerformanceAnalysis）
 	BTCETHLeverage  int                     `json:"-"` // BTC/ETH杠杆倍数（从配置读取）
 	AltcoinLeverage int                     `json:"-"` // 山寨币杠杆倍数（从配置读取）
+	// MaxDepthFraction 单笔开仓名义价值占开仓方向 ±1% 盘口深度的上限（0表示使用 DefaultMaxDepthFraction）
+	MaxDepthFraction float64 `json:"-"`
+	// FundingDivergence 跨交易所资金费率与标记价格差异（未开启时为空）
+	FundingDivergence map[string]*market.FundingDivergence `json:"-"`
+
+	// MarketDataFunc 市场数据来源（为空时使用 market.Get，回测时注入历史数据）
+	MarketDataFunc func(symbol string) (*market.Data, error) `json:"-"`
+	// Now 决策时刻（为空时使用 time.Now，回测时为模拟时间）
+	Now time.Time `json:"-"`
+	// Trigger 提前触发本周期的事件（定时扫描时为空）
+	Trigger *TriggerInfo `json:"trigger,omitempty"`
+}
+
+// TriggerInfo 提前触发决策周期的事件
+type TriggerInfo struct {
+	Type   string `json:"type"`             // price_move / volume_spike / stop_proximity / ...
+	Symbol string `json:"symbol,omitempty"` // 相关币种
+	Reason string `json:"reason"`           // 事件描述
 }
 
+// now 返回上下文的当前时间
+func (ctx *Context) now() time.Time {
+	if ctx.Now.IsZero() {
+		return time.Now()
+	}
+	return ctx.Now
+}
+
+// 开仓订单类型
+const (
+	OrderTypeMarket   = "market"    // 市价单（默认）
+	OrderTypeLimit    = "limit"     // 限价单
+	OrderTypePostOnly = "post_only" // 只做Maker的限价单（会立即成交时被交易所撤销）
+)
+
 // Decision AI的交易决策
 type Decision struct {
 	Symbol string `json:"symbol"`
@@ -97,6 +145,8 @@ type Decision struct {
 	PositionSizeUSD float64 `json:"position_size_usd,omitempty"`
 	StopLoss        float64 `json:"stop_loss,omitempty"`
 	TakeProfit      float64 `json:"take_profit,omitempty"`
+	OrderType       string  `json:"order_type,omitempty"`  // market（默认）/ limit / post_only
+	EntryPrice      float64 `json:"entry_price,omitempty"` // 限价单挂单价格
 
 	// 调整参数（新增）
 	NewStopLoss     float64 `json:"new_stop_loss,omitempty"`    // 用于 update_stop_loss
@@ -109,6 +159,11 @@ type Decision struct {
 	Reasoning  string  `json:"reasoning"`
 }
 
+// IsLimitOrder 是否以限价单开仓
+func (d *Decision) IsLimitOrder() bool {
+	return d.OrderType == OrderTypeLimit || d.OrderType == OrderTypePostOnly
+}
+
 // FullDecision AI的完整决策（包含思维链）
 type FullDecision struct {
 	SystemPrompt string     `json:"system_prompt"` // 系统提示词（发送给AI的系统prompt）
@@ -116,8 +171,17 @@ type FullDecision struct {
 	CoTTrace     string     `json:"cot_trace"`     // 思维链分析（AI输出）
 	Decisions    []Decision `json:"decisions"`     // 具体决策列表
 	Timestamp    time.Time  `json:"timestamp"`
+	// RawResponse AI原始响应（解析前），多模型集成时记录在各模型的 ModelResponse 中
+	RawResponse string `json:"raw_response,omitempty"`
 	// AIRequestDurationMs 记录 AI API 调用耗时（毫秒）方便排查延迟问题
 	AIRequestDurationMs int64 `json:"ai_request_duration_ms,omitempty"`
+	// EnsemblePolicy 多模型集成策略，ModelResponses 为各模型（含仲裁模型）的原始响应（单模型决策时为空）
+	EnsemblePolicy string          `json:"ensemble_policy,omitempty"`
+	ModelResponses []ModelResponse `json:"model_responses,omitempty"`
+	// AIProvider 实际应答的AI提供商（配置了故障转移链时记录，可能不是首选提供商）
+	AIProvider string `json:"ai_provider,omitempty"`
+	// Adjustments 校验前被缩减或剔除的决策及原因（如超过盘口深度的开仓），写入执行日志
+	Adjustments []string `json:"adjustments,omitempty"`
 }
 
 // GetFullDecision 获取AI的完整交易决策（批量分析所有币种和持仓）
@@ -136,32 +200,66 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient mcp.AIClient, custo
 	systemPrompt := buildSystemPromptWithCustom(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, customPrompt, overrideBase, templateName)
 	userPrompt := buildUserPrompt(ctx)
 
-	// 3. 调用AI API（使用 system + user prompt）
+	// 3. 调用AI API并解析响应
+	return requestDecision(mcpClient, systemPrompt, userPrompt, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, newLiquidityGuard(ctx))
+}
+
+// ReplayDecision 使用已保存的提示词重新请求并解析决策
+// 配合 mcp.ReplayClient 可离线复现 parseFullDecisionResponse 的失败，或用新的校验逻辑重跑历史周期
+func ReplayDecision(mcpClient mcp.AIClient, systemPrompt, userPrompt string, accountEquity float64, btcEthLeverage, altcoinLeverage int) (*FullDecision, error) {
+	return requestDecision(mcpClient, systemPrompt, userPrompt, accountEquity, btcEthLeverage, altcoinLeverage, nil)
+}
+
+// callAI 调用AI：支持 Function Calling 的客户端走结构化输出
+// 仅当提供商拒绝 tools 或响应中没有工具调用时回退到文本输出；超时、网络等其他错误直接返回，避免重复调用
+func callAI(mcpClient mcp.AIClient, systemPrompt, userPrompt string) (string, error) {
+	if mcp.SupportsFunctionCalling(mcpClient) {
+		req, err := buildDecisionRequest(systemPrompt, userPrompt)
+		if err != nil {
+			return "", fmt.Errorf("构建结构化决策请求失败: %w", err)
+		}
+		response, err := mcpClient.CallWithRequest(req)
+		switch {
+		case err == nil:
+			if _, _, ok := parseStructuredDecision(response); ok {
+				return response, nil
+			}
+			log.Printf("⚠️  模型未返回工具调用，回退到文本输出")
+		case mcp.IsToolsRejected(err):
+			log.Printf("⚠️  提供商不支持 tools，回退到文本输出: %v", err)
+		default:
+			return "", err
+		}
+	}
+	return mcpClient.CallWithMessages(systemPrompt, userPrompt)
+}
+
+// requestDecision 调用AI并解析完整决策（guard 为空时不检查盘口深度）
+func requestDecision(mcpClient mcp.AIClient, systemPrompt, userPrompt string, accountEquity float64, btcEthLeverage, altcoinLeverage int, guard *liquidityGuard) (*FullDecision, error) {
 	aiCallStart := time.Now()
-	aiResponse, err := mcpClient.CallWithMessages(systemPrompt, userPrompt)
+	aiResponse, err := callAI(mcpClient, systemPrompt, userPrompt)
 	aiCallDuration := time.Since(aiCallStart)
 	if err != nil {
 		return nil, fmt.Errorf("调用AI API失败: %w", err)
 	}
 
-	// 4. 解析AI响应
-	decision, err := parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+	// 解析AI响应
+	decision, err := parseFullDecisionResponse(aiResponse, accountEquity, btcEthLeverage, altcoinLeverage, guard)
 
 	// 无论是否有错误，都要保存 SystemPrompt 和 UserPrompt（用于调试和决策未执行后的问题定位）
 	if decision != nil {
 		decision.Timestamp = time.Now()
 		decision.SystemPrompt = systemPrompt // 保存系统prompt
 		decision.UserPrompt = userPrompt     // 保存输入prompt
+		decision.RawResponse = aiResponse
 		decision.AIRequestDurationMs = aiCallDuration.Milliseconds()
+		decision.AIProvider = mcp.AnsweredBy(mcpClient)
 	}
 
 	if err != nil {
 		return decision, fmt.Errorf("解析AI响应失败: %w", err)
 	}
 
-	decision.Timestamp = time.Now()
-	decision.SystemPrompt = systemPrompt // 保存系统prompt
-	decision.UserPrompt = userPrompt     // 保存输入prompt
 	return decision, nil
 }
 
@@ -194,8 +292,13 @@ func fetchMarketDataForContext(ctx *Context) error {
 		positionSymbols[pos.Symbol] = true
 	}
 
+	getMarketData := market.Get
+	if ctx.MarketDataFunc != nil {
+		getMarketData = ctx.MarketDataFunc
+	}
+
 	for symbol := range symbolSet {
-		data, err := market.Get(symbol)
+		data, err := getMarketData(symbol)
 		if err != nil {
 			// 单个币种失败不影响整体，只记录错误
 			continue
@@ -356,7 +459,9 @@ func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage in
 	sb.WriteString("- 开仓时必填: leverage, position_size_usd, stop_loss, take_profit, confidence, risk_usd, reasoning\n")
 	sb.WriteString("- update_stop_loss 时必填: new_stop_loss (注意是 new_stop_loss，不是 stop_loss)\n")
 	sb.WriteString("- update_take_profit 时必填: new_take_profit (注意是 new_take_profit，不是 take_profit)\n")
-	sb.WriteString("- partial_close 时必填: close_percentage (0-100)\n\n")
+	sb.WriteString("- partial_close 时必填: close_percentage (0-100)\n")
+	sb.WriteString("- `order_type`(开仓可选): market（默认，市价立即成交）| limit（限价挂单）| post_only（只做Maker，会立即成交则撤单）\n")
+	sb.WriteString("- limit/post_only 时必填: entry_price（挂单价，止损止盈需位于挂单价两侧）；挂单超时未成交会自动撤单\n\n")
 
 	return sb.String()
 }
@@ -369,6 +474,15 @@ func buildUserPrompt(ctx *Context) string {
 	sb.WriteString(fmt.Sprintf("时间: %s | 周期: #%d | 运行: %d分钟\n\n",
 		ctx.CurrentTime, ctx.CallCount, ctx.RuntimeMinutes))
 
+	// 事件触发（非定时扫描）
+	if ctx.Trigger != nil {
+		sb.WriteString(fmt.Sprintf("⚡ 本周期由事件提前触发 [%s]: %s\n", ctx.Trigger.Type, ctx.Trigger.Reason))
+		if ctx.Trigger.Symbol != "" {
+			sb.WriteString(fmt.Sprintf("请优先评估 %s 的持仓或机会；若无需操作可选择观望。\n", ctx.Trigger.Symbol))
+		}
+		sb.WriteString("\n")
+	}
+
 	// BTC 市场
 	if btcData, hasBTC := ctx.MarketDataMap["BTCUSDT"]; hasBTC {
 		sb.WriteString(fmt.Sprintf("BTC: %.2f (1h: %+.2f%%, 4h: %+.2f%%) | MACD: %.4f | RSI: %.2f\n\n",
@@ -392,7 +506,7 @@ func buildUserPrompt(ctx *Context) string {
 			// 计算持仓时长
 			holdingDuration := ""
 			if pos.UpdateTime > 0 {
-				durationMs := time.Now().UnixMilli() - pos.UpdateTime
+				durationMs := ctx.now().UnixMilli() - pos.UpdateTime
 				durationMin := durationMs / (1000 * 60) // 转换为分钟
 				if durationMin < 60 {
 					holdingDuration = fmt.Sprintf(" | 持仓时长%d分钟", durationMin)
@@ -421,6 +535,38 @@ func buildUserPrompt(ctx *Context) string {
 		sb.WriteString("当前持仓: 无\n\n")
 	}
 
+	// 未成交的限价开仓单（避免重复开仓）
+	if len(ctx.PendingOrders) > 0 {
+		sb.WriteString("## 未成交挂单（同币种同方向请勿重复开仓）\n")
+		for i, order := range ctx.PendingOrders {
+			waitMin := (ctx.now().UnixMilli() - order.PlacedTime) / (1000 * 60)
+			sb.WriteString(fmt.Sprintf("%d. %s %s %s | 挂单价%.4f | 数量%.4f | 止损%.4f 止盈%.4f | 已挂%d分钟\n",
+				i+1, order.Symbol, strings.ToUpper(order.Side), order.OrderType,
+				order.Price, order.Quantity, order.StopLoss, order.TakeProfit, waitMin))
+		}
+		sb.WriteString("\n")
+	}
+
+	// 流动性约束（有盘口数据时）
+	if guard := newLiquidityGuard(ctx); guard != nil {
+		sb.WriteString(fmt.Sprintf("流动性约束: 单笔开仓名义价值不得超过开仓方向 ±1%% 盘口深度的 %.0f%%（做多看 asks，做空看 bids），超限的开仓会被缩减到上限（缩减后过小则取消）\n\n",
+			guard.fraction*100))
+	}
+
+	// 跨交易所资金费率（8小时折算，费率高的交易所做空、费率低的交易所做多可收取资金费）
+	if len(ctx.FundingDivergence) > 0 {
+		symbols := make([]string, 0, len(ctx.FundingDivergence))
+		for symbol := range ctx.FundingDivergence {
+			symbols = append(symbols, symbol)
+		}
+		sort.Strings(symbols)
+		sb.WriteString("## 跨交易所资金费率 (8小时折算)\n\n")
+		for _, symbol := range symbols {
+			sb.WriteString(market.FormatFundingDivergence(ctx.FundingDivergence[symbol]) + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
 	// 候选币种（完整市场数据）
 	sb.WriteString(fmt.Sprintf("## 候选币种 (%d个)\n\n", len(ctx.MarketDataMap)))
 	displayedCount := 0
@@ -432,10 +578,21 @@ func buildUserPrompt(ctx *Context) string {
 		displayedCount++
 
 		sourceTags := ""
-		if len(coin.Sources) > 1 {
+		sources := make([]string, 0, len(coin.Sources))
+		for _, source := range coin.Sources {
+			if source != "alert" {
+				sources = append(sources, source)
+			}
+		}
+		if len(sources) > 1 {
 			sourceTags = " (AI500+OI_Top双重信号)"
-		} else if len(coin.Sources) == 1 && coin.Sources[0] == "oi_top" {
+		} else if len(sources) == 1 && sources[0] == "oi_top" {
 			sourceTags = " (OI_Top持仓增长)"
+		} else if len(sources) == 0 && len(coin.Sources) > 0 {
+			sourceTags = " (市场警报)"
+		}
+		if len(coin.Alerts) > 0 {
+			sourceTags += fmt.Sprintf(" ⚠️ 近期警报: %s", strings.Join(coin.Alerts, "; "))
 		}
 
 		// 使用FormatMarketData输出完整市场数据
@@ -466,30 +623,42 @@ func buildUserPrompt(ctx *Context) string {
 }
 
 // parseFullDecisionResponse 解析AI的完整决策响应
-func parseFullDecisionResponse(aiResponse string, accountEquity float64, btcEthLeverage, altcoinLeverage int) (*FullDecision, error) {
-	// 1. 提取思维链
-	cotTrace := extractCoTTrace(aiResponse)
+func parseFullDecisionResponse(aiResponse string, accountEquity float64, btcEthLeverage, altcoinLeverage int, guard *liquidityGuard) (*FullDecision, error) {
+	// 0. 优先按结构化输出（工具调用参数）解析
+	cotTrace, decisions, ok := parseStructuredDecision(aiResponse)
+	if ok {
+		log.Printf("✓ 使用结构化输出解析决策")
+	} else {
+		// 1. 提取思维链
+		cotTrace = extractCoTTrace(aiResponse)
 
-	// 2. 提取JSON决策列表
-	decisions, err := extractDecisions(aiResponse)
-	if err != nil {
-		return &FullDecision{
-			CoTTrace:  cotTrace,
-			Decisions: []Decision{},
-		}, fmt.Errorf("提取决策失败: %w", err)
+		// 2. 提取JSON决策列表
+		var err error
+		decisions, err = extractDecisions(aiResponse)
+		if err != nil {
+			return &FullDecision{
+				CoTTrace:  cotTrace,
+				Decisions: []Decision{},
+			}, fmt.Errorf("提取决策失败: %w", err)
+		}
 	}
 
-	// 3. 验证决策
+	// 3. 按盘口深度缩减或剔除超限的开仓决策（只影响该决策，不使整个周期失败）
+	decisions, adjustments := guard.apply(decisions)
+
+	// 4. 验证决策
 	if err := validateDecisions(decisions, accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
 		return &FullDecision{
-			CoTTrace:  cotTrace,
-			Decisions: decisions,
+			CoTTrace:    cotTrace,
+			Decisions:   decisions,
+			Adjustments: adjustments,
 		}, fmt.Errorf("决策验证失败: %w", err)
 	}
 
 	return &FullDecision{
-		CoTTrace:  cotTrace,
-		Decisions: decisions,
+		CoTTrace:    cotTrace,
+		Decisions:   decisions,
+		Adjustments: adjustments,
 	}, nil
 }
 
@@ -710,6 +879,21 @@ func findMatchingBracket(s string, start int) int {
 	return -1
 }
 
+// 最小开仓金额（防止数量格式化为 0 的错误）
+// Binance 最小名义价值 10 USDT + 安全边际
+const (
+	minPositionSizeGeneral = 12.0 // 10 + 20% 安全边际
+	minPositionSizeBTCETH  = 60.0 // BTC/ETH 因价格高和精度限制需要更大金额（更灵活）
+)
+
+// minPositionSize 币种的最小开仓金额
+func minPositionSize(symbol string) float64 {
+	if symbol == "BTCUSDT" || symbol == "ETHUSDT" {
+		return minPositionSizeBTCETH
+	}
+	return minPositionSizeGeneral
+}
+
 // validateDecision 验证单个决策的有效性
 func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
 	// 验证action
@@ -753,10 +937,6 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 		}
 
 		// ✅ 验证最小开仓金额（防止数量格式化为 0 的错误）
-		// Binance 最小名义价值 10 USDT + 安全边际
-		const minPositionSizeGeneral = 12.0 // 10 + 20% 安全边际
-		const minPositionSizeBTCETH = 60.0  // BTC/ETH 因价格高和精度限制需要更大金额（更灵活）
-
 		if d.Symbol == "BTCUSDT" || d.Symbol == "ETHUSDT" {
 			if d.PositionSizeUSD < minPositionSizeBTCETH {
 				return fmt.Errorf("%s 开仓金额过小(%.2f USDT)，必须≥%.2f USDT（因价格高且精度限制，避免数量四舍五入为0）", d.Symbol, d.PositionSizeUSD, minPositionSizeBTCETH)
@@ -780,6 +960,20 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 			return fmt.Errorf("止损和止盈必须大于0")
 		}
 
+		// 验证订单类型（限价单必须给出挂单价，且位于止损止盈之间）
+		switch d.OrderType {
+		case "", OrderTypeMarket:
+		case OrderTypeLimit, OrderTypePostOnly:
+			if d.EntryPrice <= 0 {
+				return fmt.Errorf("%s 限价单必须提供入场价格(entry_price)", d.OrderType)
+			}
+			if d.EntryPrice <= math.Min(d.StopLoss, d.TakeProfit) || d.EntryPrice >= math.Max(d.StopLoss, d.TakeProfit) {
+				return fmt.Errorf("限价单入场价 %.4f 必须位于止损价和止盈价之间", d.EntryPrice)
+			}
+		default:
+			return fmt.Errorf("无效的order_type: %s", d.OrderType)
+		}
+
 		// 验证止损止盈的合理性
 		if d.Action == "open_long" {
 			if d.StopLoss >= d.TakeProfit {
@@ -792,9 +986,11 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 		}
 
 		// 验证风险回报比（必须≥1:3）
-		// 计算入场价（假设当前市价）
+		// 计算入场价（限价单使用挂单价，市价单假设当前市价）
 		var entryPrice float64
-		if d.Action == "open_long" {
+		if d.IsLimitOrder() {
+			entryPrice = d.EntryPrice
+		} else if d.Action == "open_long" {
 			// 做多：入场价在止损和止盈之间
 			entryPrice = d.StopLoss + (d.TakeProfit-d.StopLoss)*0.2 // 假设在20%位置入场
 		} else {
@@ -0,0 +1,321 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/mcp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 多模型集成策略
+const (
+	EnsembleUnanimous = "unanimous" // 所有配置的模型都给出相同决策才执行
+	EnsembleMajority  = "majority"  // 超过半数配置的模型给出相同决策才执行
+	EnsembleWeighted  = "weighted"  // 按信心度加权（除以配置的模型数），权重达到阈值才执行
+	EnsembleJudge     = "judge"     // 由仲裁模型审查其他模型的输出后给出最终决策
+)
+
+// DefaultEnsembleMinWeight 信心度加权策略的默认执行阈值（提议模型信心度之和 / 模型数）
+const DefaultEnsembleMinWeight = 0.5
+
+// defaultDecisionConfidence 模型未给出信心度时使用的默认值
+const defaultDecisionConfidence = 50
+
+// judgeCoTLimit 提供给仲裁模型的单个模型思维链最大长度（字符）
+const judgeCoTLimit = 1500
+
+// ValidEnsemblePolicy 是否为支持的集成策略
+func ValidEnsemblePolicy(policy string) bool {
+	switch policy {
+	case EnsembleUnanimous, EnsembleMajority, EnsembleWeighted, EnsembleJudge:
+		return true
+	}
+	return false
+}
+
+// EnsembleMember 参与集成决策的模型
+type EnsembleMember struct {
+	Name   string // 模型名称（记录在决策日志中）
+	Client mcp.AIClient
+}
+
+// Ensemble 多模型集成配置：所有成员使用相同的提示词并行请求，再按策略合并决策
+type Ensemble struct {
+	Policy    string
+	Members   []EnsembleMember
+	Judge     *EnsembleMember // 仅 judge 策略使用
+	MinWeight float64         // 仅 weighted 策略使用，0表示使用 DefaultEnsembleMinWeight
+}
+
+// ModelResponse 单个模型的响应（失败时 Error 非空，Decisions 为解析出的原始决策）
+type ModelResponse struct {
+	Model       string     `json:"model"`
+	CoTTrace    string     `json:"cot_trace,omitempty"`
+	RawResponse string     `json:"raw_response,omitempty"` // 模型原始响应（调用失败时为空）
+	Decisions   []Decision `json:"decisions,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	DurationMs  int64      `json:"duration_ms"`
+	// Adjustments 按盘口深度缩减或剔除的开仓决策
+	Adjustments []string `json:"adjustments,omitempty"`
+	Judge       bool     `json:"judge,omitempty"` // 是否为仲裁模型的响应
+}
+
+// GetEnsembleDecision 使用多个模型获取完整交易决策（支持自定义prompt和模板选择）
+func GetEnsembleDecision(ctx *Context, ensemble *Ensemble, customPrompt string, overrideBase bool, templateName string) (*FullDecision, error) {
+	if err := fetchMarketDataForContext(ctx); err != nil {
+		return nil, fmt.Errorf("获取市场数据失败: %w", err)
+	}
+
+	systemPrompt := buildSystemPromptWithCustom(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, customPrompt, overrideBase, templateName)
+	userPrompt := buildUserPrompt(ctx)
+	return ensemble.decide(systemPrompt, userPrompt, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, newLiquidityGuard(ctx))
+}
+
+// decide 并行请求所有成员并按策略合并决策
+func (e *Ensemble) decide(systemPrompt, userPrompt string, accountEquity float64, btcEthLeverage, altcoinLeverage int, guard *liquidityGuard) (*FullDecision, error) {
+	responses := make([]ModelResponse, len(e.Members))
+	var wg sync.WaitGroup
+	for i, member := range e.Members {
+		wg.Add(1)
+		go func(i int, member EnsembleMember) {
+			defer wg.Done()
+			responses[i] = queryMember(member, systemPrompt, userPrompt, accountEquity, btcEthLeverage, altcoinLeverage, guard)
+		}(i, member)
+	}
+	wg.Wait()
+
+	full := &FullDecision{
+		SystemPrompt:   systemPrompt,
+		UserPrompt:     userPrompt,
+		Timestamp:      time.Now(),
+		EnsemblePolicy: e.Policy,
+		ModelResponses: responses,
+	}
+	var valid []ModelResponse
+	for _, r := range responses {
+		full.AIRequestDurationMs = max(full.AIRequestDurationMs, r.DurationMs)
+		if r.Error == "" {
+			valid = append(valid, r)
+		} else {
+			log.Printf("⚠️  集成模型 %s 决策无效: %s", r.Model, r.Error)
+		}
+	}
+	if len(valid) == 0 {
+		full.Decisions = []Decision{}
+		return full, fmt.Errorf("集成决策失败: %d 个模型均未返回有效决策", len(responses))
+	}
+	// 有效响应未超过配置模型的半数时不合并决策，本周期等待
+	if len(valid)*2 <= len(responses) {
+		log.Printf("⚠️  集成决策未达法定数: %d/%d 个模型有效，本周期等待", len(valid), len(responses))
+		full.CoTTrace = ensembleCoTTrace(e.Policy, len(valid), len(responses), valid)
+		full.Decisions = []Decision{{
+			Symbol:    "ALL",
+			Action:    "wait",
+			Reasoning: fmt.Sprintf("集成决策未达法定数（%d/%d 个模型有效，需要超过半数），本周期等待", len(valid), len(responses)),
+		}}
+		return full, nil
+	}
+
+	policy := e.Policy
+	if policy == EnsembleJudge {
+		if e.Judge == nil {
+			log.Printf("⚠️  未配置仲裁模型，改用多数投票")
+			policy = EnsembleMajority
+		} else {
+			judgeSystem, judgeUser := buildJudgePrompt(systemPrompt, userPrompt, responses)
+			verdict := queryMember(*e.Judge, judgeSystem, judgeUser, accountEquity, btcEthLeverage, altcoinLeverage, guard)
+			verdict.Judge = true
+			full.ModelResponses = append(full.ModelResponses, verdict)
+			full.AIRequestDurationMs += verdict.DurationMs
+			if verdict.Error == "" {
+				full.CoTTrace = verdict.CoTTrace
+				full.Decisions = nonNilDecisions(verdict.Decisions)
+				full.Adjustments = memberAdjustments([]ModelResponse{verdict})
+				return full, nil
+			}
+			log.Printf("⚠️  仲裁模型 %s 决策无效，改用多数投票: %s", verdict.Model, verdict.Error)
+			policy = EnsembleMajority
+		}
+	}
+
+	full.Decisions = combineDecisions(policy, valid, len(responses), e.minWeight())
+	full.Adjustments = memberAdjustments(valid)
+	full.CoTTrace = ensembleCoTTrace(policy, len(valid), len(responses), valid)
+	log.Printf("🗳️ 集成决策 [%s]: %d/%d 个模型有效，合并后 %d 条决策", policy, len(valid), len(responses), len(full.Decisions))
+	return full, nil
+}
+
+// memberAdjustments 汇总各模型决策的盘口深度调整（标注模型名称）
+func memberAdjustments(responses []ModelResponse) []string {
+	var adjustments []string
+	for _, r := range responses {
+		for _, adjustment := range r.Adjustments {
+			adjustments = append(adjustments, fmt.Sprintf("[%s] %s", r.Model, adjustment))
+		}
+	}
+	return adjustments
+}
+
+// minWeight 返回加权策略的执行阈值
+func (e *Ensemble) minWeight() float64 {
+	if e.MinWeight <= 0 {
+		return DefaultEnsembleMinWeight
+	}
+	return e.MinWeight
+}
+
+// queryMember 请求单个模型并记录响应（调用、解析或校验失败都记为错误）
+func queryMember(member EnsembleMember, systemPrompt, userPrompt string, accountEquity float64, btcEthLeverage, altcoinLeverage int, guard *liquidityGuard) ModelResponse {
+	start := time.Now()
+	decision, err := requestDecision(member.Client, systemPrompt, userPrompt, accountEquity, btcEthLeverage, altcoinLeverage, guard)
+	response := ModelResponse{Model: member.Name, DurationMs: time.Since(start).Milliseconds()}
+	if decision != nil {
+		response.CoTTrace = decision.CoTTrace
+		response.RawResponse = decision.RawResponse
+		response.Decisions = decision.Decisions
+		response.Adjustments = decision.Adjustments
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	return response
+}
+
+// proposal 多个模型给出的同一币种同一动作
+type proposal struct {
+	decision   Decision // 采用信心度最高的模型给出的参数
+	confidence int
+	models     []string
+	weight     float64 // 提议模型的信心度之和（按100归一化）
+}
+
+// combineDecisions 按投票策略合并各模型的决策（hold/wait 不参与合并）
+// total 为配置的模型数：失败的模型视为未支持任何决策，不会降低通过门槛
+func combineDecisions(policy string, responses []ModelResponse, total int, minWeight float64) []Decision {
+	var order []string
+	proposals := make(map[string]*proposal)
+	for _, r := range responses {
+		seen := make(map[string]bool)
+		for _, d := range r.Decisions {
+			if d.Action == "hold" || d.Action == "wait" {
+				continue
+			}
+			key := d.Symbol + "|" + d.Action
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			confidence := d.Confidence
+			if confidence <= 0 {
+				confidence = defaultDecisionConfidence
+			}
+			p, ok := proposals[key]
+			if !ok {
+				p = &proposal{}
+				proposals[key] = p
+				order = append(order, key)
+			}
+			if confidence > p.confidence {
+				p.decision, p.confidence = d, confidence
+			}
+			p.models = append(p.models, r.Model)
+			p.weight += float64(confidence) / 100
+		}
+	}
+
+	n := total
+	var passed []*proposal
+	for _, key := range order {
+		p := proposals[key]
+		var ok bool
+		switch policy {
+		case EnsembleUnanimous:
+			ok = len(p.models) == n
+		case EnsembleWeighted:
+			ok = p.weight/float64(n) >= minWeight
+		default:
+			ok = len(p.models)*2 > n
+		}
+		if ok {
+			passed = append(passed, p)
+		}
+	}
+
+	decisions := make([]Decision, 0, len(passed))
+	for _, p := range passed {
+		if conflictLoses(p, passed) {
+			log.Printf("⚠️  %s 开多与开空同时通过投票，以支持较多的一方为准（持平时均不执行）", p.decision.Symbol)
+			continue
+		}
+		d := p.decision
+		d.Reasoning = fmt.Sprintf("[%d/%d 模型: %s] %s", len(p.models), n, strings.Join(p.models, ", "), d.Reasoning)
+		decisions = append(decisions, d)
+	}
+	return decisions
+}
+
+// conflictLoses 同一币种开多与开空同时通过时，支持较少（或持平）的一方放弃
+func conflictLoses(p *proposal, passed []*proposal) bool {
+	opposite := map[string]string{"open_long": "open_short", "open_short": "open_long"}[p.decision.Action]
+	if opposite == "" {
+		return false
+	}
+	for _, other := range passed {
+		if other.decision.Symbol != p.decision.Symbol || other.decision.Action != opposite {
+			continue
+		}
+		if len(other.models) != len(p.models) {
+			return len(other.models) > len(p.models)
+		}
+		return other.weight >= p.weight
+	}
+	return false
+}
+
+// ensembleCoTTrace 合并各模型的思维链
+func ensembleCoTTrace(policy string, validCount, total int, responses []ModelResponse) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("集成决策（%s，%d/%d 个模型有效）\n", policy, validCount, total))
+	for _, r := range responses {
+		sb.WriteString(fmt.Sprintf("\n===== %s =====\n%s\n", r.Model, r.CoTTrace))
+	}
+	return sb.String()
+}
+
+// buildJudgePrompt 在原提示词基础上附加其他模型的分析与决策，交由仲裁模型审查
+func buildJudgePrompt(systemPrompt, userPrompt string, responses []ModelResponse) (string, string) {
+	judgeSystem := systemPrompt + "\n\n# 仲裁模式\n\n" +
+		"你是仲裁模型。用户消息末尾附有其他模型基于同一份市场数据给出的分析与决策。" +
+		"请独立审查它们的论据，指出错误与分歧，然后按上文要求的格式输出最终决策。" +
+		"只采纳依据充分的决策，存在明显分歧且无法判断时选择观望。\n"
+
+	var sb strings.Builder
+	sb.WriteString(userPrompt)
+	sb.WriteString("\n\n## 其他模型的决策\n\n")
+	for _, r := range responses {
+		sb.WriteString(fmt.Sprintf("### %s\n\n", r.Model))
+		if r.Error != "" {
+			sb.WriteString(fmt.Sprintf("（决策无效: %s）\n\n", r.Error))
+			continue
+		}
+		cot := []rune(strings.TrimSpace(r.CoTTrace))
+		if len(cot) > judgeCoTLimit {
+			cot = append(cot[:judgeCoTLimit], []rune("…")...)
+		}
+		decisionJSON, _ := json.Marshal(r.Decisions)
+		sb.WriteString(fmt.Sprintf("分析: %s\n\n决策: %s\n\n", string(cot), decisionJSON))
+	}
+	return judgeSystem, sb.String()
+}
+
+// nonNilDecisions 空决策列表序列化为 [] 而不是 null
+func nonNilDecisions(decisions []Decision) []Decision {
+	if decisions == nil {
+		return []Decision{}
+	}
+	return decisions
+}
@@ -0,0 +1,163 @@
+package decision
+
+import (
+	"errors"
+	"fmt"
+	"nofx/mcp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeModelClient 返回固定文本响应的AI客户端
+type fakeModelClient struct {
+	response string
+	err      error
+	lastUser string
+}
+
+func (c *fakeModelClient) SetAPIKey(apiKey string, customURL string, customModel string) {}
+func (c *fakeModelClient) SetTimeout(timeout time.Duration)                              {}
+func (c *fakeModelClient) CallWithRequest(req *mcp.Request) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (c *fakeModelClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	c.lastUser = userPrompt
+	return c.response, c.err
+}
+
+// modelResponse 构造包含决策JSON的文本响应
+func modelResponse(decisions string) string {
+	return "<reasoning>分析</reasoning><decision>" + decisions + "</decision>"
+}
+
+const (
+	longBTC  = `{"symbol":"BTCUSDT","action":"open_long","leverage":5,"position_size_usd":1000,"stop_loss":90000,"take_profit":110000,"confidence":%d,"risk_usd":50,"reasoning":"看多"}`
+	shortBTC = `{"symbol":"BTCUSDT","action":"open_short","leverage":5,"position_size_usd":1000,"stop_loss":110000,"take_profit":90000,"confidence":%d,"risk_usd":50,"reasoning":"看空"}`
+	closeETH = `{"symbol":"ETHUSDT","action":"close_long","reasoning":"止盈"}`
+)
+
+func members(responses ...string) []EnsembleMember {
+	result := make([]EnsembleMember, len(responses))
+	for i, r := range responses {
+		result[i] = EnsembleMember{Name: []string{"deepseek", "qwen", "custom"}[i], Client: &fakeModelClient{response: r}}
+	}
+	return result
+}
+
+// TestEnsembleVoting 一致、多数与信心度加权策略
+func TestEnsembleVoting(t *testing.T) {
+	long80, long60, short90 := fmt.Sprintf(longBTC, 80), fmt.Sprintf(longBTC, 60), fmt.Sprintf(shortBTC, 90)
+	responses := []string{
+		modelResponse("[" + long80 + "," + closeETH + "]"),
+		modelResponse("[" + long60 + "]"),
+		modelResponse("[" + short90 + "," + closeETH + "]"),
+	}
+
+	tests := []struct {
+		policy    string
+		minWeight float64
+		want      []string
+	}{
+		{EnsembleUnanimous, 0, nil},
+		{EnsembleMajority, 0, []string{"BTCUSDT open_long", "ETHUSDT close_long"}},
+		// 开多权重 (0.8+0.6)/3≈0.47，平仓 (0.5+0.5)/3≈0.33，开空 0.3
+		{EnsembleWeighted, 0.45, []string{"BTCUSDT open_long"}},
+		{EnsembleWeighted, 0.3, []string{"BTCUSDT open_long", "ETHUSDT close_long"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			e := &Ensemble{Policy: tt.policy, Members: members(responses...), MinWeight: tt.minWeight}
+			full, err := e.decide("sys", "user", 10000, 5, 5, nil)
+			if err != nil {
+				t.Fatalf("decide returned error: %v", err)
+			}
+			var got []string
+			for _, d := range full.Decisions {
+				got = append(got, d.Symbol+" "+d.Action)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("decisions = %v, want %v", got, tt.want)
+			}
+			if len(full.ModelResponses) != 3 || full.EnsemblePolicy != tt.policy {
+				t.Errorf("all model responses should be kept, got %d", len(full.ModelResponses))
+			}
+		})
+	}
+
+	// 采用信心度最高的模型给出的参数，并在理由中注明支持的模型
+	e := &Ensemble{Policy: EnsembleMajority, Members: members(responses...)}
+	full, _ := e.decide("sys", "user", 10000, 5, 5, nil)
+	if d := full.Decisions[0]; d.Confidence != 80 || !strings.HasPrefix(d.Reasoning, "[2/3 模型: deepseek, qwen]") {
+		t.Errorf("merged decision = %+v", d)
+	}
+}
+
+// TestEnsembleFailures 无效响应不参与投票但计入模型总数，未达法定数时等待，全部失败时返回错误
+func TestEnsembleFailures(t *testing.T) {
+	long := modelResponse("[" + fmt.Sprintf(longBTC, 70) + "]")
+	e := &Ensemble{Policy: EnsembleUnanimous, Members: []EnsembleMember{
+		{Name: "deepseek", Client: &fakeModelClient{response: long}},
+		{Name: "qwen", Client: &fakeModelClient{err: errors.New("timeout")}},
+		{Name: "custom", Client: &fakeModelClient{response: modelResponse(`[{"symbol":"BTCUSDT","action":"open_long","leverage":5,"position_size_usd":1000,"reasoning":"缺少止损"}]`)}},
+	}}
+	full, err := e.decide("sys", "user", 10000, 5, 5, nil)
+	if err != nil || len(full.Decisions) != 1 || full.Decisions[0].Action != "wait" {
+		t.Fatalf("a single valid model out of three should not trade: %v, %+v", err, full.Decisions)
+	}
+	if full.ModelResponses[1].Error == "" || full.ModelResponses[2].Error == "" {
+		t.Error("failed responses should record their errors")
+	}
+	// 校验失败的模型同样保留原始响应，调用失败的模型没有响应
+	if full.ModelResponses[0].RawResponse != long || full.ModelResponses[2].RawResponse == "" || full.ModelResponses[1].RawResponse != "" {
+		t.Errorf("raw responses = %q, %q, %q", full.ModelResponses[0].RawResponse, full.ModelResponses[1].RawResponse, full.ModelResponses[2].RawResponse)
+	}
+
+	// 两个有效模型达到法定数：多数策略需要超过配置模型数的半数支持，一致策略需要全部模型支持
+	e.Members[2].Client = &fakeModelClient{response: long}
+	full, err = e.decide("sys", "user", 10000, 5, 5, nil)
+	if err != nil || len(full.Decisions) != 0 {
+		t.Errorf("unanimous should require every configured model: %v, %+v", err, full.Decisions)
+	}
+	e.Policy = EnsembleMajority
+	full, err = e.decide("sys", "user", 10000, 5, 5, nil)
+	if err != nil || len(full.Decisions) != 1 || !strings.HasPrefix(full.Decisions[0].Reasoning, "[2/3 模型") {
+		t.Errorf("majority should count support against configured models: %v, %+v", err, full.Decisions)
+	}
+	e.Members[2].Client = &fakeModelClient{err: errors.New("timeout")}
+
+	e.Members = e.Members[1:]
+	if _, err := e.decide("sys", "user", 10000, 5, 5, nil); err == nil {
+		t.Error("decide should fail when no model returns a valid decision")
+	}
+}
+
+// TestEnsembleJudge 仲裁模型看到其他模型的决策，仲裁失败时回退到多数投票
+func TestEnsembleJudge(t *testing.T) {
+	long := modelResponse("[" + fmt.Sprintf(longBTC, 70) + "]")
+	judge := &fakeModelClient{response: modelResponse(`[{"symbol":"BTCUSDT","action":"wait","reasoning":"分歧过大"}]`)}
+	e := &Ensemble{
+		Policy:  EnsembleJudge,
+		Members: members(long, modelResponse("["+fmt.Sprintf(shortBTC, 70)+"]")),
+		Judge:   &EnsembleMember{Name: "judge", Client: judge},
+	}
+
+	full, err := e.decide("sys", "user", 10000, 5, 5, nil)
+	if err != nil || len(full.Decisions) != 1 || full.Decisions[0].Action != "wait" {
+		t.Fatalf("judge verdict should be final: %v, %+v", err, full.Decisions)
+	}
+	if !strings.Contains(judge.lastUser, "## 其他模型的决策") || !strings.Contains(judge.lastUser, `"action":"open_short"`) {
+		t.Errorf("judge prompt should include member decisions:\n%s", judge.lastUser)
+	}
+	if last := full.ModelResponses[len(full.ModelResponses)-1]; !last.Judge || last.Model != "judge" {
+		t.Errorf("judge response should be recorded last, got %+v", last)
+	}
+
+	judge.err = errors.New("judge down")
+	e.Members = members(long, long)
+	full, err = e.decide("sys", "user", 10000, 5, 5, nil)
+	if err != nil || len(full.Decisions) != 1 || full.Decisions[0].Action != "open_long" {
+		t.Errorf("judge failure should fall back to majority: %v, %+v", err, full.Decisions)
+	}
+}
@@ -0,0 +1,93 @@
+package decision
+
+import (
+	"fmt"
+	"log"
+
+	"nofx/market"
+)
+
+// DefaultMaxDepthFraction 单笔开仓名义价值占开仓方向 ±1% 盘口深度的默认上限
+const DefaultMaxDepthFraction = 0.25
+
+// liquidityGuard 按盘口深度限制开仓规模
+type liquidityGuard struct {
+	fraction  float64
+	liquidity map[string]*market.LiquidityData
+}
+
+// newLiquidityGuard 从上下文的市场数据构建深度检查（没有任何盘口数据时返回 nil）
+func newLiquidityGuard(ctx *Context) *liquidityGuard {
+	liquidity := make(map[string]*market.LiquidityData)
+	for symbol, data := range ctx.MarketDataMap {
+		if data != nil && data.Liquidity != nil {
+			liquidity[symbol] = data.Liquidity
+		}
+	}
+	if len(liquidity) == 0 {
+		return nil
+	}
+
+	fraction := ctx.MaxDepthFraction
+	if fraction <= 0 {
+		fraction = DefaultMaxDepthFraction
+	}
+	return &liquidityGuard{fraction: fraction, liquidity: liquidity}
+}
+
+// apply 按盘口深度调整开仓决策：名义价值超过上限时缩减到上限，缩减后低于最小开仓金额时剔除
+// 只影响超限的开仓决策，返回保留的决策与调整说明
+func (g *liquidityGuard) apply(decisions []Decision) ([]Decision, []string) {
+	if g == nil {
+		return decisions, nil
+	}
+
+	kept := make([]Decision, 0, len(decisions))
+	var adjustments []string
+	for _, d := range decisions {
+		limit, reason := g.check(&d)
+		if reason == "" {
+			kept = append(kept, d)
+			continue
+		}
+		if limit < minPositionSize(d.Symbol) {
+			adjustments = append(adjustments, fmt.Sprintf("⛔ %s %s 已剔除: %s，缩减后低于最小开仓金额 %.0f USDT",
+				d.Symbol, d.Action, reason, minPositionSize(d.Symbol)))
+			continue
+		}
+		adjustments = append(adjustments, fmt.Sprintf("📉 %s %s 已缩减: %s，仓位调整为 %.0f USDT",
+			d.Symbol, d.Action, reason, limit))
+		d.PositionSizeUSD = limit
+		kept = append(kept, d)
+	}
+	for _, adjustment := range adjustments {
+		log.Printf("⚠️ 盘口深度检查: %s", adjustment)
+	}
+	return kept, adjustments
+}
+
+// check 开仓名义价值超过可用深度的比例上限时返回上限金额与原因（未超限时原因为空）
+// 币种没有盘口数据，或快照未覆盖完整 1% 范围且深度不足时（深度只是下限）不拦截
+func (g *liquidityGuard) check(d *Decision) (float64, string) {
+	if d.Action != "open_long" && d.Action != "open_short" {
+		return 0, ""
+	}
+	liquidity, ok := g.liquidity[d.Symbol]
+	if !ok {
+		return 0, ""
+	}
+
+	long := d.Action == "open_long"
+	depth, complete := liquidity.TakerDepth(long)
+	limit := depth * g.fraction
+	if d.PositionSizeUSD <= limit || !complete {
+		return 0, ""
+	}
+
+	side := "卖盘"
+	if !long {
+		side = "买盘"
+	}
+	return limit, fmt.Sprintf("开仓金额 %.0f USDT 超过 ±1%% %s深度 %.0f USDT 的 %.0f%%（上限 %.0f USDT）",
+		d.PositionSizeUSD, side, depth, g.fraction*100, limit)
+}
@@ -1,6 +1,7 @@
 package decision
 
 import (
+	"nofx/market"
 	"strings"
 	"testing"
 )
@@ -48,3 +49,44 @@ func TestBuildSystemPrompt_ActionListCompleteness(t *testing.T) {
 		}
 	}
 }
+
+// TestBuildUserPrompt_Trigger 事件触发的周期在用户prompt中说明触发原因
+func TestBuildUserPrompt_Trigger(t *testing.T) {
+	ctx := &Context{CurrentTime: "2025-01-01 00:00:00", CallCount: 3, Account: AccountInfo{TotalEquity: 1000, AvailableBalance: 1000}}
+	if prompt := buildUserPrompt(ctx); strings.Contains(prompt, "提前触发") {
+		t.Errorf("scheduled cycle should not mention a trigger:\n%s", prompt)
+	}
+
+	ctx.Trigger = &TriggerInfo{Type: "price_move", Symbol: "BTCUSDT", Reason: "BTCUSDT 15分钟内价格变动 +3.10%"}
+	prompt := buildUserPrompt(ctx)
+	for _, want := range []string{"[price_move]", "BTCUSDT 15分钟内价格变动 +3.10%", "请优先评估 BTCUSDT"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+}
+
+// TestBuildUserPrompt_FundingDivergence 开启跨交易所资金费率时在用户prompt中列出差异
+func TestBuildUserPrompt_FundingDivergence(t *testing.T) {
+	ctx := &Context{CurrentTime: "2025-01-01 00:00:00", Account: AccountInfo{TotalEquity: 1000, AvailableBalance: 1000}}
+	if prompt := buildUserPrompt(ctx); strings.Contains(prompt, "跨交易所资金费率") {
+		t.Errorf("funding section should be omitted without data:\n%s", prompt)
+	}
+
+	ctx.FundingDivergence = map[string]*market.FundingDivergence{
+		"BTCUSDT": {
+			Symbol: "BTCUSDT",
+			Venues: []market.FundingSnapshot{
+				{Venue: "binance", Rate8h: 0.0001, MarkPrice: 50000},
+				{Venue: "hyperliquid", Rate8h: 0.0004, MarkPrice: 50050},
+			},
+			SpreadRate8h: 0.0003, HighVenue: "hyperliquid", LowVenue: "binance", PriceSpreadPct: 0.1,
+		},
+	}
+	prompt := buildUserPrompt(ctx)
+	for _, want := range []string{"## 跨交易所资金费率", "BTCUSDT: binance 0.0100%/8h", "funding spread 0.0300%/8h (high hyperliquid, low binance)"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package decision
+
+import (
+	"nofx/mcp"
+	"testing"
+)
+
+// TestReplayDecision 使用录制的响应离线重跑决策解析
+func TestReplayDecision(t *testing.T) {
+	replay := mcp.NewReplayClientFromRecordings([]*mcp.Recording{
+		{
+			SystemPrompt: "sys",
+			UserPrompt:   "ok",
+			Response:     `<reasoning>观望</reasoning><decision>[{"symbol":"BTCUSDT","action":"wait","reasoning":"无机会"}]</decision>`,
+		},
+		{
+			SystemPrompt: "sys",
+			UserPrompt:   "bad",
+			Response:     `<reasoning>开多</reasoning><decision>[{"symbol":"BTCUSDT","action":"open_long","leverage":5,"position_size_usd":1000,"stop_loss":100,"take_profit":90,"reasoning":"止损高于止盈"}]</decision>`,
+		},
+	})
+
+	full, err := ReplayDecision(replay, "sys", "ok", 10000, 5, 5)
+	if err != nil {
+		t.Fatalf("ReplayDecision returned error: %v", err)
+	}
+	if len(full.Decisions) != 1 || full.Decisions[0].Action != "wait" {
+		t.Errorf("unexpected decisions: %+v", full.Decisions)
+	}
+	if full.UserPrompt != "ok" || full.CoTTrace != "观望" {
+		t.Errorf("prompt/CoT not preserved: %+v", full)
+	}
+
+	// 复现校验失败：仍返回思维链和原始决策便于排查
+	full, err = ReplayDecision(replay, "sys", "bad", 10000, 5, 5)
+	if err == nil {
+		t.Fatal("expected validation error for inverted stop loss / take profit")
+	}
+	if full == nil || len(full.Decisions) != 1 || full.SystemPrompt != "sys" {
+		t.Errorf("failed decision should still carry prompts and decisions: %+v", full)
+	}
+
+	// 未录制的提示词不会发起真实调用
+	if _, err := ReplayDecision(replay, "sys", "missing", 10000, 5, 5); err == nil {
+		t.Error("expected error for unrecorded prompt")
+	}
+}
@@ -0,0 +1,104 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"nofx/mcp"
+	"strings"
+)
+
+// decisionToolName 结构化决策函数名
+const decisionToolName = "submit_trading_decisions"
+
+// structuredDecision 结构化输出（工具调用参数）
+type structuredDecision struct {
+	Reasoning string     `json:"reasoning"`
+	Decisions []Decision `json:"decisions"`
+}
+
+// decisionToolSchema 返回 Decision 的 JSON Schema（作为 Function Calling 的参数定义）
+func decisionToolSchema() map[string]any {
+	number := map[string]any{"type": "number"}
+	integer := map[string]any{"type": "integer"}
+	orderType := map[string]any{
+		"type":        "string",
+		"enum":        []string{OrderTypeMarket, OrderTypeLimit, OrderTypePostOnly},
+		"description": "开仓订单类型，默认 market",
+	}
+
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"reasoning": map[string]any{
+				"type":        "string",
+				"description": "思维链分析：市场判断、持仓评估、决策理由",
+			},
+			"decisions": map[string]any{
+				"type":        "array",
+				"description": "交易决策列表，无操作时给出 wait 或 hold",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"symbol": map[string]any{"type": "string", "description": "交易对，如 BTCUSDT"},
+						"action": map[string]any{
+							"type": "string",
+							"enum": []string{"open_long", "open_short", "close_long", "close_short",
+								"update_stop_loss", "update_take_profit", "partial_close", "hold", "wait"},
+						},
+						"leverage":          integer,
+						"position_size_usd": number,
+						"stop_loss":         number,
+						"take_profit":       number,
+						"order_type":        orderType,
+						"entry_price":       map[string]any{"type": "number", "description": "limit/post_only 的挂单价格"},
+						"new_stop_loss":     number,
+						"new_take_profit":   number,
+						"close_percentage":  map[string]any{"type": "number", "description": "partial_close 的平仓百分比 (0-100)"},
+						"confidence":        map[string]any{"type": "integer", "description": "信心度 (0-100)"},
+						"risk_usd":          number,
+						"reasoning":         map[string]any{"type": "string"},
+					},
+					"required": []string{"symbol", "action", "reasoning"},
+				},
+			},
+		},
+		"required": []string{"reasoning", "decisions"},
+	}
+}
+
+// buildDecisionRequest 构建带决策函数定义的请求（强制模型调用该函数）
+func buildDecisionRequest(systemPrompt, userPrompt string) (*mcp.Request, error) {
+	toolChoice := fmt.Sprintf(`{"type": "function", "function": {"name": "%s"}}`, decisionToolName)
+	return mcp.NewRequestBuilder().
+		WithSystemPrompt(systemPrompt).
+		WithUserPrompt(userPrompt).
+		AddFunction(decisionToolName, "提交本周期的交易决策（替代 <decision> 标签输出）", decisionToolSchema()).
+		WithToolChoice(toolChoice).
+		Build()
+}
+
+// parseStructuredDecision 尝试按工具调用参数解析响应
+// 仅当响应整体是包含 decisions 字段的JSON对象时返回 ok=true，否则交给文本解析器处理
+func parseStructuredDecision(response string) (cotTrace string, decisions []Decision, ok bool) {
+	s := strings.TrimSpace(response)
+	if !strings.HasPrefix(s, "{") {
+		return "", nil, false
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return "", nil, false
+	}
+	if _, exists := raw["decisions"]; !exists {
+		return "", nil, false
+	}
+
+	var parsed structuredDecision
+	if err := json.Unmarshal([]byte(s), &parsed); err != nil {
+		return "", nil, false
+	}
+	if parsed.Decisions == nil {
+		parsed.Decisions = []Decision{}
+	}
+	return parsed.Reasoning, parsed.Decisions, true
+}
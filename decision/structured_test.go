@@ -0,0 +1,127 @@
+package decision
+
+import (
+	"errors"
+	"fmt"
+	"nofx/mcp"
+	"testing"
+	"time"
+)
+
+// fakeToolClient 模拟支持/不支持 Function Calling 的AI客户端
+type fakeToolClient struct {
+	supportsTools bool
+	toolResponse  string
+	toolErr       error
+	textResponse  string
+
+	toolCalls int
+	textCalls int
+	lastReq   *mcp.Request
+}
+
+func (c *fakeToolClient) SetAPIKey(apiKey string, customURL string, customModel string) {}
+func (c *fakeToolClient) SetTimeout(timeout time.Duration)                              {}
+func (c *fakeToolClient) SupportsFunctionCalling() bool                                 { return c.supportsTools }
+
+func (c *fakeToolClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	c.textCalls++
+	return c.textResponse, nil
+}
+
+func (c *fakeToolClient) CallWithRequest(req *mcp.Request) (string, error) {
+	c.toolCalls++
+	c.lastReq = req
+	return c.toolResponse, c.toolErr
+}
+
+const textWaitResponse = `<reasoning>文本观望</reasoning><decision>[{"symbol":"BTCUSDT","action":"wait","reasoning":"文本"}]</decision>`
+
+// TestParseStructuredDecision 测试工具调用参数解析
+func TestParseStructuredDecision(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantOK   bool
+		wantLen  int
+	}{
+		{"工具调用参数", `{"reasoning":"分析","decisions":[{"symbol":"BTCUSDT","action":"wait","reasoning":"无机会"}]}`, true, 1},
+		{"空决策列表", `{"reasoning":"分析","decisions":[]}`, true, 0},
+		{"文本响应", textWaitResponse, false, 0},
+		{"无decisions字段的JSON", `{"foo":"bar"}`, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, decisions, ok := parseStructuredDecision(tt.response)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if len(decisions) != tt.wantLen {
+				t.Errorf("decisions len = %d, want %d", len(decisions), tt.wantLen)
+			}
+		})
+	}
+}
+
+// TestRequestDecision_StructuredPath 支持 Function Calling 时使用工具调用
+func TestRequestDecision_StructuredPath(t *testing.T) {
+	client := &fakeToolClient{
+		supportsTools: true,
+		toolResponse:  `{"reasoning":"结构化分析","decisions":[{"symbol":"ETHUSDT","action":"hold","reasoning":"持有"}]}`,
+	}
+
+	full, err := requestDecision(client, "sys", "user", 10000, 5, 5, nil)
+	if err != nil {
+		t.Fatalf("requestDecision failed: %v", err)
+	}
+	if client.toolCalls != 1 || client.textCalls != 0 {
+		t.Errorf("expected only tool call, got tool=%d text=%d", client.toolCalls, client.textCalls)
+	}
+	if full.CoTTrace != "结构化分析" || len(full.Decisions) != 1 || full.Decisions[0].Action != "hold" {
+		t.Errorf("unexpected decision: %+v", full)
+	}
+	if len(client.lastReq.Tools) != 1 || client.lastReq.Tools[0].Function.Name != decisionToolName {
+		t.Errorf("request should declare decision tool: %+v", client.lastReq.Tools)
+	}
+}
+
+// TestRequestDecision_Fallback 不支持或调用失败时回退到文本解析
+func TestRequestDecision_Fallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		client    *fakeToolClient
+		wantTools int
+	}{
+		{"不支持Function Calling", &fakeToolClient{textResponse: textWaitResponse}, 0},
+		{"提供商拒绝tools", &fakeToolClient{supportsTools: true, toolErr: fmt.Errorf("API返回错误 (status 400): %w", mcp.ErrToolsRejected), textResponse: textWaitResponse}, 1},
+		{"未返回工具调用", &fakeToolClient{supportsTools: true, toolResponse: "我认为应该观望", textResponse: textWaitResponse}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			full, err := requestDecision(tt.client, "sys", "user", 10000, 5, 5, nil)
+			if err != nil {
+				t.Fatalf("requestDecision failed: %v", err)
+			}
+			if tt.client.toolCalls != tt.wantTools || tt.client.textCalls != 1 {
+				t.Errorf("tool=%d text=%d, want tool=%d text=1", tt.client.toolCalls, tt.client.textCalls, tt.wantTools)
+			}
+			if full.CoTTrace != "文本观望" || len(full.Decisions) != 1 {
+				t.Errorf("unexpected decision: %+v", full)
+			}
+		})
+	}
+}
+
+// TestRequestDecision_NoFallbackOnCallError 超时、网络等错误直接返回，不再用文本输出重复调用
+func TestRequestDecision_NoFallbackOnCallError(t *testing.T) {
+	client := &fakeToolClient{supportsTools: true, toolErr: errors.New("所有AI提供商均调用失败: timeout exceeded"), textResponse: textWaitResponse}
+
+	if _, err := requestDecision(client, "sys", "user", 10000, 5, 5, nil); err == nil {
+		t.Fatal("expected call error to be returned")
+	}
+	if client.toolCalls != 1 || client.textCalls != 0 {
+		t.Errorf("tool=%d text=%d, want tool=1 text=0", client.toolCalls, client.textCalls)
+	}
+}
@@ -1,7 +1,10 @@
 package decision
 
 import (
+	"math"
 	"testing"
+
+	"nofx/market"
 )
 
 // TestLeverageFallback 测试杠杆超限时的自动修正功能
@@ -279,6 +282,70 @@ func TestPartialCloseValidation(t *testing.T) {
 	}
 }
 
+// TestOrderTypeValidation 测试限价/post-only 开仓单的入场价验证
+func TestOrderTypeValidation(t *testing.T) {
+	base := func(orderType string, entryPrice float64) Decision {
+		return Decision{
+			Symbol:          "BTCUSDT",
+			Action:          "open_long",
+			Leverage:        5,
+			PositionSizeUSD: 1000,
+			StopLoss:        90000,
+			TakeProfit:      110000,
+			OrderType:       orderType,
+			EntryPrice:      entryPrice,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		decision  Decision
+		wantError bool
+		errorMsg  string
+	}{
+		{name: "未指定订单类型按市价单处理", decision: base("", 0)},
+		{name: "post-only 限价单按挂单价计算风险回报比", decision: base(OrderTypePostOnly, 95000)},
+		{
+			name:      "限价单缺少入场价",
+			decision:  base(OrderTypeLimit, 0),
+			wantError: true,
+			errorMsg:  "必须提供入场价格",
+		},
+		{
+			name:      "限价单入场价不在止损止盈之间",
+			decision:  base(OrderTypeLimit, 89000),
+			wantError: true,
+			errorMsg:  "必须位于止损价和止盈价之间",
+		},
+		{
+			name:      "挂单价过高导致风险回报比不足",
+			decision:  base(OrderTypeLimit, 100000),
+			wantError: true,
+			errorMsg:  "风险回报比过低",
+		},
+		{
+			name:      "无效的订单类型",
+			decision:  base("stop", 95000),
+			wantError: true,
+			errorMsg:  "无效的order_type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDecision(&tt.decision, 1000.0, 10, 5)
+
+			if (err != nil) != tt.wantError {
+				t.Errorf("validateDecision() error = %v, wantError %v", err, tt.wantError)
+				return
+			}
+			if tt.wantError && tt.errorMsg != "" && !contains(err.Error(), tt.errorMsg) {
+				t.Errorf("错误信息不匹配: got %q, want to contain %q", err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
 // contains 检查字符串是否包含子串（辅助函数）
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
@@ -293,3 +360,79 @@ func stringContains(s, substr string) bool {
 	}
 	return false
 }
+
+// TestLiquidityValidation 测试按盘口深度限制开仓规模
+func TestLiquidityValidation(t *testing.T) {
+	ctx := &Context{
+		MaxDepthFraction: 0.2,
+		MarketDataMap: map[string]*market.Data{
+			"SOLUSDT":  {Symbol: "SOLUSDT", Liquidity: &market.LiquidityData{AskDepth1: 2000, AskSpanPct: 1.5, BidDepth1: 800, BidSpanPct: 1.2}},
+			"DOGEUSDT": {Symbol: "DOGEUSDT", Liquidity: &market.LiquidityData{AskDepth1: 100, AskSpanPct: 0.3}},
+			"XRPUSDT":  {Symbol: "XRPUSDT", Liquidity: &market.LiquidityData{AskDepth1: 50, AskSpanPct: 1.5}},
+			"ADAUSDT":  {Symbol: "ADAUSDT"},
+		},
+	}
+	guard := newLiquidityGuard(ctx)
+	if guard == nil {
+		t.Fatal("有盘口数据时应启用深度检查")
+	}
+
+	open := func(symbol, action string, size, stopLoss, takeProfit float64) Decision {
+		return Decision{Symbol: symbol, Action: action, Leverage: 5, PositionSizeUSD: size, StopLoss: stopLoss, TakeProfit: takeProfit}
+	}
+	tests := []struct {
+		name     string
+		decision Decision
+		wantSize float64 // 0 表示决策被剔除
+		adjusted bool
+	}{
+		{name: "做多未超过卖盘深度比例", decision: open("SOLUSDT", "open_long", 400, 90, 140), wantSize: 400},
+		{name: "做多超过卖盘深度比例时缩减到上限", decision: open("SOLUSDT", "open_long", 500, 90, 140), wantSize: 400, adjusted: true},
+		{name: "做空按买盘深度检查", decision: open("SOLUSDT", "open_short", 200, 110, 60), wantSize: 160, adjusted: true},
+		{name: "缩减后低于最小开仓金额时剔除", decision: open("XRPUSDT", "open_long", 100, 0.5, 0.8), adjusted: true},
+		{name: "盘口快照不完整时不拦截", decision: open("DOGEUSDT", "open_long", 500, 90, 140), wantSize: 500},
+		{name: "无盘口数据时不拦截", decision: open("ADAUSDT", "open_long", 500, 90, 140), wantSize: 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hold := Decision{Symbol: "BTCUSDT", Action: "hold"}
+			kept, adjustments := guard.apply([]Decision{hold, tt.decision})
+			if (len(adjustments) > 0) != tt.adjusted {
+				t.Errorf("apply() adjustments = %v, adjusted %v", adjustments, tt.adjusted)
+			}
+			if tt.adjusted && !contains(adjustments[0], "深度") {
+				t.Errorf("调整说明应说明盘口深度: %v", adjustments)
+			}
+			if kept[0].Action != "hold" {
+				t.Errorf("其他决策不应受影响: %+v", kept)
+			}
+			if tt.wantSize == 0 {
+				if len(kept) != 1 {
+					t.Errorf("超限开仓应被剔除: %+v", kept)
+				}
+				return
+			}
+			if len(kept) != 2 || math.Abs(kept[1].PositionSizeUSD-tt.wantSize) > 1e-9 {
+				t.Errorf("仓位应为 %.0f: %+v", tt.wantSize, kept)
+			}
+		})
+	}
+
+	// 超限开仓不使整个周期失败，调整说明随决策返回
+	response := `[{"symbol":"SOLUSDT","action":"open_long","leverage":5,"position_size_usd":500,"stop_loss":90,"take_profit":140},` +
+		`{"symbol":"BTCUSDT","action":"close_long"}]`
+	full, err := parseFullDecisionResponse(response, 1000.0, 10, 5, guard)
+	if err != nil {
+		t.Fatalf("超限开仓不应导致决策失败: %v", err)
+	}
+	if len(full.Decisions) != 2 || full.Decisions[0].PositionSizeUSD != 400 || len(full.Adjustments) != 1 {
+		t.Errorf("应只缩减超限的开仓: %+v, %v", full.Decisions, full.Adjustments)
+	}
+
+	if newLiquidityGuard(&Context{MarketDataMap: map[string]*market.Data{"ADAUSDT": {}}}) != nil {
+		t.Error("没有盘口数据时不应启用深度检查")
+	}
+	if g := newLiquidityGuard(&Context{MarketDataMap: ctx.MarketDataMap}); g.fraction != DefaultMaxDepthFraction {
+		t.Errorf("未配置时应使用默认比例, got %v", g.fraction)
+	}
+}
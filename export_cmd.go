@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"nofx/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runExportCommand 导出决策日志为CSV/Parquet表供离线分析：nofx export [flags]
+// 每个表写为 <out>/<table>.<format>，多个交易员合并在同一表中（trader_id 列区分）
+func runExportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	traderIDs := fs.String("trader", "", "导出的交易员ID，多个用逗号分隔（默认导出全部）")
+	startStr := fs.String("start", "", "起始时间（含），RFC3339 或 2006-01-02")
+	endStr := fs.String("end", "", "结束时间（不含），RFC3339 或 2006-01-02")
+	format := fs.String("format", logger.ExportFormatCSV, "导出格式: csv / parquet")
+	tablesStr := fs.String("tables", strings.Join(logger.ExportTableNames, ","), "导出的表，多个用逗号分隔")
+	outDir := fs.String("out", "export", "输出目录")
+	backend := fs.String("backend", logger.DecisionLogBackendFile, "决策日志存储后端: file / sqlite")
+	logDir := fs.String("dir", "decision_logs", "JSON决策日志根目录（file 后端）")
+	dbPath := fs.String("db", logger.DefaultDecisionLogDBPath, "SQLite决策日志库路径（sqlite 后端）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !logger.ValidExportFormat(*format) {
+		return fmt.Errorf("不支持的导出格式: %s", *format)
+	}
+	tables := splitList(*tablesStr)
+	for _, name := range tables {
+		if !logger.ValidExportTable(name) {
+			return fmt.Errorf("不支持的导出表: %s（可选: %s）", name, strings.Join(logger.ExportTableNames, ", "))
+		}
+	}
+	start, err := parseExportTime(*startStr)
+	if err != nil {
+		return fmt.Errorf("start 参数无效: %w", err)
+	}
+	end, err := parseExportTime(*endStr)
+	if err != nil {
+		return fmt.Errorf("end 参数无效: %w", err)
+	}
+
+	ids := splitList(*traderIDs)
+	if len(ids) == 0 {
+		if ids, err = listExportTraders(*backend, *logDir, *dbPath); err != nil {
+			return err
+		}
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("没有可导出的交易员决策日志")
+	}
+
+	sources := make([]logger.ExportSource, 0, len(ids))
+	for _, id := range ids {
+		var l logger.IDecisionLogger
+		if *backend == logger.DecisionLogBackendFile {
+			l = logger.NewDecisionLogger(filepath.Join(*logDir, id))
+		} else if l, err = logger.NewDecisionLoggerForBackend(*backend, *dbPath, id); err != nil {
+			return err
+		}
+		sources = append(sources, logger.ExportSource{TraderID: id, Logger: l})
+	}
+
+	built, err := logger.BuildExportTables(sources, start, end)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	for _, name := range tables {
+		path := filepath.Join(*outDir, logger.ExportFileName(name, *format))
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("创建导出文件失败: %w", err)
+		}
+		err = logger.WriteExportTable(f, built[name], *format)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("写入 %s 失败: %w", path, err)
+		}
+		log.Printf("✓ %s: %d 行", path, len(built[name].Rows))
+	}
+
+	log.Printf("📊 导出完成: %d 个交易员 | 格式 %s | 输出目录 %s", len(sources), *format, *outDir)
+	return nil
+}
+
+// listExportTraders 列出存储后端中有决策日志的全部交易员
+func listExportTraders(backend, logDir, dbPath string) ([]string, error) {
+	switch backend {
+	case logger.DecisionLogBackendFile:
+		entries, err := os.ReadDir(logDir)
+		if err != nil {
+			return nil, fmt.Errorf("读取决策日志目录失败: %w", err)
+		}
+		var ids []string
+		for _, entry := range entries {
+			if entry.IsDir() {
+				ids = append(ids, entry.Name())
+			}
+		}
+		return ids, nil
+	case logger.DecisionLogBackendSQLite:
+		return logger.ListSQLiteDecisionTraders(dbPath)
+	default:
+		return nil, fmt.Errorf("不支持的决策日志存储后端: %s", backend)
+	}
+}
+
+// parseExportTime 解析时间参数（RFC3339 或本地时区的 2006-01-02），空字符串表示不限制
+func parseExportTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// splitList 拆分逗号分隔的参数（去除空白与空项）
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteFileAtomic 自动创建目录、覆盖旧内容且不留下临时文件
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	for _, content := range []string{`{"v":1}`, `{"v":2}`} {
+		if err := WriteFileAtomic(path, []byte(content)); err != nil {
+			t.Fatalf("WriteFileAtomic returned error: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != content {
+			t.Fatalf("expected %s, got %s (%v)", content, data, err)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temp file should be renamed away, stat err = %v", err)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected 0600, got %v", info.Mode().Perm())
+	}
+
+	// 目标是目录时替换失败，临时文件被清理
+	dir := filepath.Dir(path)
+	if err := WriteFileAtomic(dir, []byte("x")); err == nil {
+		t.Fatal("replacing a directory should fail")
+	}
+	if _, err := os.Stat(dir + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temp file should be removed on failure, stat err = %v", err)
+	}
+}
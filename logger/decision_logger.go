@@ -7,6 +7,9 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,6 +30,30 @@ type DecisionRecord struct {
 	ErrorMessage   string             `json:"error_message"`   // 错误信息（如果有）
 	// AIRequestDurationMs 记录 AI API 调用耗时（毫秒），方便评估调用性能
 	AIRequestDurationMs int64 `json:"ai_request_duration_ms,omitempty"`
+	// RiskReason 风控触发原因（日亏损/回撤达到上限时记录）
+	RiskReason string `json:"risk_reason,omitempty"`
+	// Trigger 本周期的触发类型（定时扫描为 scheduled），TriggerReason 为提前触发的事件描述
+	Trigger       string `json:"trigger,omitempty"`
+	TriggerReason string `json:"trigger_reason,omitempty"`
+	// EnsemblePolicy 多模型集成策略，ModelResponses 为各模型（含仲裁模型）的原始响应（单模型决策时为空）
+	EnsemblePolicy string          `json:"ensemble_policy,omitempty"`
+	ModelResponses []ModelResponse `json:"model_responses,omitempty"`
+	// AIProvider 实际应答的AI提供商（配置了故障转移链时记录）
+	AIProvider string `json:"ai_provider,omitempty"`
+
+	// 系统提示词哈希：提示词按哈希去重存储时 SystemPrompt 为空，读取时按哈希还原
+	SystemPromptHash string `json:"system_prompt_hash,omitempty"`
+}
+
+// ModelResponse 集成决策中单个模型的响应
+type ModelResponse struct {
+	Model        string `json:"model"`
+	CoTTrace     string `json:"cot_trace,omitempty"`
+	RawResponse  string `json:"raw_response,omitempty"` // 模型原始响应（解析失败时用于排查）
+	DecisionJSON string `json:"decision_json,omitempty"`
+	Error        string `json:"error,omitempty"`
+	DurationMs   int64  `json:"duration_ms"`
+	Judge        bool   `json:"judge,omitempty"` // 是否为仲裁模型的响应
 }
 
 // AccountSnapshot 账户状态快照
@@ -53,7 +80,7 @@ type PositionSnapshot struct {
 
 // DecisionAction 决策动作
 type DecisionAction struct {
-	Action    string    `json:"action"`    // open_long, open_short, close_long, close_short, update_stop_loss, update_take_profit, partial_close
+	Action    string    `json:"action"`    // open_long, open_short, close_long, close_short, update_stop_loss, update_take_profit, partial_close, auto_close_long/auto_close_short（持仓保护平仓）
 	Symbol    string    `json:"symbol"`    // 币种
 	Quantity  float64   `json:"quantity"`  // 数量（部分平仓时使用）
 	Leverage  int       `json:"leverage"`  // 杠杆（开仓时）
@@ -62,6 +89,10 @@ type DecisionAction struct {
 	Timestamp time.Time `json:"timestamp"` // 执行时间
 	Success   bool      `json:"success"`   // 是否成功
 	Error     string    `json:"error"`     // 错误信息
+	// StopLoss/TakeProfit 开仓时设置或调整后的止损止盈价，Reasoning 为AI决策理由（持仓保护平仓时为触发原因）
+	StopLoss   float64 `json:"stop_loss,omitempty"`
+	TakeProfit float64 `json:"take_profit,omitempty"`
+	Reasoning  string  `json:"reasoning,omitempty"`
 }
 
 // IDecisionLogger 决策日志记录器接口
@@ -72,18 +103,31 @@ type IDecisionLogger interface {
 	GetLatestRecords(n int) ([]*DecisionRecord, error)
 	// GetRecordByDate 获取指定日期的所有记录
 	GetRecordByDate(date time.Time) ([]*DecisionRecord, error)
+	// QueryRecords 按时间范围、币种、动作与成功状态分页查询记录（按时间倒序：从新到旧）
+	QueryRecords(q DecisionQuery) (*DecisionPage, error)
 	// CleanOldRecords 清理N天前的旧记录
 	CleanOldRecords(days int) error
 	// GetStatistics 获取统计信息
 	GetStatistics() (*Statistics, error)
 	// AnalyzePerformance 分析最近N个周期的交易表现
 	AnalyzePerformance(lookbackCycles int) (*PerformanceAnalysis, error)
+	// ApplyRetention 执行保留策略：系统提示词去重、过期记录归档或删除（保留降采样的净值快照）
+	ApplyRetention(policy RetentionPolicy) (*RetentionResult, error)
+	// GetEquitySnapshots 获取记录移出在线存储前保留的降采样净值快照（按时间正序）
+	GetEquitySnapshots() ([]EquitySnapshot, error)
+	// LoadPositionHistory 读取已持久化的持仓生命周期与回放起点
+	LoadPositionHistory() (*PositionHistory, error)
+	// SavePositionHistory 持久化已结束的持仓生命周期（已存在的忽略）并更新回放起点
+	SavePositionHistory(positions []*PositionLifecycle, since time.Time) error
 }
 
 // DecisionLogger 决策日志记录器
 type DecisionLogger struct {
 	logDir      string
 	cycleNumber int
+	clock       func() time.Time // 时间来源（回测时使用模拟时钟）
+	mu          sync.Mutex       // 保护周期编号（交易周期与持仓保护监控可能并发写入）
+	prompts     *promptStore     // 去重存储的系统提示词
 }
 
 // NewDecisionLogger 创建决策日志记录器
@@ -104,21 +148,48 @@ func NewDecisionLogger(logDir string) IDecisionLogger {
 
 	return &DecisionLogger{
 		logDir:      logDir,
-		cycleNumber: 0,
+		cycleNumber: lastCycleNumber(logDir), // 重启后从已有记录的最大编号继续，避免周期编号重复
+		clock:       time.Now,
+		prompts:     newPromptStore(filepath.Join(logDir, promptDirName)),
+	}
+}
+
+// lastCycleNumber 从日志文件名（decision_YYYYMMDD_HHMMSS_cycleN.json）中解析最大周期编号
+func lastCycleNumber(logDir string) int {
+	files, err := filepath.Glob(filepath.Join(logDir, "decision_*_cycle*.json"))
+	if err != nil {
+		return 0
+	}
+	last := 0
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		idx := strings.LastIndex(name, "_cycle")
+		if n, err := strconv.Atoi(name[idx+len("_cycle"):]); err == nil && n > last {
+			last = n
+		}
+	}
+	return last
+}
+
+// NewDecisionLoggerWithClock 创建使用指定时钟的决策日志记录器（用于回测）
+func NewDecisionLoggerWithClock(logDir string, clock func() time.Time) IDecisionLogger {
+	l := NewDecisionLogger(logDir).(*DecisionLogger)
+	if clock != nil {
+		l.clock = clock
 	}
+	return l
 }
 
 // LogDecision 记录决策
 func (l *DecisionLogger) LogDecision(record *DecisionRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	l.cycleNumber++
 	record.CycleNumber = l.cycleNumber
-	record.Timestamp = time.Now()
-
-	// 生成文件名：decision_YYYYMMDD_HHMMSS_cycleN.json
-	filename := fmt.Sprintf("decision_%s_cycle%d.json",
-		record.Timestamp.Format("20060102_150405"),
-		record.CycleNumber)
+	record.Timestamp = l.clock()
 
+	filename := recordFileName(record)
 	filepath := filepath.Join(l.logDir, filename)
 
 	// 序列化为JSON（带缩进，方便阅读）
@@ -136,6 +207,29 @@ func (l *DecisionLogger) LogDecision(record *DecisionRecord) error {
 	return nil
 }
 
+// recordFileName 记录的文件名：decision_YYYYMMDD_HHMMSS_cycleN.json
+func recordFileName(record *DecisionRecord) string {
+	return fmt.Sprintf("decision_%s_cycle%d.json",
+		record.Timestamp.Format("20060102_150405"),
+		record.CycleNumber)
+}
+
+// readRecord 读取决策记录文件（去重存储的系统提示词按哈希还原）
+func (l *DecisionLogger) readRecord(path string) (*DecisionRecord, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var record DecisionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	if record.SystemPrompt == "" && record.SystemPromptHash != "" {
+		record.SystemPrompt = l.prompts.load(record.SystemPromptHash)
+	}
+	return &record, nil
+}
+
 // GetLatestRecords 获取最近N条记录（按时间正序：从旧到新）
 func (l *DecisionLogger) GetLatestRecords(n int) ([]*DecisionRecord, error) {
 	files, err := ioutil.ReadDir(l.logDir)
@@ -152,18 +246,12 @@ func (l *DecisionLogger) GetLatestRecords(n int) ([]*DecisionRecord, error) {
 			continue
 		}
 
-		filepath := filepath.Join(l.logDir, file.Name())
-		data, err := ioutil.ReadFile(filepath)
+		record, err := l.readRecord(filepath.Join(l.logDir, file.Name()))
 		if err != nil {
 			continue
 		}
 
-		var record DecisionRecord
-		if err := json.Unmarshal(data, &record); err != nil {
-			continue
-		}
-
-		records = append(records, &record)
+		records = append(records, record)
 		count++
 	}
 
@@ -187,17 +275,12 @@ func (l *DecisionLogger) GetRecordByDate(date time.Time) ([]*DecisionRecord, err
 
 	var records []*DecisionRecord
 	for _, filepath := range files {
-		data, err := ioutil.ReadFile(filepath)
+		record, err := l.readRecord(filepath)
 		if err != nil {
 			continue
 		}
 
-		var record DecisionRecord
-		if err := json.Unmarshal(data, &record); err != nil {
-			continue
-		}
-
-		records = append(records, &record)
+		records = append(records, record)
 	}
 
 	return records, nil
@@ -249,17 +332,11 @@ func (l *DecisionLogger) GetStatistics() (*Statistics, error) {
 			continue
 		}
 
-		filepath := filepath.Join(l.logDir, file.Name())
-		data, err := ioutil.ReadFile(filepath)
+		record, err := l.readRecord(filepath.Join(l.logDir, file.Name()))
 		if err != nil {
 			continue
 		}
 
-		var record DecisionRecord
-		if err := json.Unmarshal(data, &record); err != nil {
-			continue
-		}
-
 		stats.TotalCycles++
 
 		for _, action := range record.Decisions {
@@ -342,7 +419,12 @@ type SymbolPerformance struct {
 
 // AnalyzePerformance 分析最近N个周期的交易表现
 func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAnalysis, error) {
-	records, err := l.GetLatestRecords(lookbackCycles)
+	return analyzePerformance(l.GetLatestRecords, lookbackCycles)
+}
+
+// analyzePerformance 根据最近的决策记录重建已完成交易并统计表现（文件与SQLite存储共用）
+func analyzePerformance(getLatestRecords func(n int) ([]*DecisionRecord, error), lookbackCycles int) (*PerformanceAnalysis, error) {
+	records, err := getLatestRecords(lookbackCycles)
 	if err != nil {
 		return nil, fmt.Errorf("读取历史记录失败: %w", err)
 	}
@@ -354,20 +436,32 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 		}, nil
 	}
 
-	analysis := &PerformanceAnalysis{
-		RecentTrades: []TradeOutcome{},
-		SymbolStats:  make(map[string]*SymbolPerformance),
+	// 为了避免开仓记录在窗口外导致匹配失败，需要先从所有历史记录中找出未平仓的持仓
+	// 获取更多历史记录来构建完整的持仓状态（使用更大的窗口）
+	allRecords, err := getLatestRecords(lookbackCycles * 3) // 扩大3倍窗口
+	if err != nil || len(allRecords) <= len(records) {
+		allRecords = nil
 	}
 
+	analysis := SummarizeTrades(ReconstructTrades(allRecords, records))
+
+	// 计算夏普比率（需要至少2个数据点）
+	analysis.SharpeRatio = calculateSharpeRatio(records)
+
+	return analysis, nil
+}
+
+// ReconstructTrades 根据成功执行的开平仓动作重建已完成的交易（按平仓时间正序）
+// history 为包含更早周期的记录（可为空），只用于恢复 records 开始前已开仓的持仓
+func ReconstructTrades(history, records []*DecisionRecord) []TradeOutcome {
+	var trades []TradeOutcome
+
 	// 追踪持仓状态：symbol_side -> {side, openPrice, openTime, quantity, leverage}
 	openPositions := make(map[string]map[string]interface{})
 
-	// 为了避免开仓记录在窗口外导致匹配失败，需要先从所有历史记录中找出未平仓的持仓
-	// 获取更多历史记录来构建完整的持仓状态（使用更大的窗口）
-	allRecords, err := l.GetLatestRecords(lookbackCycles * 3) // 扩大3倍窗口
-	if err == nil && len(allRecords) > len(records) {
+	if len(history) > 0 {
 		// 先从扩大的窗口中收集所有开仓记录
-		for _, record := range allRecords {
+		for _, record := range history {
 			for _, action := range record.Decisions {
 				if !action.Success {
 					continue
@@ -527,32 +621,7 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 								CloseTime:     action.Timestamp,
 							}
 
-							analysis.RecentTrades = append(analysis.RecentTrades, outcome)
-							analysis.TotalTrades++ // 🔧 只在完全平倉時計數
-
-							// 分类交易
-							if accumulatedPnL > 0 {
-								analysis.WinningTrades++
-								analysis.AvgWin += accumulatedPnL
-							} else if accumulatedPnL < 0 {
-								analysis.LosingTrades++
-								analysis.AvgLoss += accumulatedPnL
-							}
-
-							// 更新币种统计
-							if _, exists := analysis.SymbolStats[symbol]; !exists {
-								analysis.SymbolStats[symbol] = &SymbolPerformance{
-									Symbol: symbol,
-								}
-							}
-							stats := analysis.SymbolStats[symbol]
-							stats.TotalTrades++
-							stats.TotalPnL += accumulatedPnL
-							if accumulatedPnL > 0 {
-								stats.WinningTrades++
-							} else if accumulatedPnL < 0 {
-								stats.LosingTrades++
-							}
+							trades = append(trades, outcome) // 🔧 只在完全平倉時計數
 
 							// 刪除持倉記錄
 							delete(openPositions, posKey)
@@ -587,32 +656,7 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 							CloseTime:     action.Timestamp,
 						}
 
-						analysis.RecentTrades = append(analysis.RecentTrades, outcome)
-						analysis.TotalTrades++
-
-						// 分类交易
-						if totalPnL > 0 {
-							analysis.WinningTrades++
-							analysis.AvgWin += totalPnL
-						} else if totalPnL < 0 {
-							analysis.LosingTrades++
-							analysis.AvgLoss += totalPnL
-						}
-
-						// 更新币种统计
-						if _, exists := analysis.SymbolStats[symbol]; !exists {
-							analysis.SymbolStats[symbol] = &SymbolPerformance{
-								Symbol: symbol,
-							}
-						}
-						stats := analysis.SymbolStats[symbol]
-						stats.TotalTrades++
-						stats.TotalPnL += totalPnL
-						if totalPnL > 0 {
-							stats.WinningTrades++
-						} else if totalPnL < 0 {
-							stats.LosingTrades++
-						}
+						trades = append(trades, outcome)
 
 						// 刪除持倉記錄
 						delete(openPositions, posKey)
@@ -622,6 +666,55 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 		}
 	}
 
+	return trades
+}
+
+// SummarizeTrades 根据已完成的交易（按平仓时间正序）生成表现统计（不含夏普比率）
+func SummarizeTrades(trades []TradeOutcome) *PerformanceAnalysis {
+	analysis := &PerformanceAnalysis{
+		RecentTrades: []TradeOutcome{},
+		SymbolStats:  make(map[string]*SymbolPerformance),
+	}
+	for _, trade := range trades {
+		analysis.addTrade(trade)
+	}
+	analysis.finalize()
+	return analysis
+}
+
+// addTrade 累加一笔已完成交易（AvgWin/AvgLoss 暂存总和，由 finalize 计算均值）
+func (analysis *PerformanceAnalysis) addTrade(outcome TradeOutcome) {
+	symbol := outcome.Symbol
+	analysis.RecentTrades = append(analysis.RecentTrades, outcome)
+	analysis.TotalTrades++
+
+	// 分类交易
+	if outcome.PnL > 0 {
+		analysis.WinningTrades++
+		analysis.AvgWin += outcome.PnL
+	} else if outcome.PnL < 0 {
+		analysis.LosingTrades++
+		analysis.AvgLoss += outcome.PnL
+	}
+
+	// 更新币种统计
+	if _, exists := analysis.SymbolStats[symbol]; !exists {
+		analysis.SymbolStats[symbol] = &SymbolPerformance{
+			Symbol: symbol,
+		}
+	}
+	stats := analysis.SymbolStats[symbol]
+	stats.TotalTrades++
+	stats.TotalPnL += outcome.PnL
+	if outcome.PnL > 0 {
+		stats.WinningTrades++
+	} else if outcome.PnL < 0 {
+		stats.LosingTrades++
+	}
+}
+
+// finalize 计算胜率、盈亏比与币种统计，并只保留最近10笔交易（最新的在前）
+func (analysis *PerformanceAnalysis) finalize() {
 	// 计算统计指标
 	if analysis.TotalTrades > 0 {
 		analysis.WinRate = (float64(analysis.WinningTrades) / float64(analysis.TotalTrades)) * 100
@@ -679,16 +772,11 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 			analysis.RecentTrades[i], analysis.RecentTrades[j] = analysis.RecentTrades[j], analysis.RecentTrades[i]
 		}
 	}
-
-	// 计算夏普比率（需要至少2个数据点）
-	analysis.SharpeRatio = l.calculateSharpeRatio(records)
-
-	return analysis, nil
 }
 
 // calculateSharpeRatio 计算夏普比率
 // 基于账户净值的变化计算风险调整后收益
-func (l *DecisionLogger) calculateSharpeRatio(records []*DecisionRecord) float64 {
+func calculateSharpeRatio(records []*DecisionRecord) float64 {
 	if len(records) < 2 {
 		return 0.0
 	}
@@ -0,0 +1,177 @@
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 决策查询分页大小
+const (
+	DefaultDecisionQueryLimit = 50
+	MaxDecisionQueryLimit     = 500
+)
+
+// DecisionQuery 决策记录查询条件（零值字段表示不过滤）
+// Symbol、Action、Success 针对同一条执行动作：至少有一个动作同时满足时记录才匹配；
+// 只设置 Success 时按周期是否成功过滤
+type DecisionQuery struct {
+	Start   time.Time // 起始时间（含）
+	End     time.Time // 结束时间（不含）
+	Symbol  string
+	Action  string
+	Success *bool
+	Cursor  string // 上一页返回的 NextCursor，为空表示从最新记录开始
+	Limit   int    // 每页条数，0表示 DefaultDecisionQueryLimit
+}
+
+// DecisionPage 一页查询结果（按时间倒序：最新的在前），NextCursor 为空表示没有更多记录
+type DecisionPage struct {
+	Records    []*DecisionRecord `json:"records"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// decisionCursor 分页游标：上一页最后一条记录的时间戳（毫秒）与周期编号
+type decisionCursor struct {
+	timestamp int64
+	cycle     int
+}
+
+// parseDecisionCursor 解析分页游标（格式 <毫秒时间戳>_<周期编号>）
+func parseDecisionCursor(cursor string) (*decisionCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	ts, cycle, ok := strings.Cut(cursor, "_")
+	if !ok {
+		return nil, fmt.Errorf("无效的分页游标: %s", cursor)
+	}
+	c := &decisionCursor{}
+	var err error
+	if c.timestamp, err = strconv.ParseInt(ts, 10, 64); err != nil {
+		return nil, fmt.Errorf("无效的分页游标: %s", cursor)
+	}
+	if c.cycle, err = strconv.Atoi(cycle); err != nil {
+		return nil, fmt.Errorf("无效的分页游标: %s", cursor)
+	}
+	return c, nil
+}
+
+// cursorFor 生成指向该记录之后（更早）的分页游标
+func cursorFor(record *DecisionRecord) string {
+	return fmt.Sprintf("%d_%d", record.Timestamp.UnixMilli(), record.CycleNumber)
+}
+
+// before 记录是否位于游标之后（按时间倒序）
+func (c *decisionCursor) before(record *DecisionRecord) bool {
+	if c == nil {
+		return true
+	}
+	ts := record.Timestamp.UnixMilli()
+	return ts < c.timestamp || (ts == c.timestamp && record.CycleNumber < c.cycle)
+}
+
+// limit 返回有效的每页条数
+func (q DecisionQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultDecisionQueryLimit
+	}
+	return min(q.Limit, MaxDecisionQueryLimit)
+}
+
+// filtersActions 是否按执行动作过滤
+func (q DecisionQuery) filtersActions() bool {
+	return q.Symbol != "" || q.Action != ""
+}
+
+// matchAction 动作是否满足 Symbol/Action/Success 条件
+func (q DecisionQuery) matchAction(action DecisionAction) bool {
+	return (q.Symbol == "" || strings.EqualFold(action.Symbol, q.Symbol)) &&
+		(q.Action == "" || action.Action == q.Action) &&
+		(q.Success == nil || action.Success == *q.Success)
+}
+
+// Match 记录是否满足查询条件（不含分页游标）
+func (q DecisionQuery) Match(record *DecisionRecord) bool {
+	if !q.Start.IsZero() && record.Timestamp.Before(q.Start) {
+		return false
+	}
+	if !q.End.IsZero() && !record.Timestamp.Before(q.End) {
+		return false
+	}
+	if !q.filtersActions() {
+		return q.Success == nil || record.Success == *q.Success
+	}
+	for _, action := range record.Decisions {
+		if q.matchAction(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryRecords 按条件分页查询决策记录（按时间倒序）
+// 文件名中的时间用于跳过时间范围外的文件，其余条件需要解析文件后过滤
+func (l *DecisionLogger) QueryRecords(q DecisionQuery) (*DecisionPage, error) {
+	cursor, err := parseDecisionCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(l.logDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+
+	// 文件名按写入时的时区精确到秒，范围边界放宽一天以覆盖时区差异，由 Match 精确过滤
+	var startName, endName string
+	if !q.Start.IsZero() {
+		startName = "decision_" + q.Start.Add(-24*time.Hour).Local().Format("20060102_150405")
+	}
+	if !q.End.IsZero() {
+		endName = "decision_" + q.End.Add(24*time.Hour).Local().Format("20060102_150405")
+	}
+
+	var records []*DecisionRecord
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasPrefix(name, "decision_") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		if (startName != "" && name < startName) || (endName != "" && name > endName) {
+			continue
+		}
+		record, err := l.readRecord(filepath.Join(l.logDir, name))
+		if err != nil {
+			continue
+		}
+		if cursor.before(record) && q.Match(record) {
+			records = append(records, record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.After(b.Timestamp)
+		}
+		return a.CycleNumber > b.CycleNumber
+	})
+	return newDecisionPage(records, q.limit()), nil
+}
+
+// newDecisionPage 截取一页记录（records 已按时间倒序），超出 limit 时生成下一页游标
+func newDecisionPage(records []*DecisionRecord, limit int) *DecisionPage {
+	page := &DecisionPage{Records: records}
+	if len(records) > limit {
+		page.Records = records[:limit]
+		page.NextCursor = cursorFor(page.Records[limit-1])
+	}
+	if page.Records == nil {
+		page.Records = []*DecisionRecord{}
+	}
+	return page
+}
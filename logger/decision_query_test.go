@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestQueryRecords 两种存储后端的时间范围、币种、动作、成功状态过滤与游标分页
+func TestQueryRecords(t *testing.T) {
+	dir := t.TempDir()
+	sqliteLogger, err := NewSQLiteDecisionLogger(filepath.Join(dir, "decisions.db"), "trader_a")
+	if err != nil {
+		t.Fatalf("NewSQLiteDecisionLogger returned error: %v", err)
+	}
+	fileLogger := NewDecisionLogger(filepath.Join(dir, "files")).(*DecisionLogger)
+
+	start := time.Date(2025, 3, 4, 0, 0, 0, 0, time.Local) // 周二
+	for name, l := range map[string]IDecisionLogger{"file": fileLogger, "sqlite": sqliteLogger} {
+		t.Run(name, func(t *testing.T) {
+			now := start.Add(-time.Hour)
+			clock := func() time.Time { return now }
+			if fl, ok := l.(*DecisionLogger); ok {
+				fl.clock = clock
+			} else {
+				l.(*SQLiteDecisionLogger).clock = clock
+			}
+
+			// 每小时一个周期（共50个）：SOL 开空交替成功/失败，每3个周期一次 BTC 开多
+			for i := 0; i < 50; i++ {
+				symbol, action := "SOLUSDT", "open_short"
+				if i%3 == 0 {
+					symbol, action = "BTCUSDT", "open_long"
+				}
+				record := testRecord(1000, DecisionAction{Action: action, Symbol: symbol, Timestamp: now, Success: i%2 == 0})
+				record.Success = i%5 != 0
+				if err := l.LogDecision(record); err != nil {
+					t.Fatalf("LogDecision returned error: %v", err)
+				}
+				now = now.Add(time.Hour)
+			}
+
+			failed := false
+			q := DecisionQuery{Start: start, End: start.Add(48 * time.Hour), Symbol: "solusdt", Action: "open_short", Success: &failed, Limit: 5}
+			var got []*DecisionRecord
+			for pages := 0; ; pages++ {
+				page, err := l.QueryRecords(q)
+				if err != nil {
+					t.Fatalf("QueryRecords returned error: %v", err)
+				}
+				got = append(got, page.Records...)
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+
+			// 窗口内周期 i=1..48：SOL（i%3!=0）且失败（i 为奇数）
+			want := 0
+			for i := 1; i <= 48; i++ {
+				if i%3 != 0 && i%2 == 1 {
+					want++
+				}
+			}
+			if len(got) != want {
+				t.Fatalf("filtered records = %d, want %d", len(got), want)
+			}
+			for i, r := range got {
+				a := r.Decisions[0]
+				if a.Symbol != "SOLUSDT" || a.Action != "open_short" || a.Success || r.Timestamp.Before(start) {
+					t.Errorf("record %d does not match filter: %+v", i, a)
+				}
+				if i > 0 && !r.Timestamp.Before(got[i-1].Timestamp) {
+					t.Errorf("records should be newest first, got %v after %v", r.Timestamp, got[i-1].Timestamp)
+				}
+			}
+
+			// 只设置 Success 时按周期成功状态过滤
+			page, _ := l.QueryRecords(DecisionQuery{Success: &failed, Limit: 100})
+			if len(page.Records) != 10 {
+				t.Errorf("failed cycles = %d, want 10", len(page.Records))
+			}
+
+			if _, err := l.QueryRecords(DecisionQuery{Cursor: "bad"}); err == nil {
+				t.Error("invalid cursor should return an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package logger
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 导出表名称
+const (
+	ExportCycles   = "cycles"            // 每个决策周期一行
+	ExportActions  = "actions"           // 每个执行动作一行
+	ExportAccounts = "account_snapshots" // 每个周期的账户快照
+	ExportTrades   = "trades"            // 由开平仓动作重建的已完成交易
+)
+
+// ExportTableNames 所有可导出的表（按导出顺序）
+var ExportTableNames = []string{ExportCycles, ExportActions, ExportAccounts, ExportTrades}
+
+// 导出格式
+const (
+	ExportFormatCSV     = "csv"
+	ExportFormatParquet = "parquet"
+)
+
+// ColumnKind 导出列的数据类型
+type ColumnKind int
+
+const (
+	ColumnString ColumnKind = iota
+	ColumnInt               // int64
+	ColumnFloat             // float64
+	ColumnBool
+	ColumnTime // time.Time，Parquet 中为毫秒时间戳
+)
+
+// ExportColumn 导出列
+type ExportColumn struct {
+	Name string
+	Kind ColumnKind
+}
+
+// ExportTable 扁平化的导出表，Rows 中每个值的类型与列类型一致
+type ExportTable struct {
+	Name    string
+	Columns []ExportColumn
+	Rows    [][]any
+}
+
+// ExportSource 一个交易员的决策日志
+type ExportSource struct {
+	TraderID string
+	Logger   IDecisionLogger
+}
+
+var exportSchemas = map[string][]ExportColumn{
+	ExportCycles: {
+		{"trader_id", ColumnString},
+		{"timestamp", ColumnTime},
+		{"cycle_number", ColumnInt},
+		{"success", ColumnBool},
+		{"error_message", ColumnString},
+		{"trigger", ColumnString},
+		{"trigger_reason", ColumnString},
+		{"risk_reason", ColumnString},
+		{"ai_provider", ColumnString},
+		{"ensemble_policy", ColumnString},
+		{"ai_request_duration_ms", ColumnInt},
+		{"candidate_coins", ColumnString},
+		{"position_count", ColumnInt},
+		{"action_count", ColumnInt},
+	},
+	ExportActions: {
+		{"trader_id", ColumnString},
+		{"cycle_timestamp", ColumnTime},
+		{"cycle_number", ColumnInt},
+		{"timestamp", ColumnTime},
+		{"action", ColumnString},
+		{"symbol", ColumnString},
+		{"quantity", ColumnFloat},
+		{"leverage", ColumnInt},
+		{"price", ColumnFloat},
+		{"order_id", ColumnInt},
+		{"success", ColumnBool},
+		{"error", ColumnString},
+		{"stop_loss", ColumnFloat},
+		{"take_profit", ColumnFloat},
+		{"reasoning", ColumnString},
+	},
+	ExportAccounts: {
+		{"trader_id", ColumnString},
+		{"timestamp", ColumnTime},
+		{"cycle_number", ColumnInt},
+		{"total_balance", ColumnFloat},
+		{"available_balance", ColumnFloat},
+		{"total_unrealized_profit", ColumnFloat},
+		{"position_count", ColumnInt},
+		{"margin_used_pct", ColumnFloat},
+		{"initial_balance", ColumnFloat},
+	},
+	ExportTrades: {
+		{"trader_id", ColumnString},
+		{"symbol", ColumnString},
+		{"side", ColumnString},
+		{"quantity", ColumnFloat},
+		{"leverage", ColumnInt},
+		{"open_price", ColumnFloat},
+		{"close_price", ColumnFloat},
+		{"position_value", ColumnFloat},
+		{"margin_used", ColumnFloat},
+		{"pnl", ColumnFloat},
+		{"pnl_pct", ColumnFloat},
+		{"open_time", ColumnTime},
+		{"close_time", ColumnTime},
+		{"duration_seconds", ColumnInt},
+		{"was_stop_loss", ColumnBool},
+	},
+}
+
+// ValidExportTable 是否为可导出的表
+func ValidExportTable(name string) bool {
+	_, ok := exportSchemas[name]
+	return ok
+}
+
+// ValidExportFormat 是否为支持的导出格式
+func ValidExportFormat(format string) bool {
+	return format == ExportFormatCSV || format == ExportFormatParquet
+}
+
+// CollectRecords 分页读取 [start, end) 内的全部决策记录（零值表示不限制），按时间正序返回
+func CollectRecords(l IDecisionLogger, start, end time.Time) ([]*DecisionRecord, error) {
+	var records []*DecisionRecord
+	q := DecisionQuery{Start: start, End: end, Limit: MaxDecisionQueryLimit}
+	for {
+		page, err := l.QueryRecords(q)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, page.Records...)
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// BuildExportTables 构建所有导出表（按交易员、时间正序排列）
+// cycles/actions/account_snapshots 按周期时间过滤，trades 按平仓时间过滤；
+// 交易重建需要开仓记录，因此会读取 end 之前的全部历史
+func BuildExportTables(sources []ExportSource, start, end time.Time) (map[string]*ExportTable, error) {
+	tables := make(map[string]*ExportTable, len(exportSchemas))
+	for name, columns := range exportSchemas {
+		tables[name] = &ExportTable{Name: name, Columns: columns, Rows: [][]any{}}
+	}
+
+	for _, src := range sources {
+		history, err := CollectRecords(src.Logger, time.Time{}, end)
+		if err != nil {
+			return nil, fmt.Errorf("读取交易员 %s 的决策日志失败: %w", src.TraderID, err)
+		}
+
+		for _, record := range history {
+			if record.Timestamp.Before(start) {
+				continue
+			}
+			tables[ExportCycles].Rows = append(tables[ExportCycles].Rows, []any{
+				src.TraderID,
+				record.Timestamp,
+				int64(record.CycleNumber),
+				record.Success,
+				record.ErrorMessage,
+				record.Trigger,
+				record.TriggerReason,
+				record.RiskReason,
+				record.AIProvider,
+				record.EnsemblePolicy,
+				record.AIRequestDurationMs,
+				strings.Join(record.CandidateCoins, ","),
+				int64(len(record.Positions)),
+				int64(len(record.Decisions)),
+			})
+			for _, action := range record.Decisions {
+				// 执行失败的动作可能没有执行时间，使用周期时间代替
+				actionTime := action.Timestamp
+				if actionTime.IsZero() {
+					actionTime = record.Timestamp
+				}
+				tables[ExportActions].Rows = append(tables[ExportActions].Rows, []any{
+					src.TraderID,
+					record.Timestamp,
+					int64(record.CycleNumber),
+					actionTime,
+					action.Action,
+					action.Symbol,
+					action.Quantity,
+					int64(action.Leverage),
+					action.Price,
+					action.OrderID,
+					action.Success,
+					action.Error,
+					action.StopLoss,
+					action.TakeProfit,
+					action.Reasoning,
+				})
+			}
+			account := record.AccountState
+			tables[ExportAccounts].Rows = append(tables[ExportAccounts].Rows, []any{
+				src.TraderID,
+				record.Timestamp,
+				int64(record.CycleNumber),
+				account.TotalBalance,
+				account.AvailableBalance,
+				account.TotalUnrealizedProfit,
+				int64(account.PositionCount),
+				account.MarginUsedPct,
+				account.InitialBalance,
+			})
+		}
+
+		for _, trade := range ReconstructTrades(nil, history) {
+			if trade.CloseTime.Before(start) {
+				continue
+			}
+			tables[ExportTrades].Rows = append(tables[ExportTrades].Rows, []any{
+				src.TraderID,
+				trade.Symbol,
+				trade.Side,
+				trade.Quantity,
+				int64(trade.Leverage),
+				trade.OpenPrice,
+				trade.ClosePrice,
+				trade.PositionValue,
+				trade.MarginUsed,
+				trade.PnL,
+				trade.PnLPct,
+				trade.OpenTime,
+				trade.CloseTime,
+				int64(trade.CloseTime.Sub(trade.OpenTime).Seconds()),
+				trade.WasStopLoss,
+			})
+		}
+	}
+	return tables, nil
+}
+
+// ExportFileName 导出文件名（如 trades.parquet）
+func ExportFileName(table, format string) string {
+	return table + "." + format
+}
+
+// WriteExportTable 按格式写出导出表
+func WriteExportTable(w io.Writer, table *ExportTable, format string) error {
+	switch format {
+	case ExportFormatCSV:
+		return WriteCSV(w, table)
+	case ExportFormatParquet:
+		return WriteParquet(w, table)
+	default:
+		return fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// WriteCSV 把导出表写为带表头的CSV（时间为UTC的RFC3339毫秒格式）
+func WriteCSV(w io.Writer, table *ExportTable) error {
+	writer := csv.NewWriter(w)
+	header := make([]string, len(table.Columns))
+	for i, col := range table.Columns {
+		header[i] = col.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	line := make([]string, len(table.Columns))
+	for _, row := range table.Rows {
+		for i, value := range row {
+			switch v := value.(type) {
+			case string:
+				line[i] = v
+			case int64:
+				line[i] = strconv.FormatInt(v, 10)
+			case float64:
+				line[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				line[i] = strconv.FormatBool(v)
+			case time.Time:
+				line[i] = v.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+			default:
+				return fmt.Errorf("列 %s 的值类型不支持: %T", table.Columns[i].Name, v)
+			}
+		}
+		if err := writer.Write(line); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,192 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// buildTestExport 一笔窗口前开仓、窗口内平仓的交易，以及窗口外的一笔完整交易
+func buildTestExport(t *testing.T) (map[string]*ExportTable, time.Time) {
+	t.Helper()
+	l, err := NewSQLiteDecisionLogger(filepath.Join(t.TempDir(), "decisions.db"), "trader_a")
+	if err != nil {
+		t.Fatalf("NewSQLiteDecisionLogger returned error: %v", err)
+	}
+	base := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	now := base
+	l.clock = func() time.Time { return now }
+
+	steps := []DecisionAction{
+		{Action: "open_long", Symbol: "ETHUSDT", Quantity: 1, Leverage: 5, Price: 3000},
+		{Action: "close_long", Symbol: "ETHUSDT", Quantity: 1, Price: 3100},
+		{Action: "open_short", Symbol: "BTCUSDT", Quantity: 0.1, Leverage: 10, Price: 100000},
+		{Action: "close_short", Symbol: "BTCUSDT", Quantity: 0.1, Price: 99000},
+	}
+	for _, action := range steps {
+		action.Timestamp = now
+		action.Success = true
+		if err := l.LogDecision(testRecord(1000, action)); err != nil {
+			t.Fatalf("LogDecision returned error: %v", err)
+		}
+		now = now.Add(time.Hour)
+	}
+
+	start := base.Add(150 * time.Minute) // 第3个周期（BTC开空）之后
+	tables, err := BuildExportTables([]ExportSource{{TraderID: "trader_a", Logger: l}}, start, time.Time{})
+	if err != nil {
+		t.Fatalf("BuildExportTables returned error: %v", err)
+	}
+	return tables, base
+}
+
+// TestBuildExportTables 周期类表按周期时间过滤，交易按平仓时间过滤且可使用窗口前的开仓
+func TestBuildExportTables(t *testing.T) {
+	tables, base := buildTestExport(t)
+
+	if got := len(tables[ExportCycles].Rows); got != 1 {
+		t.Fatalf("expected 1 cycle row, got %d", got)
+	}
+	if got := len(tables[ExportActions].Rows); got != 1 {
+		t.Fatalf("expected 1 action row, got %d", got)
+	}
+	if got := len(tables[ExportAccounts].Rows); got != 1 {
+		t.Fatalf("expected 1 account row, got %d", got)
+	}
+
+	trades := tables[ExportTrades].Rows
+	if len(trades) != 1 {
+		t.Fatalf("expected 1 trade row, got %d", len(trades))
+	}
+	trade := trades[0]
+	if trade[0] != "trader_a" || trade[1] != "BTCUSDT" || trade[2] != "short" {
+		t.Fatalf("unexpected trade row: %v", trade)
+	}
+	if pnl := trade[9].(float64); math.Abs(pnl-100) > 1e-9 {
+		t.Fatalf("expected pnl 100, got %v", pnl)
+	}
+	if !trade[11].(time.Time).Equal(base.Add(2*time.Hour)) || trade[13] != int64(3600) {
+		t.Fatalf("unexpected trade timing: open=%v duration=%v", trade[11], trade[13])
+	}
+
+	for name, table := range tables {
+		for _, row := range table.Rows {
+			if len(row) != len(table.Columns) {
+				t.Fatalf("%s: row has %d values for %d columns", name, len(row), len(table.Columns))
+			}
+		}
+	}
+}
+
+// TestWriteCSV 表头与值格式
+func TestWriteCSV(t *testing.T) {
+	tables, _ := buildTestExport(t)
+	var buf bytes.Buffer
+	if err := WriteExportTable(&buf, tables[ExportTrades], ExportFormatCSV); err != nil {
+		t.Fatalf("WriteCSV returned error: %v", err)
+	}
+	lines, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse csv: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected header and 1 row, got %d lines", len(lines))
+	}
+	if strings.Join(lines[0][:3], ",") != "trader_id,symbol,side" {
+		t.Fatalf("unexpected header: %v", lines[0])
+	}
+	row := lines[1]
+	if row[3] != "0.1" || row[11] != "2025-03-01T10:00:00.000Z" || row[14] != "false" {
+		t.Fatalf("unexpected csv row: %v", row)
+	}
+}
+
+// TestWriteParquet 用 parquet-go 的读取器读回文件，校验行数、列名、类型和值
+func TestWriteParquet(t *testing.T) {
+	table := &ExportTable{
+		Name: "sample",
+		Columns: []ExportColumn{
+			{"name", ColumnString},
+			{"count", ColumnInt},
+			{"price", ColumnFloat},
+			{"ok", ColumnBool},
+			{"at", ColumnTime},
+		},
+	}
+	at := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		table.Rows = append(table.Rows, []any{strings.Repeat("x", i), int64(i), float64(i) / 2, i%3 == 0, at.Add(time.Duration(i) * time.Second)})
+	}
+
+	var buf bytes.Buffer
+	if err := WriteExportTable(&buf, table, ExportFormatParquet); err != nil {
+		t.Fatalf("WriteParquet returned error: %v", err)
+	}
+	file, err := buffer.NewBufferFile(buf.Bytes())
+	if err != nil {
+		t.Fatalf("open buffer: %v", err)
+	}
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatalf("read parquet footer: %v", err)
+	}
+	defer pr.ReadStop()
+
+	if pr.GetNumRows() != 20 {
+		t.Fatalf("expected 20 rows, got %d", pr.GetNumRows())
+	}
+	schema := pr.Footer.Schema
+	if len(schema) != 6 {
+		t.Fatalf("expected root plus 5 schema elements, got %d", len(schema))
+	}
+	// 读取器把列名改成了内部名称，文件中的原始列名保存在 ExName
+	for i, col := range table.Columns {
+		if name := pr.SchemaHandler.Infos[i+1].ExName; name != col.Name {
+			t.Fatalf("schema element %d: expected %s, got %s", i, col.Name, name)
+		}
+	}
+	if schema[5].GetConvertedType() != parquet.ConvertedType_TIMESTAMP_MILLIS {
+		t.Fatalf("time column should be TIMESTAMP_MILLIS, got %v", schema[5].GetConvertedType())
+	}
+
+	column := func(index int64) []any {
+		values, _, _, err := pr.ReadColumnByIndex(index, 20)
+		if err != nil {
+			t.Fatalf("read column %d: %v", index, err)
+		}
+		if len(values) != 20 {
+			t.Fatalf("column %d: expected 20 values, got %d", index, len(values))
+		}
+		return values
+	}
+	names, counts, prices, oks, times := column(0), column(1), column(2), column(3), column(4)
+	for i := 0; i < 20; i++ {
+		if names[i] != strings.Repeat("x", i) || counts[i] != int64(i) || prices[i] != float64(i)/2 || oks[i] != (i%3 == 0) {
+			t.Fatalf("row %d: unexpected values %v %v %v %v", i, names[i], counts[i], prices[i], oks[i])
+		}
+		if times[i] != at.Add(time.Duration(i)*time.Second).UnixMilli() {
+			t.Fatalf("row %d: unexpected timestamp millis %v", i, times[i])
+		}
+	}
+
+	// 空表同样是合法的 Parquet 文件；值类型与列类型不一致时报错
+	buf.Reset()
+	if err := WriteParquet(&buf, &ExportTable{Columns: table.Columns}); err != nil {
+		t.Fatalf("WriteParquet on empty table returned error: %v", err)
+	}
+	file, _ = buffer.NewBufferFile(buf.Bytes())
+	if empty, err := reader.NewParquetColumnReader(file, 1); err != nil || empty.GetNumRows() != 0 {
+		t.Fatalf("empty table should be readable with 0 rows: %v", err)
+	}
+	if err := WriteParquet(&buf, &ExportTable{Columns: table.Columns[:1], Rows: [][]any{{int64(1)}}}); err == nil {
+		t.Fatal("mismatched value type should fail")
+	}
+}
@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// WriteParquet 把导出表写为 Parquet 文件（所有列为 REQUIRED，时间列为毫秒时间戳）
+func WriteParquet(w io.Writer, table *ExportTable) error {
+	pw, err := writer.NewCSVWriterFromWriter(parquetSchema(table.Columns), w, 1) // 导出表不大，单协程编码
+	if err != nil {
+		return fmt.Errorf("创建Parquet写入器失败: %w", err)
+	}
+	for i, row := range table.Rows {
+		values, err := parquetRow(table.Columns, row)
+		if err != nil {
+			return fmt.Errorf("第 %d 行: %w", i, err)
+		}
+		if err := pw.Write(values); err != nil {
+			return fmt.Errorf("写入Parquet行失败: %w", err)
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		return fmt.Errorf("写入Parquet文件尾失败: %w", err)
+	}
+	return nil
+}
+
+// parquetSchema 导出列对应的 parquet-go 列元数据
+func parquetSchema(columns []ExportColumn) []string {
+	schema := make([]string, len(columns))
+	for i, col := range columns {
+		var typ string
+		switch col.Kind {
+		case ColumnInt:
+			typ = "type=INT64"
+		case ColumnFloat:
+			typ = "type=DOUBLE"
+		case ColumnBool:
+			typ = "type=BOOLEAN"
+		case ColumnTime:
+			typ = "type=INT64, convertedtype=TIMESTAMP_MILLIS"
+		default:
+			typ = "type=BYTE_ARRAY, convertedtype=UTF8"
+		}
+		schema[i] = fmt.Sprintf("name=%s, %s, repetitiontype=REQUIRED", col.Name, typ)
+	}
+	return schema
+}
+
+// parquetRow 把一行的值转换为 Parquet 物理类型（时间转为毫秒时间戳）
+func parquetRow(columns []ExportColumn, row []any) ([]any, error) {
+	values := make([]any, len(columns))
+	for i, col := range columns {
+		v := row[i]
+		ok := false
+		switch col.Kind {
+		case ColumnInt:
+			_, ok = v.(int64)
+		case ColumnFloat:
+			_, ok = v.(float64)
+		case ColumnBool:
+			_, ok = v.(bool)
+		case ColumnTime:
+			var t time.Time
+			if t, ok = v.(time.Time); ok {
+				v = t.UnixMilli()
+			}
+		default:
+			_, ok = v.(string)
+		}
+		if !ok {
+			return nil, fmt.Errorf("列 %s 的值类型不支持: %T", col.Name, row[i])
+		}
+		values[i] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,492 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 持仓退出原因
+const (
+	ExitCauseAIClose     = "ai_close"         // AI决策平仓（含风控强制平仓）
+	ExitCauseStopLoss    = "stop_loss"        // 交易所止损单触发
+	ExitCauseTakeProfit  = "take_profit"      // 交易所止盈单触发
+	ExitCauseMonitor     = "drawdown_monitor" // 持仓保护（回撤监控）平仓
+	ExitCauseLiquidation = "liquidation"      // 强平
+	ExitCauseManual      = "manual"           // 系统外的人工操作
+)
+
+// 持仓事件类型
+const (
+	PositionEventOpen         = "open"
+	PositionEventAdd          = "add" // 同方向加仓
+	PositionEventStopLoss     = "update_stop_loss"
+	PositionEventTakeProfit   = "update_take_profit"
+	PositionEventPartialClose = "partial_close"
+	PositionEventClose        = "close"
+)
+
+// 持仓状态
+const (
+	PositionStatusOpen   = "open"
+	PositionStatusClosed = "closed"
+)
+
+// PositionEvent 持仓生命周期中的一个事件
+type PositionEvent struct {
+	Type        string    `json:"type"`
+	Time        time.Time `json:"time"`
+	CycleNumber int       `json:"cycle_number,omitempty"` // 所属决策周期（交易所侧成交为0）
+	Price       float64   `json:"price,omitempty"`
+	Quantity    float64   `json:"quantity,omitempty"`
+	StopLoss    float64   `json:"stop_loss,omitempty"`
+	TakeProfit  float64   `json:"take_profit,omitempty"`
+	PnL         float64   `json:"pnl,omitempty"`   // 本次平仓盈亏（USDT，不含手续费）
+	Cause       string    `json:"cause,omitempty"` // 平仓事件的退出原因
+	OrderID     string    `json:"order_id,omitempty"`
+	Reasoning   string    `json:"reasoning,omitempty"`
+	// Inferred 持仓从快照中消失但账本尚无对应成交，原因与价格按最近的止损/止盈价推断
+	Inferred bool `json:"inferred,omitempty"`
+}
+
+// PositionLifecycle 单个持仓从开仓、止损止盈调整、部分平仓到最终退出的完整记录
+type PositionLifecycle struct {
+	ID                string          `json:"id"` // 币种-方向-开仓时间（毫秒），同一持仓始终不变
+	Symbol            string          `json:"symbol"`
+	Side              string          `json:"side"`
+	Status            string          `json:"status"`
+	Leverage          int             `json:"leverage"`
+	EntryPrice        float64         `json:"entry_price"` // 加权开仓均价
+	Quantity          float64         `json:"quantity"`    // 累计开仓数量
+	RemainingQuantity float64         `json:"remaining_quantity"`
+	StopLoss          float64         `json:"stop_loss,omitempty"` // 当前止损价
+	TakeProfit        float64         `json:"take_profit,omitempty"`
+	OpenTime          time.Time       `json:"open_time"`
+	OpenCycle         int             `json:"open_cycle"`
+	EntryReasoning    string          `json:"entry_reasoning,omitempty"`
+	CloseTime         time.Time       `json:"close_time"`
+	ExitPrice         float64         `json:"exit_price,omitempty"` // 加权平仓均价
+	ExitCause         string          `json:"exit_cause,omitempty"` // 最终退出原因
+	RealizedPnL       float64         `json:"realized_pnl"`
+	RealizedPnLPct    float64         `json:"realized_pnl_pct"` // 相对保证金
+	Events            []PositionEvent `json:"events"`
+
+	closedQty   float64
+	closedValue float64
+	lastMark    float64
+	seen        bool // 是否已出现在持仓快照中
+	dropped     bool
+}
+
+// PositionExit 决策日志之外的平仓成交（交易所止损止盈、强平、人工操作），由订单账本提供
+type PositionExit struct {
+	Symbol   string
+	Side     string  // long / short
+	Quantity float64 // 0 表示全部平仓
+	Price    float64
+	Time     time.Time
+	Cause    string
+	OrderID  string
+}
+
+// positionID 持仓ID：币种-方向-开仓时间（毫秒）
+func positionID(symbol, side string, openTime time.Time) string {
+	return fmt.Sprintf("%s-%s-%d", symbol, side, openTime.UnixMilli())
+}
+
+// BuildPositionLifecycles 按时间顺序回放决策记录与交易所侧平仓成交，重建每个持仓的生命周期（按开仓时间排序）
+// 账本尚未对账出成交时，已出现在快照中的持仓从后续快照消失视为被平仓（原因推断）；
+// 从未出现在快照中就消失的持仓视为未成交的挂单，不计入结果
+func BuildPositionLifecycles(records []*DecisionRecord, exits []PositionExit) []*PositionLifecycle {
+	sorted := make([]PositionExit, len(exits))
+	copy(sorted, exits)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	b := &lifecycleBuilder{open: make(map[string]*PositionLifecycle), exits: sorted}
+	for _, record := range records {
+		// 持仓快照在周期开始时采集，早于本周期的执行动作
+		snapshotTime := record.Timestamp
+		for _, action := range record.Decisions {
+			if !action.Timestamp.IsZero() && action.Timestamp.Before(snapshotTime) {
+				snapshotTime = action.Timestamp
+			}
+		}
+		b.applyExits(snapshotTime)
+		if record.AccountState != (AccountSnapshot{}) {
+			b.applySnapshot(record.Positions, snapshotTime)
+		}
+
+		for _, action := range record.Decisions {
+			if !action.Success {
+				continue
+			}
+			if action.Timestamp.IsZero() {
+				action.Timestamp = record.Timestamp
+			}
+			b.applyExits(action.Timestamp)
+			b.applyAction(action, record.CycleNumber)
+		}
+	}
+	b.applyExits(time.Time{})
+
+	result := make([]*PositionLifecycle, 0, len(b.all))
+	for _, pos := range b.all {
+		if !pos.dropped {
+			result = append(result, pos)
+		}
+	}
+	return result
+}
+
+// lifecycleBuilder 重建持仓生命周期时的状态：symbol_side -> 未平仓持仓
+type lifecycleBuilder struct {
+	open  map[string]*PositionLifecycle
+	all   []*PositionLifecycle
+	exits []PositionExit
+	next  int
+}
+
+// applyExits 应用不晚于 until 的交易所侧平仓成交（until 为零值时应用全部）
+func (b *lifecycleBuilder) applyExits(until time.Time) {
+	for ; b.next < len(b.exits); b.next++ {
+		exit := b.exits[b.next]
+		if !until.IsZero() && exit.Time.After(until) {
+			return
+		}
+		pos, ok := b.open[exit.Symbol+"_"+exit.Side]
+		if !ok {
+			continue
+		}
+		b.reduce(pos, PositionEvent{
+			Time:    exit.Time,
+			Price:   exit.Price,
+			Cause:   exit.Cause,
+			OrderID: exit.OrderID,
+		}, exit.Quantity)
+	}
+}
+
+// applySnapshot 用持仓快照标记已成交的持仓，并处理从快照中消失的持仓
+func (b *lifecycleBuilder) applySnapshot(positions []PositionSnapshot, at time.Time) {
+	present := make(map[string]PositionSnapshot, len(positions))
+	for _, p := range positions {
+		present[p.Symbol+"_"+p.Side] = p
+	}
+
+	for key, pos := range b.open {
+		if snap, ok := present[key]; ok {
+			pos.seen = true
+			pos.lastMark = snap.MarkPrice
+			if pos.Leverage == 0 {
+				pos.Leverage = int(snap.Leverage)
+			}
+			continue
+		}
+		if !pos.seen {
+			pos.dropped = true
+			delete(b.open, key)
+			continue
+		}
+
+		cause, price := inferExit(pos)
+		b.reduce(pos, PositionEvent{Time: at, Price: price, Cause: cause, Inferred: true}, 0)
+	}
+}
+
+// inferExit 推断持仓在系统外被平仓的原因：止损/止盈价中离最后标记价较近的一个，都未设置时视为人工平仓
+func inferExit(pos *PositionLifecycle) (string, float64) {
+	cause, price := ExitCauseManual, pos.lastMark
+	best := math.Inf(1)
+	for _, level := range []struct {
+		cause string
+		price float64
+	}{{ExitCauseStopLoss, pos.StopLoss}, {ExitCauseTakeProfit, pos.TakeProfit}} {
+		if level.price <= 0 {
+			continue
+		}
+		if dist := math.Abs(pos.lastMark - level.price); dist < best {
+			best = dist
+			cause, price = level.cause, level.price
+		}
+	}
+	return cause, price
+}
+
+// applyAction 应用一个执行成功的决策动作
+func (b *lifecycleBuilder) applyAction(action DecisionAction, cycle int) {
+	event := PositionEvent{
+		Time:        action.Timestamp,
+		CycleNumber: cycle,
+		Price:       action.Price,
+		Reasoning:   action.Reasoning,
+	}
+	if action.OrderID > 0 {
+		event.OrderID = strconv.FormatInt(action.OrderID, 10)
+	}
+
+	switch action.Action {
+	case "open_long", "open_short":
+		side := action.Action[len("open_"):]
+		key := action.Symbol + "_" + side
+		event.Quantity = action.Quantity
+		event.StopLoss = action.StopLoss
+		event.TakeProfit = action.TakeProfit
+
+		pos, ok := b.open[key]
+		if !ok {
+			event.Type = PositionEventOpen
+			pos = &PositionLifecycle{
+				ID:             positionID(action.Symbol, side, action.Timestamp),
+				Symbol:         action.Symbol,
+				Side:           side,
+				Status:         PositionStatusOpen,
+				Leverage:       action.Leverage,
+				OpenTime:       action.Timestamp,
+				OpenCycle:      cycle,
+				EntryReasoning: action.Reasoning,
+			}
+			b.open[key] = pos
+			b.all = append(b.all, pos)
+		} else {
+			event.Type = PositionEventAdd
+		}
+		if total := pos.RemainingQuantity + action.Quantity; total > 0 {
+			pos.EntryPrice = (pos.EntryPrice*pos.RemainingQuantity + action.Price*action.Quantity) / total
+		}
+		pos.Quantity += action.Quantity
+		pos.RemainingQuantity += action.Quantity
+		if action.StopLoss > 0 {
+			pos.StopLoss = action.StopLoss
+		}
+		if action.TakeProfit > 0 {
+			pos.TakeProfit = action.TakeProfit
+		}
+		pos.Events = append(pos.Events, event)
+
+	case "update_stop_loss", "update_take_profit":
+		pos := b.findOpen(action.Symbol)
+		if pos == nil {
+			return
+		}
+		event.Type = action.Action
+		if action.Action == "update_stop_loss" {
+			event.StopLoss = action.StopLoss
+			if action.StopLoss > 0 {
+				pos.StopLoss = action.StopLoss
+			}
+		} else {
+			event.TakeProfit = action.TakeProfit
+			if action.TakeProfit > 0 {
+				pos.TakeProfit = action.TakeProfit
+			}
+		}
+		pos.Events = append(pos.Events, event)
+
+	case "partial_close":
+		if pos := b.findOpen(action.Symbol); pos != nil {
+			event.Cause = ExitCauseAIClose
+			b.reduce(pos, event, action.Quantity)
+		}
+
+	case "close_long", "close_short", "auto_close_long", "auto_close_short":
+		side := "long"
+		if action.Action == "close_short" || action.Action == "auto_close_short" {
+			side = "short"
+		}
+		event.Cause = ExitCauseAIClose
+		if strings.HasPrefix(action.Action, "auto_") {
+			event.Cause = ExitCauseMonitor
+		}
+		if pos, ok := b.open[action.Symbol+"_"+side]; ok {
+			b.reduce(pos, event, 0)
+		}
+	}
+}
+
+// findOpen 查找币种的未平仓持仓（部分平仓、调整止损止盈时决策中没有方向）
+func (b *lifecycleBuilder) findOpen(symbol string) *PositionLifecycle {
+	for _, side := range []string{"long", "short"} {
+		if pos, ok := b.open[symbol+"_"+side]; ok {
+			return pos
+		}
+	}
+	return nil
+}
+
+// reduce 平掉 qty 数量（0 或超过剩余数量时全部平仓），剩余为0时结束生命周期
+func (b *lifecycleBuilder) reduce(pos *PositionLifecycle, event PositionEvent, qty float64) {
+	if qty <= 0 || qty > pos.RemainingQuantity {
+		qty = pos.RemainingQuantity
+	}
+	event.Quantity = qty
+	if pos.Side == "long" {
+		event.PnL = qty * (event.Price - pos.EntryPrice)
+	} else {
+		event.PnL = qty * (pos.EntryPrice - event.Price)
+	}
+	pos.RemainingQuantity -= qty
+	pos.closedQty += qty
+	pos.closedValue += qty * event.Price
+	pos.RealizedPnL += event.PnL
+
+	if pos.RemainingQuantity > pos.Quantity*1e-6 {
+		event.Type = PositionEventPartialClose
+		pos.Events = append(pos.Events, event)
+		return
+	}
+
+	event.Type = PositionEventClose
+	pos.Events = append(pos.Events, event)
+	pos.RemainingQuantity = 0
+	pos.Status = PositionStatusClosed
+	pos.CloseTime = event.Time
+	pos.ExitCause = event.Cause
+	if pos.closedQty > 0 {
+		pos.ExitPrice = pos.closedValue / pos.closedQty
+	}
+	leverage := pos.Leverage
+	if leverage <= 0 {
+		leverage = 1
+	}
+	if margin := pos.EntryPrice * pos.Quantity / float64(leverage); margin > 0 {
+		pos.RealizedPnLPct = pos.RealizedPnL / margin * 100
+	}
+	delete(b.open, pos.Symbol+"_"+pos.Side)
+}
+
+// PositionHistory 持久化的持仓生命周期：已结束且不再修正的持仓，以及仍需回放的记录起点
+type PositionHistory struct {
+	Since     time.Time            `json:"since"`     // 此后的决策记录与账本成交仍需回放（零值表示全部回放）
+	Positions []*PositionLifecycle `json:"positions"` // 已持久化的持仓（按开仓时间排序）
+}
+
+// SettlePositions 合并已持久化的持仓与回放结果
+// 回放结果中结束早于 settleBefore 的持仓不再变化，作为 settled 返回供持久化；
+// 下次回放从仍未定型的持仓中最早的开仓时间（没有时为 settleBefore）开始
+func SettlePositions(history *PositionHistory, replayed []*PositionLifecycle, settleBefore time.Time) (all, settled []*PositionLifecycle, since time.Time) {
+	stored := make(map[string]bool, len(history.Positions))
+	all = append(all, history.Positions...)
+	for _, pos := range history.Positions {
+		stored[pos.ID] = true
+	}
+
+	since = settleBefore
+	for _, pos := range replayed {
+		if stored[pos.ID] {
+			continue
+		}
+		all = append(all, pos)
+		if pos.Status == PositionStatusClosed && pos.CloseTime.Before(settleBefore) {
+			settled = append(settled, pos)
+		} else if pos.OpenTime.Before(since) {
+			since = pos.OpenTime
+		}
+	}
+	if since.Before(history.Since) {
+		since = history.Since
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].OpenTime.Before(all[j].OpenTime) })
+	return all, settled, since
+}
+
+// 文件存储的持仓生命周期（位于交易员的决策日志目录下的子目录，不会被当作记录文件读取）
+const (
+	positionDirName       = "positions"
+	positionFileName      = "lifecycles.jsonl" // 每行一个已结束的持仓（含事件）
+	positionStateFileName = "state.json"       // 回放起点
+)
+
+// positionHistoryState 持仓生命周期的回放起点
+type positionHistoryState struct {
+	Since time.Time `json:"since"`
+}
+
+// LoadPositionHistory 读取 <logDir>/positions 中已持久化的持仓生命周期与回放起点（重复的持仓保留第一条）
+func (l *DecisionLogger) LoadPositionHistory() (*PositionHistory, error) {
+	history := &PositionHistory{Positions: []*PositionLifecycle{}}
+	dir := filepath.Join(l.logDir, positionDirName)
+
+	var state positionHistoryState
+	if data, err := os.ReadFile(filepath.Join(dir, positionStateFileName)); err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("解析持仓回放起点失败: %w", err)
+		}
+		history.Since = state.Since
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取持仓回放起点失败: %w", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, positionFileName))
+	if os.IsNotExist(err) {
+		return history, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("读取持仓生命周期失败: %w", err)
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // 事件较多的持仓单行可能超过默认的 64KB
+	for scanner.Scan() {
+		var pos PositionLifecycle
+		if err := json.Unmarshal(scanner.Bytes(), &pos); err != nil || seen[pos.ID] {
+			continue
+		}
+		seen[pos.ID] = true
+		history.Positions = append(history.Positions, &pos)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取持仓生命周期失败: %w", err)
+	}
+	sort.SliceStable(history.Positions, func(i, j int) bool {
+		return history.Positions[i].OpenTime.Before(history.Positions[j].OpenTime)
+	})
+	return history, nil
+}
+
+// SavePositionHistory 追加已结束的持仓生命周期，并原子替换回放起点
+func (l *DecisionLogger) SavePositionHistory(positions []*PositionLifecycle, since time.Time) error {
+	dir := filepath.Join(l.logDir, positionDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("创建持仓生命周期目录失败: %w", err)
+	}
+
+	if len(positions) > 0 {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		for _, pos := range positions {
+			if err := encoder.Encode(pos); err != nil {
+				return fmt.Errorf("序列化持仓生命周期失败: %w", err)
+			}
+		}
+		f, err := os.OpenFile(filepath.Join(dir, positionFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("写入持仓生命周期失败: %w", err)
+		}
+		if _, err := f.Write(buf.Bytes()); err != nil {
+			f.Close()
+			return fmt.Errorf("写入持仓生命周期失败: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("写入持仓生命周期失败: %w", err)
+		}
+	}
+
+	// 持仓先于回放起点写入：中断时下次回放会再次得到这些持仓，按ID去重
+	data, err := json.Marshal(positionHistoryState{Since: since})
+	if err != nil {
+		return fmt.Errorf("序列化持仓回放起点失败: %w", err)
+	}
+	if err := WriteFileAtomic(filepath.Join(dir, positionStateFileName), data); err != nil {
+		return fmt.Errorf("写入持仓回放起点失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package logger
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestBuildPositionLifecycles 开仓理由、止损调整、部分平仓、交易所止损、快照推断止盈、持仓保护平仓与未成交挂单
+func TestBuildPositionLifecycles(t *testing.T) {
+	l, err := NewSQLiteDecisionLogger(filepath.Join(t.TempDir(), "decisions.db"), "trader_a")
+	if err != nil {
+		t.Fatalf("NewSQLiteDecisionLogger returned error: %v", err)
+	}
+	base := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	var now time.Time
+	l.clock = func() time.Time { return now }
+
+	logCycle := func(hour float64, positions []PositionSnapshot, actions ...DecisionAction) {
+		t.Helper()
+		at := base.Add(time.Duration(hour * float64(time.Hour)))
+		for i := range actions {
+			actions[i].Timestamp = at
+			actions[i].Success = true
+		}
+		record := testRecord(1000, actions...)
+		record.Positions = positions
+		now = at.Add(time.Minute)
+		if err := l.LogDecision(record); err != nil {
+			t.Fatalf("LogDecision returned error: %v", err)
+		}
+	}
+
+	logCycle(0, nil, DecisionAction{Action: "open_long", Symbol: "ETHUSDT", Quantity: 2, Leverage: 5, Price: 100,
+		OrderID: 11, StopLoss: 90, TakeProfit: 130, Reasoning: "breakout"})
+	logCycle(1, []PositionSnapshot{{Symbol: "ETHUSDT", Side: "long", MarkPrice: 110}},
+		DecisionAction{Action: "update_stop_loss", Symbol: "ETHUSDT", Price: 110, StopLoss: 105, Reasoning: "trail"},
+		DecisionAction{Action: "partial_close", Symbol: "ETHUSDT", Quantity: 1, Price: 115})
+	logCycle(2, nil, DecisionAction{Action: "open_short", Symbol: "SOLUSDT", Quantity: 10, Leverage: 2, Price: 20,
+		StopLoss: 22, TakeProfit: 15, Reasoning: "weak"})
+	logCycle(3, []PositionSnapshot{{Symbol: "SOLUSDT", Side: "short", MarkPrice: 16}},
+		DecisionAction{Action: "open_long", Symbol: "BTCUSDT", Quantity: 1, Leverage: 3, Price: 100000})
+	logCycle(4, nil, DecisionAction{Action: "open_long", Symbol: "XRPUSDT", Quantity: 100, Leverage: 2, Price: 1})
+
+	// 持仓保护记录没有账户与持仓快照
+	now = base.Add(4*time.Hour + 30*time.Minute)
+	if err := l.LogDecision(&DecisionRecord{Success: true, Decisions: []DecisionAction{{Action: "auto_close_long",
+		Symbol: "XRPUSDT", Quantity: 100, Price: 0.9, Timestamp: now, Success: true, Reasoning: "[drawdown] 回撤过大"}}}); err != nil {
+		t.Fatalf("LogDecision returned error: %v", err)
+	}
+
+	records, err := CollectRecords(l, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("CollectRecords returned error: %v", err)
+	}
+	exits := []PositionExit{{Symbol: "ETHUSDT", Side: "long", Price: 105, Time: base.Add(90 * time.Minute),
+		Cause: ExitCauseStopLoss, OrderID: "21"}}
+	positions := BuildPositionLifecycles(records, exits)
+	if len(positions) != 3 {
+		t.Fatalf("expected 3 positions (unfilled BTC order dropped), got %d", len(positions))
+	}
+
+	eth := positions[0]
+	if eth.ID != positionID("ETHUSDT", "long", base) || eth.EntryReasoning != "breakout" || eth.OpenCycle != 1 {
+		t.Fatalf("unexpected ETH entry: %+v", eth)
+	}
+	if eth.Status != PositionStatusClosed || eth.ExitCause != ExitCauseStopLoss || eth.StopLoss != 105 {
+		t.Fatalf("unexpected ETH exit: %+v", eth)
+	}
+	if math.Abs(eth.RealizedPnL-20) > 1e-9 || math.Abs(eth.ExitPrice-110) > 1e-9 || math.Abs(eth.RealizedPnLPct-50) > 1e-9 {
+		t.Fatalf("unexpected ETH pnl: pnl=%v exit=%v pct=%v", eth.RealizedPnL, eth.ExitPrice, eth.RealizedPnLPct)
+	}
+	types := []string{PositionEventOpen, PositionEventStopLoss, PositionEventPartialClose, PositionEventClose}
+	if len(eth.Events) != len(types) {
+		t.Fatalf("expected %d ETH events, got %d", len(types), len(eth.Events))
+	}
+	for i, typ := range types {
+		if eth.Events[i].Type != typ {
+			t.Fatalf("event %d: expected %s, got %s", i, typ, eth.Events[i].Type)
+		}
+	}
+	if e := eth.Events[2]; e.Cause != ExitCauseAIClose || e.CycleNumber != 2 || e.PnL != 15 {
+		t.Fatalf("unexpected partial close: %+v", e)
+	}
+	if e := eth.Events[3]; e.OrderID != "21" || e.CycleNumber != 0 || e.Quantity != 1 {
+		t.Fatalf("unexpected stop-loss exit: %+v", e)
+	}
+
+	sol := positions[1]
+	last := sol.Events[len(sol.Events)-1]
+	if sol.ExitCause != ExitCauseTakeProfit || !last.Inferred || last.Price != 15 || sol.RealizedPnL != 50 {
+		t.Fatalf("expected inferred take-profit exit, got %+v", sol)
+	}
+
+	xrp := positions[2]
+	if xrp.ExitCause != ExitCauseMonitor || xrp.Events[1].Reasoning != "[drawdown] 回撤过大" || math.Abs(xrp.RealizedPnL+10) > 1e-9 {
+		t.Fatalf("unexpected monitor exit: %+v", xrp)
+	}
+}
+
+// TestPositionHistoryPersistence 两种存储后端：回放包含已归档的天数，结束的持仓持久化后只回放仍未定型的时间段
+func TestPositionHistoryPersistence(t *testing.T) {
+	dir := t.TempDir()
+	sqliteLogger, err := NewSQLiteDecisionLogger(filepath.Join(dir, "db", "decisions.db"), "trader_a")
+	if err != nil {
+		t.Fatalf("NewSQLiteDecisionLogger returned error: %v", err)
+	}
+	fileLogger := NewDecisionLogger(filepath.Join(dir, "files")).(*DecisionLogger)
+
+	today := time.Date(2025, 3, 20, 0, 0, 0, 0, time.Local)
+	for name, l := range map[string]IDecisionLogger{"file": fileLogger, "sqlite": sqliteLogger} {
+		t.Run(name, func(t *testing.T) {
+			var now time.Time
+			clock := func() time.Time { return now }
+			if fl, ok := l.(*DecisionLogger); ok {
+				fl.clock = clock
+			} else {
+				l.(*SQLiteDecisionLogger).clock = clock
+			}
+
+			logCycle := func(at time.Time, positions []PositionSnapshot, actions ...DecisionAction) {
+				t.Helper()
+				for i := range actions {
+					actions[i].Timestamp = at
+					actions[i].Success = true
+				}
+				record := testRecord(1000, actions...)
+				record.Positions = positions
+				now = at.Add(time.Minute)
+				if err := l.LogDecision(record); err != nil {
+					t.Fatalf("LogDecision returned error: %v", err)
+				}
+			}
+
+			ethOpen := today.AddDate(0, 0, -5).Add(10 * time.Hour)
+			solOpen := today.AddDate(0, 0, -1).Add(10 * time.Hour)
+			logCycle(ethOpen, nil, DecisionAction{Action: "open_long", Symbol: "ETHUSDT", Quantity: 2, Leverage: 5, Price: 100, Reasoning: "breakout"})
+			logCycle(ethOpen.AddDate(0, 0, 1), []PositionSnapshot{{Symbol: "ETHUSDT", Side: "long", MarkPrice: 120}},
+				DecisionAction{Action: "close_long", Symbol: "ETHUSDT", Price: 120})
+			logCycle(solOpen, nil, DecisionAction{Action: "open_short", Symbol: "SOLUSDT", Quantity: 10, Leverage: 2, Price: 20})
+			logCycle(today.Add(9*time.Hour), []PositionSnapshot{{Symbol: "SOLUSDT", Side: "short", MarkPrice: 19}})
+
+			// ETH 的开仓与平仓记录移入压缩归档
+			now = today.Add(10 * time.Hour)
+			if _, err := l.ApplyRetention(RetentionPolicy{ArchiveAfterDays: 3}); err != nil {
+				t.Fatalf("ApplyRetention returned error: %v", err)
+			}
+			records, err := CollectRecordsSince(l, time.Time{})
+			if err != nil {
+				t.Fatalf("CollectRecordsSince returned error: %v", err)
+			}
+			if len(records) != 4 || !records[0].Timestamp.Equal(ethOpen.Add(time.Minute)) {
+				t.Fatalf("expected 4 records including archived days, got %d", len(records))
+			}
+
+			history, err := l.LoadPositionHistory()
+			if err != nil {
+				t.Fatalf("LoadPositionHistory returned error: %v", err)
+			}
+			settleBefore := now.Add(-24 * time.Hour)
+			all, settled, since := SettlePositions(history, BuildPositionLifecycles(records, nil), settleBefore)
+			if len(all) != 2 || len(settled) != 1 || settled[0].Symbol != "ETHUSDT" || !since.Equal(solOpen) {
+				t.Fatalf("unexpected settle result: all=%d settled=%d since=%v", len(all), len(settled), since)
+			}
+			if err := l.SavePositionHistory(settled, since); err != nil {
+				t.Fatalf("SavePositionHistory returned error: %v", err)
+			}
+
+			history, err = l.LoadPositionHistory()
+			if err != nil {
+				t.Fatalf("LoadPositionHistory returned error: %v", err)
+			}
+			if !history.Since.Equal(solOpen) || len(history.Positions) != 1 {
+				t.Fatalf("unexpected stored history: since=%v positions=%d", history.Since, len(history.Positions))
+			}
+			eth := history.Positions[0]
+			if eth.ID != positionID("ETHUSDT", "long", ethOpen) || eth.EntryReasoning != "breakout" || eth.RealizedPnL != 40 ||
+				eth.Status != PositionStatusClosed || len(eth.Events) != 2 || eth.Events[1].Cause != ExitCauseAIClose ||
+				!eth.Events[1].Time.Equal(ethOpen.AddDate(0, 0, 1)) {
+				t.Fatalf("unexpected stored position: %+v", eth)
+			}
+
+			// 之后只回放 SOL 开仓以来的记录，已持久化的持仓不重复
+			records, err = CollectRecordsSince(l, history.Since)
+			if err != nil {
+				t.Fatalf("CollectRecordsSince returned error: %v", err)
+			}
+			all, settled, _ = SettlePositions(history, BuildPositionLifecycles(records, nil), settleBefore)
+			if len(records) != 2 || len(all) != 2 || len(settled) != 0 || all[1].Status != PositionStatusOpen {
+				t.Fatalf("unexpected replay: records=%d all=%d settled=%d", len(records), len(all), len(settled))
+			}
+		})
+	}
+}
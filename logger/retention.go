@@ -0,0 +1,510 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 保留策略相关目录（位于交易员的决策日志目录下，子目录不会被当作记录文件读取）
+const (
+	promptDirName    = "prompts" // 去重存储的系统提示词
+	archiveDirName   = "archive" // 按天压缩归档的记录与降采样净值快照
+	equityFileName   = "equity_snapshots.jsonl"
+	archivePrefix    = "decision_"
+	archiveSuffix    = ".jsonl.gz"
+	archiveDayLayout = "20060102"
+)
+
+// DefaultSnapshotMinutes 记录移出在线存储时保留净值快照的默认间隔（分钟）
+const DefaultSnapshotMinutes = 60
+
+// RetentionPolicy 决策日志保留策略（按交易员存储为JSON，0表示不启用对应步骤）
+// 归档与删除都按自然日执行，被移出在线存储的记录按 SnapshotMinutes 间隔保留净值快照供长周期图表使用
+type RetentionPolicy struct {
+	ArchiveAfterDays int `json:"archive_after_days,omitempty"` // 超过N天的记录按天压缩归档（.jsonl.gz）后从在线存储移除
+	DeleteAfterDays  int `json:"delete_after_days,omitempty"`  // 超过M天的记录与归档文件删除
+	SnapshotMinutes  int `json:"snapshot_minutes,omitempty"`   // 净值快照间隔（分钟），0表示默认60
+}
+
+// ParseRetentionPolicy 解析保留策略JSON，空字符串返回空策略（只做系统提示词去重）
+func ParseRetentionPolicy(raw string) (RetentionPolicy, error) {
+	var policy RetentionPolicy
+	if strings.TrimSpace(raw) == "" {
+		return policy, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &policy); err != nil {
+		return RetentionPolicy{}, fmt.Errorf("解析决策日志保留策略失败: %w", err)
+	}
+	if policy.ArchiveAfterDays < 0 || policy.DeleteAfterDays < 0 || policy.SnapshotMinutes < 0 {
+		return RetentionPolicy{}, fmt.Errorf("保留策略的天数与快照间隔不能为负数")
+	}
+	if policy.ArchiveAfterDays > 0 && policy.DeleteAfterDays > 0 && policy.DeleteAfterDays <= policy.ArchiveAfterDays {
+		return RetentionPolicy{}, fmt.Errorf("删除天数(%d)必须大于归档天数(%d)", policy.DeleteAfterDays, policy.ArchiveAfterDays)
+	}
+	return policy, nil
+}
+
+// Enabled 是否启用归档或删除
+func (p RetentionPolicy) Enabled() bool {
+	return p.ArchiveAfterDays > 0 || p.DeleteAfterDays > 0
+}
+
+// snapshotInterval 净值快照间隔
+func (p RetentionPolicy) snapshotInterval() time.Duration {
+	if p.SnapshotMinutes <= 0 {
+		return DefaultSnapshotMinutes * time.Minute
+	}
+	return time.Duration(p.SnapshotMinutes) * time.Minute
+}
+
+// RetentionResult 一次保留策略执行的结果
+type RetentionResult struct {
+	PromptsDeduplicated int `json:"prompts_deduplicated"` // 改为按哈希存储系统提示词的记录数
+	RecordsArchived     int `json:"records_archived"`     // 压缩归档后移出在线存储的记录数
+	RecordsDeleted      int `json:"records_deleted"`      // 超过删除天数直接删除的记录数
+	ArchivesDeleted     int `json:"archives_deleted"`     // 删除的过期归档文件数
+	SnapshotsKept       int `json:"snapshots_kept"`       // 新保留的净值快照数
+}
+
+// EquitySnapshot 降采样保留的账户净值快照
+type EquitySnapshot struct {
+	Timestamp    time.Time       `json:"timestamp"`
+	CycleNumber  int             `json:"cycle_number"`
+	AccountState AccountSnapshot `json:"account_state"`
+}
+
+// retentionBackend 保留策略在各存储后端上的操作
+type retentionBackend interface {
+	IDecisionLogger
+	dedupSystemPrompts() (int, error)                     // 把内联的系统提示词改为按哈希存储
+	oldestRecordTime() (time.Time, bool, error)           // 在线存储中最早的记录时间
+	removeRecords(records []*DecisionRecord) error        // 从在线存储中移除记录
+	saveEquitySnapshots(snapshots []EquitySnapshot) error // 追加净值快照（重复的快照忽略）
+	archiveDir() string                                   // 归档文件目录
+}
+
+// applyRetention 执行保留策略
+// 早于归档（未启用归档时为删除）截止日的记录逐天处理：先保留降采样的净值快照，
+// 未超过删除天数的写入当天的压缩归档，然后从在线存储移除；最后删除超过删除天数的归档文件
+func applyRetention(b retentionBackend, policy RetentionPolicy, now time.Time) (*RetentionResult, error) {
+	result := &RetentionResult{}
+	n, err := b.dedupSystemPrompts()
+	if err != nil {
+		return nil, err
+	}
+	result.PromptsDeduplicated = n
+	if !policy.Enabled() {
+		return result, nil
+	}
+
+	var archiveCutoff, deleteCutoff time.Time
+	if policy.ArchiveAfterDays > 0 {
+		archiveCutoff = startOfDay(now.AddDate(0, 0, -policy.ArchiveAfterDays))
+	}
+	if policy.DeleteAfterDays > 0 {
+		deleteCutoff = startOfDay(now.AddDate(0, 0, -policy.DeleteAfterDays))
+	}
+	expireBefore := archiveCutoff
+	if expireBefore.IsZero() {
+		expireBefore = deleteCutoff
+	}
+
+	oldest, ok, err := b.oldestRecordTime()
+	if err != nil {
+		return nil, err
+	}
+	for day := startOfDay(oldest); ok && day.Before(expireBefore); day = day.AddDate(0, 0, 1) {
+		records, err := CollectRecords(b, day, day.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			continue
+		}
+
+		snapshots := downsampleEquity(records, policy.snapshotInterval())
+		if err := b.saveEquitySnapshots(snapshots); err != nil {
+			return nil, err
+		}
+		result.SnapshotsKept += len(snapshots)
+
+		if !archiveCutoff.IsZero() && (deleteCutoff.IsZero() || !day.Before(deleteCutoff)) {
+			if err := writeDailyArchive(b.archiveDir(), day, records); err != nil {
+				return nil, err
+			}
+			result.RecordsArchived += len(records)
+		} else {
+			result.RecordsDeleted += len(records)
+		}
+		if err := b.removeRecords(records); err != nil {
+			return nil, err
+		}
+	}
+
+	if !deleteCutoff.IsZero() {
+		if result.ArchivesDeleted, err = pruneArchives(b.archiveDir(), deleteCutoff); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// startOfDay 本地时区的当天零点
+func startOfDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// downsampleEquity 每个时间间隔保留最后一条记录的账户快照（records 按时间正序）
+func downsampleEquity(records []*DecisionRecord, interval time.Duration) []EquitySnapshot {
+	var snapshots []EquitySnapshot
+	var lastBucket time.Time
+	for _, record := range records {
+		snapshot := EquitySnapshot{Timestamp: record.Timestamp, CycleNumber: record.CycleNumber, AccountState: record.AccountState}
+		bucket := record.Timestamp.Truncate(interval)
+		if len(snapshots) > 0 && bucket.Equal(lastBucket) {
+			snapshots[len(snapshots)-1] = snapshot
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+		lastBucket = bucket
+	}
+	return snapshots
+}
+
+// archiveFileName 某天的归档文件名：decision_YYYYMMDD.jsonl.gz
+func archiveFileName(day time.Time) string {
+	return archivePrefix + day.Format(archiveDayLayout) + archiveSuffix
+}
+
+// writeDailyArchive 把一天的记录追加到当天的压缩归档（每行一条JSON记录）
+// 同一归档文件内相同的系统提示词只保存一次，之后的记录只保留哈希；多次追加形成多段 gzip，读取时自动拼接
+func writeDailyArchive(dir string, day time.Time, records []*DecisionRecord) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	path := filepath.Join(dir, archiveFileName(day))
+	seen := make(map[string]bool)
+	if existing, err := ReadArchive(path); err == nil {
+		for _, record := range existing {
+			seen[record.SystemPromptHash] = true
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("打开归档文件失败: %w", err)
+	}
+	gz := gzip.NewWriter(f)
+	encoder := json.NewEncoder(gz)
+	for _, record := range records {
+		archived := *record
+		if archived.SystemPrompt != "" {
+			archived.SystemPromptHash = systemPromptHash(archived.SystemPrompt)
+			if seen[archived.SystemPromptHash] {
+				archived.SystemPrompt = ""
+			}
+			seen[archived.SystemPromptHash] = true
+		}
+		if err := encoder.Encode(&archived); err != nil {
+			gz.Close()
+			f.Close()
+			return fmt.Errorf("写入归档失败: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return fmt.Errorf("写入归档失败: %w", err)
+	}
+	return f.Close()
+}
+
+// ReadArchive 读取一个压缩归档文件中的全部记录（按哈希还原系统提示词）
+func ReadArchive(path string) ([]*DecisionRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("读取归档失败: %w", err)
+	}
+	defer gz.Close()
+
+	prompts := make(map[string]string)
+	var records []*DecisionRecord
+	decoder := json.NewDecoder(gz)
+	for {
+		var record DecisionRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("解析归档失败: %w", err)
+		}
+		if record.SystemPrompt != "" {
+			prompts[record.SystemPromptHash] = record.SystemPrompt
+		} else if record.SystemPromptHash != "" {
+			record.SystemPrompt = prompts[record.SystemPromptHash]
+		}
+		records = append(records, &record)
+	}
+	return records, nil
+}
+
+// pruneArchives 删除早于 cutoff 的归档文件，返回删除数量
+func pruneArchives(dir string, cutoff time.Time) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("读取归档目录失败: %w", err)
+	}
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, archiveSuffix) {
+			continue
+		}
+		day, err := time.ParseInLocation(archiveDayLayout, strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), archiveSuffix), time.Local)
+		if err != nil || !day.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return removed, fmt.Errorf("删除过期归档失败: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// CollectRecordsSince 读取 start 之后的全部记录（零值表示不限制），包括已移入压缩归档的天数（按时间正序）
+func CollectRecordsSince(l IDecisionLogger, start time.Time) ([]*DecisionRecord, error) {
+	records, err := CollectRecords(l, start, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	b, ok := l.(retentionBackend)
+	if !ok {
+		return records, nil
+	}
+	archived, err := readArchivesSince(b.archiveDir(), start)
+	if err != nil || len(archived) == 0 {
+		return records, err
+	}
+
+	// 归档写入后、移出在线存储前中断时两边可能都有同一条记录
+	online := make(map[string]bool, len(records))
+	for _, record := range records {
+		online[fmt.Sprintf("%d_%d", record.Timestamp.UnixMilli(), record.CycleNumber)] = true
+	}
+	var merged []*DecisionRecord
+	for _, record := range archived {
+		if record.Timestamp.Before(start) || online[fmt.Sprintf("%d_%d", record.Timestamp.UnixMilli(), record.CycleNumber)] {
+			continue
+		}
+		merged = append(merged, record)
+	}
+	merged = append(merged, records...)
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Timestamp.Before(merged[j].Timestamp) })
+	return merged, nil
+}
+
+// readArchivesSince 读取 start 当天及之后的归档文件中的记录（按日期顺序）
+func readArchivesSince(dir string, start time.Time) ([]*DecisionRecord, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("读取归档目录失败: %w", err)
+	}
+	var records []*DecisionRecord
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, archiveSuffix) {
+			continue
+		}
+		day, err := time.ParseInLocation(archiveDayLayout, strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), archiveSuffix), time.Local)
+		if err != nil || (!start.IsZero() && day.Before(startOfDay(start))) {
+			continue
+		}
+		archived, err := ReadArchive(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, archived...)
+	}
+	return records, nil
+}
+
+// GetEquityHistory 净值曲线数据：已移出在线存储的降采样快照 + 在线存储中最近 n 条记录的账户快照（按时间正序）
+func GetEquityHistory(l IDecisionLogger, n int) ([]EquitySnapshot, error) {
+	records, err := l.GetLatestRecords(n)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := l.GetEquitySnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	var history []EquitySnapshot
+	for _, snapshot := range snapshots {
+		if len(records) > 0 && !snapshot.Timestamp.Before(records[0].Timestamp) {
+			break
+		}
+		history = append(history, snapshot)
+	}
+	for _, record := range records {
+		history = append(history, EquitySnapshot{Timestamp: record.Timestamp, CycleNumber: record.CycleNumber, AccountState: record.AccountState})
+	}
+	return history, nil
+}
+
+// ApplyRetention 执行保留策略（归档保存在 <logDir>/archive）
+func (l *DecisionLogger) ApplyRetention(policy RetentionPolicy) (*RetentionResult, error) {
+	return applyRetention(l, policy, l.clock())
+}
+
+// GetEquitySnapshots 获取降采样净值快照（按时间正序，去除重复）
+func (l *DecisionLogger) GetEquitySnapshots() ([]EquitySnapshot, error) {
+	f, err := os.Open(filepath.Join(l.archiveDir(), equityFileName))
+	if os.IsNotExist(err) {
+		return []EquitySnapshot{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("读取净值快照失败: %w", err)
+	}
+	defer f.Close()
+
+	var snapshots []EquitySnapshot
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var snapshot EquitySnapshot
+		if err := json.Unmarshal(scanner.Bytes(), &snapshot); err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取净值快照失败: %w", err)
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Timestamp.Before(snapshots[j].Timestamp)
+	})
+	result := []EquitySnapshot{}
+	for _, snapshot := range snapshots {
+		if n := len(result); n > 0 && result[n-1].Timestamp.Equal(snapshot.Timestamp) && result[n-1].CycleNumber == snapshot.CycleNumber {
+			continue
+		}
+		result = append(result, snapshot)
+	}
+	return result, nil
+}
+
+// dedupSystemPrompts 把旧文件中内联的系统提示词改为按哈希存储（保留文件修改时间）
+func (l *DecisionLogger) dedupSystemPrompts() (int, error) {
+	files, err := filepath.Glob(filepath.Join(l.logDir, "decision_*.json"))
+	if err != nil {
+		return 0, fmt.Errorf("查找日志文件失败: %w", err)
+	}
+	deduped := 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var record DecisionRecord
+		if err := json.Unmarshal(data, &record); err != nil || record.SystemPrompt == "" {
+			continue
+		}
+		if record.SystemPromptHash, err = l.prompts.save(record.SystemPrompt); err != nil {
+			return deduped, err
+		}
+		record.SystemPrompt = ""
+		data, err = json.MarshalIndent(&record, "", "  ")
+		if err != nil {
+			return deduped, fmt.Errorf("序列化决策记录失败: %w", err)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if err := WriteFileAtomic(path, data); err != nil {
+			return deduped, fmt.Errorf("写入决策记录失败: %w", err)
+		}
+		os.Chtimes(path, info.ModTime(), info.ModTime())
+		deduped++
+	}
+	return deduped, nil
+}
+
+// oldestRecordTime 最早的记录时间（从文件名解析）
+func (l *DecisionLogger) oldestRecordTime() (time.Time, bool, error) {
+	files, err := filepath.Glob(filepath.Join(l.logDir, "decision_*_cycle*.json"))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("查找日志文件失败: %w", err)
+	}
+	var oldest time.Time
+	found := false
+	for _, file := range files {
+		stamp := strings.TrimPrefix(filepath.Base(file), "decision_")
+		if len(stamp) < len("20060102_150405") {
+			continue
+		}
+		t, err := time.ParseInLocation("20060102_150405", stamp[:len("20060102_150405")], time.Local)
+		if err != nil {
+			continue
+		}
+		if !found || t.Before(oldest) {
+			oldest, found = t, true
+		}
+	}
+	return oldest, found, nil
+}
+
+// removeRecords 删除记录文件
+func (l *DecisionLogger) removeRecords(records []*DecisionRecord) error {
+	for _, record := range records {
+		if err := os.Remove(filepath.Join(l.logDir, recordFileName(record))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除决策记录失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// saveEquitySnapshots 追加净值快照到 archive/equity_snapshots.jsonl
+func (l *DecisionLogger) saveEquitySnapshots(snapshots []EquitySnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(l.archiveDir(), 0700); err != nil {
+		return fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(l.archiveDir(), equityFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("写入净值快照失败: %w", err)
+	}
+	encoder := json.NewEncoder(f)
+	for _, snapshot := range snapshots {
+		if err := encoder.Encode(snapshot); err != nil {
+			f.Close()
+			return fmt.Errorf("写入净值快照失败: %w", err)
+		}
+	}
+	return f.Close()
+}
+
+// archiveDir 归档目录
+func (l *DecisionLogger) archiveDir() string {
+	return filepath.Join(l.logDir, archiveDirName)
+}
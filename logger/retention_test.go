@@ -0,0 +1,178 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestApplyRetention 两种存储后端的提示词去重、按天归档、过期删除与净值快照降采样
+func TestApplyRetention(t *testing.T) {
+	dir := t.TempDir()
+	sqliteLogger, err := NewSQLiteDecisionLogger(filepath.Join(dir, "db", "decisions.db"), "trader_a")
+	if err != nil {
+		t.Fatalf("NewSQLiteDecisionLogger returned error: %v", err)
+	}
+	fileLogger := NewDecisionLogger(filepath.Join(dir, "files")).(*DecisionLogger)
+
+	today := time.Date(2025, 3, 20, 0, 0, 0, 0, time.Local)
+	for name, l := range map[string]IDecisionLogger{"file": fileLogger, "sqlite": sqliteLogger} {
+		t.Run(name, func(t *testing.T) {
+			var now time.Time
+			clock := func() time.Time { return now }
+			var archiveDir string
+			if fl, ok := l.(*DecisionLogger); ok {
+				fl.clock = clock
+				archiveDir = fl.archiveDir()
+			} else {
+				sl := l.(*SQLiteDecisionLogger)
+				sl.clock = clock
+				archiveDir = sl.archiveDir()
+			}
+
+			// 10天，每天4个周期：00:00 与 00:20 落在同一个小时快照区间
+			for d := -9; d <= 0; d++ {
+				for _, offset := range []time.Duration{0, 20 * time.Minute, 6 * time.Hour, 12 * time.Hour} {
+					now = today.AddDate(0, 0, d).Add(offset)
+					record := testRecord(1000 + float64(d))
+					record.SystemPrompt = "system prompt"
+					if err := l.LogDecision(record); err != nil {
+						t.Fatalf("LogDecision returned error: %v", err)
+					}
+				}
+			}
+
+			now = today.Add(13 * time.Hour)
+			policy := RetentionPolicy{ArchiveAfterDays: 3, DeleteAfterDays: 7}
+			result, err := l.ApplyRetention(policy)
+			if err != nil {
+				t.Fatalf("ApplyRetention returned error: %v", err)
+			}
+			if result.RecordsDeleted != 8 || result.RecordsArchived != 16 || result.SnapshotsKept != 18 {
+				t.Fatalf("unexpected retention result: %+v", result)
+			}
+
+			records, err := l.GetLatestRecords(100)
+			if err != nil {
+				t.Fatalf("GetLatestRecords returned error: %v", err)
+			}
+			if len(records) != 16 || !records[0].Timestamp.Equal(today.AddDate(0, 0, -3)) {
+				t.Fatalf("expected 16 live records starting 3 days ago, got %d", len(records))
+			}
+			if records[0].SystemPrompt != "system prompt" {
+				t.Fatalf("expected system prompt restored from hash, got %q", records[0].SystemPrompt)
+			}
+
+			archived, err := ReadArchive(filepath.Join(archiveDir, archiveFileName(today.AddDate(0, 0, -4))))
+			if err != nil {
+				t.Fatalf("ReadArchive returned error: %v", err)
+			}
+			if len(archived) != 4 || archived[3].SystemPrompt != "system prompt" {
+				t.Fatalf("unexpected archive contents: %d records", len(archived))
+			}
+			if _, err := os.Stat(filepath.Join(archiveDir, archiveFileName(today.AddDate(0, 0, -8)))); !os.IsNotExist(err) {
+				t.Fatalf("records older than delete cutoff must not be archived")
+			}
+
+			history, err := GetEquityHistory(l, 100)
+			if err != nil {
+				t.Fatalf("GetEquityHistory returned error: %v", err)
+			}
+			if len(history) != 18+16 || history[0].AccountState.TotalBalance != 991 {
+				t.Fatalf("unexpected equity history: %d points", len(history))
+			}
+			for i := 1; i < len(history); i++ {
+				if history[i].Timestamp.Before(history[i-1].Timestamp) {
+					t.Fatalf("equity history out of order at %d", i)
+				}
+			}
+
+			// 两天后：再归档2天，删除超过7天的归档文件
+			now = now.AddDate(0, 0, 2)
+			result, err = l.ApplyRetention(policy)
+			if err != nil {
+				t.Fatalf("ApplyRetention returned error: %v", err)
+			}
+			if result.RecordsArchived != 8 || result.ArchivesDeleted != 2 || result.RecordsDeleted != 0 {
+				t.Fatalf("unexpected retention result: %+v", result)
+			}
+			archives, _ := filepath.Glob(filepath.Join(archiveDir, "decision_*.jsonl.gz"))
+			if len(archives) != 4 {
+				t.Fatalf("expected 4 archives, got %d", len(archives))
+			}
+		})
+	}
+}
+
+// TestDecisionLoggerPromptDedup 文件存储中内联的系统提示词由保留任务改为按哈希存储，读取时还原
+func TestDecisionLoggerPromptDedup(t *testing.T) {
+	dir := t.TempDir()
+	l := NewDecisionLogger(dir).(*DecisionLogger)
+
+	var records []*DecisionRecord
+	for _, prompt := range []string{"prompt a", "prompt a", "prompt b"} {
+		record := testRecord(1000)
+		record.SystemPrompt = prompt
+		if err := l.LogDecision(record); err != nil {
+			t.Fatalf("LogDecision returned error: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	result, err := l.ApplyRetention(RetentionPolicy{})
+	if err != nil {
+		t.Fatalf("ApplyRetention returned error: %v", err)
+	}
+	if result.PromptsDeduplicated != 3 {
+		t.Fatalf("expected 3 records deduplicated, got %d", result.PromptsDeduplicated)
+	}
+	prompts, _ := filepath.Glob(filepath.Join(dir, promptDirName, "*.txt"))
+	if len(prompts) != 2 {
+		t.Fatalf("expected 2 stored prompts, got %d", len(prompts))
+	}
+	data, err := os.ReadFile(filepath.Join(dir, recordFileName(records[0])))
+	if err != nil {
+		t.Fatalf("failed to read record file: %v", err)
+	}
+	if strings.Contains(string(data), "prompt a") {
+		t.Fatalf("record file should not contain the inline prompt")
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(tmps) != 0 {
+		t.Fatalf("temporary files left behind: %v", tmps)
+	}
+
+	// 新的记录器实例（没有提示词缓存）按哈希从磁盘还原
+	loaded, err := NewDecisionLogger(dir).GetLatestRecords(10)
+	if err != nil {
+		t.Fatalf("GetLatestRecords returned error: %v", err)
+	}
+	if len(loaded) != 3 || loaded[0].SystemPrompt != "prompt a" || loaded[2].SystemPrompt != "prompt b" {
+		t.Fatalf("prompts not restored: %+v", loaded)
+	}
+
+	if result, _ := l.ApplyRetention(RetentionPolicy{}); result.PromptsDeduplicated != 0 {
+		t.Fatalf("expected no further deduplication, got %d", result.PromptsDeduplicated)
+	}
+}
+
+// TestParseRetentionPolicy 保留策略校验
+func TestParseRetentionPolicy(t *testing.T) {
+	if policy, err := ParseRetentionPolicy(""); err != nil || policy.Enabled() {
+		t.Fatalf("empty policy should be valid and disabled: %+v %v", policy, err)
+	}
+	if _, err := ParseRetentionPolicy(`{"archive_after_days":7,"delete_after_days":30,"snapshot_minutes":15}`); err != nil {
+		t.Fatalf("valid policy returned error: %v", err)
+	}
+	for _, raw := range []string{
+		`{"archive_after_days":-1}`,
+		`{"archive_after_days":30,"delete_after_days":7}`,
+		`{"archive_after_days":7,"delete_after_days":7}`,
+		`not json`,
+	} {
+		if _, err := ParseRetentionPolicy(raw); err == nil {
+			t.Fatalf("expected error for %s", raw)
+		}
+	}
+}
@@ -0,0 +1,878 @@
+package logger
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// 决策日志存储后端
+const (
+	DecisionLogBackendFile   = "file"   // 每个周期一个JSON文件（默认）
+	DecisionLogBackendSQLite = "sqlite" // 所有交易员共用一个SQLite库
+)
+
+// DefaultDecisionLogDBPath SQLite决策日志库的默认路径
+const DefaultDecisionLogDBPath = "decision_logs/decisions.db"
+
+// decisionLogSchema 决策记录、执行动作、账户快照、持仓快照与持仓生命周期表
+var decisionLogSchema = []string{
+	`CREATE TABLE IF NOT EXISTS decision_records (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		trader_id TEXT NOT NULL,
+		cycle_number INTEGER NOT NULL,
+		timestamp INTEGER NOT NULL,
+		success BOOLEAN NOT NULL DEFAULT 0,
+		error_message TEXT NOT NULL DEFAULT '',
+		system_prompt TEXT NOT NULL DEFAULT '',
+		input_prompt TEXT NOT NULL DEFAULT '',
+		cot_trace TEXT NOT NULL DEFAULT '',
+		decision_json TEXT NOT NULL DEFAULT '',
+		candidate_coins TEXT NOT NULL DEFAULT '[]',
+		execution_log TEXT NOT NULL DEFAULT '[]',
+		ai_request_duration_ms INTEGER NOT NULL DEFAULT 0,
+		risk_reason TEXT NOT NULL DEFAULT '',
+		trigger_type TEXT NOT NULL DEFAULT '',
+		trigger_reason TEXT NOT NULL DEFAULT '',
+		ensemble_policy TEXT NOT NULL DEFAULT '',
+		model_responses TEXT NOT NULL DEFAULT '',
+		ai_provider TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_decision_records_trader_time ON decision_records(trader_id, timestamp, cycle_number)`,
+	`CREATE INDEX IF NOT EXISTS idx_decision_records_trader_cycle ON decision_records(trader_id, cycle_number)`,
+	`CREATE TABLE IF NOT EXISTS decision_actions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		record_id INTEGER NOT NULL REFERENCES decision_records(id) ON DELETE CASCADE,
+		trader_id TEXT NOT NULL,
+		action TEXT NOT NULL,
+		symbol TEXT NOT NULL,
+		quantity REAL NOT NULL DEFAULT 0,
+		leverage INTEGER NOT NULL DEFAULT 0,
+		price REAL NOT NULL DEFAULT 0,
+		order_id INTEGER NOT NULL DEFAULT 0,
+		timestamp INTEGER NOT NULL,
+		success BOOLEAN NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_decision_actions_record ON decision_actions(record_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_decision_actions_trader_symbol_time ON decision_actions(trader_id, symbol, timestamp)`,
+	`CREATE TABLE IF NOT EXISTS account_snapshots (
+		record_id INTEGER PRIMARY KEY REFERENCES decision_records(id) ON DELETE CASCADE,
+		trader_id TEXT NOT NULL,
+		timestamp INTEGER NOT NULL,
+		total_balance REAL NOT NULL DEFAULT 0,
+		available_balance REAL NOT NULL DEFAULT 0,
+		total_unrealized_profit REAL NOT NULL DEFAULT 0,
+		position_count INTEGER NOT NULL DEFAULT 0,
+		margin_used_pct REAL NOT NULL DEFAULT 0,
+		initial_balance REAL NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_account_snapshots_trader_time ON account_snapshots(trader_id, timestamp)`,
+	`CREATE TABLE IF NOT EXISTS position_snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		record_id INTEGER NOT NULL REFERENCES decision_records(id) ON DELETE CASCADE,
+		trader_id TEXT NOT NULL,
+		timestamp INTEGER NOT NULL,
+		symbol TEXT NOT NULL,
+		side TEXT NOT NULL,
+		position_amt REAL NOT NULL DEFAULT 0,
+		entry_price REAL NOT NULL DEFAULT 0,
+		mark_price REAL NOT NULL DEFAULT 0,
+		unrealized_profit REAL NOT NULL DEFAULT 0,
+		leverage REAL NOT NULL DEFAULT 0,
+		liquidation_price REAL NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_position_snapshots_record ON position_snapshots(record_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_position_snapshots_trader_symbol_time ON position_snapshots(trader_id, symbol, timestamp)`,
+	`CREATE TABLE IF NOT EXISTS system_prompts (
+		hash TEXT PRIMARY KEY,
+		prompt TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS equity_snapshots (
+		trader_id TEXT NOT NULL,
+		timestamp INTEGER NOT NULL,
+		cycle_number INTEGER NOT NULL,
+		total_balance REAL NOT NULL DEFAULT 0,
+		available_balance REAL NOT NULL DEFAULT 0,
+		total_unrealized_profit REAL NOT NULL DEFAULT 0,
+		position_count INTEGER NOT NULL DEFAULT 0,
+		margin_used_pct REAL NOT NULL DEFAULT 0,
+		initial_balance REAL NOT NULL DEFAULT 0,
+		PRIMARY KEY (trader_id, timestamp, cycle_number)
+	)`,
+	`CREATE TABLE IF NOT EXISTS position_lifecycles (
+		trader_id TEXT NOT NULL,
+		id TEXT NOT NULL,
+		symbol TEXT NOT NULL,
+		side TEXT NOT NULL,
+		status TEXT NOT NULL,
+		leverage INTEGER NOT NULL DEFAULT 0,
+		entry_price REAL NOT NULL DEFAULT 0,
+		quantity REAL NOT NULL DEFAULT 0,
+		stop_loss REAL NOT NULL DEFAULT 0,
+		take_profit REAL NOT NULL DEFAULT 0,
+		open_time INTEGER NOT NULL,
+		open_cycle INTEGER NOT NULL DEFAULT 0,
+		entry_reasoning TEXT NOT NULL DEFAULT '',
+		close_time INTEGER NOT NULL DEFAULT 0,
+		exit_price REAL NOT NULL DEFAULT 0,
+		exit_cause TEXT NOT NULL DEFAULT '',
+		realized_pnl REAL NOT NULL DEFAULT 0,
+		realized_pnl_pct REAL NOT NULL DEFAULT 0,
+		PRIMARY KEY (trader_id, id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS position_events (
+		trader_id TEXT NOT NULL,
+		position_id TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		time INTEGER NOT NULL,
+		cycle_number INTEGER NOT NULL DEFAULT 0,
+		price REAL NOT NULL DEFAULT 0,
+		quantity REAL NOT NULL DEFAULT 0,
+		stop_loss REAL NOT NULL DEFAULT 0,
+		take_profit REAL NOT NULL DEFAULT 0,
+		pnl REAL NOT NULL DEFAULT 0,
+		cause TEXT NOT NULL DEFAULT '',
+		order_id TEXT NOT NULL DEFAULT '',
+		reasoning TEXT NOT NULL DEFAULT '',
+		inferred BOOLEAN NOT NULL DEFAULT 0,
+		PRIMARY KEY (trader_id, position_id, seq),
+		FOREIGN KEY (trader_id, position_id) REFERENCES position_lifecycles(trader_id, id) ON DELETE CASCADE
+	)`,
+	`CREATE TABLE IF NOT EXISTS position_history_state (
+		trader_id TEXT PRIMARY KEY,
+		since INTEGER NOT NULL
+	)`,
+}
+
+// decisionLogMigrations 为已有的决策日志库补充新列（列已存在时报错，忽略即可）
+var decisionLogMigrations = []string{
+	`ALTER TABLE decision_records ADD COLUMN system_prompt_hash TEXT NOT NULL DEFAULT ''`, // 按哈希去重存储的系统提示词
+	`ALTER TABLE decision_actions ADD COLUMN stop_loss REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE decision_actions ADD COLUMN take_profit REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE decision_actions ADD COLUMN reasoning TEXT NOT NULL DEFAULT ''`,
+}
+
+var (
+	decisionDBs   = make(map[string]*sql.DB)
+	decisionDBsMu sync.Mutex
+)
+
+// openDecisionDB 打开（不存在时创建）SQLite决策日志库，同一路径在进程内共用一个连接池
+func openDecisionDB(path string) (*sql.DB, error) {
+	decisionDBsMu.Lock()
+	defer decisionDBsMu.Unlock()
+	if db, ok := decisionDBs[path]; ok {
+		return db, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("创建决策日志库目录失败: %w", err)
+	}
+	// 连接参数对连接池中的每个连接生效：WAL 模式下读写互不阻塞；busy_timeout 避免多个交易员并发写入时立即返回 SQLITE_BUSY；
+	// foreign_keys 使清理记录时级联删除动作与快照
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, fmt.Errorf("打开决策日志库失败: %w", err)
+	}
+	for _, stmt := range decisionLogSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("创建决策日志表失败: %w", err)
+		}
+	}
+	for _, stmt := range decisionLogMigrations {
+		db.Exec(stmt)
+	}
+	decisionDBs[path] = db
+	return db, nil
+}
+
+// SQLiteDecisionLogger SQLite决策日志记录器（按交易员ID区分，多个交易员共用一个库）
+type SQLiteDecisionLogger struct {
+	db          *sql.DB
+	dbPath      string
+	traderID    string
+	cycleNumber int
+	clock       func() time.Time // 时间来源
+	mu          sync.Mutex       // 保护周期编号（交易周期与持仓保护监控可能并发写入）
+}
+
+// NewSQLiteDecisionLogger 创建SQLite决策日志记录器，周期编号从库中该交易员的最大编号继续
+func NewSQLiteDecisionLogger(dbPath, traderID string) (*SQLiteDecisionLogger, error) {
+	if dbPath == "" {
+		dbPath = DefaultDecisionLogDBPath
+	}
+	db, err := openDecisionDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastCycle sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(cycle_number) FROM decision_records WHERE trader_id = ?`, traderID).Scan(&lastCycle); err != nil {
+		return nil, fmt.Errorf("读取最近周期编号失败: %w", err)
+	}
+	return &SQLiteDecisionLogger{
+		db:          db,
+		dbPath:      dbPath,
+		traderID:    traderID,
+		cycleNumber: int(lastCycle.Int64),
+		clock:       time.Now,
+	}, nil
+}
+
+// ListSQLiteDecisionTraders 返回SQLite决策日志库中有记录的交易员ID（按ID排序）
+func ListSQLiteDecisionTraders(dbPath string) ([]string, error) {
+	if dbPath == "" {
+		dbPath = DefaultDecisionLogDBPath
+	}
+	db, err := openDecisionDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT DISTINCT trader_id FROM decision_records ORDER BY trader_id`)
+	if err != nil {
+		return nil, fmt.Errorf("查询交易员列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	var traders []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("读取交易员ID失败: %w", err)
+		}
+		traders = append(traders, id)
+	}
+	return traders, rows.Err()
+}
+
+// NewDecisionLoggerForBackend 按存储后端创建交易员的决策日志记录器
+func NewDecisionLoggerForBackend(backend, dbPath, traderID string) (IDecisionLogger, error) {
+	switch backend {
+	case "", DecisionLogBackendFile:
+		return NewDecisionLogger(fmt.Sprintf("decision_logs/%s", traderID)), nil
+	case DecisionLogBackendSQLite:
+		return NewSQLiteDecisionLogger(dbPath, traderID)
+	default:
+		return nil, fmt.Errorf("不支持的决策日志存储后端: %s", backend)
+	}
+}
+
+// LogDecision 记录决策（分配周期编号与时间戳）
+func (l *SQLiteDecisionLogger) LogDecision(record *DecisionRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record.CycleNumber = l.cycleNumber + 1
+	record.Timestamp = l.clock()
+	if _, err := l.insert(record); err != nil {
+		return err
+	}
+	l.cycleNumber = record.CycleNumber
+	return nil
+}
+
+// ImportRecord 按原周期编号与时间戳导入记录（用于迁移JSON文件），已存在的记录返回 false
+func (l *SQLiteDecisionLogger) ImportRecord(record *DecisionRecord) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inserted, err := l.insert(record)
+	if err != nil {
+		return false, err
+	}
+	l.cycleNumber = max(l.cycleNumber, record.CycleNumber)
+	return inserted, nil
+}
+
+// insert 在一个事务中写入记录及其动作、账户快照与持仓快照（相同交易员+时间+周期的记录跳过）
+func (l *SQLiteDecisionLogger) insert(record *DecisionRecord) (bool, error) {
+	candidateCoins, _ := json.Marshal(nonNilStrings(record.CandidateCoins))
+	executionLog, _ := json.Marshal(nonNilStrings(record.ExecutionLog))
+	modelResponses := ""
+	if len(record.ModelResponses) > 0 {
+		data, _ := json.Marshal(record.ModelResponses)
+		modelResponses = string(data)
+	}
+	ts := record.Timestamp.UnixMilli()
+
+	tx, err := l.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("开启决策记录事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 系统提示词按哈希去重存储
+	if record.SystemPrompt != "" {
+		record.SystemPromptHash = systemPromptHash(record.SystemPrompt)
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO system_prompts (hash, prompt) VALUES (?, ?)`, record.SystemPromptHash, record.SystemPrompt); err != nil {
+			return false, fmt.Errorf("写入系统提示词失败: %w", err)
+		}
+	}
+
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO decision_records (trader_id, cycle_number, timestamp, success, error_message,
+			system_prompt_hash, input_prompt, cot_trace, decision_json, candidate_coins, execution_log,
+			ai_request_duration_ms, risk_reason, trigger_type, trigger_reason, ensemble_policy, model_responses, ai_provider)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, l.traderID, record.CycleNumber, ts, record.Success, record.ErrorMessage,
+		record.SystemPromptHash, record.InputPrompt, record.CoTTrace, record.DecisionJSON, string(candidateCoins), string(executionLog),
+		record.AIRequestDurationMs, record.RiskReason, record.Trigger, record.TriggerReason, record.EnsemblePolicy, modelResponses, record.AIProvider)
+	if err != nil {
+		return false, fmt.Errorf("写入决策记录失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	recordID, err := result.LastInsertId()
+	if err != nil {
+		return false, fmt.Errorf("获取决策记录ID失败: %w", err)
+	}
+
+	for _, a := range record.Decisions {
+		_, err := tx.Exec(`
+			INSERT INTO decision_actions (record_id, trader_id, action, symbol, quantity, leverage, price, order_id, timestamp, success, error, stop_loss, take_profit, reasoning)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, recordID, l.traderID, a.Action, a.Symbol, a.Quantity, a.Leverage, a.Price, a.OrderID, a.Timestamp.UnixMilli(), a.Success, a.Error, a.StopLoss, a.TakeProfit, a.Reasoning)
+		if err != nil {
+			return false, fmt.Errorf("写入决策动作失败: %w", err)
+		}
+	}
+
+	s := record.AccountState
+	_, err = tx.Exec(`
+		INSERT INTO account_snapshots (record_id, trader_id, timestamp, total_balance, available_balance,
+			total_unrealized_profit, position_count, margin_used_pct, initial_balance)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, recordID, l.traderID, ts, s.TotalBalance, s.AvailableBalance, s.TotalUnrealizedProfit, s.PositionCount, s.MarginUsedPct, s.InitialBalance)
+	if err != nil {
+		return false, fmt.Errorf("写入账户快照失败: %w", err)
+	}
+
+	for _, p := range record.Positions {
+		_, err := tx.Exec(`
+			INSERT INTO position_snapshots (record_id, trader_id, timestamp, symbol, side, position_amt, entry_price,
+				mark_price, unrealized_profit, leverage, liquidation_price)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, recordID, l.traderID, ts, p.Symbol, p.Side, p.PositionAmt, p.EntryPrice, p.MarkPrice, p.UnrealizedProfit, p.Leverage, p.LiquidationPrice)
+		if err != nil {
+			return false, fmt.Errorf("写入持仓快照失败: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("提交决策记录失败: %w", err)
+	}
+	return true, nil
+}
+
+// GetLatestRecords 获取最近N条记录（按时间正序：从旧到新）
+func (l *SQLiteDecisionLogger) GetLatestRecords(n int) ([]*DecisionRecord, error) {
+	records, err := l.queryRecords(`WHERE r.trader_id = ? ORDER BY r.timestamp DESC, r.id DESC LIMIT ?`, l.traderID, n)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// GetRecordByDate 获取指定日期的所有记录（按 date 所在时区的自然日）
+func (l *SQLiteDecisionLogger) GetRecordByDate(date time.Time) ([]*DecisionRecord, error) {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	return l.GetRecordsBetween(start, start.AddDate(0, 0, 1))
+}
+
+// GetRecordsBetween 获取时间位于 [start, end) 的记录（按时间正序）
+func (l *SQLiteDecisionLogger) GetRecordsBetween(start, end time.Time) ([]*DecisionRecord, error) {
+	return l.queryRecords(`WHERE r.trader_id = ? AND r.timestamp >= ? AND r.timestamp < ? ORDER BY r.timestamp, r.id`,
+		l.traderID, start.UnixMilli(), end.UnixMilli())
+}
+
+// QueryRecords 按条件分页查询决策记录（按时间倒序），动作条件通过 (trader_id, symbol, timestamp) 索引匹配
+func (l *SQLiteDecisionLogger) QueryRecords(q DecisionQuery) (*DecisionPage, error) {
+	cursor, err := parseDecisionCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"r.trader_id = ?"}
+	args := []any{l.traderID}
+	if !q.Start.IsZero() {
+		where = append(where, "r.timestamp >= ?")
+		args = append(args, q.Start.UnixMilli())
+	}
+	if !q.End.IsZero() {
+		where = append(where, "r.timestamp < ?")
+		args = append(args, q.End.UnixMilli())
+	}
+	if cursor != nil {
+		where = append(where, "(r.timestamp < ? OR (r.timestamp = ? AND r.cycle_number < ?))")
+		args = append(args, cursor.timestamp, cursor.timestamp, cursor.cycle)
+	}
+	if q.filtersActions() {
+		conds := []string{"d.record_id = r.id", "d.trader_id = r.trader_id"}
+		if q.Symbol != "" {
+			conds = append(conds, "d.symbol = ?")
+			args = append(args, strings.ToUpper(q.Symbol))
+		}
+		if q.Action != "" {
+			conds = append(conds, "d.action = ?")
+			args = append(args, q.Action)
+		}
+		if q.Success != nil {
+			conds = append(conds, "d.success = ?")
+			args = append(args, *q.Success)
+		}
+		where = append(where, "EXISTS (SELECT 1 FROM decision_actions d WHERE "+strings.Join(conds, " AND ")+")")
+	} else if q.Success != nil {
+		where = append(where, "r.success = ?")
+		args = append(args, *q.Success)
+	}
+
+	limit := q.limit()
+	args = append(args, limit+1)
+	records, err := l.queryRecords("WHERE "+strings.Join(where, " AND ")+" ORDER BY r.timestamp DESC, r.cycle_number DESC LIMIT ?", args...)
+	if err != nil {
+		return nil, err
+	}
+	return newDecisionPage(records, limit), nil
+}
+
+// CleanOldRecords 清理N天前的旧记录（动作与快照级联删除）
+func (l *SQLiteDecisionLogger) CleanOldRecords(days int) error {
+	cutoff := time.Now().AddDate(0, 0, -days).UnixMilli()
+	result, err := l.db.Exec(`DELETE FROM decision_records WHERE trader_id = ? AND timestamp < ?`, l.traderID, cutoff)
+	if err != nil {
+		return fmt.Errorf("清理旧决策记录失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		fmt.Printf("🗑️ 已清理 %d 条旧记录（%d天前）\n", n, days)
+	}
+	return nil
+}
+
+// GetStatistics 获取统计信息
+func (l *SQLiteDecisionLogger) GetStatistics() (*Statistics, error) {
+	stats := &Statistics{}
+	err := l.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN success THEN 1 ELSE 0 END), 0)
+		FROM decision_records WHERE trader_id = ?
+	`, l.traderID).Scan(&stats.TotalCycles, &stats.SuccessfulCycles)
+	if err != nil {
+		return nil, fmt.Errorf("统计决策周期失败: %w", err)
+	}
+	stats.FailedCycles = stats.TotalCycles - stats.SuccessfulCycles
+
+	// partial_close 不计入平仓次数，与文件存储保持一致
+	err = l.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN action IN ('open_long', 'open_short') THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN action IN ('close_long', 'close_short', 'auto_close_long', 'auto_close_short') THEN 1 ELSE 0 END), 0)
+		FROM decision_actions WHERE trader_id = ? AND success
+	`, l.traderID).Scan(&stats.TotalOpenPositions, &stats.TotalClosePositions)
+	if err != nil {
+		return nil, fmt.Errorf("统计决策动作失败: %w", err)
+	}
+	return stats, nil
+}
+
+// AnalyzePerformance 分析最近N个周期的交易表现
+func (l *SQLiteDecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAnalysis, error) {
+	return analyzePerformance(l.GetLatestRecords, lookbackCycles)
+}
+
+// ApplyRetention 执行保留策略（归档保存在库文件所在目录的 archive/<trader_id>）
+func (l *SQLiteDecisionLogger) ApplyRetention(policy RetentionPolicy) (*RetentionResult, error) {
+	return applyRetention(l, policy, l.clock())
+}
+
+// GetEquitySnapshots 获取降采样净值快照（按时间正序）
+func (l *SQLiteDecisionLogger) GetEquitySnapshots() ([]EquitySnapshot, error) {
+	rows, err := l.db.Query(`
+		SELECT timestamp, cycle_number, total_balance, available_balance, total_unrealized_profit,
+			position_count, margin_used_pct, initial_balance
+		FROM equity_snapshots WHERE trader_id = ? ORDER BY timestamp, cycle_number
+	`, l.traderID)
+	if err != nil {
+		return nil, fmt.Errorf("查询净值快照失败: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []EquitySnapshot{}
+	for rows.Next() {
+		var snapshot EquitySnapshot
+		var ts int64
+		s := &snapshot.AccountState
+		if err := rows.Scan(&ts, &snapshot.CycleNumber, &s.TotalBalance, &s.AvailableBalance, &s.TotalUnrealizedProfit,
+			&s.PositionCount, &s.MarginUsedPct, &s.InitialBalance); err != nil {
+			return nil, fmt.Errorf("读取净值快照失败: %w", err)
+		}
+		snapshot.Timestamp = time.UnixMilli(ts)
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// dedupSystemPrompts 把旧记录中内联的系统提示词移入 system_prompts 表
+func (l *SQLiteDecisionLogger) dedupSystemPrompts() (int, error) {
+	rows, err := l.db.Query(`SELECT id, system_prompt FROM decision_records WHERE trader_id = ? AND system_prompt != ''`, l.traderID)
+	if err != nil {
+		return 0, fmt.Errorf("查询系统提示词失败: %w", err)
+	}
+	prompts := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var prompt string
+		if err := rows.Scan(&id, &prompt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("读取系统提示词失败: %w", err)
+		}
+		prompts[id] = prompt
+	}
+	rows.Close()
+	if len(prompts) == 0 {
+		return 0, nil
+	}
+
+	tx, err := l.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("开启系统提示词去重事务失败: %w", err)
+	}
+	defer tx.Rollback()
+	for id, prompt := range prompts {
+		hash := systemPromptHash(prompt)
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO system_prompts (hash, prompt) VALUES (?, ?)`, hash, prompt); err != nil {
+			return 0, fmt.Errorf("写入系统提示词失败: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE decision_records SET system_prompt = '', system_prompt_hash = ? WHERE id = ?`, hash, id); err != nil {
+			return 0, fmt.Errorf("更新决策记录失败: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("提交系统提示词去重失败: %w", err)
+	}
+	return len(prompts), nil
+}
+
+// oldestRecordTime 最早的记录时间
+func (l *SQLiteDecisionLogger) oldestRecordTime() (time.Time, bool, error) {
+	var oldest sql.NullInt64
+	if err := l.db.QueryRow(`SELECT MIN(timestamp) FROM decision_records WHERE trader_id = ?`, l.traderID).Scan(&oldest); err != nil {
+		return time.Time{}, false, fmt.Errorf("查询最早记录时间失败: %w", err)
+	}
+	return time.UnixMilli(oldest.Int64), oldest.Valid, nil
+}
+
+// removeRecords 删除记录（动作与快照级联删除）
+func (l *SQLiteDecisionLogger) removeRecords(records []*DecisionRecord) error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启删除事务失败: %w", err)
+	}
+	defer tx.Rollback()
+	for _, record := range records {
+		_, err := tx.Exec(`DELETE FROM decision_records WHERE trader_id = ? AND timestamp = ? AND cycle_number = ?`,
+			l.traderID, record.Timestamp.UnixMilli(), record.CycleNumber)
+		if err != nil {
+			return fmt.Errorf("删除决策记录失败: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交删除失败: %w", err)
+	}
+	return nil
+}
+
+// saveEquitySnapshots 写入净值快照（已存在的忽略）
+func (l *SQLiteDecisionLogger) saveEquitySnapshots(snapshots []EquitySnapshot) error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启净值快照事务失败: %w", err)
+	}
+	defer tx.Rollback()
+	for _, snapshot := range snapshots {
+		s := snapshot.AccountState
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO equity_snapshots (trader_id, timestamp, cycle_number, total_balance, available_balance,
+				total_unrealized_profit, position_count, margin_used_pct, initial_balance)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, l.traderID, snapshot.Timestamp.UnixMilli(), snapshot.CycleNumber, s.TotalBalance, s.AvailableBalance,
+			s.TotalUnrealizedProfit, s.PositionCount, s.MarginUsedPct, s.InitialBalance)
+		if err != nil {
+			return fmt.Errorf("写入净值快照失败: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交净值快照失败: %w", err)
+	}
+	return nil
+}
+
+// archiveDir 归档目录
+func (l *SQLiteDecisionLogger) archiveDir() string {
+	return filepath.Join(filepath.Dir(l.dbPath), archiveDirName, l.traderID)
+}
+
+// LoadPositionHistory 读取已持久化的持仓生命周期与回放起点
+func (l *SQLiteDecisionLogger) LoadPositionHistory() (*PositionHistory, error) {
+	history := &PositionHistory{Positions: []*PositionLifecycle{}}
+	var since sql.NullInt64
+	err := l.db.QueryRow(`SELECT since FROM position_history_state WHERE trader_id = ?`, l.traderID).Scan(&since)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("查询持仓回放起点失败: %w", err)
+	}
+	if since.Valid {
+		history.Since = time.UnixMilli(since.Int64)
+	}
+
+	rows, err := l.db.Query(`
+		SELECT id, symbol, side, status, leverage, entry_price, quantity, stop_loss, take_profit, open_time, open_cycle,
+			entry_reasoning, close_time, exit_price, exit_cause, realized_pnl, realized_pnl_pct
+		FROM position_lifecycles WHERE trader_id = ? ORDER BY open_time, id
+	`, l.traderID)
+	if err != nil {
+		return nil, fmt.Errorf("查询持仓生命周期失败: %w", err)
+	}
+	byID := make(map[string]*PositionLifecycle)
+	for rows.Next() {
+		var openTime, closeTime int64
+		pos := &PositionLifecycle{Events: []PositionEvent{}}
+		if err := rows.Scan(&pos.ID, &pos.Symbol, &pos.Side, &pos.Status, &pos.Leverage, &pos.EntryPrice, &pos.Quantity,
+			&pos.StopLoss, &pos.TakeProfit, &openTime, &pos.OpenCycle, &pos.EntryReasoning, &closeTime, &pos.ExitPrice,
+			&pos.ExitCause, &pos.RealizedPnL, &pos.RealizedPnLPct); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("读取持仓生命周期失败: %w", err)
+		}
+		pos.OpenTime = time.UnixMilli(openTime)
+		pos.CloseTime = time.UnixMilli(closeTime)
+		history.Positions = append(history.Positions, pos)
+		byID[pos.ID] = pos
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取持仓生命周期失败: %w", err)
+	}
+	if len(byID) == 0 {
+		return history, nil
+	}
+
+	rows, err = l.db.Query(`
+		SELECT position_id, type, time, cycle_number, price, quantity, stop_loss, take_profit, pnl, cause, order_id, reasoning, inferred
+		FROM position_events WHERE trader_id = ? ORDER BY position_id, seq
+	`, l.traderID)
+	if err != nil {
+		return nil, fmt.Errorf("查询持仓事件失败: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var positionID string
+		var ts int64
+		var e PositionEvent
+		if err := rows.Scan(&positionID, &e.Type, &ts, &e.CycleNumber, &e.Price, &e.Quantity, &e.StopLoss, &e.TakeProfit,
+			&e.PnL, &e.Cause, &e.OrderID, &e.Reasoning, &e.Inferred); err != nil {
+			return nil, fmt.Errorf("读取持仓事件失败: %w", err)
+		}
+		e.Time = time.UnixMilli(ts)
+		if pos, ok := byID[positionID]; ok {
+			pos.Events = append(pos.Events, e)
+		}
+	}
+	return history, rows.Err()
+}
+
+// SavePositionHistory 写入已结束的持仓生命周期及其事件（已存在的忽略）并更新回放起点
+func (l *SQLiteDecisionLogger) SavePositionHistory(positions []*PositionLifecycle, since time.Time) error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启持仓生命周期事务失败: %w", err)
+	}
+	defer tx.Rollback()
+	for _, pos := range positions {
+		result, err := tx.Exec(`
+			INSERT OR IGNORE INTO position_lifecycles (trader_id, id, symbol, side, status, leverage, entry_price, quantity,
+				stop_loss, take_profit, open_time, open_cycle, entry_reasoning, close_time, exit_price, exit_cause,
+				realized_pnl, realized_pnl_pct)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, l.traderID, pos.ID, pos.Symbol, pos.Side, pos.Status, pos.Leverage, pos.EntryPrice, pos.Quantity,
+			pos.StopLoss, pos.TakeProfit, pos.OpenTime.UnixMilli(), pos.OpenCycle, pos.EntryReasoning, pos.CloseTime.UnixMilli(),
+			pos.ExitPrice, pos.ExitCause, pos.RealizedPnL, pos.RealizedPnLPct)
+		if err != nil {
+			return fmt.Errorf("写入持仓生命周期失败: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		for seq, e := range pos.Events {
+			_, err := tx.Exec(`
+				INSERT INTO position_events (trader_id, position_id, seq, type, time, cycle_number, price, quantity,
+					stop_loss, take_profit, pnl, cause, order_id, reasoning, inferred)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, l.traderID, pos.ID, seq, e.Type, e.Time.UnixMilli(), e.CycleNumber, e.Price, e.Quantity,
+				e.StopLoss, e.TakeProfit, e.PnL, e.Cause, e.OrderID, e.Reasoning, e.Inferred)
+			if err != nil {
+				return fmt.Errorf("写入持仓事件失败: %w", err)
+			}
+		}
+	}
+	_, err = tx.Exec(`
+		INSERT INTO position_history_state (trader_id, since) VALUES (?, ?)
+		ON CONFLICT(trader_id) DO UPDATE SET since = excluded.since
+	`, l.traderID, since.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("更新持仓回放起点失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交持仓生命周期失败: %w", err)
+	}
+	return nil
+}
+
+// queryRecords 按条件查询记录并加载其动作与持仓快照
+func (l *SQLiteDecisionLogger) queryRecords(where string, args ...any) ([]*DecisionRecord, error) {
+	rows, err := l.db.Query(`
+		SELECT r.id, r.cycle_number, r.timestamp, r.success, r.error_message,
+			COALESCE(p.prompt, r.system_prompt), r.system_prompt_hash, r.input_prompt,
+			r.cot_trace, r.decision_json, r.candidate_coins, r.execution_log, r.ai_request_duration_ms,
+			r.risk_reason, r.trigger_type, r.trigger_reason, r.ensemble_policy, r.model_responses, r.ai_provider,
+			COALESCE(a.total_balance, 0), COALESCE(a.available_balance, 0), COALESCE(a.total_unrealized_profit, 0),
+			COALESCE(a.position_count, 0), COALESCE(a.margin_used_pct, 0), COALESCE(a.initial_balance, 0)
+		FROM decision_records r
+		LEFT JOIN account_snapshots a ON a.record_id = r.id
+		LEFT JOIN system_prompts p ON p.hash = r.system_prompt_hash AND r.system_prompt = ''
+		`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询决策记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*DecisionRecord
+	byID := make(map[int64]*DecisionRecord)
+	var ids []any
+	for rows.Next() {
+		var (
+			id                                         int64
+			ts                                         int64
+			candidateCoins, executionLog, modelReplies string
+			record                                     DecisionRecord
+		)
+		s := &record.AccountState
+		err := rows.Scan(&id, &record.CycleNumber, &ts, &record.Success, &record.ErrorMessage,
+			&record.SystemPrompt, &record.SystemPromptHash, &record.InputPrompt,
+			&record.CoTTrace, &record.DecisionJSON, &candidateCoins, &executionLog, &record.AIRequestDurationMs,
+			&record.RiskReason, &record.Trigger, &record.TriggerReason, &record.EnsemblePolicy, &modelReplies, &record.AIProvider,
+			&s.TotalBalance, &s.AvailableBalance, &s.TotalUnrealizedProfit, &s.PositionCount, &s.MarginUsedPct, &s.InitialBalance)
+		if err != nil {
+			return nil, fmt.Errorf("读取决策记录失败: %w", err)
+		}
+		record.Timestamp = time.UnixMilli(ts)
+		json.Unmarshal([]byte(candidateCoins), &record.CandidateCoins)
+		json.Unmarshal([]byte(executionLog), &record.ExecutionLog)
+		if modelReplies != "" {
+			json.Unmarshal([]byte(modelReplies), &record.ModelResponses)
+		}
+		record.Positions = []PositionSnapshot{}
+		record.Decisions = []DecisionAction{}
+		records = append(records, &record)
+		byID[id] = &record
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取决策记录失败: %w", err)
+	}
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	if err := l.loadActions(byID, ids); err != nil {
+		return nil, err
+	}
+	if err := l.loadPositions(byID, ids); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// loadActions 批量加载记录的决策动作
+func (l *SQLiteDecisionLogger) loadActions(byID map[int64]*DecisionRecord, ids []any) error {
+	return forEachChunk(ids, func(chunk []any) error {
+		rows, err := l.db.Query(`
+			SELECT record_id, action, symbol, quantity, leverage, price, order_id, timestamp, success, error, stop_loss, take_profit, reasoning
+			FROM decision_actions WHERE record_id IN (`+placeholders(len(chunk))+`) ORDER BY id
+		`, chunk...)
+		if err != nil {
+			return fmt.Errorf("查询决策动作失败: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var recordID, ts int64
+			var a DecisionAction
+			if err := rows.Scan(&recordID, &a.Action, &a.Symbol, &a.Quantity, &a.Leverage, &a.Price, &a.OrderID, &ts, &a.Success, &a.Error, &a.StopLoss, &a.TakeProfit, &a.Reasoning); err != nil {
+				return fmt.Errorf("读取决策动作失败: %w", err)
+			}
+			a.Timestamp = time.UnixMilli(ts)
+			byID[recordID].Decisions = append(byID[recordID].Decisions, a)
+		}
+		return rows.Err()
+	})
+}
+
+// loadPositions 批量加载记录的持仓快照
+func (l *SQLiteDecisionLogger) loadPositions(byID map[int64]*DecisionRecord, ids []any) error {
+	return forEachChunk(ids, func(chunk []any) error {
+		rows, err := l.db.Query(`
+			SELECT record_id, symbol, side, position_amt, entry_price, mark_price, unrealized_profit, leverage, liquidation_price
+			FROM position_snapshots WHERE record_id IN (`+placeholders(len(chunk))+`) ORDER BY id
+		`, chunk...)
+		if err != nil {
+			return fmt.Errorf("查询持仓快照失败: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var recordID int64
+			var p PositionSnapshot
+			if err := rows.Scan(&recordID, &p.Symbol, &p.Side, &p.PositionAmt, &p.EntryPrice, &p.MarkPrice, &p.UnrealizedProfit, &p.Leverage, &p.LiquidationPrice); err != nil {
+				return fmt.Errorf("读取持仓快照失败: %w", err)
+			}
+			byID[recordID].Positions = append(byID[recordID].Positions, p)
+		}
+		return rows.Err()
+	})
+}
+
+// sqliteMaxParams 单条语句的参数数量上限（低于 SQLite 默认限制）
+const sqliteMaxParams = 900
+
+// forEachChunk 分批执行 IN 查询，避免超过 SQLite 的参数数量限制
+func forEachChunk(ids []any, fn func(chunk []any) error) error {
+	for start := 0; start < len(ids); start += sqliteMaxParams {
+		end := min(start+sqliteMaxParams, len(ids))
+		if err := fn(ids[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// placeholders 生成 n 个以逗号分隔的 ? 占位符
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// nonNilStrings 空列表序列化为 [] 而不是 null
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
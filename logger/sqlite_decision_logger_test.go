@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testRecord(equity float64, actions ...DecisionAction) *DecisionRecord {
+	return &DecisionRecord{
+		Success:        true,
+		CandidateCoins: []string{"BTCUSDT"},
+		ExecutionLog:   []string{"ok"},
+		AccountState:   AccountSnapshot{TotalBalance: equity, InitialBalance: 1000, PositionCount: 1},
+		Positions:      []PositionSnapshot{{Symbol: "BTCUSDT", Side: "long", PositionAmt: 0.1, EntryPrice: 100000}},
+		Decisions:      actions,
+		AIProvider:     "qwen",
+	}
+}
+
+// TestSQLiteDecisionLogger 写入、读取、周期编号延续、统计与清理
+func TestSQLiteDecisionLogger(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "decisions.db")
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.Local)
+
+	l, err := NewSQLiteDecisionLogger(dbPath, "trader_a")
+	if err != nil {
+		t.Fatalf("NewSQLiteDecisionLogger returned error: %v", err)
+	}
+	l.clock = func() time.Time { return now }
+
+	open := DecisionAction{Action: "open_long", Symbol: "BTCUSDT", Quantity: 0.1, Leverage: 5, Price: 100000, Timestamp: now, Success: true}
+	closeAction := DecisionAction{Action: "close_long", Symbol: "BTCUSDT", Price: 101000, Timestamp: now.Add(time.Hour), Success: true}
+	if err := l.LogDecision(testRecord(1000, open)); err != nil {
+		t.Fatalf("LogDecision returned error: %v", err)
+	}
+	now = now.Add(time.Hour)
+	if err := l.LogDecision(testRecord(1100, closeAction)); err != nil {
+		t.Fatalf("LogDecision returned error: %v", err)
+	}
+
+	// 其他交易员的记录互不影响
+	other, _ := NewSQLiteDecisionLogger(dbPath, "trader_b")
+	other.LogDecision(testRecord(500))
+
+	records, err := l.GetLatestRecords(10)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("GetLatestRecords = %d records, %v; want 2", len(records), err)
+	}
+	r := records[1]
+	if r.CycleNumber != 2 || r.AccountState.TotalBalance != 1100 || r.AIProvider != "qwen" ||
+		len(r.Positions) != 1 || len(r.Decisions) != 1 || r.Decisions[0].Action != "close_long" || r.CandidateCoins[0] != "BTCUSDT" {
+		t.Errorf("unexpected round-tripped record: %+v", r)
+	}
+
+	// 重启后周期编号从库中的最大值继续
+	reopened, _ := NewSQLiteDecisionLogger(dbPath, "trader_a")
+	if reopened.cycleNumber != 2 {
+		t.Errorf("cycle number after restart = %d, want 2", reopened.cycleNumber)
+	}
+
+	if got, _ := l.GetRecordByDate(now); len(got) != 2 {
+		t.Errorf("GetRecordByDate = %d records, want 2", len(got))
+	}
+	stats, err := l.GetStatistics()
+	if err != nil || stats.TotalCycles != 2 || stats.TotalOpenPositions != 1 || stats.TotalClosePositions != 1 {
+		t.Errorf("GetStatistics = %+v, %v", stats, err)
+	}
+	analysis, err := l.AnalyzePerformance(10)
+	if err != nil || analysis.TotalTrades != 1 || analysis.RecentTrades[0].PnL != 100 {
+		t.Errorf("AnalyzePerformance = %+v, %v", analysis, err)
+	}
+
+	// 重复导入同一条记录被跳过
+	if inserted, err := l.ImportRecord(records[0]); err != nil || inserted {
+		t.Errorf("re-importing an existing record should be skipped: %v, %v", inserted, err)
+	}
+
+	// 清理时级联删除动作与快照
+	if err := l.CleanOldRecords(0); err != nil {
+		t.Fatalf("CleanOldRecords returned error: %v", err)
+	}
+	var actions int
+	l.db.QueryRow(`SELECT COUNT(*) FROM decision_actions WHERE trader_id = 'trader_a'`).Scan(&actions)
+	if got, _ := l.GetLatestRecords(10); len(got) != 0 || actions != 0 {
+		t.Errorf("old records should be removed with their actions, got %d records, %d actions", len(got), actions)
+	}
+	if got, _ := other.GetLatestRecords(10); len(got) != 1 {
+		t.Errorf("cleaning one trader should not touch others, got %d", len(got))
+	}
+}
+
+// TestDecisionLoggerCycleNumberRestore 文件存储重启后从已有文件的最大周期编号继续
+func TestDecisionLoggerCycleNumberRestore(t *testing.T) {
+	dir := t.TempDir()
+	first := NewDecisionLogger(dir)
+	for i := 0; i < 3; i++ {
+		if err := first.LogDecision(testRecord(1000)); err != nil {
+			t.Fatalf("LogDecision returned error: %v", err)
+		}
+	}
+
+	record := testRecord(1000)
+	if err := NewDecisionLogger(dir).LogDecision(record); err != nil {
+		t.Fatalf("LogDecision returned error: %v", err)
+	}
+	if record.CycleNumber != 4 {
+		t.Errorf("cycle number after restart = %d, want 4", record.CycleNumber)
+	}
+}
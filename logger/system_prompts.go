@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// systemPromptHash 系统提示词的 SHA-256 哈希（十六进制）
+func systemPromptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// promptStore 文件存储的系统提示词去重目录：每个不同的提示词保存为 <dir>/<hash>.txt
+type promptStore struct {
+	dir   string
+	mu    sync.Mutex
+	cache map[string]string // 哈希 -> 提示词
+}
+
+func newPromptStore(dir string) *promptStore {
+	return &promptStore{dir: dir, cache: make(map[string]string)}
+}
+
+// save 保存提示词（已存在时跳过），返回其哈希
+func (s *promptStore) save(prompt string) (string, error) {
+	hash := systemPromptHash(prompt)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.cache[hash]; ok {
+		return hash, nil
+	}
+
+	path := filepath.Join(s.dir, hash+".txt")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := WriteFileAtomic(path, []byte(prompt)); err != nil {
+			return "", fmt.Errorf("写入系统提示词失败: %w", err)
+		}
+	}
+	s.cache[hash] = prompt
+	return hash, nil
+}
+
+// load 按哈希读取提示词（不存在时返回空字符串）
+func (s *promptStore) load(hash string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prompt, ok := s.cache[hash]; ok {
+		return prompt
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, hash+".txt"))
+	if err != nil {
+		return ""
+	}
+	s.cache[hash] = string(data)
+	return string(data)
+}
@@ -23,19 +23,23 @@ import (
 // ConfigFile 配置文件结构，只包含需要同步到数据库的字段
 // TODO 现在与config.Config相同，未来会被替换， 现在为了兼容性不得不保留当前文件
 type ConfigFile struct {
-	BetaMode           bool                  `json:"beta_mode"`
-	APIServerPort      int                   `json:"api_server_port"`
-	UseDefaultCoins    bool                  `json:"use_default_coins"`
-	DefaultCoins       []string              `json:"default_coins"`
-	CoinPoolAPIURL     string                `json:"coin_pool_api_url"`
-	OITopAPIURL        string                `json:"oi_top_api_url"`
-	MaxDailyLoss       float64               `json:"max_daily_loss"`
-	MaxDrawdown        float64               `json:"max_drawdown"`
-	StopTradingMinutes int                   `json:"stop_trading_minutes"`
-	Leverage           config.LeverageConfig `json:"leverage"`
-	JWTSecret          string                `json:"jwt_secret"`
-	DataKLineTime      string                `json:"data_k_line_time"`
-	Log                *config.LogConfig     `json:"log"` // 日志配置
+	BetaMode           bool                       `json:"beta_mode"`
+	APIServerPort      int                        `json:"api_server_port"`
+	UseDefaultCoins    bool                       `json:"use_default_coins"`
+	DefaultCoins       []string                   `json:"default_coins"`
+	CoinPoolAPIURL     string                     `json:"coin_pool_api_url"`
+	OITopAPIURL        string                     `json:"oi_top_api_url"`
+	MaxDailyLoss       float64                    `json:"max_daily_loss"`
+	MaxDrawdown        float64                    `json:"max_drawdown"`
+	StopTradingMinutes int                        `json:"stop_trading_minutes"`
+	Leverage           config.LeverageConfig      `json:"leverage"`
+	PaperTrading       *config.PaperTradingConfig `json:"paper_trading"`       // 模拟盘配置（可选）
+	RecordAIResponses  bool                       `json:"record_ai_responses"` // 是否录制AI请求与响应（用于离线回放）
+	JWTSecret          string                     `json:"jwt_secret"`
+	KlineStorePath     string                     `json:"kline_store_path"`     // 本地K线库路径（SQLite）
+	DecisionLogBackend string                     `json:"decision_log_backend"` // 决策日志存储后端：file（默认）/ sqlite
+	DecisionLogDBPath  string                     `json:"decision_log_db_path"` // SQLite决策日志库路径
+	Log                *config.LogConfig          `json:"log"`                  // 日志配置
 }
 
 // loadConfigFile 读取并解析config.json文件
@@ -79,6 +83,7 @@ func syncConfigToDatabase(database *config.Database, configFile *ConfigFile) err
 		"max_daily_loss":       fmt.Sprintf("%.1f", configFile.MaxDailyLoss),
 		"max_drawdown":         fmt.Sprintf("%.1f", configFile.MaxDrawdown),
 		"stop_trading_minutes": strconv.Itoa(configFile.StopTradingMinutes),
+		"record_ai_responses":  fmt.Sprintf("%t", configFile.RecordAIResponses),
 	}
 
 	// 同步default_coins（转换为JSON字符串存储）
@@ -97,6 +102,25 @@ func syncConfigToDatabase(database *config.Database, configFile *ConfigFile) err
 		configs["altcoin_leverage"] = strconv.Itoa(configFile.Leverage.AltcoinLeverage)
 	}
 
+	// 同步模拟盘配置
+	if configFile.PaperTrading != nil {
+		configs["paper_fee_rate"] = strconv.FormatFloat(configFile.PaperTrading.FeeRate, 'f', -1, 64)
+		configs["paper_slippage"] = strconv.FormatFloat(configFile.PaperTrading.Slippage, 'f', -1, 64)
+	}
+
+	// K线库路径不为空时同步（未配置时保持数据库中的值）
+	if configFile.KlineStorePath != "" {
+		configs["kline_store_path"] = configFile.KlineStorePath
+	}
+
+	// 决策日志存储后端不为空时同步（未配置时保持数据库中的值）
+	if configFile.DecisionLogBackend != "" {
+		configs["decision_log_backend"] = configFile.DecisionLogBackend
+	}
+	if configFile.DecisionLogDBPath != "" {
+		configs["decision_log_db_path"] = configFile.DecisionLogDBPath
+	}
+
 	// 如果JWT密钥不为空，也同步
 	if configFile.JWTSecret != "" {
 		configs["jwt_secret"] = configFile.JWTSecret
@@ -160,6 +184,38 @@ func main() {
 	// In Docker Compose, variables are injected by the runtime and this is harmless.
 	_ = godotenv.Load()
 
+	// 子命令：历史回测
+	if len(os.Args) > 1 && os.Args[1] == "backtest" {
+		if err := runBacktestCommand(os.Args[2:]); err != nil {
+			log.Fatalf("❌ 回测失败: %v", err)
+		}
+		return
+	}
+
+	// 子命令：回放录制的AI响应
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplayCommand(os.Args[2:]); err != nil {
+			log.Fatalf("❌ 回放失败: %v", err)
+		}
+		return
+	}
+
+	// 子命令：把JSON决策日志导入SQLite决策日志库
+	if len(os.Args) > 1 && os.Args[1] == "migrate-decisions" {
+		if err := runMigrateDecisionsCommand(os.Args[2:]); err != nil {
+			log.Fatalf("❌ 决策日志迁移失败: %v", err)
+		}
+		return
+	}
+
+	// 子命令：导出决策日志为CSV/Parquet
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExportCommand(os.Args[2:]); err != nil {
+			log.Fatalf("❌ 决策日志导出失败: %v", err)
+		}
+		return
+	}
+
 	// 初始化数据库配置
 	dbPath := "config.db"
 	if len(os.Args) > 1 {
@@ -345,9 +401,29 @@ func main() {
 		}
 	}()
 
+	// 打开本地K线库：行情监控收盘时写入，重启时补齐缺口，供指标、API与回测查询长历史
+	if klineStorePath, _ := database.GetSystemConfig("kline_store_path"); klineStorePath != "" {
+		klineStore, err := market.OpenKlineStore(klineStorePath)
+		if err != nil {
+			log.Printf("⚠️  打开K线库失败，仅使用内存缓存: %v", err)
+		} else {
+			market.SetKlineStore(klineStore)
+			defer klineStore.Close()
+			log.Printf("✓ 已启用本地K线库: %s", klineStorePath)
+		}
+	}
+
 	// 启动流行情数据 - 默认使用所有交易员设置的币种 如果没有设置币种 则优先使用系统默认
 	go market.NewWSMonitor(150).Start(database.GetCustomCoins())
 	//go market.NewWSMonitor(150).Start([]string{}) //这里是一个使用方式 传入空的话 则使用market市场的所有币种
+
+	// 启动跨交易所资金费率采集（币安、Hyperliquid、Aster），交易员运行时会追加各自的候选币种
+	fundingCollector := market.NewFundingCollector(market.DefaultProvider(), market.NewHyperliquidProvider(false), market.NewAsterProvider())
+	fundingCollector.Track(database.GetCustomCoins()...)
+	market.SetFundingCollector(fundingCollector)
+	fundingCollector.Start()
+	defer fundingCollector.Stop()
+
 	// 设置优雅退出
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
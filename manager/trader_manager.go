@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"log"
 	"nofx/config"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
 	"nofx/trader"
 	"sort"
 	"strconv"
@@ -252,7 +255,22 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		traderConfig.AsterUser = exchangeCfg.AsterUser
 		traderConfig.AsterSigner = exchangeCfg.AsterSigner
 		traderConfig.AsterPrivateKey = exchangeCfg.AsterPrivateKey
-	}
+	} else if exchangeCfg.ID == "paper" {
+		traderConfig.PaperFeeRate, traderConfig.PaperSlippage = loadPaperTradingParams(database)
+	}
+	traderConfig.RecordAIResponses = loadRecordAIResponses(database)
+	traderConfig.DecisionLogBackend, traderConfig.DecisionLogDBPath = loadDecisionLogBackend(database)
+	applyTraderRiskLimits(&traderConfig, traderCfg)
+	applyPositionProtection(&traderConfig, traderCfg)
+	applyMarketDataConfig(&traderConfig, traderCfg)
+	traderConfig.UseAlertCoins = traderCfg.UseAlertCoins
+	traderConfig.AlertWakeup = traderCfg.AlertWakeup
+	applyDecisionTriggers(&traderConfig, traderCfg)
+	traderConfig.MaxDepthFraction = traderCfg.MaxDepthFraction
+	traderConfig.CrossVenueFunding = traderCfg.CrossVenueFunding
+	applyEnsembleConfig(&traderConfig, traderCfg, database)
+	applyFallbackModels(&traderConfig, traderCfg, database)
+	applyDecisionRetention(&traderConfig, traderCfg)
 
 	// 根据AI模型设置API密钥
 	if aiModelCfg.Provider == "qwen" {
@@ -358,7 +376,22 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		traderConfig.AsterUser = exchangeCfg.AsterUser
 		traderConfig.AsterSigner = exchangeCfg.AsterSigner
 		traderConfig.AsterPrivateKey = exchangeCfg.AsterPrivateKey
-	}
+	} else if exchangeCfg.ID == "paper" {
+		traderConfig.PaperFeeRate, traderConfig.PaperSlippage = loadPaperTradingParams(database)
+	}
+	traderConfig.RecordAIResponses = loadRecordAIResponses(database)
+	traderConfig.DecisionLogBackend, traderConfig.DecisionLogDBPath = loadDecisionLogBackend(database)
+	applyTraderRiskLimits(&traderConfig, traderCfg)
+	applyPositionProtection(&traderConfig, traderCfg)
+	applyMarketDataConfig(&traderConfig, traderCfg)
+	traderConfig.UseAlertCoins = traderCfg.UseAlertCoins
+	traderConfig.AlertWakeup = traderCfg.AlertWakeup
+	applyDecisionTriggers(&traderConfig, traderCfg)
+	traderConfig.MaxDepthFraction = traderCfg.MaxDepthFraction
+	traderConfig.CrossVenueFunding = traderCfg.CrossVenueFunding
+	applyEnsembleConfig(&traderConfig, traderCfg, database)
+	applyFallbackModels(&traderConfig, traderCfg, database)
+	applyDecisionRetention(&traderConfig, traderCfg)
 
 	// 根据AI模型设置API密钥
 	if aiModelCfg.Provider == "qwen" {
@@ -1059,7 +1092,22 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		traderConfig.AsterUser = exchangeCfg.AsterUser
 		traderConfig.AsterSigner = exchangeCfg.AsterSigner
 		traderConfig.AsterPrivateKey = exchangeCfg.AsterPrivateKey
-	}
+	} else if exchangeCfg.ID == "paper" {
+		traderConfig.PaperFeeRate, traderConfig.PaperSlippage = loadPaperTradingParams(database)
+	}
+	traderConfig.RecordAIResponses = loadRecordAIResponses(database)
+	traderConfig.DecisionLogBackend, traderConfig.DecisionLogDBPath = loadDecisionLogBackend(database)
+	applyTraderRiskLimits(&traderConfig, traderCfg)
+	applyPositionProtection(&traderConfig, traderCfg)
+	applyMarketDataConfig(&traderConfig, traderCfg)
+	traderConfig.UseAlertCoins = traderCfg.UseAlertCoins
+	traderConfig.AlertWakeup = traderCfg.AlertWakeup
+	applyDecisionTriggers(&traderConfig, traderCfg)
+	traderConfig.MaxDepthFraction = traderCfg.MaxDepthFraction
+	traderConfig.CrossVenueFunding = traderCfg.CrossVenueFunding
+	applyEnsembleConfig(&traderConfig, traderCfg, database)
+	applyFallbackModels(&traderConfig, traderCfg, database)
+	applyDecisionRetention(&traderConfig, traderCfg)
 
 	// 根据AI模型设置API密钥
 	if aiModelCfg.Provider == "qwen" {
@@ -1090,6 +1138,194 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 	return nil
 }
 
+// loadPaperTradingParams 从系统配置读取模拟盘手续费率和滑点（缺失或非法时使用默认值）
+func loadPaperTradingParams(database *config.Database) (feeRate, slippage float64) {
+	feeRate = trader.DefaultPaperFeeRate
+	slippage = trader.DefaultPaperSlippage
+	if database == nil {
+		return feeRate, slippage
+	}
+
+	if val, err := database.GetSystemConfig("paper_fee_rate"); err == nil {
+		if parsed, err := strconv.ParseFloat(val, 64); err == nil && parsed >= 0 {
+			feeRate = parsed
+		}
+	}
+	if val, err := database.GetSystemConfig("paper_slippage"); err == nil {
+		if parsed, err := strconv.ParseFloat(val, 64); err == nil && parsed >= 0 {
+			slippage = parsed
+		}
+	}
+	return feeRate, slippage
+}
+
+// loadRecordAIResponses 从系统配置读取是否录制AI请求与响应
+func loadRecordAIResponses(database *config.Database) bool {
+	if database == nil {
+		return false
+	}
+	val, err := database.GetSystemConfig("record_ai_responses")
+	return err == nil && val == "true"
+}
+
+// loadDecisionLogBackend 从系统配置读取决策日志存储后端与SQLite库路径
+func loadDecisionLogBackend(database *config.Database) (string, string) {
+	if database == nil {
+		return "", ""
+	}
+	backend, _ := database.GetSystemConfig("decision_log_backend")
+	dbPath, _ := database.GetSystemConfig("decision_log_db_path")
+	return backend, dbPath
+}
+
+// applyTraderRiskLimits 使用交易员自身的风控参数覆盖系统默认值（0表示沿用系统默认）
+func applyTraderRiskLimits(traderConfig *trader.AutoTraderConfig, traderCfg *config.TraderRecord) {
+	if traderCfg.MaxDailyLoss > 0 {
+		traderConfig.MaxDailyLoss = traderCfg.MaxDailyLoss
+	}
+	if traderCfg.MaxDrawdown > 0 {
+		traderConfig.MaxDrawdown = traderCfg.MaxDrawdown
+	}
+	if traderCfg.StopTradingMinutes > 0 {
+		traderConfig.StopTradingTime = time.Duration(traderCfg.StopTradingMinutes) * time.Minute
+	}
+	traderConfig.FlattenOnRiskBreach = traderCfg.FlattenOnRiskBreach
+
+	// 交易员未设置阈值时沿用系统默认，明确提示以免默认风控被静默执行
+	var defaults []string
+	if traderCfg.MaxDailyLoss <= 0 && traderConfig.MaxDailyLoss > 0 {
+		defaults = append(defaults, fmt.Sprintf("最大日亏损 %.1f%%", traderConfig.MaxDailyLoss))
+	}
+	if traderCfg.MaxDrawdown <= 0 && traderConfig.MaxDrawdown > 0 {
+		defaults = append(defaults, fmt.Sprintf("最大回撤 %.1f%%", traderConfig.MaxDrawdown))
+	}
+	if len(defaults) > 0 {
+		log.Printf("ℹ️ 交易员 %s 未设置风控阈值，使用系统默认: %s", traderCfg.Name, strings.Join(defaults, " | "))
+	}
+}
+
+// applyPositionProtection 解析交易员的持仓保护规则，配置无效时回退到默认规则
+func applyPositionProtection(traderConfig *trader.AutoTraderConfig, traderCfg *config.TraderRecord) {
+	protection, err := trader.ParseProtectionConfig(traderCfg.PositionProtection)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 持仓保护配置无效，使用默认规则: %v", traderCfg.Name, err)
+		protection = trader.DefaultProtectionConfig()
+	}
+	traderConfig.PositionProtection = protection
+}
+
+// applyDecisionTriggers 解析交易员的事件触发配置，配置无效时仅使用定时扫描
+func applyDecisionTriggers(traderConfig *trader.AutoTraderConfig, traderCfg *config.TraderRecord) {
+	triggers, err := trader.ParseTriggerConfig(traderCfg.DecisionTriggers)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 事件触发配置无效，仅使用定时扫描: %v", traderCfg.Name, err)
+		triggers = trader.TriggerConfig{}
+	}
+	traderConfig.Triggers = triggers
+}
+
+// applyEnsembleConfig 解析交易员的多模型集成配置并加载引用的AI模型，配置无效时只使用主模型
+// 不存在或未启用的模型会被跳过；没有可用的额外模型（judge 策略没有仲裁模型）时不启用集成
+func applyEnsembleConfig(traderConfig *trader.AutoTraderConfig, traderCfg *config.TraderRecord, database *config.Database) {
+	ensemble, err := trader.ParseEnsembleConfig(traderCfg.EnsembleConfig)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 多模型集成配置无效，仅使用主模型: %v", traderCfg.Name, err)
+		return
+	}
+	if !ensemble.Enabled() || database == nil {
+		return
+	}
+	models, err := database.GetAIModels(traderCfg.UserID)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 获取AI模型失败，仅使用主模型: %v", traderCfg.Name, err)
+		return
+	}
+	resolve := func(id string) *trader.AIModelSpec {
+		spec := resolveAIModel(models, id)
+		if spec == nil {
+			log.Printf("⚠️ 交易员 %s 集成模型 %s 不存在或未启用，已跳过", traderCfg.Name, id)
+		}
+		return spec
+	}
+
+	var specs []trader.AIModelSpec
+	for _, id := range ensemble.Models {
+		if spec := resolve(id); spec != nil {
+			specs = append(specs, *spec)
+		}
+	}
+	var judge *trader.AIModelSpec
+	if ensemble.Policy == decision.EnsembleJudge {
+		if judge = resolve(ensemble.Judge); judge == nil {
+			return
+		}
+	} else if len(specs) == 0 {
+		return
+	}
+	traderConfig.EnsemblePolicy = ensemble.Policy
+	traderConfig.EnsembleModels = specs
+	traderConfig.EnsembleJudge = judge
+	traderConfig.EnsembleMinWeight = ensemble.MinWeight
+}
+
+// applyDecisionRetention 解析交易员的决策日志保留策略，配置无效时只做系统提示词去重
+func applyDecisionRetention(traderConfig *trader.AutoTraderConfig, traderCfg *config.TraderRecord) {
+	policy, err := logger.ParseRetentionPolicy(traderCfg.DecisionRetention)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 决策日志保留策略无效，不归档或删除记录: %v", traderCfg.Name, err)
+		policy = logger.RetentionPolicy{}
+	}
+	traderConfig.DecisionRetention = policy
+}
+
+// applyFallbackModels 加载交易员的AI故障转移备用模型，不存在或未启用的模型会被跳过
+func applyFallbackModels(traderConfig *trader.AutoTraderConfig, traderCfg *config.TraderRecord, database *config.Database) {
+	ids, err := trader.ParseFallbackModels(traderCfg.FallbackModels)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 备用模型配置无效，不启用故障转移: %v", traderCfg.Name, err)
+		return
+	}
+	if len(ids) == 0 || database == nil {
+		return
+	}
+	models, err := database.GetAIModels(traderCfg.UserID)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 获取AI模型失败，不启用故障转移: %v", traderCfg.Name, err)
+		return
+	}
+	for _, id := range ids {
+		if id == traderCfg.AIModelID {
+			continue
+		}
+		if spec := resolveAIModel(models, id); spec != nil {
+			traderConfig.FallbackModels = append(traderConfig.FallbackModels, *spec)
+		} else {
+			log.Printf("⚠️ 交易员 %s 备用模型 %s 不存在或未启用，已跳过", traderCfg.Name, id)
+		}
+	}
+}
+
+// resolveAIModel 按ID查找已启用的AI模型连接配置（不存在或未启用时返回 nil）
+func resolveAIModel(models []*config.AIModelConfig, id string) *trader.AIModelSpec {
+	for _, model := range models {
+		if model.ID == id && model.Enabled {
+			return &trader.AIModelSpec{Provider: model.Provider, APIKey: model.APIKey,
+				CustomAPIURL: model.CustomAPIURL, CustomModelName: model.CustomModelName}
+		}
+	}
+	return nil
+}
+
+// applyMarketDataConfig 解析交易员的附加时间框架配置（无效时仅使用默认的3分钟/4小时数据）
+func applyMarketDataConfig(traderConfig *trader.AutoTraderConfig, traderCfg *config.TraderRecord) {
+	dataConfig, err := market.ParseDataConfig(traderCfg.MarketData)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 市场数据配置无效，仅使用默认数据: %v", traderCfg.Name, err)
+		dataConfig = market.DataConfig{}
+	}
+	traderConfig.MarketData = dataConfig
+}
+
 // RemoveTrader 从内存中移除指定的trader（不影响数据库）
 // 用于更新trader配置时强制重新加载
 func (tm *TraderManager) RemoveTrader(traderID string) {
@@ -0,0 +1,180 @@
+package market
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// alertCooldown 同一币种同类警报的最小间隔（避免每根K线重复报警）
+const alertCooldown = 15 * time.Minute
+
+// recentAlertLimit 保留的最近警报数量
+const recentAlertLimit = 500
+
+// alertState 警报订阅者与最近警报
+type alertState struct {
+	mu        sync.RWMutex
+	subs      map[int]chan Alert
+	nextSubID int
+	recent    []Alert
+	lastFired map[string]time.Time // symbol|type -> 上次触发时间
+}
+
+// GetFeatures 返回币种最新特征（3分钟K线收盘时更新）
+func (m *WSMonitor) GetFeatures(symbol string) (*SymbolFeatures, bool) {
+	value, ok := m.featuresMap.Load(Normalize(symbol))
+	if !ok {
+		return nil, false
+	}
+	f := *value.(*SymbolFeatures)
+	return &f, true
+}
+
+// Alerts 返回全局警报通道（单消费者，通道满时丢弃；多个消费者请使用 SubscribeAlerts）
+func (m *WSMonitor) Alerts() <-chan Alert {
+	return m.alertsChan
+}
+
+// SubscribeAlerts 订阅警报，返回警报通道和取消订阅函数（通道满时丢弃，不阻塞行情处理）
+func (m *WSMonitor) SubscribeAlerts(bufferSize int) (<-chan Alert, func()) {
+	ch := make(chan Alert, bufferSize)
+
+	m.alerts.mu.Lock()
+	if m.alerts.subs == nil {
+		m.alerts.subs = make(map[int]chan Alert)
+	}
+	id := m.alerts.nextSubID
+	m.alerts.nextSubID++
+	m.alerts.subs[id] = ch
+	m.alerts.mu.Unlock()
+
+	unsubscribe := func() {
+		m.alerts.mu.Lock()
+		defer m.alerts.mu.Unlock()
+		if sub, ok := m.alerts.subs[id]; ok {
+			delete(m.alerts.subs, id)
+			close(sub)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// RecentAlerts 返回 since 之后的警报（按时间正序）
+func (m *WSMonitor) RecentAlerts(since time.Time) []Alert {
+	m.alerts.mu.RLock()
+	defer m.alerts.mu.RUnlock()
+
+	var result []Alert
+	for _, a := range m.alerts.recent {
+		if a.Timestamp.After(since) {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// AlertSymbols 返回 since 之后触发过警报的币种，按活跃度评分从高到低排序（limit<=0 表示不限制）
+func (m *WSMonitor) AlertSymbols(since time.Time, limit int) []string {
+	type scored struct {
+		symbol string
+		score  float64
+	}
+	var list []scored
+	m.symbolStats.Range(func(key, value interface{}) bool {
+		stats := value.(*SymbolStats)
+		if stats.LastAlertTime.After(since) {
+			list = append(list, scored{key.(string), stats.Score})
+		}
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].score != list[j].score {
+			return list[i].score > list[j].score
+		}
+		return list[i].symbol < list[j].symbol
+	})
+
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	symbols := make([]string, len(list))
+	for i, s := range list {
+		symbols[i] = s.symbol
+	}
+	return symbols
+}
+
+// updateFeatures 3分钟K线收盘时重新计算特征并检查警报
+func (m *WSMonitor) updateFeatures(symbol string, klines []Kline) {
+	features, err := CalculateFeatures(symbol, klines)
+	if err != nil {
+		return // 新上线或刚订阅的币种K线不足，等待积累
+	}
+	m.featuresMap.Store(symbol, features)
+
+	// 每个币种只在自己的K线协程中更新，写入新副本避免与读取方竞争
+	stats := &SymbolStats{}
+	if value, ok := m.symbolStats.Load(symbol); ok {
+		*stats = *value.(*SymbolStats)
+	}
+	stats.LastActiveTime = features.Timestamp
+	stats.Score = featureScore(features)
+
+	var fired []Alert
+	for _, alert := range CheckAlerts(features, config.AlertThresholds) {
+		if !m.allowAlert(alert) {
+			continue
+		}
+		fired = append(fired, alert)
+		stats.AlertCount++
+		stats.LastAlertTime = alert.Timestamp
+		if alert.Type == AlertVolumeSpike {
+			stats.VolumeSpikeCount++
+		}
+	}
+	m.symbolStats.Store(symbol, stats)
+
+	for _, alert := range fired {
+		m.publishAlert(alert)
+	}
+}
+
+// allowAlert 检查同币种同类警报的冷却时间
+func (m *WSMonitor) allowAlert(alert Alert) bool {
+	key := alert.Symbol + "|" + alert.Type
+	m.alerts.mu.Lock()
+	defer m.alerts.mu.Unlock()
+	if m.alerts.lastFired == nil {
+		m.alerts.lastFired = make(map[string]time.Time)
+	}
+	if last, ok := m.alerts.lastFired[key]; ok && alert.Timestamp.Sub(last) < alertCooldown {
+		return false
+	}
+	m.alerts.lastFired[key] = alert.Timestamp
+	return true
+}
+
+// publishAlert 记录警报并推送给全局通道与所有订阅者
+func (m *WSMonitor) publishAlert(alert Alert) {
+	log.Printf("🚨 [%s] %s", alert.Type, alert.Message)
+
+	select {
+	case m.alertsChan <- alert:
+	default:
+	}
+
+	m.alerts.mu.Lock()
+	defer m.alerts.mu.Unlock()
+	m.alerts.recent = append(m.alerts.recent, alert)
+	if len(m.alerts.recent) > recentAlertLimit {
+		m.alerts.recent = m.alerts.recent[len(m.alerts.recent)-recentAlertLimit:]
+	}
+	for _, ch := range m.alerts.subs {
+		select {
+		case ch <- alert:
+		default:
+		}
+	}
+}
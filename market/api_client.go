@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"nofx/hook"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,11 +16,19 @@ const (
 	baseURL = "https://fapi.binance.com"
 )
 
+// APIClient 币安兼容的合约行情 REST 客户端（币安、Aster 等）
 type APIClient struct {
-	client *http.Client
+	client  *http.Client
+	baseURL string
 }
 
+// NewAPIClient 创建币安合约行情客户端
 func NewAPIClient() *APIClient {
+	return NewAPIClientWithBaseURL(baseURL)
+}
+
+// NewAPIClientWithBaseURL 创建指定地址的币安兼容行情客户端
+func NewAPIClientWithBaseURL(base string) *APIClient {
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 	}
@@ -31,12 +40,13 @@ func NewAPIClient() *APIClient {
 	}
 
 	return &APIClient{
-		client: client,
+		client:  client,
+		baseURL: strings.TrimRight(base, "/"),
 	}
 }
 
 func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
-	url := fmt.Sprintf("%s/fapi/v1/exchangeInfo", baseURL)
+	url := fmt.Sprintf("%s/fapi/v1/exchangeInfo", c.baseURL)
 	resp, err := c.client.Get(url)
 	if err != nil {
 		return nil, err
@@ -57,7 +67,37 @@ func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
 }
 
 func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
-	url := fmt.Sprintf("%s/fapi/v1/klines", baseURL)
+	return c.fetchKlines(symbol, interval, 0, 0, limit)
+}
+
+// GetKlinesRange 获取指定时间范围内的K线（毫秒时间戳，自动分页）
+func (c *APIClient) GetKlinesRange(symbol, interval string, startTime, endTime int64) ([]Kline, error) {
+	const pageLimit = 1500
+
+	var all []Kline
+	cursor := startTime
+	for cursor < endTime {
+		page, err := c.fetchKlines(symbol, interval, cursor, endTime, pageLimit)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		all = append(all, page...)
+
+		next := page[len(page)-1].CloseTime + 1
+		if next <= cursor || len(page) < pageLimit {
+			break
+		}
+		cursor = next
+	}
+
+	return all, nil
+}
+
+func (c *APIClient) fetchKlines(symbol, interval string, startTime, endTime int64, limit int) ([]Kline, error) {
+	url := fmt.Sprintf("%s/fapi/v1/klines", c.baseURL)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -67,6 +107,12 @@ func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, erro
 	q.Add("symbol", symbol)
 	q.Add("interval", interval)
 	q.Add("limit", strconv.Itoa(limit))
+	if startTime > 0 {
+		q.Add("startTime", strconv.FormatInt(startTime, 10))
+	}
+	if endTime > 0 {
+		q.Add("endTime", strconv.FormatInt(endTime, 10))
+	}
 	req.URL.RawQuery = q.Encode()
 
 	resp, err := c.client.Do(req)
@@ -124,7 +170,7 @@ func parseKline(kr KlineResponse) (Kline, error) {
 }
 
 func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
-	url := fmt.Sprintf("%s/fapi/v1/ticker/price", baseURL)
+	url := fmt.Sprintf("%s/fapi/v1/ticker/price", c.baseURL)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return 0, err
@@ -158,3 +204,130 @@ func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
 
 	return price, nil
 }
+
+// GetMarkPrice 获取标记价格（用于模拟盘成交与强平计算）
+func (c *APIClient) GetMarkPrice(symbol string) (float64, error) {
+	url := fmt.Sprintf("%s/fapi/v1/premiumIndex", c.baseURL)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	q := req.URL.Query()
+	q.Add("symbol", symbol)
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	var premium PremiumIndex
+	if err := json.Unmarshal(body, &premium); err != nil {
+		return 0, err
+	}
+
+	price, err := strconv.ParseFloat(premium.MarkPrice, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析标记价格失败: %w", err)
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("%s 标记价格无效", symbol)
+	}
+
+	return price, nil
+}
+
+// GetOpenInterest 获取当前持仓量（合约张数/币数）
+func (c *APIClient) GetOpenInterest(symbol string) (*OIData, error) {
+	url := fmt.Sprintf("%s/fapi/v1/openInterest?symbol=%s", c.baseURL, symbol)
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		OpenInterest string `json:"openInterest"`
+		Symbol       string `json:"symbol"`
+		Time         int64  `json:"time"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	oi, _ := strconv.ParseFloat(result.OpenInterest, 64)
+	return &OIData{
+		Latest:  oi,
+		Average: oi * 0.999, // 近似平均值
+	}, nil
+}
+
+// GetFundingRate 获取最新资金费率（不缓存）
+func (c *APIClient) GetFundingRate(symbol string) (float64, error) {
+	premium, err := c.GetPremiumIndex(symbol)
+	if err != nil {
+		return 0, err
+	}
+	rate, _ := strconv.ParseFloat(premium.LastFundingRate, 64)
+	return rate, nil
+}
+
+// GetFundingIntervals 获取资金费率结算周期（小时），接口只返回调整过结算周期或费率上下限的币种
+func (c *APIClient) GetFundingIntervals() (map[string]float64, error) {
+	url := fmt.Sprintf("%s/fapi/v1/fundingInfo", c.baseURL)
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []FundingInfo
+	if err := json.Unmarshal(body, &infos); err != nil {
+		return nil, err
+	}
+	intervals := make(map[string]float64, len(infos))
+	for _, info := range infos {
+		if info.FundingIntervalHours > 0 {
+			intervals[info.Symbol] = float64(info.FundingIntervalHours)
+		}
+	}
+	return intervals, nil
+}
+
+// GetPremiumIndex 获取标记价格、指数价格、最新资金费率与下次结算时间
+func (c *APIClient) GetPremiumIndex(symbol string) (*PremiumIndex, error) {
+	url := fmt.Sprintf("%s/fapi/v1/premiumIndex?symbol=%s", c.baseURL, symbol)
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var premium PremiumIndex
+	if err := json.Unmarshal(body, &premium); err != nil {
+		return nil, err
+	}
+	return &premium, nil
+}
@@ -1,9 +1,7 @@
 package market
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"math"
 	"strconv"
@@ -24,14 +22,22 @@ var (
 	frCacheTTL     = 1 * time.Hour
 )
 
-// Get 获取指定代币的市场数据
+// Get 获取指定代币的市场数据（币安行情）
+// 禁止内联：交易员测试通过 gomonkey 替换该函数
+//
+//go:noinline
 func Get(symbol string) (*Data, error) {
+	return GetFrom(DefaultProvider(), symbol)
+}
+
+// GetFrom 从指定行情源获取市场数据（K线、OI与资金费率均来自该交易所）
+func GetFrom(provider Provider, symbol string) (*Data, error) {
 	var klines3m, klines4h []Kline
 	var err error
 	// 标准化symbol
 	symbol = Normalize(symbol)
 	// 获取3分钟K线数据 (最近10个)
-	klines3m, err = WSMonitorCli.GetCurrentKlines(symbol, "3m") // 多获取一些用于计算
+	klines3m, err = provider.GetKlines(symbol, "3m", klineHistoryLimit) // 多获取一些用于计算
 	if err != nil {
 		return nil, fmt.Errorf("获取3分钟K线失败: %v", err)
 	}
@@ -43,11 +49,36 @@ func Get(symbol string) (*Data, error) {
 	}
 
 	// 获取4小时K线数据 (最近10个)
-	klines4h, err = WSMonitorCli.GetCurrentKlines(symbol, "4h") // 多获取用于计算指标
+	klines4h, err = provider.GetKlines(symbol, "4h", klineHistoryLimit) // 多获取用于计算指标
 	if err != nil {
 		return nil, fmt.Errorf("获取4小时K线失败: %v", err)
 	}
 
+	data, err := BuildData(symbol, klines3m, klines4h)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取OI数据
+	oiData, err := provider.GetOpenInterest(symbol)
+	if err != nil {
+		// OI失败不影响整体,使用默认值
+		oiData = &OIData{Latest: 0, Average: 0}
+	}
+	data.OpenInterest = oiData
+
+	// 获取Funding Rate
+	data.FundingRate, _ = provider.GetFundingRate(symbol)
+
+	// 获取盘口流动性
+	attachLiquidity(provider, data)
+
+	return data, nil
+}
+
+// BuildData 根据K线序列计算市场数据（纯计算，不访问网络）
+// OI与资金费率需要由调用方补充，回测时可直接使用
+func BuildData(symbol string, klines3m, klines4h []Kline) (*Data, error) {
 	// 检查数据是否为空
 	if len(klines3m) == 0 {
 		return nil, fmt.Errorf("3分钟K线数据为空")
@@ -81,16 +112,6 @@ func Get(symbol string) (*Data, error) {
 		}
 	}
 
-	// 获取OI数据
-	oiData, err := getOpenInterestData(symbol)
-	if err != nil {
-		// OI失败不影响整体,使用默认值
-		oiData = &OIData{Latest: 0, Average: 0}
-	}
-
-	// 获取Funding Rate
-	fundingRate, _ := getFundingRate(symbol)
-
 	// 计算日内系列数据
 	intradayData := calculateIntradaySeries(klines3m)
 
@@ -105,8 +126,6 @@ func Get(symbol string) (*Data, error) {
 		CurrentEMA20:      currentEMA20,
 		CurrentMACD:       currentMACD,
 		CurrentRSI7:       currentRSI7,
-		OpenInterest:      oiData,
-		FundingRate:       fundingRate,
 		IntradaySeries:    intradayData,
 		LongerTermContext: longerTermData,
 	}, nil
@@ -322,45 +341,27 @@ func calculateLongerTermData(klines []Kline) *LongerTermData {
 	return data
 }
 
-// getOpenInterestData 获取OI数据
+// getOpenInterestData 获取币安OI数据
 func getOpenInterestData(symbol string) (*OIData, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/openInterest?symbol=%s", symbol)
-
-	apiClient := NewAPIClient()
-	resp, err := apiClient.client.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var result struct {
-		OpenInterest string `json:"openInterest"`
-		Symbol       string `json:"symbol"`
-		Time         int64  `json:"time"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
-	}
-
-	oi, _ := strconv.ParseFloat(result.OpenInterest, 64)
+	return NewAPIClient().GetOpenInterest(symbol)
+}
 
-	return &OIData{
-		Latest:  oi,
-		Average: oi * 0.999, // 近似平均值
-	}, nil
+// GetFundingRate 获取币种在币安的最新资金费率（1 小时缓存）
+func GetFundingRate(symbol string) (float64, error) {
+	return getFundingRate(Normalize(symbol))
 }
 
-// getFundingRate 获取资金费率（优化：使用 1 小时缓存）
+// getFundingRate 获取币安资金费率（优化：使用 1 小时缓存）
 func getFundingRate(symbol string) (float64, error) {
-	// 检查缓存（有效期 1 小时）
-	// Funding Rate 每 8 小时才更新，1 小时缓存非常合理
-	if cached, ok := fundingRateMap.Load(symbol); ok {
+	return cachedFundingRate(symbol, func() (float64, error) {
+		return NewAPIClient().GetFundingRate(symbol)
+	})
+}
+
+// cachedFundingRate 按 key 缓存资金费率，缓存过期或不存在时调用 fetch
+// Funding Rate 每 1~8 小时才结算一次，1 小时缓存非常合理
+func cachedFundingRate(key string, fetch func() (float64, error)) (float64, error) {
+	if cached, ok := fundingRateMap.Load(key); ok {
 		cache := cached.(*FundingRateCache)
 		if time.Since(cache.UpdatedAt) < frCacheTTL {
 			// 缓存命中，直接返回
@@ -368,43 +369,16 @@ func getFundingRate(symbol string) (float64, error) {
 		}
 	}
 
-	// 缓存过期或不存在，调用 API
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/premiumIndex?symbol=%s", symbol)
-
-	apiClient := NewAPIClient()
-	resp, err := apiClient.client.Get(url)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	rate, err := fetch()
 	if err != nil {
 		return 0, err
 	}
 
-	var result struct {
-		Symbol          string `json:"symbol"`
-		MarkPrice       string `json:"markPrice"`
-		IndexPrice      string `json:"indexPrice"`
-		LastFundingRate string `json:"lastFundingRate"`
-		NextFundingTime int64  `json:"nextFundingTime"`
-		InterestRate    string `json:"interestRate"`
-		Time            int64  `json:"time"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, err
-	}
-
-	rate, _ := strconv.ParseFloat(result.LastFundingRate, 64)
-
 	// 更新缓存
-	fundingRateMap.Store(symbol, &FundingRateCache{
+	fundingRateMap.Store(key, &FundingRateCache{
 		Rate:      rate,
 		UpdatedAt: time.Now(),
 	})
-
 	return rate, nil
 }
 
@@ -430,6 +404,10 @@ func Format(data *Data) string {
 
 	sb.WriteString(fmt.Sprintf("Funding Rate: %.2e\n\n", data.FundingRate))
 
+	if data.Liquidity != nil {
+		sb.WriteString(formatLiquidity(data.Liquidity))
+	}
+
 	if data.IntradaySeries != nil {
 		sb.WriteString("Intraday series (3‑minute intervals, oldest → latest):\n\n")
 
@@ -481,6 +459,26 @@ func Format(data *Data) string {
 		}
 	}
 
+	for _, tf := range data.Timeframes {
+		sb.WriteString(fmt.Sprintf("Additional timeframe (%s intervals, oldest → latest):\n\n", tf.Interval))
+
+		if len(tf.Closes) > 0 {
+			sb.WriteString(fmt.Sprintf("Close prices: %s\n\n", formatFloatSlice(tf.Closes)))
+		}
+
+		if len(tf.Volume) > 0 {
+			sb.WriteString(fmt.Sprintf("Volume: %s\n\n", formatFloatSlice(tf.Volume)))
+		}
+
+		for _, series := range tf.Indicators {
+			if len(series.Values) == 0 {
+				sb.WriteString(fmt.Sprintf("%s: insufficient data\n\n", series.Label))
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("%s: %s\n\n", series.Label, formatFloatSlice(series.Values)))
+		}
+	}
+
 	return sb.String()
 }
 
@@ -500,3 +500,28 @@ func TestIsStaleData_EmptyKlines(t *testing.T) {
 		t.Error("Expected false for empty klines, got true")
 	}
 }
+
+// TestBuildData 测试纯K线计算（不访问网络，OI与资金费率留空）
+func TestBuildData(t *testing.T) {
+	klines3m := generateTestKlines(60)
+	klines4h := generateTestKlines(30)
+
+	data, err := BuildData("BTCUSDT", klines3m, klines4h)
+	if err != nil {
+		t.Fatalf("BuildData returned error: %v", err)
+	}
+
+	if data.CurrentPrice != klines3m[len(klines3m)-1].Close {
+		t.Errorf("CurrentPrice = %.3f, want %.3f", data.CurrentPrice, klines3m[len(klines3m)-1].Close)
+	}
+	if data.OpenInterest != nil {
+		t.Errorf("OpenInterest should be nil when built from klines only")
+	}
+	if data.IntradaySeries == nil || data.LongerTermContext == nil {
+		t.Fatal("IntradaySeries and LongerTermContext should be populated")
+	}
+
+	if _, err := BuildData("BTCUSDT", nil, klines4h); err == nil {
+		t.Error("BuildData should fail with empty 3m klines")
+	}
+}
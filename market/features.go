@@ -0,0 +1,157 @@
+package market
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// 警报类型
+const (
+	AlertVolumeSpike   = "volume_spike"   // 成交量突增（当前量 / 20周期均量）
+	AlertPriceChange   = "price_change"   // 15分钟价格异动
+	AlertVolumeTrend   = "volume_trend"   // 成交量趋势放大（5周期均量 / 20周期均量）
+	AlertRSIOverbought = "rsi_overbought" // RSI超买
+	AlertRSIOversold   = "rsi_oversold"   // RSI超卖
+)
+
+// featureMinKlines 计算特征所需的最少K线数量（SMA20 + 前一根用于成交量比较）
+const featureMinKlines = 21
+
+// barsPer 指定时长对应的3分钟K线数量
+func barsPer(d time.Duration) int {
+	return int(d / (3 * time.Minute))
+}
+
+// CalculateFeatures 基于3分钟K线计算币种特征（纯计算，价格变化与波动率均为小数比例）
+func CalculateFeatures(symbol string, klines []Kline) (*SymbolFeatures, error) {
+	if len(klines) < featureMinKlines {
+		return nil, fmt.Errorf("%s K线数量不足: %d < %d", symbol, len(klines), featureMinKlines)
+	}
+
+	last := klines[len(klines)-1]
+	f := &SymbolFeatures{
+		Symbol:           symbol,
+		Timestamp:        time.UnixMilli(last.CloseTime),
+		Price:            last.Close,
+		PriceChange15Min: priceChange(klines, barsPer(15*time.Minute)),
+		PriceChange1H:    priceChange(klines, barsPer(time.Hour)),
+		PriceChange4H:    priceChange(klines, barsPer(4*time.Hour)),
+		Volume:           last.Volume,
+		RSI14:            calculateRSI(klines, 14),
+	}
+
+	// 成交量比率：当前量相对此前N根均量，趋势为近5根均量相对近20根均量
+	prev := klines[:len(klines)-1]
+	f.VolumeRatio5 = ratio(last.Volume, averageVolume(prev[len(prev)-5:]))
+	f.VolumeRatio20 = ratio(last.Volume, averageVolume(prev[len(prev)-20:]))
+	f.VolumeTrend = ratio(averageVolume(klines[len(klines)-5:]), averageVolume(klines[len(klines)-20:]))
+
+	c := closes(klines)
+	f.SMA5 = smaSeries(c[len(c)-5:], 5)[4]
+	f.SMA10 = smaSeries(c[len(c)-10:], 10)[9]
+	f.SMA20 = smaSeries(c[len(c)-20:], 20)[19]
+
+	// 近20根的区间位置与高低比
+	window := klines[len(klines)-20:]
+	high, low := highLow(window, len(window)-1, len(window))
+	f.HighLowRatio = ratio(high, low)
+	if high > low {
+		f.PositionInRange = (last.Close - low) / (high - low)
+	} else {
+		f.PositionInRange = 0.5
+	}
+
+	// 近20根收益率的标准差
+	returns := make([]float64, 0, 20)
+	for i := len(klines) - 20; i < len(klines); i++ {
+		if klines[i-1].Close > 0 {
+			returns = append(returns, klines[i].Close/klines[i-1].Close-1)
+		}
+	}
+	f.Volatility20 = stdDev(returns)
+
+	return f, nil
+}
+
+// CheckAlerts 检查特征是否触发警报阈值
+func CheckAlerts(f *SymbolFeatures, th AlertThresholds) []Alert {
+	var alerts []Alert
+	add := func(typ string, value, threshold float64, format string, args ...interface{}) {
+		alerts = append(alerts, Alert{
+			Type:      typ,
+			Symbol:    f.Symbol,
+			Value:     value,
+			Threshold: threshold,
+			Message:   fmt.Sprintf(format, args...),
+			Timestamp: f.Timestamp,
+		})
+	}
+
+	if th.VolumeSpike > 0 && f.VolumeRatio20 >= th.VolumeSpike {
+		add(AlertVolumeSpike, f.VolumeRatio20, th.VolumeSpike, "%s 成交量突增 %.1f 倍（20周期均量）", f.Symbol, f.VolumeRatio20)
+	}
+	if th.PriceChange15Min > 0 && math.Abs(f.PriceChange15Min) >= th.PriceChange15Min {
+		add(AlertPriceChange, f.PriceChange15Min, th.PriceChange15Min, "%s 15分钟价格变化 %+.2f%%", f.Symbol, f.PriceChange15Min*100)
+	}
+	if th.VolumeTrend > 0 && f.VolumeTrend >= th.VolumeTrend {
+		add(AlertVolumeTrend, f.VolumeTrend, th.VolumeTrend, "%s 近5周期成交量为20周期均量的 %.1f 倍", f.Symbol, f.VolumeTrend)
+	}
+	if th.RSIOverbought > 0 && f.RSI14 >= th.RSIOverbought {
+		add(AlertRSIOverbought, f.RSI14, th.RSIOverbought, "%s RSI14 超买: %.1f", f.Symbol, f.RSI14)
+	}
+	if th.RSIOversold > 0 && f.RSI14 > 0 && f.RSI14 <= th.RSIOversold {
+		add(AlertRSIOversold, f.RSI14, th.RSIOversold, "%s RSI14 超卖: %.1f", f.Symbol, f.RSI14)
+	}
+	return alerts
+}
+
+// featureScore 币种活跃度评分：1小时涨跌幅(%) + 成交量放大倍数 + RSI偏离程度
+func featureScore(f *SymbolFeatures) float64 {
+	return math.Abs(f.PriceChange1H)*100 + f.VolumeRatio20*5 + math.Abs(f.RSI14-50)/5
+}
+
+// priceChange 最新收盘价相对 n 根K线前的变化比例（数据不足时为0）
+func priceChange(klines []Kline, n int) float64 {
+	if len(klines) <= n || klines[len(klines)-1-n].Close <= 0 {
+		return 0
+	}
+	return klines[len(klines)-1].Close/klines[len(klines)-1-n].Close - 1
+}
+
+// averageVolume 平均成交量
+func averageVolume(klines []Kline) float64 {
+	if len(klines) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, k := range klines {
+		sum += k.Volume
+	}
+	return sum / float64(len(klines))
+}
+
+// ratio 安全除法（分母为0时返回0）
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// stdDev 总体标准差
+func stdDev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
@@ -0,0 +1,166 @@
+package market
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// flatKlines 生成价格与成交量恒定的3分钟K线
+func flatKlines(count int, price, volume float64) []Kline {
+	klines := make([]Kline, count)
+	for i := range klines {
+		klines[i] = Kline{
+			OpenTime:  int64(i * 180000),
+			Open:      price,
+			High:      price * 1.001,
+			Low:       price * 0.999,
+			Close:     price,
+			Volume:    volume,
+			CloseTime: int64((i+1)*180000 - 1),
+		}
+	}
+	return klines
+}
+
+// zigzagKlines 生成小幅来回波动的K线（RSI约为50，不触发超买超卖）
+func zigzagKlines(count int, volume float64) []Kline {
+	klines := flatKlines(count, 100, volume)
+	for i := range klines {
+		if i%2 == 1 {
+			klines[i].Close = 100.1
+		}
+	}
+	return klines
+}
+
+// TestCalculateFeatures 成交量比率、价格变化与区间位置
+func TestCalculateFeatures(t *testing.T) {
+	klines := flatKlines(30, 100, 1000)
+	last := &klines[len(klines)-1]
+	last.Close = 106
+	last.High = 107
+	last.Volume = 5000
+
+	f, err := CalculateFeatures("BTCUSDT", klines)
+	if err != nil {
+		t.Fatalf("CalculateFeatures returned error: %v", err)
+	}
+	if f.VolumeRatio20 != 5 || f.VolumeRatio5 != 5 {
+		t.Errorf("volume ratios = %.2f/%.2f, want 5/5", f.VolumeRatio5, f.VolumeRatio20)
+	}
+	if math.Abs(f.PriceChange15Min-0.06) > 1e-9 || math.Abs(f.PriceChange1H-0.06) > 1e-9 {
+		t.Errorf("price change 15m/1h = %.4f/%.4f, want 0.06", f.PriceChange15Min, f.PriceChange1H)
+	}
+	if f.PriceChange4H != 0 {
+		t.Errorf("PriceChange4H = %.4f, want 0 with only 30 klines", f.PriceChange4H)
+	}
+	if f.SMA5 != (100*4+106)/5.0 {
+		t.Errorf("SMA5 = %.3f, want %.3f", f.SMA5, (100*4+106)/5.0)
+	}
+	if want := (106 - 99.9) / (107 - 99.9); math.Abs(f.PositionInRange-want) > 1e-9 {
+		t.Errorf("PositionInRange = %.4f, want %.4f", f.PositionInRange, want)
+	}
+	if f.Volatility20 <= 0 || f.RSI14 != 100 {
+		t.Errorf("Volatility20 = %.4f, RSI14 = %.1f, want >0 and 100", f.Volatility20, f.RSI14)
+	}
+	if !f.Timestamp.Equal(time.UnixMilli(last.CloseTime)) {
+		t.Errorf("Timestamp = %v, want last kline close time", f.Timestamp)
+	}
+
+	if _, err := CalculateFeatures("BTCUSDT", klines[:20]); err == nil {
+		t.Error("CalculateFeatures should fail with fewer than 21 klines")
+	}
+}
+
+// TestCheckAlerts 超过阈值时触发对应类型的警报
+func TestCheckAlerts(t *testing.T) {
+	f := &SymbolFeatures{Symbol: "BTCUSDT", VolumeRatio20: 4, PriceChange15Min: -0.06, VolumeTrend: 1.2, RSI14: 25}
+	alerts := CheckAlerts(f, config.AlertThresholds)
+
+	types := make(map[string]bool)
+	for _, a := range alerts {
+		types[a.Type] = true
+	}
+	for _, want := range []string{AlertVolumeSpike, AlertPriceChange, AlertRSIOversold} {
+		if !types[want] {
+			t.Errorf("expected %s alert, got %+v", want, alerts)
+		}
+	}
+	if types[AlertVolumeTrend] || types[AlertRSIOverbought] {
+		t.Errorf("unexpected alerts: %+v", alerts)
+	}
+
+	if alerts := CheckAlerts(&SymbolFeatures{Symbol: "BTCUSDT", RSI14: 50}, config.AlertThresholds); len(alerts) != 0 {
+		t.Errorf("quiet market should not alert, got %+v", alerts)
+	}
+}
+
+// TestWSMonitor_AlertsOnKlineClose 收盘K线计算特征并推送警报，冷却期内不重复
+func TestWSMonitor_AlertsOnKlineClose(t *testing.T) {
+	m := &WSMonitor{alertsChan: make(chan Alert, 10)}
+	m.getKlineDataMap("3m").Store("BTCUSDT", zigzagKlines(30, 1000))
+	m.getKlineDataMap("3m").Store("ETHUSDT", zigzagKlines(30, 1000))
+	alerts, unsubscribe := m.SubscribeAlerts(10)
+	defer unsubscribe()
+
+	closeKline := func(symbol string, index int, volume string) {
+		var ws KlineWSData
+		ws.Kline.StartTime = int64(index * 180000)
+		ws.Kline.CloseTime = int64((index+1)*180000 - 1)
+		ws.Kline.ClosePrice = "100"
+		ws.Kline.HighPrice = "100.1"
+		ws.Kline.LowPrice = "99.9"
+		ws.Kline.Volume = volume
+		ws.Kline.IsFinal = true
+		m.processKlineUpdate(symbol, ws, "3m")
+	}
+
+	// 未收盘的推送不计算特征
+	var ws KlineWSData
+	ws.Kline.StartTime = 30 * 180000
+	ws.Kline.ClosePrice = "100"
+	ws.Kline.Volume = "9000"
+	m.processKlineUpdate("BTCUSDT", ws, "3m")
+	if _, ok := m.GetFeatures("BTCUSDT"); ok {
+		t.Fatal("features should only update on closed klines")
+	}
+
+	closeKline("BTCUSDT", 30, "9000")
+	select {
+	case a := <-alerts:
+		if a.Type != AlertVolumeSpike || a.Symbol != "BTCUSDT" {
+			t.Errorf("unexpected alert: %+v", a)
+		}
+	default:
+		t.Fatal("expected a volume spike alert")
+	}
+	if len(m.Alerts()) != 1 {
+		t.Errorf("global alert channel should receive the alert too")
+	}
+	if f, ok := m.GetFeatures("btc"); !ok || f.VolumeRatio20 != 9 {
+		t.Errorf("GetFeatures = %+v, %v", f, ok)
+	}
+
+	// 冷却期内（下一根K线）同类警报不再推送，新类型（成交量趋势）照常推送
+	closeKline("BTCUSDT", 31, "9000")
+	if len(alerts) != 1 {
+		t.Fatalf("expected only the volume trend alert, got %d", len(alerts))
+	}
+	if a := <-alerts; a.Type != AlertVolumeTrend {
+		t.Errorf("unexpected alert during cooldown: %+v", a)
+	}
+
+	// ETH 放量更小，活跃度评分排在后面
+	closeKline("ETHUSDT", 30, "4000")
+	since := time.UnixMilli(0)
+	if got := m.AlertSymbols(since, 0); len(got) != 2 || got[0] != "BTCUSDT" || got[1] != "ETHUSDT" {
+		t.Errorf("AlertSymbols = %v, want [BTCUSDT ETHUSDT]", got)
+	}
+	if got := m.AlertSymbols(since, 1); len(got) != 1 {
+		t.Errorf("AlertSymbols limit = %v, want 1 symbol", got)
+	}
+	if got := m.RecentAlerts(since); len(got) != 3 {
+		t.Errorf("RecentAlerts = %d, want 3", len(got))
+	}
+}
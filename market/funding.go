@@ -0,0 +1,401 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 跨交易所资金费率采集参数
+const (
+	defaultFundingSampleInterval = 5 * time.Minute // 采样间隔
+	defaultFundingRetention      = 72 * time.Hour  // 历史保留时长
+	defaultFundingIntervalHours  = 8               // 币安/Aster 默认结算周期（fundingInfo 未列出的币种）
+	requestedFundingTTL          = 24 * time.Hour  // 外部请求的币种超过该时长未再请求则停止采集
+	maxRequestedFundingSymbols   = 50              // 外部请求的币种最多同时采集的数量
+)
+
+// FundingSnapshot 单个交易所某一时刻的资金费率与价格
+type FundingSnapshot struct {
+	Venue           string    `json:"venue"`
+	Symbol          string    `json:"symbol"`
+	FundingRate     float64   `json:"funding_rate"`   // 交易所原始费率（每个结算周期）
+	IntervalHours   float64   `json:"interval_hours"` // 结算周期（小时）
+	Rate8h          float64   `json:"rate_8h"`        // 折算为8小时的费率，用于跨交易所比较
+	NextFundingTime time.Time `json:"next_funding_time"`
+	MarkPrice       float64   `json:"mark_price"`
+	IndexPrice      float64   `json:"index_price"`
+	BasisPct        float64   `json:"basis_pct"` // 标记价格相对指数价格的偏离（%）
+	Time            time.Time `json:"time"`
+}
+
+// FundingDivergence 同一币种的跨交易所资金费率与价格差异
+type FundingDivergence struct {
+	Symbol         string            `json:"symbol"`
+	Venues         []FundingSnapshot `json:"venues"`           // 各交易所最新数据（按8小时费率从低到高）
+	SpreadRate8h   float64           `json:"spread_rate_8h"`   // 最高与最低8小时费率之差
+	HighVenue      string            `json:"high_venue"`       // 费率最高的交易所（做空收取资金费）
+	LowVenue       string            `json:"low_venue"`        // 费率最低的交易所（做多收取资金费）
+	PriceSpreadPct float64           `json:"price_spread_pct"` // 各交易所标记价格最大差（相对最低价，%）
+}
+
+// fundingInfoProvider 提供资金费率结算信息与标记价格的行情源
+type fundingInfoProvider interface {
+	GetFundingInfo(symbol string) (*FundingSnapshot, error)
+}
+
+// newFundingSnapshot 计算8小时折算费率与基差
+func newFundingSnapshot(venue, symbol string, rate, intervalHours float64, next time.Time, mark, index float64) *FundingSnapshot {
+	s := &FundingSnapshot{
+		Venue:           venue,
+		Symbol:          Normalize(symbol),
+		FundingRate:     rate,
+		IntervalHours:   intervalHours,
+		NextFundingTime: next,
+		MarkPrice:       mark,
+		IndexPrice:      index,
+		Time:            time.Now(),
+	}
+	if intervalHours > 0 {
+		s.Rate8h = rate * 8 / intervalHours
+	}
+	if index > 0 {
+		s.BasisPct = (mark - index) / index * 100
+	}
+	return s
+}
+
+// premiumSnapshot 将 premiumIndex 转换为资金费率快照（币安与 Aster 通用）
+func premiumSnapshot(venue string, premium *PremiumIndex, intervalHours float64) *FundingSnapshot {
+	rate, _ := strconv.ParseFloat(premium.LastFundingRate, 64)
+	mark, _ := strconv.ParseFloat(premium.MarkPrice, 64)
+	index, _ := strconv.ParseFloat(premium.IndexPrice, 64)
+	return newFundingSnapshot(venue, premium.Symbol, rate, intervalHours,
+		time.UnixMilli(premium.NextFundingTime), mark, index)
+}
+
+// fundingIntervalCache 缓存 /fapi/v1/fundingInfo 返回的结算周期（按上线币种列表的周期刷新）
+type fundingIntervalCache struct {
+	mu        sync.Mutex
+	intervals map[string]float64
+	updatedAt time.Time
+}
+
+// get 返回币种的结算周期（小时），未列出的币种使用默认8小时；刷新失败时沿用旧数据，下个周期再试
+func (c *fundingIntervalCache) get(client *APIClient, symbol string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.updatedAt.IsZero() || time.Since(c.updatedAt) >= symbolListTTL {
+		intervals, err := client.GetFundingIntervals()
+		if err != nil {
+			log.Printf("⚠️  获取资金费率结算周期失败（沿用上次结果，未列出的币种按8小时）: %v", err)
+		} else {
+			c.intervals = intervals
+		}
+		c.updatedAt = time.Now()
+	}
+	if hours, ok := c.intervals[symbol]; ok {
+		return hours
+	}
+	return defaultFundingIntervalHours
+}
+
+// GetFundingInfo 获取资金费率、下次结算时间、结算周期与标记价格
+func (p *BinanceProvider) GetFundingInfo(symbol string) (*FundingSnapshot, error) {
+	client := NewAPIClient()
+	premium, err := client.GetPremiumIndex(Normalize(symbol))
+	if err != nil {
+		return nil, err
+	}
+	return premiumSnapshot(ProviderBinance, premium, p.funding.get(client, premium.Symbol)), nil
+}
+
+// GetFundingInfo 获取资金费率、下次结算时间、结算周期与标记价格
+func (p *AsterProvider) GetFundingInfo(symbol string) (*FundingSnapshot, error) {
+	premium, err := p.client.GetPremiumIndex(Normalize(symbol))
+	if err != nil {
+		return nil, err
+	}
+	return premiumSnapshot(ProviderAster, premium, p.funding.get(p.client, premium.Symbol)), nil
+}
+
+// GetFundingInfo 获取资金费率与标记价格（Hyperliquid 每小时整点结算，指数价格为预言机价格）
+func (p *HyperliquidProvider) GetFundingInfo(symbol string) (*FundingSnapshot, error) {
+	ctx, err := p.assetCtx(symbol)
+	if err != nil {
+		return nil, err
+	}
+	next := time.Now().Truncate(time.Hour).Add(time.Hour)
+	return newFundingSnapshot(ProviderHyperliquid, symbol, ctx.Funding, 1, next, ctx.MarkPrice, ctx.OraclePrice), nil
+}
+
+// FundingCollector 定期采集各交易所资金费率与标记价格，保存滚动历史
+type FundingCollector struct {
+	providers []Provider
+	interval  time.Duration
+	retention time.Duration
+
+	mu        sync.RWMutex
+	symbols   map[string]bool
+	requested map[string]time.Time         // 外部请求的币种 -> 最近请求时间（过期后停止采集）
+	history   map[string][]FundingSnapshot // 币种 -> 按时间正序的快照（各交易所混合）
+	stop      chan struct{}
+}
+
+var (
+	defaultFundingCollector   *FundingCollector
+	defaultFundingCollectorMu sync.RWMutex
+)
+
+// SetFundingCollector 设置全局资金费率采集器（API 与交易员共用），传 nil 表示关闭
+func SetFundingCollector(collector *FundingCollector) {
+	defaultFundingCollectorMu.Lock()
+	defer defaultFundingCollectorMu.Unlock()
+	defaultFundingCollector = collector
+}
+
+// GetFundingCollector 返回全局资金费率采集器（未启用时为 nil）
+func GetFundingCollector() *FundingCollector {
+	defaultFundingCollectorMu.RLock()
+	defer defaultFundingCollectorMu.RUnlock()
+	return defaultFundingCollector
+}
+
+// NewFundingCollector 创建资金费率采集器（不支持资金费率信息的行情源会被忽略）
+func NewFundingCollector(providers ...Provider) *FundingCollector {
+	c := &FundingCollector{
+		interval:  defaultFundingSampleInterval,
+		retention: defaultFundingRetention,
+		symbols:   make(map[string]bool),
+		requested: make(map[string]time.Time),
+		history:   make(map[string][]FundingSnapshot),
+	}
+	for _, p := range providers {
+		if _, ok := p.(fundingInfoProvider); ok {
+			c.providers = append(c.providers, p)
+		}
+	}
+	return c
+}
+
+// Track 添加需要采集的币种（重复添加无影响，下次采样生效）
+func (c *FundingCollector) Track(symbols ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, symbol := range symbols {
+		if symbol = Normalize(symbol); symbol != "" {
+			c.symbols[symbol] = true
+		}
+	}
+}
+
+// TrackRequested 采集外部（API）请求的币种，返回因数量上限未采集的币种
+// 这些币种在 requestedFundingTTL 内未再请求即停止采集，同时最多采集 maxRequestedFundingSymbols 个
+func (c *FundingCollector) TrackRequested(symbols ...string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var rejected []string
+	for _, symbol := range symbols {
+		symbol = Normalize(symbol)
+		if symbol == "" || c.symbols[symbol] {
+			continue
+		}
+		if _, ok := c.requested[symbol]; !ok && len(c.requested) >= maxRequestedFundingSymbols {
+			rejected = append(rejected, symbol)
+			continue
+		}
+		c.requested[symbol] = now
+	}
+	return rejected
+}
+
+// Start 立即采样一次，之后按间隔定期采样
+func (c *FundingCollector) Start() {
+	c.mu.Lock()
+	if c.stop != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.stop = make(chan struct{})
+	stop := c.stop
+	c.mu.Unlock()
+
+	log.Printf("💸 资金费率采集器启动（%d 个交易所，每 %v 采样）", len(c.providers), c.interval)
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		c.Collect(time.Now())
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				c.Collect(now)
+			}
+		}
+	}()
+}
+
+// Stop 停止定期采样
+func (c *FundingCollector) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+// Collect 采集所有跟踪币种在各交易所的资金费率（未上线的币种跳过），并清理过期的请求币种与历史
+func (c *FundingCollector) Collect(now time.Time) {
+	c.mu.Lock()
+	symbols := make([]string, 0, len(c.symbols)+len(c.requested))
+	for symbol := range c.symbols {
+		symbols = append(symbols, symbol)
+	}
+	for symbol, requestedAt := range c.requested {
+		if now.Sub(requestedAt) > requestedFundingTTL {
+			delete(c.requested, symbol)
+		} else if !c.symbols[symbol] {
+			symbols = append(symbols, symbol)
+		}
+	}
+	c.mu.Unlock()
+	sort.Strings(symbols)
+
+	var (
+		wg        sync.WaitGroup
+		resultsMu sync.Mutex
+		results   []FundingSnapshot
+	)
+	for _, provider := range c.providers {
+		wg.Add(1)
+		go func(provider Provider) {
+			defer wg.Done()
+			source := provider.(fundingInfoProvider)
+			for _, symbol := range symbols {
+				if !IsListed(provider, symbol) {
+					continue
+				}
+				snapshot, err := source.GetFundingInfo(symbol)
+				if err != nil {
+					log.Printf("⚠️  获取 %s %s 资金费率失败: %v", provider.Name(), symbol, err)
+					continue
+				}
+				snapshot.Time = now
+				resultsMu.Lock()
+				results = append(results, *snapshot)
+				resultsMu.Unlock()
+			}
+		}(provider)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, snapshot := range results {
+		c.history[snapshot.Symbol] = append(c.history[snapshot.Symbol], snapshot)
+	}
+	cutoff := now.Add(-c.retention)
+	for symbol, snapshots := range c.history {
+		i := sort.Search(len(snapshots), func(i int) bool { return !snapshots[i].Time.Before(cutoff) })
+		if i == len(snapshots) {
+			delete(c.history, symbol)
+		} else if i > 0 {
+			c.history[symbol] = append([]FundingSnapshot(nil), snapshots[i:]...)
+		}
+	}
+}
+
+// Symbols 返回有采集数据的币种
+func (c *FundingCollector) Symbols() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	symbols := make([]string, 0, len(c.history))
+	for symbol := range c.history {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// History 返回 since 之后的历史快照（按时间正序）
+func (c *FundingCollector) History(symbol string, since time.Time) []FundingSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snapshots := c.history[Normalize(symbol)]
+	i := sort.Search(len(snapshots), func(i int) bool { return !snapshots[i].Time.Before(since) })
+	return append([]FundingSnapshot(nil), snapshots[i:]...)
+}
+
+// Latest 返回各交易所最新一次快照（按8小时费率从低到高）
+func (c *FundingCollector) Latest(symbol string) []FundingSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snapshots := c.history[Normalize(symbol)]
+	seen := make(map[string]bool)
+	var latest []FundingSnapshot
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if s := snapshots[i]; !seen[s.Venue] {
+			seen[s.Venue] = true
+			latest = append(latest, s)
+		}
+	}
+	sort.Slice(latest, func(i, j int) bool { return latest[i].Rate8h < latest[j].Rate8h })
+	return latest
+}
+
+// Divergence 计算币种的跨交易所差异（少于两个交易所有数据时返回 nil）
+func (c *FundingCollector) Divergence(symbol string) *FundingDivergence {
+	latest := c.Latest(symbol)
+	if len(latest) < 2 {
+		return nil
+	}
+	d := &FundingDivergence{
+		Symbol:       Normalize(symbol),
+		Venues:       latest,
+		SpreadRate8h: latest[len(latest)-1].Rate8h - latest[0].Rate8h,
+		HighVenue:    latest[len(latest)-1].Venue,
+		LowVenue:     latest[0].Venue,
+	}
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, s := range latest {
+		if s.MarkPrice > 0 {
+			low, high = math.Min(low, s.MarkPrice), math.Max(high, s.MarkPrice)
+		}
+	}
+	if low > 0 && high > low && !math.IsInf(low, 1) {
+		d.PriceSpreadPct = (high - low) / low * 100
+	}
+	return d
+}
+
+// Divergences 批量计算跨交易所差异（跳过数据不足的币种）
+func (c *FundingCollector) Divergences(symbols []string) map[string]*FundingDivergence {
+	result := make(map[string]*FundingDivergence)
+	for _, symbol := range symbols {
+		if d := c.Divergence(symbol); d != nil {
+			result[d.Symbol] = d
+		}
+	}
+	return result
+}
+
+// FormatFundingDivergence 格式化跨交易所资金费率（紧凑格式，节省 Token）
+func FormatFundingDivergence(d *FundingDivergence) string {
+	parts := make([]string, len(d.Venues))
+	for i, s := range d.Venues {
+		parts[i] = fmt.Sprintf("%s %.4f%%/8h (mark %s, basis %+.3f%%)",
+			s.Venue, s.Rate8h*100, formatPriceWithDynamicPrecision(s.MarkPrice), s.BasisPct)
+	}
+	return fmt.Sprintf("%s: %s | funding spread %.4f%%/8h (high %s, low %s), mark price spread %.3f%%",
+		d.Symbol, strings.Join(parts, "; "), d.SpreadRate8h*100, d.HighVenue, d.LowVenue, d.PriceSpreadPct)
+}
@@ -0,0 +1,163 @@
+package market
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeFundingProvider 返回固定资金费率与标记价格的行情源
+type fakeFundingProvider struct {
+	fakeProvider
+	name          string
+	rate          float64
+	intervalHours float64
+	mark          float64
+	calls         int
+}
+
+func (f *fakeFundingProvider) Name() string { return f.name }
+
+func (f *fakeFundingProvider) GetFundingInfo(symbol string) (*FundingSnapshot, error) {
+	f.calls++
+	return newFundingSnapshot(f.name, symbol, f.rate, f.intervalHours, time.Time{}, f.mark, f.mark), nil
+}
+
+// TestFundingCollector 采样、未上线币种跳过、过期清理与跨交易所差异
+func TestFundingCollector(t *testing.T) {
+	binance := &fakeFundingProvider{fakeProvider: fakeProvider{symbols: []string{"BTCUSDT", "PEPEUSDT"}},
+		name: "binance", rate: 0.0001, intervalHours: 8, mark: 100}
+	hyperliquid := &fakeFundingProvider{fakeProvider: fakeProvider{symbols: []string{"BTCUSDT"}},
+		name: "hyperliquid", rate: 0.00005, intervalHours: 1, mark: 100.5}
+	collector := NewFundingCollector(binance, hyperliquid, &fakeProvider{})
+	if len(collector.providers) != 2 {
+		t.Fatalf("providers without funding info should be ignored, got %d", len(collector.providers))
+	}
+
+	collector.Track("btc", "PEPEUSDT")
+	start := time.Unix(1_700_000_000, 0)
+	collector.Collect(start)
+	collector.Collect(start.Add(time.Hour))
+
+	if hyperliquid.calls != 2 {
+		t.Errorf("unlisted symbols should be skipped, hyperliquid called %d times", hyperliquid.calls)
+	}
+	if got := collector.History("BTCUSDT", start.Add(time.Minute)); len(got) != 2 {
+		t.Errorf("History since second sample = %d snapshots, want 2", len(got))
+	}
+
+	d := collector.Divergence("BTCUSDT")
+	if d == nil {
+		t.Fatal("Divergence returned nil with two venues")
+	}
+	// Hyperliquid 每小时 0.005% 折算为 0.04%/8h，高于币安的 0.01%
+	if d.HighVenue != "hyperliquid" || d.LowVenue != "binance" || math.Abs(d.SpreadRate8h-0.0003) > 1e-12 {
+		t.Errorf("unexpected divergence: %+v", d)
+	}
+	if math.Abs(d.PriceSpreadPct-0.5) > 1e-9 {
+		t.Errorf("PriceSpreadPct = %v, want 0.5", d.PriceSpreadPct)
+	}
+	if collector.Divergence("PEPEUSDT") != nil {
+		t.Error("single-venue symbol should not report divergence")
+	}
+	if got := collector.Divergences([]string{"BTCUSDT", "PEPEUSDT"}); len(got) != 1 || got["BTCUSDT"] == nil {
+		t.Errorf("Divergences = %v", got)
+	}
+	if out := FormatFundingDivergence(d); !strings.Contains(out, "funding spread 0.0300%/8h (high hyperliquid, low binance)") {
+		t.Errorf("FormatFundingDivergence = %s", out)
+	}
+
+	// 超过保留时长的快照被清理
+	collector.Collect(start.Add(collector.retention + 30*time.Minute))
+	if got := collector.History("BTCUSDT", time.Time{}); len(got) != 4 {
+		t.Errorf("expired snapshots should be pruned, got %d", len(got))
+	}
+}
+
+// TestFundingInfoProviders premiumIndex 与 metaAndAssetCtxs 转换为资金费率快照
+func TestFundingInfoProviders(t *testing.T) {
+	fundingInfoCalls := 0
+	aster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fapi/v1/fundingInfo" {
+			fundingInfoCalls++
+			fmt.Fprint(w, `[{"symbol":"SOLUSDT","adjustedFundingRateCap":"0.02","fundingIntervalHours":4}]`)
+			return
+		}
+		fmt.Fprintf(w, `{"symbol":"%s","markPrice":"101","indexPrice":"100","lastFundingRate":"0.0002","nextFundingTime":1700028800000}`,
+			r.URL.Query().Get("symbol"))
+	}))
+	defer aster.Close()
+
+	asterProvider := &AsterProvider{client: NewAPIClientWithBaseURL(aster.URL)}
+	s, err := asterProvider.GetFundingInfo("eth")
+	if err != nil {
+		t.Fatalf("Aster GetFundingInfo returned error: %v", err)
+	}
+	if s.Symbol != "ETHUSDT" || s.IntervalHours != 8 || s.Rate8h != 0.0002 || math.Abs(s.BasisPct-1) > 1e-9 || s.NextFundingTime.UnixMilli() != 1700028800000 {
+		t.Errorf("unexpected Aster snapshot: %+v", s)
+	}
+	// fundingInfo 列出的币种按实际结算周期折算
+	s, err = asterProvider.GetFundingInfo("SOLUSDT")
+	if err != nil {
+		t.Fatalf("Aster GetFundingInfo returned error: %v", err)
+	}
+	if s.IntervalHours != 4 || math.Abs(s.Rate8h-0.0004) > 1e-12 {
+		t.Errorf("4h funding should be scaled to 8h, got %+v", s)
+	}
+	if fundingInfoCalls != 1 {
+		t.Errorf("funding intervals should be cached, fetched %d times", fundingInfoCalls)
+	}
+
+	hyperliquid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"universe":[{"name":"ETH"}]},[{"funding":"0.00001","openInterest":"1","markPx":"2000","oraclePx":"2001"}]]`)
+	}))
+	defer hyperliquid.Close()
+
+	s, err = newHyperliquidProvider(hyperliquid.URL).GetFundingInfo("ETHUSDT")
+	if err != nil {
+		t.Fatalf("Hyperliquid GetFundingInfo returned error: %v", err)
+	}
+	if s.IntervalHours != 1 || math.Abs(s.Rate8h-0.00008) > 1e-12 || s.MarkPrice != 2000 || s.IndexPrice != 2001 {
+		t.Errorf("unexpected Hyperliquid snapshot: %+v", s)
+	}
+	if s.NextFundingTime.Minute() != 0 || !s.NextFundingTime.After(time.Now()) {
+		t.Errorf("next funding should be the next full hour, got %v", s.NextFundingTime)
+	}
+}
+
+// TestFundingCollectorRequested API 请求的币种有数量上限，长时间未再请求后停止采集
+func TestFundingCollectorRequested(t *testing.T) {
+	binance := &fakeFundingProvider{fakeProvider: fakeProvider{symbols: []string{"BTCUSDT", "DOGEUSDT"}},
+		name: "binance", rate: 0.0001, intervalHours: 8, mark: 100}
+	collector := NewFundingCollector(binance)
+	collector.Track("BTCUSDT")
+
+	var symbols []string
+	for i := 0; i < maxRequestedFundingSymbols; i++ {
+		symbols = append(symbols, fmt.Sprintf("COIN%dUSDT", i))
+	}
+	if rejected := collector.TrackRequested(append(symbols, "BTCUSDT")...); len(rejected) != 0 {
+		t.Errorf("symbols within the cap should be tracked, rejected %v", rejected)
+	}
+	if rejected := collector.TrackRequested("DOGEUSDT"); len(rejected) != 1 || rejected[0] != "DOGEUSDT" {
+		t.Errorf("symbols beyond the cap should be rejected, got %v", rejected)
+	}
+
+	// 过期后释放名额，交易员跟踪的币种不受影响
+	collector.Collect(time.Now().Add(requestedFundingTTL + time.Minute))
+	if len(collector.requested) != 0 || !collector.symbols["BTCUSDT"] {
+		t.Errorf("expired requests should be dropped: requested=%d symbols=%v", len(collector.requested), collector.symbols)
+	}
+	if rejected := collector.TrackRequested("DOGEUSDT"); len(rejected) != 0 {
+		t.Errorf("expired requests should free capacity, rejected %v", rejected)
+	}
+	binance.calls = 0
+	collector.Collect(time.Now())
+	if binance.calls != 2 {
+		t.Errorf("tracked and requested listed symbols should be sampled, got %d calls", binance.calls)
+	}
+}
@@ -0,0 +1,442 @@
+package market
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// IndicatorFunc 指标计算函数：返回与K线等长的输出序列（数据不足的位置为 NaN）
+type IndicatorFunc func(klines []Kline, period int) [][]float64
+
+// IndicatorDef 指标定义
+type IndicatorDef struct {
+	Name          string        // 配置中使用的名称（小写）
+	Label         string        // 输出给AI时的展示名称
+	DefaultPeriod int           // 未配置周期时使用（0表示该指标不使用周期参数）
+	Outputs       []string      // 输出名称，顺序与 Compute 返回一致
+	Compute       IndicatorFunc // 计算函数
+}
+
+var (
+	indicatorRegistry   = make(map[string]IndicatorDef)
+	indicatorRegistryMu sync.RWMutex
+)
+
+// RegisterIndicator 注册指标（同名覆盖），可在启动时扩展自定义指标
+func RegisterIndicator(def IndicatorDef) {
+	if def.Name == "" || def.Compute == nil || len(def.Outputs) == 0 {
+		panic("market: 指标定义缺少名称、输出或计算函数")
+	}
+	indicatorRegistryMu.Lock()
+	defer indicatorRegistryMu.Unlock()
+	indicatorRegistry[strings.ToLower(def.Name)] = def
+}
+
+// LookupIndicator 按名称查找已注册的指标
+func LookupIndicator(name string) (IndicatorDef, bool) {
+	indicatorRegistryMu.RLock()
+	defer indicatorRegistryMu.RUnlock()
+	def, ok := indicatorRegistry[strings.ToLower(name)]
+	return def, ok
+}
+
+// IndicatorNames 返回已注册的指标名称（排序）
+func IndicatorNames() []string {
+	indicatorRegistryMu.RLock()
+	defer indicatorRegistryMu.RUnlock()
+	names := make([]string, 0, len(indicatorRegistry))
+	for name := range indicatorRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterIndicator(IndicatorDef{Name: "ema", Label: "EMA", DefaultPeriod: 20, Outputs: []string{"ema"},
+		Compute: func(k []Kline, p int) [][]float64 { return [][]float64{emaSeries(closes(k), p)} }})
+	RegisterIndicator(IndicatorDef{Name: "sma", Label: "SMA", DefaultPeriod: 20, Outputs: []string{"sma"},
+		Compute: func(k []Kline, p int) [][]float64 { return [][]float64{smaSeries(closes(k), p)} }})
+	RegisterIndicator(IndicatorDef{Name: "rsi", Label: "RSI", DefaultPeriod: 14, Outputs: []string{"rsi"},
+		Compute: func(k []Kline, p int) [][]float64 { return [][]float64{rsiSeries(k, p)} }})
+	RegisterIndicator(IndicatorDef{Name: "atr", Label: "ATR", DefaultPeriod: 14, Outputs: []string{"atr"},
+		Compute: func(k []Kline, p int) [][]float64 { return [][]float64{atrSeries(k, p)} }})
+	RegisterIndicator(IndicatorDef{Name: "macd", Label: "MACD (12/26/9)", Outputs: []string{"line", "signal", "histogram"},
+		Compute: computeMACD})
+	RegisterIndicator(IndicatorDef{Name: "bollinger", Label: "Bollinger Bands", DefaultPeriod: 20, Outputs: []string{"upper", "middle", "lower"},
+		Compute: computeBollinger})
+	RegisterIndicator(IndicatorDef{Name: "vwap", Label: "VWAP", Outputs: []string{"vwap"},
+		Compute: func(k []Kline, p int) [][]float64 { return [][]float64{vwapSeries(k, p)} }})
+	RegisterIndicator(IndicatorDef{Name: "stochastic", Label: "Stochastic", DefaultPeriod: 14, Outputs: []string{"%K", "%D"},
+		Compute: computeStochastic})
+	RegisterIndicator(IndicatorDef{Name: "adx", Label: "ADX", DefaultPeriod: 14, Outputs: []string{"adx", "+DI", "-DI"},
+		Compute: computeADX})
+	RegisterIndicator(IndicatorDef{Name: "obv", Label: "OBV", Outputs: []string{"obv"},
+		Compute: func(k []Kline, _ int) [][]float64 { return [][]float64{obvSeries(k)} }})
+	RegisterIndicator(IndicatorDef{Name: "ichimoku", Label: "Ichimoku", DefaultPeriod: 9, Outputs: []string{"tenkan", "kijun", "span_a", "span_b"},
+		Compute: computeIchimoku})
+}
+
+// computeIndicator 按配置计算指标，返回截取最近 length 个有效值后的各输出序列
+func computeIndicator(def IndicatorDef, period int, klines []Kline, length int) []IndicatorSeries {
+	outputs := def.Compute(klines, period)
+	series := make([]IndicatorSeries, 0, len(outputs))
+	for i, values := range outputs {
+		label := def.Label
+		if period > 0 {
+			label = fmt.Sprintf("%s (%d‑period)", label, period)
+		}
+		if len(def.Outputs) > 1 {
+			label += " " + def.Outputs[i]
+		}
+		series = append(series, IndicatorSeries{
+			Name:   def.Name,
+			Period: period,
+			Output: def.Outputs[i],
+			Label:  label,
+			Values: lastValid(values, length),
+		})
+	}
+	return series
+}
+
+// lastValid 取最近 n 个位置中的有效值（去掉数据不足产生的 NaN）
+func lastValid(values []float64, n int) []float64 {
+	start := len(values) - n
+	if start < 0 {
+		start = 0
+	}
+	result := make([]float64, 0, len(values)-start)
+	for _, v := range values[start:] {
+		if !math.IsNaN(v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// nanSeries 创建全部为 NaN 的序列
+func nanSeries(n int) []float64 {
+	series := make([]float64, n)
+	for i := range series {
+		series[i] = math.NaN()
+	}
+	return series
+}
+
+// closes 提取收盘价
+func closes(klines []Kline) []float64 {
+	values := make([]float64, len(klines))
+	for i, k := range klines {
+		values[i] = k.Close
+	}
+	return values
+}
+
+// firstValid 第一个非 NaN 值的位置
+func firstValid(values []float64) int {
+	for i, v := range values {
+		if !math.IsNaN(v) {
+			return i
+		}
+	}
+	return len(values)
+}
+
+// smaSeries 简单移动平均（从第一个有效值开始计算）
+func smaSeries(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	start := firstValid(values)
+	if period <= 0 || len(values)-start < period {
+		return out
+	}
+	sum := 0.0
+	for i := start; i < len(values); i++ {
+		sum += values[i]
+		if i-start >= period {
+			sum -= values[i-period]
+		}
+		if i-start >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// emaSeries 指数移动平均，以前 period 个值的SMA为初始值（与 calculateEMA 一致）
+func emaSeries(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	start := firstValid(values)
+	if period <= 0 || len(values)-start < period {
+		return out
+	}
+	sum := 0.0
+	for i := start; i < start+period; i++ {
+		sum += values[i]
+	}
+	ema := sum / float64(period)
+	out[start+period-1] = ema
+
+	multiplier := 2.0 / float64(period+1)
+	for i := start + period; i < len(values); i++ {
+		ema = (values[i]-ema)*multiplier + ema
+		out[i] = ema
+	}
+	return out
+}
+
+// rsiSeries Wilder平滑RSI（与 calculateRSI 一致）
+func rsiSeries(klines []Kline, period int) []float64 {
+	out := nanSeries(len(klines))
+	if period <= 0 || len(klines) <= period {
+		return out
+	}
+
+	gains, losses := 0.0, 0.0
+	for i := 1; i <= period; i++ {
+		change := klines[i].Close - klines[i-1].Close
+		if change > 0 {
+			gains += change
+		} else {
+			losses -= change
+		}
+	}
+	avgGain := gains / float64(period)
+	avgLoss := losses / float64(period)
+	out[period] = rsiValue(avgGain, avgLoss)
+
+	for i := period + 1; i < len(klines); i++ {
+		change := klines[i].Close - klines[i-1].Close
+		gain, loss := math.Max(change, 0), math.Max(-change, 0)
+		avgGain = (avgGain*float64(period-1) + gain) / float64(period)
+		avgLoss = (avgLoss*float64(period-1) + loss) / float64(period)
+		out[i] = rsiValue(avgGain, avgLoss)
+	}
+	return out
+}
+
+func rsiValue(avgGain, avgLoss float64) float64 {
+	if avgLoss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+avgGain/avgLoss)
+}
+
+// trueRanges 真实波幅序列（第0根没有前收盘价，记为0）
+func trueRanges(klines []Kline) []float64 {
+	trs := make([]float64, len(klines))
+	for i := 1; i < len(klines); i++ {
+		prevClose := klines[i-1].Close
+		trs[i] = math.Max(klines[i].High-klines[i].Low,
+			math.Max(math.Abs(klines[i].High-prevClose), math.Abs(klines[i].Low-prevClose)))
+	}
+	return trs
+}
+
+// atrSeries Wilder平滑ATR（与 calculateATR 一致）
+func atrSeries(klines []Kline, period int) []float64 {
+	out := nanSeries(len(klines))
+	if period <= 0 || len(klines) <= period {
+		return out
+	}
+	trs := trueRanges(klines)
+	sum := 0.0
+	for i := 1; i <= period; i++ {
+		sum += trs[i]
+	}
+	atr := sum / float64(period)
+	out[period] = atr
+	for i := period + 1; i < len(klines); i++ {
+		atr = (atr*float64(period-1) + trs[i]) / float64(period)
+		out[i] = atr
+	}
+	return out
+}
+
+// computeMACD MACD线(EMA12-EMA26)、信号线(MACD的EMA9)与柱状图
+func computeMACD(klines []Kline, _ int) [][]float64 {
+	values := closes(klines)
+	ema12 := emaSeries(values, 12)
+	ema26 := emaSeries(values, 26)
+	macd := nanSeries(len(klines))
+	for i := range macd {
+		macd[i] = ema12[i] - ema26[i] // 任一为 NaN 时结果仍为 NaN
+	}
+	signal := emaSeries(macd, 9)
+	histogram := nanSeries(len(klines))
+	for i := range histogram {
+		histogram[i] = macd[i] - signal[i]
+	}
+	return [][]float64{macd, signal, histogram}
+}
+
+// computeBollinger 布林带：中轨为SMA，上下轨为中轨±2倍标准差
+func computeBollinger(klines []Kline, period int) [][]float64 {
+	values := closes(klines)
+	middle := smaSeries(values, period)
+	upper, lower := nanSeries(len(klines)), nanSeries(len(klines))
+	for i := range values {
+		if math.IsNaN(middle[i]) {
+			continue
+		}
+		variance := 0.0
+		for _, v := range values[i-period+1 : i+1] {
+			variance += (v - middle[i]) * (v - middle[i])
+		}
+		std := math.Sqrt(variance / float64(period))
+		upper[i] = middle[i] + 2*std
+		lower[i] = middle[i] - 2*std
+	}
+	return [][]float64{upper, middle, lower}
+}
+
+// vwapSeries 成交量加权均价（period>0 为滚动窗口，否则从窗口起点累计）
+func vwapSeries(klines []Kline, period int) []float64 {
+	out := nanSeries(len(klines))
+	pv, vol := 0.0, 0.0
+	for i, k := range klines {
+		pv += (k.High + k.Low + k.Close) / 3 * k.Volume
+		vol += k.Volume
+		if period > 0 {
+			if i >= period {
+				old := klines[i-period]
+				pv -= (old.High + old.Low + old.Close) / 3 * old.Volume
+				vol -= old.Volume
+			}
+			if i < period-1 {
+				continue
+			}
+		}
+		if vol > 0 {
+			out[i] = pv / vol
+		}
+	}
+	return out
+}
+
+// highLow 窗口 [i-period+1, i] 内的最高价与最低价
+func highLow(klines []Kline, i, period int) (float64, float64) {
+	high, low := klines[i].High, klines[i].Low
+	for _, k := range klines[i-period+1 : i] {
+		high = math.Max(high, k.High)
+		low = math.Min(low, k.Low)
+	}
+	return high, low
+}
+
+// computeStochastic 随机指标：%K 为收盘价在区间中的位置，%D 为 %K 的3周期均线
+func computeStochastic(klines []Kline, period int) [][]float64 {
+	k := nanSeries(len(klines))
+	for i := period - 1; i >= 0 && i < len(klines); i++ {
+		high, low := highLow(klines, i, period)
+		if high > low {
+			k[i] = (klines[i].Close - low) / (high - low) * 100
+		} else {
+			k[i] = 50
+		}
+	}
+	return [][]float64{k, smaSeries(k, 3)}
+}
+
+// computeADX 平均趋向指数及 +DI/-DI（Wilder平滑）
+func computeADX(klines []Kline, period int) [][]float64 {
+	n := len(klines)
+	adx, plusDI, minusDI := nanSeries(n), nanSeries(n), nanSeries(n)
+	if period <= 0 || n <= period {
+		return [][]float64{adx, plusDI, minusDI}
+	}
+
+	trs := trueRanges(klines)
+	var smoothTR, smoothPlus, smoothMinus float64
+	dx := nanSeries(n)
+	for i := 1; i < n; i++ {
+		up := klines[i].High - klines[i-1].High
+		down := klines[i-1].Low - klines[i].Low
+		plusDM, minusDM := 0.0, 0.0
+		if up > down && up > 0 {
+			plusDM = up
+		}
+		if down > up && down > 0 {
+			minusDM = down
+		}
+
+		if i <= period {
+			smoothTR += trs[i]
+			smoothPlus += plusDM
+			smoothMinus += minusDM
+			if i < period {
+				continue
+			}
+		} else {
+			smoothTR = smoothTR - smoothTR/float64(period) + trs[i]
+			smoothPlus = smoothPlus - smoothPlus/float64(period) + plusDM
+			smoothMinus = smoothMinus - smoothMinus/float64(period) + minusDM
+		}
+
+		if smoothTR == 0 {
+			continue
+		}
+		plusDI[i] = smoothPlus / smoothTR * 100
+		minusDI[i] = smoothMinus / smoothTR * 100
+		if sum := plusDI[i] + minusDI[i]; sum > 0 {
+			dx[i] = math.Abs(plusDI[i]-minusDI[i]) / sum * 100
+		} else {
+			dx[i] = 0
+		}
+	}
+
+	// ADX 首值为前 period 个 DX 的均值，之后 Wilder 平滑
+	first := 2*period - 1
+	if n <= first {
+		return [][]float64{adx, plusDI, minusDI}
+	}
+	sum := 0.0
+	for i := period; i <= first; i++ {
+		sum += dx[i]
+	}
+	adx[first] = sum / float64(period)
+	for i := first + 1; i < n; i++ {
+		adx[i] = (adx[i-1]*float64(period-1) + dx[i]) / float64(period)
+	}
+	return [][]float64{adx, plusDI, minusDI}
+}
+
+// obvSeries 能量潮（从窗口起点累计）
+func obvSeries(klines []Kline) []float64 {
+	out := make([]float64, len(klines))
+	for i := 1; i < len(klines); i++ {
+		out[i] = out[i-1]
+		switch {
+		case klines[i].Close > klines[i-1].Close:
+			out[i] += klines[i].Volume
+		case klines[i].Close < klines[i-1].Close:
+			out[i] -= klines[i].Volume
+		}
+	}
+	return out
+}
+
+// computeIchimoku 一目均衡表（转换线周期为 period，基准线与先行带B按 9/26/52 比例放大）
+// 先行带为当前值，未做向前平移
+func computeIchimoku(klines []Kline, period int) [][]float64 {
+	n := len(klines)
+	midpoint := func(p int) []float64 {
+		out := nanSeries(n)
+		for i := p - 1; i >= 0 && i < n; i++ {
+			high, low := highLow(klines, i, p)
+			out[i] = (high + low) / 2
+		}
+		return out
+	}
+	tenkan := midpoint(period)
+	kijun := midpoint(period * 26 / 9)
+	spanB := midpoint(period * 52 / 9)
+	spanA := nanSeries(n)
+	for i := range spanA {
+		spanA[i] = (tenkan[i] + kijun[i]) / 2
+	}
+	return [][]float64{tenkan, kijun, spanA, spanB}
+}
@@ -0,0 +1,198 @@
+package market
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestIndicatorSeries_MatchLegacyCalculations 注册表中的 EMA/RSI/ATR 最新值与原有计算一致
+func TestIndicatorSeries_MatchLegacyCalculations(t *testing.T) {
+	klines := generateTestKlines(80)
+
+	checks := []struct {
+		name   string
+		series []float64
+		want   float64
+	}{
+		{"ema20", emaSeries(closes(klines), 20), calculateEMA(klines, 20)},
+		{"rsi7", rsiSeries(klines, 7), calculateRSI(klines, 7)},
+		{"atr14", atrSeries(klines, 14), calculateATR(klines, 14)},
+		{"macd", computeMACD(klines, 0)[0], calculateMACD(klines)},
+	}
+	for _, c := range checks {
+		got := c.series[len(c.series)-1]
+		if math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s latest = %.6f, want %.6f", c.name, got, c.want)
+		}
+	}
+
+	// 数据不足的位置为 NaN
+	if ema := emaSeries(closes(klines), 20); !math.IsNaN(ema[18]) || math.IsNaN(ema[19]) {
+		t.Errorf("EMA20 should start at index 19, got ema[18]=%v ema[19]=%v", ema[18], ema[19])
+	}
+}
+
+// TestIndicators_BasicProperties 新增指标的基本性质
+func TestIndicators_BasicProperties(t *testing.T) {
+	klines := generateTestKlines(80)
+	last := len(klines) - 1
+
+	boll := computeBollinger(klines, 20)
+	sma := smaSeries(closes(klines), 20)
+	if boll[1][last] != sma[last] || !(boll[0][last] > boll[1][last] && boll[1][last] > boll[2][last]) {
+		t.Errorf("bollinger bands out of order: upper=%.3f middle=%.3f lower=%.3f", boll[0][last], boll[1][last], boll[2][last])
+	}
+
+	stoch := computeStochastic(klines, 14)
+	for i := 13; i < len(klines); i++ {
+		if stoch[0][i] < 0 || stoch[0][i] > 100 {
+			t.Fatalf("stochastic %%K[%d] = %.3f out of [0,100]", i, stoch[0][i])
+		}
+	}
+
+	adx := computeADX(klines, 14)
+	if math.IsNaN(adx[0][last]) || adx[0][last] < 0 || adx[0][last] > 100 {
+		t.Errorf("ADX latest = %v, want within [0,100]", adx[0][last])
+	}
+
+	ichimoku := computeIchimoku(klines, 9)
+	if math.IsNaN(ichimoku[3][last]) || !math.IsNaN(ichimoku[3][50]) {
+		t.Errorf("ichimoku span B should start at index 51")
+	}
+
+	// 单根K线的 VWAP 等于典型价格
+	k := klines[last]
+	vwap := vwapSeries(klines, 1)
+	if want := (k.High + k.Low + k.Close) / 3; math.Abs(vwap[last]-want) > 1e-9 {
+		t.Errorf("VWAP(1) = %.6f, want %.6f", vwap[last], want)
+	}
+}
+
+// TestOBVSeries 收盘上涨累加成交量，下跌扣减
+func TestOBVSeries(t *testing.T) {
+	klines := []Kline{
+		{Close: 10, Volume: 100},
+		{Close: 11, Volume: 200},
+		{Close: 11, Volume: 300},
+		{Close: 9, Volume: 50},
+	}
+	want := []float64{0, 200, 200, 150}
+	got := obvSeries(klines)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("obv[%d] = %.0f, want %.0f", i, got[i], want[i])
+		}
+	}
+}
+
+// TestParseDataConfig 解析与校验时间框架配置
+func TestParseDataConfig(t *testing.T) {
+	cfg, err := ParseDataConfig("")
+	if err != nil || len(cfg.Timeframes) != 0 {
+		t.Errorf("empty config should parse to no timeframes, got %+v, %v", cfg, err)
+	}
+
+	cfg, err = ParseDataConfig(`{"timeframes":[{"interval":"1h","indicators":[{"name":"bollinger","period":20},{"name":"VWAP"}]},{"interval":"1d","indicators":[{"name":"ichimoku"}]}]}`)
+	if err != nil {
+		t.Fatalf("ParseDataConfig returned error: %v", err)
+	}
+	if got := strings.Join(cfg.Intervals(), ","); got != "1h,1d" {
+		t.Errorf("Intervals() = %s, want 1h,1d", got)
+	}
+
+	invalid := []string{
+		`{"timeframes":[{"interval":"7m"}]}`,
+		`{"timeframes":[{"interval":"1h","indicators":[{"name":"unknown"}]}]}`,
+		`{"timeframes":[{"interval":"1h","indicators":[{"name":"ema","period":500}]}]}`,
+		`{"timeframes":[{"interval":"1h"},{"interval":"1h"}]}`,
+		`{"timeframes":`,
+	}
+	for _, raw := range invalid {
+		if _, err := ParseDataConfig(raw); err == nil {
+			t.Errorf("ParseDataConfig(%s) should fail", raw)
+		}
+	}
+}
+
+// TestBuildTimeframeAndFormat 按配置计算附加时间框架并输出到 Format
+func TestBuildTimeframeAndFormat(t *testing.T) {
+	spec := TimeframeSpec{
+		Interval: "1h",
+		Indicators: []IndicatorSpec{
+			{Name: "bollinger"},
+			{Name: "ema", Period: 50},
+			{Name: "adx", Period: 60}, // 80根K线不足以计算 ADX(60)
+		},
+		SeriesLength: 5,
+	}
+	tf, err := BuildTimeframe(spec, generateTestKlines(80))
+	if err != nil {
+		t.Fatalf("BuildTimeframe returned error: %v", err)
+	}
+	if len(tf.Closes) != 5 || len(tf.Volume) != 5 {
+		t.Errorf("closes/volume length = %d/%d, want 5", len(tf.Closes), len(tf.Volume))
+	}
+	if len(tf.Indicators) != 3+1+3 {
+		t.Fatalf("indicator series = %d, want 7", len(tf.Indicators))
+	}
+	upper := tf.Indicators[0]
+	if upper.Period != 20 || upper.Output != "upper" || len(upper.Values) != 5 {
+		t.Errorf("bollinger upper = %+v, want default period 20 with 5 values", upper)
+	}
+	if adx := tf.Indicators[4]; len(adx.Values) != 0 || adx.Latest() != 0 {
+		t.Errorf("ADX(60) should have no values, got %v", adx.Values)
+	}
+
+	if _, err := BuildTimeframe(spec, nil); err == nil {
+		t.Error("BuildTimeframe should fail with empty klines")
+	}
+
+	data, err := BuildData("BTCUSDT", generateTestKlines(60), generateTestKlines(30))
+	if err != nil {
+		t.Fatalf("BuildData returned error: %v", err)
+	}
+	legacy := Format(data)
+	data.Timeframes = []*TimeframeData{tf}
+	output := Format(data)
+
+	if !strings.HasPrefix(output, legacy) {
+		t.Error("configured timeframes should be appended after the default sections")
+	}
+	for _, want := range []string{
+		"Additional timeframe (1h intervals",
+		"Bollinger Bands (20‑period) upper: [",
+		"EMA (50‑period): [",
+		"ADX (60‑period) adx: insufficient data",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Format output missing %q", want)
+		}
+	}
+}
+
+// TestWSMonitor_KlineCachePerInterval 任意周期的K线都会被缓存，附加周期去重注册
+func TestWSMonitor_KlineCachePerInterval(t *testing.T) {
+	m := &WSMonitor{intervals: append([]string(nil), subKlineTime...)}
+
+	var ws KlineWSData
+	ws.Kline.StartTime = 1700000000000
+	ws.Kline.ClosePrice = "101.5"
+	m.processKlineUpdate("BTCUSDT", ws, "1h")
+	ws.Kline.StartTime += 3600000
+	m.processKlineUpdate("BTCUSDT", ws, "1h")
+
+	value, ok := m.getKlineDataMap("1h").Load("BTCUSDT")
+	if !ok || len(value.([]Kline)) != 2 {
+		t.Fatalf("1h klines should be cached, got %v", value)
+	}
+	if _, ok := m.getKlineDataMap("3m").Load("BTCUSDT"); ok {
+		t.Error("1h klines should not leak into the 3m cache")
+	}
+
+	// 未启动时只记录周期，Start 时统一订阅
+	m.RegisterIntervals("1h", "3m", "1h", "1d")
+	if got := strings.Join(m.Intervals(), ","); got != "3m,4h,1h,1d" {
+		t.Errorf("Intervals() = %s, want 3m,4h,1h,1d", got)
+	}
+}
@@ -0,0 +1,317 @@
+package market
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// maxBackfillBars 重启补齐缺口时最多回补的K线数量（避免长时间停机后一次性拉取过多数据）
+const maxBackfillBars = 5000
+
+// KlineStore 本地K线库（SQLite），按 币种+周期+开盘时间 去重保存已收盘的K线
+type KlineStore struct {
+	db *sql.DB
+}
+
+var (
+	defaultKlineStore   *KlineStore
+	defaultKlineStoreMu sync.RWMutex
+)
+
+// SetKlineStore 设置全局K线库（WSMonitor、market.Get 与 API 共用），传 nil 表示关闭
+func SetKlineStore(store *KlineStore) {
+	defaultKlineStoreMu.Lock()
+	defer defaultKlineStoreMu.Unlock()
+	defaultKlineStore = store
+}
+
+// GetKlineStore 返回全局K线库（未启用时为 nil）
+func GetKlineStore() *KlineStore {
+	defaultKlineStoreMu.RLock()
+	defer defaultKlineStoreMu.RUnlock()
+	return defaultKlineStore
+}
+
+// OpenKlineStore 打开（不存在时创建）K线库
+func OpenKlineStore(path string) (*KlineStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建K线库目录失败: %w", err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("打开K线库失败: %w", err)
+	}
+	// WAL 模式下读写互不阻塞；busy_timeout 避免并发写入时立即返回 SQLITE_BUSY
+	for _, pragma := range []string{"PRAGMA journal_mode=WAL", "PRAGMA busy_timeout=5000"} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("设置K线库参数失败(%s): %w", pragma, err)
+		}
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS klines (
+			symbol TEXT NOT NULL,
+			interval TEXT NOT NULL,
+			open_time INTEGER NOT NULL,
+			close_time INTEGER NOT NULL,
+			open REAL NOT NULL,
+			high REAL NOT NULL,
+			low REAL NOT NULL,
+			close REAL NOT NULL,
+			volume REAL NOT NULL,
+			quote_volume REAL NOT NULL DEFAULT 0,
+			trades INTEGER NOT NULL DEFAULT 0,
+			taker_buy_base_volume REAL NOT NULL DEFAULT 0,
+			taker_buy_quote_volume REAL NOT NULL DEFAULT 0,
+			PRIMARY KEY (symbol, interval, open_time)
+		) WITHOUT ROWID
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("创建K线表失败: %w", err)
+	}
+	return &KlineStore{db: db}, nil
+}
+
+// Close 关闭K线库
+func (s *KlineStore) Close() error {
+	return s.db.Close()
+}
+
+// Save 批量写入K线（相同开盘时间覆盖旧数据）
+func (s *KlineStore) Save(symbol, interval string, klines []Kline) error {
+	if len(klines) == 0 {
+		return nil
+	}
+	symbol = strings.ToUpper(symbol)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启K线写入事务失败: %w", err)
+	}
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO klines (symbol, interval, open_time, close_time, open, high, low, close,
+			volume, quote_volume, trades, taker_buy_base_volume, taker_buy_quote_volume)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("准备K线写入语句失败: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, k := range klines {
+		_, err := stmt.Exec(symbol, interval, k.OpenTime, k.CloseTime, k.Open, k.High, k.Low, k.Close,
+			k.Volume, k.QuoteVolume, k.Trades, k.TakerBuyBaseVolume, k.TakerBuyQuoteVolume)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("写入 %s %s K线失败: %w", symbol, interval, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交K线写入失败: %w", err)
+	}
+	return nil
+}
+
+// Range 查询开盘时间位于 [startTime, endTime) 的K线（毫秒时间戳，按时间正序）
+func (s *KlineStore) Range(symbol, interval string, startTime, endTime int64) ([]Kline, error) {
+	return s.query(`
+		SELECT open_time, close_time, open, high, low, close, volume, quote_volume, trades,
+			taker_buy_base_volume, taker_buy_quote_volume
+		FROM klines WHERE symbol = ? AND interval = ? AND open_time >= ? AND open_time < ?
+		ORDER BY open_time
+	`, strings.ToUpper(symbol), interval, startTime, endTime)
+}
+
+// Latest 查询最近 limit 根K线（按时间正序）
+func (s *KlineStore) Latest(symbol, interval string, limit int) ([]Kline, error) {
+	return s.Before(symbol, interval, math.MaxInt64, limit)
+}
+
+// Before 查询开盘时间早于 endTime 的最近 limit 根K线（按时间正序）
+func (s *KlineStore) Before(symbol, interval string, endTime int64, limit int) ([]Kline, error) {
+	klines, err := s.query(`
+		SELECT open_time, close_time, open, high, low, close, volume, quote_volume, trades,
+			taker_buy_base_volume, taker_buy_quote_volume
+		FROM klines WHERE symbol = ? AND interval = ? AND open_time < ?
+		ORDER BY open_time DESC LIMIT ?
+	`, strings.ToUpper(symbol), interval, endTime, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(klines)-1; i < j; i, j = i+1, j-1 {
+		klines[i], klines[j] = klines[j], klines[i]
+	}
+	return klines, nil
+}
+
+// LastOpenTime 返回已保存的最新一根K线的开盘时间（没有数据时 ok=false）
+func (s *KlineStore) LastOpenTime(symbol, interval string) (openTime int64, ok bool, err error) {
+	var last sql.NullInt64
+	err = s.db.QueryRow(`SELECT MAX(open_time) FROM klines WHERE symbol = ? AND interval = ?`,
+		strings.ToUpper(symbol), interval).Scan(&last)
+	if err != nil {
+		return 0, false, fmt.Errorf("查询 %s %s 最新K线失败: %w", symbol, interval, err)
+	}
+	return last.Int64, last.Valid, nil
+}
+
+// query 执行K线查询
+func (s *KlineStore) query(query string, args ...interface{}) ([]Kline, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询K线失败: %w", err)
+	}
+	defer rows.Close()
+
+	var klines []Kline
+	for rows.Next() {
+		var k Kline
+		err := rows.Scan(&k.OpenTime, &k.CloseTime, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume,
+			&k.QuoteVolume, &k.Trades, &k.TakerBuyBaseVolume, &k.TakerBuyQuoteVolume)
+		if err != nil {
+			return nil, fmt.Errorf("读取K线失败: %w", err)
+		}
+		klines = append(klines, k)
+	}
+	return klines, rows.Err()
+}
+
+// Backfill 从交易所补齐本地最新K线之后的缺口，返回补齐后最近 limit 根K线（含未收盘K线）
+// 本地没有数据时直接拉取最近 limit 根；只有已收盘的K线会写入本地库
+func (s *KlineStore) Backfill(client *APIClient, symbol, interval string, limit int, now time.Time) ([]Kline, error) {
+	last, ok, err := s.LastOpenTime(symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+	step, known := intervalDurations[interval]
+
+	var fetched []Kline
+	if !ok || !known {
+		fetched, err = client.GetKlines(symbol, interval, limit)
+	} else {
+		from := last
+		if oldest := now.Add(-step * maxBackfillBars).UnixMilli(); from < oldest {
+			log.Printf("⚠️  %s %s 本地K线缺口超过 %d 根，仅回补最近部分", symbol, interval, maxBackfillBars)
+			from = oldest
+		}
+		fetched, err = client.GetKlinesRange(symbol, interval, from, now.UnixMilli())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	closed := closedKlines(fetched, now)
+	if err := s.Save(symbol, interval, closed); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.Latest(symbol, interval, limit)
+	if err != nil {
+		return nil, err
+	}
+	// 追加未收盘的最新K线，保持与实时缓存一致
+	if len(fetched) > len(closed) {
+		stored = append(stored, fetched[len(closed):]...)
+	}
+	return tailKlines(stored, limit), nil
+}
+
+// closedKlines 返回已收盘的K线前缀（交易所按时间正序返回，未收盘K线只可能在末尾）
+func closedKlines(klines []Kline, now time.Time) []Kline {
+	nowMs := now.UnixMilli()
+	n := len(klines)
+	for n > 0 && klines[n-1].CloseTime >= nowMs {
+		n--
+	}
+	return klines[:n]
+}
+
+// IntervalDuration 返回K线周期对应的时长
+func IntervalDuration(interval string) (time.Duration, bool) {
+	step, ok := intervalDurations[interval]
+	return step, ok
+}
+
+// LoadKlineRange 查询 [start, end) 区间的K线：本地库完整覆盖时直接返回，否则从交易所下载并写入本地库
+func LoadKlineRange(symbol, interval string, start, end time.Time) ([]Kline, error) {
+	source := &StoredKlineSource{Store: GetKlineStore(), Client: NewAPIClient()}
+	return source.GetKlinesRange(Normalize(symbol), interval, start.UnixMilli(), end.UnixMilli())
+}
+
+// StoredKlineSource 优先读取本地K线库的区间K线来源（实现回测的 KlineDownloader）
+// Client 为空时只读本地库；Store 为空时直接走交易所
+type StoredKlineSource struct {
+	Store  *KlineStore
+	Client *APIClient
+	Now    func() time.Time // 为空时使用 time.Now
+}
+
+// GetKlinesRange 获取开盘时间位于 [startTime, endTime) 的K线
+func (s *StoredKlineSource) GetKlinesRange(symbol, interval string, startTime, endTime int64) ([]Kline, error) {
+	if s.Store == nil {
+		if s.Client == nil {
+			return nil, fmt.Errorf("未配置K线来源")
+		}
+		return s.Client.GetKlinesRange(symbol, interval, startTime, endTime)
+	}
+
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	stored, err := s.Store.Range(symbol, interval, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	if s.Client == nil || rangeCovered(stored, interval, startTime, endTime, now.UnixMilli()) {
+		return stored, nil
+	}
+
+	fetched, err := s.Client.GetKlinesRange(symbol, interval, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Store.Save(symbol, interval, closedKlines(fetched, now)); err != nil {
+		log.Printf("⚠️  保存 %s %s K线到本地库失败: %v", symbol, interval, err)
+	}
+	return fetched, nil
+}
+
+// rangeCovered 本地K线是否连续覆盖 [startTime, endTime) 内所有已收盘的K线
+func rangeCovered(klines []Kline, interval string, startTime, endTime, nowMs int64) bool {
+	step, ok := intervalDurations[interval]
+	if !ok || len(klines) == 0 {
+		return false
+	}
+	stepMs := step.Milliseconds()
+
+	// 第一根应为覆盖 startTime 的K线，最后一根之后不应再有已收盘的K线
+	firstOpen := startTime
+	if rem := firstOpen % stepMs; rem != 0 {
+		firstOpen += stepMs - rem
+	}
+	if klines[0].OpenTime != firstOpen {
+		return false
+	}
+	if next := klines[len(klines)-1].OpenTime + stepMs; next < endTime && next+stepMs <= nowMs {
+		return false
+	}
+	for i := 1; i < len(klines); i++ {
+		if klines[i].OpenTime-klines[i-1].OpenTime != stepMs {
+			return false
+		}
+	}
+	return true
+}
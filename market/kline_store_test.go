@@ -0,0 +1,196 @@
+package market
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testStepMs = int64(3 * 60 * 1000)
+
+// storeKlines 生成从 from 开始、每 3 分钟一根的K线
+func storeKlines(from int64, count int) []Kline {
+	klines := make([]Kline, count)
+	for i := range klines {
+		open := from + int64(i)*testStepMs
+		klines[i] = Kline{OpenTime: open, CloseTime: open + testStepMs - 1, Open: 100, High: 101, Low: 99, Close: float64(100 + i), Volume: 10}
+	}
+	return klines
+}
+
+func openTestStore(t *testing.T) *KlineStore {
+	t.Helper()
+	store, err := OpenKlineStore(filepath.Join(t.TempDir(), "klines.db"))
+	if err != nil {
+		t.Fatalf("OpenKlineStore returned error: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// klineServer 模拟币安 /fapi/v1/klines：按 startTime/endTime/limit 返回 series 中的K线，记录请求次数
+func klineServer(t *testing.T, series []Kline, requests *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		q := r.URL.Query()
+		start, _ := strconv.ParseInt(q.Get("startTime"), 10, 64)
+		end, _ := strconv.ParseInt(q.Get("endTime"), 10, 64)
+		limit, _ := strconv.Atoi(q.Get("limit"))
+
+		var selected []Kline
+		for _, k := range series {
+			if k.OpenTime >= start && (end == 0 || k.OpenTime <= end) {
+				selected = append(selected, k)
+			}
+		}
+		if q.Get("startTime") == "" {
+			selected = tailKlines(selected, limit)
+		} else if len(selected) > limit {
+			selected = selected[:limit]
+		}
+
+		rows := make([][]interface{}, 0, len(selected))
+		for _, k := range selected {
+			f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+			rows = append(rows, []interface{}{k.OpenTime, f(k.Open), f(k.High), f(k.Low), f(k.Close), f(k.Volume),
+				k.CloseTime, f(k.QuoteVolume), k.Trades, f(k.TakerBuyBaseVolume), f(k.TakerBuyQuoteVolume)})
+		}
+		json.NewEncoder(w).Encode(rows)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestKlineStore_SaveAndQuery 写入去重、区间查询与最近K线
+func TestKlineStore_SaveAndQuery(t *testing.T) {
+	store := openTestStore(t)
+
+	if _, ok, err := store.LastOpenTime("BTCUSDT", "3m"); err != nil || ok {
+		t.Fatalf("empty store LastOpenTime = %v, %v", ok, err)
+	}
+
+	klines := storeKlines(0, 10)
+	if err := store.Save("btcusdt", "3m", klines); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	// 相同开盘时间覆盖旧数据
+	updated := klines[9]
+	updated.Close = 999
+	if err := store.Save("BTCUSDT", "3m", []Kline{updated}); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	got, err := store.Range("BTCUSDT", "3m", 2*testStepMs, 5*testStepMs)
+	if err != nil || len(got) != 3 || got[0].OpenTime != 2*testStepMs {
+		t.Errorf("Range = %d klines (%v), want 3 starting at bar 2", len(got), err)
+	}
+
+	latest, err := store.Latest("BTCUSDT", "3m", 4)
+	if err != nil || len(latest) != 4 {
+		t.Fatalf("Latest = %d klines (%v), want 4", len(latest), err)
+	}
+	if latest[0].OpenTime != 6*testStepMs || latest[3].Close != 999 {
+		t.Errorf("Latest should be ascending with the replaced bar last, got %+v", latest)
+	}
+
+	if last, ok, _ := store.LastOpenTime("BTCUSDT", "3m"); !ok || last != 9*testStepMs {
+		t.Errorf("LastOpenTime = %d, %v", last, ok)
+	}
+	if got, _ := store.Latest("BTCUSDT", "4h", 10); len(got) != 0 {
+		t.Errorf("other intervals should be empty, got %d", len(got))
+	}
+}
+
+// TestKlineStore_Backfill 重启时只补齐缺口，未收盘K线不写入
+func TestKlineStore_Backfill(t *testing.T) {
+	series := storeKlines(0, 50)
+	now := time.UnixMilli(series[49].OpenTime + testStepMs/2) // 最后一根尚未收盘
+	requests := 0
+	client := NewAPIClientWithBaseURL(klineServer(t, series, &requests).URL)
+	store := openTestStore(t)
+
+	// 本地为空：拉取最近 limit 根
+	klines, err := store.Backfill(client, "BTCUSDT", "3m", 20, now)
+	if err != nil || len(klines) != 20 || klines[19].OpenTime != series[49].OpenTime {
+		t.Fatalf("initial Backfill = %d klines (%v)", len(klines), err)
+	}
+	if last, _, _ := store.LastOpenTime("BTCUSDT", "3m"); last != series[48].OpenTime {
+		t.Errorf("open kline should not be stored, last stored = %d", last)
+	}
+
+	// 停机一段时间后：从最后保存的K线开始补齐
+	series = storeKlines(0, 60)
+	now = time.UnixMilli(series[59].OpenTime + testStepMs/2)
+	client = NewAPIClientWithBaseURL(klineServer(t, series, &requests).URL)
+
+	klines, err = store.Backfill(client, "BTCUSDT", "3m", 30, now)
+	if err != nil || len(klines) != 30 {
+		t.Fatalf("Backfill = %d klines (%v)", len(klines), err)
+	}
+	for i := 1; i < len(klines); i++ {
+		if klines[i].OpenTime-klines[i-1].OpenTime != testStepMs {
+			t.Fatalf("backfilled klines should be contiguous, gap at %d", i)
+		}
+	}
+	stored, _ := store.Range("BTCUSDT", "3m", 0, now.UnixMilli())
+	if len(stored) != 29 || stored[0].OpenTime != series[30].OpenTime || stored[28].OpenTime != series[58].OpenTime {
+		t.Errorf("stored %d closed klines, want bars 30-58", len(stored))
+	}
+}
+
+// TestStoredKlineSource 本地库完整覆盖时不请求交易所，存在缺口时下载并写回
+func TestStoredKlineSource(t *testing.T) {
+	series := storeKlines(0, 100)
+	now := time.UnixMilli(series[99].CloseTime + 1)
+	requests := 0
+	store := openTestStore(t)
+	source := &StoredKlineSource{
+		Store:  store,
+		Client: NewAPIClientWithBaseURL(klineServer(t, series, &requests).URL),
+		Now:    func() time.Time { return now },
+	}
+
+	store.Save("BTCUSDT", "3m", series[:40])
+	store.Save("BTCUSDT", "3m", series[45:60])
+
+	klines, err := source.GetKlinesRange("BTCUSDT", "3m", series[10].OpenTime, series[30].OpenTime)
+	if err != nil || len(klines) != 20 || requests != 0 {
+		t.Fatalf("covered range: %d klines, %d requests (%v)", len(klines), requests, err)
+	}
+
+	klines, err = source.GetKlinesRange("BTCUSDT", "3m", series[30].OpenTime, series[50].OpenTime)
+	if err != nil || requests == 0 || len(klines) == 0 {
+		t.Fatalf("gap should trigger download: %d klines, %d requests (%v)", len(klines), requests, err)
+	}
+	requests = 0
+	if _, err := source.GetKlinesRange("BTCUSDT", "3m", series[30].OpenTime, series[50].OpenTime); err != nil || requests != 0 {
+		t.Errorf("downloaded range should be served from the store, got %d requests (%v)", requests, err)
+	}
+
+	// 只读本地库
+	offline := &StoredKlineSource{Store: store}
+	if klines, _ := offline.GetKlinesRange("BTCUSDT", "3m", series[55].OpenTime, series[70].OpenTime); len(klines) != 5 {
+		t.Errorf("offline source should return stored klines only, got %d", len(klines))
+	}
+}
+
+// TestPrependStored 实时缓存不足时用本地库中更早的K线补足
+func TestPrependStored(t *testing.T) {
+	store := openTestStore(t)
+	series := storeKlines(0, 30)
+	store.Save("BTCUSDT", "3m", series[:25])
+
+	cache := series[20:]
+	klines := prependStored(store, "BTCUSDT", "3m", cache, 15)
+	if len(klines) != 15 || klines[0].OpenTime != series[15].OpenTime || klines[14].OpenTime != series[29].OpenTime {
+		t.Errorf("prependStored = %d klines from %d", len(klines), klines[0].OpenTime)
+	}
+	if klines := prependStored(nil, "BTCUSDT", "3m", cache, 15); len(klines) != len(cache) {
+		t.Error("without a store the cache should be returned unchanged")
+	}
+}
@@ -13,15 +13,27 @@ type WSMonitor struct {
 	wsClient       *WSClient
 	combinedClient *CombinedStreamsClient
 	symbols        []string
-	featuresMap    sync.Map
+	featuresMap    sync.Map // 每个交易对的最新特征（3m K线收盘时更新）
 	alertsChan     chan Alert
-	klineDataMap3m sync.Map // 存储每个交易对的K线历史数据
-	klineDataMap4h sync.Map // 存储每个交易对的K线历史数据
-	tickerDataMap  sync.Map // 存储每个交易对的ticker数据
+	alerts         alertState // 警报订阅者、冷却与最近警报
+	klineDataMaps  sync.Map   // K线周期 -> *sync.Map（存储每个交易对的K线历史数据）
+	tickerDataMap  sync.Map   // 存储每个交易对的ticker数据
 	batchSize      int
 	filterSymbols  sync.Map // 使用sync.Map来存储需要监控的币种和其状态
 	symbolStats    sync.Map // 存储币种统计信息
 	FilterSymbol   []string //经过筛选的币种
+
+	priceSubs      map[int]chan PriceUpdate // 实时价格订阅者
+	priceSubsMu    sync.RWMutex
+	nextPriceSubID int
+
+	intervals   []string        // 订阅的K线周期（默认3m/4h，交易员配置的附加周期会追加进来）
+	loaded      map[string]bool // 已加载历史K线的周期
+	intervalsMu sync.Mutex
+	subscribed  bool // 是否已完成批量订阅（之后注册的周期需要单独订阅）
+
+	orderBooks      sync.Map // 币种 -> *OrderBook（部分深度流的最新盘口）
+	depthSubscribed sync.Map // 已订阅盘口流的币种
 }
 type SymbolStats struct {
 	LastActiveTime   time.Time
@@ -32,7 +44,10 @@ type SymbolStats struct {
 }
 
 var WSMonitorCli *WSMonitor
-var subKlineTime = []string{"3m", "4h"} // 管理订阅流的K线周期
+var subKlineTime = []string{"3m", "4h"} // 默认订阅的K线周期
+
+// klineHistoryLimit 每个交易对每个周期缓存的K线数量
+const klineHistoryLimit = 100
 
 func NewWSMonitor(batchSize int) *WSMonitor {
 	WSMonitorCli = &WSMonitor{
@@ -40,6 +55,8 @@ func NewWSMonitor(batchSize int) *WSMonitor {
 		combinedClient: NewCombinedStreamsClient(batchSize),
 		alertsChan:     make(chan Alert, 1000),
 		batchSize:      batchSize,
+		intervals:      append([]string(nil), subKlineTime...),
+		loaded:         make(map[string]bool),
 	}
 	return WSMonitorCli
 }
@@ -68,14 +85,23 @@ func (m *WSMonitor) Initialize(coins []string) error {
 
 	log.Printf("找到 %d 个交易对", len(m.symbols))
 	// 初始化历史数据
-	if err := m.initializeHistoricalData(); err != nil {
+	if err := m.initializeHistoricalData(m.Intervals()); err != nil {
 		log.Printf("初始化历史数据失败: %v", err)
 	}
 
 	return nil
 }
 
-func (m *WSMonitor) initializeHistoricalData() error {
+// loadHistory 加载最近的历史K线：启用K线库时从上次保存的位置补齐缺口，否则直接请求API
+func loadHistory(apiClient *APIClient, symbol, interval string) ([]Kline, error) {
+	if store := GetKlineStore(); store != nil {
+		return store.Backfill(apiClient, symbol, interval, klineHistoryLimit, time.Now())
+	}
+	return apiClient.GetKlines(symbol, interval, klineHistoryLimit)
+}
+
+// initializeHistoricalData 通过API加载所有交易对指定周期的历史K线
+func (m *WSMonitor) initializeHistoricalData(intervals []string) error {
 	apiClient := NewAPIClient()
 
 	var wg sync.WaitGroup
@@ -89,30 +115,34 @@ func (m *WSMonitor) initializeHistoricalData() error {
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			// 获取历史K线数据
-			klines, err := apiClient.GetKlines(s, "3m", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-				return
-			}
-			if len(klines) > 0 {
-				m.klineDataMap3m.Store(s, klines)
-				log.Printf("已加载 %s 的历史K线数据-3m: %d 条", s, len(klines))
-			}
-			// 获取历史K线数据
-			klines4h, err := apiClient.GetKlines(s, "4h", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-				return
-			}
-			if len(klines4h) > 0 {
-				m.klineDataMap4h.Store(s, klines4h)
-				log.Printf("已加载 %s 的历史K线数据-4h: %d 条", s, len(klines4h))
+			for _, interval := range intervals {
+				// 获取历史K线数据（启用K线库时先补齐本地缺口再读取）
+				klines, err := loadHistory(apiClient, s, interval)
+				if err != nil {
+					log.Printf("获取 %s 历史数据失败: %v", s, err)
+					return
+				}
+				if len(klines) > 0 {
+					m.getKlineDataMap(interval).Store(s, klines)
+					log.Printf("已加载 %s 的历史K线数据-%s: %d 条", s, interval, len(klines))
+					if interval == "3m" {
+						m.updateFeatures(s, klines)
+					}
+				}
 			}
 		}(symbol)
 	}
 
 	wg.Wait()
+
+	m.intervalsMu.Lock()
+	if m.loaded == nil {
+		m.loaded = make(map[string]bool)
+	}
+	for _, interval := range intervals {
+		m.loaded[interval] = true
+	}
+	m.intervalsMu.Unlock()
 	return nil
 }
 
@@ -151,15 +181,24 @@ func (m *WSMonitor) subscribeSymbol(symbol, st string) []string {
 func (m *WSMonitor) subscribeAll() error {
 	// 执行批量订阅
 	log.Println("开始订阅所有交易对...")
-	for _, symbol := range m.symbols {
-		for _, st := range subKlineTime {
-			m.subscribeSymbol(symbol, st)
+	m.intervalsMu.Lock()
+	m.subscribed = true
+	intervals := append([]string(nil), m.intervals...)
+	var missing []string // 初始化历史数据之后才注册的周期
+	for _, st := range intervals {
+		if !m.loaded[st] {
+			missing = append(missing, st)
 		}
 	}
-	for _, st := range subKlineTime {
-		err := m.combinedClient.BatchSubscribeKlines(m.symbols, st)
-		if err != nil {
-			log.Printf("❌ 订阅 %s K线失败: %v", st, err)
+	m.intervalsMu.Unlock()
+
+	if len(missing) > 0 {
+		if err := m.initializeHistoricalData(missing); err != nil {
+			log.Printf("初始化历史数据失败: %v", err)
+		}
+	}
+	for _, st := range intervals {
+		if err := m.subscribeInterval(st); err != nil {
 			return err
 		}
 	}
@@ -167,6 +206,63 @@ func (m *WSMonitor) subscribeAll() error {
 	return nil
 }
 
+// subscribeInterval 为所有交易对订阅指定周期的K线流
+func (m *WSMonitor) subscribeInterval(interval string) error {
+	for _, symbol := range m.symbols {
+		m.subscribeSymbol(symbol, interval)
+	}
+	if err := m.combinedClient.BatchSubscribeKlines(m.symbols, interval); err != nil {
+		log.Printf("❌ 订阅 %s K线失败: %v", interval, err)
+		return err
+	}
+	return nil
+}
+
+// Intervals 返回当前订阅的K线周期
+func (m *WSMonitor) Intervals() []string {
+	m.intervalsMu.Lock()
+	defer m.intervalsMu.Unlock()
+	return append([]string(nil), m.intervals...)
+}
+
+// RegisterIntervals 注册需要订阅的附加K线周期（已注册的忽略）
+// 监控器已完成订阅时，在后台为新周期加载历史数据并订阅所有交易对
+func (m *WSMonitor) RegisterIntervals(intervals ...string) {
+	m.intervalsMu.Lock()
+	var added []string
+	for _, interval := range intervals {
+		exists := false
+		for _, st := range m.intervals {
+			if st == interval {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			m.intervals = append(m.intervals, interval)
+			added = append(added, interval)
+		}
+	}
+	subscribed := m.subscribed
+	m.intervalsMu.Unlock()
+
+	if len(added) == 0 {
+		return
+	}
+	log.Printf("📈 新增K线订阅周期: %v", added)
+	if !subscribed {
+		return // 尚未启动，Start 时统一加载与订阅
+	}
+	go func() {
+		if err := m.initializeHistoricalData(added); err != nil {
+			log.Printf("初始化历史数据失败: %v", err)
+		}
+		for _, interval := range added {
+			m.subscribeInterval(interval)
+		}
+	}()
+}
+
 func (m *WSMonitor) handleKlineData(symbol string, ch <-chan []byte, _time string) {
 	for data := range ch {
 		var klineData KlineWSData
@@ -178,16 +274,10 @@ func (m *WSMonitor) handleKlineData(symbol string, ch <-chan []byte, _time strin
 	}
 }
 
+// getKlineDataMap 返回指定周期的K线缓存（不存在时创建）
 func (m *WSMonitor) getKlineDataMap(_time string) *sync.Map {
-	var klineDataMap *sync.Map
-	if _time == "3m" {
-		klineDataMap = &m.klineDataMap3m
-	} else if _time == "4h" {
-		klineDataMap = &m.klineDataMap4h
-	} else {
-		klineDataMap = &sync.Map{}
-	}
-	return klineDataMap
+	value, _ := m.klineDataMaps.LoadOrStore(_time, &sync.Map{})
+	return value.(*sync.Map)
 }
 func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time string) {
 	// 转换WebSocket数据为Kline结构
@@ -221,7 +311,7 @@ func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time
 			klines = append(klines, kline)
 
 			// 保持数据长度
-			if len(klines) > 100 {
+			if len(klines) > klineHistoryLimit {
 				klines = klines[1:]
 			}
 		}
@@ -230,6 +320,25 @@ func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time
 	}
 
 	klineDataMap.Store(symbol, klines)
+
+	// 3m K线每次推送都携带最新成交价，转发给价格订阅者（如持仓保护）
+	if _time == "3m" && kline.Close > 0 {
+		m.publishPrice(PriceUpdate{Symbol: symbol, Price: kline.Close, Time: time.UnixMilli(wsData.EventTime)})
+	}
+
+	if !wsData.Kline.IsFinal {
+		return
+	}
+	// K线收盘时写入K线库
+	if store := GetKlineStore(); store != nil {
+		if err := store.Save(symbol, _time, []Kline{kline}); err != nil {
+			log.Printf("⚠️  保存 %s %s K线失败: %v", symbol, _time, err)
+		}
+	}
+	// 3m K线收盘时更新特征并检查警报
+	if _time == "3m" {
+		m.updateFeatures(symbol, klines)
+	}
 }
 
 func (m *WSMonitor) GetCurrentKlines(symbol string, duration string) ([]Kline, error) {
@@ -238,7 +347,7 @@ func (m *WSMonitor) GetCurrentKlines(symbol string, duration string) ([]Kline, e
 	if !exists {
 		// 如果Ws数据未初始化完成时,单独使用api获取 - 兼容性代码 (防止在未初始化完成是,已经有交易员运行)
 		apiClient := NewAPIClient()
-		klines, err := apiClient.GetKlines(symbol, duration, 100)
+		klines, err := loadHistory(apiClient, symbol, duration)
 		if err != nil {
 			return nil, fmt.Errorf("获取%v分钟K线失败: %v", duration, err)
 		}
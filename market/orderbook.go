@@ -0,0 +1,263 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 盘口深度参数
+const (
+	orderBookLevels    = 20               // WebSocket 订阅的档位数（币安部分深度流最多20档）
+	orderBookRESTLimit = 100              // REST 快照请求的档位数
+	orderBookTTL       = 10 * time.Second // 缓存有效期，超过后视为过期并通过 REST 刷新
+	liquidityTopLevels = 5                // 展示给AI的买卖档位数
+)
+
+// BookLevel 盘口单档
+type BookLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+// OrderBook 盘口快照（买盘价格从高到低，卖盘价格从低到高）
+type OrderBook struct {
+	Symbol string
+	Bids   []BookLevel
+	Asks   []BookLevel
+	Time   time.Time
+}
+
+// LiquidityData 盘口流动性摘要（深度为名义价值，单位 USDT）
+type LiquidityData struct {
+	BestBid    float64
+	BestAsk    float64
+	SpreadPct  float64 // 买一卖一价差（相对中间价，%）
+	BidDepth05 float64 // 中间价下方 0.5% 内的买盘深度
+	AskDepth05 float64 // 中间价上方 0.5% 内的卖盘深度
+	BidDepth1  float64 // 中间价下方 1% 内的买盘深度
+	AskDepth1  float64 // 中间价上方 1% 内的卖盘深度
+	BidSpanPct float64 // 快照中买盘覆盖的价格范围（%），小于1表示 ±1% 深度被截断
+	AskSpanPct float64 // 快照中卖盘覆盖的价格范围（%）
+	TopBids    []BookLevel
+	TopAsks    []BookLevel
+	UpdatedAt  time.Time
+}
+
+// orderBookProvider 提供盘口快照的行情源
+type orderBookProvider interface {
+	GetOrderBook(symbol string) (*OrderBook, error)
+}
+
+// ComputeLiquidity 根据盘口快照计算价差与 ±0.5%/±1% 深度（盘口为空或单边为空时返回 nil）
+func ComputeLiquidity(book *OrderBook, topN int) *LiquidityData {
+	if book == nil || len(book.Bids) == 0 || len(book.Asks) == 0 {
+		return nil
+	}
+	bestBid, bestAsk := book.Bids[0].Price, book.Asks[0].Price
+	mid := (bestBid + bestAsk) / 2
+	if mid <= 0 {
+		return nil
+	}
+
+	l := &LiquidityData{
+		BestBid:   bestBid,
+		BestAsk:   bestAsk,
+		SpreadPct: (bestAsk - bestBid) / mid * 100,
+		TopBids:   append([]BookLevel(nil), book.Bids[:min(topN, len(book.Bids))]...),
+		TopAsks:   append([]BookLevel(nil), book.Asks[:min(topN, len(book.Asks))]...),
+		UpdatedAt: book.Time,
+	}
+	for _, level := range book.Bids {
+		distance := (mid - level.Price) / mid * 100
+		notional := level.Price * level.Quantity
+		if distance <= 0.5 {
+			l.BidDepth05 += notional
+		}
+		if distance <= 1 {
+			l.BidDepth1 += notional
+		}
+		l.BidSpanPct = math.Max(l.BidSpanPct, distance)
+	}
+	for _, level := range book.Asks {
+		distance := (level.Price - mid) / mid * 100
+		notional := level.Price * level.Quantity
+		if distance <= 0.5 {
+			l.AskDepth05 += notional
+		}
+		if distance <= 1 {
+			l.AskDepth1 += notional
+		}
+		l.AskSpanPct = math.Max(l.AskSpanPct, distance)
+	}
+	return l
+}
+
+// TakerDepth 开仓方向可吃的 ±1% 深度（做多吃卖盘，做空吃买盘）
+// complete=false 表示快照未覆盖完整的 1% 价格范围，深度只是下限
+func (l *LiquidityData) TakerDepth(long bool) (depth float64, complete bool) {
+	if long {
+		return l.AskDepth1, l.AskSpanPct >= 1
+	}
+	return l.BidDepth1, l.BidSpanPct >= 1
+}
+
+// attachLiquidity 行情源支持盘口时补充流动性数据（获取失败不影响其他市场数据）
+func attachLiquidity(provider Provider, data *Data) {
+	books, ok := provider.(orderBookProvider)
+	if !ok {
+		return
+	}
+	book, err := books.GetOrderBook(data.Symbol)
+	if err != nil {
+		log.Printf("⚠️  获取 %s 盘口失败: %v", data.Symbol, err)
+		return
+	}
+	data.Liquidity = ComputeLiquidity(book, liquidityTopLevels)
+}
+
+// formatLiquidity 格式化盘口流动性（紧凑格式，节省 Token）
+func formatLiquidity(l *LiquidityData) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Order book liquidity: spread = %.3f%%, depth within ±0.5%%: bids %s / asks %s USDT, depth within ±1%%: bids %s / asks %s USDT",
+		l.SpreadPct, formatNotional(l.BidDepth05), formatNotional(l.AskDepth05), formatNotional(l.BidDepth1), formatNotional(l.AskDepth1)))
+	if l.BidSpanPct < 1 || l.AskSpanPct < 1 {
+		sb.WriteString(" (partial book, actual ±1% depth is higher)")
+	}
+	sb.WriteString("\n\n")
+	sb.WriteString(fmt.Sprintf("Top %d bids (price x qty): %s\n", len(l.TopBids), formatBookLevels(l.TopBids)))
+	sb.WriteString(fmt.Sprintf("Top %d asks (price x qty): %s\n\n", len(l.TopAsks), formatBookLevels(l.TopAsks)))
+	return sb.String()
+}
+
+// formatBookLevels 格式化盘口档位
+func formatBookLevels(levels []BookLevel) string {
+	parts := make([]string, len(levels))
+	for i, level := range levels {
+		parts[i] = formatPriceWithDynamicPrecision(level.Price) + " x " + strconv.FormatFloat(level.Quantity, 'f', -1, 64)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// formatNotional 以 K/M 为单位格式化名义价值
+func formatNotional(value float64) string {
+	switch {
+	case value >= 1e6:
+		return fmt.Sprintf("%.2fM", value/1e6)
+	case value >= 1e3:
+		return fmt.Sprintf("%.1fK", value/1e3)
+	default:
+		return fmt.Sprintf("%.0f", value)
+	}
+}
+
+// GetOrderBook 获取盘口快照（/fapi/v1/depth，币安与 Aster 通用）
+func (c *APIClient) GetOrderBook(symbol string, limit int) (*OrderBook, error) {
+	url := fmt.Sprintf("%s/fapi/v1/depth?symbol=%s&limit=%d", c.baseURL, symbol, limit)
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result depthPayload
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析 %s 盘口失败: %w", symbol, err)
+	}
+	return result.book(symbol), nil
+}
+
+// depthPayload 币安深度数据（REST 快照与部分深度流字段一致）
+type depthPayload struct {
+	EventTime       int64      `json:"E"`
+	TransactionTime int64      `json:"T"`
+	Bids            [][]string `json:"bids"`
+	Asks            [][]string `json:"asks"`
+	StreamBids      [][]string `json:"b"`
+	StreamAsks      [][]string `json:"a"`
+}
+
+// book 转换为盘口快照
+func (p depthPayload) book(symbol string) *OrderBook {
+	bids, asks := p.Bids, p.Asks
+	if len(bids) == 0 && len(asks) == 0 {
+		bids, asks = p.StreamBids, p.StreamAsks
+	}
+	ts := time.Now()
+	if p.TransactionTime > 0 {
+		ts = time.UnixMilli(p.TransactionTime)
+	} else if p.EventTime > 0 {
+		ts = time.UnixMilli(p.EventTime)
+	}
+	return &OrderBook{Symbol: Normalize(symbol), Bids: parseBookLevels(bids), Asks: parseBookLevels(asks), Time: ts}
+}
+
+// parseBookLevels 解析 [价格, 数量] 字符串档位，跳过数量为0的档位
+func parseBookLevels(raw [][]string) []BookLevel {
+	levels := make([]BookLevel, 0, len(raw))
+	for _, r := range raw {
+		if len(r) < 2 {
+			continue
+		}
+		price, err1 := strconv.ParseFloat(r[0], 64)
+		qty, err2 := strconv.ParseFloat(r[1], 64)
+		if err1 != nil || err2 != nil || qty <= 0 {
+			continue
+		}
+		levels = append(levels, BookLevel{Price: price, Quantity: qty})
+	}
+	return levels
+}
+
+// GetOrderBook 获取盘口（WebSocket 部分深度流缓存，首次请求时通过 REST 初始化并订阅）
+func (m *WSMonitor) GetOrderBook(symbol string) (*OrderBook, error) {
+	symbol = Normalize(symbol)
+	if value, ok := m.orderBooks.Load(symbol); ok {
+		if book := value.(*OrderBook); time.Since(book.Time) < orderBookTTL {
+			return book, nil
+		}
+	}
+
+	book, err := NewAPIClient().GetOrderBook(symbol, orderBookRESTLimit)
+	if err != nil {
+		return nil, err
+	}
+	m.orderBooks.Store(symbol, book)
+	m.subscribeDepth(symbol)
+	return book, nil
+}
+
+// subscribeDepth 订阅币种的部分深度流（每个币种只订阅一次）
+func (m *WSMonitor) subscribeDepth(symbol string) {
+	if _, loaded := m.depthSubscribed.LoadOrStore(symbol, true); loaded || m.combinedClient == nil {
+		return
+	}
+	stream := fmt.Sprintf("%s@depth%d@500ms", strings.ToLower(symbol), orderBookLevels)
+	ch := m.combinedClient.AddSubscriber(stream, 100)
+	go m.handleDepthData(symbol, ch)
+	if err := m.combinedClient.subscribeStreams([]string{stream}); err != nil {
+		log.Printf("⚠️  订阅 %s 盘口流失败（使用REST快照）: %v", symbol, err)
+	}
+}
+
+// handleDepthData 处理部分深度流推送
+func (m *WSMonitor) handleDepthData(symbol string, ch <-chan []byte) {
+	for data := range ch {
+		var payload depthPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			log.Printf("解析 %s 盘口数据失败: %v", symbol, err)
+			continue
+		}
+		m.orderBooks.Store(symbol, payload.book(symbol))
+	}
+}
@@ -0,0 +1,118 @@
+package market
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestComputeLiquidity 价差、±0.5%/±1% 深度与快照覆盖范围
+func TestComputeLiquidity(t *testing.T) {
+	book := &OrderBook{
+		Symbol: "SOLUSDT",
+		Bids:   []BookLevel{{99.9, 10}, {99.6, 20}, {99.2, 30}, {98.5, 40}},
+		Asks:   []BookLevel{{100.1, 5}, {100.4, 10}, {100.8, 15}},
+		Time:   time.UnixMilli(1000),
+	}
+
+	l := ComputeLiquidity(book, 2)
+	if l == nil {
+		t.Fatal("ComputeLiquidity returned nil")
+	}
+	if math.Abs(l.SpreadPct-0.2) > 1e-9 {
+		t.Errorf("SpreadPct = %v, want 0.2", l.SpreadPct)
+	}
+	if math.Abs(l.BidDepth05-(999+1992)) > 1e-6 || math.Abs(l.BidDepth1-(999+1992+2976)) > 1e-6 {
+		t.Errorf("bid depth = %v / %v", l.BidDepth05, l.BidDepth1)
+	}
+	if math.Abs(l.AskDepth05-(500.5+1004)) > 1e-6 || math.Abs(l.AskDepth1-(500.5+1004+1512)) > 1e-6 {
+		t.Errorf("ask depth = %v / %v", l.AskDepth05, l.AskDepth1)
+	}
+	if len(l.TopBids) != 2 || len(l.TopAsks) != 2 {
+		t.Errorf("expected top 2 levels, got %d/%d", len(l.TopBids), len(l.TopAsks))
+	}
+
+	// 买盘覆盖超过1%，卖盘只覆盖到0.8%
+	if depth, complete := l.TakerDepth(false); !complete || depth != l.BidDepth1 {
+		t.Errorf("short taker depth = %v, complete=%v", depth, complete)
+	}
+	if _, complete := l.TakerDepth(true); complete {
+		t.Error("ask side does not span 1%, depth should be incomplete")
+	}
+
+	if ComputeLiquidity(&OrderBook{Bids: book.Bids}, 5) != nil {
+		t.Error("one-sided book should return nil")
+	}
+}
+
+// TestFormatLiquidity 市场数据输出包含盘口流动性
+func TestFormatLiquidity(t *testing.T) {
+	data := &Data{Symbol: "SOLUSDT", CurrentPrice: 100, Liquidity: &LiquidityData{
+		SpreadPct: 0.02, BidDepth05: 1500, AskDepth05: 2_500_000, BidDepth1: 3000, AskDepth1: 4_000_000,
+		BidSpanPct: 2, AskSpanPct: 0.5,
+		TopBids: []BookLevel{{99.99, 12}}, TopAsks: []BookLevel{{100.01, 3.5}},
+	}}
+
+	out := Format(data)
+	for _, want := range []string{"spread = 0.020%", "bids 1.5K / asks 2.50M", "partial book", "Top 1 asks (price x qty): [100.01 x 3.5]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Format output missing %q:\n%s", want, out)
+		}
+	}
+	data.Liquidity = nil
+	if strings.Contains(Format(data), "Order book") {
+		t.Error("liquidity section should be omitted without data")
+	}
+}
+
+// TestOrderBookProviders 币安兼容 REST 深度与 Hyperliquid l2Book
+func TestOrderBookProviders(t *testing.T) {
+	binance := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fapi/v1/depth" || r.URL.Query().Get("limit") != fmt.Sprint(orderBookRESTLimit) {
+			t.Errorf("unexpected request %s", r.URL.String())
+		}
+		fmt.Fprint(w, `{"lastUpdateId":1,"E":1700000000000,"T":1700000000001,
+			"bids":[["100.0","2"],["99.5","0"],["99.0","3"]],"asks":[["100.5","1"]]}`)
+	}))
+	defer binance.Close()
+
+	book, err := (&AsterProvider{client: NewAPIClientWithBaseURL(binance.URL)}).GetOrderBook("SOLUSDT")
+	if err != nil {
+		t.Fatalf("GetOrderBook returned error: %v", err)
+	}
+	if len(book.Bids) != 2 || book.Asks[0].Price != 100.5 || book.Time.UnixMilli() != 1700000000001 {
+		t.Errorf("unexpected book: %+v", book)
+	}
+
+	// 部分深度流推送使用 b/a 字段
+	stream := depthPayload{EventTime: 5, StreamBids: [][]string{{"1", "1"}}, StreamAsks: [][]string{{"2", "1"}}}
+	if b := stream.book("sol"); b.Symbol != "SOLUSDT" || len(b.Bids) != 1 || len(b.Asks) != 1 {
+		t.Errorf("stream payload parsed as %+v", b)
+	}
+
+	hyperliquid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case strings.Contains(string(body), "metaAndAssetCtxs"):
+			fmt.Fprint(w, `[{"universe":[{"name":"SOL"}]},[{"funding":"0","openInterest":"0"}]]`)
+		case strings.Contains(string(body), `"l2Book"`) && strings.Contains(string(body), `"SOL"`):
+			fmt.Fprint(w, `{"coin":"SOL","time":42,"levels":[[{"px":"99.9","sz":"4","n":2}],[{"px":"100.1","sz":"6","n":1},{"px":"100.2","sz":"1","n":1}]]}`)
+		default:
+			t.Errorf("unexpected request body %s", body)
+		}
+	}))
+	defer hyperliquid.Close()
+
+	book, err = newHyperliquidProvider(hyperliquid.URL).GetOrderBook("SOLUSDT")
+	if err != nil {
+		t.Fatalf("Hyperliquid GetOrderBook returned error: %v", err)
+	}
+	if len(book.Bids) != 1 || len(book.Asks) != 2 || book.Bids[0].Quantity != 4 || book.Time.UnixMilli() != 42 {
+		t.Errorf("unexpected Hyperliquid book: %+v", book)
+	}
+}
@@ -0,0 +1,61 @@
+package market
+
+import (
+	"strings"
+	"time"
+)
+
+// PriceUpdate 实时价格更新（来自3m K线流的最新成交价）
+type PriceUpdate struct {
+	Symbol string
+	Price  float64
+	Time   time.Time
+}
+
+// SubscribePrices 订阅所有已监控币种的实时价格，返回价格通道和取消订阅函数
+// 通道满时丢弃新的更新（下一次推送会带来更新的价格），不会阻塞行情处理
+func (m *WSMonitor) SubscribePrices(bufferSize int) (<-chan PriceUpdate, func()) {
+	ch := make(chan PriceUpdate, bufferSize)
+
+	m.priceSubsMu.Lock()
+	if m.priceSubs == nil {
+		m.priceSubs = make(map[int]chan PriceUpdate)
+	}
+	id := m.nextPriceSubID
+	m.nextPriceSubID++
+	m.priceSubs[id] = ch
+	m.priceSubsMu.Unlock()
+
+	unsubscribe := func() {
+		m.priceSubsMu.Lock()
+		defer m.priceSubsMu.Unlock()
+		if sub, ok := m.priceSubs[id]; ok {
+			delete(m.priceSubs, id)
+			close(sub)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// publishPrice 向所有价格订阅者推送更新
+func (m *WSMonitor) publishPrice(update PriceUpdate) {
+	m.priceSubsMu.RLock()
+	defer m.priceSubsMu.RUnlock()
+
+	for _, ch := range m.priceSubs {
+		select {
+		case ch <- update:
+		default:
+		}
+	}
+}
+
+// WatchSymbol 确保币种已订阅实时K线流（持仓币种可能不在监控列表中）
+func (m *WSMonitor) WatchSymbol(symbol string) error {
+	symbol = strings.ToUpper(symbol)
+	if _, exists := m.getKlineDataMap("3m").Load(symbol); exists {
+		return nil
+	}
+	_, err := m.GetCurrentKlines(symbol, "3m")
+	return err
+}
@@ -0,0 +1,41 @@
+package market
+
+import "testing"
+
+func TestWSMonitor_SubscribePrices(t *testing.T) {
+	m := &WSMonitor{}
+	updates, unsubscribe := m.SubscribePrices(2)
+
+	var ws KlineWSData
+	ws.EventTime = 1700000000000
+	ws.Kline.StartTime = 1699999980000
+	ws.Kline.ClosePrice = "101.5"
+	m.processKlineUpdate("BTCUSDT", ws, "3m")
+
+	// 4h K线不推送价格，避免重复
+	m.processKlineUpdate("BTCUSDT", ws, "4h")
+
+	select {
+	case u := <-updates:
+		if u.Symbol != "BTCUSDT" || u.Price != 101.5 || u.Time.UnixMilli() != ws.EventTime {
+			t.Errorf("unexpected update: %+v", u)
+		}
+	default:
+		t.Fatal("expected a price update")
+	}
+	if len(updates) != 0 {
+		t.Errorf("expected only one update, got %d more", len(updates))
+	}
+
+	// 通道满时丢弃，不阻塞
+	for i := 0; i < 5; i++ {
+		m.processKlineUpdate("BTCUSDT", ws, "3m")
+	}
+	if len(updates) != 2 {
+		t.Errorf("buffered updates = %d, want 2", len(updates))
+	}
+
+	unsubscribe()
+	unsubscribe()
+	m.processKlineUpdate("BTCUSDT", ws, "3m")
+}
@@ -0,0 +1,240 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 行情源名称
+const (
+	ProviderBinance     = "binance"
+	ProviderHyperliquid = "hyperliquid"
+	ProviderAster       = "aster"
+)
+
+// asterBaseURL Aster 合约 REST 地址（行情接口与币安兼容）
+const asterBaseURL = "https://fapi.asterdex.com"
+
+// symbolListTTL 交易所上线币种列表的缓存时间
+const symbolListTTL = time.Hour
+
+// Provider 市场数据来源：每个交易员使用自己所在交易所的K线、资金费率与持仓量
+// 币种统一使用 Normalize 后的格式（如 BTCUSDT），由实现负责转换为交易所格式
+type Provider interface {
+	// Name 行情源名称（binance / hyperliquid / aster）
+	Name() string
+	// GetKlines 获取最近 limit 根K线（按时间正序）
+	GetKlines(symbol, interval string, limit int) ([]Kline, error)
+	// GetFundingRate 获取最新资金费率
+	GetFundingRate(symbol string) (float64, error)
+	// GetOpenInterest 获取当前持仓量（币数）
+	GetOpenInterest(symbol string) (*OIData, error)
+	// Symbols 返回交易所当前可交易的永续合约币种
+	Symbols() ([]string, error)
+}
+
+// intervalRegistrar 支持按需订阅附加K线周期的行情源（如币安 WebSocket）
+type intervalRegistrar interface {
+	RegisterIntervals(intervals ...string)
+}
+
+// NewProvider 按交易所名称创建行情源，未知交易所（如模拟盘）使用币安行情
+func NewProvider(exchange string, testnet bool) Provider {
+	switch strings.ToLower(exchange) {
+	case ProviderHyperliquid:
+		return NewHyperliquidProvider(testnet)
+	case ProviderAster:
+		return NewAsterProvider()
+	default:
+		return DefaultProvider()
+	}
+}
+
+// DefaultProvider 默认行情源（币安，K线优先使用 WSMonitorCli 缓存）
+func DefaultProvider() Provider {
+	return binanceProvider
+}
+
+var binanceProvider = &BinanceProvider{}
+
+// BinanceProvider 币安合约行情：K线来自 WebSocket 缓存（未初始化时走 REST），OI 与资金费率走 REST
+type BinanceProvider struct {
+	symbols symbolCache
+	funding fundingIntervalCache
+}
+
+// Name 行情源名称
+func (p *BinanceProvider) Name() string { return ProviderBinance }
+
+// GetKlines 获取K线（WSMonitor 可用时使用实时缓存并自动订阅，超出缓存长度的部分从K线库补充）
+func (p *BinanceProvider) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	if WSMonitorCli != nil {
+		klines, err := WSMonitorCli.GetCurrentKlines(symbol, interval)
+		if err != nil {
+			return nil, err
+		}
+		if limit > len(klines) {
+			klines = prependStored(GetKlineStore(), symbol, interval, klines, limit)
+		}
+		return tailKlines(klines, limit), nil
+	}
+	return NewAPIClient().GetKlines(symbol, interval, limit)
+}
+
+// prependStored 用K线库中更早的K线补足实时缓存，使总数尽量达到 limit
+func prependStored(store *KlineStore, symbol, interval string, klines []Kline, limit int) []Kline {
+	if store == nil || len(klines) == 0 {
+		return klines
+	}
+	older, err := store.Before(symbol, interval, klines[0].OpenTime, limit-len(klines))
+	if err != nil {
+		log.Printf("⚠️  读取 %s %s 本地K线失败: %v", symbol, interval, err)
+		return klines
+	}
+	return append(older, klines...)
+}
+
+// GetFundingRate 获取资金费率（1 小时缓存）
+func (p *BinanceProvider) GetFundingRate(symbol string) (float64, error) {
+	return getFundingRate(symbol)
+}
+
+// GetOpenInterest 获取持仓量
+func (p *BinanceProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	return getOpenInterestData(symbol)
+}
+
+// GetOrderBook 获取盘口（WSMonitor 可用时使用部分深度流缓存）
+func (p *BinanceProvider) GetOrderBook(symbol string) (*OrderBook, error) {
+	if WSMonitorCli != nil {
+		return WSMonitorCli.GetOrderBook(symbol)
+	}
+	return NewAPIClient().GetOrderBook(symbol, orderBookRESTLimit)
+}
+
+// Symbols 返回可交易的 USDT 永续合约
+func (p *BinanceProvider) Symbols() ([]string, error) {
+	return p.symbols.get(func() ([]string, error) {
+		return NewAPIClient().perpetualSymbols()
+	})
+}
+
+// RegisterIntervals 注册附加K线周期到 WebSocket 订阅
+func (p *BinanceProvider) RegisterIntervals(intervals ...string) {
+	if WSMonitorCli != nil {
+		WSMonitorCli.RegisterIntervals(intervals...)
+	}
+}
+
+// AsterProvider Aster 合约行情（REST 接口与币安兼容）
+type AsterProvider struct {
+	client  *APIClient
+	symbols symbolCache
+	funding fundingIntervalCache
+}
+
+// NewAsterProvider 创建 Aster 行情源
+func NewAsterProvider() *AsterProvider {
+	return &AsterProvider{client: NewAPIClientWithBaseURL(asterBaseURL)}
+}
+
+// Name 行情源名称
+func (p *AsterProvider) Name() string { return ProviderAster }
+
+// GetKlines 获取最近 limit 根K线
+func (p *AsterProvider) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	return p.client.GetKlines(symbol, interval, limit)
+}
+
+// GetFundingRate 获取资金费率（1 小时缓存）
+func (p *AsterProvider) GetFundingRate(symbol string) (float64, error) {
+	return cachedFundingRate(ProviderAster+":"+symbol, func() (float64, error) {
+		return p.client.GetFundingRate(symbol)
+	})
+}
+
+// GetOpenInterest 获取持仓量
+func (p *AsterProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	return p.client.GetOpenInterest(symbol)
+}
+
+// GetOrderBook 获取盘口快照
+func (p *AsterProvider) GetOrderBook(symbol string) (*OrderBook, error) {
+	return p.client.GetOrderBook(symbol, orderBookRESTLimit)
+}
+
+// Symbols 返回可交易的 USDT 永续合约
+func (p *AsterProvider) Symbols() ([]string, error) {
+	return p.symbols.get(p.client.perpetualSymbols)
+}
+
+// perpetualSymbols 从 exchangeInfo 筛选状态为 TRADING 的 USDT 永续合约
+func (c *APIClient) perpetualSymbols() ([]string, error) {
+	info, err := c.GetExchangeInfo()
+	if err != nil {
+		return nil, err
+	}
+	var symbols []string
+	for _, s := range info.Symbols {
+		if s.Status == "TRADING" && s.ContractType == "PERPETUAL" && strings.HasSuffix(strings.ToUpper(s.Symbol), "USDT") {
+			symbols = append(symbols, s.Symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("%s 未返回可交易的永续合约", c.baseURL)
+	}
+	return symbols, nil
+}
+
+// IsListed 币种是否在行情源所在交易所上线（列表获取失败时视为上线，不影响交易）
+func IsListed(provider Provider, symbol string) bool {
+	symbols, err := provider.Symbols()
+	if err != nil {
+		return true
+	}
+	symbol = Normalize(symbol)
+	for _, s := range symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// symbolCache 交易所币种列表缓存
+type symbolCache struct {
+	mu        sync.Mutex
+	symbols   []string
+	updatedAt time.Time
+}
+
+// get 返回缓存的币种列表，过期时调用 fetch 刷新（刷新失败时沿用旧列表）
+func (c *symbolCache) get(fetch func() ([]string, error)) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.symbols != nil && time.Since(c.updatedAt) < symbolListTTL {
+		return c.symbols, nil
+	}
+	symbols, err := fetch()
+	if err != nil {
+		if c.symbols != nil {
+			return c.symbols, nil
+		}
+		return nil, err
+	}
+	c.symbols = symbols
+	c.updatedAt = time.Now()
+	return symbols, nil
+}
+
+// tailKlines 返回最后 limit 根K线（limit<=0 表示全部）
+func tailKlines(klines []Kline, limit int) []Kline {
+	if limit > 0 && len(klines) > limit {
+		return klines[len(klines)-limit:]
+	}
+	return klines
+}
@@ -0,0 +1,291 @@
+package market
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hyperliquid 行情接口地址
+const (
+	hyperliquidMainnetURL = "https://api.hyperliquid.xyz"
+	hyperliquidTestnetURL = "https://api.hyperliquid-testnet.xyz"
+)
+
+// hyperliquidCtxTTL 资产上下文（资金费率、持仓量）的缓存时间，一次请求返回所有币种
+const hyperliquidCtxTTL = 30 * time.Second
+
+// intervalDurations K线周期对应的时长（用于换算 Hyperliquid 的查询起始时间）
+var intervalDurations = map[string]time.Duration{
+	"1m": time.Minute, "3m": 3 * time.Minute, "5m": 5 * time.Minute, "15m": 15 * time.Minute,
+	"30m": 30 * time.Minute, "1h": time.Hour, "2h": 2 * time.Hour, "4h": 4 * time.Hour,
+	"6h": 6 * time.Hour, "8h": 8 * time.Hour, "12h": 12 * time.Hour, "1d": 24 * time.Hour,
+	"3d": 72 * time.Hour, "1w": 7 * 24 * time.Hour,
+}
+
+// HyperliquidProvider Hyperliquid 行情（info 接口）
+// 资金费率为每小时结算的费率，持仓量单位为币数
+type HyperliquidProvider struct {
+	client  *http.Client
+	baseURL string
+
+	mu        sync.Mutex
+	coins     map[string]string              // BTCUSDT -> BTC
+	ctxs      map[string]hyperliquidAssetCtx // 币种 -> 资产上下文
+	updatedAt time.Time
+}
+
+// hyperliquidAssetCtx 单个币种的资产上下文
+type hyperliquidAssetCtx struct {
+	Funding      float64
+	OpenInterest float64
+	MarkPrice    float64
+	OraclePrice  float64
+}
+
+// NewHyperliquidProvider 创建 Hyperliquid 行情源
+func NewHyperliquidProvider(testnet bool) *HyperliquidProvider {
+	base := hyperliquidMainnetURL
+	if testnet {
+		base = hyperliquidTestnetURL
+	}
+	return newHyperliquidProvider(base)
+}
+
+// newHyperliquidProvider 创建指定地址的 Hyperliquid 行情源
+func newHyperliquidProvider(base string) *HyperliquidProvider {
+	return &HyperliquidProvider{
+		client:  NewAPIClient().client,
+		baseURL: strings.TrimRight(base, "/"),
+	}
+}
+
+// Name 行情源名称
+func (p *HyperliquidProvider) Name() string { return ProviderHyperliquid }
+
+// GetKlines 获取最近 limit 根K线（按周期时长换算查询起始时间）
+func (p *HyperliquidProvider) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	step, ok := intervalDurations[interval]
+	if !ok {
+		return nil, fmt.Errorf("Hyperliquid 不支持的K线周期: %s", interval)
+	}
+	if limit <= 0 {
+		limit = klineHistoryLimit
+	}
+	end := time.Now()
+	start := end.Add(-step * time.Duration(limit))
+
+	var candles []struct {
+		OpenTime  int64  `json:"t"`
+		CloseTime int64  `json:"T"`
+		Open      string `json:"o"`
+		High      string `json:"h"`
+		Low       string `json:"l"`
+		Close     string `json:"c"`
+		Volume    string `json:"v"`
+		Trades    int    `json:"n"`
+	}
+	err := p.post(map[string]interface{}{
+		"type": "candleSnapshot",
+		"req": map[string]interface{}{
+			"coin":      p.coin(symbol),
+			"interval":  interval,
+			"startTime": start.UnixMilli(),
+			"endTime":   end.UnixMilli(),
+		},
+	}, &candles)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Hyperliquid %s K线失败: %w", symbol, err)
+	}
+
+	klines := make([]Kline, 0, len(candles))
+	for _, c := range candles {
+		k := Kline{OpenTime: c.OpenTime, CloseTime: c.CloseTime, Trades: c.Trades}
+		k.Open, _ = strconv.ParseFloat(c.Open, 64)
+		k.High, _ = strconv.ParseFloat(c.High, 64)
+		k.Low, _ = strconv.ParseFloat(c.Low, 64)
+		k.Close, _ = strconv.ParseFloat(c.Close, 64)
+		k.Volume, _ = strconv.ParseFloat(c.Volume, 64)
+		k.QuoteVolume = k.Volume * k.Close
+		klines = append(klines, k)
+	}
+	return tailKlines(klines, limit), nil
+}
+
+// GetFundingRate 获取最新资金费率（每小时费率）
+func (p *HyperliquidProvider) GetFundingRate(symbol string) (float64, error) {
+	ctx, err := p.assetCtx(symbol)
+	if err != nil {
+		return 0, err
+	}
+	return ctx.Funding, nil
+}
+
+// GetOpenInterest 获取持仓量
+func (p *HyperliquidProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	ctx, err := p.assetCtx(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return &OIData{Latest: ctx.OpenInterest, Average: ctx.OpenInterest * 0.999}, nil
+}
+
+// GetOrderBook 获取盘口快照（l2Book，每边最多20档）
+func (p *HyperliquidProvider) GetOrderBook(symbol string) (*OrderBook, error) {
+	var book struct {
+		Time   int64 `json:"time"`
+		Levels [][]struct {
+			Px string `json:"px"`
+			Sz string `json:"sz"`
+		} `json:"levels"`
+	}
+	if err := p.post(map[string]string{"type": "l2Book", "coin": p.coin(symbol)}, &book); err != nil {
+		return nil, fmt.Errorf("获取 Hyperliquid %s 盘口失败: %w", symbol, err)
+	}
+	if len(book.Levels) != 2 {
+		return nil, fmt.Errorf("Hyperliquid %s 盘口格式异常", symbol)
+	}
+
+	var raw [2][][]string // 买盘、卖盘
+	for i, side := range book.Levels {
+		for _, level := range side {
+			raw[i] = append(raw[i], []string{level.Px, level.Sz})
+		}
+	}
+	return &OrderBook{
+		Symbol: Normalize(symbol),
+		Bids:   parseBookLevels(raw[0]),
+		Asks:   parseBookLevels(raw[1]),
+		Time:   time.UnixMilli(book.Time),
+	}, nil
+}
+
+// Symbols 返回可交易的永续合约（转换为 XXXUSDT 格式）
+func (p *HyperliquidProvider) Symbols() ([]string, error) {
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	symbols := make([]string, 0, len(p.coins))
+	for symbol := range p.coins {
+		symbols = append(symbols, symbol)
+	}
+	return symbols, nil
+}
+
+// coin 将标准币种转换为 Hyperliquid 币种名（优先使用 meta 中的原始名称，如 kPEPE）
+func (p *HyperliquidProvider) coin(symbol string) string {
+	symbol = Normalize(symbol)
+	_ = p.refresh() // 获取失败时按去掉 USDT 后缀处理
+	p.mu.Lock()
+	coin, ok := p.coins[symbol]
+	p.mu.Unlock()
+	if ok {
+		return coin
+	}
+	return strings.TrimSuffix(symbol, "USDT")
+}
+
+// assetCtx 返回币种的资产上下文
+func (p *HyperliquidProvider) assetCtx(symbol string) (hyperliquidAssetCtx, error) {
+	if err := p.refresh(); err != nil {
+		return hyperliquidAssetCtx{}, err
+	}
+	symbol = Normalize(symbol)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ctx, ok := p.ctxs[symbol]
+	if !ok {
+		return hyperliquidAssetCtx{}, fmt.Errorf("Hyperliquid 未上线 %s", symbol)
+	}
+	return ctx, nil
+}
+
+// refresh 缓存过期时重新获取 metaAndAssetCtxs（一次返回所有币种）
+func (p *HyperliquidProvider) refresh() error {
+	p.mu.Lock()
+	fresh := p.ctxs != nil && time.Since(p.updatedAt) < hyperliquidCtxTTL
+	p.mu.Unlock()
+	if fresh {
+		return nil
+	}
+
+	var raw []json.RawMessage
+	if err := p.post(map[string]string{"type": "metaAndAssetCtxs"}, &raw); err != nil {
+		return fmt.Errorf("获取 Hyperliquid 资产信息失败: %w", err)
+	}
+	if len(raw) != 2 {
+		return fmt.Errorf("Hyperliquid 资产信息格式异常")
+	}
+
+	var meta struct {
+		Universe []struct {
+			Name       string `json:"name"`
+			IsDelisted bool   `json:"isDelisted"`
+		} `json:"universe"`
+	}
+	var assetCtxs []struct {
+		Funding      string `json:"funding"`
+		OpenInterest string `json:"openInterest"`
+		MarkPx       string `json:"markPx"`
+		OraclePx     string `json:"oraclePx"`
+	}
+	if err := json.Unmarshal(raw[0], &meta); err != nil {
+		return fmt.Errorf("解析 Hyperliquid meta 失败: %w", err)
+	}
+	if err := json.Unmarshal(raw[1], &assetCtxs); err != nil {
+		return fmt.Errorf("解析 Hyperliquid 资产上下文失败: %w", err)
+	}
+
+	coins := make(map[string]string, len(meta.Universe))
+	ctxs := make(map[string]hyperliquidAssetCtx, len(meta.Universe))
+	for i, asset := range meta.Universe {
+		if asset.IsDelisted || i >= len(assetCtxs) {
+			continue
+		}
+		symbol := Normalize(asset.Name)
+		coins[symbol] = asset.Name
+		ctx := hyperliquidAssetCtx{}
+		ctx.Funding, _ = strconv.ParseFloat(assetCtxs[i].Funding, 64)
+		ctx.OpenInterest, _ = strconv.ParseFloat(assetCtxs[i].OpenInterest, 64)
+		ctx.MarkPrice, _ = strconv.ParseFloat(assetCtxs[i].MarkPx, 64)
+		ctx.OraclePrice, _ = strconv.ParseFloat(assetCtxs[i].OraclePx, 64)
+		ctxs[symbol] = ctx
+	}
+
+	p.mu.Lock()
+	p.coins = coins
+	p.ctxs = ctxs
+	p.updatedAt = time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+// post 调用 info 接口
+func (p *HyperliquidProvider) post(payload interface{}, result interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Post(p.baseURL+"/info", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(data))
+	}
+	return json.Unmarshal(data, result)
+}
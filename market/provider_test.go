@@ -0,0 +1,181 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeProvider 固定返回K线的行情源
+type fakeProvider struct {
+	klines  map[string][]Kline // interval -> K线
+	funding float64
+	oi      float64
+	symbols []string
+}
+
+func (f *fakeProvider) Name() string { return "fake" }
+
+func (f *fakeProvider) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	klines, ok := f.klines[interval]
+	if !ok {
+		return nil, fmt.Errorf("no %s klines", interval)
+	}
+	return tailKlines(klines, limit), nil
+}
+
+func (f *fakeProvider) GetFundingRate(symbol string) (float64, error) { return f.funding, nil }
+
+func (f *fakeProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	return &OIData{Latest: f.oi, Average: f.oi}, nil
+}
+
+func (f *fakeProvider) Symbols() ([]string, error) {
+	if f.symbols == nil {
+		return nil, fmt.Errorf("symbols unavailable")
+	}
+	return f.symbols, nil
+}
+
+// TestGetFrom 市场数据的K线、资金费率与持仓量都来自指定行情源
+func TestGetFrom(t *testing.T) {
+	p := &fakeProvider{
+		klines:  map[string][]Kline{"3m": zigzagKlines(40, 10), "4h": zigzagKlines(30, 10), "1h": zigzagKlines(30, 10)},
+		funding: 0.0002,
+		oi:      12345,
+	}
+
+	data, err := GetFrom(p, "btc")
+	if err != nil {
+		t.Fatalf("GetFrom returned error: %v", err)
+	}
+	if data.Symbol != "BTCUSDT" || data.FundingRate != 0.0002 || data.OpenInterest.Latest != 12345 {
+		t.Errorf("unexpected data: symbol=%s funding=%v oi=%+v", data.Symbol, data.FundingRate, data.OpenInterest)
+	}
+
+	cfg := DataConfig{Timeframes: []TimeframeSpec{DefaultTimeframe("1h"), DefaultTimeframe("15m")}}
+	data, err = GetWithProvider(p, "BTCUSDT", cfg)
+	if err != nil {
+		t.Fatalf("GetWithProvider returned error: %v", err)
+	}
+	if len(data.Timeframes) != 1 || data.Timeframes[0].Interval != "1h" {
+		t.Errorf("expected only the 1h timeframe (15m unavailable), got %d", len(data.Timeframes))
+	}
+
+	if _, err := GetFrom(&fakeProvider{klines: map[string][]Kline{"3m": zigzagKlines(40, 10)}}, "BTCUSDT"); err == nil {
+		t.Error("GetFrom should fail without 4h klines")
+	}
+}
+
+// TestIsListed 币种列表获取失败时不拦截
+func TestIsListed(t *testing.T) {
+	p := &fakeProvider{symbols: []string{"BTCUSDT", "ETHUSDT"}}
+	if !IsListed(p, "btc") || IsListed(p, "PEPEUSDT") {
+		t.Error("IsListed should match normalized symbols from the provider list")
+	}
+	if !IsListed(&fakeProvider{}, "PEPEUSDT") {
+		t.Error("IsListed should allow symbols when the list is unavailable")
+	}
+}
+
+// TestHyperliquidProvider K线、资金费率、持仓量与币种名称转换
+func TestHyperliquidProvider(t *testing.T) {
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/info" || r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+
+		switch req["type"] {
+		case "metaAndAssetCtxs":
+			fmt.Fprint(w, `[{"universe":[{"name":"BTC"},{"name":"kPEPE"},{"name":"OLD","isDelisted":true}]},
+				[{"funding":"0.0000125","openInterest":"1000.5"},{"funding":"-0.00003","openInterest":"5000000"},{"funding":"0","openInterest":"0"}]]`)
+		case "candleSnapshot":
+			fmt.Fprint(w, `[{"t":0,"T":179999,"s":"kPEPE","i":"3m","o":"1.0","c":"1.1","h":"1.2","l":"0.9","v":"100","n":7},
+				{"t":180000,"T":359999,"s":"kPEPE","i":"3m","o":"1.1","c":"1.3","h":"1.4","l":"1.0","v":"50","n":3}]`)
+		default:
+			t.Errorf("unexpected info type %v", req["type"])
+		}
+	}))
+	defer server.Close()
+
+	p := newHyperliquidProvider(server.URL)
+
+	symbols, err := p.Symbols()
+	if err != nil || len(symbols) != 2 {
+		t.Fatalf("Symbols = %v, %v; want BTCUSDT and KPEPEUSDT", symbols, err)
+	}
+	if rate, err := p.GetFundingRate("BTCUSDT"); err != nil || rate != 0.0000125 {
+		t.Errorf("GetFundingRate = %v, %v", rate, err)
+	}
+	if oi, err := p.GetOpenInterest("kpepe"); err != nil || oi.Latest != 5000000 {
+		t.Errorf("GetOpenInterest = %+v, %v", oi, err)
+	}
+	if _, err := p.GetFundingRate("OLDUSDT"); err == nil {
+		t.Error("delisted coin should not have funding data")
+	}
+
+	klines, err := p.GetKlines("KPEPEUSDT", "3m", 1)
+	if err != nil {
+		t.Fatalf("GetKlines returned error: %v", err)
+	}
+	if len(klines) != 1 || klines[0].Close != 1.3 || klines[0].Volume != 50 || klines[0].CloseTime != 359999 {
+		t.Errorf("unexpected klines: %+v", klines)
+	}
+	last := requests[len(requests)-1]["req"].(map[string]interface{})
+	if last["coin"] != "kPEPE" || last["interval"] != "3m" {
+		t.Errorf("candle request should use the Hyperliquid coin name, got %v", last)
+	}
+
+	// 资产上下文有缓存，多次查询只请求一次
+	metaRequests := 0
+	for _, req := range requests {
+		if req["type"] == "metaAndAssetCtxs" {
+			metaRequests++
+		}
+	}
+	if metaRequests != 1 {
+		t.Errorf("metaAndAssetCtxs requested %d times, want 1", metaRequests)
+	}
+
+	if _, err := p.GetKlines("BTCUSDT", "7m", 10); err == nil {
+		t.Error("unsupported interval should fail")
+	}
+}
+
+// TestAsterProvider 币安兼容接口：上线币种、资金费率与持仓量
+func TestAsterProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fapi/v1/exchangeInfo":
+			fmt.Fprint(w, `{"symbols":[{"symbol":"BTCUSDT","status":"TRADING","contractType":"PERPETUAL"},
+				{"symbol":"ETHUSDT","status":"SETTLING","contractType":"PERPETUAL"},
+				{"symbol":"ASTERUSDT","status":"TRADING","contractType":"PERPETUAL"}]}`)
+		case "/fapi/v1/premiumIndex":
+			fmt.Fprintf(w, `{"symbol":"%s","markPrice":"1.5","lastFundingRate":"-0.0004"}`, r.URL.Query().Get("symbol"))
+		case "/fapi/v1/openInterest":
+			fmt.Fprint(w, `{"symbol":"ASTERUSDT","openInterest":"777"}`)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	p := &AsterProvider{client: NewAPIClientWithBaseURL(server.URL + "/")}
+	symbols, err := p.Symbols()
+	if err != nil || strings.Join(symbols, ",") != "BTCUSDT,ASTERUSDT" {
+		t.Errorf("Symbols = %v, %v", symbols, err)
+	}
+	if rate, err := p.GetFundingRate("ASTERUSDT_TEST"); err != nil || rate != -0.0004 {
+		t.Errorf("GetFundingRate = %v, %v", rate, err)
+	}
+	if oi, err := p.GetOpenInterest("ASTERUSDT"); err != nil || oi.Latest != 777 {
+		t.Errorf("GetOpenInterest = %+v, %v", oi, err)
+	}
+}
@@ -0,0 +1,179 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// DefaultSeriesLength 附加时间框架每个序列输出的数据点数量
+const DefaultSeriesLength = 10
+
+// validIntervals 币安支持的K线周期
+var validIntervals = map[string]bool{
+	"1m": true, "3m": true, "5m": true, "15m": true, "30m": true,
+	"1h": true, "2h": true, "4h": true, "6h": true, "8h": true, "12h": true,
+	"1d": true, "3d": true, "1w": true, "1M": true,
+}
+
+// IndicatorSpec 指标配置
+type IndicatorSpec struct {
+	Name   string `json:"name"`             // 指标名称（见 IndicatorNames）
+	Period int    `json:"period,omitempty"` // 周期，0表示使用指标默认周期
+}
+
+// TimeframeSpec 附加时间框架配置
+type TimeframeSpec struct {
+	Interval     string          `json:"interval"`                // K线周期，如 1m/15m/1h/1d
+	Indicators   []IndicatorSpec `json:"indicators"`              // 该周期上计算的指标
+	SeriesLength int             `json:"series_length,omitempty"` // 输出序列长度，0表示默认10
+}
+
+// DataConfig 市场数据配置：在默认的3分钟/4小时数据之外追加的时间框架
+type DataConfig struct {
+	Timeframes []TimeframeSpec `json:"timeframes"`
+}
+
+// ParseDataConfig 解析市场数据配置JSON，空字符串返回空配置（仅默认数据）
+func ParseDataConfig(raw string) (DataConfig, error) {
+	if strings.TrimSpace(raw) == "" {
+		return DataConfig{}, nil
+	}
+
+	var cfg DataConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return DataConfig{}, fmt.Errorf("解析市场数据配置失败: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return DataConfig{}, err
+	}
+	return cfg, nil
+}
+
+// Validate 校验时间框架与指标配置
+func (c DataConfig) Validate() error {
+	seen := make(map[string]bool)
+	for _, tf := range c.Timeframes {
+		if !validIntervals[tf.Interval] {
+			return fmt.Errorf("不支持的K线周期: %q", tf.Interval)
+		}
+		if seen[tf.Interval] {
+			return fmt.Errorf("K线周期 %s 重复配置", tf.Interval)
+		}
+		seen[tf.Interval] = true
+		if tf.SeriesLength < 0 || tf.SeriesLength > klineHistoryLimit {
+			return fmt.Errorf("%s 序列长度必须在 0-%d 之间", tf.Interval, klineHistoryLimit)
+		}
+		for _, ind := range tf.Indicators {
+			if _, ok := LookupIndicator(ind.Name); !ok {
+				return fmt.Errorf("未知指标 %q（可用: %s）", ind.Name, strings.Join(IndicatorNames(), ", "))
+			}
+			if ind.Period < 0 || ind.Period > klineHistoryLimit {
+				return fmt.Errorf("%s 指标 %s 周期必须在 0-%d 之间", tf.Interval, ind.Name, klineHistoryLimit)
+			}
+		}
+	}
+	return nil
+}
+
+// Intervals 返回配置的全部K线周期
+func (c DataConfig) Intervals() []string {
+	intervals := make([]string, 0, len(c.Timeframes))
+	for _, tf := range c.Timeframes {
+		intervals = append(intervals, tf.Interval)
+	}
+	return intervals
+}
+
+// DefaultTimeframe 使用常用指标组合（EMA20/EMA50、MACD、RSI14、ATR14、布林带）的时间框架
+func DefaultTimeframe(interval string) TimeframeSpec {
+	return TimeframeSpec{
+		Interval: interval,
+		Indicators: []IndicatorSpec{
+			{Name: "ema", Period: 20},
+			{Name: "ema", Period: 50},
+			{Name: "macd"},
+			{Name: "rsi", Period: 14},
+			{Name: "atr", Period: 14},
+			{Name: "bollinger", Period: 20},
+		},
+	}
+}
+
+// BuildTimeframe 根据K线计算附加时间框架数据（纯计算，不访问网络）
+func BuildTimeframe(spec TimeframeSpec, klines []Kline) (*TimeframeData, error) {
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("%s K线数据为空", spec.Interval)
+	}
+
+	length := spec.SeriesLength
+	if length <= 0 {
+		length = DefaultSeriesLength
+	}
+
+	data := &TimeframeData{Interval: spec.Interval}
+	start := len(klines) - length
+	if start < 0 {
+		start = 0
+	}
+	for _, k := range klines[start:] {
+		data.Closes = append(data.Closes, k.Close)
+		data.Volume = append(data.Volume, k.Volume)
+	}
+
+	for _, ind := range spec.Indicators {
+		def, ok := LookupIndicator(ind.Name)
+		if !ok {
+			return nil, fmt.Errorf("未知指标 %q", ind.Name)
+		}
+		period := ind.Period
+		if period == 0 {
+			period = def.DefaultPeriod
+		}
+		data.Indicators = append(data.Indicators, computeIndicator(def, period, klines, length)...)
+	}
+	return data, nil
+}
+
+// GetWithConfig 获取币安市场数据，并按配置追加附加时间框架（未配置时等同于 Get）
+func GetWithConfig(symbol string, cfg DataConfig) (*Data, error) {
+	data, err := Get(symbol)
+	return appendTimeframes(DefaultProvider(), data, err, cfg)
+}
+
+// GetWithProvider 从指定行情源获取市场数据，并按配置追加附加时间框架
+func GetWithProvider(provider Provider, symbol string, cfg DataConfig) (*Data, error) {
+	data, err := GetFrom(provider, symbol)
+	return appendTimeframes(provider, data, err, cfg)
+}
+
+// TimeframeHistoryLimit 附加时间框架请求的K线数量，使长周期指标有足够预热（回测按相同长度截取）
+// 币安实时缓存只保留 klineHistoryLimit 根，更早的部分由K线库补充（未启用K线库时按缓存长度计算）
+const TimeframeHistoryLimit = 3 * klineHistoryLimit
+
+// appendTimeframes 按配置追加附加时间框架
+// 行情源支持时附加周期会自动注册订阅；单个周期获取失败只跳过该周期
+func appendTimeframes(provider Provider, data *Data, err error, cfg DataConfig) (*Data, error) {
+	if err != nil || len(cfg.Timeframes) == 0 {
+		return data, err
+	}
+
+	if registrar, ok := provider.(intervalRegistrar); ok {
+		registrar.RegisterIntervals(cfg.Intervals()...)
+	}
+	for _, spec := range cfg.Timeframes {
+		klines, err := provider.GetKlines(data.Symbol, spec.Interval, TimeframeHistoryLimit)
+		if err != nil {
+			log.Printf("⚠️ 获取 %s %s K线失败，跳过该周期: %v", data.Symbol, spec.Interval, err)
+			continue
+		}
+		tf, err := BuildTimeframe(spec, klines)
+		if err != nil {
+			log.Printf("⚠️ 计算 %s %s 指标失败，跳过该周期: %v", data.Symbol, spec.Interval, err)
+			continue
+		}
+		data.Timeframes = append(data.Timeframes, tf)
+	}
+	return data, nil
+}
@@ -15,6 +15,8 @@ type Data struct {
 	FundingRate       float64
 	IntradaySeries    *IntradayData
 	LongerTermContext *LongerTermData
+	Timeframes        []*TimeframeData // 按配置追加的时间框架（未配置时为空）
+	Liquidity         *LiquidityData   // 盘口流动性（行情源不支持或获取失败时为空）
 }
 
 // OIData Open Interest数据
@@ -46,6 +48,31 @@ type LongerTermData struct {
 	RSI14Values   []float64
 }
 
+// TimeframeData 附加时间框架数据（序列按时间从旧到新）
+type TimeframeData struct {
+	Interval   string
+	Closes     []float64
+	Volume     []float64
+	Indicators []IndicatorSeries
+}
+
+// IndicatorSeries 单个指标输出序列
+type IndicatorSeries struct {
+	Name   string // 指标名称，如 bollinger
+	Period int    // 实际使用的周期（0表示无周期参数）
+	Output string // 输出名称，如 upper
+	Label  string // 展示名称，如 "Bollinger Bands (20‑period) upper"
+	Values []float64
+}
+
+// Latest 最新值（无数据时返回0）
+func (s IndicatorSeries) Latest() float64 {
+	if len(s.Values) == 0 {
+		return 0
+	}
+	return s.Values[len(s.Values)-1]
+}
+
 // Binance API 响应结构
 type ExchangeInfo struct {
 	Symbols []SymbolInfo `json:"symbols"`
@@ -82,6 +109,20 @@ type PriceTicker struct {
 	Price  string `json:"price"`
 }
 
+type PremiumIndex struct {
+	Symbol          string `json:"symbol"`
+	MarkPrice       string `json:"markPrice"`
+	IndexPrice      string `json:"indexPrice"`
+	LastFundingRate string `json:"lastFundingRate"`
+	NextFundingTime int64  `json:"nextFundingTime"`
+}
+
+// FundingInfo 资金费率结算配置（/fapi/v1/fundingInfo）
+type FundingInfo struct {
+	Symbol               string `json:"symbol"`
+	FundingIntervalHours int    `json:"fundingIntervalHours"`
+}
+
 type Ticker24hr struct {
 	Symbol             string `json:"symbol"`
 	PriceChange        string `json:"priceChange"`
@@ -3,6 +3,7 @@ package mcp
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -133,6 +134,14 @@ func (client *Client) SetTimeout(timeout time.Duration) {
 	client.httpClient.Timeout = timeout
 }
 
+// SetMaxRetries 设置单次调用的最大尝试次数（小于1时按1次）
+func (client *Client) SetMaxRetries(maxRetries int) {
+	if maxRetries < 1 {
+		maxRetries = 1
+	}
+	client.config.MaxRetries = maxRetries
+}
+
 // CallWithMessages 模板方法 - 固定的重试流程（不可重写）
 func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
 	if client.APIKey == "" {
@@ -214,11 +223,20 @@ func (client *Client) marshalRequestBody(requestBody map[string]any) ([]byte, er
 	return jsonData, nil
 }
 
+// parseMCPResponse 解析响应内容
+// 如果模型返回了工具调用，则返回第一个函数调用的参数JSON（结构化输出），否则返回文本内容
 func (client *Client) parseMCPResponse(body []byte) (string, error) {
 	var result struct {
 		Choices []struct {
 			Message struct {
-				Content string `json:"content"`
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Type     string `json:"type"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
 			} `json:"message"`
 		} `json:"choices"`
 	}
@@ -231,7 +249,31 @@ func (client *Client) parseMCPResponse(body []byte) (string, error) {
 		return "", fmt.Errorf("API返回空响应")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	message := result.Choices[0].Message
+	for _, call := range message.ToolCalls {
+		if call.Function.Arguments != "" {
+			client.logger.Debugf("[%s] 使用工具调用参数作为响应: %s", client.String(), call.Function.Name)
+			return call.Function.Arguments, nil
+		}
+	}
+
+	return message.Content, nil
+}
+
+// SupportsFunctionCalling 是否支持原生 Function Calling
+// 显式配置优先；否则 DeepSeek（reasoner 除外）和 Qwen 默认支持，自定义API默认不支持
+func (client *Client) SupportsFunctionCalling() bool {
+	if client.config != nil && client.config.FunctionCalling != nil {
+		return *client.config.FunctionCalling
+	}
+	switch client.Provider {
+	case ProviderDeepSeek:
+		return !strings.Contains(client.Model, "reasoner")
+	case ProviderQwen:
+		return true
+	default:
+		return false
+	}
 }
 
 func (client *Client) buildUrl() string {
@@ -432,6 +474,9 @@ func (client *Client) callWithRequest(req *Request) (string, error) {
 
 	// 检查 HTTP 状态码
 	if resp.StatusCode != http.StatusOK {
+		if len(req.Tools) > 0 && isToolsRejection(resp.StatusCode, body) {
+			return "", fmt.Errorf("API返回错误 (status %d): %s: %w", resp.StatusCode, string(body), ErrToolsRejected)
+		}
 		return "", fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
 	}
 
@@ -444,6 +489,29 @@ func (client *Client) callWithRequest(req *Request) (string, error) {
 	return result, nil
 }
 
+// ErrToolsRejected 提供商以请求参数错误拒绝了 tools（不支持 Function Calling）
+var ErrToolsRejected = errors.New("提供商拒绝了 tools 参数")
+
+// IsToolsRejected 错误是否由提供商拒绝 tools 引起
+// 故障转移与录制回放会把下层错误转为文本，因此同时按错误文本判断
+func IsToolsRejected(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrToolsRejected) || strings.Contains(err.Error(), ErrToolsRejected.Error())
+}
+
+// isToolsRejection 请求参数类错误且错误信息提到 tools/function 时视为不支持 Function Calling
+func isToolsRejection(statusCode int, body []byte) bool {
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity:
+	default:
+		return false
+	}
+	msg := strings.ToLower(string(body))
+	return strings.Contains(msg, "tool") || strings.Contains(msg, "function")
+}
+
 // buildRequestBodyFromRequest 从 Request 对象构建请求体
 func (client *Client) buildRequestBodyFromRequest(req *Request) map[string]any {
 	// 转换 Message 为 API 格式
@@ -497,7 +565,13 @@ func (client *Client) buildRequestBodyFromRequest(req *Request) map[string]any {
 	}
 
 	if req.ToolChoice != "" {
-		requestBody["tool_choice"] = req.ToolChoice
+		// 指定具体函数时 tool_choice 需要以 JSON 对象发送
+		var choice map[string]any
+		if strings.HasPrefix(strings.TrimSpace(req.ToolChoice), "{") && json.Unmarshal([]byte(req.ToolChoice), &choice) == nil {
+			requestBody["tool_choice"] = choice
+		} else {
+			requestBody["tool_choice"] = req.ToolChoice
+		}
 	}
 
 	if req.Stream {
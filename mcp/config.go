@@ -28,6 +28,9 @@ type Config struct {
 	// 超时配置
 	Timeout time.Duration
 
+	// FunctionCalling 是否使用原生 Function Calling（nil 时按 Provider 自动判断）
+	FunctionCalling *bool
+
 	// 依赖注入
 	Logger     Logger
 	HTTPClient *http.Client
@@ -0,0 +1,289 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 熔断器默认参数
+const (
+	DefaultFailoverThreshold      = 2                // 连续失败次数达到该值后熔断
+	DefaultFailoverCooldown       = 5 * time.Minute  // 熔断后的冷却时间，期间跳过该提供商
+	DefaultFailoverAttemptTimeout = 60 * time.Second // 链中每个提供商单次尝试的超时
+)
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"    // 正常调用
+	BreakerOpen     = "open"      // 熔断中，冷却结束前跳过
+	BreakerHalfOpen = "half_open" // 冷却结束，允许一次试探调用
+)
+
+// FailoverProvider 故障转移链中的一个AI提供商
+type FailoverProvider struct {
+	Name   string // 提供商名称（记录在决策日志与状态接口中）
+	Client AIClient
+}
+
+// ProviderHealth 提供商健康状态
+type ProviderHealth struct {
+	Name                string    `json:"name"`
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	TotalCalls          int       `json:"total_calls"`
+	TotalFailures       int       `json:"total_failures"`
+	AvgLatencyMs        int64     `json:"avg_latency_ms"` // 成功调用的平均耗时
+	LastError           string    `json:"last_error,omitempty"`
+	LastErrorAt         time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt       time.Time `json:"last_success_at,omitempty"`
+	OpenUntil           time.Time `json:"open_until,omitempty"` // 熔断冷却结束时间
+}
+
+// failoverEntry 提供商及其熔断器
+type failoverEntry struct {
+	FailoverProvider
+	health         ProviderHealth
+	totalLatencyMs int64
+	successes      int
+}
+
+// ProviderReporter 可以报告最近一次实际应答的提供商的客户端
+type ProviderReporter interface {
+	LastProvider() string
+}
+
+// AnsweredBy 返回客户端最近一次实际应答的提供商（不支持时返回空字符串）
+func AnsweredBy(client AIClient) string {
+	if reporter, ok := client.(ProviderReporter); ok {
+		return reporter.LastProvider()
+	}
+	return ""
+}
+
+// HealthReporter 可以报告各提供商健康状态的客户端
+type HealthReporter interface {
+	ProviderHealth() []ProviderHealth
+}
+
+// retryLimiter 可以设置最大尝试次数的客户端
+type retryLimiter interface {
+	SetMaxRetries(maxRetries int)
+}
+
+// FailoverClient 故障转移AI客户端：按顺序尝试各提供商，连续失败的提供商熔断一段时间
+// 链中的提供商只尝试一次并使用较短的超时，重试由故障转移链与熔断器负责，避免单个提供商故障阻塞整个周期
+type FailoverClient struct {
+	entries   []*failoverEntry
+	threshold int
+	cooldown  time.Duration
+	logger    Logger
+	now       func() time.Time
+
+	mu   sync.Mutex
+	last *failoverEntry // 最近一次成功应答的提供商
+}
+
+// NewFailoverClient 创建故障转移客户端（providers 按优先级排序）
+// 各提供商改为单次尝试，超时设为 DefaultFailoverAttemptTimeout
+func NewFailoverClient(providers ...FailoverProvider) *FailoverClient {
+	c := &FailoverClient{
+		threshold: DefaultFailoverThreshold,
+		cooldown:  DefaultFailoverCooldown,
+		logger:    &defaultLogger{},
+		now:       time.Now,
+	}
+	for _, p := range providers {
+		if limiter, ok := p.Client.(retryLimiter); ok {
+			limiter.SetMaxRetries(1)
+		}
+		p.Client.SetTimeout(DefaultFailoverAttemptTimeout)
+		c.entries = append(c.entries, &failoverEntry{
+			FailoverProvider: p,
+			health:           ProviderHealth{Name: p.Name, State: BreakerClosed},
+		})
+	}
+	return c
+}
+
+// SetBreaker 设置熔断阈值与冷却时间（非正数保持默认值）
+func (c *FailoverClient) SetBreaker(threshold int, cooldown time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if threshold > 0 {
+		c.threshold = threshold
+	}
+	if cooldown > 0 {
+		c.cooldown = cooldown
+	}
+}
+
+// SetAPIKey 设置首选提供商的API密钥（其余提供商在创建时已配置）
+func (c *FailoverClient) SetAPIKey(apiKey string, customURL string, customModel string) {
+	if len(c.entries) > 0 {
+		c.entries[0].Client.SetAPIKey(apiKey, customURL, customModel)
+	}
+}
+
+// SetTimeout 透传到所有提供商（作为单次尝试的超时）
+func (c *FailoverClient) SetTimeout(timeout time.Duration) {
+	for _, e := range c.entries {
+		e.Client.SetTimeout(timeout)
+	}
+}
+
+// SupportsFunctionCalling 任一提供商支持时返回 true（不支持的提供商会退化为文本调用）
+func (c *FailoverClient) SupportsFunctionCalling() bool {
+	for _, e := range c.entries {
+		if SupportsFunctionCalling(e.Client) {
+			return true
+		}
+	}
+	return false
+}
+
+// CallWithMessages 按故障转移顺序调用
+func (c *FailoverClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	return c.call(func(client AIClient) (string, error) {
+		return client.CallWithMessages(systemPrompt, userPrompt)
+	})
+}
+
+// CallWithRequest 按故障转移顺序调用（每个提供商使用请求副本，不支持 Function Calling 的提供商改用文本调用）
+func (c *FailoverClient) CallWithRequest(req *Request) (string, error) {
+	return c.call(func(client AIClient) (string, error) {
+		if len(req.Tools) > 0 && !SupportsFunctionCalling(client) {
+			return client.CallWithMessages(splitRequestPrompts(req))
+		}
+		attempt := *req
+		return client.CallWithRequest(&attempt)
+	})
+}
+
+// LastProvider 最近一次成功应答的提供商
+func (c *FailoverClient) LastProvider() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last == nil {
+		return ""
+	}
+	return c.last.Name
+}
+
+// modelName 最近一次应答（尚未调用时为首选）提供商的模型名称，供录制使用
+func (c *FailoverClient) modelName() string {
+	c.mu.Lock()
+	entry := c.last
+	if entry == nil && len(c.entries) > 0 {
+		entry = c.entries[0]
+	}
+	c.mu.Unlock()
+	if entry == nil {
+		return ""
+	}
+	if namer, ok := entry.Client.(modelNamer); ok {
+		return namer.modelName()
+	}
+	return ""
+}
+
+// ProviderHealth 返回各提供商的健康状态（按优先级排序）
+func (c *FailoverClient) ProviderHealth() []ProviderHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	result := make([]ProviderHealth, len(c.entries))
+	for i, e := range c.entries {
+		result[i] = e.health
+		if e.health.State == BreakerOpen && !now.Before(e.health.OpenUntil) {
+			result[i].State = BreakerHalfOpen
+		}
+	}
+	return result
+}
+
+// call 依次尝试可用的提供商；全部熔断时仍尝试冷却最早结束的一个，避免整个周期无AI可用
+func (c *FailoverClient) call(invoke func(AIClient) (string, error)) (string, error) {
+	if len(c.entries) == 0 {
+		return "", fmt.Errorf("未配置AI提供商")
+	}
+
+	var errs []string
+	tried := 0
+	for _, e := range c.candidates() {
+		tried++
+		start := c.now()
+		response, err := invoke(e.Client)
+		if err == nil {
+			c.recordSuccess(e, c.now().Sub(start))
+			if len(errs) > 0 {
+				c.logger.Warnf("🔀 AI提供商故障转移: 由 %s 应答（%s）", e.Name, strings.Join(errs, "; "))
+			}
+			return response, nil
+		}
+		c.recordFailure(e, err)
+		errs = append(errs, fmt.Sprintf("%s: %v", e.Name, err))
+	}
+	return "", fmt.Errorf("所有AI提供商均调用失败（尝试 %d/%d 个）: %s", tried, len(c.entries), strings.Join(errs, "; "))
+}
+
+// candidates 返回本次调用按顺序尝试的提供商（跳过冷却中的熔断提供商）
+func (c *FailoverClient) candidates() []*failoverEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	var available []*failoverEntry
+	var soonest *failoverEntry
+	for _, e := range c.entries {
+		if e.health.State == BreakerOpen {
+			if now.Before(e.health.OpenUntil) {
+				if soonest == nil || e.health.OpenUntil.Before(soonest.health.OpenUntil) {
+					soonest = e
+				}
+				continue
+			}
+			e.health.State = BreakerHalfOpen
+		}
+		available = append(available, e)
+	}
+	if len(available) == 0 && soonest != nil {
+		available = append(available, soonest)
+	}
+	return available
+}
+
+// recordSuccess 调用成功：重置熔断器并记录应答的提供商
+func (c *FailoverClient) recordSuccess(e *failoverEntry, latency time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e.health.State != BreakerClosed {
+		c.logger.Infof("✓ AI提供商 %s 已恢复", e.Name)
+	}
+	e.health.State = BreakerClosed
+	e.health.ConsecutiveFailures = 0
+	e.health.TotalCalls++
+	e.health.LastSuccessAt = c.now()
+	e.health.OpenUntil = time.Time{}
+	e.successes++
+	e.totalLatencyMs += latency.Milliseconds()
+	e.health.AvgLatencyMs = e.totalLatencyMs / int64(e.successes)
+	c.last = e
+}
+
+// recordFailure 调用失败：连续失败达到阈值（或试探调用失败）时熔断
+func (c *FailoverClient) recordFailure(e *failoverEntry, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	e.health.ConsecutiveFailures++
+	e.health.TotalCalls++
+	e.health.TotalFailures++
+	e.health.LastError = err.Error()
+	e.health.LastErrorAt = now
+	if e.health.State == BreakerHalfOpen || e.health.ConsecutiveFailures >= c.threshold {
+		e.health.State = BreakerOpen
+		e.health.OpenUntil = now.Add(c.cooldown)
+		c.logger.Warnf("⛔ AI提供商 %s 连续失败 %d 次，熔断至 %s", e.Name, e.health.ConsecutiveFailures, e.health.OpenUntil.Format("15:04:05"))
+	}
+}
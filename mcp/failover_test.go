@@ -0,0 +1,179 @@
+package mcp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProviderClient 可控制失败与 Function Calling 支持的AI客户端
+type fakeProviderClient struct {
+	name      string
+	fail      bool
+	tools     bool
+	calls     int
+	lastModel string
+	textCalls int
+}
+
+func (c *fakeProviderClient) SetAPIKey(apiKey string, customURL string, customModel string) {}
+func (c *fakeProviderClient) SetTimeout(timeout time.Duration)                              {}
+func (c *fakeProviderClient) SupportsFunctionCalling() bool                                 { return c.tools }
+
+func (c *fakeProviderClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	c.calls++
+	c.textCalls++
+	if c.fail {
+		return "", errors.New(c.name + " timeout")
+	}
+	return c.name, nil
+}
+
+func (c *fakeProviderClient) CallWithRequest(req *Request) (string, error) {
+	c.calls++
+	if req.Model == "" {
+		req.Model = c.name + "-model"
+	}
+	c.lastModel = req.Model
+	if c.fail {
+		return "", errors.New(c.name + " timeout")
+	}
+	return c.name, nil
+}
+
+func newTestFailover(clients ...*fakeProviderClient) (*FailoverClient, *time.Time) {
+	providers := make([]FailoverProvider, len(clients))
+	for i, c := range clients {
+		providers[i] = FailoverProvider{Name: c.name, Client: c}
+	}
+	now := time.Unix(1_700_000_000, 0)
+	fc := NewFailoverClient(providers...)
+	fc.logger = NewMockLogger()
+	fc.now = func() time.Time { return now }
+	return fc, &now
+}
+
+// TestFailoverClient 失败时切换到下一个提供商，连续失败后熔断，冷却结束后试探恢复
+func TestFailoverClient(t *testing.T) {
+	deepseek := &fakeProviderClient{name: "deepseek", fail: true}
+	qwen := &fakeProviderClient{name: "qwen"}
+	fc, now := newTestFailover(deepseek, qwen)
+
+	for i := 0; i < 3; i++ {
+		got, err := fc.CallWithMessages("sys", "user")
+		if err != nil || got != "qwen" {
+			t.Fatalf("call %d = %q, %v; want qwen", i, got, err)
+		}
+	}
+	if AnsweredBy(fc) != "qwen" {
+		t.Errorf("AnsweredBy = %q, want qwen", AnsweredBy(fc))
+	}
+	// 连续失败2次后熔断，第3次调用跳过 deepseek
+	if deepseek.calls != 2 {
+		t.Errorf("open breaker should skip deepseek, called %d times", deepseek.calls)
+	}
+	health := fc.ProviderHealth()
+	if health[0].State != BreakerOpen || health[0].TotalFailures != 2 || !strings.Contains(health[0].LastError, "timeout") {
+		t.Errorf("unexpected deepseek health: %+v", health[0])
+	}
+	if health[1].State != BreakerClosed || health[1].TotalCalls != 3 {
+		t.Errorf("unexpected qwen health: %+v", health[1])
+	}
+
+	// 冷却结束后试探调用，失败立即重新熔断
+	*now = now.Add(DefaultFailoverCooldown)
+	if fc.ProviderHealth()[0].State != BreakerHalfOpen {
+		t.Error("breaker should be half-open after cooldown")
+	}
+	fc.CallWithMessages("sys", "user")
+	if deepseek.calls != 3 || fc.ProviderHealth()[0].State != BreakerOpen {
+		t.Errorf("failed trial call should reopen breaker, calls=%d", deepseek.calls)
+	}
+
+	// 恢复后重新由首选提供商应答
+	*now = now.Add(DefaultFailoverCooldown)
+	deepseek.fail = false
+	if got, _ := fc.CallWithMessages("sys", "user"); got != "deepseek" || fc.LastProvider() != "deepseek" {
+		t.Errorf("recovered primary should answer, got %q", got)
+	}
+	if h := fc.ProviderHealth()[0]; h.State != BreakerClosed || h.ConsecutiveFailures != 0 {
+		t.Errorf("success should close breaker: %+v", h)
+	}
+}
+
+// TestFailoverAllFailing 全部失败时返回汇总错误，全部熔断时仍尝试冷却最早结束的提供商
+func TestFailoverAllFailing(t *testing.T) {
+	deepseek := &fakeProviderClient{name: "deepseek", fail: true}
+	qwen := &fakeProviderClient{name: "qwen", fail: true}
+	fc, now := newTestFailover(deepseek, qwen)
+	fc.SetBreaker(1, time.Minute)
+
+	_, err := fc.CallWithMessages("sys", "user")
+	if err == nil || !strings.Contains(err.Error(), "deepseek timeout") || !strings.Contains(err.Error(), "qwen timeout") {
+		t.Fatalf("expected aggregated error, got %v", err)
+	}
+
+	*now = now.Add(30 * time.Second)
+	fc.CallWithMessages("sys", "user")
+	if deepseek.calls != 2 || qwen.calls != 1 {
+		t.Errorf("all-open chain should try soonest provider only, calls deepseek=%d qwen=%d", deepseek.calls, qwen.calls)
+	}
+}
+
+// TestFailoverRequest 每个提供商使用请求副本，不支持 Function Calling 的提供商改用文本调用
+func TestFailoverRequest(t *testing.T) {
+	primary := &fakeProviderClient{name: "deepseek", fail: true, tools: true}
+	backup := &fakeProviderClient{name: "custom", tools: true}
+	fc, _ := newTestFailover(primary, backup)
+
+	req := &Request{Messages: []Message{NewSystemMessage("sys"), NewUserMessage("user")}}
+	if got, err := fc.CallWithRequest(req); err != nil || got != "custom" {
+		t.Fatalf("CallWithRequest = %q, %v", got, err)
+	}
+	if backup.lastModel != "custom-model" || req.Model != "" {
+		t.Errorf("backup should use its own model, got %q (request model %q)", backup.lastModel, req.Model)
+	}
+
+	textOnly := &fakeProviderClient{name: "qwen"}
+	fc, _ = newTestFailover(primary, textOnly)
+	req.Tools = []Tool{{Type: "function"}}
+	if got, err := fc.CallWithRequest(req); err != nil || got != "qwen" || textOnly.textCalls != 1 {
+		t.Errorf("text-only backup should be called with messages: %q, %v", got, err)
+	}
+	if !fc.SupportsFunctionCalling() {
+		t.Error("chain should support function calling when any provider does")
+	}
+}
+
+// TestFailoverSingleAttempt 链中的提供商只尝试一次，并使用较短的单次超时
+func TestFailoverSingleAttempt(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// 直接断开连接（EOF 属于可重试错误）
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer server.Close()
+
+	primary := NewClient(WithLogger(NewMockLogger())).(*Client)
+	primary.SetAPIKey("sk-test", server.URL, "model")
+	backup := &fakeProviderClient{name: "backup"}
+	fc := NewFailoverClient(FailoverProvider{Name: "primary", Client: primary}, FailoverProvider{Name: "backup", Client: backup})
+	fc.logger = NewMockLogger()
+
+	if primary.config.MaxRetries != 1 || primary.httpClient.Timeout != DefaultFailoverAttemptTimeout {
+		t.Fatalf("chain member should make a single short attempt: retries=%d timeout=%v",
+			primary.config.MaxRetries, primary.httpClient.Timeout)
+	}
+	resp, err := fc.CallWithMessages("sys", "user")
+	if err != nil || resp != "backup" {
+		t.Fatalf("CallWithMessages = %q, %v", resp, err)
+	}
+	if requests != 1 {
+		t.Errorf("primary should be tried once before failing over, got %d requests", requests)
+	}
+}
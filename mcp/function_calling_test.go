@@ -0,0 +1,139 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+)
+
+// ============================================================
+// 测试 Function Calling / 结构化输出
+// ============================================================
+
+func TestClient_ParseMCPResponse_ToolCall(t *testing.T) {
+	c := NewClient().(*Client)
+
+	body := []byte(`{"choices":[{"message":{"content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"submit","arguments":"{\"decisions\":[]}"}}]}}]}`)
+	result, err := c.parseMCPResponse(body)
+	if err != nil {
+		t.Fatalf("parseMCPResponse failed: %v", err)
+	}
+	if result != `{"decisions":[]}` {
+		t.Errorf("expected tool call arguments, got %q", result)
+	}
+
+	// 没有工具调用时返回文本内容
+	body = []byte(`{"choices":[{"message":{"content":"plain text"}}]}`)
+	result, err = c.parseMCPResponse(body)
+	if err != nil || result != "plain text" {
+		t.Errorf("expected plain text, got %q, %v", result, err)
+	}
+}
+
+func TestClient_CallWithRequest_ToolChoiceObject(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.SetSuccessResponse("ok")
+	client := NewClient(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithAPIKey("sk-test"),
+		WithLogger(NewMockLogger()),
+	)
+
+	req := NewRequestBuilder().
+		WithUserPrompt("hi").
+		AddFunction("submit", "desc", map[string]any{"type": "object"}).
+		WithToolChoice(`{"type": "function", "function": {"name": "submit"}}`).
+		MustBuild()
+	if _, err := client.CallWithRequest(req); err != nil {
+		t.Fatalf("CallWithRequest failed: %v", err)
+	}
+
+	last := mockHTTP.GetLastRequest()
+	if last == nil {
+		t.Fatal("expected an HTTP request")
+	}
+	raw, _ := io.ReadAll(last.Body)
+	var body map[string]any
+	if err := json.Unmarshal(raw, &body); err != nil {
+		t.Fatalf("invalid request body: %v", err)
+	}
+	choice, ok := body["tool_choice"].(map[string]any)
+	if !ok {
+		t.Fatalf("tool_choice should be sent as object, got %T", body["tool_choice"])
+	}
+	if choice["type"] != "function" {
+		t.Errorf("unexpected tool_choice: %v", choice)
+	}
+	if _, ok := body["tools"].([]any); !ok {
+		t.Error("tools should be present in request body")
+	}
+}
+
+func TestSupportsFunctionCalling(t *testing.T) {
+	tests := []struct {
+		name   string
+		client AIClient
+		want   bool
+	}{
+		{"DeepSeek默认支持", NewDeepSeekClientWithOptions(WithLogger(NewMockLogger())), true},
+		{"DeepSeek reasoner不支持", NewDeepSeekClientWithOptions(WithLogger(NewMockLogger()), WithModel("deepseek-reasoner")), false},
+		{"Qwen默认支持", NewQwenClientWithOptions(WithLogger(NewMockLogger())), true},
+		{"自定义API默认不支持", NewClient(WithProvider(ProviderCustom), WithLogger(NewMockLogger())), false},
+		{"自定义API显式开启", NewClient(WithProvider(ProviderCustom), WithFunctionCalling(true), WithLogger(NewMockLogger())), true},
+		{"DeepSeek显式关闭", NewDeepSeekClientWithOptions(WithFunctionCalling(false), WithLogger(NewMockLogger())), false},
+		{"回放客户端不支持", NewReplayClientFromRecordings(nil), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SupportsFunctionCalling(tt.client); got != tt.want {
+				t.Errorf("SupportsFunctionCalling() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_CallWithRequest_ToolsRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		tools    bool
+		rejected bool
+	}{
+		{"拒绝tools参数", 400, `{"error":{"message":"tools is not supported by this model"}}`, true, true},
+		{"其他参数错误", 400, `{"error":{"message":"max_tokens too large"}}`, true, false},
+		{"服务端错误", 500, `{"error":{"message":"function runtime crashed"}}`, true, false},
+		{"未声明tools", 400, `{"error":{"message":"unknown field: tools"}}`, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockHTTP := NewMockHTTPClient()
+			mockHTTP.SetErrorResponse(tt.status, tt.body)
+			client := NewClient(
+				WithHTTPClient(mockHTTP.ToHTTPClient()),
+				WithAPIKey("sk-test"),
+				WithMaxRetries(1),
+				WithLogger(NewMockLogger()),
+			)
+
+			builder := NewRequestBuilder().WithUserPrompt("hi")
+			if tt.tools {
+				builder = builder.AddFunction("submit", "desc", map[string]any{"type": "object"})
+			}
+			_, err := client.CallWithRequest(builder.MustBuild())
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := IsToolsRejected(err); got != tt.rejected {
+				t.Errorf("IsToolsRejected() = %v, want %v (err: %v)", got, tt.rejected, err)
+			}
+			// 故障转移把错误转为文本后仍可识别
+			if got := IsToolsRejected(errors.New("所有AI提供商均调用失败: " + err.Error())); got != tt.rejected {
+				t.Errorf("IsToolsRejected(text) = %v, want %v", got, tt.rejected)
+			}
+		})
+	}
+}
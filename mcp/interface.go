@@ -13,6 +13,17 @@ type AIClient interface {
 	CallWithRequest(req *Request) (string, error) // 构建器模式 API（支持高级功能）
 }
 
+// FunctionCallingClient 可选接口：报告客户端是否支持原生 Function Calling
+type FunctionCallingClient interface {
+	SupportsFunctionCalling() bool
+}
+
+// SupportsFunctionCalling 判断客户端是否支持原生 Function Calling（未实现可选接口时视为不支持）
+func SupportsFunctionCalling(client AIClient) bool {
+	fc, ok := client.(FunctionCallingClient)
+	return ok && fc.SupportsFunctionCalling()
+}
+
 // clientHooks 内部钩子接口（用于子类重写特定步骤）
 // 这些方法只在包内部使用，实现动态分派
 type clientHooks interface {
@@ -131,6 +131,13 @@ func WithUseFullURL(useFullURL bool) ClientOption {
 	}
 }
 
+// WithFunctionCalling 显式开启/关闭原生 Function Calling（默认按 Provider 判断）
+func WithFunctionCalling(enabled bool) ClientOption {
+	return func(c *Config) {
+		c.FunctionCalling = &enabled
+	}
+}
+
 // ============================================================
 // 组合选项（便捷方法）
 // ============================================================
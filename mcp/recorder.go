@@ -0,0 +1,276 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recording 一次AI调用的完整记录（用于离线回放）
+type Recording struct {
+	Hash         string    `json:"hash"`          // PromptHash(system, user)
+	Timestamp    time.Time `json:"timestamp"`     // 调用时间
+	Model        string    `json:"model"`         // 模型名称
+	SystemPrompt string    `json:"system_prompt"` // 系统提示词
+	UserPrompt   string    `json:"user_prompt"`   // 用户提示词
+	Response     string    `json:"response"`      // AI原始响应
+	Error        string    `json:"error,omitempty"`
+	LatencyMs    int64     `json:"latency_ms"` // 调用耗时（毫秒）
+}
+
+// PromptHash 计算提示词哈希（回放时的查找键）
+func PromptHash(systemPrompt, userPrompt string) string {
+	sum := sha256.Sum256([]byte(systemPrompt + "\x00" + userPrompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// modelNamer 可以报告当前模型名称的客户端
+type modelNamer interface {
+	modelName() string
+}
+
+func (client *Client) modelName() string {
+	return client.Model
+}
+
+// splitRequestPrompts 从请求消息中提取 system 与 user 提示词
+func splitRequestPrompts(req *Request) (systemPrompt, userPrompt string) {
+	var systems, users []string
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system":
+			systems = append(systems, msg.Content)
+		default:
+			users = append(users, msg.Role+": "+msg.Content)
+		}
+	}
+	systemPrompt = strings.Join(systems, "\n")
+	if len(users) == 1 && len(req.Messages) > 0 && req.Messages[len(req.Messages)-1].Role == "user" {
+		// 单轮对话与 CallWithMessages 使用相同的键
+		return systemPrompt, req.Messages[len(req.Messages)-1].Content
+	}
+	return systemPrompt, strings.Join(users, "\n")
+}
+
+// RecordingClient 录制型AI客户端：透传调用并把每次请求/响应写入磁盘
+type RecordingClient struct {
+	inner  AIClient
+	dir    string
+	model  string
+	logger Logger
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecordingClient 包装已有客户端，录制结果保存到 dir
+func NewRecordingClient(inner AIClient, dir string) (*RecordingClient, error) {
+	if inner == nil {
+		return nil, fmt.Errorf("被录制的AI客户端不能为空")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建录制目录失败: %w", err)
+	}
+	return &RecordingClient{inner: inner, dir: dir, logger: &defaultLogger{}}, nil
+}
+
+// SetAPIKey 透传到被包装的客户端
+func (c *RecordingClient) SetAPIKey(apiKey string, customURL string, customModel string) {
+	c.inner.SetAPIKey(apiKey, customURL, customModel)
+	c.model = customModel
+}
+
+// SetTimeout 透传到被包装的客户端
+func (c *RecordingClient) SetTimeout(timeout time.Duration) {
+	c.inner.SetTimeout(timeout)
+}
+
+// CallWithMessages 调用并录制
+func (c *RecordingClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	start := time.Now()
+	response, err := c.inner.CallWithMessages(systemPrompt, userPrompt)
+	c.record(c.currentModel(), systemPrompt, userPrompt, response, err, start)
+	return response, err
+}
+
+// CallWithRequest 调用并录制
+func (c *RecordingClient) CallWithRequest(req *Request) (string, error) {
+	start := time.Now()
+	response, err := c.inner.CallWithRequest(req)
+	systemPrompt, userPrompt := splitRequestPrompts(req)
+	model := req.Model
+	if model == "" {
+		model = c.currentModel()
+	}
+	c.record(model, systemPrompt, userPrompt, response, err, start)
+	return response, err
+}
+
+// SupportsFunctionCalling 与被包装的客户端保持一致
+func (c *RecordingClient) SupportsFunctionCalling() bool {
+	return SupportsFunctionCalling(c.inner)
+}
+
+// LastProvider 被包装的客户端最近一次实际应答的提供商
+func (c *RecordingClient) LastProvider() string {
+	return AnsweredBy(c.inner)
+}
+
+// ProviderHealth 被包装的故障转移客户端的提供商健康状态（不支持时为空）
+func (c *RecordingClient) ProviderHealth() []ProviderHealth {
+	if reporter, ok := c.inner.(HealthReporter); ok {
+		return reporter.ProviderHealth()
+	}
+	return nil
+}
+
+// currentModel 返回被包装客户端的模型名称
+func (c *RecordingClient) currentModel() string {
+	if namer, ok := c.inner.(modelNamer); ok {
+		if name := namer.modelName(); name != "" {
+			return name
+		}
+	}
+	return c.model
+}
+
+// record 写入一条录制（失败只记日志，不影响调用方）
+func (c *RecordingClient) record(model, systemPrompt, userPrompt, response string, callErr error, start time.Time) {
+	rec := Recording{
+		Hash:         PromptHash(systemPrompt, userPrompt),
+		Timestamp:    start,
+		Model:        model,
+		SystemPrompt: systemPrompt,
+		UserPrompt:   userPrompt,
+		Response:     response,
+		LatencyMs:    time.Since(start).Milliseconds(),
+	}
+	if callErr != nil {
+		rec.Error = callErr.Error()
+	}
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		c.logger.Warnf("⚠️  序列化AI调用录制失败: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	c.seq++
+	filename := fmt.Sprintf("ai_%s_%04d_%s.json", start.Format("20060102_150405"), c.seq, rec.Hash[:12])
+	c.mu.Unlock()
+
+	if err := os.WriteFile(filepath.Join(c.dir, filename), data, 0600); err != nil {
+		c.logger.Warnf("⚠️  写入AI调用录制失败: %v", err)
+	}
+}
+
+// LoadRecordings 读取目录下的全部录制（按时间正序）
+func LoadRecordings(dir string) ([]*Recording, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "ai_*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("查找录制文件失败: %w", err)
+	}
+
+	var recordings []*Recording
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取录制文件失败 %s: %w", filepath.Base(file), err)
+		}
+		var rec Recording
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("解析录制文件失败 %s: %w", filepath.Base(file), err)
+		}
+		if rec.Hash == "" {
+			rec.Hash = PromptHash(rec.SystemPrompt, rec.UserPrompt)
+		}
+		recordings = append(recordings, &rec)
+	}
+
+	sort.SliceStable(recordings, func(i, j int) bool {
+		return recordings[i].Timestamp.Before(recordings[j].Timestamp)
+	})
+	return recordings, nil
+}
+
+// ReplayClient 回放型AI客户端：按提示词哈希返回录制的响应，不发起任何网络请求
+// 同一哈希有多条录制时按录制顺序依次返回，用尽后重复最后一条
+type ReplayClient struct {
+	byHash map[string][]*Recording
+
+	mu     sync.Mutex
+	served map[string]int
+}
+
+// NewReplayClient 从录制目录创建回放客户端
+func NewReplayClient(dir string) (*ReplayClient, error) {
+	recordings, err := LoadRecordings(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(recordings) == 0 {
+		return nil, fmt.Errorf("录制目录中没有AI调用记录: %s", dir)
+	}
+	return NewReplayClientFromRecordings(recordings), nil
+}
+
+// NewReplayClientFromRecordings 从内存中的录制创建回放客户端
+func NewReplayClientFromRecordings(recordings []*Recording) *ReplayClient {
+	c := &ReplayClient{
+		byHash: make(map[string][]*Recording),
+		served: make(map[string]int),
+	}
+	for _, rec := range recordings {
+		hash := rec.Hash
+		if hash == "" {
+			hash = PromptHash(rec.SystemPrompt, rec.UserPrompt)
+		}
+		c.byHash[hash] = append(c.byHash[hash], rec)
+	}
+	return c
+}
+
+// SetAPIKey 回放客户端无需API密钥
+func (c *ReplayClient) SetAPIKey(apiKey string, customURL string, customModel string) {}
+
+// SetTimeout 回放客户端无需超时设置
+func (c *ReplayClient) SetTimeout(timeout time.Duration) {}
+
+// CallWithMessages 按提示词哈希返回录制的响应
+func (c *ReplayClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	hash := PromptHash(systemPrompt, userPrompt)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	recs := c.byHash[hash]
+	if len(recs) == 0 {
+		return "", fmt.Errorf("未找到提示词哈希 %s 的录制", hash[:12])
+	}
+
+	idx := c.served[hash]
+	if idx >= len(recs) {
+		idx = len(recs) - 1
+	}
+	c.served[hash]++
+
+	rec := recs[idx]
+	if rec.Error != "" {
+		return "", fmt.Errorf("回放录制的错误: %s", rec.Error)
+	}
+	return rec.Response, nil
+}
+
+// CallWithRequest 按提示词哈希返回录制的响应
+func (c *ReplayClient) CallWithRequest(req *Request) (string, error) {
+	systemPrompt, userPrompt := splitRequestPrompts(req)
+	return c.CallWithMessages(systemPrompt, userPrompt)
+}
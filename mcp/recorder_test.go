@@ -0,0 +1,115 @@
+package mcp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newRecordedDeepSeek 创建使用 mock HTTP 的 DeepSeek 客户端并包装录制
+func newRecordedDeepSeek(t *testing.T, mockHTTP *MockHTTPClient, dir string) *RecordingClient {
+	t.Helper()
+	inner := NewDeepSeekClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithAPIKey("sk-test"),
+		WithLogger(NewMockLogger()),
+		WithMaxRetries(1),
+	)
+	recorder, err := NewRecordingClient(inner, dir)
+	if err != nil {
+		t.Fatalf("NewRecordingClient failed: %v", err)
+	}
+	recorder.logger = NewMockLogger()
+	return recorder
+}
+
+func TestRecordingClient_RecordsAndReplays(t *testing.T) {
+	dir := t.TempDir()
+	mockHTTP := NewMockHTTPClient()
+	recorder := newRecordedDeepSeek(t, mockHTTP, dir)
+
+	mockHTTP.SetSuccessResponse("first answer")
+	if _, err := recorder.CallWithMessages("sys", "user-1"); err != nil {
+		t.Fatalf("first call failed: %v", err)
+	}
+	mockHTTP.SetSuccessResponse("second answer")
+	if _, err := recorder.CallWithMessages("sys", "user-2"); err != nil {
+		t.Fatalf("second call failed: %v", err)
+	}
+
+	recordings, err := LoadRecordings(dir)
+	if err != nil {
+		t.Fatalf("LoadRecordings failed: %v", err)
+	}
+	if len(recordings) != 2 {
+		t.Fatalf("expected 2 recordings, got %d", len(recordings))
+	}
+	rec := recordings[0]
+	if rec.Model != DefaultDeepSeekModel {
+		t.Errorf("Model = %q, want %q", rec.Model, DefaultDeepSeekModel)
+	}
+	if rec.Hash != PromptHash("sys", "user-1") {
+		t.Errorf("Hash mismatch")
+	}
+	if rec.SystemPrompt != "sys" || rec.UserPrompt != "user-1" || rec.Response != "first answer" {
+		t.Errorf("unexpected recording: %+v", rec)
+	}
+
+	// 回放：不经过网络，按提示词哈希取回响应
+	replay, err := NewReplayClient(dir)
+	if err != nil {
+		t.Fatalf("NewReplayClient failed: %v", err)
+	}
+	mockHTTP.Reset()
+
+	got, err := replay.CallWithMessages("sys", "user-2")
+	if err != nil || got != "second answer" {
+		t.Errorf("replay user-2 = %q, %v; want %q", got, err, "second answer")
+	}
+	got, err = replay.CallWithRequest(&Request{Messages: []Message{NewSystemMessage("sys"), NewUserMessage("user-1")}})
+	if err != nil || got != "first answer" {
+		t.Errorf("replay request user-1 = %q, %v; want %q", got, err, "first answer")
+	}
+	if len(mockHTTP.GetRequests()) != 0 {
+		t.Errorf("replay should not issue HTTP requests")
+	}
+
+	if _, err := replay.CallWithMessages("sys", "unknown"); err == nil {
+		t.Error("replay should fail for unrecorded prompts")
+	}
+}
+
+func TestRecordingClient_RecordsErrors(t *testing.T) {
+	dir := t.TempDir()
+	mockHTTP := NewMockHTTPClient()
+	recorder := newRecordedDeepSeek(t, mockHTTP, dir)
+
+	mockHTTP.SetNetworkError(errors.New("boom"))
+	if _, err := recorder.CallWithMessages("sys", "user"); err == nil {
+		t.Fatal("expected error from inner client")
+	}
+
+	replay, err := NewReplayClient(dir)
+	if err != nil {
+		t.Fatalf("NewReplayClient failed: %v", err)
+	}
+	_, err = replay.CallWithMessages("sys", "user")
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("replay should reproduce recorded error, got %v", err)
+	}
+}
+
+func TestReplayClient_SameHashServedInOrder(t *testing.T) {
+	replay := NewReplayClientFromRecordings([]*Recording{
+		{SystemPrompt: "s", UserPrompt: "u", Response: "a"},
+		{SystemPrompt: "s", UserPrompt: "u", Response: "b"},
+	})
+
+	want := []string{"a", "b", "b"}
+	for i, w := range want {
+		got, err := replay.CallWithMessages("s", "u")
+		if err != nil || got != w {
+			t.Errorf("call %d = %q, %v; want %q", i, got, err, w)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"nofx/logger"
+	"os"
+	"path/filepath"
+)
+
+// runMigrateDecisionsCommand 把 decision_logs/<trader_id>/*.json 导入SQLite决策日志库：nofx migrate-decisions [flags]
+// 保留原周期编号与时间戳，已导入的记录会被跳过，可重复执行
+func runMigrateDecisionsCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate-decisions", flag.ContinueOnError)
+	logDir := fs.String("dir", "decision_logs", "JSON决策日志根目录（每个交易员一个子目录）")
+	dbPath := fs.String("db", logger.DefaultDecisionLogDBPath, "SQLite决策日志库路径")
+	traderID := fs.String("trader", "", "只迁移指定交易员（默认迁移全部）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(*logDir)
+	if err != nil {
+		return fmt.Errorf("读取决策日志目录失败: %w", err)
+	}
+
+	var traders, imported, skipped int
+	for _, entry := range entries {
+		if !entry.IsDir() || (*traderID != "" && entry.Name() != *traderID) {
+			continue
+		}
+		records, err := logger.NewDecisionLogger(filepath.Join(*logDir, entry.Name())).GetLatestRecords(math.MaxInt32)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			continue
+		}
+
+		sqliteLogger, err := logger.NewSQLiteDecisionLogger(*dbPath, entry.Name())
+		if err != nil {
+			return err
+		}
+		var traderImported int
+		for _, record := range records {
+			inserted, err := sqliteLogger.ImportRecord(record)
+			if err != nil {
+				return fmt.Errorf("导入交易员 %s 周期 #%d 失败: %w", entry.Name(), record.CycleNumber, err)
+			}
+			if inserted {
+				traderImported++
+			} else {
+				skipped++
+			}
+		}
+		traders++
+		imported += traderImported
+		log.Printf("✓ 交易员 %s: 导入 %d/%d 条决策记录", entry.Name(), traderImported, len(records))
+	}
+
+	log.Printf("📊 迁移完成: %d 个交易员 | 导入 %d 条 | 已存在跳过 %d 条 | 目标库 %s", traders, imported, skipped, *dbPath)
+	log.Printf("💡 在 config.json 中设置 \"decision_log_backend\": \"sqlite\" 后重启即可使用SQLite存储")
+	return nil
+}
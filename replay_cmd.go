@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/mcp"
+	"os"
+)
+
+// runReplayCommand 用录制的AI响应重跑历史决策周期：nofx replay [flags]
+// 不调用任何AI API，用于复现解析失败或验证新的校验逻辑
+func runReplayCommand(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	decisionDir := fs.String("decisions", "", "决策日志目录，如 decision_logs/<trader_id>")
+	recordingDir := fs.String("recordings", "", "AI录制目录，如 ai_recordings/<trader_id>")
+	btcEthLeverage := fs.Int("btc-eth-leverage", 5, "BTC/ETH杠杆上限")
+	altcoinLeverage := fs.Int("altcoin-leverage", 5, "山寨币杠杆上限")
+	failedOnly := fs.Bool("failed-only", false, "只重跑原本失败的周期")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *decisionDir == "" || *recordingDir == "" {
+		return fmt.Errorf("必须指定 -decisions 和 -recordings")
+	}
+	if _, err := os.Stat(*decisionDir); err != nil {
+		return fmt.Errorf("决策日志目录不可用: %w", err)
+	}
+
+	replayClient, err := mcp.NewReplayClient(*recordingDir)
+	if err != nil {
+		return err
+	}
+
+	records, err := logger.NewDecisionLogger(*decisionDir).GetLatestRecords(math.MaxInt32)
+	if err != nil {
+		return err
+	}
+
+	var replayed, missing, passed, failed, changed int
+	for _, record := range records {
+		if record.SystemPrompt == "" || record.InputPrompt == "" {
+			continue
+		}
+		if *failedOnly && record.Success {
+			continue
+		}
+
+		equity := record.AccountState.TotalBalance + record.AccountState.TotalUnrealizedProfit
+		full, err := decision.ReplayDecision(replayClient, record.SystemPrompt, record.InputPrompt,
+			equity, *btcEthLeverage, *altcoinLeverage)
+		if err != nil && full == nil {
+			missing++
+			log.Printf("⚠️  周期 #%d (%s): %v", record.CycleNumber, record.Timestamp.Format("2006-01-02 15:04:05"), err)
+			continue
+		}
+
+		replayed++
+		if err != nil {
+			failed++
+			log.Printf("❌ 周期 #%d (%s): %v", record.CycleNumber, record.Timestamp.Format("2006-01-02 15:04:05"), err)
+		} else {
+			passed++
+			log.Printf("✓ 周期 #%d (%s): %d 个决策", record.CycleNumber, record.Timestamp.Format("2006-01-02 15:04:05"), len(full.Decisions))
+		}
+		if (err == nil) != record.Success {
+			changed++
+		}
+	}
+
+	log.Printf("📊 回放完成: 重跑 %d 个周期 | 通过 %d | 失败 %d | 与原结果不同 %d | 缺少录制 %d",
+		replayed, passed, failed, changed, missing)
+	return nil
+}
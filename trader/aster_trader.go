@@ -36,6 +36,7 @@ type AsterTrader struct {
 
 	// 缓存交易对精度信息
 	symbolPrecision map[string]SymbolPrecision
+	orderTypeCache  map[int64]string // 订单ID -> 原始订单类型（对账时区分止损止盈成交）
 	mu              sync.RWMutex
 }
 
@@ -432,7 +433,7 @@ func (t *AsterTrader) doRequest(method, endpoint string, params map[string]inter
 }
 
 // GetBalance 获取账户余额
-func (t *AsterTrader) GetBalance() (map[string]interface{}, error) {
+func (t *AsterTrader) GetBalance() (*Balance, error) {
 	params := make(map[string]interface{})
 	body, err := t.request("GET", "/fapi/v3/balance", params)
 	if err != nil {
@@ -477,10 +478,10 @@ func (t *AsterTrader) GetBalance() (map[string]interface{}, error) {
 	if err != nil {
 		log.Printf("⚠️  获取持仓信息失败: %v", err)
 		// fallback: 无法获取持仓时使用简单计算
-		return map[string]interface{}{
-			"totalWalletBalance":    crossWalletBalance,
-			"availableBalance":      availableBalance,
-			"totalUnrealizedProfit": crossUnPnl,
+		return &Balance{
+			TotalWalletBalance:    crossWalletBalance,
+			AvailableBalance:      availableBalance,
+			TotalUnrealizedProfit: crossUnPnl,
 		}, nil
 	}
 
@@ -489,20 +490,8 @@ func (t *AsterTrader) GetBalance() (map[string]interface{}, error) {
 	totalMarginUsed := 0.0
 	realUnrealizedPnl := 0.0
 	for _, pos := range positions {
-		markPrice := pos["markPrice"].(float64)
-		quantity := pos["positionAmt"].(float64)
-		if quantity < 0 {
-			quantity = -quantity
-		}
-		unrealizedPnl := pos["unRealizedProfit"].(float64)
-		realUnrealizedPnl += unrealizedPnl
-
-		leverage := 10
-		if lev, ok := pos["leverage"].(float64); ok {
-			leverage = int(lev)
-		}
-		marginUsed := (quantity * markPrice) / float64(leverage)
-		totalMarginUsed += marginUsed
+		realUnrealizedPnl += pos.UnrealizedPnL
+		totalMarginUsed += pos.MarginUsed()
 	}
 
 	// ✅ Aster 正确计算方式:
@@ -512,15 +501,15 @@ func (t *AsterTrader) GetBalance() (map[string]interface{}, error) {
 	totalEquity := availableBalance + totalMarginUsed
 	totalWalletBalance := totalEquity - realUnrealizedPnl
 
-	return map[string]interface{}{
-		"totalWalletBalance":    totalWalletBalance, // 钱包余额（不含未实现盈亏）
-		"availableBalance":      availableBalance,   // 可用余额
-		"totalUnrealizedProfit": realUnrealizedPnl,  // 未实现盈亏（从持仓累加）
+	return &Balance{
+		TotalWalletBalance:    totalWalletBalance, // 钱包余额（不含未实现盈亏）
+		AvailableBalance:      availableBalance,   // 可用余额
+		TotalUnrealizedProfit: realUnrealizedPnl,  // 未实现盈亏（从持仓累加）
 	}, nil
 }
 
 // GetPositions 获取持仓信息
-func (t *AsterTrader) GetPositions() ([]map[string]interface{}, error) {
+func (t *AsterTrader) GetPositions() ([]Position, error) {
 	params := make(map[string]interface{})
 	body, err := t.request("GET", "/fapi/v3/positionRisk", params)
 	if err != nil {
@@ -532,7 +521,7 @@ func (t *AsterTrader) GetPositions() ([]map[string]interface{}, error) {
 		return nil, err
 	}
 
-	result := []map[string]interface{}{}
+	result := []Position{}
 	for _, pos := range positions {
 		posAmtStr, ok := pos["positionAmt"].(string)
 		if !ok {
@@ -557,16 +546,16 @@ func (t *AsterTrader) GetPositions() ([]map[string]interface{}, error) {
 			posAmt = -posAmt
 		}
 
-		// 返回与Binance相同的字段名
-		result = append(result, map[string]interface{}{
-			"symbol":           pos["symbol"],
-			"side":             side,
-			"positionAmt":      posAmt,
-			"entryPrice":       entryPrice,
-			"markPrice":        markPrice,
-			"unRealizedProfit": unRealizedProfit,
-			"leverage":         leverageVal,
-			"liquidationPrice": liquidationPrice,
+		symbol, _ := pos["symbol"].(string)
+		result = append(result, Position{
+			Symbol:           symbol,
+			Side:             side,
+			Quantity:         posAmt,
+			EntryPrice:       entryPrice,
+			MarkPrice:        markPrice,
+			UnrealizedPnL:    unRealizedProfit,
+			Leverage:         int(leverageVal),
+			LiquidationPrice: liquidationPrice,
 		})
 	}
 
@@ -574,7 +563,7 @@ func (t *AsterTrader) GetPositions() ([]map[string]interface{}, error) {
 }
 
 // OpenLong 开多单
-func (t *AsterTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *AsterTrader) OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error) {
 	// 开仓前先取消所有挂单,防止残留挂单导致仓位叠加
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消挂单失败(继续开仓): %v", err)
@@ -632,8 +621,8 @@ func (t *AsterTrader) OpenLong(symbol string, quantity float64, leverage int) (m
 		return nil, err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
+	result, err := parseAsterOrderResult(body)
+	if err != nil {
 		return nil, err
 	}
 
@@ -641,7 +630,7 @@ func (t *AsterTrader) OpenLong(symbol string, quantity float64, leverage int) (m
 }
 
 // OpenShort 开空单
-func (t *AsterTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *AsterTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
 	// 开仓前先取消所有挂单,防止残留挂单导致仓位叠加
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消挂单失败(继续开仓): %v", err)
@@ -699,16 +688,79 @@ func (t *AsterTrader) OpenShort(symbol string, quantity float64, leverage int) (
 		return nil, err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
+	result, err := parseAsterOrderResult(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// OpenLongLimit 限价开多单
+func (t *AsterTrader) OpenLongLimit(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	return t.openLimit(symbol, "BUY", quantity, leverage, price, postOnly)
+}
+
+// OpenShortLimit 限价开空单
+func (t *AsterTrader) OpenShortLimit(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	return t.openLimit(symbol, "SELL", quantity, leverage, price, postOnly)
+}
+
+// openLimit 按指定价格挂限价开仓单（post-only 使用 GTX）
+// 与市价开仓不同，这里不取消已有挂单，避免撤掉已有持仓的止盈止损单
+func (t *AsterTrader) openLimit(symbol, side string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	if err := t.SetLeverage(symbol, leverage); err != nil {
+		return nil, fmt.Errorf("设置杠杆失败: %w", err)
+	}
+
+	formattedPrice, err := t.formatPrice(symbol, price)
+	if err != nil {
+		return nil, err
+	}
+	formattedQty, err := t.formatQuantity(symbol, quantity)
+	if err != nil {
+		return nil, err
+	}
+	prec, err := t.getPrecision(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	priceStr := t.formatFloatWithPrecision(formattedPrice, prec.PricePrecision)
+	qtyStr := t.formatFloatWithPrecision(formattedQty, prec.QuantityPrecision)
+
+	timeInForce := "GTC"
+	if postOnly {
+		timeInForce = "GTX"
+	}
+
+	params := map[string]interface{}{
+		"symbol":       symbol,
+		"positionSide": "BOTH",
+		"type":         "LIMIT",
+		"side":         side,
+		"timeInForce":  timeInForce,
+		"quantity":     qtyStr,
+		"price":        priceStr,
+	}
+
+	body, err := t.request("POST", "/fapi/v3/order", params)
+	if err != nil {
+		return nil, fmt.Errorf("限价开仓失败: %w", err)
+	}
+
+	result, err := parseAsterOrderResult(body)
+	if err != nil {
 		return nil, err
 	}
 
+	log.Printf("✓ 限价开仓单已提交: %s %s 数量: %s 价格: %s (%s) 状态: %s",
+		symbol, side, qtyStr, priceStr, timeInForce, result.Status)
 	return result, nil
 }
 
 // CloseLong 平多单
-func (t *AsterTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *AsterTrader) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -717,8 +769,8 @@ func (t *AsterTrader) CloseLong(symbol string, quantity float64) (map[string]int
 		}
 
 		for _, pos := range positions {
-			if pos["symbol"] == symbol && pos["side"] == "long" {
-				quantity = pos["positionAmt"].(float64)
+			if pos.Symbol == symbol && pos.Side == "long" {
+				quantity = pos.Quantity
 				break
 			}
 		}
@@ -774,8 +826,8 @@ func (t *AsterTrader) CloseLong(symbol string, quantity float64) (map[string]int
 		return nil, err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
+	result, err := parseAsterOrderResult(body)
+	if err != nil {
 		return nil, err
 	}
 
@@ -790,7 +842,7 @@ func (t *AsterTrader) CloseLong(symbol string, quantity float64) (map[string]int
 }
 
 // CloseShort 平空单
-func (t *AsterTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *AsterTrader) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -799,9 +851,8 @@ func (t *AsterTrader) CloseShort(symbol string, quantity float64) (map[string]in
 		}
 
 		for _, pos := range positions {
-			if pos["symbol"] == symbol && pos["side"] == "short" {
-				// Aster的GetPositions已经将空仓数量转换为正数，直接使用
-				quantity = pos["positionAmt"].(float64)
+			if pos.Symbol == symbol && pos.Side == "short" {
+				quantity = pos.Quantity
 				break
 			}
 		}
@@ -857,8 +908,8 @@ func (t *AsterTrader) CloseShort(symbol string, quantity float64) (map[string]in
 		return nil, err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
+	result, err := parseAsterOrderResult(body)
+	if err != nil {
 		return nil, err
 	}
 
@@ -957,6 +1008,43 @@ func (t *AsterTrader) GetMarketPrice(symbol string) (float64, error) {
 	return strconv.ParseFloat(priceStr, 64)
 }
 
+// GetMarketPrices 不带 symbol 请求 ticker 接口，一次获取多个币种的价格（未找到的币种不在结果中）
+func (t *AsterTrader) GetMarketPrices(symbols []string) (map[string]float64, error) {
+	resp, err := t.client.Get(fmt.Sprintf("%s/fapi/v3/ticker/price", t.baseURL))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tickers []struct {
+		Symbol string `json:"symbol"`
+		Price  string `json:"price"`
+	}
+	if err := json.Unmarshal(body, &tickers); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		wanted[symbol] = true
+	}
+	prices := make(map[string]float64, len(symbols))
+	for _, ticker := range tickers {
+		if !wanted[ticker.Symbol] {
+			continue
+		}
+		if price, err := strconv.ParseFloat(ticker.Price, 64); err == nil {
+			prices[ticker.Symbol] = price
+		}
+	}
+	return prices, nil
+}
+
 // SetStopLoss 设置止损
 func (t *AsterTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
 	side := "SELL"
@@ -1157,6 +1245,25 @@ func (t *AsterTrader) CancelTakeProfitOrders(symbol string) error {
 	return nil
 }
 
+// CancelOrder 按订单ID取消单个挂单
+func (t *AsterTrader) CancelOrder(symbol string, orderID string) error {
+	id, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("无效的订单ID %s: %w", orderID, err)
+	}
+
+	params := map[string]interface{}{
+		"symbol":  symbol,
+		"orderId": id,
+	}
+	if _, err := t.request("DELETE", "/fapi/v3/order", params); err != nil {
+		return fmt.Errorf("取消订单 %s 失败: %w", orderID, err)
+	}
+
+	log.Printf("  ✓ 已取消订单 %s (%s)", orderID, symbol)
+	return nil
+}
+
 // CancelAllOrders 取消所有订单
 func (t *AsterTrader) CancelAllOrders(symbol string) error {
 	params := map[string]interface{}{
@@ -1222,6 +1329,227 @@ func (t *AsterTrader) CancelStopOrders(symbol string) error {
 	return nil
 }
 
+// asterTrade 成交记录（/fapi/v3/userTrades）
+type asterTrade struct {
+	ID           int64  `json:"id"`
+	OrderID      int64  `json:"orderId"`
+	Symbol       string `json:"symbol"`
+	Side         string `json:"side"`
+	PositionSide string `json:"positionSide"`
+	Price        string `json:"price"`
+	Qty          string `json:"qty"`
+	Commission   string `json:"commission"`
+	RealizedPnl  string `json:"realizedPnl"`
+	Time         int64  `json:"time"`
+}
+
+// asterIncome 资金流水（/fapi/v3/income）
+type asterIncome struct {
+	Symbol     string `json:"symbol"`
+	IncomeType string `json:"incomeType"`
+	Income     string `json:"income"`
+	Asset      string `json:"asset"`
+	TranID     int64  `json:"tranId"`
+	TradeID    string `json:"tradeId"`
+	Time       int64  `json:"time"`
+}
+
+// asterOrder 订单信息（/fapi/v3/openOrders、/fapi/v3/order）
+type asterOrder struct {
+	OrderID       int64  `json:"orderId"`
+	Symbol        string `json:"symbol"`
+	Status        string `json:"status"`
+	AvgPrice      string `json:"avgPrice"`
+	Side          string `json:"side"`
+	PositionSide  string `json:"positionSide"`
+	Type          string `json:"type"`
+	OrigType      string `json:"origType"`
+	Price         string `json:"price"`
+	StopPrice     string `json:"stopPrice"`
+	OrigQty       string `json:"origQty"`
+	ExecutedQty   string `json:"executedQty"`
+	ReduceOnly    bool   `json:"reduceOnly"`
+	ClosePosition bool   `json:"closePosition"`
+	Time          int64  `json:"time"`
+}
+
+// parseAsterOrderResult 解析下单返回
+func parseAsterOrderResult(body []byte) (*OrderResult, error) {
+	var order asterOrder
+	if err := json.Unmarshal(body, &order); err != nil {
+		return nil, err
+	}
+	avgPrice, _ := strconv.ParseFloat(order.AvgPrice, 64)
+	executedQty, _ := strconv.ParseFloat(order.ExecutedQty, 64)
+	return &OrderResult{
+		OrderID:     order.OrderID,
+		Symbol:      order.Symbol,
+		Status:      order.Status,
+		AvgPrice:    avgPrice,
+		ExecutedQty: executedQty,
+	}, nil
+}
+
+// GetFills 获取指定币种 since 之后的成交（Aster 要求指定币种，单次查询最多回溯7天）
+func (t *AsterTrader) GetFills(symbol string, since time.Time) ([]Fill, error) {
+	if symbol == "" {
+		return nil, fmt.Errorf("Aster查询成交必须指定币种")
+	}
+	if earliest := time.Now().Add(-7 * 24 * time.Hour); since.Before(earliest) {
+		since = earliest
+	}
+
+	body, err := t.request("GET", "/fapi/v3/userTrades", map[string]interface{}{
+		"symbol":    symbol,
+		"startTime": since.UnixMilli(),
+		"limit":     1000,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取成交历史失败: %w", err)
+	}
+
+	var trades []asterTrade
+	if err := json.Unmarshal(body, &trades); err != nil {
+		return nil, fmt.Errorf("解析成交数据失败: %w", err)
+	}
+
+	fills := make([]Fill, 0, len(trades))
+	for _, trade := range trades {
+		price, _ := strconv.ParseFloat(trade.Price, 64)
+		quantity, _ := strconv.ParseFloat(trade.Qty, 64)
+		fee, _ := strconv.ParseFloat(trade.Commission, 64)
+		realized, _ := strconv.ParseFloat(trade.RealizedPnl, 64)
+
+		fills = append(fills, Fill{
+			TradeID:      strconv.FormatInt(trade.ID, 10),
+			OrderID:      strconv.FormatInt(trade.OrderID, 10),
+			Symbol:       trade.Symbol,
+			Side:         trade.Side,
+			PositionSide: trade.PositionSide,
+			OrderType:    t.getOrderType(trade.Symbol, trade.OrderID),
+			Price:        price,
+			Quantity:     quantity,
+			Fee:          fee,
+			RealizedPnL:  realized,
+			ReduceOnly:   (trade.PositionSide == "LONG" && trade.Side == "SELL") || (trade.PositionSide == "SHORT" && trade.Side == "BUY"),
+			Time:         time.UnixMilli(trade.Time),
+		})
+	}
+	return fills, nil
+}
+
+// GetIncome 获取 since 之后的资金流水（已实现盈亏、资金费、手续费等）
+func (t *AsterTrader) GetIncome(symbol string, since time.Time) ([]Income, error) {
+	params := map[string]interface{}{
+		"startTime": since.UnixMilli(),
+		"limit":     1000,
+	}
+	if symbol != "" {
+		params["symbol"] = symbol
+	}
+
+	body, err := t.request("GET", "/fapi/v3/income", params)
+	if err != nil {
+		return nil, fmt.Errorf("获取资金流水失败: %w", err)
+	}
+
+	var records []asterIncome
+	if err := json.Unmarshal(body, &records); err != nil {
+		return nil, fmt.Errorf("解析资金流水失败: %w", err)
+	}
+
+	incomes := make([]Income, 0, len(records))
+	for _, record := range records {
+		amount, _ := strconv.ParseFloat(record.Income, 64)
+		incomes = append(incomes, Income{
+			Symbol:  record.Symbol,
+			Type:    record.IncomeType,
+			Amount:  amount,
+			Asset:   record.Asset,
+			TranID:  strconv.FormatInt(record.TranID, 10),
+			TradeID: record.TradeID,
+			Time:    time.UnixMilli(record.Time),
+		})
+	}
+	return incomes, nil
+}
+
+// GetOpenOrders 获取当前挂单（包括止盈止损条件单）
+func (t *AsterTrader) GetOpenOrders(symbol string) ([]OpenOrder, error) {
+	params := make(map[string]interface{})
+	if symbol != "" {
+		params["symbol"] = symbol
+	}
+
+	body, err := t.request("GET", "/fapi/v3/openOrders", params)
+	if err != nil {
+		return nil, fmt.Errorf("获取挂单失败: %w", err)
+	}
+
+	var orders []asterOrder
+	if err := json.Unmarshal(body, &orders); err != nil {
+		return nil, fmt.Errorf("解析订单数据失败: %w", err)
+	}
+
+	result := make([]OpenOrder, 0, len(orders))
+	for _, order := range orders {
+		price, _ := strconv.ParseFloat(order.Price, 64)
+		stopPrice, _ := strconv.ParseFloat(order.StopPrice, 64)
+		quantity, _ := strconv.ParseFloat(order.OrigQty, 64)
+		filled, _ := strconv.ParseFloat(order.ExecutedQty, 64)
+		result = append(result, OpenOrder{
+			OrderID:      strconv.FormatInt(order.OrderID, 10),
+			Symbol:       order.Symbol,
+			Side:         order.Side,
+			PositionSide: order.PositionSide,
+			Type:         order.Type,
+			Price:        price,
+			StopPrice:    stopPrice,
+			Quantity:     quantity,
+			FilledQty:    filled,
+			ReduceOnly:   order.ReduceOnly || order.ClosePosition,
+			Time:         time.UnixMilli(order.Time),
+		})
+	}
+	return result, nil
+}
+
+// getOrderType 查询订单原始类型（STOP_MARKET / TAKE_PROFIT_MARKET 等），查询失败时返回空
+func (t *AsterTrader) getOrderType(symbol string, orderID int64) string {
+	t.mu.RLock()
+	orderType, ok := t.orderTypeCache[orderID]
+	t.mu.RUnlock()
+	if ok {
+		return orderType
+	}
+
+	body, err := t.request("GET", "/fapi/v3/order", map[string]interface{}{
+		"symbol":  symbol,
+		"orderId": orderID,
+	})
+	if err != nil {
+		log.Printf("  ⚠ 查询订单 %d 类型失败: %v", orderID, err)
+		return ""
+	}
+	var order asterOrder
+	if err := json.Unmarshal(body, &order); err != nil {
+		log.Printf("  ⚠ 解析订单 %d 失败: %v", orderID, err)
+		return ""
+	}
+	orderType = order.OrigType
+	if orderType == "" {
+		orderType = order.Type
+	}
+
+	t.mu.Lock()
+	if t.orderTypeCache == nil {
+		t.orderTypeCache = make(map[int64]string)
+	}
+	t.orderTypeCache[orderID] = orderType
+	t.mu.Unlock()
+	return orderType
+}
+
 // FormatQuantity 格式化数量（实现Trader接口）
 func (t *AsterTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
 	formatted, err := t.formatQuantity(symbol, quantity)
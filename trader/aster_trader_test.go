@@ -6,7 +6,9 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
@@ -65,13 +67,18 @@ func NewAsterTraderTestSuite(t *testing.T) *AsterTraderTestSuite {
 				},
 			}
 
+		// Mock GetMarketPrices - /fapi/v3/ticker/price 不带 symbol (返回全部币种)
+		case path == "/fapi/v3/ticker/price" && r.URL.Query().Get("symbol") == "":
+			respBody = []map[string]interface{}{
+				{"symbol": "BTCUSDT", "price": "50000.00", "time": 1700000000000},
+				{"symbol": "ETHUSDT", "price": "3000.00", "time": 1700000000000},
+				{"symbol": "SOLUSDT", "price": "150.00", "time": 1700000000000},
+			}
+
 		// Mock GetMarketPrice - /fapi/v3/ticker/price (返回单个对象)
 		case path == "/fapi/v3/ticker/price":
 			// 从查询参数获取symbol
 			symbol := r.URL.Query().Get("symbol")
-			if symbol == "" {
-				symbol = "BTCUSDT"
-			}
 			// 根据symbol返回不同价格
 			price := "50000.00"
 			if symbol == "ETHUSDT" {
@@ -151,8 +158,8 @@ func NewAsterTraderTestSuite(t *testing.T) *AsterTraderTestSuite {
 				"type":    orderParams["type"],
 			}
 
-		// Mock CancelOrder - /fapi/v1/order (DELETE)
-		case path == "/fapi/v1/order" && r.Method == "DELETE":
+		// Mock CancelOrder - /fapi/v1/order and /fapi/v3/order (DELETE)
+		case (path == "/fapi/v1/order" || path == "/fapi/v3/order") && r.Method == "DELETE":
 			respBody = map[string]interface{}{
 				"orderId": 123456,
 				"symbol":  "BTCUSDT",
@@ -161,7 +168,43 @@ func NewAsterTraderTestSuite(t *testing.T) *AsterTraderTestSuite {
 
 		// Mock ListOpenOrders - /fapi/v1/openOrders and /fapi/v3/openOrders
 		case path == "/fapi/v1/openOrders" || path == "/fapi/v3/openOrders":
-			respBody = []map[string]interface{}{}
+			respBody = []map[string]interface{}{
+				{"orderId": 7001, "symbol": "BTCUSDT", "side": "BUY", "positionSide": "LONG", "type": "LIMIT",
+					"origType": "LIMIT", "price": "49000", "stopPrice": "0", "origQty": "0.2", "executedQty": "0.05",
+					"reduceOnly": false, "time": 1700000000000},
+			}
+
+		// Mock GetOrder - /fapi/v3/order (GET)
+		case path == "/fapi/v3/order" && r.Method == "GET":
+			orderID, _ := strconv.ParseInt(r.URL.Query().Get("orderId"), 10, 64)
+			orderType := "MARKET"
+			if orderID == 6002 {
+				orderType = "TAKE_PROFIT_MARKET"
+			}
+			respBody = map[string]interface{}{
+				"orderId":  orderID,
+				"symbol":   r.URL.Query().Get("symbol"),
+				"type":     "MARKET",
+				"origType": orderType,
+			}
+
+		// Mock ListAccountTrades - /fapi/v3/userTrades
+		case path == "/fapi/v3/userTrades":
+			respBody = []map[string]interface{}{
+				{"id": 11, "orderId": 6001, "symbol": "BTCUSDT", "side": "SELL", "positionSide": "SHORT",
+					"price": "50000", "qty": "0.2", "commission": "4", "realizedPnl": "0", "time": 1700000000000},
+				{"id": 12, "orderId": 6002, "symbol": "BTCUSDT", "side": "BUY", "positionSide": "SHORT",
+					"price": "48000", "qty": "0.2", "commission": "3.84", "realizedPnl": "400", "time": 1700000060000},
+			}
+
+		// Mock GetIncomeHistory - /fapi/v3/income
+		case path == "/fapi/v3/income":
+			respBody = []map[string]interface{}{
+				{"symbol": "BTCUSDT", "incomeType": "FUNDING_FEE", "income": "0.42", "asset": "USDT",
+					"tranId": 8001, "tradeId": "", "time": 1700000030000},
+				{"symbol": "BTCUSDT", "incomeType": "REALIZED_PNL", "income": "400", "asset": "USDT",
+					"tranId": 8002, "tradeId": "12", "time": 1700000060000},
+			}
 
 		// Mock SetLeverage - /fapi/v1/leverage
 		case path == "/fapi/v1/leverage":
@@ -297,3 +340,47 @@ func TestNewAsterTrader(t *testing.T) {
 		})
 	}
 }
+
+// TestAsterTrader_FillsIncomeAndOpenOrders 测试成交、资金流水与挂单查询
+// TestAsterTrader_GetMarketPrices 一次请求返回需要的币种，未上线的币种不在结果中
+func TestAsterTrader_GetMarketPrices(t *testing.T) {
+	suite := NewAsterTraderTestSuite(t)
+	defer suite.Cleanup()
+
+	prices, err := suite.Trader.(*AsterTrader).GetMarketPrices([]string{"BTCUSDT", "ETHUSDT", "DOGEUSDT"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"BTCUSDT": 50000, "ETHUSDT": 3000}, prices)
+}
+
+func TestAsterTrader_FillsIncomeAndOpenOrders(t *testing.T) {
+	suite := NewAsterTraderTestSuite(t)
+	defer suite.Cleanup()
+
+	trader := suite.Trader.(*AsterTrader)
+	fills, err := trader.GetFills("BTCUSDT", time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, fills, 2)
+	assert.Equal(t, "MARKET", fills[0].OrderType)
+	assert.False(t, fills[0].ReduceOnly)
+	assert.Equal(t, "TAKE_PROFIT_MARKET", fills[1].OrderType)
+	assert.True(t, fills[1].ReduceOnly, "空仓买入为平仓成交")
+	assert.Equal(t, 400.0, fills[1].RealizedPnL)
+	assert.Equal(t, 3.84, fills[1].Fee)
+
+	_, err = trader.GetFills("", time.Now())
+	assert.Error(t, err, "未指定币种应返回错误")
+
+	incomes, err := trader.GetIncome("", time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, incomes, 2)
+	assert.Equal(t, IncomeFundingFee, incomes[0].Type)
+	assert.Equal(t, 0.42, incomes[0].Amount)
+	assert.Equal(t, "8001", incomes[0].TranID)
+
+	orders, err := trader.GetOpenOrders("BTCUSDT")
+	assert.NoError(t, err)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, "7001", orders[0].OrderID)
+	assert.Equal(t, 49000.0, orders[0].Price)
+	assert.Equal(t, 0.05, orders[0].FilledQty)
+}
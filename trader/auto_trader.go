@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"nofx/config"
 	"nofx/decision"
 	"nofx/logger"
 	"nofx/market"
 	"nofx/mcp"
 	"nofx/pool"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -23,7 +25,7 @@ type AutoTraderConfig struct {
 	AIModel string // AI模型: "qwen" 或 "deepseek"
 
 	// 交易平台选择
-	Exchange string // "binance", "hyperliquid" 或 "aster"
+	Exchange string // "binance", "hyperliquid", "aster" 或 "paper"（模拟盘）
 
 	// 币安API配置
 	BinanceAPIKey    string
@@ -39,6 +41,20 @@ type AutoTraderConfig struct {
 	AsterSigner     string // Aster API钱包地址
 	AsterPrivateKey string // Aster API钱包私钥
 
+	// 模拟盘配置
+	PaperFeeRate  float64 // 模拟手续费率（如 0.0004 = 0.04%）
+	PaperSlippage float64 // 模拟滑点比例（如 0.0005 = 0.05%）
+
+	// 录制AI请求与响应到 ai_recordings/<ID>（用于离线回放）
+	RecordAIResponses bool
+
+	// 决策日志存储后端（file: decision_logs/<ID> 下的JSON文件；sqlite: 所有交易员共用的SQLite库）
+	DecisionLogBackend string
+	DecisionLogDBPath  string // SQLite库路径，为空时使用 logger.DefaultDecisionLogDBPath
+
+	// 决策日志保留策略（系统提示词去重、过期记录归档/删除，零值只做去重）
+	DecisionRetention logger.RetentionPolicy
+
 	CoinPoolAPIURL string
 
 	// AI配置
@@ -61,10 +77,42 @@ type AutoTraderConfig struct {
 	BTCETHLeverage  int // BTC和ETH的杠杆倍数
 	AltcoinLeverage int // 山寨币的杠杆倍数
 
-	// 风险控制（仅作为提示，AI可自主决定）
-	MaxDailyLoss    float64       // 最大日亏损百分比（提示）
-	MaxDrawdown     float64       // 最大回撤百分比（提示）
-	StopTradingTime time.Duration // 触发风控后暂停时长
+	// 风险控制（由风控引擎强制执行，0表示不启用）
+	MaxDailyLoss        float64       // 最大日亏损百分比（已实现+未实现）
+	MaxDrawdown         float64       // 最大回撤百分比（相对峰值净值）
+	StopTradingTime     time.Duration // 触发风控后暂停时长
+	FlattenOnRiskBreach bool          // 触发风控时是否强制平仓（否则仅拦截开仓）
+
+	// 持仓保护（追踪止盈规则，Rules 为 nil 时使用默认的 5%/40% 回撤平仓）
+	PositionProtection ProtectionConfig
+
+	// 限价开仓挂单超时（超时未成交则撤单并按当前价重挂一次，0表示使用默认15分钟）
+	PendingOrderTimeout time.Duration
+
+	// 市场数据（在默认3分钟/4小时数据之外追加的时间框架与指标，为空则只使用默认数据）
+	MarketData market.DataConfig
+
+	// 市场警报（来自 WSMonitor 的成交量/价格/RSI 异动）
+	UseAlertCoins bool // 将近期触发警报的币种加入候选币种
+	AlertWakeup   bool // 候选或持仓币种触发警报时提前运行决策周期
+
+	// 事件触发（价格异动、成交量突增、接近止损/强平、资金费率翻转时提前运行决策周期）
+	Triggers TriggerConfig
+
+	// 开仓名义价值占开仓方向 ±1% 盘口深度的上限（0表示使用 decision.DefaultMaxDepthFraction）
+	MaxDepthFraction float64
+
+	// 向AI提供跨交易所（币安/Hyperliquid/Aster）资金费率与标记价格差异
+	CrossVenueFunding bool
+
+	// 多模型集成决策（EnsemblePolicy 为空时只使用主模型）
+	EnsemblePolicy    string        // unanimous / majority / weighted / judge
+	EnsembleModels    []AIModelSpec // 与主模型一起参与决策的额外模型
+	EnsembleJudge     *AIModelSpec  // 仲裁模型（judge 策略）
+	EnsembleMinWeight float64       // weighted 策略的执行阈值，0表示默认值
+
+	// AI提供商故障转移链：主模型调用失败（重试耗尽）时按顺序尝试的备用模型
+	FallbackModels []AIModelSpec
 
 	// 仓位模式
 	IsCrossMargin bool // true=全仓模式, false=逐仓模式
@@ -86,6 +134,7 @@ type AutoTrader struct {
 	config                AutoTraderConfig
 	trader                Trader // 使用Trader接口（支持多平台）
 	mcpClient             mcp.AIClient
+	ensemble              *decision.Ensemble     // 多模型集成（未启用时为nil）
 	decisionLogger        logger.IDecisionLogger // 决策日志记录器
 	initialBalance        float64
 	dailyPnL              float64
@@ -96,17 +145,43 @@ type AutoTrader struct {
 	tradingCoins          []string // 实际交易币种列表
 	lastResetTime         time.Time
 	stopUntil             time.Time
+	riskEngine            *RiskEngine // 组合风控引擎
+	riskStateFile         string      // 风控状态持久化文件（为空则不持久化）
+	pendingOrdersFile     string      // 未成交挂单持久化文件（为空则不持久化）
 	isRunning             bool
-	startTime             time.Time          // 系统启动时间
-	callCount             int                // AI调用次数
-	positionFirstSeenTime map[string]int64   // 持仓首次出现时间 (symbol_side -> timestamp毫秒)
-	stopMonitorCh         chan struct{}      // 用于停止监控goroutine
-	monitorWg             sync.WaitGroup     // 用于等待监控goroutine结束
-	peakPnLCache          map[string]float64 // 最高收益缓存 (symbol -> 峰值盈亏百分比)
-	peakPnLCacheMutex     sync.RWMutex       // 缓存读写锁
-	lastBalanceSyncTime   time.Time          // 上次余额同步时间
-	database              interface{}        // 数据库引用（用于自动更新余额）
-	userID                string             // 用户ID
+	startTime             time.Time                  // 系统启动时间
+	callCount             int                        // AI调用次数
+	positionFirstSeenTime map[string]int64           // 持仓首次出现时间 (symbol_side -> timestamp毫秒)
+	stopMonitorCh         chan struct{}              // 用于停止监控goroutine
+	monitorWg             sync.WaitGroup             // 用于等待监控goroutine结束
+	peakPnLCache          map[string]float64         // 最高收益缓存 (symbol -> 峰值盈亏百分比)
+	peakPnLCacheMutex     sync.RWMutex               // 缓存读写锁
+	peakPnLFile           string                     // 峰值缓存持久化文件（为空则不持久化）
+	protectionConfig      ProtectionConfig           // 持仓保护配置
+	protectionRules       []ProtectionRule           // 持仓保护规则
+	protectionMu          sync.Mutex                 // 串行化持仓保护评估（轮询与行情推送）
+	protectedPositions    map[string]trackedPosition // 行情驱动模式下的持仓快照 (symbol_side -> 持仓)
+	atrCache              map[string]atrEntry        // 持仓币种的4小时ATR缓存 (symbol -> ATR)
+	atrMu                 sync.RWMutex               // 保护 atrCache
+	positionHistoryMu     sync.Mutex                 // 串行化持仓生命周期的回放与持久化（API请求可能并发）
+	protectionRefreshCh   chan struct{}              // 请求刷新持仓快照（如AI执行决策后）
+	priceStream           PriceStream                // 实时价格源（为空时币安行情使用 market.WSMonitorCli，否则轮询持仓）
+	alertStream           AlertStream                // 市场警报源（为空时币安行情使用 market.WSMonitorCli）
+	watchedSymbols        map[string]bool            // 上个周期的候选与持仓币种（事件触发只关注这些币种）
+	watchedMu             sync.RWMutex               // 保护 watchedSymbols（触发源在后台读取）
+	lastCycleAt           time.Time                  // 上次决策周期开始时间
+	triggers              triggerState               // 事件触发状态（冷却、AI调用预算、价格窗口）
+	lastBalanceSyncTime   time.Time                  // 上次余额同步时间
+	database              interface{}                // 数据库引用（用于自动更新余额）
+	ledger                OrderLedger                // 订单/成交账本（为空则不记录）
+	pendingOrders         map[string]*pendingOrder   // 未成交的限价开仓单 (symbol_side -> 挂单)
+	userID                string                     // 用户ID
+
+	marketProvider  market.Provider                           // 交易所行情源（K线、资金费率、持仓量与上线币种）
+	marketDataFunc  func(symbol string) (*market.Data, error) // 市场数据来源（为空时使用 marketProvider）
+	clock           func() time.Time                          // 时间来源（为空时使用 time.Now）
+	fundingRateFunc func(symbol string) (float64, error)      // 资金费率来源（为空时使用 marketProvider）
+	executionDelay  time.Duration                             // 每个决策成功执行后的等待时间
 }
 
 // NewAutoTrader 创建自动交易器
@@ -153,6 +228,12 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		}
 	}
 
+	// 配置了备用模型时包装为故障转移客户端，开启录制时录制AI调用（用于复现解析失败、离线回放）
+	mcpClient, err := wrapAIClient(config, modelLabel(config.AIModel, config.CustomModelName), mcpClient, "")
+	if err != nil {
+		return nil, err
+	}
+
 	// 初始化币种池API
 	if config.CoinPoolAPIURL != "" {
 		pool.SetCoinPoolAPI(config.CoinPoolAPIURL)
@@ -165,7 +246,6 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 
 	// 根据配置创建对应的交易器
 	var trader Trader
-	var err error
 
 	// 记录仓位模式（通用）
 	marginModeStr := "全仓"
@@ -190,6 +270,17 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		if err != nil {
 			return nil, fmt.Errorf("初始化Aster交易器失败: %w", err)
 		}
+	case "paper":
+		log.Printf("🏦 [%s] 使用模拟盘交易（手续费率 %.4f%%, 滑点 %.4f%%）", config.Name, config.PaperFeeRate*100, config.PaperSlippage*100)
+		trader, err = NewPaperTrader(PaperTraderConfig{
+			InitialBalance: config.InitialBalance,
+			FeeRate:        config.PaperFeeRate,
+			Slippage:       config.PaperSlippage,
+			StateFile:      fmt.Sprintf("paper_trading/%s.json", config.ID),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("初始化模拟盘交易器失败: %w", err)
+		}
 	default:
 		return nil, fmt.Errorf("不支持的交易平台: %s", config.Exchange)
 	}
@@ -199,9 +290,60 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		return nil, fmt.Errorf("初始金额必须大于0，请在配置中设置InitialBalance")
 	}
 
-	// 初始化决策日志记录器（使用trader ID创建独立目录）
-	logDir := fmt.Sprintf("decision_logs/%s", config.ID)
-	decisionLogger := logger.NewDecisionLogger(logDir)
+	// 初始化决策日志记录器（文件存储时使用trader ID创建独立目录）
+	decisionLogger, err := logger.NewDecisionLoggerForBackend(config.DecisionLogBackend, config.DecisionLogDBPath, config.ID)
+	if err != nil {
+		return nil, fmt.Errorf("初始化决策日志失败: %w", err)
+	}
+
+	at, err := newAutoTrader(config, trader, mcpClient, decisionLogger)
+	if err != nil {
+		return nil, err
+	}
+	if at.ensemble, err = buildEnsemble(config, mcpClient); err != nil {
+		return nil, err
+	}
+	at.peakPnLFile = fmt.Sprintf("peak_pnl/%s.json", config.ID)
+	at.riskStateFile = fmt.Sprintf("risk_state/%s.json", config.ID)
+	at.pendingOrdersFile = fmt.Sprintf("pending_orders/%s.json", config.ID)
+	at.database = database
+	at.userID = userID
+	at.marketProvider = market.NewProvider(config.Exchange, config.HyperliquidTestnet)
+	log.Printf("📈 [%s] 行情数据来源: %s", config.Name, at.marketProvider.Name())
+	if !at.usesBinanceMarket() {
+		// 币安 WebSocket 价格不适用于其他交易所，持仓保护与价格异动触发轮询本交易所价格
+		at.priceStream = newPollingPriceStream(trader, DefaultPricePollInterval)
+	}
+
+	// 数据库实现了账本接口时记录所有下单与成交
+	if ledger, ok := database.(OrderLedger); ok {
+		at.ledger = ledger
+	}
+
+	// 恢复持久化的峰值缓存（重启后不丢失追踪止盈的峰值）
+	if err := at.loadPeakPnLCache(); err != nil {
+		log.Printf("⚠️ [%s] 加载峰值缓存失败: %v", config.Name, err)
+	}
+	// 恢复风控状态（重启后不清除进行中的暂停与回撤峰值）
+	if err := at.loadRiskState(); err != nil {
+		log.Printf("⚠️ [%s] 加载风控状态失败: %v", config.Name, err)
+	}
+	// 恢复未成交挂单（重启后仍阻止重复开仓，成交后设置止盈止损）
+	if err := at.loadPendingOrders(); err != nil {
+		log.Printf("⚠️ [%s] 加载挂单失败: %v", config.Name, err)
+	}
+
+	return at, nil
+}
+
+// newAutoTrader 组装实盘与回测共用的 AutoTrader（新增字段只需在此初始化）
+// 调用方再按需设置集成模型、账本、数据库、行情源、时钟等依赖
+func newAutoTrader(config AutoTraderConfig, trader Trader, mcpClient mcp.AIClient, decisionLogger logger.IDecisionLogger) (*AutoTrader, error) {
+	// 初始化持仓保护规则
+	protectionConfig, protectionRules, err := resolveProtection(config.PositionProtection)
+	if err != nil {
+		return nil, err
+	}
 
 	// 设置默认系统提示词模板
 	systemPromptTemplate := config.SystemPromptTemplate
@@ -210,6 +352,7 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		systemPromptTemplate = "adaptive"
 	}
 
+	now := time.Now()
 	return &AutoTrader{
 		id:                    config.ID,
 		name:                  config.Name,
@@ -223,18 +366,18 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		systemPromptTemplate:  systemPromptTemplate,
 		defaultCoins:          config.DefaultCoins,
 		tradingCoins:          config.TradingCoins,
-		lastResetTime:         time.Now(),
-		startTime:             time.Now(),
-		callCount:             0,
-		isRunning:             false,
+		lastResetTime:         now,
+		riskEngine:            newRiskEngineFromConfig(config),
+		startTime:             now,
 		positionFirstSeenTime: make(map[string]int64),
 		stopMonitorCh:         make(chan struct{}),
-		monitorWg:             sync.WaitGroup{},
 		peakPnLCache:          make(map[string]float64),
-		peakPnLCacheMutex:     sync.RWMutex{},
-		lastBalanceSyncTime:   time.Now(), // 初始化为当前时间
-		database:              database,
-		userID:                userID,
+		atrCache:              make(map[string]atrEntry),
+		protectionConfig:      protectionConfig,
+		protectionRules:       protectionRules,
+		protectionRefreshCh:   make(chan struct{}, 1),
+		lastBalanceSyncTime:   now,
+		executionDelay:        1 * time.Second,
 	}, nil
 }
 
@@ -254,9 +397,18 @@ func (at *AutoTrader) Run() error {
 	// 启动回撤监控
 	at.startDrawdownMonitor()
 
+	// 启动成交对账
+	at.startLedgerReconciler()
+
+	// 启动决策日志保留任务
+	at.startDecisionRetention()
+
 	ticker := time.NewTicker(at.config.ScanInterval)
 	defer ticker.Stop()
 
+	// 事件触发：市场警报、价格异动、接近止损/强平、资金费率翻转（未启用时为nil通道）
+	triggers := at.startCycleTriggers()
+
 	// 首次立即执行
 	if err := at.runCycle(); err != nil {
 		log.Printf("❌ 执行失败: %v", err)
@@ -268,6 +420,14 @@ func (at *AutoTrader) Run() error {
 			if err := at.runCycle(); err != nil {
 				log.Printf("❌ 执行失败: %v", err)
 			}
+		case trigger := <-triggers:
+			if !at.allowTriggeredCycle(trigger) {
+				continue
+			}
+			log.Printf("⚡ [%s] 事件提前触发决策周期 [%s]: %s", at.name, trigger.Type, trigger.Reason)
+			if err := at.runTriggeredCycle(&trigger); err != nil {
+				log.Printf("❌ 执行失败: %v", err)
+			}
 		case <-at.stopMonitorCh:
 			log.Printf("[%s] ⏹ 收到停止信号，退出自动交易主循环", at.name)
 			return nil
@@ -288,23 +448,85 @@ func (at *AutoTrader) Stop() {
 	log.Println("⏹ 自动交易系统停止")
 }
 
-// runCycle 运行一个交易周期（使用AI全权决策）
+// now 返回当前时间（回测时为模拟时间）
+func (at *AutoTrader) now() time.Time {
+	if at.clock != nil {
+		return at.clock()
+	}
+	return time.Now()
+}
+
+// getMarketData 获取市场数据（回测时使用注入的历史数据源，否则使用所在交易所的行情）
+func (at *AutoTrader) getMarketData(symbol string) (*market.Data, error) {
+	if at.marketDataFunc != nil {
+		return at.marketDataFunc(symbol)
+	}
+	if at.marketProvider == nil {
+		return market.GetWithConfig(symbol, at.config.MarketData)
+	}
+	return market.GetWithProvider(at.marketProvider, symbol, at.config.MarketData)
+}
+
+// resolveMarketProvider 返回交易所行情源（未设置时使用币安行情）
+func (at *AutoTrader) resolveMarketProvider() market.Provider {
+	if at.marketProvider != nil {
+		return at.marketProvider
+	}
+	return market.DefaultProvider()
+}
+
+// filterUnlistedCoins 移除所在交易所未上线的候选币种（如币安币种池中 Hyperliquid 没有的币）
+func (at *AutoTrader) filterUnlistedCoins(coins []decision.CandidateCoin) []decision.CandidateCoin {
+	if at.marketDataFunc != nil {
+		return coins // 回测使用注入的数据源，不检查上线状态
+	}
+	provider := at.resolveMarketProvider()
+	filtered := coins[:0]
+	var skipped []string
+	for _, coin := range coins {
+		if market.IsListed(provider, coin.Symbol) {
+			filtered = append(filtered, coin)
+		} else {
+			skipped = append(skipped, coin.Symbol)
+		}
+	}
+	if len(skipped) > 0 {
+		log.Printf("⚠️ [%s] %s 未上线以下币种，已从候选中移除: %v", at.name, provider.Name(), skipped)
+	}
+	return filtered
+}
+
+// runCycle 运行一个定时交易周期（使用AI全权决策）
 func (at *AutoTrader) runCycle() error {
+	return at.runTriggeredCycle(nil)
+}
+
+// runTriggeredCycle 运行一个交易周期，trigger 为提前触发本周期的事件（定时扫描时为nil）
+func (at *AutoTrader) runTriggeredCycle(trigger *CycleTrigger) error {
 	at.callCount++
+	at.recordCycleStart(at.now())
 
 	log.Print("\n" + strings.Repeat("=", 70) + "\n")
-	log.Printf("⏰ %s - AI决策周期 #%d", time.Now().Format("2006-01-02 15:04:05"), at.callCount)
+	log.Printf("⏰ %s - AI决策周期 #%d", at.now().Format("2006-01-02 15:04:05"), at.callCount)
 	log.Println(strings.Repeat("=", 70))
 
 	// 创建决策记录
 	record := &logger.DecisionRecord{
 		ExecutionLog: []string{},
 		Success:      true,
+		Trigger:      TriggerScheduled,
 	}
+	if trigger != nil {
+		record.Trigger = trigger.Type
+		record.TriggerReason = trigger.Reason
+	}
+
+	// 同步限价挂单（成交后设置止盈止损，超时撤单重挂），暂停交易期间也需要处理
+	at.syncPendingOrders()
 
 	// 1. 检查是否需要停止交易
-	if time.Now().Before(at.stopUntil) {
-		remaining := at.stopUntil.Sub(time.Now())
+	if at.now().Before(at.stopUntil) {
+		remaining := at.stopUntil.Sub(at.now())
 		log.Printf("⏸ 风险控制：暂停交易中，剩余 %.0f 分钟", remaining.Minutes())
 		record.Success = false
 		record.ErrorMessage = fmt.Sprintf("风险控制暂停中，剩余 %.0f 分钟", remaining.Minutes())
@@ -312,14 +534,7 @@ func (at *AutoTrader) runCycle() error {
 		return nil
 	}
 
-	// 2. 重置日盈亏（每天重置）
-	if time.Since(at.lastResetTime) > 24*time.Hour {
-		at.dailyPnL = 0
-		at.lastResetTime = time.Now()
-		log.Println("📅 日盈亏已重置")
-	}
-
-	// 4. 收集交易上下文
+	// 2. 收集交易上下文
 	ctx, err := at.buildTradingContext()
 	if err != nil {
 		record.Success = false
@@ -327,6 +542,10 @@ func (at *AutoTrader) runCycle() error {
 		at.decisionLogger.LogDecision(record)
 		return fmt.Errorf("构建交易上下文失败: %w", err)
 	}
+	ctx.Trigger = trigger.info()
+
+	// 3. 风控评估：更新日盈亏与峰值净值（日初基准每24小时滚动）
+	riskCheck := at.evaluateRisk(ctx.Account.TotalEquity)
 
 	// 保存账户状态快照
 	record.AccountState = logger.AccountSnapshot{
@@ -360,9 +579,68 @@ func (at *AutoTrader) runCycle() error {
 	log.Printf("📊 账户净值: %.2f USDT | 可用: %.2f USDT | 持仓: %d",
 		ctx.Account.TotalEquity, ctx.Account.AvailableBalance, ctx.Account.PositionCount)
 
+	// 4. 风控触发时不再请求AI：直接按配置强制平仓、暂停交易并记录原因（AI调用失败也不影响风控）
+	var sortedDecisions []decision.Decision
+	if riskCheck.Breached {
+		sortedDecisions = at.applyRiskControl(riskCheck, nil, ctx.Positions, record)
+	} else {
+		sortedDecisions, err = at.requestCycleDecisions(ctx, record)
+		if err != nil {
+			at.decisionLogger.LogDecision(record)
+			return err
+		}
+	}
+
+	log.Println("🔄 执行顺序（已优化）: 先平仓→后开仓")
+	for i, d := range sortedDecisions {
+		log.Printf("  [%d] %s %s", i+1, d.Symbol, d.Action)
+	}
+	log.Println()
+
+	// 执行决策并记录结果
+	for _, d := range sortedDecisions {
+		actionRecord := logger.DecisionAction{
+			Action:    d.Action,
+			Symbol:    d.Symbol,
+			Quantity:  0,
+			Leverage:  d.Leverage,
+			Price:     0,
+			Timestamp: at.now(),
+			Success:   false,
+			Reasoning: d.Reasoning,
+		}
+
+		if err := at.executeDecisionWithRecord(&d, &actionRecord); err != nil {
+			log.Printf("❌ 执行决策失败 (%s %s): %v", d.Symbol, d.Action, err)
+			actionRecord.Error = err.Error()
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 失败: %v", d.Symbol, d.Action, err))
+		} else {
+			actionRecord.Success = true
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 成功", d.Symbol, d.Action))
+			// 成功执行后短暂延迟
+			time.Sleep(at.executionDelay)
+		}
+
+		record.Decisions = append(record.Decisions, actionRecord)
+	}
+	if len(sortedDecisions) > 0 {
+		// 持仓可能已变化，通知持仓保护刷新快照
+		at.requestProtectionRefresh()
+	}
+
+	// 10. 保存决策记录
+	if err := at.decisionLogger.LogDecision(record); err != nil {
+		log.Printf("⚠ 保存决策记录失败: %v", err)
+	}
+
+	return nil
+}
+
+// requestCycleDecisions 请求AI决策并把提示词、思维链与耗时写入决策记录，返回按先平仓后开仓排序的决策
+func (at *AutoTrader) requestCycleDecisions(ctx *decision.Context, record *logger.DecisionRecord) ([]decision.Decision, error) {
 	// 5. 调用AI获取完整决策
 	log.Printf("🤖 正在请求AI分析并决策... [模板: %s]", at.systemPromptTemplate)
-	decision, err := decision.GetFullDecisionWithCustomPrompt(ctx, at.mcpClient, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+	decision, err := at.requestFullDecision(ctx)
 
 	if decision != nil && decision.AIRequestDurationMs > 0 {
 		record.AIRequestDurationMs = decision.AIRequestDurationMs
@@ -380,6 +658,11 @@ func (at *AutoTrader) runCycle() error {
 			decisionJSON, _ := json.MarshalIndent(decision.Decisions, "", "  ")
 			record.DecisionJSON = string(decisionJSON)
 		}
+		record.EnsemblePolicy = decision.EnsemblePolicy
+		record.ModelResponses = modelResponseRecords(decision.ModelResponses)
+		record.AIProvider = decision.AIProvider
+		// 按盘口深度缩减或剔除的开仓决策
+		record.ExecutionLog = append(record.ExecutionLog, decision.Adjustments...)
 	}
 
 	if err != nil {
@@ -403,8 +686,7 @@ func (at *AutoTrader) runCycle() error {
 			}
 		}
 
-		at.decisionLogger.LogDecision(record)
-		return fmt.Errorf("获取AI决策失败: %w", err)
+		return nil, fmt.Errorf("获取AI决策失败: %w", err)
 	}
 
 	// // 5. 打印系统提示词
@@ -436,46 +718,67 @@ func (at *AutoTrader) runCycle() error {
 	log.Print(strings.Repeat("-", 70))
 
 	// 8. 对决策排序：确保先平仓后开仓（防止仓位叠加超限）
-	sortedDecisions := sortDecisionsByPriority(decision.Decisions)
+	return sortDecisionsByPriority(decision.Decisions), nil
+}
 
-	log.Println("🔄 执行顺序（已优化）: 先平仓→后开仓")
-	for i, d := range sortedDecisions {
-		log.Printf("  [%d] %s %s", i+1, d.Symbol, d.Action)
+// evaluateRisk 评估组合风险并同步日盈亏统计
+func (at *AutoTrader) evaluateRisk(equity float64) RiskCheck {
+	dayStart := at.riskEngine.DayStart()
+	check := at.riskEngine.Evaluate(at.now(), equity)
+	if !at.riskEngine.DayStart().Equal(dayStart) {
+		at.lastResetTime = at.riskEngine.DayStart()
+		if !dayStart.IsZero() {
+			log.Println("📅 日盈亏已重置")
+		}
 	}
-	log.Println()
+	at.dailyPnL = check.DailyPnL
+	if err := at.saveRiskState(); err != nil {
+		log.Printf("⚠️ [%s] 保存风控状态失败: %v", at.name, err)
+	}
+	return check
+}
 
-	// 执行决策并记录结果
-	for _, d := range sortedDecisions {
-		actionRecord := logger.DecisionAction{
+// applyRiskControl 根据风控评估结果过滤决策，触发时设置暂停时间并写入决策记录
+func (at *AutoTrader) applyRiskControl(check RiskCheck, decisions []decision.Decision, positions []decision.PositionInfo, record *logger.DecisionRecord) []decision.Decision {
+	if !check.Breached {
+		return decisions
+	}
+
+	limits := at.riskEngine.Limits()
+	log.Printf("🛑 风险控制触发: %s", check.Reason)
+	record.RiskReason = check.Reason
+	record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🛑 风险控制触发: %s", check.Reason))
+
+	allowed, blocked := at.riskEngine.Apply(check, decisions, positions)
+	for _, d := range blocked {
+		log.Printf("  ⛔ 风控拦截: %s %s", d.Symbol, d.Action)
+		record.Decisions = append(record.Decisions, logger.DecisionAction{
 			Action:    d.Action,
 			Symbol:    d.Symbol,
-			Quantity:  0,
 			Leverage:  d.Leverage,
-			Price:     0,
-			Timestamp: time.Now(),
+			Timestamp: at.now(),
 			Success:   false,
-		}
-
-		if err := at.executeDecisionWithRecord(&d, &actionRecord); err != nil {
-			log.Printf("❌ 执行决策失败 (%s %s): %v", d.Symbol, d.Action, err)
-			actionRecord.Error = err.Error()
-			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 失败: %v", d.Symbol, d.Action, err))
-		} else {
-			actionRecord.Success = true
-			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 成功", d.Symbol, d.Action))
-			// 成功执行后短暂延迟
-			time.Sleep(1 * time.Second)
-		}
-
-		record.Decisions = append(record.Decisions, actionRecord)
+			Error:     "风控拦截: " + check.Reason,
+		})
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⛔ %s %s 被风控拦截", d.Symbol, d.Action))
+	}
+	if limits.FlattenOnBreach {
+		log.Printf("  🧹 风控强制平仓: %d 个持仓", len(positions))
 	}
 
-	// 9. 保存决策记录
-	if err := at.decisionLogger.LogDecision(record); err != nil {
-		log.Printf("⚠ 保存决策记录失败: %v", err)
+	if limits.StopTradingTime > 0 {
+		at.stopUntil = at.now().Add(limits.StopTradingTime)
+		log.Printf("  ⏸ 暂停交易至 %s", at.stopUntil.Format("2006-01-02 15:04:05"))
+		record.ExecutionLog = append(record.ExecutionLog,
+			fmt.Sprintf("⏸ 暂停交易 %.0f 分钟", limits.StopTradingTime.Minutes()))
+	}
+	// 回撤基准重置为当前净值，恢复交易后重新累计回撤
+	at.riskEngine.ResetPeak(check.Equity)
+	if err := at.saveRiskState(); err != nil {
+		log.Printf("⚠️ [%s] 保存风控状态失败: %v", at.name, err)
 	}
 
-	return nil
+	return allowed
 }
 
 // buildTradingContext 构建交易上下文
@@ -486,23 +789,11 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		return nil, fmt.Errorf("获取账户余额失败: %w", err)
 	}
 
-	// 获取账户字段
-	totalWalletBalance := 0.0
-	totalUnrealizedProfit := 0.0
-	availableBalance := 0.0
-
-	if wallet, ok := balance["totalWalletBalance"].(float64); ok {
-		totalWalletBalance = wallet
-	}
-	if unrealized, ok := balance["totalUnrealizedProfit"].(float64); ok {
-		totalUnrealizedProfit = unrealized
-	}
-	if avail, ok := balance["availableBalance"].(float64); ok {
-		availableBalance = avail
-	}
+	totalUnrealizedProfit := balance.TotalUnrealizedProfit
+	availableBalance := balance.AvailableBalance
 
 	// Total Equity = 钱包余额 + 未实现盈亏
-	totalEquity := totalWalletBalance + totalUnrealizedProfit
+	totalEquity := balance.Equity()
 
 	// 2. 获取持仓信息
 	positions, err := at.trader.GetPositions()
@@ -517,29 +808,23 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 	currentPositionKeys := make(map[string]bool)
 
 	for _, pos := range positions {
-		symbol := pos["symbol"].(string)
-		side := pos["side"].(string)
-		entryPrice := pos["entryPrice"].(float64)
-		markPrice := pos["markPrice"].(float64)
-		quantity := pos["positionAmt"].(float64)
-		if quantity < 0 {
-			quantity = -quantity // 空仓数量为负，转为正数
-		}
+		symbol := pos.Symbol
+		side := pos.Side
+		entryPrice := pos.EntryPrice
+		markPrice := pos.MarkPrice
+		quantity := pos.Quantity
 
 		// 跳过已平仓的持仓（quantity = 0），防止"幽灵持仓"传递给AI
 		if quantity == 0 {
 			continue
 		}
 
-		unrealizedPnl := pos["unRealizedProfit"].(float64)
-		liquidationPrice := pos["liquidationPrice"].(float64)
+		unrealizedPnl := pos.UnrealizedPnL
+		liquidationPrice := pos.LiquidationPrice
 
 		// 计算占用保证金（估算）
-		leverage := 10 // 默认值，实际应该从持仓信息获取
-		if lev, ok := pos["leverage"].(float64); ok {
-			leverage = int(lev)
-		}
-		marginUsed := (quantity * markPrice) / float64(leverage)
+		leverage := pos.EffectiveLeverage()
+		marginUsed := pos.MarginUsed()
 		totalMarginUsed += marginUsed
 
 		// 计算盈亏百分比（基于保证金，考虑杠杆）
@@ -550,7 +835,7 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		currentPositionKeys[posKey] = true
 		if _, exists := at.positionFirstSeenTime[posKey]; !exists {
 			// 新持仓，记录当前时间
-			at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
+			at.positionFirstSeenTime[posKey] = at.now().UnixMilli()
 		}
 		updateTime := at.positionFirstSeenTime[posKey]
 
@@ -587,6 +872,9 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 	if err != nil {
 		return nil, fmt.Errorf("获取候选币种失败: %w", err)
 	}
+	candidateCoins = at.appendAlertCoins(candidateCoins)
+	candidateCoins = at.filterUnlistedCoins(candidateCoins)
+	at.updateWatchedSymbols(candidateCoins, positionInfos)
 
 	// 4. 计算总盈亏
 	totalPnL := totalEquity - at.initialBalance
@@ -602,7 +890,7 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 
 	// 5. 分析历史表现（最近100个周期，避免长期持仓的交易记录丢失）
 	// 假设每3分钟一个周期，100个周期 = 5小时，足够覆盖大部分交易
-	performance, err := at.decisionLogger.AnalyzePerformance(100)
+	performance, err := at.AnalyzePerformance(100)
 	if err != nil {
 		log.Printf("⚠️  分析历史表现失败: %v", err)
 		// 不影响主流程，继续执行（但设置performance为nil以避免传递错误数据）
@@ -611,11 +899,13 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 
 	// 6. 构建上下文
 	ctx := &decision.Context{
-		CurrentTime:     time.Now().Format("2006-01-02 15:04:05"),
-		RuntimeMinutes:  int(time.Since(at.startTime).Minutes()),
-		CallCount:       at.callCount,
-		BTCETHLeverage:  at.config.BTCETHLeverage,  // 使用配置的杠杆倍数
-		AltcoinLeverage: at.config.AltcoinLeverage, // 使用配置的杠杆倍数
+		CurrentTime:      at.now().Format("2006-01-02 15:04:05"),
+		Now:              at.now(),
+		RuntimeMinutes:   int(at.now().Sub(at.startTime).Minutes()),
+		CallCount:        at.callCount,
+		BTCETHLeverage:   at.config.BTCETHLeverage,  // 使用配置的杠杆倍数
+		AltcoinLeverage:  at.config.AltcoinLeverage, // 使用配置的杠杆倍数
+		MaxDepthFraction: at.config.MaxDepthFraction,
 		Account: decision.AccountInfo{
 			TotalEquity:      totalEquity,
 			AvailableBalance: availableBalance,
@@ -627,13 +917,34 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 			PositionCount:    len(positionInfos),
 		},
 		Positions:      positionInfos,
+		PendingOrders:  at.pendingOrderInfos(),
 		CandidateCoins: candidateCoins,
 		Performance:    performance, // 添加历史表现分析
+		MarketDataFunc: at.getMarketData,
 	}
+	ctx.FundingDivergence = at.fundingDivergence(candidateCoins, positionInfos)
 
 	return ctx, nil
 }
 
+// fundingDivergence 跟踪候选与持仓币种的跨交易所资金费率，返回已有多个交易所数据的币种差异
+// 未开启或采集器未启用（如回测）时返回 nil
+func (at *AutoTrader) fundingDivergence(coins []decision.CandidateCoin, positions []decision.PositionInfo) map[string]*market.FundingDivergence {
+	collector := market.GetFundingCollector()
+	if !at.config.CrossVenueFunding || collector == nil || at.marketDataFunc != nil {
+		return nil
+	}
+	symbols := make([]string, 0, len(coins)+len(positions))
+	for _, pos := range positions {
+		symbols = append(symbols, pos.Symbol)
+	}
+	for _, coin := range coins {
+		symbols = append(symbols, coin.Symbol)
+	}
+	collector.Track(symbols...)
+	return collector.Divergences(symbols)
+}
+
 // executeDecisionWithRecord 执行AI决策并记录详细信息
 func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	switch decision.Action {
@@ -667,22 +978,31 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 	positions, err := at.trader.GetPositions()
 	if err == nil {
 		for _, pos := range positions {
-			if pos["symbol"] == decision.Symbol && pos["side"] == "long" {
+			if pos.Symbol == decision.Symbol && pos.Side == "long" {
 				return fmt.Errorf("❌ %s 已有多仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_long 决策", decision.Symbol)
 			}
 		}
 	}
+	if at.hasPendingOrder(decision.Symbol, "long") {
+		return fmt.Errorf("❌ %s 已有未成交的限价开多单，拒绝重复开仓", decision.Symbol)
+	}
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
 
-	// 计算数量
-	quantity := decision.PositionSizeUSD / marketData.CurrentPrice
+	// 计算数量（限价单按挂单价计算）
+	entryPrice := marketData.CurrentPrice
+	if decision.IsLimitOrder() {
+		entryPrice = decision.EntryPrice
+	}
+	quantity := decision.PositionSizeUSD / entryPrice
 	actionRecord.Quantity = quantity
-	actionRecord.Price = marketData.CurrentPrice
+	actionRecord.Price = entryPrice
+	actionRecord.StopLoss = decision.StopLoss
+	actionRecord.TakeProfit = decision.TakeProfit
 
 	// ⚠️ 保证金验证：防止保证金不足错误（code=-2019）
 	requiredMargin := decision.PositionSizeUSD / float64(decision.Leverage)
@@ -691,10 +1011,7 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 	if err != nil {
 		return fmt.Errorf("获取账户余额失败: %w", err)
 	}
-	availableBalance := 0.0
-	if avail, ok := balance["availableBalance"].(float64); ok {
-		availableBalance = avail
-	}
+	availableBalance := balance.AvailableBalance
 
 	// 手续费估算（Taker费率 0.04%）
 	estimatedFee := decision.PositionSizeUSD * 0.0004
@@ -711,22 +1028,33 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 		// 继续执行，不影响交易
 	}
 
+	// 限价单：挂单成交后再设置止盈止损
+	if decision.IsLimitOrder() {
+		return at.placeLimitOrder(decision, "long", quantity, actionRecord)
+	}
+
 	// 开仓
 	order, err := at.trader.OpenLong(decision.Symbol, quantity, decision.Leverage)
 	if err != nil {
 		return err
 	}
+	at.recordOrder(&config.LedgerOrder{
+		Symbol:       decision.Symbol,
+		PositionSide: "LONG",
+		Quantity:     quantity,
+		Price:        marketData.CurrentPrice,
+		Leverage:     decision.Leverage,
+		Origin:       config.OrderOriginAI,
+	}, order)
 
 	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
-	}
+	actionRecord.OrderID = order.OrderID
 
-	log.Printf("  ✓ 开仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
+	log.Printf("  ✓ 开仓成功，订单ID: %d, 数量: %.4f", order.OrderID, quantity)
 
 	// 记录开仓时间
 	posKey := decision.Symbol + "_long"
-	at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
+	at.positionFirstSeenTime[posKey] = at.now().UnixMilli()
 
 	// 设置止损止盈
 	if err := at.trader.SetStopLoss(decision.Symbol, "LONG", quantity, decision.StopLoss); err != nil {
@@ -747,22 +1075,31 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	positions, err := at.trader.GetPositions()
 	if err == nil {
 		for _, pos := range positions {
-			if pos["symbol"] == decision.Symbol && pos["side"] == "short" {
+			if pos.Symbol == decision.Symbol && pos.Side == "short" {
 				return fmt.Errorf("❌ %s 已有空仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_short 决策", decision.Symbol)
 			}
 		}
 	}
+	if at.hasPendingOrder(decision.Symbol, "short") {
+		return fmt.Errorf("❌ %s 已有未成交的限价开空单，拒绝重复开仓", decision.Symbol)
+	}
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
 
-	// 计算数量
-	quantity := decision.PositionSizeUSD / marketData.CurrentPrice
+	// 计算数量（限价单按挂单价计算）
+	entryPrice := marketData.CurrentPrice
+	if decision.IsLimitOrder() {
+		entryPrice = decision.EntryPrice
+	}
+	quantity := decision.PositionSizeUSD / entryPrice
 	actionRecord.Quantity = quantity
-	actionRecord.Price = marketData.CurrentPrice
+	actionRecord.Price = entryPrice
+	actionRecord.StopLoss = decision.StopLoss
+	actionRecord.TakeProfit = decision.TakeProfit
 
 	// ⚠️ 保证金验证：防止保证金不足错误（code=-2019）
 	requiredMargin := decision.PositionSizeUSD / float64(decision.Leverage)
@@ -771,10 +1108,7 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	if err != nil {
 		return fmt.Errorf("获取账户余额失败: %w", err)
 	}
-	availableBalance := 0.0
-	if avail, ok := balance["availableBalance"].(float64); ok {
-		availableBalance = avail
-	}
+	availableBalance := balance.AvailableBalance
 
 	// 手续费估算（Taker费率 0.04%）
 	estimatedFee := decision.PositionSizeUSD * 0.0004
@@ -791,22 +1125,33 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 		// 继续执行，不影响交易
 	}
 
+	// 限价单：挂单成交后再设置止盈止损
+	if decision.IsLimitOrder() {
+		return at.placeLimitOrder(decision, "short", quantity, actionRecord)
+	}
+
 	// 开仓
 	order, err := at.trader.OpenShort(decision.Symbol, quantity, decision.Leverage)
 	if err != nil {
 		return err
 	}
+	at.recordOrder(&config.LedgerOrder{
+		Symbol:       decision.Symbol,
+		PositionSide: "SHORT",
+		Quantity:     quantity,
+		Price:        marketData.CurrentPrice,
+		Leverage:     decision.Leverage,
+		Origin:       config.OrderOriginAI,
+	}, order)
 
 	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
-	}
+	actionRecord.OrderID = order.OrderID
 
-	log.Printf("  ✓ 开仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
+	log.Printf("  ✓ 开仓成功，订单ID: %d, 数量: %.4f", order.OrderID, quantity)
 
 	// 记录开仓时间
 	posKey := decision.Symbol + "_short"
-	at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
+	at.positionFirstSeenTime[posKey] = at.now().UnixMilli()
 
 	// 设置止损止盈
 	if err := at.trader.SetStopLoss(decision.Symbol, "SHORT", quantity, decision.StopLoss); err != nil {
@@ -824,7 +1169,7 @@ func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, ac
 	log.Printf("  🔄 平多仓: %s", decision.Symbol)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
@@ -835,11 +1180,16 @@ func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, ac
 	if err != nil {
 		return err
 	}
+	at.recordOrder(&config.LedgerOrder{
+		Symbol:       decision.Symbol,
+		PositionSide: "LONG",
+		Price:        marketData.CurrentPrice,
+		ReduceOnly:   true,
+		Origin:       config.OrderOriginAI,
+	}, order)
 
 	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
-	}
+	actionRecord.OrderID = order.OrderID
 
 	log.Printf("  ✓ 平仓成功")
 	return nil
@@ -850,7 +1200,7 @@ func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, a
 	log.Printf("  🔄 平空仓: %s", decision.Symbol)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
@@ -861,11 +1211,16 @@ func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, a
 	if err != nil {
 		return err
 	}
+	at.recordOrder(&config.LedgerOrder{
+		Symbol:       decision.Symbol,
+		PositionSide: "SHORT",
+		Price:        marketData.CurrentPrice,
+		ReduceOnly:   true,
+		Origin:       config.OrderOriginAI,
+	}, order)
 
 	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
-	}
+	actionRecord.OrderID = order.OrderID
 
 	log.Printf("  ✓ 平仓成功")
 	return nil
@@ -876,11 +1231,12 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 	log.Printf("  🎯 调整止损: %s → %.2f", decision.Symbol, decision.NewStopLoss)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
 	actionRecord.Price = marketData.CurrentPrice
+	actionRecord.StopLoss = decision.NewStopLoss
 
 	// 获取当前持仓
 	positions, err := at.trader.GetPositions()
@@ -889,12 +1245,10 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 	}
 
 	// 查找目标持仓
-	var targetPosition map[string]interface{}
-	for _, pos := range positions {
-		symbol, _ := pos["symbol"].(string)
-		posAmt, _ := pos["positionAmt"].(float64)
-		if symbol == decision.Symbol && posAmt != 0 {
-			targetPosition = pos
+	var targetPosition *Position
+	for i := range positions {
+		if positions[i].Symbol == decision.Symbol && positions[i].Quantity != 0 {
+			targetPosition = &positions[i]
 			break
 		}
 	}
@@ -904,9 +1258,8 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 	}
 
 	// 获取持仓方向和数量
-	side, _ := targetPosition["side"].(string)
-	positionSide := strings.ToUpper(side)
-	positionAmt, _ := targetPosition["positionAmt"].(float64)
+	positionSide := targetPosition.PositionSide()
+	positionAmt := targetPosition.Quantity
 
 	// 验证新止损价格合理性
 	if positionSide == "LONG" && decision.NewStopLoss >= marketData.CurrentPrice {
@@ -920,12 +1273,9 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 	var hasOppositePosition bool
 	oppositeSide := ""
 	for _, pos := range positions {
-		symbol, _ := pos["symbol"].(string)
-		posSide, _ := pos["side"].(string)
-		posAmt, _ := pos["positionAmt"].(float64)
-		if symbol == decision.Symbol && posAmt != 0 && strings.ToUpper(posSide) != positionSide {
+		if pos.Symbol == decision.Symbol && pos.Quantity != 0 && pos.PositionSide() != positionSide {
 			hasOppositePosition = true
-			oppositeSide = strings.ToUpper(posSide)
+			oppositeSide = pos.PositionSide()
 			break
 		}
 	}
@@ -960,11 +1310,12 @@ func (at *AutoTrader) executeUpdateTakeProfitWithRecord(decision *decision.Decis
 	log.Printf("  🎯 调整止盈: %s → %.2f", decision.Symbol, decision.NewTakeProfit)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
 	actionRecord.Price = marketData.CurrentPrice
+	actionRecord.TakeProfit = decision.NewTakeProfit
 
 	// 获取当前持仓
 	positions, err := at.trader.GetPositions()
@@ -973,12 +1324,10 @@ func (at *AutoTrader) executeUpdateTakeProfitWithRecord(decision *decision.Decis
 	}
 
 	// 查找目标持仓
-	var targetPosition map[string]interface{}
-	for _, pos := range positions {
-		symbol, _ := pos["symbol"].(string)
-		posAmt, _ := pos["positionAmt"].(float64)
-		if symbol == decision.Symbol && posAmt != 0 {
-			targetPosition = pos
+	var targetPosition *Position
+	for i := range positions {
+		if positions[i].Symbol == decision.Symbol && positions[i].Quantity != 0 {
+			targetPosition = &positions[i]
 			break
 		}
 	}
@@ -988,9 +1337,8 @@ func (at *AutoTrader) executeUpdateTakeProfitWithRecord(decision *decision.Decis
 	}
 
 	// 获取持仓方向和数量
-	side, _ := targetPosition["side"].(string)
-	positionSide := strings.ToUpper(side)
-	positionAmt, _ := targetPosition["positionAmt"].(float64)
+	positionSide := targetPosition.PositionSide()
+	positionAmt := targetPosition.Quantity
 
 	// 验证新止盈价格合理性
 	if positionSide == "LONG" && decision.NewTakeProfit <= marketData.CurrentPrice {
@@ -1004,12 +1352,9 @@ func (at *AutoTrader) executeUpdateTakeProfitWithRecord(decision *decision.Decis
 	var hasOppositePosition bool
 	oppositeSide := ""
 	for _, pos := range positions {
-		symbol, _ := pos["symbol"].(string)
-		posSide, _ := pos["side"].(string)
-		posAmt, _ := pos["positionAmt"].(float64)
-		if symbol == decision.Symbol && posAmt != 0 && strings.ToUpper(posSide) != positionSide {
+		if pos.Symbol == decision.Symbol && pos.Quantity != 0 && pos.PositionSide() != positionSide {
 			hasOppositePosition = true
-			oppositeSide = strings.ToUpper(posSide)
+			oppositeSide = pos.PositionSide()
 			break
 		}
 	}
@@ -1049,7 +1394,7 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 	}
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
@@ -1062,12 +1407,10 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 	}
 
 	// 查找目标持仓
-	var targetPosition map[string]interface{}
-	for _, pos := range positions {
-		symbol, _ := pos["symbol"].(string)
-		posAmt, _ := pos["positionAmt"].(float64)
-		if symbol == decision.Symbol && posAmt != 0 {
-			targetPosition = pos
+	var targetPosition *Position
+	for i := range positions {
+		if positions[i].Symbol == decision.Symbol && positions[i].Quantity != 0 {
+			targetPosition = &positions[i]
 			break
 		}
 	}
@@ -1077,9 +1420,8 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 	}
 
 	// 获取持仓方向和数量
-	side, _ := targetPosition["side"].(string)
-	positionSide := strings.ToUpper(side)
-	positionAmt, _ := targetPosition["positionAmt"].(float64)
+	positionSide := targetPosition.PositionSide()
+	positionAmt := targetPosition.Quantity
 
 	// 计算平仓数量
 	totalQuantity := math.Abs(positionAmt)
@@ -1087,8 +1429,8 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 	actionRecord.Quantity = closeQuantity
 
 	// ✅ Layer 2: 最小仓位检查（防止产生小额剩余）
-	markPrice, ok := targetPosition["markPrice"].(float64)
-	if !ok || markPrice <= 0 {
+	markPrice := targetPosition.MarkPrice
+	if markPrice <= 0 {
 		return fmt.Errorf("无法解析当前价格，无法执行最小仓位检查")
 	}
 
@@ -1118,7 +1460,7 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 	}
 
 	// 执行平仓
-	var order map[string]interface{}
+	var order *OrderResult
 	if positionSide == "LONG" {
 		order, err = at.trader.CloseLong(decision.Symbol, closeQuantity)
 	} else {
@@ -1128,11 +1470,17 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 	if err != nil {
 		return fmt.Errorf("部分平仓失败: %w", err)
 	}
+	at.recordOrder(&config.LedgerOrder{
+		Symbol:       decision.Symbol,
+		PositionSide: positionSide,
+		Quantity:     closeQuantity,
+		Price:        marketData.CurrentPrice,
+		ReduceOnly:   true,
+		Origin:       config.OrderOriginAI,
+	}, order)
 
 	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
-	}
+	actionRecord.OrderID = order.OrderID
 
 	log.Printf("  ✓ 部分平仓成功: 平仓 %.4f (%.1f%%), 剩余 %.4f",
 		closeQuantity, decision.ClosePercentage, remainingQuantity)
@@ -1218,7 +1566,7 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
 		aiProvider = "Qwen"
 	}
 
-	return map[string]interface{}{
+	status := map[string]interface{}{
 		"trader_id":       at.id,
 		"trader_name":     at.name,
 		"ai_model":        at.aiModel,
@@ -1233,6 +1581,13 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
 		"last_reset_time": at.lastResetTime.Format(time.RFC3339),
 		"ai_provider":     aiProvider,
 	}
+	if reporter, ok := at.mcpClient.(mcp.HealthReporter); ok {
+		if health := reporter.ProviderHealth(); len(health) > 0 {
+			status["ai_last_provider"] = mcp.AnsweredBy(at.mcpClient)
+			status["ai_provider_health"] = health
+		}
+	}
+	return status
 }
 
 // GetAccountInfo 获取账户信息（用于API）
@@ -1242,23 +1597,12 @@ func (at *AutoTrader) GetAccountInfo() (map[string]interface{}, error) {
 		return nil, fmt.Errorf("获取余额失败: %w", err)
 	}
 
-	// 获取账户字段
-	totalWalletBalance := 0.0
-	totalUnrealizedProfit := 0.0
-	availableBalance := 0.0
-
-	if wallet, ok := balance["totalWalletBalance"].(float64); ok {
-		totalWalletBalance = wallet
-	}
-	if unrealized, ok := balance["totalUnrealizedProfit"].(float64); ok {
-		totalUnrealizedProfit = unrealized
-	}
-	if avail, ok := balance["availableBalance"].(float64); ok {
-		availableBalance = avail
-	}
+	totalWalletBalance := balance.TotalWalletBalance
+	totalUnrealizedProfit := balance.TotalUnrealizedProfit
+	availableBalance := balance.AvailableBalance
 
 	// Total Equity = 钱包余额 + 未实现盈亏
-	totalEquity := totalWalletBalance + totalUnrealizedProfit
+	totalEquity := balance.Equity()
 
 	// 获取持仓计算总保证金
 	positions, err := at.trader.GetPositions()
@@ -1269,20 +1613,8 @@ func (at *AutoTrader) GetAccountInfo() (map[string]interface{}, error) {
 	totalMarginUsed := 0.0
 	totalUnrealizedPnLCalculated := 0.0
 	for _, pos := range positions {
-		markPrice := pos["markPrice"].(float64)
-		quantity := pos["positionAmt"].(float64)
-		if quantity < 0 {
-			quantity = -quantity
-		}
-		unrealizedPnl := pos["unRealizedProfit"].(float64)
-		totalUnrealizedPnLCalculated += unrealizedPnl
-
-		leverage := 10
-		if lev, ok := pos["leverage"].(float64); ok {
-			leverage = int(lev)
-		}
-		marginUsed := (quantity * markPrice) / float64(leverage)
-		totalMarginUsed += marginUsed
+		totalUnrealizedPnLCalculated += pos.UnrealizedPnL
+		totalMarginUsed += pos.MarginUsed()
 	}
 
 	// 验证未实现盈亏的一致性（API值 vs 从持仓计算）
@@ -1334,38 +1666,22 @@ func (at *AutoTrader) GetPositions() ([]map[string]interface{}, error) {
 
 	var result []map[string]interface{}
 	for _, pos := range positions {
-		symbol := pos["symbol"].(string)
-		side := pos["side"].(string)
-		entryPrice := pos["entryPrice"].(float64)
-		markPrice := pos["markPrice"].(float64)
-		quantity := pos["positionAmt"].(float64)
-		if quantity < 0 {
-			quantity = -quantity
-		}
-		unrealizedPnl := pos["unRealizedProfit"].(float64)
-		liquidationPrice := pos["liquidationPrice"].(float64)
-
-		leverage := 10
-		if lev, ok := pos["leverage"].(float64); ok {
-			leverage = int(lev)
-		}
-
 		// 计算占用保证金
-		marginUsed := (quantity * markPrice) / float64(leverage)
+		marginUsed := pos.MarginUsed()
 
 		// 计算盈亏百分比（基于保证金）
-		pnlPct := calculatePnLPercentage(unrealizedPnl, marginUsed)
+		pnlPct := calculatePnLPercentage(pos.UnrealizedPnL, marginUsed)
 
 		result = append(result, map[string]interface{}{
-			"symbol":             symbol,
-			"side":               side,
-			"entry_price":        entryPrice,
-			"mark_price":         markPrice,
-			"quantity":           quantity,
-			"leverage":           leverage,
-			"unrealized_pnl":     unrealizedPnl,
+			"symbol":             pos.Symbol,
+			"side":               pos.Side,
+			"entry_price":        pos.EntryPrice,
+			"mark_price":         pos.MarkPrice,
+			"quantity":           pos.Quantity,
+			"leverage":           pos.EffectiveLeverage(),
+			"unrealized_pnl":     pos.UnrealizedPnL,
 			"unrealized_pnl_pct": pnlPct,
-			"liquidation_price":  liquidationPrice,
+			"liquidation_price":  pos.LiquidationPrice,
 			"margin_used":        marginUsed,
 		})
 	}
@@ -1492,124 +1808,293 @@ func normalizeSymbol(symbol string) string {
 	return symbol
 }
 
-// 启动回撤监控
+// 启动持仓保护监控
+// 有实时行情流时按每次价格推送评估，否则按固定间隔轮询持仓
 func (at *AutoTrader) startDrawdownMonitor() {
+	interval := at.protectionConfig.Interval()
+	stream := at.resolvePriceStream()
 	at.monitorWg.Add(1)
 	go func() {
 		defer at.monitorWg.Done()
 
-		ticker := time.NewTicker(1 * time.Minute) // 每分钟检查一次
+		if stream != nil {
+			at.runStreamProtection(stream, interval)
+			return
+		}
+
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
-		log.Println("📊 启动持仓回撤监控（每分钟检查一次）")
+		log.Printf("📊 启动持仓保护监控（每 %v 检查一次，%d 条规则）", interval, len(at.protectionRules))
 
 		for {
 			select {
 			case <-ticker.C:
 				at.checkPositionDrawdown()
 			case <-at.stopMonitorCh:
-				log.Println("⏹ 停止持仓回撤监控")
+				log.Println("⏹ 停止持仓保护监控")
 				return
 			}
 		}
 	}()
 }
 
-// 检查持仓回撤情况
+// 从交易所同步持仓并按保护规则检查，命中任一规则即平仓并写入决策日志
+// 同时刷新行情驱动模式使用的持仓快照
 func (at *AutoTrader) checkPositionDrawdown() {
 	// 获取当前持仓
 	positions, err := at.trader.GetPositions()
 	if err != nil {
-		log.Printf("❌ 回撤监控：获取持仓失败: %v", err)
+		log.Printf("❌ 持仓保护：获取持仓失败: %v", err)
 		return
 	}
 
+	// ATR在加锁前刷新，逐笔行情评估只读取缓存
+	symbols := make([]string, 0, len(positions))
 	for _, pos := range positions {
-		symbol := pos["symbol"].(string)
-		side := pos["side"].(string)
-		entryPrice := pos["entryPrice"].(float64)
-		markPrice := pos["markPrice"].(float64)
-		quantity := pos["positionAmt"].(float64)
-		if quantity < 0 {
-			quantity = -quantity // 空仓数量为负，转为正数
+		symbols = append(symbols, pos.Symbol)
+	}
+	at.refreshATRCache(symbols)
+
+	at.protectionMu.Lock()
+	defer at.protectionMu.Unlock()
+
+	record := newProtectionRecord()
+	tracked := make(map[string]trackedPosition, len(positions))
+	openKeys := make(map[string]bool, len(positions))
+
+	for _, pos := range positions {
+		tp := trackedPosition{Symbol: pos.Symbol, Side: pos.Side, EntryPrice: pos.EntryPrice, Quantity: pos.Quantity,
+			Leverage: pos.EffectiveLeverage()}
+		posKey := tp.key()
+		openKeys[posKey] = true
+
+		attempted, state := at.protectPosition(tp, pos.MarkPrice, record)
+		if attempted {
+			continue
 		}
+		tracked[posKey] = tp
 
-		// 计算当前盈亏百分比
-		leverage := 10 // 默认值
-		if lev, ok := pos["leverage"].(float64); ok {
-			leverage = int(lev)
+		if state.PnLPct > 5.0 {
+			// 记录盈利持仓的追踪状态（用于调试）
+			log.Printf("📊 持仓保护: %s %s | 收益: %.2f%% | 最高: %.2f%% | 回撤: %.2f%%",
+				pos.Symbol, pos.Side, state.PnLPct, state.PeakPnLPct, state.DrawdownPct())
 		}
+	}
+	at.protectedPositions = tracked
+
+	// 清理已不存在持仓的峰值（如被AI或交易所止盈止损平掉），避免同方向新仓位继承旧峰值
+	at.prunePeakPnLCache(openKeys)
+	at.finishProtectionRecord(record)
+}
 
-		var currentPnLPct float64
-		if side == "long" {
-			currentPnLPct = ((markPrice - entryPrice) / entryPrice) * float64(leverage) * 100
+// protectPosition 以给定价格评估单个持仓：更新峰值、匹配规则，命中时平仓并写入记录
+// 返回是否已尝试平仓，以及评估时的持仓视图
+func (at *AutoTrader) protectPosition(tp trackedPosition, markPrice float64, record *logger.DecisionRecord) (bool, *ProtectedPosition) {
+	var currentPnLPct float64
+	if tp.EntryPrice > 0 {
+		if tp.Side == "long" {
+			currentPnLPct = ((markPrice - tp.EntryPrice) / tp.EntryPrice) * float64(tp.Leverage) * 100
 		} else {
-			currentPnLPct = ((entryPrice - markPrice) / entryPrice) * float64(leverage) * 100
+			currentPnLPct = ((tp.EntryPrice - markPrice) / tp.EntryPrice) * float64(tp.Leverage) * 100
 		}
+	}
 
-		// 构造持仓唯一标识（区分多空）
-		posKey := symbol + "_" + side
+	// 获取该持仓的历史最高收益（首次出现时以当前盈亏为初始值）
+	at.peakPnLCacheMutex.RLock()
+	peakPnLPct, exists := at.peakPnLCache[tp.key()]
+	at.peakPnLCacheMutex.RUnlock()
+	if !exists {
+		peakPnLPct = currentPnLPct
+	}
+	at.UpdatePeakPnL(tp.Symbol, tp.Side, currentPnLPct)
 
-		// 获取该持仓的历史最高收益
-		at.peakPnLCacheMutex.RLock()
-		peakPnLPct, exists := at.peakPnLCache[posKey]
-		at.peakPnLCacheMutex.RUnlock()
+	state := &ProtectedPosition{
+		Symbol:     tp.Symbol,
+		Side:       tp.Side,
+		EntryPrice: tp.EntryPrice,
+		MarkPrice:  markPrice,
+		Quantity:   tp.Quantity,
+		Leverage:   tp.Leverage,
+		PnLPct:     currentPnLPct,
+		PeakPnLPct: peakPnLPct,
+		atr:        at.cachedATR(tp.Symbol),
+	}
 
-		if !exists {
-			// 如果没有历史最高记录，使用当前盈亏作为初始值
-			peakPnLPct = currentPnLPct
-			at.UpdatePeakPnL(symbol, side, currentPnLPct)
-		} else {
-			// 更新峰值缓存
-			at.UpdatePeakPnL(symbol, side, currentPnLPct)
+	rule, reason, triggered := at.evaluateProtectionRules(state)
+	if !triggered {
+		return false, state
+	}
+
+	symbol, side := tp.Symbol, tp.Side
+	log.Printf("🚨 触发持仓保护 [%s]: %s %s | %s", rule, symbol, side, reason)
+
+	actionRecord := logger.DecisionAction{
+		Action:    "auto_close_" + side,
+		Symbol:    symbol,
+		Quantity:  tp.Quantity,
+		Leverage:  tp.Leverage,
+		Price:     markPrice,
+		Timestamp: at.now(),
+		Reasoning: fmt.Sprintf("[%s] %s", rule, reason),
+	}
+
+	// 执行平仓
+	if err := at.emergencyClosePosition(symbol, side, tp.Quantity, markPrice); err != nil {
+		log.Printf("❌ 持仓保护平仓失败 (%s %s): %v", symbol, side, err)
+		actionRecord.Error = err.Error()
+		record.Success = false
+		record.ExecutionLog = append(record.ExecutionLog,
+			fmt.Sprintf("❌ 持仓保护[%s] %s %s 平仓失败: %v", rule, symbol, side, err))
+	} else {
+		log.Printf("✅ 持仓保护平仓成功: %s %s", symbol, side)
+		actionRecord.Success = true
+		record.ExecutionLog = append(record.ExecutionLog,
+			fmt.Sprintf("🛡️ 持仓保护[%s] %s %s 已平仓: %s", rule, symbol, side, reason))
+		// 平仓后清理该持仓的缓存
+		at.ClearPeakPnLCache(symbol, side)
+	}
+	record.Decisions = append(record.Decisions, actionRecord)
+	return true, state
+}
+
+// newProtectionRecord 创建持仓保护的决策记录
+func newProtectionRecord() *logger.DecisionRecord {
+	return &logger.DecisionRecord{
+		ExecutionLog: []string{},
+		Success:      true,
+	}
+}
+
+// finishProtectionRecord 持久化峰值缓存，并在有平仓动作时写入决策日志
+func (at *AutoTrader) finishProtectionRecord(record *logger.DecisionRecord) {
+	if err := at.savePeakPnLCache(); err != nil {
+		log.Printf("⚠️ 保存峰值缓存失败: %v", err)
+	}
+
+	if len(record.Decisions) > 0 {
+		if err := at.decisionLogger.LogDecision(record); err != nil {
+			log.Printf("⚠ 保存持仓保护记录失败: %v", err)
 		}
+	}
+}
 
-		// 计算回撤（从最高点下跌的幅度）
-		var drawdownPct float64
-		if peakPnLPct > 0 && currentPnLPct < peakPnLPct {
-			drawdownPct = ((peakPnLPct - currentPnLPct) / peakPnLPct) * 100
+// evaluateProtectionRules 按顺序评估适用的保护规则，返回首个命中的规则名称与原因
+func (at *AutoTrader) evaluateProtectionRules(pos *ProtectedPosition) (string, string, bool) {
+	for _, rule := range at.protectionRules {
+		if !rule.Applies(pos.Symbol) {
+			continue
 		}
+		if ok, reason := rule.ShouldClose(pos); ok {
+			return rule.Name(), reason, true
+		}
+	}
+	return "", "", false
+}
 
-		// 检查平仓条件：收益大于5%且回撤超过40%
-		if currentPnLPct > 5.0 && drawdownPct >= 40.0 {
-			log.Printf("🚨 触发回撤平仓条件: %s %s | 当前收益: %.2f%% | 最高收益: %.2f%% | 回撤: %.2f%%",
-				symbol, side, currentPnLPct, peakPnLPct, drawdownPct)
-
-			// 执行平仓
-			if err := at.emergencyClosePosition(symbol, side); err != nil {
-				log.Printf("❌ 回撤平仓失败 (%s %s): %v", symbol, side, err)
-			} else {
-				log.Printf("✅ 回撤平仓成功: %s %s", symbol, side)
-				// 平仓后清理该持仓的缓存
-				at.ClearPeakPnLCache(symbol, side)
-			}
-		} else if currentPnLPct > 5.0 {
-			// 记录接近平仓条件的情况（用于调试）
-			log.Printf("📊 回撤监控: %s %s | 收益: %.2f%% | 最高: %.2f%% | 回撤: %.2f%%",
-				symbol, side, currentPnLPct, peakPnLPct, drawdownPct)
+// atrEntry 缓存的4小时ATR，bucket 为获取时所在的4小时K线起点
+type atrEntry struct {
+	value  float64
+	bucket time.Time
+}
+
+// needsATR 是否有适用于该币种的ATR追踪规则
+func (at *AutoTrader) needsATR(symbol string) bool {
+	for _, rule := range at.protectionRules {
+		if rule.Name() == ProtectionATRTrail && rule.Applies(symbol) {
+			return true
 		}
 	}
+	return false
 }
 
-// 紧急平仓函数
-func (at *AutoTrader) emergencyClosePosition(symbol, side string) error {
+// refreshATRCache 同步持仓时刷新持仓币种的4小时ATR缓存
+// 每根4小时K线收盘后重新获取一次；获取失败时保留旧值，已无持仓的币种移出缓存
+func (at *AutoTrader) refreshATRCache(symbols []string) {
+	bucket := at.now().UTC().Truncate(4 * time.Hour)
+	held := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		held[symbol] = true
+		if !at.needsATR(symbol) {
+			continue
+		}
+		at.atrMu.RLock()
+		entry, ok := at.atrCache[symbol]
+		at.atrMu.RUnlock()
+		if ok && entry.bucket.Equal(bucket) {
+			continue
+		}
+
+		atr, err := at.getLongerTermATR(symbol)
+		if err != nil {
+			log.Printf("⚠️ 持仓保护：获取 %s 4小时ATR失败: %v", symbol, err)
+			continue
+		}
+		at.atrMu.Lock()
+		if at.atrCache == nil {
+			at.atrCache = make(map[string]atrEntry)
+		}
+		at.atrCache[symbol] = atrEntry{value: atr, bucket: bucket}
+		at.atrMu.Unlock()
+	}
+
+	at.atrMu.Lock()
+	for symbol := range at.atrCache {
+		if !held[symbol] {
+			delete(at.atrCache, symbol)
+		}
+	}
+	at.atrMu.Unlock()
+}
+
+// cachedATR 读取缓存的4小时ATR（未缓存返回0）
+func (at *AutoTrader) cachedATR(symbol string) float64 {
+	at.atrMu.RLock()
+	defer at.atrMu.RUnlock()
+	return at.atrCache[symbol].value
+}
+
+// getLongerTermATR 获取4小时ATR14（供ATR追踪规则使用）
+func (at *AutoTrader) getLongerTermATR(symbol string) (float64, error) {
+	data, err := at.getMarketData(symbol)
+	if err != nil {
+		return 0, err
+	}
+	if data.LongerTermContext == nil {
+		return 0, fmt.Errorf("%s 缺少4小时数据", symbol)
+	}
+	return data.LongerTermContext.ATR14, nil
+}
+
+// 紧急平仓函数（持仓保护触发），quantity 与 markPrice 为触发时的持仓快照，用于写入账本
+func (at *AutoTrader) emergencyClosePosition(symbol, side string, quantity, markPrice float64) error {
+	var order *OrderResult
+	var err error
 	switch side {
 	case "long":
-		order, err := at.trader.CloseLong(symbol, 0) // 0 = 全部平仓
+		order, err = at.trader.CloseLong(symbol, 0) // 0 = 全部平仓
 		if err != nil {
 			return err
 		}
-		log.Printf("✅ 紧急平多仓成功，订单ID: %v", order["orderId"])
+		log.Printf("✅ 紧急平多仓成功，订单ID: %d", order.OrderID)
 	case "short":
-		order, err := at.trader.CloseShort(symbol, 0) // 0 = 全部平仓
+		order, err = at.trader.CloseShort(symbol, 0) // 0 = 全部平仓
 		if err != nil {
 			return err
 		}
-		log.Printf("✅ 紧急平空仓成功，订单ID: %v", order["orderId"])
+		log.Printf("✅ 紧急平空仓成功，订单ID: %d", order.OrderID)
 	default:
 		return fmt.Errorf("未知的持仓方向: %s", side)
 	}
 
+	at.recordOrder(&config.LedgerOrder{
+		Symbol:       symbol,
+		PositionSide: strings.ToUpper(side),
+		Quantity:     quantity,
+		Price:        markPrice,
+		ReduceOnly:   true,
+		Origin:       config.OrderOriginMonitor,
+	}, order)
 	return nil
 }
 
@@ -1651,3 +2136,60 @@ func (at *AutoTrader) ClearPeakPnLCache(symbol, side string) {
 	posKey := symbol + "_" + side
 	delete(at.peakPnLCache, posKey)
 }
+
+// prunePeakPnLCache 删除不在当前持仓中的峰值缓存
+func (at *AutoTrader) prunePeakPnLCache(openKeys map[string]bool) {
+	at.peakPnLCacheMutex.Lock()
+	defer at.peakPnLCacheMutex.Unlock()
+
+	for posKey := range at.peakPnLCache {
+		if !openKeys[posKey] {
+			delete(at.peakPnLCache, posKey)
+		}
+	}
+}
+
+// loadPeakPnLCache 从文件恢复峰值缓存
+func (at *AutoTrader) loadPeakPnLCache() error {
+	if at.peakPnLFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(at.peakPnLFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取峰值缓存文件失败: %w", err)
+	}
+
+	cache := make(map[string]float64)
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return fmt.Errorf("解析峰值缓存文件失败: %w", err)
+	}
+
+	at.peakPnLCacheMutex.Lock()
+	defer at.peakPnLCacheMutex.Unlock()
+	for k, v := range cache {
+		at.peakPnLCache[k] = v
+	}
+	if len(cache) > 0 {
+		log.Printf("📂 [%s] 已恢复 %d 个持仓的峰值收益", at.name, len(cache))
+	}
+	return nil
+}
+
+// savePeakPnLCache 将峰值缓存写入文件
+func (at *AutoTrader) savePeakPnLCache() error {
+	if at.peakPnLFile == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(at.GetPeakPnLCache(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化峰值缓存失败: %w", err)
+	}
+	if err := logger.WriteFileAtomic(at.peakPnLFile, data); err != nil {
+		return fmt.Errorf("写入峰值缓存失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package trader
+
+import (
+	"fmt"
+	"nofx/logger"
+	"nofx/market"
+	"nofx/mcp"
+	"time"
+)
+
+// BacktestDeps 回测模式下注入的依赖
+type BacktestDeps struct {
+	Trader         Trader                                    // 模拟撮合（通常为 PaperTrader）
+	AIClient       mcp.AIClient                              // AI客户端（桩或录制回放）
+	DecisionLogger logger.IDecisionLogger                    // 决策日志记录器
+	MarketDataFunc func(symbol string) (*market.Data, error) // 历史市场数据源
+	Clock          func() time.Time                          // 模拟时钟
+}
+
+// NewBacktestAutoTrader 创建回测用自动交易器
+// 与 NewAutoTrader 共用同一套决策与执行流程，但交易所、AI、行情和时钟均由调用方注入
+func NewBacktestAutoTrader(config AutoTraderConfig, deps BacktestDeps) (*AutoTrader, error) {
+	if deps.Trader == nil {
+		return nil, fmt.Errorf("回测交易器不能为空")
+	}
+	if deps.AIClient == nil {
+		return nil, fmt.Errorf("回测AI客户端不能为空")
+	}
+	if deps.DecisionLogger == nil {
+		return nil, fmt.Errorf("回测决策日志记录器不能为空")
+	}
+	if deps.MarketDataFunc == nil {
+		return nil, fmt.Errorf("回测行情数据源不能为空")
+	}
+	if deps.Clock == nil {
+		return nil, fmt.Errorf("回测时钟不能为空")
+	}
+	if config.InitialBalance <= 0 {
+		return nil, fmt.Errorf("初始金额必须大于0")
+	}
+
+	if config.ID == "" {
+		config.ID = "backtest"
+	}
+	if config.Name == "" {
+		config.Name = "Backtest"
+	}
+	if config.Exchange == "" {
+		config.Exchange = "paper"
+	}
+
+	at, err := newAutoTrader(config, deps.Trader, deps.AIClient, deps.DecisionLogger)
+	if err != nil {
+		return nil, err
+	}
+	// 集成模型与账本、峰值缓存与风控状态文件均不启用：回测只调用注入的AI客户端，且不写入实盘状态
+	at.marketDataFunc = deps.MarketDataFunc
+	at.clock = deps.Clock
+	at.executionDelay = 0
+	now := deps.Clock()
+	at.lastResetTime = now
+	at.startTime = now
+	at.lastBalanceSyncTime = now
+	return at, nil
+}
+
+// RunCycle 手动执行一个交易周期（回测引擎按模拟时间逐步调用）
+func (at *AutoTrader) RunCycle() error {
+	return at.runCycle()
+}
@@ -0,0 +1,38 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"nofx/logger"
+	"nofx/market"
+	"nofx/mcp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBacktestAutoTrader_SharesLiveDefaults(t *testing.T) {
+	simTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at, err := NewBacktestAutoTrader(AutoTraderConfig{InitialBalance: 1000}, BacktestDeps{
+		Trader:         newTestPaperTrader(t, paperPriceFeed{"BTCUSDT": 50000}, ""),
+		AIClient:       mcp.New(),
+		DecisionLogger: logger.NewDecisionLogger(t.TempDir()),
+		MarketDataFunc: func(symbol string) (*market.Data, error) { return nil, nil },
+		Clock:          func() time.Time { return simTime },
+	})
+	require.NoError(t, err)
+
+	// 与实盘共用构造逻辑：实盘初始化的通道与缓存在回测中同样可用
+	assert.NotNil(t, at.protectionRefreshCh)
+	assert.NotNil(t, at.positionFirstSeenTime)
+	assert.NotNil(t, at.peakPnLCache)
+	assert.Equal(t, "adaptive", at.systemPromptTemplate)
+	// 回测专属依赖覆盖实盘默认值
+	assert.Equal(t, simTime, at.now())
+	assert.Equal(t, simTime, at.startTime)
+	assert.Zero(t, at.executionDelay)
+	assert.Empty(t, at.peakPnLFile, "回测不写入峰值缓存文件")
+	assert.Nil(t, at.ledger)
+	assert.Nil(t, at.ensemble)
+}
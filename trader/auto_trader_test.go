@@ -57,12 +57,12 @@ func (s *AutoTraderTestSuite) SetupTest() {
 
 	// 创建 mock 对象
 	s.mockTrader = &MockTrader{
-		balance: map[string]interface{}{
-			"totalWalletBalance":    10000.0,
-			"availableBalance":      8000.0,
-			"totalUnrealizedProfit": 100.0,
+		balance: &Balance{
+			TotalWalletBalance:    10000.0,
+			AvailableBalance:      8000.0,
+			TotalUnrealizedProfit: 100.0,
 		},
-		positions: []map[string]interface{}{},
+		positions: []Position{},
 	}
 
 	s.mockDB = &MockDatabase{}
@@ -84,6 +84,9 @@ func (s *AutoTraderTestSuite) SetupTest() {
 		IsCrossMargin:        true,
 	}
 
+	protectionRules, err := BuildProtectionRules(DefaultProtectionConfig().Rules)
+	s.Require().NoError(err)
+
 	// 创建 AutoTrader 实例（直接构造，不调用 NewAutoTrader 以避免外部依赖）
 	s.autoTrader = &AutoTrader{
 		id:                    s.config.ID,
@@ -99,12 +102,15 @@ func (s *AutoTraderTestSuite) SetupTest() {
 		defaultCoins:          []string{"BTC", "ETH"},
 		tradingCoins:          []string{},
 		lastResetTime:         time.Now(),
+		riskEngine:            newRiskEngineFromConfig(s.config),
 		startTime:             time.Now(),
 		callCount:             0,
 		isRunning:             false,
 		positionFirstSeenTime: make(map[string]int64),
 		stopMonitorCh:         make(chan struct{}),
 		peakPnLCache:          make(map[string]float64),
+		protectionConfig:      DefaultProtectionConfig(),
+		protectionRules:       protectionRules,
 		lastBalanceSyncTime:   time.Now(),
 		database:              s.mockDB,
 		userID:                "test_user",
@@ -299,16 +305,16 @@ func (s *AutoTraderTestSuite) TestGetPositions() {
 
 	s.Run("有持仓", func() {
 		// 设置 mock 持仓
-		s.mockTrader.positions = []map[string]interface{}{
+		s.mockTrader.positions = []Position{
 			{
-				"symbol":           "BTCUSDT",
-				"side":             "long",
-				"entryPrice":       50000.0,
-				"markPrice":        51000.0,
-				"positionAmt":      0.1,
-				"unRealizedProfit": 100.0,
-				"liquidationPrice": 45000.0,
-				"leverage":         10.0,
+				Symbol:           "BTCUSDT",
+				Side:             "long",
+				EntryPrice:       50000.0,
+				MarkPrice:        51000.0,
+				Quantity:         0.1,
+				UnrealizedPnL:    100.0,
+				LiquidationPrice: 45000.0,
+				Leverage:         10,
 			},
 		}
 
@@ -480,11 +486,11 @@ func (s *AutoTraderTestSuite) TestExecuteOpenPosition() {
 				return &market.Data{Symbol: symbol, CurrentPrice: 50000.0}, nil
 			})
 
-			s.mockTrader.balance["availableBalance"] = tt.availBalance
+			s.mockTrader.balance.AvailableBalance = tt.availBalance
 			if tt.existingSide != "" {
-				s.mockTrader.positions = []map[string]interface{}{{"symbol": "BTCUSDT", "side": tt.existingSide}}
+				s.mockTrader.positions = []Position{{Symbol: "BTCUSDT", Side: tt.existingSide}}
 			} else {
-				s.mockTrader.positions = []map[string]interface{}{}
+				s.mockTrader.positions = []Position{}
 			}
 
 			decision := &decision.Decision{Action: tt.action, Symbol: "BTCUSDT", PositionSizeUSD: 1000.0, Leverage: 10}
@@ -503,8 +509,8 @@ func (s *AutoTraderTestSuite) TestExecuteOpenPosition() {
 			}
 
 			// 恢复默认状态
-			s.mockTrader.balance["availableBalance"] = 8000.0
-			s.mockTrader.positions = []map[string]interface{}{}
+			s.mockTrader.balance.AvailableBalance = 8000.0
+			s.mockTrader.positions = []Position{}
 		})
 	}
 }
@@ -687,11 +693,11 @@ func (s *AutoTraderTestSuite) TestExecuteUpdateStopOrTakeProfit() {
 			testPrice = &tt.currentPrice
 
 			if tt.hasPosition {
-				s.mockTrader.positions = []map[string]interface{}{
-					{"symbol": tt.symbol, "side": tt.side, "positionAmt": 0.1},
+				s.mockTrader.positions = []Position{
+					{Symbol: tt.symbol, Side: tt.side, Quantity: 0.1},
 				}
 			} else {
-				s.mockTrader.positions = []map[string]interface{}{}
+				s.mockTrader.positions = []Position{}
 			}
 
 			decision := &decision.Decision{Action: tt.action, Symbol: tt.symbol}
@@ -713,7 +719,7 @@ func (s *AutoTraderTestSuite) TestExecuteUpdateStopOrTakeProfit() {
 			}
 
 			// 恢复默认状态
-			s.mockTrader.positions = []map[string]interface{}{}
+			s.mockTrader.positions = []Position{}
 		})
 	}
 }
@@ -721,13 +727,13 @@ func (s *AutoTraderTestSuite) TestExecuteUpdateStopOrTakeProfit() {
 func (s *AutoTraderTestSuite) TestExecutePartialCloseWithRecord() {
 	s.Run("成功部分平仓", func() {
 		// 设置持仓
-		s.mockTrader.positions = []map[string]interface{}{
+		s.mockTrader.positions = []Position{
 			{
-				"symbol":      "BTCUSDT",
-				"side":        "long",
-				"positionAmt": 0.1,
-				"entryPrice":  50000.0,
-				"markPrice":   52000.0,
+				Symbol:     "BTCUSDT",
+				Side:       "long",
+				Quantity:   0.1,
+				EntryPrice: 50000.0,
+				MarkPrice:  52000.0,
 			},
 		}
 
@@ -852,14 +858,14 @@ func (s *AutoTraderTestSuite) TestCheckPositionDrawdown() {
 		},
 		{
 			name:           "无持仓_不panic",
-			setupPositions: func() { s.mockTrader.positions = []map[string]interface{}{} },
+			setupPositions: func() { s.mockTrader.positions = []Position{} },
 			skipCacheCheck: true,
 		},
 		{
 			name: "收益不足5%_不触发平仓",
 			setupPositions: func() {
-				s.mockTrader.positions = []map[string]interface{}{
-					{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.1, "entryPrice": 50000.0, "markPrice": 50150.0, "leverage": 10.0},
+				s.mockTrader.positions = []Position{
+					{Symbol: "BTCUSDT", Side: "long", Quantity: 0.1, EntryPrice: 50000.0, MarkPrice: 50150.0, Leverage: 10},
 				}
 			},
 			setupPeakPnL:   func() { s.autoTrader.ClearPeakPnLCache("BTCUSDT", "long") },
@@ -868,8 +874,8 @@ func (s *AutoTraderTestSuite) TestCheckPositionDrawdown() {
 		{
 			name: "回撤不足40%_不触发平仓",
 			setupPositions: func() {
-				s.mockTrader.positions = []map[string]interface{}{
-					{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.1, "entryPrice": 50000.0, "markPrice": 50400.0, "leverage": 10.0},
+				s.mockTrader.positions = []Position{
+					{Symbol: "BTCUSDT", Side: "long", Quantity: 0.1, EntryPrice: 50000.0, MarkPrice: 50400.0, Leverage: 10},
 				}
 			},
 			setupPeakPnL:   func() { s.autoTrader.UpdatePeakPnL("BTCUSDT", "long", 10.0) },
@@ -878,8 +884,8 @@ func (s *AutoTraderTestSuite) TestCheckPositionDrawdown() {
 		{
 			name: "多头_触发回撤平仓",
 			setupPositions: func() {
-				s.mockTrader.positions = []map[string]interface{}{
-					{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.1, "entryPrice": 50000.0, "markPrice": 50300.0, "leverage": 10.0},
+				s.mockTrader.positions = []Position{
+					{Symbol: "BTCUSDT", Side: "long", Quantity: 0.1, EntryPrice: 50000.0, MarkPrice: 50300.0, Leverage: 10},
 				}
 			},
 			setupPeakPnL:     func() { s.autoTrader.UpdatePeakPnL("BTCUSDT", "long", 10.0) },
@@ -889,8 +895,8 @@ func (s *AutoTraderTestSuite) TestCheckPositionDrawdown() {
 		{
 			name: "空头_触发回撤平仓",
 			setupPositions: func() {
-				s.mockTrader.positions = []map[string]interface{}{
-					{"symbol": "ETHUSDT", "side": "short", "positionAmt": -0.5, "entryPrice": 3000.0, "markPrice": 2982.0, "leverage": 10.0},
+				s.mockTrader.positions = []Position{
+					{Symbol: "ETHUSDT", Side: "short", Quantity: 0.5, EntryPrice: 3000.0, MarkPrice: 2982.0, Leverage: 10},
 				}
 			},
 			setupPeakPnL:     func() { s.autoTrader.UpdatePeakPnL("ETHUSDT", "short", 10.0) },
@@ -900,8 +906,8 @@ func (s *AutoTraderTestSuite) TestCheckPositionDrawdown() {
 		{
 			name: "多头_平仓失败_保留缓存",
 			setupPositions: func() {
-				s.mockTrader.positions = []map[string]interface{}{
-					{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.1, "entryPrice": 50000.0, "markPrice": 50300.0, "leverage": 10.0},
+				s.mockTrader.positions = []Position{
+					{Symbol: "BTCUSDT", Side: "long", Quantity: 0.1, EntryPrice: 50000.0, MarkPrice: 50300.0, Leverage: 10},
 				}
 			},
 			setupPeakPnL:     func() { s.autoTrader.UpdatePeakPnL("BTCUSDT", "long", 10.0) },
@@ -913,8 +919,8 @@ func (s *AutoTraderTestSuite) TestCheckPositionDrawdown() {
 		{
 			name: "空头_平仓失败_保留缓存",
 			setupPositions: func() {
-				s.mockTrader.positions = []map[string]interface{}{
-					{"symbol": "ETHUSDT", "side": "short", "positionAmt": -0.5, "entryPrice": 3000.0, "markPrice": 2982.0, "leverage": 10.0},
+				s.mockTrader.positions = []Position{
+					{Symbol: "ETHUSDT", Side: "short", Quantity: 0.5, EntryPrice: 3000.0, MarkPrice: 2982.0, Leverage: 10},
 				}
 			},
 			setupPeakPnL:     func() { s.autoTrader.UpdatePeakPnL("ETHUSDT", "short", 10.0) },
@@ -953,7 +959,7 @@ func (s *AutoTraderTestSuite) TestCheckPositionDrawdown() {
 			}
 
 			// 清理状态
-			s.mockTrader.positions = []map[string]interface{}{}
+			s.mockTrader.positions = []Position{}
 		})
 	}
 }
@@ -976,8 +982,8 @@ func (m *MockDatabase) UpdateTraderInitialBalance(userID, traderID string, newBa
 
 // MockTrader 增强版（添加错误控制）
 type MockTrader struct {
-	balance              map[string]interface{}
-	positions            []map[string]interface{}
+	balance              *Balance
+	positions            []Position
 	shouldFailBalance    bool
 	shouldFailPositions  bool
 	shouldFailOpenLong   bool
@@ -985,65 +991,65 @@ type MockTrader struct {
 	shouldFailCloseShort bool
 }
 
-func (m *MockTrader) GetBalance() (map[string]interface{}, error) {
+func (m *MockTrader) GetBalance() (*Balance, error) {
 	if m.shouldFailBalance {
 		return nil, errors.New("failed to get balance")
 	}
 	if m.balance == nil {
-		return map[string]interface{}{
-			"totalWalletBalance":    10000.0,
-			"availableBalance":      8000.0,
-			"totalUnrealizedProfit": 100.0,
+		return &Balance{
+			TotalWalletBalance:    10000.0,
+			AvailableBalance:      8000.0,
+			TotalUnrealizedProfit: 100.0,
 		}, nil
 	}
 	return m.balance, nil
 }
 
-func (m *MockTrader) GetPositions() ([]map[string]interface{}, error) {
+func (m *MockTrader) GetPositions() ([]Position, error) {
 	if m.shouldFailPositions {
 		return nil, errors.New("failed to get positions")
 	}
 	if m.positions == nil {
-		return []map[string]interface{}{}, nil
+		return []Position{}, nil
 	}
 	return m.positions, nil
 }
 
-func (m *MockTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (m *MockTrader) OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error) {
 	if m.shouldFailOpenLong {
 		return nil, errors.New("failed to open long")
 	}
-	return map[string]interface{}{
-		"orderId": int64(123456),
-		"symbol":  symbol,
-	}, nil
+	return &OrderResult{OrderID: 123456, Symbol: symbol}, nil
+}
+
+func (m *MockTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return &OrderResult{OrderID: 123457, Symbol: symbol}, nil
+}
+
+func (m *MockTrader) OpenLongLimit(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	return &OrderResult{OrderID: 123458, Symbol: symbol, Status: OrderStatusNew}, nil
+}
+
+func (m *MockTrader) OpenShortLimit(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	return &OrderResult{OrderID: 123459, Symbol: symbol, Status: OrderStatusNew}, nil
 }
 
-func (m *MockTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
-	return map[string]interface{}{
-		"orderId": int64(123457),
-		"symbol":  symbol,
-	}, nil
+func (m *MockTrader) CancelOrder(symbol string, orderID string) error {
+	return nil
 }
 
-func (m *MockTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+func (m *MockTrader) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
 	if m.shouldFailCloseLong {
 		return nil, errors.New("failed to close long")
 	}
-	return map[string]interface{}{
-		"orderId": int64(123458),
-		"symbol":  symbol,
-	}, nil
+	return &OrderResult{OrderID: 123458, Symbol: symbol}, nil
 }
 
-func (m *MockTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+func (m *MockTrader) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
 	if m.shouldFailCloseShort {
 		return nil, errors.New("failed to close short")
 	}
-	return map[string]interface{}{
-		"orderId": int64(123459),
-		"symbol":  symbol,
-	}, nil
+	return &OrderResult{OrderID: 123459, Symbol: symbol}, nil
 }
 
 func (m *MockTrader) SetLeverage(symbol string, leverage int) error {
@@ -1086,6 +1092,18 @@ func (m *MockTrader) FormatQuantity(symbol string, quantity float64) (string, er
 	return fmt.Sprintf("%.4f", quantity), nil
 }
 
+func (m *MockTrader) GetFills(symbol string, since time.Time) ([]Fill, error) {
+	return nil, nil
+}
+
+func (m *MockTrader) GetIncome(symbol string, since time.Time) ([]Income, error) {
+	return nil, nil
+}
+
+func (m *MockTrader) GetOpenOrders(symbol string) ([]OpenOrder, error) {
+	return nil, nil
+}
+
 // ============================================================
 // 测试套件入口
 // ============================================================
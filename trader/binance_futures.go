@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log"
+	"math"
 	"nofx/hook"
 	"strconv"
 	"strings"
@@ -48,17 +49,21 @@ type FuturesTrader struct {
 	client *futures.Client
 
 	// 余额缓存
-	cachedBalance     map[string]interface{}
+	cachedBalance     *Balance
 	balanceCacheTime  time.Time
 	balanceCacheMutex sync.RWMutex
 
 	// 持仓缓存
-	cachedPositions     []map[string]interface{}
+	cachedPositions     []Position
 	positionsCacheTime  time.Time
 	positionsCacheMutex sync.RWMutex
 
 	// 缓存有效期（15秒）
 	cacheDuration time.Duration
+
+	// 订单类型缓存（成交对账时区分条件单与市价单）
+	orderTypeCache map[int64]string
+	orderTypeMutex sync.Mutex
 }
 
 // NewFuturesTrader 创建合约交易器
@@ -123,7 +128,7 @@ func syncBinanceServerTime(client *futures.Client) {
 }
 
 // GetBalance 获取账户余额（带缓存）
-func (t *FuturesTrader) GetBalance() (map[string]interface{}, error) {
+func (t *FuturesTrader) GetBalance() (*Balance, error) {
 	// 先检查缓存是否有效
 	t.balanceCacheMutex.RLock()
 	if t.cachedBalance != nil && time.Since(t.balanceCacheTime) < t.cacheDuration {
@@ -142,10 +147,10 @@ func (t *FuturesTrader) GetBalance() (map[string]interface{}, error) {
 		return nil, fmt.Errorf("获取账户信息失败: %w", err)
 	}
 
-	result := make(map[string]interface{})
-	result["totalWalletBalance"], _ = strconv.ParseFloat(account.TotalWalletBalance, 64)
-	result["availableBalance"], _ = strconv.ParseFloat(account.AvailableBalance, 64)
-	result["totalUnrealizedProfit"], _ = strconv.ParseFloat(account.TotalUnrealizedProfit, 64)
+	result := &Balance{}
+	result.TotalWalletBalance, _ = strconv.ParseFloat(account.TotalWalletBalance, 64)
+	result.AvailableBalance, _ = strconv.ParseFloat(account.AvailableBalance, 64)
+	result.TotalUnrealizedProfit, _ = strconv.ParseFloat(account.TotalUnrealizedProfit, 64)
 
 	log.Printf("✓ 币安API返回: 总余额=%s, 可用=%s, 未实现盈亏=%s",
 		account.TotalWalletBalance,
@@ -162,7 +167,7 @@ func (t *FuturesTrader) GetBalance() (map[string]interface{}, error) {
 }
 
 // GetPositions 获取所有持仓（带缓存）
-func (t *FuturesTrader) GetPositions() ([]map[string]interface{}, error) {
+func (t *FuturesTrader) GetPositions() ([]Position, error) {
 	// 先检查缓存是否有效
 	t.positionsCacheMutex.RLock()
 	if t.cachedPositions != nil && time.Since(t.positionsCacheTime) < t.cacheDuration {
@@ -180,30 +185,27 @@ func (t *FuturesTrader) GetPositions() ([]map[string]interface{}, error) {
 		return nil, fmt.Errorf("获取持仓失败: %w", err)
 	}
 
-	var result []map[string]interface{}
+	var result []Position
 	for _, pos := range positions {
 		posAmt, _ := strconv.ParseFloat(pos.PositionAmt, 64)
 		if posAmt == 0 {
 			continue // 跳过无持仓的
 		}
 
-		posMap := make(map[string]interface{})
-		posMap["symbol"] = pos.Symbol
-		posMap["positionAmt"], _ = strconv.ParseFloat(pos.PositionAmt, 64)
-		posMap["entryPrice"], _ = strconv.ParseFloat(pos.EntryPrice, 64)
-		posMap["markPrice"], _ = strconv.ParseFloat(pos.MarkPrice, 64)
-		posMap["unRealizedProfit"], _ = strconv.ParseFloat(pos.UnRealizedProfit, 64)
-		posMap["leverage"], _ = strconv.ParseFloat(pos.Leverage, 64)
-		posMap["liquidationPrice"], _ = strconv.ParseFloat(pos.LiquidationPrice, 64)
-
-		// 判断方向
-		if posAmt > 0 {
-			posMap["side"] = "long"
-		} else {
-			posMap["side"] = "short"
+		position := Position{Symbol: pos.Symbol, Side: "long", Quantity: posAmt}
+		// 判断方向（空仓数量为负，转为正数）
+		if posAmt < 0 {
+			position.Side = "short"
+			position.Quantity = -posAmt
 		}
+		position.EntryPrice, _ = strconv.ParseFloat(pos.EntryPrice, 64)
+		position.MarkPrice, _ = strconv.ParseFloat(pos.MarkPrice, 64)
+		position.UnrealizedPnL, _ = strconv.ParseFloat(pos.UnRealizedProfit, 64)
+		position.LiquidationPrice, _ = strconv.ParseFloat(pos.LiquidationPrice, 64)
+		leverage, _ := strconv.ParseFloat(pos.Leverage, 64)
+		position.Leverage = int(leverage)
 
-		result = append(result, posMap)
+		result = append(result, position)
 	}
 
 	// 更新缓存
@@ -273,11 +275,9 @@ func (t *FuturesTrader) SetLeverage(symbol string, leverage int) error {
 	positions, err := t.GetPositions()
 	if err == nil {
 		for _, pos := range positions {
-			if pos["symbol"] == symbol {
-				if lev, ok := pos["leverage"].(float64); ok {
-					currentLeverage = int(lev)
-					break
-				}
+			if pos.Symbol == symbol && pos.Leverage > 0 {
+				currentLeverage = pos.Leverage
+				break
 			}
 		}
 	}
@@ -313,7 +313,7 @@ func (t *FuturesTrader) SetLeverage(symbol string, leverage int) error {
 }
 
 // OpenLong 开多仓
-func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error) {
 	// 先取消该币种的所有委托单（清理旧的止损止盈单）
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消旧委托单失败（可能没有委托单）: %v", err)
@@ -360,15 +360,11 @@ func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int)
 	log.Printf("✓ 开多仓成功: %s 数量: %s", symbol, quantityStr)
 	log.Printf("  订单ID: %d", order.OrderID)
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return newOrderResult(order), nil
 }
 
 // OpenShort 开空仓
-func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
 	// 先取消该币种的所有委托单（清理旧的止损止盈单）
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消旧委托单失败（可能没有委托单）: %v", err)
@@ -415,15 +411,72 @@ func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int)
 	log.Printf("✓ 开空仓成功: %s 数量: %s", symbol, quantityStr)
 	log.Printf("  订单ID: %d", order.OrderID)
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return newOrderResult(order), nil
+}
+
+// OpenLongLimit 限价开多仓
+func (t *FuturesTrader) OpenLongLimit(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	return t.openLimit(symbol, futures.SideTypeBuy, futures.PositionSideTypeLong, quantity, leverage, price, postOnly)
+}
+
+// OpenShortLimit 限价开空仓
+func (t *FuturesTrader) OpenShortLimit(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	return t.openLimit(symbol, futures.SideTypeSell, futures.PositionSideTypeShort, quantity, leverage, price, postOnly)
+}
+
+// openLimit 下限价开仓单（post-only 使用 GTX，会立即成交时交易所直接拒单）
+// 注意：不取消已有委托，避免撤掉同币种其他方向的止盈止损单
+func (t *FuturesTrader) openLimit(symbol string, side futures.SideType, positionSide futures.PositionSideType,
+	quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	if err := t.SetLeverage(symbol, leverage); err != nil {
+		return nil, err
+	}
+
+	quantityStr, err := t.FormatQuantity(symbol, quantity)
+	if err != nil {
+		return nil, err
+	}
+	quantityFloat, parseErr := strconv.ParseFloat(quantityStr, 64)
+	if parseErr != nil || quantityFloat <= 0 {
+		return nil, fmt.Errorf("开仓数量过小，格式化后为 0 (原始: %.8f → 格式化: %s)。建议增加开仓金额或选择价格更低的币种", quantity, quantityStr)
+	}
+	if err := t.CheckMinNotional(symbol, quantityFloat); err != nil {
+		return nil, err
+	}
+
+	priceStr, err := t.FormatPrice(symbol, price)
+	if err != nil {
+		return nil, err
+	}
+
+	timeInForce := futures.TimeInForceTypeGTC
+	if postOnly {
+		timeInForce = futures.TimeInForceTypeGTX
+	}
+
+	order, err := t.client.NewCreateOrderService().
+		Symbol(symbol).
+		Side(side).
+		PositionSide(positionSide).
+		Type(futures.OrderTypeLimit).
+		TimeInForce(timeInForce).
+		Price(priceStr).
+		Quantity(quantityStr).
+		NewClientOrderID(getBrOrderID()).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("限价开仓失败: %w", err)
+	}
+
+	log.Printf("✓ 限价开仓单已提交: %s %s 数量: %s 价格: %s (%s) 状态: %s",
+		symbol, positionSide, quantityStr, priceStr, timeInForce, order.Status)
+	log.Printf("  订单ID: %d", order.OrderID)
+
+	return newOrderResult(order), nil
 }
 
 // CloseLong 平多仓
-func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -432,8 +485,8 @@ func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]i
 		}
 
 		for _, pos := range positions {
-			if pos["symbol"] == symbol && pos["side"] == "long" {
-				quantity = pos["positionAmt"].(float64)
+			if pos.Symbol == symbol && pos.Side == "long" {
+				quantity = pos.Quantity
 				break
 			}
 		}
@@ -470,15 +523,11 @@ func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]i
 		log.Printf("  ⚠ 取消挂单失败: %v", err)
 	}
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return newOrderResult(order), nil
 }
 
 // CloseShort 平空仓
-func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -487,8 +536,8 @@ func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]
 		}
 
 		for _, pos := range positions {
-			if pos["symbol"] == symbol && pos["side"] == "short" {
-				quantity = -pos["positionAmt"].(float64) // 空仓数量是负的，取绝对值
+			if pos.Symbol == symbol && pos.Side == "short" {
+				quantity = pos.Quantity
 				break
 			}
 		}
@@ -525,11 +574,20 @@ func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]
 		log.Printf("  ⚠ 取消挂单失败: %v", err)
 	}
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return newOrderResult(order), nil
+}
+
+// newOrderResult 转换下单返回（市价单返回时可能尚未给出成交均价）
+func newOrderResult(order *futures.CreateOrderResponse) *OrderResult {
+	avgPrice, _ := strconv.ParseFloat(order.AvgPrice, 64)
+	executedQty, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	return &OrderResult{
+		OrderID:     order.OrderID,
+		Symbol:      order.Symbol,
+		Status:      string(order.Status),
+		AvgPrice:    avgPrice,
+		ExecutedQty: executedQty,
+	}
 }
 
 // CancelStopLossOrders 仅取消止损单（不影响止盈单）
@@ -646,6 +704,24 @@ func (t *FuturesTrader) CancelAllOrders(symbol string) error {
 	return nil
 }
 
+// CancelOrder 按订单ID取消单个挂单
+func (t *FuturesTrader) CancelOrder(symbol string, orderID string) error {
+	id, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("无效的订单ID %s: %w", orderID, err)
+	}
+
+	if _, err := t.client.NewCancelOrderService().
+		Symbol(symbol).
+		OrderID(id).
+		Do(context.Background()); err != nil {
+		return fmt.Errorf("取消订单 %s 失败: %w", orderID, err)
+	}
+
+	log.Printf("  ✓ 已取消订单 %s (%s)", orderID, symbol)
+	return nil
+}
+
 // CancelStopOrders 取消该币种的止盈/止损单（用于调整止盈止损位置）
 func (t *FuturesTrader) CancelStopOrders(symbol string) error {
 	// 获取该币种的所有未完成订单
@@ -796,6 +872,140 @@ func (t *FuturesTrader) SetTakeProfit(symbol string, positionSide string, quanti
 	return nil
 }
 
+// GetFills 获取指定币种 since 之后的成交（币安要求指定币种，单次查询最多回溯7天）
+func (t *FuturesTrader) GetFills(symbol string, since time.Time) ([]Fill, error) {
+	if symbol == "" {
+		return nil, fmt.Errorf("币安查询成交必须指定币种")
+	}
+	if earliest := time.Now().Add(-7 * 24 * time.Hour); since.Before(earliest) {
+		since = earliest
+	}
+
+	trades, err := t.client.NewListAccountTradeService().
+		Symbol(symbol).
+		StartTime(since.UnixMilli()).
+		Limit(1000).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("获取成交历史失败: %w", err)
+	}
+
+	fills := make([]Fill, 0, len(trades))
+	for _, trade := range trades {
+		price, _ := strconv.ParseFloat(trade.Price, 64)
+		quantity, _ := strconv.ParseFloat(trade.Quantity, 64)
+		fee, _ := strconv.ParseFloat(trade.Commission, 64)
+		realized, _ := strconv.ParseFloat(trade.RealizedPnl, 64)
+		positionSide := string(trade.PositionSide)
+		side := string(trade.Side)
+
+		fills = append(fills, Fill{
+			TradeID:      strconv.FormatInt(trade.ID, 10),
+			OrderID:      strconv.FormatInt(trade.OrderID, 10),
+			Symbol:       trade.Symbol,
+			Side:         side,
+			PositionSide: positionSide,
+			OrderType:    t.getOrderType(trade.Symbol, trade.OrderID),
+			Price:        price,
+			Quantity:     quantity,
+			Fee:          fee,
+			RealizedPnL:  realized,
+			ReduceOnly:   (positionSide == "LONG" && side == "SELL") || (positionSide == "SHORT" && side == "BUY"),
+			Time:         time.UnixMilli(trade.Time),
+		})
+	}
+	return fills, nil
+}
+
+// GetIncome 获取 since 之后的资金流水（已实现盈亏、资金费、手续费等）
+func (t *FuturesTrader) GetIncome(symbol string, since time.Time) ([]Income, error) {
+	service := t.client.NewGetIncomeHistoryService().
+		StartTime(since.UnixMilli()).
+		Limit(1000)
+	if symbol != "" {
+		service = service.Symbol(symbol)
+	}
+	records, err := service.Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("获取资金流水失败: %w", err)
+	}
+
+	incomes := make([]Income, 0, len(records))
+	for _, record := range records {
+		amount, _ := strconv.ParseFloat(record.Income, 64)
+		incomes = append(incomes, Income{
+			Symbol:  record.Symbol,
+			Type:    record.IncomeType,
+			Amount:  amount,
+			Asset:   record.Asset,
+			TranID:  strconv.FormatInt(record.TranID, 10),
+			TradeID: record.TradeID,
+			Time:    time.UnixMilli(record.Time),
+		})
+	}
+	return incomes, nil
+}
+
+// GetOpenOrders 获取当前挂单（包括止盈止损条件单）
+func (t *FuturesTrader) GetOpenOrders(symbol string) ([]OpenOrder, error) {
+	service := t.client.NewListOpenOrdersService()
+	if symbol != "" {
+		service = service.Symbol(symbol)
+	}
+	orders, err := service.Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("获取挂单失败: %w", err)
+	}
+
+	result := make([]OpenOrder, 0, len(orders))
+	for _, order := range orders {
+		price, _ := strconv.ParseFloat(order.Price, 64)
+		stopPrice, _ := strconv.ParseFloat(order.StopPrice, 64)
+		quantity, _ := strconv.ParseFloat(order.OrigQuantity, 64)
+		filled, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+		result = append(result, OpenOrder{
+			OrderID:      strconv.FormatInt(order.OrderID, 10),
+			Symbol:       order.Symbol,
+			Side:         string(order.Side),
+			PositionSide: string(order.PositionSide),
+			Type:         string(order.Type),
+			Price:        price,
+			StopPrice:    stopPrice,
+			Quantity:     quantity,
+			FilledQty:    filled,
+			ReduceOnly:   order.ReduceOnly || order.ClosePosition,
+			Time:         time.UnixMilli(order.Time),
+		})
+	}
+	return result, nil
+}
+
+// getOrderType 查询订单原始类型（STOP_MARKET / TAKE_PROFIT_MARKET 等），查询失败时返回空
+func (t *FuturesTrader) getOrderType(symbol string, orderID int64) string {
+	t.orderTypeMutex.Lock()
+	defer t.orderTypeMutex.Unlock()
+
+	if orderType, ok := t.orderTypeCache[orderID]; ok {
+		return orderType
+	}
+
+	order, err := t.client.NewGetOrderService().Symbol(symbol).OrderID(orderID).Do(context.Background())
+	if err != nil {
+		log.Printf("  ⚠ 查询订单 %d 类型失败: %v", orderID, err)
+		return ""
+	}
+	orderType := string(order.OrigType)
+	if orderType == "" {
+		orderType = string(order.Type)
+	}
+
+	if t.orderTypeCache == nil {
+		t.orderTypeCache = make(map[int64]string)
+	}
+	t.orderTypeCache[orderID] = orderType
+	return orderType
+}
+
 // GetMinNotional 获取最小名义价值（Binance要求）
 func (t *FuturesTrader) GetMinNotional(symbol string) float64 {
 	// 使用保守的默认值 10 USDT，确保订单能够通过交易所验证
@@ -847,6 +1057,34 @@ func (t *FuturesTrader) GetSymbolPrecision(symbol string) (int, error) {
 	return 3, nil // 默认精度为3
 }
 
+// FormatPrice 按交易对 tickSize 格式化价格
+func (t *FuturesTrader) FormatPrice(symbol string, price float64) (string, error) {
+	exchangeInfo, err := t.client.NewExchangeInfoService().Do(context.Background())
+	if err != nil {
+		return "", fmt.Errorf("获取交易规则失败: %w", err)
+	}
+
+	for _, s := range exchangeInfo.Symbols {
+		if s.Symbol != symbol {
+			continue
+		}
+		for _, filter := range s.Filters {
+			if filter["filterType"] == "PRICE_FILTER" {
+				tickSize, _ := filter["tickSize"].(string)
+				tick, _ := strconv.ParseFloat(tickSize, 64)
+				if tick <= 0 {
+					break
+				}
+				rounded := math.Round(price/tick) * tick
+				return strconv.FormatFloat(rounded, 'f', calculatePrecision(tickSize), 64), nil
+			}
+		}
+	}
+
+	log.Printf("  ⚠ %s 未找到价格精度信息，按原始价格提交", symbol)
+	return strconv.FormatFloat(price, 'f', -1, 64), nil
+}
+
 // calculatePrecision 从stepSize计算精度
 func calculatePrecision(stepSize string) int {
 	// 去除尾部的0
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -180,15 +181,20 @@ func NewBinanceFuturesTestSuite(t *testing.T) *BinanceFuturesTestSuite {
 			if symbol == "" {
 				symbol = "BTCUSDT"
 			}
+			// 限价单挂单未成交，其余订单视为立即成交
+			status, executedQty := "FILLED", r.FormValue("quantity")
+			if r.FormValue("type") == "LIMIT" {
+				status, executedQty = "NEW", "0"
+			}
 			respBody = map[string]interface{}{
 				"orderId":       123456,
 				"symbol":        symbol,
-				"status":        "FILLED",
+				"status":        status,
 				"clientOrderId": r.FormValue("newClientOrderId"),
 				"price":         r.FormValue("price"),
 				"avgPrice":      r.FormValue("price"),
 				"origQty":       r.FormValue("quantity"),
-				"executedQty":   r.FormValue("quantity"),
+				"executedQty":   executedQty,
 				"cumQty":        r.FormValue("quantity"),
 				"cumQuote":      "1000.00",
 				"timeInForce":   r.FormValue("timeInForce"),
@@ -208,9 +214,48 @@ func NewBinanceFuturesTestSuite(t *testing.T) *BinanceFuturesTestSuite {
 				"status":  "CANCELED",
 			}
 
+		// Mock GetOrder - /fapi/v1/order (GET)
+		case path == "/fapi/v1/order" && r.Method == "GET":
+			orderID, _ := strconv.ParseInt(r.URL.Query().Get("orderId"), 10, 64)
+			orderType := "MARKET"
+			if orderID == 2002 {
+				orderType = "STOP_MARKET"
+			}
+			respBody = map[string]interface{}{
+				"orderId":  orderID,
+				"symbol":   r.URL.Query().Get("symbol"),
+				"status":   "FILLED",
+				"type":     "MARKET",
+				"origType": orderType,
+			}
+
+		// Mock ListAccountTrades - /fapi/v1/userTrades
+		case path == "/fapi/v1/userTrades":
+			respBody = []map[string]interface{}{
+				{"id": 1, "orderId": 2001, "symbol": "BTCUSDT", "side": "BUY", "positionSide": "LONG",
+					"price": "50000", "qty": "0.1", "commission": "2", "realizedPnl": "0", "time": 1700000000000},
+				{"id": 2, "orderId": 2002, "symbol": "BTCUSDT", "side": "SELL", "positionSide": "LONG",
+					"price": "49000", "qty": "0.1", "commission": "1.96", "realizedPnl": "-100", "time": 1700000060000},
+			}
+
 		// Mock ListOpenOrders - /fapi/v1/openOrders
 		case path == "/fapi/v1/openOrders":
-			respBody = []map[string]interface{}{}
+			respBody = []map[string]interface{}{
+				{"orderId": 3001, "symbol": "BTCUSDT", "side": "SELL", "positionSide": "LONG", "type": "STOP_MARKET",
+					"origType": "STOP_MARKET", "price": "0", "stopPrice": "48000", "origQty": "0.1", "executedQty": "0",
+					"reduceOnly": true, "status": "NEW", "time": 1700000000000},
+			}
+
+		// Mock GetIncomeHistory - /fapi/v1/income
+		case path == "/fapi/v1/income":
+			respBody = []map[string]interface{}{
+				{"symbol": "BTCUSDT", "incomeType": "COMMISSION", "income": "-2", "asset": "USDT",
+					"tranId": 9001, "tradeId": "1", "time": 1700000000000},
+				{"symbol": "BTCUSDT", "incomeType": "FUNDING_FEE", "income": "-0.35", "asset": "USDT",
+					"tranId": 9002, "tradeId": "", "time": 1700000030000},
+				{"symbol": "BTCUSDT", "incomeType": "REALIZED_PNL", "income": "-100", "asset": "USDT",
+					"tranId": 9003, "tradeId": "2", "time": 1700000060000},
+			}
 
 		// Mock CancelAllOrders - /fapi/v1/allOpenOrders (DELETE)
 		case path == "/fapi/v1/allOpenOrders" && r.Method == "DELETE":
@@ -418,3 +463,52 @@ func TestGetBrOrderID(t *testing.T) {
 		ids[id] = true
 	}
 }
+
+// TestFuturesTrader_GetFills 测试成交历史解析与订单类型识别
+func TestFuturesTrader_GetFills(t *testing.T) {
+	suite := NewBinanceFuturesTestSuite(t)
+	defer suite.Cleanup()
+
+	trader := suite.Trader.(*FuturesTrader)
+	fills, err := trader.GetFills("BTCUSDT", time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, fills, 2)
+
+	assert.Equal(t, "1", fills[0].TradeID)
+	assert.Equal(t, "2001", fills[0].OrderID)
+	assert.Equal(t, "MARKET", fills[0].OrderType)
+	assert.False(t, fills[0].ReduceOnly)
+	assert.Equal(t, 2.0, fills[0].Fee)
+
+	assert.Equal(t, "STOP_MARKET", fills[1].OrderType)
+	assert.True(t, fills[1].ReduceOnly, "多仓卖出为平仓成交")
+	assert.Equal(t, -100.0, fills[1].RealizedPnL)
+	assert.Equal(t, time.UnixMilli(1700000060000), fills[1].Time)
+}
+
+// TestFuturesTrader_GetIncomeAndOpenOrders 测试资金流水与挂单查询
+func TestFuturesTrader_GetIncomeAndOpenOrders(t *testing.T) {
+	suite := NewBinanceFuturesTestSuite(t)
+	defer suite.Cleanup()
+
+	trader := suite.Trader.(*FuturesTrader)
+	incomes, err := trader.GetIncome("BTCUSDT", time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, incomes, 3)
+	assert.Equal(t, IncomeCommission, incomes[0].Type)
+	assert.Equal(t, -2.0, incomes[0].Amount)
+	assert.Equal(t, "9001", incomes[0].TranID)
+	assert.Equal(t, IncomeFundingFee, incomes[1].Type)
+	assert.Equal(t, -0.35, incomes[1].Amount)
+	assert.Equal(t, IncomeRealizedPnL, incomes[2].Type)
+	assert.Equal(t, "2", incomes[2].TradeID)
+
+	orders, err := trader.GetOpenOrders("BTCUSDT")
+	assert.NoError(t, err)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, "3001", orders[0].OrderID)
+	assert.Equal(t, "STOP_MARKET", orders[0].Type)
+	assert.Equal(t, 48000.0, orders[0].StopPrice)
+	assert.Equal(t, 0.1, orders[0].Quantity)
+	assert.True(t, orders[0].ReduceOnly)
+}
@@ -0,0 +1,498 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/decision"
+	"nofx/market"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 决策周期触发类型
+const (
+	TriggerScheduled     = "scheduled"             // 定时扫描
+	TriggerPriceMove     = "price_move"            // N分钟内价格异动
+	TriggerVolumeSpike   = "volume_spike"          // 成交量突增（市场警报）
+	TriggerMarketAlert   = "market_alert"          // 其他市场警报（AlertWakeup）
+	TriggerStopProximity = "stop_proximity"        // 持仓接近止损价
+	TriggerLiquidation   = "liquidation_proximity" // 持仓接近强平价
+	TriggerFundingFlip   = "funding_flip"          // 资金费率正负翻转
+)
+
+// DefaultTriggerMinSpacing 事件提前触发距上次周期开始的默认最小间隔
+const DefaultTriggerMinSpacing = 3 * time.Minute
+
+// defaultTriggerCheckInterval 持仓距离与资金费率的默认检查间隔
+const defaultTriggerCheckInterval = time.Minute
+
+// defaultPriceMoveWindow 价格异动的默认统计窗口
+const defaultPriceMoveWindow = 15 * time.Minute
+
+// triggerCooldown 同一事件（类型+币种）提前触发周期的最小间隔，避免持续状态反复触发
+const triggerCooldown = 15 * time.Minute
+
+// TriggerConfig 事件触发决策周期配置（按交易员存储为JSON，为空时仅定时扫描）
+// 启用后可以适当调大扫描间隔，由事件负责捕捉两次扫描之间的行情
+type TriggerConfig struct {
+	PriceMovePct           float64 `json:"price_move_pct,omitempty"`           // 价格在窗口内变动超过该百分比时触发（0表示关闭）
+	PriceMoveMinutes       int     `json:"price_move_minutes,omitempty"`       // 价格变动窗口（分钟），0表示默认15分钟
+	VolumeSpike            bool    `json:"volume_spike,omitempty"`             // 候选或持仓币种成交量突增时触发
+	StopDistancePct        float64 `json:"stop_distance_pct,omitempty"`        // 标记价距止损价不足该百分比时触发（0表示关闭）
+	LiquidationDistancePct float64 `json:"liquidation_distance_pct,omitempty"` // 标记价距强平价不足该百分比时触发（0表示关闭）
+	FundingFlip            bool    `json:"funding_flip,omitempty"`             // 候选或持仓币种资金费率正负翻转时触发
+	CheckIntervalSeconds   int     `json:"check_interval_seconds,omitempty"`   // 持仓距离与资金费率检查间隔（秒），0表示默认60秒
+	MinSpacingMinutes      int     `json:"min_spacing_minutes,omitempty"`      // 提前触发距上次周期的最小间隔（分钟），0表示默认3分钟
+	MaxCallsPerHour        int     `json:"max_calls_per_hour,omitempty"`       // 每小时AI调用预算（含定时周期，用完后不再提前触发），0表示不限
+}
+
+// ParseTriggerConfig 解析事件触发配置JSON，空字符串返回空配置（仅定时扫描）
+func ParseTriggerConfig(raw string) (TriggerConfig, error) {
+	var cfg TriggerConfig
+	if strings.TrimSpace(raw) == "" {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return TriggerConfig{}, fmt.Errorf("解析事件触发配置失败: %w", err)
+	}
+	if cfg.PriceMovePct < 0 || cfg.StopDistancePct < 0 || cfg.LiquidationDistancePct < 0 {
+		return TriggerConfig{}, fmt.Errorf("事件触发阈值不能为负数")
+	}
+	if cfg.PriceMoveMinutes < 0 || cfg.CheckIntervalSeconds < 0 || cfg.MinSpacingMinutes < 0 || cfg.MaxCallsPerHour < 0 {
+		return TriggerConfig{}, fmt.Errorf("事件触发间隔与调用预算不能为负数")
+	}
+	return cfg, nil
+}
+
+// Enabled 是否启用了任一触发源
+func (c TriggerConfig) Enabled() bool {
+	return c.PriceMovePct > 0 || c.VolumeSpike || c.StopDistancePct > 0 || c.LiquidationDistancePct > 0 || c.FundingFlip
+}
+
+// MinSpacing 返回提前触发的最小间隔
+func (c TriggerConfig) MinSpacing() time.Duration {
+	if c.MinSpacingMinutes <= 0 {
+		return DefaultTriggerMinSpacing
+	}
+	return time.Duration(c.MinSpacingMinutes) * time.Minute
+}
+
+// CheckInterval 返回持仓距离与资金费率的检查间隔
+func (c TriggerConfig) CheckInterval() time.Duration {
+	if c.CheckIntervalSeconds <= 0 {
+		return defaultTriggerCheckInterval
+	}
+	return time.Duration(c.CheckIntervalSeconds) * time.Second
+}
+
+// PriceMoveWindow 返回价格异动的统计窗口
+func (c TriggerConfig) PriceMoveWindow() time.Duration {
+	if c.PriceMoveMinutes <= 0 {
+		return defaultPriceMoveWindow
+	}
+	return time.Duration(c.PriceMoveMinutes) * time.Minute
+}
+
+// CycleTrigger 提前触发决策周期的事件
+type CycleTrigger struct {
+	Type   string
+	Symbol string
+	Reason string
+	Time   time.Time
+}
+
+// key 事件冷却使用的唯一标识
+func (t CycleTrigger) key() string {
+	return t.Type + "|" + t.Symbol
+}
+
+// info 转换为决策上下文中的触发信息
+func (t *CycleTrigger) info() *decision.TriggerInfo {
+	if t == nil {
+		return nil
+	}
+	return &decision.TriggerInfo{Type: t.Type, Symbol: t.Symbol, Reason: t.Reason}
+}
+
+// triggerState 事件触发的运行状态
+type triggerState struct {
+	mu           sync.Mutex
+	lastFired    map[string]time.Time            // type|symbol -> 上次提前触发周期的时间
+	cycleTimes   []time.Time                     // 最近一小时的决策周期开始时间（AI调用预算）
+	prices       map[string][]market.PriceUpdate // 价格异动窗口内的价格 (symbol -> 推送)
+	fundingSigns map[string]int                  // 上次观察到的资金费率符号 (symbol -> -1/0/1)
+}
+
+// startCycleTriggers 启动已启用的触发源，返回事件通道（未启用任何触发源时为nil通道，select 中永不就绪）
+func (at *AutoTrader) startCycleTriggers() <-chan CycleTrigger {
+	cfg := at.config.Triggers
+	if !cfg.Enabled() && !at.config.AlertWakeup {
+		return nil
+	}
+	ch := make(chan CycleTrigger, 64)
+
+	alerts, unsubscribeAlerts := at.subscribeAlerts()
+	if alerts != nil {
+		at.startTriggerSource(func() { at.runAlertTriggers(alerts, unsubscribeAlerts, ch) })
+	}
+
+	if cfg.PriceMovePct > 0 {
+		if stream := at.resolvePriceStream(); stream != nil {
+			at.startTriggerSource(func() { at.runPriceTriggers(stream, ch) })
+		} else {
+			log.Printf("⚠️ [%s] 没有可用的实时价格源，价格异动触发未启用", at.name)
+		}
+	}
+
+	if cfg.StopDistancePct > 0 || cfg.LiquidationDistancePct > 0 || cfg.FundingFlip {
+		at.startTriggerSource(func() { at.runPeriodicTriggers(cfg.CheckInterval(), ch) })
+	}
+
+	log.Printf("⚡ [%s] 事件触发已启用（最小间隔 %v，每小时AI调用预算 %d，0表示不限）",
+		at.name, cfg.MinSpacing(), cfg.MaxCallsPerHour)
+	return ch
+}
+
+// startTriggerSource 在后台运行触发源，停止交易时等待其退出
+func (at *AutoTrader) startTriggerSource(run func()) {
+	at.monitorWg.Add(1)
+	go func() {
+		defer at.monitorWg.Done()
+		run()
+	}()
+}
+
+// sendTrigger 投递事件（通道满时丢弃，不阻塞触发源）
+func sendTrigger(ch chan<- CycleTrigger, trigger CycleTrigger) {
+	select {
+	case ch <- trigger:
+	default:
+	}
+}
+
+// runAlertTriggers 把候选或持仓币种的市场警报转换为触发事件
+func (at *AutoTrader) runAlertTriggers(alerts <-chan market.Alert, unsubscribe func(), ch chan<- CycleTrigger) {
+	defer unsubscribe()
+	for {
+		select {
+		case alert, ok := <-alerts:
+			if !ok {
+				return
+			}
+			if trigger, ok := at.alertTrigger(alert); ok {
+				sendTrigger(ch, trigger)
+			}
+		case <-at.stopMonitorCh:
+			return
+		}
+	}
+}
+
+// alertTrigger 成交量突增对应 volume_spike 触发源，其他警报在启用警报唤醒时触发
+func (at *AutoTrader) alertTrigger(alert market.Alert) (CycleTrigger, bool) {
+	if !at.alertRelevant(alert) {
+		return CycleTrigger{}, false
+	}
+	trigger := CycleTrigger{Symbol: alert.Symbol, Reason: alert.Message, Time: alert.Timestamp}
+	switch {
+	case alert.Type == market.AlertVolumeSpike && at.config.Triggers.VolumeSpike:
+		trigger.Type = TriggerVolumeSpike
+	case at.config.AlertWakeup:
+		trigger.Type = TriggerMarketAlert
+	default:
+		return CycleTrigger{}, false
+	}
+	return trigger, true
+}
+
+// runPriceTriggers 逐笔检查候选与持仓币种的价格异动，并定期确保这些币种已订阅实时行情
+func (at *AutoTrader) runPriceTriggers(stream PriceStream, ch chan<- CycleTrigger) {
+	updates, unsubscribe := stream.SubscribePrices(1024)
+	defer unsubscribe()
+
+	ticker := time.NewTicker(at.config.Triggers.CheckInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				log.Printf("⚠️ [%s] 实时价格流已关闭，价格异动触发停止", at.name)
+				return
+			}
+			if trigger, ok := at.checkPriceMove(update); ok {
+				sendTrigger(ch, trigger)
+			}
+		case <-ticker.C:
+			for _, symbol := range at.watchedSymbolList() {
+				if err := stream.WatchSymbol(symbol); err != nil {
+					log.Printf("⚠️ 价格异动触发：订阅 %s 实时行情失败: %v", symbol, err)
+				}
+			}
+		case <-at.stopMonitorCh:
+			return
+		}
+	}
+}
+
+// checkPriceMove 记录价格并检查窗口内涨跌幅，触发后重置该币种的窗口
+func (at *AutoTrader) checkPriceMove(update market.PriceUpdate) (CycleTrigger, bool) {
+	if update.Price <= 0 || !at.isWatched(update.Symbol) {
+		return CycleTrigger{}, false
+	}
+	if update.Time.IsZero() {
+		update.Time = at.now()
+	}
+	window := at.config.Triggers.PriceMoveWindow()
+
+	at.triggers.mu.Lock()
+	defer at.triggers.mu.Unlock()
+	if at.triggers.prices == nil {
+		at.triggers.prices = make(map[string][]market.PriceUpdate)
+	}
+
+	history := append(at.triggers.prices[update.Symbol], update)
+	start := 0
+	for start < len(history)-1 && update.Time.Sub(history[start].Time) > window {
+		start++
+	}
+	history = history[start:]
+
+	oldest := history[0]
+	changePct := (update.Price/oldest.Price - 1) * 100
+	if math.Abs(changePct) < at.config.Triggers.PriceMovePct {
+		at.triggers.prices[update.Symbol] = history
+		return CycleTrigger{}, false
+	}
+
+	at.triggers.prices[update.Symbol] = []market.PriceUpdate{update}
+	return CycleTrigger{
+		Type:   TriggerPriceMove,
+		Symbol: update.Symbol,
+		Reason: fmt.Sprintf("%s %.0f分钟内价格变动 %+.2f%%（%.4f → %.4f）",
+			update.Symbol, window.Minutes(), changePct, oldest.Price, update.Price),
+		Time: update.Time,
+	}, true
+}
+
+// runPeriodicTriggers 定期检查持仓距止损/强平价的距离与资金费率翻转
+func (at *AutoTrader) runPeriodicTriggers(interval time.Duration, ch chan<- CycleTrigger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, trigger := range at.checkPositionTriggers() {
+				sendTrigger(ch, trigger)
+			}
+			for _, trigger := range at.checkFundingFlips() {
+				sendTrigger(ch, trigger)
+			}
+		case <-at.stopMonitorCh:
+			return
+		}
+	}
+}
+
+// checkPositionTriggers 检查持仓标记价是否接近止损价或强平价
+func (at *AutoTrader) checkPositionTriggers() []CycleTrigger {
+	cfg := at.config.Triggers
+	if cfg.StopDistancePct <= 0 && cfg.LiquidationDistancePct <= 0 {
+		return nil
+	}
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("⚠️ [%s] 事件触发：获取持仓失败: %v", at.name, err)
+		return nil
+	}
+	if len(positions) == 0 {
+		return nil
+	}
+
+	var orders []OpenOrder
+	if cfg.StopDistancePct > 0 {
+		if orders, err = at.trader.GetOpenOrders(""); err != nil {
+			log.Printf("⚠️ [%s] 事件触发：获取挂单失败: %v", at.name, err)
+		}
+	}
+
+	var triggers []CycleTrigger
+	for _, pos := range positions {
+		if pos.MarkPrice <= 0 {
+			continue
+		}
+		if cfg.StopDistancePct > 0 {
+			for _, order := range orders {
+				if !isStopLossFor(order, pos) {
+					continue
+				}
+				distance := math.Abs(pos.MarkPrice-order.StopPrice) / pos.MarkPrice * 100
+				if distance <= cfg.StopDistancePct {
+					triggers = append(triggers, CycleTrigger{
+						Type:   TriggerStopProximity,
+						Symbol: pos.Symbol,
+						Reason: fmt.Sprintf("%s %s 标记价 %.4f 距止损价 %.4f 仅 %.2f%%",
+							pos.Symbol, pos.Side, pos.MarkPrice, order.StopPrice, distance),
+						Time: at.now(),
+					})
+					break
+				}
+			}
+		}
+		if cfg.LiquidationDistancePct > 0 && pos.LiquidationPrice > 0 {
+			distance := math.Abs(pos.MarkPrice-pos.LiquidationPrice) / pos.MarkPrice * 100
+			if distance <= cfg.LiquidationDistancePct {
+				triggers = append(triggers, CycleTrigger{
+					Type:   TriggerLiquidation,
+					Symbol: pos.Symbol,
+					Reason: fmt.Sprintf("%s %s 标记价 %.4f 距强平价 %.4f 仅 %.2f%%",
+						pos.Symbol, pos.Side, pos.MarkPrice, pos.LiquidationPrice, distance),
+					Time: at.now(),
+				})
+			}
+		}
+	}
+	return triggers
+}
+
+// isStopLossFor 挂单是否为该持仓的止损单（单向持仓模式按平仓方向判断）
+func isStopLossFor(order OpenOrder, pos Position) bool {
+	if order.Symbol != pos.Symbol || order.StopPrice <= 0 {
+		return false
+	}
+	if order.Type != "STOP_MARKET" && order.Type != "STOP" {
+		return false
+	}
+	switch order.PositionSide {
+	case "", "BOTH":
+		closeSide := "SELL"
+		if pos.Side == "short" {
+			closeSide = "BUY"
+		}
+		return order.Side == closeSide
+	default:
+		return order.PositionSide == pos.PositionSide()
+	}
+}
+
+// checkFundingFlips 检查候选与持仓币种的资金费率是否正负翻转（首次观察只记录）
+func (at *AutoTrader) checkFundingFlips() []CycleTrigger {
+	if !at.config.Triggers.FundingFlip {
+		return nil
+	}
+	fetch := at.fundingRateFunc
+	if fetch == nil {
+		fetch = at.resolveMarketProvider().GetFundingRate
+	}
+
+	var triggers []CycleTrigger
+	for _, symbol := range at.watchedSymbolList() {
+		rate, err := fetch(symbol)
+		if err != nil {
+			continue
+		}
+		sign := 0
+		if rate > 0 {
+			sign = 1
+		} else if rate < 0 {
+			sign = -1
+		}
+
+		at.triggers.mu.Lock()
+		if at.triggers.fundingSigns == nil {
+			at.triggers.fundingSigns = make(map[string]int)
+		}
+		prev, seen := at.triggers.fundingSigns[symbol]
+		if sign != 0 {
+			at.triggers.fundingSigns[symbol] = sign
+		}
+		at.triggers.mu.Unlock()
+
+		if seen && sign != 0 && sign != prev {
+			direction := "由负转正（多头付费）"
+			if sign < 0 {
+				direction = "由正转负（空头付费）"
+			}
+			triggers = append(triggers, CycleTrigger{
+				Type:   TriggerFundingFlip,
+				Symbol: symbol,
+				Reason: fmt.Sprintf("%s 资金费率%s: %.4f%%", symbol, direction, rate*100),
+				Time:   at.now(),
+			})
+		}
+	}
+	return triggers
+}
+
+// allowTriggeredCycle 检查事件冷却、最小间隔与每小时AI调用预算，允许时记录冷却
+func (at *AutoTrader) allowTriggeredCycle(trigger CycleTrigger) bool {
+	now := at.now()
+	cfg := at.config.Triggers
+
+	at.triggers.mu.Lock()
+	defer at.triggers.mu.Unlock()
+
+	if last, ok := at.triggers.lastFired[trigger.key()]; ok && now.Sub(last) < triggerCooldown {
+		return false
+	}
+	if now.Sub(at.lastCycleAt) < cfg.MinSpacing() {
+		return false
+	}
+	if cfg.MaxCallsPerHour > 0 {
+		at.pruneCycleTimes(now)
+		if len(at.triggers.cycleTimes) >= cfg.MaxCallsPerHour {
+			log.Printf("⏳ [%s] 最近一小时已调用AI %d 次，达到预算上限，忽略事件: %s",
+				at.name, len(at.triggers.cycleTimes), trigger.Reason)
+			return false
+		}
+	}
+
+	if at.triggers.lastFired == nil {
+		at.triggers.lastFired = make(map[string]time.Time)
+	}
+	at.triggers.lastFired[trigger.key()] = now
+	return true
+}
+
+// recordCycleStart 记录决策周期开始时间（用于最小间隔与AI调用预算）
+func (at *AutoTrader) recordCycleStart(now time.Time) {
+	at.lastCycleAt = now
+
+	at.triggers.mu.Lock()
+	defer at.triggers.mu.Unlock()
+	at.triggers.cycleTimes = append(at.triggers.cycleTimes, now)
+	at.pruneCycleTimes(now)
+}
+
+// pruneCycleTimes 丢弃一小时之前的周期记录（调用方持有 triggers.mu）
+func (at *AutoTrader) pruneCycleTimes(now time.Time) {
+	start := 0
+	for start < len(at.triggers.cycleTimes) && now.Sub(at.triggers.cycleTimes[start]) >= time.Hour {
+		start++
+	}
+	at.triggers.cycleTimes = at.triggers.cycleTimes[start:]
+}
+
+// isWatched 币种是否属于上个周期的候选或持仓
+func (at *AutoTrader) isWatched(symbol string) bool {
+	at.watchedMu.RLock()
+	defer at.watchedMu.RUnlock()
+	return at.watchedSymbols[symbol]
+}
+
+// watchedSymbolList 返回上个周期的候选与持仓币种（按字母排序）
+func (at *AutoTrader) watchedSymbolList() []string {
+	at.watchedMu.RLock()
+	symbols := make([]string, 0, len(at.watchedSymbols))
+	for symbol := range at.watchedSymbols {
+		symbols = append(symbols, symbol)
+	}
+	at.watchedMu.RUnlock()
+	sort.Strings(symbols)
+	return symbols
+}
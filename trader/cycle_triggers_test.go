@@ -0,0 +1,188 @@
+package trader
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ordersTrader 在 MockTrader 基础上返回固定挂单
+type ordersTrader struct {
+	*MockTrader
+	orders []OpenOrder
+}
+
+func (o *ordersTrader) GetOpenOrders(symbol string) ([]OpenOrder, error) {
+	return o.orders, nil
+}
+
+func TestParseTriggerConfig(t *testing.T) {
+	cfg, err := ParseTriggerConfig("")
+	require.NoError(t, err)
+	assert.False(t, cfg.Enabled())
+	assert.Equal(t, DefaultTriggerMinSpacing, cfg.MinSpacing())
+	assert.Equal(t, defaultPriceMoveWindow, cfg.PriceMoveWindow())
+
+	cfg, err = ParseTriggerConfig(`{"price_move_pct":2,"price_move_minutes":5,"min_spacing_minutes":10,"max_calls_per_hour":6,"check_interval_seconds":30}`)
+	require.NoError(t, err)
+	assert.True(t, cfg.Enabled())
+	assert.Equal(t, 5*time.Minute, cfg.PriceMoveWindow())
+	assert.Equal(t, 10*time.Minute, cfg.MinSpacing())
+	assert.Equal(t, 30*time.Second, cfg.CheckInterval())
+	assert.Equal(t, 6, cfg.MaxCallsPerHour)
+
+	for _, raw := range []string{`{"price_move_pct":-1}`, `{"max_calls_per_hour":-2}`, `not json`} {
+		_, err := ParseTriggerConfig(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestAutoTrader_CheckPriceMove(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := &AutoTrader{config: AutoTraderConfig{Triggers: TriggerConfig{PriceMovePct: 2, PriceMoveMinutes: 5}}}
+	at.updateWatchedSymbols([]decision.CandidateCoin{{Symbol: "BTCUSDT"}}, nil)
+
+	update := func(symbol string, price float64, offset time.Duration) (CycleTrigger, bool) {
+		return at.checkPriceMove(market.PriceUpdate{Symbol: symbol, Price: price, Time: start.Add(offset)})
+	}
+
+	_, ok := update("BTCUSDT", 100, 0)
+	assert.False(t, ok)
+	_, ok = update("BTCUSDT", 101.5, 2*time.Minute)
+	assert.False(t, ok, "涨幅未达阈值")
+	_, ok = update("DOGEUSDT", 1, 0)
+	assert.False(t, ok, "非候选/持仓币种不记录")
+
+	// 窗口外的旧价格被丢弃：相对 101.5 只涨了 1.5%
+	_, ok = update("BTCUSDT", 103, 6*time.Minute)
+	assert.False(t, ok)
+
+	trigger, ok := update("BTCUSDT", 99, 7*time.Minute)
+	require.True(t, ok)
+	assert.Equal(t, TriggerPriceMove, trigger.Type)
+	assert.Equal(t, "BTCUSDT", trigger.Symbol)
+	assert.Contains(t, trigger.Reason, "-2.46%")
+
+	// 触发后窗口重置，不会重复触发
+	_, ok = update("BTCUSDT", 99.1, 8*time.Minute)
+	assert.False(t, ok)
+}
+
+func TestAutoTrader_CheckPositionTriggers(t *testing.T) {
+	mock := &ordersTrader{
+		MockTrader: &MockTrader{positions: []Position{
+			{Symbol: "BTCUSDT", Side: "long", MarkPrice: 100, LiquidationPrice: 80},
+			{Symbol: "ETHUSDT", Side: "short", MarkPrice: 100, LiquidationPrice: 104},
+		}},
+		orders: []OpenOrder{
+			{Symbol: "BTCUSDT", Side: "SELL", PositionSide: "LONG", Type: "STOP_MARKET", StopPrice: 99.5},
+			{Symbol: "BTCUSDT", Side: "SELL", PositionSide: "LONG", Type: "TAKE_PROFIT_MARKET", StopPrice: 100.2},
+			{Symbol: "ETHUSDT", Side: "BUY", PositionSide: "BOTH", Type: "STOP_MARKET", StopPrice: 110},
+		},
+	}
+	at := &AutoTrader{trader: mock, config: AutoTraderConfig{Triggers: TriggerConfig{StopDistancePct: 1, LiquidationDistancePct: 5}}}
+
+	triggers := at.checkPositionTriggers()
+	require.Len(t, triggers, 2)
+	assert.Equal(t, TriggerStopProximity, triggers[0].Type)
+	assert.Equal(t, "BTCUSDT", triggers[0].Symbol)
+	assert.Contains(t, triggers[0].Reason, "止损价 99.5000")
+	assert.Equal(t, TriggerLiquidation, triggers[1].Type)
+	assert.Equal(t, "ETHUSDT", triggers[1].Symbol)
+
+	mock.shouldFailPositions = true
+	assert.Empty(t, at.checkPositionTriggers())
+}
+
+func TestAutoTrader_CheckFundingFlips(t *testing.T) {
+	rates := map[string]float64{"BTCUSDT": 0.0001, "ETHUSDT": -0.0002}
+	at := &AutoTrader{
+		config: AutoTraderConfig{Triggers: TriggerConfig{FundingFlip: true}},
+		fundingRateFunc: func(symbol string) (float64, error) {
+			rate, ok := rates[symbol]
+			if !ok {
+				return 0, errors.New("unknown symbol")
+			}
+			return rate, nil
+		},
+	}
+	at.updateWatchedSymbols([]decision.CandidateCoin{{Symbol: "BTCUSDT"}, {Symbol: "SOLUSDT"}}, []decision.PositionInfo{{Symbol: "ETHUSDT"}})
+
+	assert.Empty(t, at.checkFundingFlips(), "首次观察只记录符号")
+
+	rates["BTCUSDT"] = -0.0003
+	rates["ETHUSDT"] = 0 // 归零不算翻转
+	triggers := at.checkFundingFlips()
+	require.Len(t, triggers, 1)
+	assert.Equal(t, TriggerFundingFlip, triggers[0].Type)
+	assert.Equal(t, "BTCUSDT", triggers[0].Symbol)
+	assert.Contains(t, triggers[0].Reason, "由正转负")
+
+	// 负 → 0 → 正 仍视为翻转
+	rates["ETHUSDT"] = 0.0001
+	triggers = at.checkFundingFlips()
+	require.Len(t, triggers, 1)
+	assert.Equal(t, "ETHUSDT", triggers[0].Symbol)
+	assert.Contains(t, triggers[0].Reason, "由负转正")
+	assert.Empty(t, at.checkFundingFlips())
+}
+
+func TestAutoTrader_AllowTriggeredCycle(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := &AutoTrader{
+		clock:  func() time.Time { return now },
+		config: AutoTraderConfig{Triggers: TriggerConfig{MinSpacingMinutes: 5, MaxCallsPerHour: 3}},
+	}
+	btc := CycleTrigger{Type: TriggerPriceMove, Symbol: "BTCUSDT"}
+	eth := CycleTrigger{Type: TriggerPriceMove, Symbol: "ETHUSDT"}
+
+	at.recordCycleStart(now.Add(-2 * time.Minute))
+	assert.False(t, at.allowTriggeredCycle(btc), "距上次周期不足最小间隔")
+
+	now = now.Add(4 * time.Minute)
+	assert.True(t, at.allowTriggeredCycle(btc))
+	at.recordCycleStart(now)
+
+	now = now.Add(6 * time.Minute)
+	assert.False(t, at.allowTriggeredCycle(btc), "同一事件处于冷却期")
+	assert.True(t, at.allowTriggeredCycle(eth))
+	at.recordCycleStart(now)
+
+	// 最近一小时已有3次周期，预算用完
+	now = now.Add(10 * time.Minute)
+	assert.False(t, at.allowTriggeredCycle(CycleTrigger{Type: TriggerFundingFlip, Symbol: "SOLUSDT"}))
+
+	// 一小时前的周期不再计入预算
+	now = now.Add(40 * time.Minute)
+	assert.True(t, at.allowTriggeredCycle(CycleTrigger{Type: TriggerFundingFlip, Symbol: "SOLUSDT"}))
+}
+
+func TestAutoTrader_TriggeredCycleRecordsTrigger(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	decisionLogger := logger.NewDecisionLogger(t.TempDir())
+	at := &AutoTrader{
+		name:           "trigger-test",
+		decisionLogger: decisionLogger,
+		clock:          func() time.Time { return now },
+		stopUntil:      now.Add(time.Hour), // 风控暂停中：周期直接记录后返回，不调用AI
+	}
+
+	require.NoError(t, at.runTriggeredCycle(&CycleTrigger{Type: TriggerStopProximity, Symbol: "BTCUSDT", Reason: "BTCUSDT long 接近止损"}))
+	require.NoError(t, at.runCycle())
+
+	records, err := decisionLogger.GetLatestRecords(2)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, TriggerStopProximity, records[0].Trigger)
+	assert.Equal(t, "BTCUSDT long 接近止损", records[0].TriggerReason)
+	assert.Equal(t, TriggerScheduled, records[1].Trigger)
+	assert.Empty(t, records[1].TriggerReason)
+	assert.Equal(t, now, at.lastCycleAt)
+}
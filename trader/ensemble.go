@@ -0,0 +1,157 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/mcp"
+	"path/filepath"
+	"strings"
+)
+
+// EnsembleConfig 多模型集成决策配置（按交易员存储为JSON，为空时只使用交易员的主模型）
+// 交易员的主模型始终参与决策，Models 为额外参与的AI模型ID
+type EnsembleConfig struct {
+	Policy    string   `json:"policy"`               // unanimous / majority / weighted / judge
+	Models    []string `json:"models,omitempty"`     // 额外参与决策的AI模型ID
+	Judge     string   `json:"judge,omitempty"`      // 仲裁模型ID（judge 策略必填）
+	MinWeight float64  `json:"min_weight,omitempty"` // weighted 策略的执行阈值(0-1]，0表示默认0.5
+}
+
+// ParseEnsembleConfig 解析多模型集成配置JSON，空字符串返回空配置（单模型决策）
+func ParseEnsembleConfig(raw string) (EnsembleConfig, error) {
+	var cfg EnsembleConfig
+	if strings.TrimSpace(raw) == "" {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return EnsembleConfig{}, fmt.Errorf("解析多模型集成配置失败: %w", err)
+	}
+	if !cfg.Enabled() {
+		return EnsembleConfig{}, nil
+	}
+	if !decision.ValidEnsemblePolicy(cfg.Policy) {
+		return EnsembleConfig{}, fmt.Errorf("不支持的集成策略: %s", cfg.Policy)
+	}
+	if cfg.Policy == decision.EnsembleJudge && cfg.Judge == "" {
+		return EnsembleConfig{}, fmt.Errorf("judge 策略需要指定仲裁模型")
+	}
+	if cfg.Policy != decision.EnsembleJudge && len(cfg.Models) == 0 {
+		return EnsembleConfig{}, fmt.Errorf("%s 策略至少需要一个额外模型", cfg.Policy)
+	}
+	if cfg.MinWeight < 0 || cfg.MinWeight > 1 {
+		return EnsembleConfig{}, fmt.Errorf("加权阈值必须在0-1之间")
+	}
+	return cfg, nil
+}
+
+// Enabled 是否启用多模型集成
+func (c EnsembleConfig) Enabled() bool {
+	return c.Policy != ""
+}
+
+// AIModelSpec AI模型连接配置（集成决策中的额外模型与仲裁模型）
+type AIModelSpec struct {
+	Name            string // 模型名称（记录在决策日志中，为空时使用 提供商/模型名）
+	Provider        string // deepseek / qwen / custom
+	APIKey          string
+	CustomAPIURL    string
+	CustomModelName string
+}
+
+// label 模型在决策日志中的名称
+func (s AIModelSpec) label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return modelLabel(s.Provider, s.CustomModelName)
+}
+
+// newAIClient 按提供商创建AI客户端
+func newAIClient(spec AIModelSpec) mcp.AIClient {
+	var client mcp.AIClient
+	switch spec.Provider {
+	case "qwen":
+		client = mcp.NewQwenClient()
+	case "deepseek":
+		client = mcp.NewDeepSeekClient()
+	default:
+		client = mcp.New()
+	}
+	client.SetAPIKey(spec.APIKey, spec.CustomAPIURL, spec.CustomModelName)
+	return client
+}
+
+// modelLabel 模型在决策日志中的名称（自定义模型名优先）
+func modelLabel(provider, customModelName string) string {
+	if customModelName != "" {
+		return provider + "/" + customModelName
+	}
+	return provider
+}
+
+// buildEnsemble 根据配置创建多模型集成（未启用时返回 nil），主模型作为第一个成员
+// 额外成员与仲裁模型和主模型一样经 wrapAIClient 包装，录制到各自的子目录（回放按提示词哈希查找，不能混在一起）
+func buildEnsemble(config AutoTraderConfig, primary mcp.AIClient) (*decision.Ensemble, error) {
+	if config.EnsemblePolicy == "" {
+		return nil, nil
+	}
+	ensemble := &decision.Ensemble{
+		Policy:    config.EnsemblePolicy,
+		Members:   []decision.EnsembleMember{{Name: modelLabel(config.AIModel, config.CustomModelName), Client: primary}},
+		MinWeight: config.EnsembleMinWeight,
+	}
+	names := []string{ensemble.Members[0].Name}
+	for i, spec := range config.EnsembleModels {
+		client, err := wrapAIClient(config, spec.label(), newAIClient(spec), filepath.Join("ensemble", fmt.Sprintf("member_%d", i+1)))
+		if err != nil {
+			return nil, err
+		}
+		ensemble.Members = append(ensemble.Members, decision.EnsembleMember{Name: spec.label(), Client: client})
+		names = append(names, spec.label())
+	}
+	if config.EnsembleJudge != nil {
+		client, err := wrapAIClient(config, config.EnsembleJudge.label(), newAIClient(*config.EnsembleJudge), filepath.Join("ensemble", "judge"))
+		if err != nil {
+			return nil, err
+		}
+		ensemble.Judge = &decision.EnsembleMember{Name: config.EnsembleJudge.label(), Client: client}
+		log.Printf("🗳️ [%s] 多模型集成决策 [%s]: %s，仲裁模型: %s", config.Name, config.EnsemblePolicy, strings.Join(names, ", "), ensemble.Judge.Name)
+	} else {
+		log.Printf("🗳️ [%s] 多模型集成决策 [%s]: %s", config.Name, config.EnsemblePolicy, strings.Join(names, ", "))
+	}
+	return ensemble, nil
+}
+
+// requestFullDecision 请求AI决策（配置了多模型集成时并行请求所有模型）
+func (at *AutoTrader) requestFullDecision(ctx *decision.Context) (*decision.FullDecision, error) {
+	if at.ensemble != nil {
+		return decision.GetEnsembleDecision(ctx, at.ensemble, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+	}
+	return decision.GetFullDecisionWithCustomPrompt(ctx, at.mcpClient, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+}
+
+// modelResponseRecords 转换为决策日志中的模型响应记录
+func modelResponseRecords(responses []decision.ModelResponse) []logger.ModelResponse {
+	if len(responses) == 0 {
+		return nil
+	}
+	records := make([]logger.ModelResponse, len(responses))
+	for i, r := range responses {
+		records[i] = logger.ModelResponse{
+			Model:       r.Model,
+			CoTTrace:    r.CoTTrace,
+			RawResponse: r.RawResponse,
+			Error:       r.Error,
+			DurationMs:  r.DurationMs,
+			Judge:       r.Judge,
+		}
+		if len(r.Decisions) > 0 {
+			decisionJSON, _ := json.MarshalIndent(r.Decisions, "", "  ")
+			records[i].DecisionJSON = string(decisionJSON)
+		}
+	}
+	return records
+}
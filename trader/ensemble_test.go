@@ -0,0 +1,114 @@
+package trader
+
+import (
+	"nofx/decision"
+	"nofx/mcp"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnsembleConfig(t *testing.T) {
+	cfg, err := ParseEnsembleConfig("")
+	require.NoError(t, err)
+	assert.False(t, cfg.Enabled())
+
+	cfg, err = ParseEnsembleConfig(`{"policy":"weighted","models":["user_qwen"],"min_weight":0.6}`)
+	require.NoError(t, err)
+	assert.True(t, cfg.Enabled())
+	assert.Equal(t, []string{"user_qwen"}, cfg.Models)
+
+	// judge 策略可以只有主模型与仲裁模型
+	cfg, err = ParseEnsembleConfig(`{"policy":"judge","judge":"user_custom"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "user_custom", cfg.Judge)
+
+	for _, raw := range []string{
+		`{"policy":"vote","models":["a"]}`,
+		`{"policy":"majority"}`,
+		`{"policy":"judge","models":["a"]}`,
+		`{"policy":"weighted","models":["a"],"min_weight":1.5}`,
+		`not json`,
+	} {
+		_, err := ParseEnsembleConfig(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestBuildEnsemble(t *testing.T) {
+	ensemble, err := buildEnsemble(AutoTraderConfig{AIModel: "deepseek"}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, ensemble)
+
+	primary := mcp.New()
+	ensemble, err = buildEnsemble(AutoTraderConfig{
+		AIModel:        "deepseek",
+		EnsemblePolicy: decision.EnsembleJudge,
+		EnsembleModels: []AIModelSpec{{Provider: "qwen"}, {Provider: "custom", CustomModelName: "gpt-4o"}},
+		EnsembleJudge:  &AIModelSpec{Name: "arbiter", Provider: "custom"},
+	}, primary)
+	require.NoError(t, err)
+	require.NotNil(t, ensemble)
+	require.Len(t, ensemble.Members, 3)
+	assert.Same(t, primary, ensemble.Members[0].Client)
+	assert.Equal(t, []string{"deepseek", "qwen", "custom/gpt-4o"},
+		[]string{ensemble.Members[0].Name, ensemble.Members[1].Name, ensemble.Members[2].Name})
+	require.NotNil(t, ensemble.Judge)
+	assert.Equal(t, "arbiter", ensemble.Judge.Name)
+}
+
+// TestBuildEnsemble_WrapsMembers 额外成员与仲裁模型和主模型一样带故障转移与录制，各自录制到独立子目录
+func TestBuildEnsemble_WrapsMembers(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	ensemble, err := buildEnsemble(AutoTraderConfig{
+		ID:                "t1",
+		AIModel:           "deepseek",
+		RecordAIResponses: true,
+		FallbackModels:    []AIModelSpec{{Provider: "custom", CustomModelName: "gpt-4o"}},
+		EnsemblePolicy:    decision.EnsembleJudge,
+		EnsembleModels:    []AIModelSpec{{Provider: "qwen"}},
+		EnsembleJudge:     &AIModelSpec{Name: "arbiter", Provider: "custom"},
+	}, mcp.New())
+	require.NoError(t, err)
+	require.Len(t, ensemble.Members, 2)
+
+	for _, client := range []mcp.AIClient{ensemble.Members[1].Client, ensemble.Judge.Client} {
+		_, ok := client.(*mcp.RecordingClient)
+		assert.True(t, ok, "member should be recorded")
+	}
+	assert.DirExists(t, filepath.Join("ai_recordings", "t1", "ensemble", "member_1"))
+	assert.DirExists(t, filepath.Join("ai_recordings", "t1", "ensemble", "judge"))
+
+	// 录制层之下是故障转移链：成员 → 备用模型
+	client, err := wrapAIClient(AutoTraderConfig{
+		FallbackModels: []AIModelSpec{{Provider: "custom", CustomModelName: "gpt-4o"}},
+	}, "qwen", newAIClient(AIModelSpec{Provider: "qwen"}), "")
+	require.NoError(t, err)
+	failover, ok := client.(*mcp.FailoverClient)
+	require.True(t, ok)
+	var names []string
+	for _, h := range failover.ProviderHealth() {
+		names = append(names, h.Name)
+	}
+	assert.Equal(t, []string{"qwen", "custom/gpt-4o"}, names)
+}
+
+func TestModelResponseRecords(t *testing.T) {
+	assert.Nil(t, modelResponseRecords(nil))
+
+	records := modelResponseRecords([]decision.ModelResponse{
+		{Model: "deepseek", CoTTrace: "分析", RawResponse: "<reasoning>分析</reasoning>", Decisions: []decision.Decision{{Symbol: "BTCUSDT", Action: "wait"}}, DurationMs: 1200},
+		{Model: "qwen", Error: "调用AI API失败: timeout"},
+		{Model: "judge", Judge: true},
+	})
+	require.Len(t, records, 3)
+	assert.Contains(t, records[0].DecisionJSON, `"action": "wait"`)
+	assert.Equal(t, "<reasoning>分析</reasoning>", records[0].RawResponse)
+	assert.Equal(t, int64(1200), records[0].DurationMs)
+	assert.Empty(t, records[1].DecisionJSON)
+	assert.Equal(t, "调用AI API失败: timeout", records[1].Error)
+	assert.True(t, records[2].Judge)
+}
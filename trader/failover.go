@@ -0,0 +1,63 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/mcp"
+	"path/filepath"
+	"strings"
+)
+
+// ParseFallbackModels 解析AI故障转移备用模型ID列表（JSON数组），空字符串表示不启用故障转移
+func ParseFallbackModels(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var ids []string
+	if err := json.Unmarshal([]byte(raw), &ids); err != nil {
+		return nil, fmt.Errorf("解析备用模型配置失败: %w", err)
+	}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if id == "" {
+			return nil, fmt.Errorf("备用模型ID不能为空")
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("备用模型重复: %s", id)
+		}
+		seen[id] = true
+	}
+	return ids, nil
+}
+
+// buildFailoverClient 配置了备用模型时把模型包装为故障转移客户端（未配置时原样返回），name 为该模型在链中的名称
+func buildFailoverClient(config AutoTraderConfig, name string, primary mcp.AIClient) mcp.AIClient {
+	if len(config.FallbackModels) == 0 {
+		return primary
+	}
+	providers := []mcp.FailoverProvider{{Name: name, Client: primary}}
+	names := []string{providers[0].Name}
+	for _, spec := range config.FallbackModels {
+		providers = append(providers, mcp.FailoverProvider{Name: spec.label(), Client: newAIClient(spec)})
+		names = append(names, spec.label())
+	}
+	log.Printf("🔀 [%s] AI提供商故障转移链: %s", config.Name, strings.Join(names, " → "))
+	return mcp.NewFailoverClient(providers...)
+}
+
+// wrapAIClient 按交易员配置包装AI客户端（主模型、集成成员与仲裁模型共用）：
+// 配置了备用模型时加上故障转移，开启录制时录制到 ai_recordings/<交易员ID>/<recordSubdir>
+func wrapAIClient(config AutoTraderConfig, name string, client mcp.AIClient, recordSubdir string) (mcp.AIClient, error) {
+	client = buildFailoverClient(config, name, client)
+	if !config.RecordAIResponses {
+		return client, nil
+	}
+	dir := filepath.Join("ai_recordings", config.ID, recordSubdir)
+	recorder, err := mcp.NewRecordingClient(client, dir)
+	if err != nil {
+		return nil, fmt.Errorf("初始化AI录制失败 [%s]: %w", name, err)
+	}
+	log.Printf("🎙️ [%s] %s 的AI请求与响应将录制到 %s", config.Name, name, dir)
+	return recorder, nil
+}
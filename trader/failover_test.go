@@ -0,0 +1,44 @@
+package trader
+
+import (
+	"nofx/mcp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFallbackModels(t *testing.T) {
+	ids, err := ParseFallbackModels(" ")
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	ids, err = ParseFallbackModels(`["user_qwen","user_custom"]`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user_qwen", "user_custom"}, ids)
+
+	for _, raw := range []string{`["a","a"]`, `[""]`, `{"models":["a"]}`} {
+		_, err := ParseFallbackModels(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestBuildFailoverClient(t *testing.T) {
+	primary := mcp.New()
+	assert.Same(t, primary, buildFailoverClient(AutoTraderConfig{AIModel: "deepseek"}, "deepseek", primary))
+
+	client := buildFailoverClient(AutoTraderConfig{
+		AIModel:        "deepseek",
+		FallbackModels: []AIModelSpec{{Provider: "qwen", APIKey: "k"}, {Provider: "custom", CustomModelName: "gpt-4o"}},
+	}, "deepseek", primary)
+	failover, ok := client.(*mcp.FailoverClient)
+	require.True(t, ok)
+
+	var names []string
+	for _, h := range failover.ProviderHealth() {
+		names = append(names, h.Name)
+		assert.Equal(t, mcp.BreakerClosed, h.State)
+	}
+	assert.Equal(t, []string{"deepseek", "qwen", "custom/gpt-4o"}, names)
+	assert.Empty(t, mcp.AnsweredBy(client))
+}
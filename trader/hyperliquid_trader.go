@@ -1,14 +1,19 @@
 package trader
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/sonirico/go-hyperliquid"
@@ -22,6 +27,7 @@ type HyperliquidTrader struct {
 	meta          *hyperliquid.Meta // 缓存meta信息（包含精度等）
 	metaMutex     sync.RWMutex      // 保护meta字段的并发访问
 	isCrossMargin bool              // 是否为全仓模式
+	apiURL        string            // API地址（SDK未封装的 info 查询直接请求）
 }
 
 // NewHyperliquidTrader 创建Hyperliquid交易器
@@ -126,11 +132,12 @@ func NewHyperliquidTrader(privateKeyHex string, walletAddr string, testnet bool)
 		walletAddr:    walletAddr,
 		meta:          meta,
 		isCrossMargin: true, // 默认使用全仓模式
+		apiURL:        apiURL,
 	}, nil
 }
 
 // GetBalance 获取账户余额
-func (t *HyperliquidTrader) GetBalance() (map[string]interface{}, error) {
+func (t *HyperliquidTrader) GetBalance() (*Balance, error) {
 	log.Printf("🔄 正在调用Hyperliquid API获取账户余额...")
 
 	// ✅ Step 1: 查询 Spot 现货账户余额
@@ -155,9 +162,6 @@ func (t *HyperliquidTrader) GetBalance() (map[string]interface{}, error) {
 		return nil, fmt.Errorf("获取账户信息失败: %w", err)
 	}
 
-	// 解析余额信息（MarginSummary字段都是string）
-	result := make(map[string]interface{})
-
 	// ✅ Step 3: 根据保证金模式动态选择正确的摘要（CrossMarginSummary 或 MarginSummary）
 	var accountValue, totalMarginUsed float64
 	var summaryType string
@@ -222,10 +226,12 @@ func (t *HyperliquidTrader) GetBalance() (map[string]interface{}, error) {
 	//      原因：Spot 和 Perpetuals 是独立帐户，需手动 ClassTransfer 才能转账
 	totalWalletBalance := walletBalanceWithoutUnrealized + spotUSDCBalance
 
-	result["totalWalletBalance"] = totalWalletBalance    // 总资产（Perp + Spot）
-	result["availableBalance"] = availableBalance        // 可用余额（仅 Perpetuals，不含 Spot）
-	result["totalUnrealizedProfit"] = totalUnrealizedPnl // 未实现盈亏（仅来自 Perpetuals）
-	result["spotBalance"] = spotUSDCBalance              // Spot 现货余额（单独返回）
+	result := &Balance{
+		TotalWalletBalance:    totalWalletBalance, // 总资产（Perp + Spot）
+		AvailableBalance:      availableBalance,   // 可用余额（仅 Perpetuals，不含 Spot）
+		TotalUnrealizedProfit: totalUnrealizedPnl, // 未实现盈亏（仅来自 Perpetuals）
+		SpotBalance:           spotUSDCBalance,    // Spot 现货余额（单独返回）
+	}
 
 	log.Printf("✓ Hyperliquid 完整账户:")
 	log.Printf("  • Spot 现货余额: %.2f USDC （需手动转账到 Perpetuals 才能开仓）", spotUSDCBalance)
@@ -243,14 +249,14 @@ func (t *HyperliquidTrader) GetBalance() (map[string]interface{}, error) {
 }
 
 // GetPositions 获取所有持仓
-func (t *HyperliquidTrader) GetPositions() ([]map[string]interface{}, error) {
+func (t *HyperliquidTrader) GetPositions() ([]Position, error) {
 	// 获取账户状态
 	accountState, err := t.exchange.Info().UserState(t.ctx, t.walletAddr)
 	if err != nil {
 		return nil, fmt.Errorf("获取持仓失败: %w", err)
 	}
 
-	var result []map[string]interface{}
+	var result []Position
 
 	// 遍历所有持仓
 	for _, assetPos := range accountState.AssetPositions {
@@ -263,19 +269,16 @@ func (t *HyperliquidTrader) GetPositions() ([]map[string]interface{}, error) {
 			continue // 跳过无持仓的
 		}
 
-		posMap := make(map[string]interface{})
-
 		// 标准化symbol格式（Hyperliquid使用如"BTC"，我们转换为"BTCUSDT"）
-		symbol := position.Coin + "USDT"
-		posMap["symbol"] = symbol
+		pos := Position{Symbol: position.Coin + "USDT"}
 
 		// 持仓数量和方向
 		if posAmt > 0 {
-			posMap["side"] = "long"
-			posMap["positionAmt"] = posAmt
+			pos.Side = "long"
+			pos.Quantity = posAmt
 		} else {
-			posMap["side"] = "short"
-			posMap["positionAmt"] = -posAmt // 转为正数
+			pos.Side = "short"
+			pos.Quantity = -posAmt // 转为正数
 		}
 
 		// 价格信息（EntryPx和LiquidationPx是指针类型）
@@ -296,13 +299,13 @@ func (t *HyperliquidTrader) GetPositions() ([]map[string]interface{}, error) {
 			markPrice = positionValue / absFloat(posAmt)
 		}
 
-		posMap["entryPrice"] = entryPrice
-		posMap["markPrice"] = markPrice
-		posMap["unRealizedProfit"] = unrealizedPnl
-		posMap["leverage"] = float64(position.Leverage.Value)
-		posMap["liquidationPrice"] = liquidationPx
+		pos.EntryPrice = entryPrice
+		pos.MarkPrice = markPrice
+		pos.UnrealizedPnL = unrealizedPnl
+		pos.Leverage = position.Leverage.Value
+		pos.LiquidationPrice = liquidationPx
 
-		result = append(result, posMap)
+		result = append(result, pos)
 	}
 
 	return result, nil
@@ -372,7 +375,7 @@ func (t *HyperliquidTrader) refreshMetaIfNeeded(coin string) error {
 }
 
 // OpenLong 开多仓
-func (t *HyperliquidTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *HyperliquidTrader) OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error) {
 	// 先取消该币种的所有委托单
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消旧委托单失败: %v", err)
@@ -421,16 +424,11 @@ func (t *HyperliquidTrader) OpenLong(symbol string, quantity float64, leverage i
 
 	log.Printf("✓ 开多仓成功: %s 数量: %.4f", symbol, roundedQuantity)
 
-	result := make(map[string]interface{})
-	result["orderId"] = 0 // Hyperliquid没有返回order ID
-	result["symbol"] = symbol
-	result["status"] = "FILLED"
-
-	return result, nil
+	return &OrderResult{Symbol: symbol, Status: "FILLED"}, nil // Hyperliquid没有返回order ID
 }
 
 // OpenShort 开空仓
-func (t *HyperliquidTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *HyperliquidTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
 	// 先取消该币种的所有委托单
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消旧委托单失败: %v", err)
@@ -479,16 +477,73 @@ func (t *HyperliquidTrader) OpenShort(symbol string, quantity float64, leverage
 
 	log.Printf("✓ 开空仓成功: %s 数量: %.4f", symbol, roundedQuantity)
 
-	result := make(map[string]interface{})
-	result["orderId"] = 0
-	result["symbol"] = symbol
-	result["status"] = "FILLED"
+	return &OrderResult{Symbol: symbol, Status: "FILLED"}, nil
+}
+
+// OpenLongLimit 限价开多仓
+func (t *HyperliquidTrader) OpenLongLimit(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	return t.openLimit(symbol, true, quantity, leverage, price, postOnly)
+}
+
+// OpenShortLimit 限价开空仓
+func (t *HyperliquidTrader) OpenShortLimit(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	return t.openLimit(symbol, false, quantity, leverage, price, postOnly)
+}
+
+// openLimit 挂限价开仓单（post-only 使用 Alo，会立即成交时交易所直接拒单）
+// 不取消已有挂单，避免撤掉已有持仓的止盈止损单
+func (t *HyperliquidTrader) openLimit(symbol string, isBuy bool, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	if err := t.SetLeverage(symbol, leverage); err != nil {
+		return nil, err
+	}
+
+	coin := convertSymbolToHyperliquid(symbol)
+	roundedQuantity := t.roundToSzDecimals(coin, quantity)
+	if roundedQuantity <= 0 {
+		return nil, fmt.Errorf("开仓数量过小，精度处理后为 0 (原始: %.8f)", quantity)
+	}
+	limitPrice := t.roundPriceToSigfigs(price)
+
+	tif := hyperliquid.TifGtc
+	if postOnly {
+		tif = hyperliquid.TifAlo
+	}
+
+	status, err := t.exchange.Order(t.ctx, hyperliquid.CreateOrderRequest{
+		Coin:  coin,
+		IsBuy: isBuy,
+		Size:  roundedQuantity,
+		Price: limitPrice,
+		OrderType: hyperliquid.OrderType{
+			Limit: &hyperliquid.LimitOrderType{Tif: tif},
+		},
+		ReduceOnly: false,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("限价开仓失败: %w", err)
+	}
+	if status.Error != nil {
+		return nil, fmt.Errorf("限价开仓被拒绝: %s", *status.Error)
+	}
+
+	result := &OrderResult{Symbol: symbol, Status: OrderStatusNew}
+	switch {
+	case status.Filled != nil:
+		result.Status = OrderStatusFilled
+		result.OrderID = int64(status.Filled.Oid)
+		result.AvgPrice, _ = strconv.ParseFloat(status.Filled.AvgPx, 64)
+		result.ExecutedQty, _ = strconv.ParseFloat(status.Filled.TotalSz, 64)
+	case status.Resting != nil:
+		result.OrderID = status.Resting.Oid
+	}
 
+	log.Printf("✓ 限价开仓单已提交: %s 买入=%v 数量: %.4f 价格: %.4f (%s) 状态: %s",
+		symbol, isBuy, roundedQuantity, limitPrice, tif, result.Status)
 	return result, nil
 }
 
 // CloseLong 平多仓
-func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -497,8 +552,8 @@ func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (map[stri
 		}
 
 		for _, pos := range positions {
-			if pos["symbol"] == symbol && pos["side"] == "long" {
-				quantity = pos["positionAmt"].(float64)
+			if pos.Symbol == symbol && pos.Side == "long" {
+				quantity = pos.Quantity
 				break
 			}
 		}
@@ -551,16 +606,11 @@ func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (map[stri
 		log.Printf("  ⚠ 取消挂单失败: %v", err)
 	}
 
-	result := make(map[string]interface{})
-	result["orderId"] = 0
-	result["symbol"] = symbol
-	result["status"] = "FILLED"
-
-	return result, nil
+	return &OrderResult{Symbol: symbol, Status: "FILLED"}, nil
 }
 
 // CloseShort 平空仓
-func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -569,8 +619,8 @@ func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (map[str
 		}
 
 		for _, pos := range positions {
-			if pos["symbol"] == symbol && pos["side"] == "short" {
-				quantity = pos["positionAmt"].(float64)
+			if pos.Symbol == symbol && pos.Side == "short" {
+				quantity = pos.Quantity
 				break
 			}
 		}
@@ -623,12 +673,7 @@ func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (map[str
 		log.Printf("  ⚠ 取消挂单失败: %v", err)
 	}
 
-	result := make(map[string]interface{})
-	result["orderId"] = 0
-	result["symbol"] = symbol
-	result["status"] = "FILLED"
-
-	return result, nil
+	return &OrderResult{Symbol: symbol, Status: "FILLED"}, nil
 }
 
 // CancelStopOrders 取消该币种的止盈/止
@@ -649,6 +694,21 @@ func (t *HyperliquidTrader) CancelTakeProfitOrders(symbol string) error {
 	return t.CancelStopOrders(symbol)
 }
 
+// CancelOrder 按订单ID取消单个挂单
+func (t *HyperliquidTrader) CancelOrder(symbol string, orderID string) error {
+	oid, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("无效的订单ID %s: %w", orderID, err)
+	}
+
+	if _, err := t.exchange.Cancel(t.ctx, convertSymbolToHyperliquid(symbol), oid); err != nil {
+		return fmt.Errorf("取消订单 %s 失败: %w", orderID, err)
+	}
+
+	log.Printf("  ✓ 已取消订单 %s (%s)", orderID, symbol)
+	return nil
+}
+
 // CancelAllOrders 取消该币种的所有挂单
 func (t *HyperliquidTrader) CancelAllOrders(symbol string) error {
 	coin := convertSymbolToHyperliquid(symbol)
@@ -729,6 +789,26 @@ func (t *HyperliquidTrader) GetMarketPrice(symbol string) (float64, error) {
 	return 0, fmt.Errorf("未找到 %s 的价格", symbol)
 }
 
+// GetMarketPrices 一次 allMids 请求获取多个币种的价格（未找到或格式错误的币种不在结果中）
+func (t *HyperliquidTrader) GetMarketPrices(symbols []string) (map[string]float64, error) {
+	allMids, err := t.exchange.Info().AllMids(t.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取价格失败: %w", err)
+	}
+
+	prices := make(map[string]float64, len(symbols))
+	for _, symbol := range symbols {
+		priceStr, ok := allMids[convertSymbolToHyperliquid(symbol)]
+		if !ok {
+			continue
+		}
+		if price, err := strconv.ParseFloat(priceStr, 64); err == nil {
+			prices[symbol] = price
+		}
+	}
+	return prices, nil
+}
+
 // SetStopLoss 设置止损单
 func (t *HyperliquidTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
 	coin := convertSymbolToHyperliquid(symbol)
@@ -887,6 +967,244 @@ func (t *HyperliquidTrader) roundPriceToSigfigs(price float64) float64 {
 	return rounded
 }
 
+// GetFills 获取 since 之后的成交（symbol为空表示全部币种）
+func (t *HyperliquidTrader) GetFills(symbol string, since time.Time) ([]Fill, error) {
+	hlFills, err := t.exchange.Info().UserFillsByTime(t.ctx, t.walletAddr, since.UnixMilli(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取成交历史失败: %w", err)
+	}
+
+	coin := convertSymbolToHyperliquid(symbol)
+	var orderTypes map[int64]string
+	fills := make([]Fill, 0, len(hlFills))
+	for _, f := range hlFills {
+		if symbol != "" && f.Coin != coin {
+			continue
+		}
+
+		price, _ := strconv.ParseFloat(f.Price, 64)
+		quantity, _ := strconv.ParseFloat(f.Size, 64)
+		fee, _ := strconv.ParseFloat(f.Fee, 64)
+		realized, _ := strconv.ParseFloat(f.ClosedPnl, 64)
+		side := "BUY"
+		if f.Side == string(hyperliquid.OrderSideAsk) {
+			side = "SELL"
+		}
+		positionSide, reduceOnly := hyperliquidFillDirection(f.Dir, side)
+
+		// 平仓成交需要区分是否为止损止盈触发，按需查询一次历史订单
+		orderType := ""
+		if reduceOnly {
+			if orderTypes == nil {
+				orderTypes = t.historicalOrderTypes()
+			}
+			orderType = orderTypes[f.Oid]
+			if strings.Contains(f.Dir, "Liquidat") {
+				orderType = "LIQUIDATION"
+			}
+		}
+
+		fills = append(fills, Fill{
+			TradeID:      strconv.FormatInt(f.Tid, 10),
+			OrderID:      strconv.FormatInt(f.Oid, 10),
+			Symbol:       f.Coin + "USDT",
+			Side:         side,
+			PositionSide: positionSide,
+			OrderType:    orderType,
+			Price:        price,
+			Quantity:     quantity,
+			Fee:          fee,
+			RealizedPnL:  realized,
+			ReduceOnly:   reduceOnly,
+			Time:         time.UnixMilli(f.Time),
+		})
+	}
+
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].Time.Before(fills[j].Time) })
+	return fills, nil
+}
+
+// hyperliquidFillDirection 根据成交方向描述（Open Long / Close Short / Long > Short 等）推导持仓方向和是否平仓
+func hyperliquidFillDirection(dir, side string) (positionSide string, reduceOnly bool) {
+	hasLong := strings.Contains(dir, "Long")
+	hasShort := strings.Contains(dir, "Short")
+	switch {
+	case hasLong && !hasShort:
+		positionSide = "LONG"
+	case hasShort && !hasLong:
+		positionSide = "SHORT"
+	case side == "BUY":
+		// 反手成交（Short > Long）按新开仓方向记录
+		positionSide = "LONG"
+	default:
+		positionSide = "SHORT"
+	}
+	reduceOnly = strings.HasPrefix(dir, "Close") || strings.Contains(dir, "Liquidat")
+	return positionSide, reduceOnly
+}
+
+// historicalOrderTypes 查询历史订单类型（订单ID -> STOP_MARKET / TAKE_PROFIT_MARKET / MARKET / LIMIT），查询失败时返回空表
+func (t *HyperliquidTrader) historicalOrderTypes() map[int64]string {
+	types := make(map[int64]string)
+	orders, err := t.exchange.Info().HistoricalOrders(t.ctx, t.walletAddr)
+	if err != nil {
+		log.Printf("  ⚠ 查询历史订单失败: %v", err)
+		return types
+	}
+
+	for _, order := range orders {
+		orderType := strings.ToLower(order.Order.OrderType)
+		switch {
+		case strings.HasPrefix(orderType, "stop"):
+			types[order.Order.Oid] = "STOP_MARKET"
+		case strings.HasPrefix(orderType, "take profit"):
+			types[order.Order.Oid] = "TAKE_PROFIT_MARKET"
+		case orderType == "market":
+			types[order.Order.Oid] = "MARKET"
+		case orderType == "limit":
+			types[order.Order.Oid] = "LIMIT"
+		}
+	}
+	return types
+}
+
+// hyperliquidFunding 资金费记录（userFunding 接口，SDK 未解析金额字段）
+type hyperliquidFunding struct {
+	Time  int64  `json:"time"`
+	Hash  string `json:"hash"`
+	Delta struct {
+		Type string `json:"type"`
+		Coin string `json:"coin"`
+		USDC string `json:"usdc"`
+	} `json:"delta"`
+}
+
+// GetIncome 获取 since 之后的资金流水
+// Hyperliquid 没有统一的流水接口：已实现盈亏和手续费来自成交记录，资金费来自 userFunding
+func (t *HyperliquidTrader) GetIncome(symbol string, since time.Time) ([]Income, error) {
+	fills, err := t.GetFills(symbol, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var incomes []Income
+	for _, fill := range fills {
+		if fill.RealizedPnL != 0 {
+			incomes = append(incomes, Income{Symbol: fill.Symbol, Type: IncomeRealizedPnL, Amount: fill.RealizedPnL,
+				Asset: "USDC", TranID: fill.TradeID + "-pnl", TradeID: fill.TradeID, Time: fill.Time})
+		}
+		if fill.Fee != 0 {
+			incomes = append(incomes, Income{Symbol: fill.Symbol, Type: IncomeCommission, Amount: -fill.Fee,
+				Asset: "USDC", TranID: fill.TradeID + "-fee", TradeID: fill.TradeID, Time: fill.Time})
+		}
+	}
+
+	var fundings []hyperliquidFunding
+	if err := t.postInfo(map[string]interface{}{
+		"type":      "userFunding",
+		"user":      t.walletAddr,
+		"startTime": since.UnixMilli(),
+	}, &fundings); err != nil {
+		return nil, fmt.Errorf("获取资金费记录失败: %w", err)
+	}
+
+	coin := convertSymbolToHyperliquid(symbol)
+	for _, funding := range fundings {
+		if funding.Delta.Type != "funding" || (symbol != "" && funding.Delta.Coin != coin) {
+			continue
+		}
+		amount, _ := strconv.ParseFloat(funding.Delta.USDC, 64)
+		incomes = append(incomes, Income{
+			Symbol: funding.Delta.Coin + "USDT",
+			Type:   IncomeFundingFee,
+			Amount: amount,
+			Asset:  "USDC",
+			TranID: funding.Hash + "-" + funding.Delta.Coin,
+			Time:   time.UnixMilli(funding.Time),
+		})
+	}
+
+	sort.SliceStable(incomes, func(i, j int) bool { return incomes[i].Time.Before(incomes[j].Time) })
+	return incomes, nil
+}
+
+// GetOpenOrders 获取当前挂单（包括止盈止损触发单）
+func (t *HyperliquidTrader) GetOpenOrders(symbol string) ([]OpenOrder, error) {
+	hlOrders, err := t.exchange.Info().FrontendOpenOrders(t.ctx, t.walletAddr)
+	if err != nil {
+		return nil, fmt.Errorf("获取挂单失败: %w", err)
+	}
+
+	coin := convertSymbolToHyperliquid(symbol)
+	orders := make([]OpenOrder, 0, len(hlOrders))
+	for _, order := range hlOrders {
+		if symbol != "" && order.Coin != coin {
+			continue
+		}
+
+		side := "BUY"
+		if order.Side == hyperliquid.OrderSideAsk {
+			side = "SELL"
+		}
+		// 平仓单的持仓方向与买卖方向相反
+		positionSide := "LONG"
+		if (side == "SELL") != order.ReduceOnly {
+			positionSide = "SHORT"
+		}
+
+		orderType := "LIMIT"
+		stopPrice := 0.0
+		if order.IsTrigger {
+			stopPrice = order.TriggerPx
+			orderType = "STOP_MARKET"
+			if strings.HasPrefix(strings.ToLower(order.OrderType), "take profit") {
+				orderType = "TAKE_PROFIT_MARKET"
+			}
+		}
+
+		orders = append(orders, OpenOrder{
+			OrderID:      strconv.FormatInt(order.Oid, 10),
+			Symbol:       order.Coin + "USDT",
+			Side:         side,
+			PositionSide: positionSide,
+			Type:         orderType,
+			Price:        order.LimitPx,
+			StopPrice:    stopPrice,
+			Quantity:     order.OrigSz,
+			FilledQty:    order.OrigSz - order.Sz,
+			ReduceOnly:   order.ReduceOnly,
+			Time:         time.UnixMilli(order.Timestamp),
+		})
+	}
+	return orders, nil
+}
+
+// postInfo 直接请求 /info 接口（用于 SDK 未完整解析响应的查询）
+func (t *HyperliquidTrader) postInfo(payload map[string]interface{}, result interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodPost, t.apiURL+"/info", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, result)
+}
+
 // convertSymbolToHyperliquid 将标准symbol转换为Hyperliquid格式
 // 例如: "BTCUSDT" -> "BTC"
 func convertSymbolToHyperliquid(symbol string) string {
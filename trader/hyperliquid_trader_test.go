@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/sonirico/go-hyperliquid"
@@ -150,6 +151,47 @@ func NewHyperliquidTestSuite(t *testing.T) *HyperliquidTestSuite {
 		case "openOrders":
 			respBody = []interface{}{}
 
+		// Mock FrontendOpenOrders - 获取挂单列表（包含触发单信息）
+		case "frontendOpenOrders":
+			respBody = []map[string]interface{}{
+				{"coin": "BTC", "oid": 501, "side": "A", "limitPx": "47500.0", "sz": "0.5", "origSz": "0.5",
+					"orderType": "Stop Market", "isTrigger": true, "triggerPx": "48000.0", "triggerCondition": "Price below 48000",
+					"reduceOnly": true, "isPositionTpsl": false, "timestamp": 1700000000000},
+				{"coin": "ETH", "oid": 502, "side": "B", "limitPx": "2900.0", "sz": "1.0", "origSz": "2.0",
+					"orderType": "Limit", "isTrigger": false, "triggerPx": "0.0", "triggerCondition": "N/A",
+					"reduceOnly": false, "isPositionTpsl": false, "timestamp": 1700000001000},
+			}
+
+		// Mock UserFillsByTime - 获取成交历史
+		case "userFillsByTime":
+			respBody = []map[string]interface{}{
+				{"coin": "BTC", "px": "50000.0", "sz": "0.5", "side": "B", "time": 1700000000000, "startPosition": "0.0",
+					"dir": "Open Long", "closedPnl": "0.0", "hash": "0xaa", "oid": 401, "crossed": true, "fee": "5.0",
+					"tid": 9001, "feeToken": "USDC"},
+				{"coin": "ETH", "px": "3000.0", "sz": "1.0", "side": "A", "time": 1700000030000, "startPosition": "0.0",
+					"dir": "Open Short", "closedPnl": "0.0", "hash": "0xbb", "oid": 402, "crossed": true, "fee": "1.2",
+					"tid": 9002, "feeToken": "USDC"},
+				{"coin": "BTC", "px": "48000.0", "sz": "0.5", "side": "A", "time": 1700000060000, "startPosition": "0.5",
+					"dir": "Close Long", "closedPnl": "-1000.0", "hash": "0xcc", "oid": 403, "crossed": true, "fee": "4.8",
+					"tid": 9003, "feeToken": "USDC"},
+			}
+
+		// Mock HistoricalOrders - 获取历史订单（用于识别止损止盈成交）
+		case "historicalOrders":
+			respBody = []map[string]interface{}{
+				{"order": map[string]interface{}{"coin": "BTC", "oid": 403, "side": "A", "orderType": "Stop Market",
+					"isTrigger": true, "reduceOnly": true}, "status": "triggered", "statusTimestamp": 1700000060000},
+			}
+
+		// Mock UserFunding - 获取资金费记录
+		case "userFunding":
+			respBody = []map[string]interface{}{
+				{"time": 1700000040000, "hash": "0x00", "delta": map[string]interface{}{
+					"type": "funding", "coin": "BTC", "usdc": "-0.75", "szi": "0.5", "fundingRate": "0.0000125"}},
+				{"time": 1700000040000, "hash": "0x00", "delta": map[string]interface{}{
+					"type": "funding", "coin": "ETH", "usdc": "0.3", "szi": "-1.0", "fundingRate": "0.0000100"}},
+			}
+
 		// Mock Order - 创建订单（开仓、平仓、止损、止盈）
 		case "order":
 			respBody = map[string]interface{}{
@@ -222,6 +264,7 @@ func NewHyperliquidTestSuite(t *testing.T) *HyperliquidTestSuite {
 		walletAddr:    walletAddr,
 		meta:          meta,
 		isCrossMargin: true,
+		apiURL:        mockServer.URL,
 	}
 
 	// 创建基础套件
@@ -644,3 +687,66 @@ func TestNewHyperliquidTrader_PrivateKeyProcessing(t *testing.T) {
 		})
 	}
 }
+
+// TestHyperliquidTrader_FillsIncomeAndOpenOrders 测试成交、资金流水与挂单的字段转换
+// TestHyperliquidTrader_GetMarketPrices 一次 allMids 请求返回需要的币种，未上线的币种不在结果中
+func TestHyperliquidTrader_GetMarketPrices(t *testing.T) {
+	suite := NewHyperliquidTestSuite(t)
+	defer suite.Cleanup()
+
+	prices, err := suite.Trader.(*HyperliquidTrader).GetMarketPrices([]string{"BTCUSDT", "ETHUSDT", "DOGEUSDT"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"BTCUSDT": 50000, "ETHUSDT": 3000}, prices)
+}
+
+func TestHyperliquidTrader_FillsIncomeAndOpenOrders(t *testing.T) {
+	suite := NewHyperliquidTestSuite(t)
+	defer suite.Cleanup()
+
+	trader := suite.Trader.(*HyperliquidTrader)
+	since := time.UnixMilli(1700000000000)
+
+	fills, err := trader.GetFills("BTCUSDT", since)
+	assert.NoError(t, err)
+	assert.Len(t, fills, 2)
+	assert.Equal(t, "BUY", fills[0].Side)
+	assert.Equal(t, "LONG", fills[0].PositionSide)
+	assert.False(t, fills[0].ReduceOnly)
+	assert.Empty(t, fills[0].OrderType, "开仓成交不查询订单类型")
+	assert.Equal(t, "9003", fills[1].TradeID)
+	assert.Equal(t, "403", fills[1].OrderID)
+	assert.Equal(t, "SELL", fills[1].Side)
+	assert.True(t, fills[1].ReduceOnly)
+	assert.Equal(t, "STOP_MARKET", fills[1].OrderType, "止损触发的平仓应能识别")
+	assert.Equal(t, -1000.0, fills[1].RealizedPnL)
+
+	all, err := trader.GetFills("", since)
+	assert.NoError(t, err)
+	assert.Len(t, all, 3)
+	assert.Equal(t, "SHORT", all[1].PositionSide)
+
+	incomes, err := trader.GetIncome("BTCUSDT", since)
+	assert.NoError(t, err)
+	byType := make(map[string]float64)
+	for _, income := range incomes {
+		assert.Equal(t, "BTCUSDT", income.Symbol)
+		byType[income.Type] += income.Amount
+	}
+	assert.InDelta(t, -9.8, byType[IncomeCommission], 1e-9)
+	assert.InDelta(t, -1000, byType[IncomeRealizedPnL], 1e-9)
+	assert.InDelta(t, -0.75, byType[IncomeFundingFee], 1e-9)
+
+	orders, err := trader.GetOpenOrders("BTCUSDT")
+	assert.NoError(t, err)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, "STOP_MARKET", orders[0].Type)
+	assert.Equal(t, "LONG", orders[0].PositionSide, "卖出平仓单对应多仓")
+	assert.Equal(t, 48000.0, orders[0].StopPrice)
+
+	orders, err = trader.GetOpenOrders("")
+	assert.NoError(t, err)
+	assert.Len(t, orders, 2)
+	assert.Equal(t, "LIMIT", orders[1].Type)
+	assert.Equal(t, "LONG", orders[1].PositionSide)
+	assert.Equal(t, 1.0, orders[1].FilledQty)
+}
@@ -1,25 +1,39 @@
 package trader
 
+import (
+	"strings"
+	"time"
+)
+
 // Trader 交易器统一接口
 // 支持多个交易平台（币安、Hyperliquid等）
 type Trader interface {
 	// GetBalance 获取账户余额
-	GetBalance() (map[string]interface{}, error)
+	GetBalance() (*Balance, error)
 
-	// GetPositions 获取所有持仓
-	GetPositions() ([]map[string]interface{}, error)
+	// GetPositions 获取所有持仓（不含数量为0的记录）
+	GetPositions() ([]Position, error)
 
 	// OpenLong 开多仓
-	OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error)
+	OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error)
 
 	// OpenShort 开空仓
-	OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error)
+	OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error)
+
+	// OpenLongLimit 限价开多仓（postOnly=true 时只做 maker，会立即成交则拒单）
+	OpenLongLimit(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error)
+
+	// OpenShortLimit 限价开空仓（postOnly=true 时只做 maker，会立即成交则拒单）
+	OpenShortLimit(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error)
+
+	// CancelOrder 按订单ID取消单个挂单
+	CancelOrder(symbol string, orderID string) error
 
 	// CloseLong 平多仓（quantity=0表示全部平仓）
-	CloseLong(symbol string, quantity float64) (map[string]interface{}, error)
+	CloseLong(symbol string, quantity float64) (*OrderResult, error)
 
 	// CloseShort 平空仓（quantity=0表示全部平仓）
-	CloseShort(symbol string, quantity float64) (map[string]interface{}, error)
+	CloseShort(symbol string, quantity float64) (*OrderResult, error)
 
 	// SetLeverage 设置杠杆
 	SetLeverage(symbol string, leverage int) error
@@ -50,4 +64,125 @@ type Trader interface {
 
 	// FormatQuantity 格式化数量到正确的精度
 	FormatQuantity(symbol string, quantity float64) (string, error)
+
+	// GetFills 获取 since 之后的成交明细（按时间正序）
+	GetFills(symbol string, since time.Time) ([]Fill, error)
+
+	// GetIncome 获取 since 之后的资金流水：已实现盈亏、资金费、手续费（symbol为空表示全部币种）
+	GetIncome(symbol string, since time.Time) ([]Income, error)
+
+	// GetOpenOrders 获取当前挂单（symbol为空表示全部币种）
+	GetOpenOrders(symbol string) ([]OpenOrder, error)
+}
+
+// defaultPositionLeverage 交易所未返回杠杆时估算保证金使用的默认杠杆
+const defaultPositionLeverage = 10
+
+// Balance 账户余额（JSON 字段名与旧版 map 返回保持一致）
+type Balance struct {
+	TotalWalletBalance    float64 `json:"totalWalletBalance"`    // 钱包余额（不含未实现盈亏）
+	AvailableBalance      float64 `json:"availableBalance"`      // 可用余额
+	TotalUnrealizedProfit float64 `json:"totalUnrealizedProfit"` // 未实现盈亏
+	SpotBalance           float64 `json:"spotBalance,omitempty"` // 现货余额（仅 Hyperliquid，已计入钱包余额）
+}
+
+// Equity 账户净值 = 钱包余额 + 未实现盈亏
+func (b *Balance) Equity() float64 {
+	return b.TotalWalletBalance + b.TotalUnrealizedProfit
+}
+
+// Position 持仓（JSON 字段名与旧版 map 返回保持一致）
+type Position struct {
+	Symbol           string  `json:"symbol"`
+	Side             string  `json:"side"`        // long / short
+	Quantity         float64 `json:"positionAmt"` // 持仓数量（始终为正数）
+	EntryPrice       float64 `json:"entryPrice"`
+	MarkPrice        float64 `json:"markPrice"`
+	UnrealizedPnL    float64 `json:"unRealizedProfit"`
+	Leverage         int     `json:"leverage"` // 交易所未返回时为0
+	LiquidationPrice float64 `json:"liquidationPrice"`
+}
+
+// PositionSide 交易所下单使用的持仓方向（LONG / SHORT）
+func (p Position) PositionSide() string {
+	return strings.ToUpper(p.Side)
+}
+
+// EffectiveLeverage 用于估算保证金的杠杆（未知时使用默认杠杆）
+func (p Position) EffectiveLeverage() int {
+	if p.Leverage > 0 {
+		return p.Leverage
+	}
+	return defaultPositionLeverage
+}
+
+// MarginUsed 估算占用保证金 = 持仓价值 / 杠杆
+func (p Position) MarginUsed() float64 {
+	return p.Quantity * p.MarkPrice / float64(p.EffectiveLeverage())
+}
+
+// 下单结果状态
+const (
+	OrderStatusNew    = "NEW"    // 限价单挂单中
+	OrderStatusFilled = "FILLED" // 已成交
+)
+
+// OrderResult 下单结果（JSON 字段名与旧版 map 返回保持一致）
+type OrderResult struct {
+	OrderID     int64   `json:"orderId"` // 交易所订单ID（交易所未返回时为0）
+	Symbol      string  `json:"symbol"`
+	Status      string  `json:"status"`
+	AvgPrice    float64 `json:"avgPrice,omitempty"` // 成交均价（交易所未返回时为0）
+	ExecutedQty float64 `json:"executedQty,omitempty"`
+	Fee         float64 `json:"fee,omitempty"`
+	RealizedPnL float64 `json:"realizedPnl,omitempty"`
+}
+
+// 资金流水类型（与币安 incomeType 一致）
+const (
+	IncomeRealizedPnL = "REALIZED_PNL" // 已实现盈亏
+	IncomeFundingFee  = "FUNDING_FEE"  // 资金费
+	IncomeCommission  = "COMMISSION"   // 交易手续费
+)
+
+// Fill 交易所成交明细
+type Fill struct {
+	TradeID      string
+	OrderID      string
+	Symbol       string
+	Side         string // BUY / SELL
+	PositionSide string // LONG / SHORT
+	OrderType    string // MARKET / LIMIT / STOP_MARKET / TAKE_PROFIT_MARKET / LIQUIDATION（未知时为空）
+	Price        float64
+	Quantity     float64
+	Fee          float64
+	RealizedPnL  float64
+	ReduceOnly   bool
+	Time         time.Time
+}
+
+// Income 资金流水（金额为正表示收入，为负表示支出）
+type Income struct {
+	Symbol  string
+	Type    string // REALIZED_PNL / FUNDING_FEE / COMMISSION，其他类型原样返回
+	Amount  float64
+	Asset   string
+	TranID  string
+	TradeID string // 关联的成交ID（资金费为空）
+	Time    time.Time
+}
+
+// OpenOrder 当前挂单
+type OpenOrder struct {
+	OrderID      string
+	Symbol       string
+	Side         string // BUY / SELL
+	PositionSide string // LONG / SHORT（单向持仓模式下可能为 BOTH 或空）
+	Type         string // LIMIT / STOP_MARKET / TAKE_PROFIT_MARKET ...
+	Price        float64
+	StopPrice    float64 // 触发价（非条件单为0）
+	Quantity     float64 // 原始下单数量
+	FilledQty    float64
+	ReduceOnly   bool
+	Time         time.Time
 }
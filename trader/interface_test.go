@@ -0,0 +1,51 @@
+package trader
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPosition_Helpers(t *testing.T) {
+	pos := Position{Symbol: "BTCUSDT", Side: "short", Quantity: 0.5, MarkPrice: 40000, Leverage: 5}
+	assert.Equal(t, "SHORT", pos.PositionSide())
+	assert.Equal(t, 5, pos.EffectiveLeverage())
+	assert.InDelta(t, 4000, pos.MarginUsed(), 1e-9)
+
+	// 交易所未返回杠杆时按默认杠杆估算保证金
+	pos.Leverage = 0
+	assert.Equal(t, defaultPositionLeverage, pos.EffectiveLeverage())
+	assert.InDelta(t, 2000, pos.MarginUsed(), 1e-9)
+
+	balance := &Balance{TotalWalletBalance: 1000, TotalUnrealizedProfit: -50}
+	assert.InDelta(t, 950, balance.Equity(), 1e-9)
+}
+
+func TestTypedResults_LegacyJSON(t *testing.T) {
+	// 序列化后的字段名需与旧版 map 返回一致，避免破坏依赖原始字段的调用方
+	cases := []struct {
+		name  string
+		value interface{}
+		keys  []string
+	}{
+		{"Balance", &Balance{TotalWalletBalance: 1}, []string{"totalWalletBalance", "availableBalance", "totalUnrealizedProfit"}},
+		{"Position", Position{Symbol: "BTCUSDT"}, []string{"symbol", "side", "positionAmt", "entryPrice", "markPrice",
+			"unRealizedProfit", "leverage", "liquidationPrice"}},
+		{"OrderResult", &OrderResult{OrderID: 1, AvgPrice: 100}, []string{"orderId", "symbol", "status", "avgPrice"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.value)
+			require.NoError(t, err)
+
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal(data, &fields))
+			for _, key := range tc.keys {
+				assert.Contains(t, fields, key)
+			}
+		})
+	}
+}
@@ -0,0 +1,454 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"nofx/config"
+	"nofx/logger"
+)
+
+// LedgerReconcileInterval 成交对账间隔
+const LedgerReconcileInterval = 5 * time.Minute
+
+// ledgerInitialLookback 账本为空时首次对账回溯的时长
+const ledgerInitialLookback = 24 * time.Hour
+
+// OrderLedger 订单与成交账本（由 config.Database 实现）
+type OrderLedger interface {
+	RecordOrder(order *config.LedgerOrder) error
+	RecordFill(fill *config.LedgerFill) (bool, error)
+	GetLedgerOrder(traderID, exchangeOrderID string) (*config.LedgerOrder, error)
+	GetLedgerOrders(traderID string, since time.Time) ([]*config.LedgerOrder, error)
+	GetLedgerFills(traderID string, since time.Time) ([]*config.LedgerFill, error)
+	GetLatestFillTime(traderID, symbol string) (time.Time, error)
+}
+
+// SetOrderLedger 设置订单账本（默认使用传入 NewAutoTrader 的数据库）
+func (at *AutoTrader) SetOrderLedger(ledger OrderLedger) {
+	at.ledger = ledger
+}
+
+// recordOrder 将已成交的下单写入账本，成交价与手续费优先取交易所返回值
+func (at *AutoTrader) recordOrder(entry *config.LedgerOrder, order *OrderResult) {
+	if at.ledger == nil {
+		return
+	}
+
+	entry.TraderID = at.id
+	entry.Side = orderSide(entry.PositionSide, entry.ReduceOnly)
+	if entry.OrderType == "" {
+		entry.OrderType = "MARKET"
+	}
+	entry.OrderTime = at.now()
+	if order != nil {
+		if order.OrderID > 0 {
+			entry.ExchangeOrderID = strconv.FormatInt(order.OrderID, 10)
+		}
+		entry.Status = order.Status
+		if order.AvgPrice > 0 {
+			entry.Price = order.AvgPrice
+		}
+		if order.Fee > 0 {
+			entry.Fee = order.Fee
+		}
+	}
+
+	if err := at.ledger.RecordOrder(entry); err != nil {
+		log.Printf("⚠️ [%s] 写入订单账本失败: %v", at.name, err)
+	}
+}
+
+// orderSide 根据持仓方向和开平仓推导买卖方向
+func orderSide(positionSide string, reduceOnly bool) string {
+	if (positionSide == "LONG") != reduceOnly {
+		return "BUY"
+	}
+	return "SELL"
+}
+
+// startLedgerReconciler 定期从交易所拉取成交写入账本
+func (at *AutoTrader) startLedgerReconciler() {
+	if at.ledger == nil {
+		return
+	}
+
+	at.monitorWg.Add(1)
+	go func() {
+		defer at.monitorWg.Done()
+
+		ticker := time.NewTicker(LedgerReconcileInterval)
+		defer ticker.Stop()
+
+		for {
+			if n, err := at.reconcileLedger(); err != nil {
+				log.Printf("⚠️ [%s] 成交对账失败: %v", at.name, err)
+			} else if n > 0 {
+				log.Printf("📒 [%s] 成交对账完成，新增 %d 笔成交", at.name, n)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-at.stopMonitorCh:
+				return
+			}
+		}
+	}()
+}
+
+// reconcileLedger 按币种拉取各自上次对账以来的成交写入账本，返回新增成交数
+// 账本中没有对应订单的成交（交易所止损止盈、强平、人工操作）会补记订单并标注来源
+// 单个币种拉取失败只记录日志，不影响其他币种
+func (at *AutoTrader) reconcileLedger() (int, error) {
+	if at.ledger == nil {
+		return 0, nil
+	}
+
+	lookback := at.now().Add(-ledgerInitialLookback)
+	since, err := at.ledger.GetLatestFillTime(at.id, "")
+	if err != nil {
+		return 0, err
+	}
+	if since.IsZero() || since.After(lookback) {
+		since = lookback
+	}
+
+	symbols, err := at.reconcileSymbols(since)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, symbol := range symbols {
+		n, err := at.reconcileSymbol(symbol, lookback)
+		added += n
+		if err != nil {
+			log.Printf("⚠️ [%s] %s 成交对账失败: %v", at.name, symbol, err)
+		}
+	}
+	return added, nil
+}
+
+// reconcileSymbol 拉取单个币种自其最近一笔成交以来的成交（没有成交时从 lookback 开始）
+func (at *AutoTrader) reconcileSymbol(symbol string, lookback time.Time) (int, error) {
+	since, err := at.ledger.GetLatestFillTime(at.id, symbol)
+	if err != nil {
+		return 0, err
+	}
+	if since.IsZero() {
+		since = lookback
+	}
+
+	fills, err := at.trader.GetFills(symbol, since)
+	if err != nil {
+		return 0, fmt.Errorf("获取 %s 成交失败: %w", symbol, err)
+	}
+
+	added := 0
+	orders := make(map[string]*config.LedgerOrder)
+	for _, fill := range fills {
+		order := at.fillOrder(fill, orders)
+		inserted, err := at.ledger.RecordFill(&config.LedgerFill{
+			TraderID:        at.id,
+			ExchangeTradeID: fill.TradeID,
+			ExchangeOrderID: fill.OrderID,
+			Symbol:          fill.Symbol,
+			Side:            fill.Side,
+			PositionSide:    fill.PositionSide,
+			Quantity:        fill.Quantity,
+			Price:           fill.Price,
+			Fee:             fill.Fee,
+			RealizedPnL:     fill.RealizedPnL,
+			ReduceOnly:      fill.ReduceOnly,
+			Origin:          order.Origin,
+			FillTime:        fill.Time,
+		})
+		if err != nil {
+			return added, err
+		}
+		if !inserted {
+			continue
+		}
+		added++
+
+		// 按成交量加权计算订单均价
+		totalQty := order.Quantity + fill.Quantity
+		order.Price = (order.Price*order.Quantity + fill.Price*fill.Quantity) / totalQty
+		order.Quantity = totalQty
+		order.Fee += fill.Fee
+	}
+
+	// 用实际成交更新订单的均价与手续费（系统外的订单在此补记）
+	for _, order := range orders {
+		if order.Quantity <= 0 {
+			continue
+		}
+		if err := at.ledger.RecordOrder(order); err != nil {
+			return added, err
+		}
+	}
+	return added, nil
+}
+
+// reconcileSymbols 需要对账的币种：当前持仓、交易币种以及账本中近期下过单的币种
+func (at *AutoTrader) reconcileSymbols(since time.Time) ([]string, error) {
+	seen := make(map[string]bool)
+	var symbols []string
+	add := func(symbol string) {
+		if symbol != "" && !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("获取持仓失败: %w", err)
+	}
+	for _, pos := range positions {
+		add(pos.Symbol)
+	}
+	for _, symbol := range at.tradingCoins {
+		add(symbol)
+	}
+
+	orders, err := at.ledger.GetLedgerOrders(at.id, since)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		add(order.Symbol)
+	}
+	return symbols, nil
+}
+
+// fillOrder 返回成交所属订单（同一批次内复用），并确定订单来源：
+// 账本中已有的订单沿用其来源，否则按订单类型判断为交易所条件单或人工操作
+func (at *AutoTrader) fillOrder(fill Fill, orders map[string]*config.LedgerOrder) *config.LedgerOrder {
+	if order, ok := orders[fill.OrderID]; ok {
+		return order
+	}
+
+	order := &config.LedgerOrder{
+		TraderID:        at.id,
+		ExchangeOrderID: fill.OrderID,
+		Symbol:          fill.Symbol,
+		Side:            fill.Side,
+		PositionSide:    fill.PositionSide,
+		OrderType:       fill.OrderType,
+		ReduceOnly:      fill.ReduceOnly,
+		Status:          "FILLED",
+		OrderTime:       fill.Time,
+	}
+	if order.OrderType == "" {
+		order.OrderType = "MARKET"
+	}
+
+	var existing *config.LedgerOrder
+	if fill.OrderID != "" {
+		existing, _ = at.ledger.GetLedgerOrder(at.id, fill.OrderID)
+	}
+	switch {
+	case existing != nil:
+		order.Origin = existing.Origin
+	case isStopOrderType(fill.OrderType):
+		order.Origin = config.OrderOriginStop
+	default:
+		order.Origin = config.OrderOriginManual
+	}
+
+	if fill.OrderID != "" {
+		orders[fill.OrderID] = order
+	}
+	return order
+}
+
+// isStopOrderType 是否为交易所侧触发的条件单或强平
+func isStopOrderType(orderType string) bool {
+	orderType = strings.ToUpper(orderType)
+	return strings.Contains(orderType, "STOP") ||
+		strings.Contains(orderType, "TAKE_PROFIT") ||
+		strings.Contains(orderType, "LIQUIDATION")
+}
+
+// AnalyzePerformance 交易表现分析
+// 优先根据订单/成交账本重建交易（覆盖交易所止损、持仓保护和人工平仓），账本没有已完成交易时退回决策日志配对
+// 夏普比率始终基于决策日志中的净值曲线
+func (at *AutoTrader) AnalyzePerformance(lookbackCycles int) (*logger.PerformanceAnalysis, error) {
+	logAnalysis, logErr := at.decisionLogger.AnalyzePerformance(lookbackCycles)
+	if at.ledger == nil {
+		return logAnalysis, logErr
+	}
+
+	trades, err := at.ledgerTrades()
+	if err != nil {
+		log.Printf("⚠️ [%s] 读取账本失败，使用决策日志统计: %v", at.name, err)
+		return logAnalysis, logErr
+	}
+	if len(trades) == 0 {
+		return logAnalysis, logErr
+	}
+
+	analysis := logger.SummarizeTrades(trades)
+	if logAnalysis != nil {
+		analysis.SharpeRatio = logAnalysis.SharpeRatio
+	}
+	return analysis, nil
+}
+
+// ledgerTrades 从账本重建已完成的交易
+// 优先使用对账成交，尚未对账出成交时使用系统下单记录
+func (at *AutoTrader) ledgerTrades() ([]logger.TradeOutcome, error) {
+	orders, err := at.ledger.GetLedgerOrders(at.id, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	fills, err := at.ledger.GetLedgerFills(at.id, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if len(fills) == 0 {
+		fills = ordersAsFills(orders)
+	}
+
+	leverages := make(map[string]int, len(orders))
+	for _, order := range orders {
+		if order.ExchangeOrderID != "" && order.Leverage > 0 {
+			leverages[order.ExchangeOrderID] = order.Leverage
+		}
+	}
+	return BuildLedgerTrades(fills, leverages), nil
+}
+
+// ordersAsFills 把下单记录视为成交（尚未对账出成交时使用）
+func ordersAsFills(orders []*config.LedgerOrder) []*config.LedgerFill {
+	fills := make([]*config.LedgerFill, 0, len(orders))
+	for _, order := range orders {
+		fills = append(fills, &config.LedgerFill{
+			TraderID:        order.TraderID,
+			ExchangeOrderID: order.ExchangeOrderID,
+			Symbol:          order.Symbol,
+			Side:            order.Side,
+			PositionSide:    order.PositionSide,
+			Quantity:        order.Quantity,
+			Price:           order.Price,
+			Fee:             order.Fee,
+			ReduceOnly:      order.ReduceOnly,
+			Origin:          order.Origin,
+			FillTime:        order.OrderTime,
+		})
+	}
+	return fills
+}
+
+// ledgerPosition 重建交易时的持仓状态
+type ledgerPosition struct {
+	quantity    float64 // 当前持仓数量
+	openedQty   float64 // 累计开仓数量
+	entryPrice  float64 // 加权开仓均价
+	openTime    time.Time
+	leverage    int
+	pnl         float64 // 累计已实现盈亏（扣除手续费）
+	closedQty   float64
+	closedValue float64
+	stopClosed  bool
+}
+
+// BuildLedgerTrades 按时间顺序回放成交重建交易：持仓从0开始到回到0算一笔完整交易
+// 平仓数量为0的记录表示全部平仓；窗口外开仓的持仓在平仓时忽略
+// leverages 为订单ID到开仓杠杆的映射，未知杠杆按1倍计算保证金
+func BuildLedgerTrades(fills []*config.LedgerFill, leverages map[string]int) []logger.TradeOutcome {
+	sorted := make([]*config.LedgerFill, len(fills))
+	copy(sorted, fills)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].FillTime.Before(sorted[j].FillTime) })
+
+	positions := make(map[string]*ledgerPosition)
+	var trades []logger.TradeOutcome
+
+	for _, fill := range sorted {
+		side := strings.ToLower(fill.PositionSide)
+		if side != "long" && side != "short" {
+			continue
+		}
+		posKey := fill.Symbol + "_" + side
+
+		if !fill.ReduceOnly {
+			if fill.Quantity <= 0 {
+				continue
+			}
+			pos, ok := positions[posKey]
+			if !ok {
+				pos = &ledgerPosition{openTime: fill.FillTime}
+				positions[posKey] = pos
+			}
+			pos.entryPrice = (pos.entryPrice*pos.quantity + fill.Price*fill.Quantity) / (pos.quantity + fill.Quantity)
+			pos.quantity += fill.Quantity
+			pos.openedQty += fill.Quantity
+			pos.pnl -= fill.Fee
+			if lev := leverages[fill.ExchangeOrderID]; lev > 0 {
+				pos.leverage = lev
+			}
+			continue
+		}
+
+		pos, ok := positions[posKey]
+		if !ok {
+			continue
+		}
+		qty := fill.Quantity
+		if qty <= 0 || qty > pos.quantity {
+			qty = pos.quantity
+		}
+		if side == "long" {
+			pos.pnl += (fill.Price - pos.entryPrice) * qty
+		} else {
+			pos.pnl += (pos.entryPrice - fill.Price) * qty
+		}
+		pos.pnl -= fill.Fee
+		pos.quantity -= qty
+		pos.closedQty += qty
+		pos.closedValue += fill.Price * qty
+		if fill.Origin == config.OrderOriginStop {
+			pos.stopClosed = true
+		}
+
+		if pos.quantity > pos.openedQty*1e-6 {
+			continue
+		}
+
+		leverage := pos.leverage
+		if leverage <= 0 {
+			leverage = 1
+		}
+		positionValue := pos.openedQty * pos.entryPrice
+		marginUsed := positionValue / float64(leverage)
+		pnlPct := 0.0
+		if marginUsed > 0 {
+			pnlPct = pos.pnl / marginUsed * 100
+		}
+		trades = append(trades, logger.TradeOutcome{
+			Symbol:        fill.Symbol,
+			Side:          side,
+			Quantity:      pos.openedQty,
+			Leverage:      leverage,
+			OpenPrice:     pos.entryPrice,
+			ClosePrice:    pos.closedValue / math.Max(pos.closedQty, 1e-12),
+			PositionValue: positionValue,
+			MarginUsed:    marginUsed,
+			PnL:           pos.pnl,
+			PnLPct:        pnlPct,
+			Duration:      fill.FillTime.Sub(pos.openTime).String(),
+			OpenTime:      pos.openTime,
+			CloseTime:     fill.FillTime,
+			WasStopLoss:   pos.stopClosed && pos.pnl < 0,
+		})
+		delete(positions, posKey)
+	}
+	return trades
+}
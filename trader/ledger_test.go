@@ -0,0 +1,170 @@
+package trader
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nofx/config"
+	"nofx/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildLedgerTrades(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	fill := func(minute int, orderID, symbol, positionSide string, reduceOnly bool, qty, price, fee float64, origin string) *config.LedgerFill {
+		return &config.LedgerFill{
+			ExchangeOrderID: orderID,
+			Symbol:          symbol,
+			PositionSide:    positionSide,
+			ReduceOnly:      reduceOnly,
+			Quantity:        qty,
+			Price:           price,
+			Fee:             fee,
+			Origin:          origin,
+			FillTime:        base.Add(time.Duration(minute) * time.Minute),
+		}
+	}
+
+	fills := []*config.LedgerFill{
+		// 窗口外开仓的空仓平仓，无法配对，忽略
+		fill(0, "0", "ETHUSDT", "SHORT", true, 1, 3000, 1, config.OrderOriginManual),
+		// BTC 多仓：两次开仓、AI部分平仓、交易所止损平掉剩余
+		fill(1, "1", "BTCUSDT", "LONG", false, 1, 100, 0.1, config.OrderOriginAI),
+		fill(2, "1", "BTCUSDT", "LONG", false, 1, 110, 0.1, config.OrderOriginAI),
+		fill(3, "2", "BTCUSDT", "LONG", true, 1, 120, 0.1, config.OrderOriginAI),
+		fill(4, "3", "BTCUSDT", "LONG", true, 1, 90, 0.1, config.OrderOriginStop),
+		// SOL 空仓：持仓保护全部平仓（数量未知记为0）
+		fill(5, "4", "SOLUSDT", "SHORT", false, 10, 20, 0, config.OrderOriginAI),
+		fill(6, "5", "SOLUSDT", "SHORT", true, 0, 18, 0, config.OrderOriginMonitor),
+	}
+
+	trades := BuildLedgerTrades(fills, map[string]int{"1": 5})
+	require.Len(t, trades, 2)
+
+	btc := trades[0]
+	assert.Equal(t, "BTCUSDT", btc.Symbol)
+	assert.Equal(t, "long", btc.Side)
+	assert.Equal(t, 2.0, btc.Quantity)
+	assert.Equal(t, 5, btc.Leverage)
+	assert.InDelta(t, 105, btc.OpenPrice, 1e-9)
+	assert.InDelta(t, 105, btc.ClosePrice, 1e-9)
+	// (120-105) + (90-105) - 4*0.1 手续费
+	assert.InDelta(t, -0.4, btc.PnL, 1e-9)
+	assert.InDelta(t, -0.4/42*100, btc.PnLPct, 1e-9)
+	assert.True(t, btc.WasStopLoss)
+	assert.Equal(t, base.Add(time.Minute), btc.OpenTime)
+	assert.Equal(t, base.Add(4*time.Minute), btc.CloseTime)
+
+	sol := trades[1]
+	assert.Equal(t, "short", sol.Side)
+	assert.Equal(t, 1, sol.Leverage, "未知杠杆按1倍计算")
+	assert.InDelta(t, 20, sol.PnL, 1e-9)
+	assert.False(t, sol.WasStopLoss)
+
+	summary := logger.SummarizeTrades(trades)
+	assert.Equal(t, 2, summary.TotalTrades)
+	assert.Equal(t, 1, summary.WinningTrades)
+	assert.Equal(t, "SOLUSDT", summary.BestSymbol)
+	assert.Equal(t, "SOLUSDT", summary.RecentTrades[0].Symbol, "最新的交易在前")
+}
+
+func TestAutoTrader_LedgerReconcile(t *testing.T) {
+	db, err := config.NewDatabase(filepath.Join(t.TempDir(), "config.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+	pt := newTestPaperTrader(t, feed, "")
+	at := &AutoTrader{
+		id:             "trader-1",
+		trader:         pt,
+		ledger:         db,
+		decisionLogger: logger.NewDecisionLogger(t.TempDir()),
+		tradingCoins:   []string{"BTCUSDT"},
+	}
+
+	// AI开仓写入账本，随后被交易所侧止损平仓（系统没有下这笔平仓单）
+	order, err := pt.OpenLong("BTCUSDT", 0.1, 10)
+	require.NoError(t, err)
+	at.recordOrder(&config.LedgerOrder{Symbol: "BTCUSDT", PositionSide: "LONG", Quantity: 0.1, Price: 50000,
+		Leverage: 10, Origin: config.OrderOriginAI}, order)
+	require.NoError(t, pt.SetStopLoss("BTCUSDT", "LONG", 0.1, 49000))
+	pt.OnPrice("BTCUSDT", 48900)
+
+	added, err := at.reconcileLedger()
+	require.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	added, err = at.reconcileLedger()
+	require.NoError(t, err)
+	assert.Zero(t, added, "重复对账不应重复写入")
+
+	fills, err := db.GetLedgerFills("trader-1", time.Time{})
+	require.NoError(t, err)
+	require.Len(t, fills, 2)
+	assert.Equal(t, config.OrderOriginAI, fills[0].Origin)
+	assert.Equal(t, config.OrderOriginStop, fills[1].Origin)
+	assert.True(t, fills[1].ReduceOnly)
+
+	orders, err := db.GetLedgerOrders("trader-1", time.Time{})
+	require.NoError(t, err)
+	require.Len(t, orders, 2, "交易所止损单应补记到订单账本")
+	assert.Equal(t, "STOP_MARKET", orders[1].OrderType)
+
+	perf, err := at.AnalyzePerformance(100)
+	require.NoError(t, err)
+	require.Equal(t, 1, perf.TotalTrades, "决策日志中没有平仓记录，交易来自账本")
+	assert.Equal(t, 1, perf.LosingTrades)
+	assert.True(t, perf.RecentTrades[0].WasStopLoss)
+	assert.Equal(t, 10, perf.RecentTrades[0].Leverage)
+}
+
+// fillsTrader 记录各币种的成交查询起点，并让指定币种查询失败
+type fillsTrader struct {
+	Trader
+	failSymbol string
+	since      map[string]time.Time
+}
+
+func (f *fillsTrader) GetFills(symbol string, since time.Time) ([]Fill, error) {
+	f.since[symbol] = since
+	if symbol == f.failSymbol {
+		return nil, fmt.Errorf("rate limited")
+	}
+	return f.Trader.GetFills(symbol, since)
+}
+
+func TestAutoTrader_LedgerReconcilePerSymbol(t *testing.T) {
+	db, err := config.NewDatabase(filepath.Join(t.TempDir(), "config.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	pt := newTestPaperTrader(t, paperPriceFeed{"BTCUSDT": 50000}, "")
+	ft := &fillsTrader{Trader: pt, failSymbol: "SOLUSDT", since: make(map[string]time.Time)}
+	at := &AutoTrader{
+		id:             "trader-1",
+		trader:         ft,
+		ledger:         db,
+		decisionLogger: logger.NewDecisionLogger(t.TempDir()),
+		tradingCoins:   []string{"SOLUSDT", "ETHUSDT", "BTCUSDT"},
+	}
+
+	// ETH 已对账到一小时前；BTC 在此之后才有成交，不能被 ETH 的游标跳过
+	ethFill := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	_, err = db.RecordFill(&config.LedgerFill{TraderID: "trader-1", ExchangeTradeID: "e1", ExchangeOrderID: "9",
+		Symbol: "ETHUSDT", Side: "BUY", PositionSide: "LONG", Quantity: 1, Price: 3000,
+		Origin: config.OrderOriginAI, FillTime: ethFill})
+	require.NoError(t, err)
+	_, err = pt.OpenLong("BTCUSDT", 0.1, 10)
+	require.NoError(t, err)
+
+	added, err := at.reconcileLedger()
+	require.NoError(t, err, "单个币种失败不应中断对账")
+	assert.Equal(t, 1, added, "SOL 失败后仍应对账 BTC")
+	assert.True(t, ft.since["ETHUSDT"].Equal(ethFill))
+	assert.WithinDuration(t, time.Now().Add(-ledgerInitialLookback), ft.since["BTCUSDT"], time.Minute)
+}
@@ -0,0 +1,122 @@
+package trader
+
+import (
+	"log"
+	"nofx/decision"
+	"nofx/market"
+	"time"
+)
+
+// alertCoinWindow 候选币种只考虑该时间窗口内触发的警报
+const alertCoinWindow = time.Hour
+
+// alertCoinLimit 警报来源最多加入的候选币种数量（按活跃度评分）
+const alertCoinLimit = 10
+
+// AlertStream 市场警报源（通常为 market.WSMonitorCli）
+type AlertStream interface {
+	// SubscribeAlerts 订阅警报，返回警报通道和取消订阅函数
+	SubscribeAlerts(bufferSize int) (<-chan market.Alert, func())
+	// RecentAlerts 返回 since 之后的警报
+	RecentAlerts(since time.Time) []market.Alert
+	// AlertSymbols 返回 since 之后触发过警报的币种（按活跃度评分排序）
+	AlertSymbols(since time.Time, limit int) []string
+}
+
+// SetAlertStream 设置市场警报源（默认：使用币安行情时为 market.WSMonitorCli）
+func (at *AutoTrader) SetAlertStream(stream AlertStream) {
+	at.alertStream = stream
+}
+
+// resolveAlertStream 返回可用的市场警报源，没有时返回nil
+// market.WSMonitorCli 的警报基于币安行情，其他交易所的交易员不使用
+func (at *AutoTrader) resolveAlertStream() AlertStream {
+	if at.alertStream != nil {
+		return at.alertStream
+	}
+	if at.usesBinanceMarket() && market.WSMonitorCli != nil {
+		return market.WSMonitorCli
+	}
+	return nil
+}
+
+// subscribeAlerts 启用警报唤醒或成交量突增触发时订阅警报；未启用或没有警报源时返回nil通道
+func (at *AutoTrader) subscribeAlerts() (<-chan market.Alert, func()) {
+	if !at.config.AlertWakeup && !at.config.Triggers.VolumeSpike {
+		return nil, func() {}
+	}
+	stream := at.resolveAlertStream()
+	if stream == nil {
+		if !at.usesBinanceMarket() {
+			log.Printf("⚠️ [%s] 市场警报基于币安行情，%s 交易员不使用，警报触发未启用", at.name, at.marketProvider.Name())
+		} else {
+			log.Printf("⚠️ [%s] 没有可用的市场警报源，警报触发未启用", at.name)
+		}
+		return nil, func() {}
+	}
+	return stream.SubscribeAlerts(256)
+}
+
+// alertRelevant 警报币种属于候选或持仓（或启用了警报候选币种）时才需要提前运行周期
+func (at *AutoTrader) alertRelevant(alert market.Alert) bool {
+	return at.config.UseAlertCoins || at.isWatched(alert.Symbol)
+}
+
+// appendAlertCoins 为候选币种附上近期警报，启用警报候选时把高分警报币种加入候选（来源标记为 alert）
+func (at *AutoTrader) appendAlertCoins(coins []decision.CandidateCoin) []decision.CandidateCoin {
+	if !at.config.UseAlertCoins && !at.config.AlertWakeup {
+		return coins
+	}
+	stream := at.resolveAlertStream()
+	if stream == nil {
+		return coins
+	}
+
+	since := at.now().Add(-alertCoinWindow)
+	messages := make(map[string][]string)
+	for _, alert := range stream.RecentAlerts(since) {
+		messages[alert.Symbol] = append(messages[alert.Symbol], alert.Message)
+	}
+
+	index := make(map[string]int, len(coins))
+	for i := range coins {
+		index[coins[i].Symbol] = i
+		coins[i].Alerts = messages[coins[i].Symbol]
+	}
+	if !at.config.UseAlertCoins {
+		return coins
+	}
+
+	added := 0
+	for _, symbol := range stream.AlertSymbols(since, alertCoinLimit) {
+		if i, ok := index[symbol]; ok {
+			coins[i].Sources = append(coins[i].Sources, "alert")
+			continue
+		}
+		coins = append(coins, decision.CandidateCoin{
+			Symbol:  symbol,
+			Sources: []string{"alert"},
+			Alerts:  messages[symbol],
+		})
+		added++
+	}
+	if added > 0 {
+		log.Printf("🚨 [%s] 加入 %d 个近期触发警报的候选币种", at.name, added)
+	}
+	return coins
+}
+
+// updateWatchedSymbols 记录本周期的候选与持仓币种（事件触发只关注这些币种）
+func (at *AutoTrader) updateWatchedSymbols(coins []decision.CandidateCoin, positions []decision.PositionInfo) {
+	watched := make(map[string]bool, len(coins)+len(positions))
+	for _, coin := range coins {
+		watched[coin.Symbol] = true
+	}
+	for _, pos := range positions {
+		watched[pos.Symbol] = true
+	}
+
+	at.watchedMu.Lock()
+	at.watchedSymbols = watched
+	at.watchedMu.Unlock()
+}
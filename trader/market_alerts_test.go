@@ -0,0 +1,93 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"nofx/decision"
+	"nofx/market"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAlertStream 固定返回的警报源
+type fakeAlertStream struct {
+	alerts  []market.Alert
+	symbols []string
+	ch      chan market.Alert
+}
+
+func (f *fakeAlertStream) SubscribeAlerts(bufferSize int) (<-chan market.Alert, func()) {
+	return f.ch, func() {}
+}
+
+func (f *fakeAlertStream) RecentAlerts(since time.Time) []market.Alert {
+	return f.alerts
+}
+
+func (f *fakeAlertStream) AlertSymbols(since time.Time, limit int) []string {
+	return f.symbols
+}
+
+func TestAutoTrader_AppendAlertCoins(t *testing.T) {
+	stream := &fakeAlertStream{
+		alerts: []market.Alert{
+			{Symbol: "BTCUSDT", Type: market.AlertVolumeSpike, Message: "BTCUSDT 成交量突增 4.0 倍"},
+			{Symbol: "PEPEUSDT", Type: market.AlertPriceChange, Message: "PEPEUSDT 15分钟价格变化 +6.00%"},
+		},
+		symbols: []string{"PEPEUSDT", "BTCUSDT"},
+	}
+	base := func() []decision.CandidateCoin {
+		return []decision.CandidateCoin{{Symbol: "BTCUSDT", Sources: []string{"custom"}}}
+	}
+	at := &AutoTrader{alertStream: stream}
+
+	// 未启用：原样返回
+	assert.Equal(t, base(), at.appendAlertCoins(base()))
+
+	// 仅启用唤醒：附上警报内容，不增加候选
+	at.config.AlertWakeup = true
+	coins := at.appendAlertCoins(base())
+	require.Len(t, coins, 1)
+	assert.Equal(t, []string{"BTCUSDT 成交量突增 4.0 倍"}, coins[0].Alerts)
+	assert.Equal(t, []string{"custom"}, coins[0].Sources)
+
+	// 启用警报候选：已有币种追加来源，新币种加入候选
+	at.config.UseAlertCoins = true
+	coins = at.appendAlertCoins(base())
+	require.Len(t, coins, 2)
+	assert.Equal(t, []string{"custom", "alert"}, coins[0].Sources)
+	assert.Equal(t, "PEPEUSDT", coins[1].Symbol)
+	assert.Equal(t, []string{"alert"}, coins[1].Sources)
+	assert.Equal(t, []string{"PEPEUSDT 15分钟价格变化 +6.00%"}, coins[1].Alerts)
+}
+
+func TestAutoTrader_AlertTrigger(t *testing.T) {
+	at := &AutoTrader{config: AutoTraderConfig{AlertWakeup: true}}
+	at.updateWatchedSymbols(
+		[]decision.CandidateCoin{{Symbol: "BTCUSDT"}},
+		[]decision.PositionInfo{{Symbol: "SOLUSDT"}},
+	)
+
+	trigger, ok := at.alertTrigger(market.Alert{Symbol: "BTCUSDT", Type: market.AlertPriceChange, Message: "候选"})
+	assert.True(t, ok, "候选币种")
+	assert.Equal(t, TriggerMarketAlert, trigger.Type)
+	assert.Equal(t, "候选", trigger.Reason)
+	_, ok = at.alertTrigger(market.Alert{Symbol: "SOLUSDT"})
+	assert.True(t, ok, "持仓币种")
+	_, ok = at.alertTrigger(market.Alert{Symbol: "DOGEUSDT"})
+	assert.False(t, ok, "无关币种")
+
+	at.config.UseAlertCoins = true
+	_, ok = at.alertTrigger(market.Alert{Symbol: "DOGEUSDT"})
+	assert.True(t, ok, "警报币种可加入候选")
+
+	// 只启用成交量突增触发：其他类型的警报不触发
+	at.config = AutoTraderConfig{Triggers: TriggerConfig{VolumeSpike: true}}
+	trigger, ok = at.alertTrigger(market.Alert{Symbol: "BTCUSDT", Type: market.AlertVolumeSpike})
+	assert.True(t, ok)
+	assert.Equal(t, TriggerVolumeSpike, trigger.Type)
+	_, ok = at.alertTrigger(market.Alert{Symbol: "BTCUSDT", Type: market.AlertRSIOverbought})
+	assert.False(t, ok)
+}
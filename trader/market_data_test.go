@@ -0,0 +1,46 @@
+package trader
+
+import (
+	"testing"
+
+	"nofx/decision"
+	"nofx/market"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listedProvider 只返回上线币种列表的行情源
+type listedProvider struct {
+	symbols []string
+}
+
+func (p *listedProvider) Name() string { return "fake" }
+
+func (p *listedProvider) GetKlines(symbol, interval string, limit int) ([]market.Kline, error) {
+	return nil, nil
+}
+
+func (p *listedProvider) GetFundingRate(symbol string) (float64, error) { return 0, nil }
+
+func (p *listedProvider) GetOpenInterest(symbol string) (*market.OIData, error) { return nil, nil }
+
+func (p *listedProvider) Symbols() ([]string, error) { return p.symbols, nil }
+
+func TestAutoTrader_FilterUnlistedCoins(t *testing.T) {
+	at := &AutoTrader{name: "test", marketProvider: &listedProvider{symbols: []string{"BTCUSDT", "ETHUSDT"}}}
+	coins := []decision.CandidateCoin{{Symbol: "BTCUSDT"}, {Symbol: "PEPEUSDT"}, {Symbol: "ETHUSDT"}}
+
+	filtered := at.filterUnlistedCoins(coins)
+	assert.Equal(t, []decision.CandidateCoin{{Symbol: "BTCUSDT"}, {Symbol: "ETHUSDT"}}, filtered)
+
+	// 回测注入数据源时不检查上线状态
+	at.marketDataFunc = func(symbol string) (*market.Data, error) { return nil, nil }
+	coins = []decision.CandidateCoin{{Symbol: "PEPEUSDT"}}
+	assert.Equal(t, coins, at.filterUnlistedCoins(coins))
+}
+
+func TestNewProviderForExchange(t *testing.T) {
+	assert.Equal(t, market.ProviderHyperliquid, market.NewProvider("hyperliquid", true).Name())
+	assert.Equal(t, market.ProviderAster, market.NewProvider("aster", false).Name())
+	assert.Equal(t, market.ProviderBinance, market.NewProvider("paper", false).Name())
+}
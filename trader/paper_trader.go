@@ -0,0 +1,1097 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/logger"
+	"nofx/market"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPaperFeeRate 默认模拟手续费率（吃单 0.04%）
+	DefaultPaperFeeRate = 0.0004
+	// DefaultPaperSlippage 默认模拟滑点（0.05%）
+	DefaultPaperSlippage = 0.0005
+	// paperMaintenanceMarginRate 维持保证金率（用于计算强平价）
+	paperMaintenanceMarginRate = 0.005
+)
+
+// PaperPriceFunc 模拟盘价格来源（返回标记价格）
+type PaperPriceFunc func(symbol string) (float64, error)
+
+// PaperTraderConfig 模拟盘配置
+type PaperTraderConfig struct {
+	InitialBalance float64          // 初始资金（USDT）
+	FeeRate        float64          // 手续费率（如 0.0004 = 0.04%）
+	Slippage       float64          // 滑点比例（如 0.0005 = 0.05%）
+	StateFile      string           // 状态持久化文件路径（为空则不持久化）
+	PriceFunc      PaperPriceFunc   // 价格来源（为空则使用币安标记价格）
+	Clock          func() time.Time // 时间来源（为空则使用当前时间，回测时传入模拟时钟）
+}
+
+// paperPosition 模拟持仓
+type paperPosition struct {
+	Symbol        string  `json:"symbol"`
+	Side          string  `json:"side"` // "long" / "short"
+	Quantity      float64 `json:"quantity"`
+	EntryPrice    float64 `json:"entry_price"`
+	MarkPrice     float64 `json:"mark_price"`
+	Leverage      int     `json:"leverage"`
+	Margin        float64 `json:"margin"` // 占用的初始保证金
+	IsCrossMargin bool    `json:"is_cross_margin"`
+	OpenTime      int64   `json:"open_time"`
+}
+
+// paperOrder 模拟条件单（止损/止盈）
+type paperOrder struct {
+	OrderID      int64   `json:"order_id"`
+	Symbol       string  `json:"symbol"`
+	PositionSide string  `json:"position_side"` // "LONG" / "SHORT"
+	Type         string  `json:"type"`          // "STOP_MARKET" / "TAKE_PROFIT_MARKET"
+	Quantity     float64 `json:"quantity"`
+	TriggerPrice float64 `json:"trigger_price"`
+	CreateTime   int64   `json:"create_time"`
+}
+
+// paperLimitOrder 模拟限价开仓挂单
+type paperLimitOrder struct {
+	OrderID    int64   `json:"order_id"`
+	Symbol     string  `json:"symbol"`
+	Side       string  `json:"side"` // "long" / "short"
+	Price      float64 `json:"price"`
+	Quantity   float64 `json:"quantity"`
+	Leverage   int     `json:"leverage"`
+	PostOnly   bool    `json:"post_only"`
+	CreateTime int64   `json:"create_time"`
+}
+
+// paperFill 模拟盘成交记录（市价单与条件单均一次成交，成交ID与订单ID相同）
+type paperFill struct {
+	OrderID      int64   `json:"order_id"`
+	Symbol       string  `json:"symbol"`
+	PositionSide string  `json:"position_side"` // "LONG" / "SHORT"
+	OrderType    string  `json:"order_type"`    // "MARKET" / "LIMIT" / "STOP_MARKET" / "TAKE_PROFIT_MARKET" / "LIQUIDATION"
+	Price        float64 `json:"price"`
+	Quantity     float64 `json:"quantity"`
+	Fee          float64 `json:"fee"`
+	RealizedPnL  float64 `json:"realized_pnl"`
+	ReduceOnly   bool    `json:"reduce_only"`
+	Time         int64   `json:"time"`
+}
+
+// paperMaxFills 状态文件中最多保留的成交记录数
+const paperMaxFills = 1000
+
+// paperState 模拟盘持久化状态
+type paperState struct {
+	WalletBalance float64                   `json:"wallet_balance"`
+	RealizedPnL   float64                   `json:"realized_pnl"`
+	TotalFees     float64                   `json:"total_fees"`
+	Positions     map[string]*paperPosition `json:"positions"` // key: symbol_side
+	Orders        []*paperOrder             `json:"orders"`
+	LimitOrders   []*paperLimitOrder        `json:"limit_orders"`
+	Fills         []*paperFill              `json:"fills"`
+	Leverage      map[string]int            `json:"leverage"`
+	MarginMode    map[string]bool           `json:"margin_mode"` // true=全仓
+	NextOrderID   int64                     `json:"next_order_id"`
+	UpdatedAt     time.Time                 `json:"updated_at"`
+}
+
+// PaperTrader 模拟盘交易器
+// 以标记价格成交（叠加滑点与手续费），在本地撮合止损止盈和强平，状态落盘以便重启后恢复
+type PaperTrader struct {
+	feeRate   float64
+	slippage  float64
+	stateFile string
+	priceFunc PaperPriceFunc
+	clock     func() time.Time // 成交、持仓、挂单与状态的时间戳来源
+
+	mu    sync.Mutex
+	state *paperState
+}
+
+// NewPaperTrader 创建模拟盘交易器
+func NewPaperTrader(cfg PaperTraderConfig) (*PaperTrader, error) {
+	if cfg.FeeRate < 0 || cfg.Slippage < 0 {
+		return nil, fmt.Errorf("手续费率和滑点不能为负数")
+	}
+
+	priceFunc := cfg.PriceFunc
+	if priceFunc == nil {
+		priceFunc = market.NewAPIClient().GetMarkPrice
+	}
+
+	clock := cfg.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+
+	t := &PaperTrader{
+		feeRate:   cfg.FeeRate,
+		slippage:  cfg.Slippage,
+		stateFile: cfg.StateFile,
+		priceFunc: priceFunc,
+		clock:     clock,
+	}
+
+	loaded, err := t.loadState()
+	if err != nil {
+		return nil, err
+	}
+	if loaded {
+		log.Printf("📄 模拟盘状态已恢复: 余额=%.2f, 持仓=%d, 挂单=%d",
+			t.state.WalletBalance, len(t.state.Positions), len(t.state.Orders))
+		return t, nil
+	}
+
+	if cfg.InitialBalance <= 0 {
+		return nil, fmt.Errorf("模拟盘初始资金必须大于0")
+	}
+	t.state = newPaperState(cfg.InitialBalance)
+	if err := t.saveState(); err != nil {
+		return nil, err
+	}
+	log.Printf("📄 模拟盘已创建: 初始资金=%.2f USDT, 手续费率=%.4f%%, 滑点=%.4f%%",
+		cfg.InitialBalance, cfg.FeeRate*100, cfg.Slippage*100)
+	return t, nil
+}
+
+// newPaperState 创建空白状态
+func newPaperState(balance float64) *paperState {
+	return &paperState{
+		WalletBalance: balance,
+		Positions:     make(map[string]*paperPosition),
+		Orders:        []*paperOrder{},
+		LimitOrders:   []*paperLimitOrder{},
+		Fills:         []*paperFill{},
+		Leverage:      make(map[string]int),
+		MarginMode:    make(map[string]bool),
+		NextOrderID:   1,
+	}
+}
+
+// loadState 从磁盘加载状态（文件不存在时返回false）
+func (t *PaperTrader) loadState() (bool, error) {
+	if t.stateFile == "" {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(t.stateFile)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("读取模拟盘状态失败: %w", err)
+	}
+
+	state := newPaperState(0)
+	if err := json.Unmarshal(data, state); err != nil {
+		return false, fmt.Errorf("解析模拟盘状态失败: %w", err)
+	}
+	if state.Positions == nil {
+		state.Positions = make(map[string]*paperPosition)
+	}
+	if state.Leverage == nil {
+		state.Leverage = make(map[string]int)
+	}
+	if state.MarginMode == nil {
+		state.MarginMode = make(map[string]bool)
+	}
+	if state.NextOrderID <= 0 {
+		state.NextOrderID = 1
+	}
+
+	t.state = state
+	return true, nil
+}
+
+// saveState 持久化状态
+func (t *PaperTrader) saveState() error {
+	if t.stateFile == "" {
+		return nil
+	}
+
+	t.state.UpdatedAt = t.clock()
+	data, err := json.MarshalIndent(t.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化模拟盘状态失败: %w", err)
+	}
+
+	if err := logger.WriteFileAtomic(t.stateFile, data); err != nil {
+		return fmt.Errorf("写入模拟盘状态失败: %w", err)
+	}
+	return nil
+}
+
+// persist 持久化状态，失败时仅记录日志（不影响撮合结果）
+func (t *PaperTrader) persist() {
+	if err := t.saveState(); err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+}
+
+// paperPositionKey 持仓键
+func paperPositionKey(symbol, side string) string {
+	return symbol + "_" + side
+}
+
+// fetchPrice 获取最新标记价格，并用其撮合该币种的条件单和强平
+// 调用方需持有锁
+func (t *PaperTrader) fetchPrice(symbol string) (float64, error) {
+	price, err := t.priceFunc(symbol)
+	if err != nil {
+		return 0, fmt.Errorf("获取价格失败: %w", err)
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("%s 价格无效: %.8f", symbol, price)
+	}
+	t.applyPrice(symbol, price)
+	return price, nil
+}
+
+// OnPrice 推送最新价格（用于行情流或回测驱动），触发止损止盈和强平检查
+func (t *PaperTrader) OnPrice(symbol string, price float64) {
+	if price <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.applyPrice(symbol, price)
+}
+
+// applyPrice 更新标记价格并撮合限价挂单、条件单与强平（调用方需持有锁）
+func (t *PaperTrader) applyPrice(symbol string, price float64) {
+	changed := t.matchLimitOrders(symbol, price)
+	for _, side := range []string{"long", "short"} {
+		pos, ok := t.state.Positions[paperPositionKey(symbol, side)]
+		if !ok {
+			continue
+		}
+		pos.MarkPrice = price
+
+		// 1. 强平检查
+		liqPrice := t.liquidationPrice(pos)
+		if liqPrice > 0 && ((side == "long" && price <= liqPrice) || (side == "short" && price >= liqPrice)) {
+			log.Printf("💥 模拟盘强平: %s %s 标记价格=%.4f 强平价=%.4f", symbol, side, price, liqPrice)
+			quantity := pos.Quantity
+			realized, fee := t.closePosition(pos, quantity, liqPrice)
+			t.recordFill(t.nextOrderID(), symbol, side, "LIQUIDATION", liqPrice, quantity, fee, realized, true)
+			t.removeOrders(symbol, side)
+			changed = true
+			continue
+		}
+
+		// 2. 止损止盈检查（止损优先）
+		if order := t.triggeredOrder(pos, price); order != nil {
+			fillPrice := t.applySlippage(price, side, false)
+			log.Printf("🎯 模拟盘条件单触发: %s %s %s 触发价=%.4f 成交价=%.4f",
+				symbol, side, order.Type, order.TriggerPrice, fillPrice)
+			// 与交易所的 ClosePosition 语义保持一致：触发后平掉整个仓位
+			quantity := pos.Quantity
+			realized, fee := t.closePosition(pos, quantity, fillPrice)
+			t.recordFill(order.OrderID, symbol, side, order.Type, fillPrice, quantity, fee, realized, true)
+			t.removeOrders(symbol, side)
+			changed = true
+		}
+	}
+
+	if changed {
+		t.persist()
+	}
+}
+
+// matchLimitOrders 撮合该币种的限价开仓挂单，返回是否有挂单成交或被撤销
+// 挂单按限价成交（maker，不计滑点）；成交时保证金不足则撤单
+func (t *PaperTrader) matchLimitOrders(symbol string, price float64) bool {
+	changed := false
+	kept := t.state.LimitOrders[:0]
+	for _, order := range t.state.LimitOrders {
+		if order.Symbol != symbol || !limitCrossed(order.Side, order.Price, price) {
+			kept = append(kept, order)
+			continue
+		}
+		changed = true
+
+		margin, fee, err := t.openMargin(order.Quantity, order.Leverage, order.Price)
+		if err != nil {
+			log.Printf("⚠️ 模拟盘限价单 %d 成交失败，已撤单: %v", order.OrderID, err)
+			continue
+		}
+		t.addPosition(symbol, order.Side, order.Quantity, order.Leverage, order.Price, price, margin, fee)
+		t.recordFill(order.OrderID, symbol, order.Side, "LIMIT", order.Price, order.Quantity, fee, 0, false)
+		log.Printf("🎯 模拟盘限价单成交: %s 开%s 数量: %.6f 成交价: %.4f 手续费: %.4f",
+			symbol, sideName(order.Side), order.Quantity, order.Price, fee)
+	}
+	t.state.LimitOrders = kept
+	return changed
+}
+
+// limitCrossed 限价开仓单在当前价格下是否可成交（开多价格不高于限价，开空价格不低于限价）
+func limitCrossed(side string, limitPrice, price float64) bool {
+	if side == "long" {
+		return price <= limitPrice
+	}
+	return price >= limitPrice
+}
+
+// triggeredOrder 返回被当前价格触发的条件单（止损优先于止盈）
+func (t *PaperTrader) triggeredOrder(pos *paperPosition, price float64) *paperOrder {
+	var takeProfit *paperOrder
+	for _, order := range t.state.Orders {
+		if order.Symbol != pos.Symbol || order.PositionSide != sideToPositionSide(pos.Side) {
+			continue
+		}
+		isLong := pos.Side == "long"
+		switch order.Type {
+		case "STOP_MARKET":
+			if (isLong && price <= order.TriggerPrice) || (!isLong && price >= order.TriggerPrice) {
+				return order
+			}
+		case "TAKE_PROFIT_MARKET":
+			if (isLong && price >= order.TriggerPrice) || (!isLong && price <= order.TriggerPrice) {
+				takeProfit = order
+			}
+		}
+	}
+	return takeProfit
+}
+
+// sideToPositionSide "long" -> "LONG"
+func sideToPositionSide(side string) string {
+	if side == "long" {
+		return "LONG"
+	}
+	return "SHORT"
+}
+
+// applySlippage 按方向叠加滑点（开多/平空买入价更高，开空/平多卖出价更低）
+func (t *PaperTrader) applySlippage(price float64, side string, isOpen bool) float64 {
+	buy := (side == "long") == isOpen
+	if buy {
+		return price * (1 + t.slippage)
+	}
+	return price * (1 - t.slippage)
+}
+
+// unrealizedPnL 未实现盈亏
+func unrealizedPnL(pos *paperPosition) float64 {
+	if pos.Side == "long" {
+		return (pos.MarkPrice - pos.EntryPrice) * pos.Quantity
+	}
+	return (pos.EntryPrice - pos.MarkPrice) * pos.Quantity
+}
+
+// liquidationPrice 计算强平价格
+// 逐仓：仅以该仓位保证金为缓冲；全仓：以账户净值（扣除其他仓位维持保证金）为缓冲
+func (t *PaperTrader) liquidationPrice(pos *paperPosition) float64 {
+	if pos.Quantity <= 0 {
+		return 0
+	}
+
+	maintenance := pos.EntryPrice * pos.Quantity * paperMaintenanceMarginRate
+	buffer := pos.Margin - maintenance
+	if pos.IsCrossMargin {
+		buffer = t.state.WalletBalance - maintenance
+		for key, other := range t.state.Positions {
+			if key == paperPositionKey(pos.Symbol, pos.Side) {
+				continue
+			}
+			if other.IsCrossMargin {
+				buffer += unrealizedPnL(other) - other.EntryPrice*other.Quantity*paperMaintenanceMarginRate
+			} else {
+				buffer -= other.Margin
+			}
+		}
+	}
+
+	var liq float64
+	if pos.Side == "long" {
+		liq = pos.EntryPrice - buffer/pos.Quantity
+	} else {
+		liq = pos.EntryPrice + buffer/pos.Quantity
+	}
+	return math.Max(liq, 0)
+}
+
+// availableBalance 可用余额 = 钱包余额 + 全仓未实现盈亏 - 已占用保证金
+func (t *PaperTrader) availableBalance() float64 {
+	available := t.state.WalletBalance
+	for _, pos := range t.state.Positions {
+		available -= pos.Margin
+		if pos.IsCrossMargin {
+			available += unrealizedPnL(pos)
+		}
+	}
+	return math.Max(available, 0)
+}
+
+// closePosition 按成交价平掉指定数量，结算盈亏与手续费（调用方需持有锁）
+func (t *PaperTrader) closePosition(pos *paperPosition, quantity, fillPrice float64) (realized, fee float64) {
+	if quantity > pos.Quantity {
+		quantity = pos.Quantity
+	}
+
+	if pos.Side == "long" {
+		realized = (fillPrice - pos.EntryPrice) * quantity
+	} else {
+		realized = (pos.EntryPrice - fillPrice) * quantity
+	}
+	fee = fillPrice * quantity * t.feeRate
+
+	ratio := quantity / pos.Quantity
+	pos.Margin -= pos.Margin * ratio
+	pos.Quantity -= quantity
+
+	t.state.WalletBalance += realized - fee
+	t.state.RealizedPnL += realized
+	t.state.TotalFees += fee
+	if t.state.WalletBalance < 0 {
+		t.state.WalletBalance = 0
+	}
+
+	if pos.Quantity <= 1e-12 {
+		delete(t.state.Positions, paperPositionKey(pos.Symbol, pos.Side))
+	}
+	return realized, fee
+}
+
+// removeOrders 删除指定币种方向的条件单（side为空表示全部方向）
+func (t *PaperTrader) removeOrders(symbol, side string) int {
+	kept := t.state.Orders[:0]
+	removed := 0
+	for _, order := range t.state.Orders {
+		if order.Symbol == symbol && (side == "" || order.PositionSide == sideToPositionSide(side)) {
+			removed++
+			continue
+		}
+		kept = append(kept, order)
+	}
+	t.state.Orders = kept
+	return removed
+}
+
+// removeOrdersByType 删除指定币种某类条件单
+func (t *PaperTrader) removeOrdersByType(symbol, orderType string) int {
+	kept := t.state.Orders[:0]
+	removed := 0
+	for _, order := range t.state.Orders {
+		if order.Symbol == symbol && order.Type == orderType {
+			removed++
+			continue
+		}
+		kept = append(kept, order)
+	}
+	t.state.Orders = kept
+	return removed
+}
+
+// nextOrderID 生成订单ID
+func (t *PaperTrader) nextOrderID() int64 {
+	id := t.state.NextOrderID
+	t.state.NextOrderID++
+	return id
+}
+
+// recordFill 记录成交（只保留最近 paperMaxFills 条，调用方需持有锁）
+func (t *PaperTrader) recordFill(orderID int64, symbol, side, orderType string, price, quantity, fee, realized float64, reduceOnly bool) {
+	t.state.Fills = append(t.state.Fills, &paperFill{
+		OrderID:      orderID,
+		Symbol:       symbol,
+		PositionSide: sideToPositionSide(side),
+		OrderType:    orderType,
+		Price:        price,
+		Quantity:     quantity,
+		Fee:          fee,
+		RealizedPnL:  realized,
+		ReduceOnly:   reduceOnly,
+		Time:         t.clock().UnixMilli(),
+	})
+	if excess := len(t.state.Fills) - paperMaxFills; excess > 0 {
+		t.state.Fills = append([]*paperFill(nil), t.state.Fills[excess:]...)
+	}
+}
+
+// GetFills 获取 since 之后的成交（symbol为空表示全部币种）
+func (t *PaperTrader) GetFills(symbol string, since time.Time) ([]Fill, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var fills []Fill
+	for _, f := range t.state.Fills {
+		if (symbol != "" && f.Symbol != symbol) || f.Time < since.UnixMilli() {
+			continue
+		}
+		id := strconv.FormatInt(f.OrderID, 10)
+		fills = append(fills, Fill{
+			TradeID:      id,
+			OrderID:      id,
+			Symbol:       f.Symbol,
+			Side:         orderSide(f.PositionSide, f.ReduceOnly),
+			PositionSide: f.PositionSide,
+			OrderType:    f.OrderType,
+			Price:        f.Price,
+			Quantity:     f.Quantity,
+			Fee:          f.Fee,
+			RealizedPnL:  f.RealizedPnL,
+			ReduceOnly:   f.ReduceOnly,
+			Time:         time.UnixMilli(f.Time),
+		})
+	}
+	return fills, nil
+}
+
+// GetIncome 根据成交记录生成已实现盈亏与手续费流水（模拟盘不计资金费）
+func (t *PaperTrader) GetIncome(symbol string, since time.Time) ([]Income, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var incomes []Income
+	for _, f := range t.state.Fills {
+		if (symbol != "" && f.Symbol != symbol) || f.Time < since.UnixMilli() {
+			continue
+		}
+		id := strconv.FormatInt(f.OrderID, 10)
+		if f.RealizedPnL != 0 {
+			incomes = append(incomes, Income{Symbol: f.Symbol, Type: IncomeRealizedPnL, Amount: f.RealizedPnL,
+				Asset: "USDT", TranID: id + "-pnl", TradeID: id, Time: time.UnixMilli(f.Time)})
+		}
+		if f.Fee != 0 {
+			incomes = append(incomes, Income{Symbol: f.Symbol, Type: IncomeCommission, Amount: -f.Fee,
+				Asset: "USDT", TranID: id + "-fee", TradeID: id, Time: time.UnixMilli(f.Time)})
+		}
+	}
+	return incomes, nil
+}
+
+// GetOpenOrders 获取未触发的止损/止盈条件单与未成交的限价挂单
+func (t *PaperTrader) GetOpenOrders(symbol string) ([]OpenOrder, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var orders []OpenOrder
+	for _, order := range t.state.Orders {
+		if symbol != "" && order.Symbol != symbol {
+			continue
+		}
+		orders = append(orders, OpenOrder{
+			OrderID:      strconv.FormatInt(order.OrderID, 10),
+			Symbol:       order.Symbol,
+			Side:         orderSide(order.PositionSide, true),
+			PositionSide: order.PositionSide,
+			Type:         order.Type,
+			StopPrice:    order.TriggerPrice,
+			Quantity:     order.Quantity,
+			ReduceOnly:   true,
+			Time:         time.UnixMilli(order.CreateTime),
+		})
+	}
+	for _, order := range t.state.LimitOrders {
+		if symbol != "" && order.Symbol != symbol {
+			continue
+		}
+		positionSide := sideToPositionSide(order.Side)
+		orders = append(orders, OpenOrder{
+			OrderID:      strconv.FormatInt(order.OrderID, 10),
+			Symbol:       order.Symbol,
+			Side:         orderSide(positionSide, false),
+			PositionSide: positionSide,
+			Type:         "LIMIT",
+			Price:        order.Price,
+			Quantity:     order.Quantity,
+			Time:         time.UnixMilli(order.CreateTime),
+		})
+	}
+	return orders, nil
+}
+
+// GetBalance 获取账户余额
+func (t *PaperTrader) GetBalance() (*Balance, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.refreshMarks()
+
+	totalUnrealized := 0.0
+	for _, pos := range t.state.Positions {
+		totalUnrealized += unrealizedPnL(pos)
+	}
+
+	return &Balance{
+		TotalWalletBalance:    t.state.WalletBalance,
+		AvailableBalance:      t.availableBalance(),
+		TotalUnrealizedProfit: totalUnrealized,
+	}, nil
+}
+
+// Totals 返回累计已实现盈亏与手续费（回测统计使用）
+func (t *PaperTrader) Totals() (realizedPnL, totalFees float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state.RealizedPnL, t.state.TotalFees
+}
+
+// refreshMarks 刷新所有持仓的标记价格（获取失败时沿用上次价格）
+func (t *PaperTrader) refreshMarks() {
+	symbols := make(map[string]bool)
+	for _, pos := range t.state.Positions {
+		symbols[pos.Symbol] = true
+	}
+	for symbol := range symbols {
+		if _, err := t.fetchPrice(symbol); err != nil {
+			log.Printf("⚠️ 模拟盘刷新 %s 标记价格失败，沿用上次价格: %v", symbol, err)
+		}
+	}
+}
+
+// GetPositions 获取所有持仓
+func (t *PaperTrader) GetPositions() ([]Position, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.refreshMarks()
+
+	keys := make([]string, 0, len(t.state.Positions))
+	for key := range t.state.Positions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]Position, 0, len(keys))
+	for _, key := range keys {
+		pos := t.state.Positions[key]
+		result = append(result, Position{
+			Symbol:           pos.Symbol,
+			Side:             pos.Side,
+			Quantity:         pos.Quantity,
+			EntryPrice:       pos.EntryPrice,
+			MarkPrice:        pos.MarkPrice,
+			UnrealizedPnL:    unrealizedPnL(pos),
+			Leverage:         pos.Leverage,
+			LiquidationPrice: t.liquidationPrice(pos),
+		})
+	}
+	return result, nil
+}
+
+// OpenLong 开多仓
+func (t *PaperTrader) OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return t.openPosition(symbol, "long", quantity, leverage)
+}
+
+// OpenShort 开空仓
+func (t *PaperTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return t.openPosition(symbol, "short", quantity, leverage)
+}
+
+// openPosition 开仓（同方向已有持仓则加仓并按加权均价更新开仓价）
+func (t *PaperTrader) openPosition(symbol, side string, quantity float64, leverage int) (*OrderResult, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("开仓数量必须大于0")
+	}
+	if leverage <= 0 {
+		return nil, fmt.Errorf("杠杆倍数必须大于0")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// 与真实交易所一致：开仓前清理该币种旧的止损止盈单
+	t.removeOrders(symbol, "")
+	t.state.Leverage[symbol] = leverage
+
+	markPrice, err := t.fetchPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	fillPrice := t.applySlippage(markPrice, side, true)
+	margin, fee, err := t.openMargin(quantity, leverage, fillPrice)
+	if err != nil {
+		return nil, err
+	}
+	t.addPosition(symbol, side, quantity, leverage, fillPrice, markPrice, margin, fee)
+
+	orderID := t.nextOrderID()
+	t.recordFill(orderID, symbol, side, "MARKET", fillPrice, quantity, fee, 0, false)
+	t.persist()
+
+	log.Printf("✓ 模拟盘开%s成功: %s 数量: %.6f 成交价: %.4f 手续费: %.4f",
+		sideName(side), symbol, quantity, fillPrice, fee)
+
+	return &OrderResult{
+		OrderID:     orderID,
+		Symbol:      symbol,
+		Status:      "FILLED",
+		AvgPrice:    fillPrice,
+		ExecutedQty: quantity,
+		Fee:         fee,
+	}, nil
+}
+
+// openMargin 计算开仓所需保证金与手续费，并检查可用余额（调用方需持有锁）
+func (t *PaperTrader) openMargin(quantity float64, leverage int, fillPrice float64) (margin, fee float64, err error) {
+	notional := fillPrice * quantity
+	margin = notional / float64(leverage)
+	fee = notional * t.feeRate
+
+	if available := t.availableBalance(); margin+fee > available {
+		return 0, 0, fmt.Errorf("可用余额不足: 需要 %.2f USDT (保证金 %.2f + 手续费 %.2f)，可用 %.2f USDT",
+			margin+fee, margin, fee, available)
+	}
+	return margin, fee, nil
+}
+
+// addPosition 按成交价开仓或加仓（按加权均价更新开仓价），扣除手续费（调用方需持有锁）
+func (t *PaperTrader) addPosition(symbol, side string, quantity float64, leverage int, fillPrice, markPrice, margin, fee float64) {
+	isCross, ok := t.state.MarginMode[symbol]
+	if !ok {
+		isCross = true
+	}
+
+	key := paperPositionKey(symbol, side)
+	pos, exists := t.state.Positions[key]
+	if !exists {
+		pos = &paperPosition{
+			Symbol:        symbol,
+			Side:          side,
+			Leverage:      leverage,
+			IsCrossMargin: isCross,
+			OpenTime:      t.clock().UnixMilli(),
+		}
+		t.state.Positions[key] = pos
+	}
+	totalQty := pos.Quantity + quantity
+	pos.EntryPrice = (pos.EntryPrice*pos.Quantity + fillPrice*quantity) / totalQty
+	pos.Quantity = totalQty
+	pos.MarkPrice = markPrice
+	pos.Margin += margin
+	pos.Leverage = leverage
+
+	t.state.WalletBalance -= fee
+	t.state.TotalFees += fee
+}
+
+// OpenLongLimit 限价开多仓
+func (t *PaperTrader) OpenLongLimit(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	return t.placeLimitOrder(symbol, "long", quantity, leverage, price, postOnly)
+}
+
+// OpenShortLimit 限价开空仓
+func (t *PaperTrader) OpenShortLimit(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	return t.placeLimitOrder(symbol, "short", quantity, leverage, price, postOnly)
+}
+
+// placeLimitOrder 挂限价开仓单
+// 限价已穿过市价时：post-only 拒单，普通限价单立即按市价（不劣于限价）吃单成交；否则挂单等待价格触及
+func (t *PaperTrader) placeLimitOrder(symbol, side string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("开仓数量必须大于0")
+	}
+	if leverage <= 0 {
+		return nil, fmt.Errorf("杠杆倍数必须大于0")
+	}
+	if price <= 0 {
+		return nil, fmt.Errorf("限价必须大于0")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	markPrice, err := t.fetchPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+	t.state.Leverage[symbol] = leverage
+
+	if limitCrossed(side, price, markPrice) {
+		if postOnly {
+			return nil, fmt.Errorf("post-only 限价单会立即成交，已拒绝 (限价 %.4f, 市价 %.4f)", price, markPrice)
+		}
+
+		fillPrice := t.applySlippage(markPrice, side, true)
+		if side == "long" {
+			fillPrice = math.Min(fillPrice, price)
+		} else {
+			fillPrice = math.Max(fillPrice, price)
+		}
+		margin, fee, err := t.openMargin(quantity, leverage, fillPrice)
+		if err != nil {
+			return nil, err
+		}
+		t.addPosition(symbol, side, quantity, leverage, fillPrice, markPrice, margin, fee)
+
+		orderID := t.nextOrderID()
+		t.recordFill(orderID, symbol, side, "LIMIT", fillPrice, quantity, fee, 0, false)
+		t.persist()
+
+		log.Printf("✓ 模拟盘限价开%s立即成交: %s 数量: %.6f 成交价: %.4f 手续费: %.4f",
+			sideName(side), symbol, quantity, fillPrice, fee)
+		return &OrderResult{
+			OrderID:     orderID,
+			Symbol:      symbol,
+			Status:      OrderStatusFilled,
+			AvgPrice:    fillPrice,
+			ExecutedQty: quantity,
+			Fee:         fee,
+		}, nil
+	}
+
+	// 挂单时预检保证金（模拟盘不冻结挂单保证金，成交时会再次检查）
+	if _, _, err := t.openMargin(quantity, leverage, price); err != nil {
+		return nil, err
+	}
+
+	order := &paperLimitOrder{
+		OrderID:    t.nextOrderID(),
+		Symbol:     symbol,
+		Side:       side,
+		Price:      price,
+		Quantity:   quantity,
+		Leverage:   leverage,
+		PostOnly:   postOnly,
+		CreateTime: t.clock().UnixMilli(),
+	}
+	t.state.LimitOrders = append(t.state.LimitOrders, order)
+	t.persist()
+
+	log.Printf("✓ 模拟盘限价开%s挂单: %s 数量: %.6f 限价: %.4f (订单ID: %d)",
+		sideName(side), symbol, quantity, price, order.OrderID)
+	return &OrderResult{OrderID: order.OrderID, Symbol: symbol, Status: OrderStatusNew}, nil
+}
+
+// removeLimitOrders 删除指定币种的限价挂单
+func (t *PaperTrader) removeLimitOrders(symbol string) int {
+	kept := t.state.LimitOrders[:0]
+	removed := 0
+	for _, order := range t.state.LimitOrders {
+		if order.Symbol == symbol {
+			removed++
+			continue
+		}
+		kept = append(kept, order)
+	}
+	t.state.LimitOrders = kept
+	return removed
+}
+
+// sideName 方向中文名
+func sideName(side string) string {
+	if side == "long" {
+		return "多仓"
+	}
+	return "空仓"
+}
+
+// CloseLong 平多仓（quantity=0表示全部平仓）
+func (t *PaperTrader) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
+	return t.reducePosition(symbol, "long", quantity)
+}
+
+// CloseShort 平空仓（quantity=0表示全部平仓）
+func (t *PaperTrader) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
+	return t.reducePosition(symbol, "short", quantity)
+}
+
+// reducePosition 市价减仓/平仓
+func (t *PaperTrader) reducePosition(symbol, side string, quantity float64) (*OrderResult, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	markPrice, err := t.fetchPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	// 价格更新可能已触发止损/强平，需在撮合后再查持仓
+	pos, ok := t.state.Positions[paperPositionKey(symbol, side)]
+	if !ok {
+		return nil, fmt.Errorf("没有找到 %s 的%s", symbol, sideName(side))
+	}
+	if quantity <= 0 || quantity > pos.Quantity {
+		quantity = pos.Quantity
+	}
+
+	fillPrice := t.applySlippage(markPrice, side, false)
+	realized, fee := t.closePosition(pos, quantity, fillPrice)
+
+	// 全部平仓后取消该方向的止损止盈单
+	if _, stillOpen := t.state.Positions[paperPositionKey(symbol, side)]; !stillOpen {
+		t.removeOrders(symbol, side)
+	}
+
+	orderID := t.nextOrderID()
+	t.recordFill(orderID, symbol, side, "MARKET", fillPrice, quantity, fee, realized, true)
+	t.persist()
+
+	log.Printf("✓ 模拟盘平%s成功: %s 数量: %.6f 成交价: %.4f 已实现盈亏: %.4f 手续费: %.4f",
+		sideName(side), symbol, quantity, fillPrice, realized, fee)
+
+	return &OrderResult{
+		OrderID:     orderID,
+		Symbol:      symbol,
+		Status:      "FILLED",
+		AvgPrice:    fillPrice,
+		ExecutedQty: quantity,
+		Fee:         fee,
+		RealizedPnL: realized,
+	}, nil
+}
+
+// SetLeverage 设置杠杆（仅影响之后的开仓）
+func (t *PaperTrader) SetLeverage(symbol string, leverage int) error {
+	if leverage <= 0 {
+		return fmt.Errorf("杠杆倍数必须大于0")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state.Leverage[symbol] = leverage
+	t.persist()
+	return nil
+}
+
+// SetMarginMode 设置仓位模式（已有持仓时保持原模式，与交易所行为一致）
+func (t *PaperTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, side := range []string{"long", "short"} {
+		if pos, ok := t.state.Positions[paperPositionKey(symbol, side)]; ok && pos.IsCrossMargin != isCrossMargin {
+			log.Printf("  ⚠️ %s 有持仓，无法更改仓位模式，继续使用当前模式", symbol)
+			return nil
+		}
+	}
+	t.state.MarginMode[symbol] = isCrossMargin
+	t.persist()
+	return nil
+}
+
+// GetMarketPrice 获取市场价格（标记价格）
+func (t *PaperTrader) GetMarketPrice(symbol string) (float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.fetchPrice(symbol)
+}
+
+// SetStopLoss 设置止损单
+func (t *PaperTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return t.placeConditionalOrder(symbol, positionSide, "STOP_MARKET", quantity, stopPrice)
+}
+
+// SetTakeProfit 设置止盈单
+func (t *PaperTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return t.placeConditionalOrder(symbol, positionSide, "TAKE_PROFIT_MARKET", quantity, takeProfitPrice)
+}
+
+// placeConditionalOrder 挂条件单
+func (t *PaperTrader) placeConditionalOrder(symbol, positionSide, orderType string, quantity, triggerPrice float64) error {
+	if positionSide != "LONG" && positionSide != "SHORT" {
+		return fmt.Errorf("无效的持仓方向: %s", positionSide)
+	}
+	if triggerPrice <= 0 {
+		return fmt.Errorf("触发价格必须大于0")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.state.Orders = append(t.state.Orders, &paperOrder{
+		OrderID:      t.nextOrderID(),
+		Symbol:       symbol,
+		PositionSide: positionSide,
+		Type:         orderType,
+		Quantity:     quantity,
+		TriggerPrice: triggerPrice,
+		CreateTime:   t.clock().UnixMilli(),
+	})
+	t.persist()
+
+	if orderType == "STOP_MARKET" {
+		log.Printf("  止损价设置: %.4f", triggerPrice)
+	} else {
+		log.Printf("  止盈价设置: %.4f", triggerPrice)
+	}
+	return nil
+}
+
+// CancelStopLossOrders 仅取消止损单
+func (t *PaperTrader) CancelStopLossOrders(symbol string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := t.removeOrdersByType(symbol, "STOP_MARKET"); n > 0 {
+		log.Printf("  ✓ 已取消 %s 的 %d 个止损单", symbol, n)
+		t.persist()
+	}
+	return nil
+}
+
+// CancelTakeProfitOrders 仅取消止盈单
+func (t *PaperTrader) CancelTakeProfitOrders(symbol string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := t.removeOrdersByType(symbol, "TAKE_PROFIT_MARKET"); n > 0 {
+		log.Printf("  ✓ 已取消 %s 的 %d 个止盈单", symbol, n)
+		t.persist()
+	}
+	return nil
+}
+
+// CancelAllOrders 取消该币种的所有挂单（包括限价挂单）
+func (t *PaperTrader) CancelAllOrders(symbol string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := t.removeOrders(symbol, "") + t.removeLimitOrders(symbol); n > 0 {
+		t.persist()
+	}
+	return nil
+}
+
+// CancelStopOrders 取消该币种的止盈/止损单（保留限价挂单）
+func (t *PaperTrader) CancelStopOrders(symbol string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := t.removeOrders(symbol, ""); n > 0 {
+		t.persist()
+	}
+	return nil
+}
+
+// CancelOrder 按订单ID取消单个挂单
+func (t *PaperTrader) CancelOrder(symbol string, orderID string) error {
+	id, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("无效的订单ID %s: %w", orderID, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, order := range t.state.LimitOrders {
+		if order.OrderID == id && order.Symbol == symbol {
+			t.state.LimitOrders = append(t.state.LimitOrders[:i], t.state.LimitOrders[i+1:]...)
+			t.persist()
+			log.Printf("  ✓ 已取消订单 %s (%s)", orderID, symbol)
+			return nil
+		}
+	}
+	for i, order := range t.state.Orders {
+		if order.OrderID == id && order.Symbol == symbol {
+			t.state.Orders = append(t.state.Orders[:i], t.state.Orders[i+1:]...)
+			t.persist()
+			log.Printf("  ✓ 已取消订单 %s (%s)", orderID, symbol)
+			return nil
+		}
+	}
+	return fmt.Errorf("订单 %s 不存在或已成交", orderID)
+}
+
+// FormatQuantity 格式化数量（模拟盘不限制精度，保留8位小数）
+func (t *PaperTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
+	return strconv.FormatFloat(math.Floor(quantity*1e8)/1e8, 'f', -1, 64), nil
+}
@@ -0,0 +1,311 @@
+package trader
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// paperPriceFeed 测试用价格源
+type paperPriceFeed map[string]float64
+
+func (f paperPriceFeed) price(symbol string) (float64, error) {
+	if p, ok := f[symbol]; ok {
+		return p, nil
+	}
+	return 0, fmt.Errorf("未知交易对: %s", symbol)
+}
+
+func newTestPaperTrader(t *testing.T, feed paperPriceFeed, stateFile string) *PaperTrader {
+	t.Helper()
+	pt, err := NewPaperTrader(PaperTraderConfig{
+		InitialBalance: 10000,
+		FeeRate:        0.001,
+		Slippage:       0.001,
+		StateFile:      stateFile,
+		PriceFunc:      feed.price,
+	})
+	require.NoError(t, err)
+	return pt
+}
+
+// TestPaperTrader_InterfaceSuite 使用通用接口测试套件验证模拟盘
+// 平仓用例依赖 mock 的无状态返回，模拟盘为有状态撮合，因此单独在下方覆盖
+func TestPaperTrader_InterfaceSuite(t *testing.T) {
+	feed := paperPriceFeed{"BTCUSDT": 50000, "ETHUSDT": 3000}
+	pt := newTestPaperTrader(t, feed, "")
+
+	suite := NewTraderTestSuite(t, pt)
+	defer suite.Cleanup()
+
+	t.Run("GetBalance", func(t *testing.T) { suite.TestGetBalance() })
+	t.Run("GetPositions", func(t *testing.T) { suite.TestGetPositions() })
+	t.Run("GetMarketPrice", func(t *testing.T) { suite.TestGetMarketPrice() })
+	t.Run("SetLeverage", func(t *testing.T) { suite.TestSetLeverage() })
+	t.Run("SetMarginMode", func(t *testing.T) { suite.TestSetMarginMode() })
+	t.Run("FormatQuantity", func(t *testing.T) { suite.TestFormatQuantity() })
+	t.Run("OpenLong", func(t *testing.T) { suite.TestOpenLong() })
+	t.Run("OpenShort", func(t *testing.T) { suite.TestOpenShort() })
+	t.Run("OpenLimitAndCancel", func(t *testing.T) { suite.TestOpenLimitAndCancel() })
+	t.Run("SetStopLoss", func(t *testing.T) { suite.TestSetStopLoss() })
+	t.Run("SetTakeProfit", func(t *testing.T) { suite.TestSetTakeProfit() })
+	t.Run("GetFills", func(t *testing.T) { suite.TestGetFills() })
+	t.Run("GetIncome", func(t *testing.T) { suite.TestGetIncome() })
+	t.Run("GetOpenOrders", func(t *testing.T) { suite.TestGetOpenOrders() })
+	t.Run("CancelStopLossOrders", func(t *testing.T) { suite.TestCancelStopLossOrders() })
+	t.Run("CancelTakeProfitOrders", func(t *testing.T) { suite.TestCancelTakeProfitOrders() })
+	t.Run("CancelStopOrders", func(t *testing.T) { suite.TestCancelStopOrders() })
+	t.Run("CancelAllOrders", func(t *testing.T) { suite.TestCancelAllOrders() })
+}
+
+func TestPaperTrader_OpenCloseWithFeeAndSlippage(t *testing.T) {
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+	pt := newTestPaperTrader(t, feed, "")
+
+	order, err := pt.OpenLong("BTCUSDT", 0.1, 10)
+	require.NoError(t, err)
+	assert.InDelta(t, 50050.0, order.AvgPrice, 1e-6) // 买入叠加0.1%滑点
+	openFee := 50050.0 * 0.1 * 0.001
+
+	positions, err := pt.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.Equal(t, "long", positions[0].Side)
+	assert.InDelta(t, 0.1, positions[0].Quantity, 1e-9)
+	assert.Equal(t, 10, positions[0].Leverage)
+	assert.GreaterOrEqual(t, positions[0].LiquidationPrice, 0.0)
+
+	feed["BTCUSDT"] = 51000
+	balance, err := pt.GetBalance()
+	require.NoError(t, err)
+	assert.InDelta(t, (51000.0-50050.0)*0.1, balance.TotalUnrealizedProfit, 1e-6)
+
+	_, err = pt.CloseLong("BTCUSDT", 0)
+	require.NoError(t, err)
+
+	closePrice := 51000.0 * 0.999
+	closeFee := closePrice * 0.1 * 0.001
+	expected := 10000 - openFee + (closePrice-50050.0)*0.1 - closeFee
+
+	balance, err = pt.GetBalance()
+	require.NoError(t, err)
+	assert.InDelta(t, expected, balance.TotalWalletBalance, 1e-6)
+	assert.InDelta(t, expected, balance.AvailableBalance, 1e-6)
+
+	positions, err = pt.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+}
+
+func TestPaperTrader_InsufficientBalance(t *testing.T) {
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+	pt := newTestPaperTrader(t, feed, "")
+
+	_, err := pt.OpenLong("BTCUSDT", 10, 5) // 名义价值50万，保证金10万
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "可用余额不足")
+}
+
+func TestPaperTrader_LimitOrders(t *testing.T) {
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+	pt := newTestPaperTrader(t, feed, "")
+
+	// post-only 单会立即成交时拒单
+	_, err := pt.OpenLongLimit("BTCUSDT", 0.1, 10, 50500, true)
+	assert.Error(t, err)
+
+	// 普通限价单穿价立即吃单成交，成交价不劣于限价
+	order, err := pt.OpenShortLimit("BTCUSDT", 0.1, 10, 49990, false)
+	require.NoError(t, err)
+	assert.Equal(t, OrderStatusFilled, order.Status)
+	assert.Equal(t, 49990.0, order.AvgPrice)
+
+	// 挂单等待价格触及，调整止盈止损不影响挂单
+	order, err = pt.OpenLongLimit("BTCUSDT", 0.1, 10, 49000, true)
+	require.NoError(t, err)
+	assert.Equal(t, OrderStatusNew, order.Status)
+	require.NoError(t, pt.CancelStopOrders("BTCUSDT"))
+
+	orders, err := pt.GetOpenOrders("BTCUSDT")
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, "LIMIT", orders[0].Type)
+	assert.Equal(t, "BUY", orders[0].Side)
+	assert.False(t, orders[0].ReduceOnly)
+
+	pt.OnPrice("BTCUSDT", 49100)
+	positions, err := pt.GetPositions()
+	require.NoError(t, err)
+	assert.Len(t, positions, 1, "价格未触及限价不成交")
+
+	pt.OnPrice("BTCUSDT", 48800)
+	fills, err := pt.GetFills("BTCUSDT", time.Time{})
+	require.NoError(t, err)
+	last := fills[len(fills)-1]
+	assert.Equal(t, "LIMIT", last.OrderType)
+	assert.Equal(t, 49000.0, last.Price)
+	assert.InDelta(t, 49000*0.1*0.001, last.Fee, 1e-9)
+
+	// 已成交的订单无法撤销
+	assert.Error(t, pt.CancelOrder("BTCUSDT", strconv.FormatInt(order.OrderID, 10)))
+}
+
+func TestPaperTrader_StopLossAndTakeProfitTrigger(t *testing.T) {
+	tests := []struct {
+		name       string
+		open       func(pt *PaperTrader) error
+		side       string
+		stopLoss   float64
+		takeProfit float64
+		nextPrice  float64
+		wantClosed bool
+	}{
+		{
+			name:       "多单跌破止损",
+			open:       func(pt *PaperTrader) error { _, err := pt.OpenLong("ETHUSDT", 1, 5); return err },
+			side:       "LONG",
+			stopLoss:   2900,
+			takeProfit: 3300,
+			nextPrice:  2890,
+			wantClosed: true,
+		},
+		{
+			name:       "空单跌破止盈",
+			open:       func(pt *PaperTrader) error { _, err := pt.OpenShort("ETHUSDT", 1, 5); return err },
+			side:       "SHORT",
+			stopLoss:   3100,
+			takeProfit: 2800,
+			nextPrice:  2790,
+			wantClosed: true,
+		},
+		{
+			name:       "价格在区间内不触发",
+			open:       func(pt *PaperTrader) error { _, err := pt.OpenLong("ETHUSDT", 1, 5); return err },
+			side:       "LONG",
+			stopLoss:   2900,
+			takeProfit: 3300,
+			nextPrice:  3050,
+			wantClosed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := paperPriceFeed{"ETHUSDT": 3000}
+			pt := newTestPaperTrader(t, feed, "")
+
+			require.NoError(t, tt.open(pt))
+			require.NoError(t, pt.SetStopLoss("ETHUSDT", tt.side, 1, tt.stopLoss))
+			require.NoError(t, pt.SetTakeProfit("ETHUSDT", tt.side, 1, tt.takeProfit))
+
+			pt.OnPrice("ETHUSDT", tt.nextPrice)
+
+			feed["ETHUSDT"] = tt.nextPrice
+			positions, err := pt.GetPositions()
+			require.NoError(t, err)
+			if tt.wantClosed {
+				assert.Empty(t, positions)
+				assert.Empty(t, pt.state.Orders, "平仓后应清理条件单")
+			} else {
+				assert.Len(t, positions, 1)
+				assert.Len(t, pt.state.Orders, 2)
+			}
+		})
+	}
+}
+
+func TestPaperTrader_Liquidation(t *testing.T) {
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+	pt := newTestPaperTrader(t, feed, "")
+
+	require.NoError(t, pt.SetMarginMode("BTCUSDT", false)) // 逐仓
+	_, err := pt.OpenLong("BTCUSDT", 0.2, 20)
+	require.NoError(t, err)
+
+	positions, err := pt.GetPositions()
+	require.NoError(t, err)
+	liqPrice := positions[0].LiquidationPrice
+	assert.InDelta(t, 50050*(1-1.0/20+paperMaintenanceMarginRate), liqPrice, 1)
+
+	pt.OnPrice("BTCUSDT", liqPrice-1)
+	assert.Empty(t, pt.state.Positions)
+
+	balance, err := pt.GetBalance()
+	require.NoError(t, err)
+	// 逐仓强平损失约等于保证金（约500.5 USDT，扣除维持保证金后加上手续费），不会波及全部余额
+	assert.Greater(t, balance.TotalWalletBalance, 9400.0)
+	assert.Less(t, balance.TotalWalletBalance, 9600.0)
+}
+
+func TestPaperTrader_StatePersistence(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "paper", "trader.json")
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+
+	pt := newTestPaperTrader(t, feed, stateFile)
+	_, err := pt.OpenShort("BTCUSDT", 0.05, 10)
+	require.NoError(t, err)
+	require.NoError(t, pt.SetStopLoss("BTCUSDT", "SHORT", 0.05, 52000))
+
+	// 重启后恢复（初始资金参数应被忽略）
+	restored, err := NewPaperTrader(PaperTraderConfig{
+		InitialBalance: 1,
+		FeeRate:        0.001,
+		Slippage:       0.001,
+		StateFile:      stateFile,
+		PriceFunc:      feed.price,
+	})
+	require.NoError(t, err)
+
+	positions, err := restored.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.Equal(t, "short", positions[0].Side)
+	assert.InDelta(t, 0.05, positions[0].Quantity, 1e-9)
+	require.Len(t, restored.state.Orders, 1)
+	assert.Equal(t, pt.state.WalletBalance, restored.state.WalletBalance)
+
+	// 恢复的止损单仍然生效
+	restored.OnPrice("BTCUSDT", 52100)
+	assert.Empty(t, restored.state.Positions)
+}
+
+// TestPaperTrader_UsesInjectedClock 成交、持仓、挂单与状态时间戳使用注入的时钟（回测为模拟时间）
+func TestPaperTrader_UsesInjectedClock(t *testing.T) {
+	simTime := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+	pt, err := NewPaperTrader(PaperTraderConfig{
+		InitialBalance: 10000,
+		StateFile:      filepath.Join(t.TempDir(), "paper.json"),
+		PriceFunc:      feed.price,
+		Clock:          func() time.Time { return simTime },
+	})
+	require.NoError(t, err)
+
+	_, err = pt.OpenLong("BTCUSDT", 0.1, 10)
+	require.NoError(t, err)
+	require.NoError(t, pt.SetStopLoss("BTCUSDT", "LONG", 0.1, 49000))
+	_, err = pt.OpenShortLimit("BTCUSDT", 0.1, 10, 51000, false)
+	require.NoError(t, err)
+
+	fills, err := pt.GetFills("", simTime)
+	require.NoError(t, err)
+	require.Len(t, fills, 1)
+	assert.Equal(t, simTime, fills[0].Time.UTC())
+	fills, err = pt.GetFills("", simTime.Add(time.Second))
+	require.NoError(t, err)
+	assert.Empty(t, fills, "since 按模拟时钟过滤")
+
+	orders, err := pt.GetOpenOrders("BTCUSDT")
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	for _, order := range orders {
+		assert.Equal(t, simTime, order.Time.UTC())
+	}
+	assert.Equal(t, simTime.UnixMilli(), pt.state.Positions[paperPositionKey("BTCUSDT", "long")].OpenTime)
+	assert.Equal(t, simTime, pt.state.UpdatedAt.UTC())
+}
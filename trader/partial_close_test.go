@@ -9,7 +9,7 @@ import (
 
 // MockPartialCloseTrader 用於測試 partial close 邏輯
 type MockPartialCloseTrader struct {
-	positions          []map[string]interface{}
+	positions          []Position
 	closePartialCalled bool
 	closeLongCalled    bool
 	closeShortCalled   bool
@@ -19,28 +19,28 @@ type MockPartialCloseTrader struct {
 	lastTakeProfit     float64
 }
 
-func (m *MockPartialCloseTrader) GetPositions() ([]map[string]interface{}, error) {
+func (m *MockPartialCloseTrader) GetPositions() ([]Position, error) {
 	return m.positions, nil
 }
 
-func (m *MockPartialCloseTrader) ClosePartialLong(symbol string, quantity float64) (map[string]interface{}, error) {
+func (m *MockPartialCloseTrader) ClosePartialLong(symbol string, quantity float64) (*OrderResult, error) {
 	m.closePartialCalled = true
-	return map[string]interface{}{"orderId": "12345"}, nil
+	return &OrderResult{OrderID: 12345}, nil
 }
 
-func (m *MockPartialCloseTrader) ClosePartialShort(symbol string, quantity float64) (map[string]interface{}, error) {
+func (m *MockPartialCloseTrader) ClosePartialShort(symbol string, quantity float64) (*OrderResult, error) {
 	m.closePartialCalled = true
-	return map[string]interface{}{"orderId": "12345"}, nil
+	return &OrderResult{OrderID: 12345}, nil
 }
 
-func (m *MockPartialCloseTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+func (m *MockPartialCloseTrader) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
 	m.closeLongCalled = true
-	return map[string]interface{}{"orderId": "12346"}, nil
+	return &OrderResult{OrderID: 12346}, nil
 }
 
-func (m *MockPartialCloseTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+func (m *MockPartialCloseTrader) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
 	m.closeShortCalled = true
-	return map[string]interface{}{"orderId": "12346"}, nil
+	return &OrderResult{OrderID: 12346}, nil
 }
 
 func (m *MockPartialCloseTrader) SetStopLoss(symbol, side string, quantity, price float64) error {
@@ -302,12 +302,12 @@ func TestPartialCloseIntegration(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// 創建 mock trader
 			mockTrader := &MockPartialCloseTrader{
-				positions: []map[string]interface{}{
+				positions: []Position{
 					{
-						"symbol":    tt.symbol,
-						"side":      tt.side,
-						"quantity":  tt.totalQuantity,
-						"markPrice": tt.markPrice,
+						Symbol:    tt.symbol,
+						Side:      tt.side,
+						Quantity:  tt.totalQuantity,
+						MarkPrice: tt.markPrice,
 					},
 				},
 			}
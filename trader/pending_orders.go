@@ -0,0 +1,360 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/config"
+	"nofx/decision"
+	"nofx/logger"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPendingOrderTimeout 限价开仓挂单默认超时时间
+const DefaultPendingOrderTimeout = 15 * time.Minute
+
+// maxPendingOrderReplaces 挂单超时后最多重新挂单的次数
+const maxPendingOrderReplaces = 1
+
+// pendingOrder 等待成交的限价开仓单（成交后才设置止盈止损）
+type pendingOrder struct {
+	OrderID    string    `json:"order_id"`
+	Symbol     string    `json:"symbol"`
+	Side       string    `json:"side"`       // "long" / "short"
+	OrderType  string    `json:"order_type"` // limit / post_only
+	Price      float64   `json:"price"`
+	Quantity   float64   `json:"quantity"`
+	Leverage   int       `json:"leverage"`
+	StopLoss   float64   `json:"stop_loss"`
+	TakeProfit float64   `json:"take_profit"`
+	PlacedAt   time.Time `json:"placed_at"`
+	Replaces   int       `json:"replaces"` // 已重新挂单次数
+}
+
+// key 挂单唯一标识（同币种同方向只允许一个挂单）
+func (p *pendingOrder) key() string {
+	return p.Symbol + "_" + p.Side
+}
+
+// pendingOrderTimeout 挂单超时时间（未配置时使用默认值）
+func (at *AutoTrader) pendingOrderTimeout() time.Duration {
+	if at.config.PendingOrderTimeout > 0 {
+		return at.config.PendingOrderTimeout
+	}
+	return DefaultPendingOrderTimeout
+}
+
+// hasPendingOrder 是否已有同币种同方向的未成交挂单
+func (at *AutoTrader) hasPendingOrder(symbol, side string) bool {
+	_, ok := at.pendingOrders[symbol+"_"+side]
+	return ok
+}
+
+// placeLimitOrder 按AI决策挂限价开仓单
+func (at *AutoTrader) placeLimitOrder(d *decision.Decision, side string, quantity float64, actionRecord *logger.DecisionAction) error {
+	po := &pendingOrder{
+		Symbol:     d.Symbol,
+		Side:       side,
+		OrderType:  d.OrderType,
+		Price:      d.EntryPrice,
+		Quantity:   quantity,
+		Leverage:   d.Leverage,
+		StopLoss:   d.StopLoss,
+		TakeProfit: d.TakeProfit,
+	}
+	order, err := at.submitLimitOrder(po, d.OrderType == decision.OrderTypePostOnly)
+	if err != nil {
+		return err
+	}
+	actionRecord.OrderID = order.OrderID
+	return nil
+}
+
+// submitLimitOrder 下限价开仓单：立即成交时直接设置止盈止损，否则登记为待成交挂单
+func (at *AutoTrader) submitLimitOrder(po *pendingOrder, postOnly bool) (*OrderResult, error) {
+	var order *OrderResult
+	var err error
+	if po.Side == "long" {
+		order, err = at.trader.OpenLongLimit(po.Symbol, po.Quantity, po.Leverage, po.Price, postOnly)
+	} else {
+		order, err = at.trader.OpenShortLimit(po.Symbol, po.Quantity, po.Leverage, po.Price, postOnly)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// post-only 单会立即成交时交易所返回 EXPIRED 而不是报错
+	switch order.Status {
+	case "EXPIRED", "CANCELED", "REJECTED":
+		return nil, fmt.Errorf("限价单未被接受（状态: %s），可能是 post-only 单会立即成交", order.Status)
+	}
+
+	at.recordOrder(&config.LedgerOrder{
+		Symbol:       po.Symbol,
+		PositionSide: strings.ToUpper(po.Side),
+		OrderType:    "LIMIT",
+		Quantity:     po.Quantity,
+		Price:        po.Price,
+		Leverage:     po.Leverage,
+		Origin:       config.OrderOriginAI,
+	}, order)
+
+	po.OrderID = strconv.FormatInt(order.OrderID, 10)
+	po.PlacedAt = at.now()
+
+	if order.Status == OrderStatusFilled {
+		log.Printf("  ✓ 限价单立即成交，订单ID: %d, 数量: %.4f", order.OrderID, po.Quantity)
+		quantity := po.Quantity
+		if order.ExecutedQty > 0 {
+			quantity = order.ExecutedQty
+		}
+		at.protectFilledOrder(po, quantity)
+		return order, nil
+	}
+
+	if at.pendingOrders == nil {
+		at.pendingOrders = make(map[string]*pendingOrder)
+	}
+	at.pendingOrders[po.key()] = po
+	at.persistPendingOrders()
+	log.Printf("  ⏳ 限价单已挂出，订单ID: %d, 价格: %.4f, 数量: %.4f（成交后设置止盈止损）",
+		order.OrderID, po.Price, po.Quantity)
+	return order, nil
+}
+
+// protectFilledOrder 限价单成交后记录开仓时间并设置止盈止损
+func (at *AutoTrader) protectFilledOrder(po *pendingOrder, quantity float64) {
+	if _, exists := at.positionFirstSeenTime[po.key()]; !exists {
+		at.positionFirstSeenTime[po.key()] = at.now().UnixMilli()
+	}
+
+	positionSide := strings.ToUpper(po.Side)
+	if err := at.trader.SetStopLoss(po.Symbol, positionSide, quantity, po.StopLoss); err != nil {
+		log.Printf("  ⚠ 设置止损失败: %v", err)
+	}
+	if err := at.trader.SetTakeProfit(po.Symbol, positionSide, quantity, po.TakeProfit); err != nil {
+		log.Printf("  ⚠ 设置止盈失败: %v", err)
+	}
+}
+
+// syncPendingOrders 同步挂单状态：已成交的设置止盈止损，超时未成交的撤单并按当前价重新挂单
+func (at *AutoTrader) syncPendingOrders() {
+	if len(at.pendingOrders) == 0 {
+		return
+	}
+	defer at.persistPendingOrders()
+
+	keys := make([]string, 0, len(at.pendingOrders))
+	for key := range at.pendingOrders {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		po := at.pendingOrders[key]
+
+		openOrders, err := at.trader.GetOpenOrders(po.Symbol)
+		if err != nil {
+			log.Printf("⚠️ 查询 %s 挂单失败，下个周期重试: %v", po.Symbol, err)
+			continue
+		}
+		var open *OpenOrder
+		for i := range openOrders {
+			if openOrders[i].OrderID == po.OrderID {
+				open = &openOrders[i]
+				break
+			}
+		}
+
+		// 挂单已不在交易所：成交或被撤销
+		if open == nil {
+			at.finishPendingOrder(po)
+			continue
+		}
+
+		if at.now().Sub(po.PlacedAt) < at.pendingOrderTimeout() {
+			continue
+		}
+
+		log.Printf("⌛ %s %s 限价单 %s 超时未成交（%.0f 分钟），撤单",
+			po.Symbol, po.Side, po.OrderID, at.now().Sub(po.PlacedAt).Minutes())
+		if err := at.trader.CancelOrder(po.Symbol, po.OrderID); err != nil {
+			// 撤单失败通常是刚好成交，下个周期按成交处理
+			log.Printf("⚠️ 撤销限价单 %s 失败: %v", po.OrderID, err)
+			continue
+		}
+		if open.FilledQty > 0 {
+			at.finishPendingOrder(po)
+			continue
+		}
+
+		delete(at.pendingOrders, key)
+		at.replacePendingOrder(po)
+	}
+}
+
+// finishPendingOrder 挂单结束（成交/撤销）后移出跟踪，按该挂单的实际成交数量设置止盈止损
+func (at *AutoTrader) finishPendingOrder(po *pendingOrder) {
+	filled, err := at.pendingOrderFilledQty(po)
+	if err != nil {
+		log.Printf("⚠️ 查询挂单 %s 成交失败，下个周期重试: %v", po.OrderID, err)
+		return
+	}
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("⚠️ 获取持仓失败，下个周期重试挂单 %s: %v", po.OrderID, err)
+		return
+	}
+	delete(at.pendingOrders, po.key())
+
+	if filled <= 0 {
+		log.Printf("ℹ️ %s %s 限价单 %s 已撤销或过期（未成交）", po.Symbol, po.Side, po.OrderID)
+		return
+	}
+	for _, pos := range positions {
+		if pos.Symbol == po.Symbol && pos.Side == po.Side {
+			// 同方向已有的持仓不在本挂单的止盈止损范围内
+			quantity := math.Min(filled, pos.Quantity)
+			log.Printf("✅ %s %s 限价单 %s 已成交，成交数量: %.4f", po.Symbol, po.Side, po.OrderID, quantity)
+			at.protectFilledOrder(po, quantity)
+			at.requestProtectionRefresh()
+			return
+		}
+	}
+	log.Printf("ℹ️ %s %s 限价单 %s 已成交 %.4f，但持仓已不存在", po.Symbol, po.Side, po.OrderID, filled)
+}
+
+// pendingOrderFilledQty 从交易所成交记录汇总挂单的实际成交数量
+func (at *AutoTrader) pendingOrderFilledQty(po *pendingOrder) (float64, error) {
+	// 留出一分钟余量，避免本地与交易所时钟偏差漏掉成交
+	fills, err := at.trader.GetFills(po.Symbol, po.PlacedAt.Add(-time.Minute))
+	if err != nil {
+		return 0, err
+	}
+	filled := 0.0
+	for _, fill := range fills {
+		if fill.OrderID == po.OrderID && !fill.ReduceOnly {
+			filled += fill.Quantity
+		}
+	}
+	return filled, nil
+}
+
+// loadPendingOrders 从文件恢复未成交挂单（重启后继续阻止重复开仓并在成交后设置止盈止损）
+func (at *AutoTrader) loadPendingOrders() error {
+	if at.pendingOrdersFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(at.pendingOrdersFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取挂单文件失败: %w", err)
+	}
+
+	var orders []*pendingOrder
+	if err := json.Unmarshal(data, &orders); err != nil {
+		return fmt.Errorf("解析挂单文件失败: %w", err)
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+	at.pendingOrders = make(map[string]*pendingOrder, len(orders))
+	for _, po := range orders {
+		at.pendingOrders[po.key()] = po
+	}
+	log.Printf("📂 [%s] 已恢复 %d 个未成交挂单", at.name, len(orders))
+	return nil
+}
+
+// persistPendingOrders 保存挂单，失败只记录日志
+func (at *AutoTrader) persistPendingOrders() {
+	if err := at.savePendingOrders(); err != nil {
+		log.Printf("⚠️ [%s] 保存挂单失败: %v", at.name, err)
+	}
+}
+
+// savePendingOrders 将未成交挂单写入文件
+func (at *AutoTrader) savePendingOrders() error {
+	if at.pendingOrdersFile == "" {
+		return nil
+	}
+
+	orders := make([]*pendingOrder, 0, len(at.pendingOrders))
+	for _, po := range at.pendingOrders {
+		orders = append(orders, po)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].key() < orders[j].key() })
+	data, err := json.MarshalIndent(orders, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化挂单失败: %w", err)
+	}
+	if err := logger.WriteFileAtomic(at.pendingOrdersFile, data); err != nil {
+		return fmt.Errorf("写入挂单失败: %w", err)
+	}
+	return nil
+}
+
+// replacePendingOrder 按当前价格重新挂普通限价单（允许吃单成交），价格已越过止损/止盈或超过重挂次数时放弃
+func (at *AutoTrader) replacePendingOrder(po *pendingOrder) {
+	if po.Replaces >= maxPendingOrderReplaces {
+		log.Printf("ℹ️ %s %s 限价单已重挂 %d 次仍未成交，放弃开仓", po.Symbol, po.Side, po.Replaces)
+		return
+	}
+
+	price, err := at.trader.GetMarketPrice(po.Symbol)
+	if err != nil {
+		log.Printf("⚠️ 获取 %s 价格失败，放弃重新挂单: %v", po.Symbol, err)
+		return
+	}
+	low, high := po.StopLoss, po.TakeProfit
+	if po.Side == "short" {
+		low, high = po.TakeProfit, po.StopLoss
+	}
+	if price <= low || price >= high {
+		log.Printf("ℹ️ %s 当前价格 %.4f 已越过止损/止盈区间，放弃重新挂单", po.Symbol, price)
+		return
+	}
+
+	// 保持名义价值不变
+	replacement := *po
+	replacement.Quantity = po.Quantity * po.Price / price
+	replacement.Price = price
+	replacement.OrderType = decision.OrderTypeLimit
+	replacement.Replaces = po.Replaces + 1
+
+	log.Printf("🔁 %s %s 按当前价 %.4f 重新挂限价单（第 %d 次）", po.Symbol, po.Side, price, replacement.Replaces)
+	if _, err := at.submitLimitOrder(&replacement, false); err != nil {
+		log.Printf("⚠️ %s 重新挂单失败: %v", po.Symbol, err)
+	}
+}
+
+// pendingOrderInfos 未成交挂单（按币种方向排序，用于AI上下文）
+func (at *AutoTrader) pendingOrderInfos() []decision.PendingOrderInfo {
+	keys := make([]string, 0, len(at.pendingOrders))
+	for key := range at.pendingOrders {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	infos := make([]decision.PendingOrderInfo, 0, len(keys))
+	for _, key := range keys {
+		po := at.pendingOrders[key]
+		infos = append(infos, decision.PendingOrderInfo{
+			Symbol:     po.Symbol,
+			Side:       po.Side,
+			OrderType:  po.OrderType,
+			Price:      po.Price,
+			Quantity:   po.Quantity,
+			StopLoss:   po.StopLoss,
+			TakeProfit: po.TakeProfit,
+			PlacedTime: po.PlacedAt.UnixMilli(),
+		})
+	}
+	return infos
+}
@@ -0,0 +1,173 @@
+package trader
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPendingOrderTrader 创建使用模拟盘与可控时钟的 AutoTrader
+func newPendingOrderTrader(t *testing.T, feed paperPriceFeed, now *time.Time) (*AutoTrader, *PaperTrader) {
+	t.Helper()
+	pt := newTestPaperTrader(t, feed, "")
+	at := &AutoTrader{
+		trader:                pt,
+		positionFirstSeenTime: make(map[string]int64),
+		config:                AutoTraderConfig{PendingOrderTimeout: 10 * time.Minute},
+		clock:                 func() time.Time { return *now },
+		marketDataFunc: func(symbol string) (*market.Data, error) {
+			return &market.Data{Symbol: symbol, CurrentPrice: feed[symbol]}, nil
+		},
+	}
+	return at, pt
+}
+
+func limitLongDecision(orderType string, entryPrice float64) *decision.Decision {
+	return &decision.Decision{
+		Symbol:          "BTCUSDT",
+		Action:          "open_long",
+		OrderType:       orderType,
+		EntryPrice:      entryPrice,
+		Leverage:        10,
+		PositionSizeUSD: 4900,
+		StopLoss:        47000,
+		TakeProfit:      55000,
+	}
+}
+
+func TestAutoTrader_LimitOrderFillSetsProtection(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+	at, pt := newPendingOrderTrader(t, feed, &now)
+
+	var record logger.DecisionAction
+	require.NoError(t, at.executeOpenLongWithRecord(limitLongDecision(decision.OrderTypePostOnly, 49000), &record))
+	assert.NotZero(t, record.OrderID)
+	assert.InDelta(t, 0.1, record.Quantity, 1e-9, "数量按挂单价计算")
+	require.True(t, at.hasPendingOrder("BTCUSDT", "long"))
+
+	// 同方向重复开仓被拒绝，挂单出现在AI上下文中
+	assert.Error(t, at.executeOpenLongWithRecord(limitLongDecision(decision.OrderTypeLimit, 48500), &logger.DecisionAction{}))
+	infos := at.pendingOrderInfos()
+	require.Len(t, infos, 1)
+	assert.Equal(t, decision.OrderTypePostOnly, infos[0].OrderType)
+	assert.Equal(t, 49000.0, infos[0].Price)
+
+	// 价格触及限价后成交，下个周期同步时设置止盈止损
+	pt.OnPrice("BTCUSDT", 48900)
+	now = now.Add(3 * time.Minute)
+	at.syncPendingOrders()
+
+	assert.False(t, at.hasPendingOrder("BTCUSDT", "long"))
+	positions, err := pt.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.Equal(t, 49000.0, positions[0].EntryPrice, "挂单按限价成交，不计滑点")
+
+	orders, err := pt.GetOpenOrders("BTCUSDT")
+	require.NoError(t, err)
+	types := make([]string, 0, len(orders))
+	for _, order := range orders {
+		types = append(types, order.Type)
+	}
+	assert.ElementsMatch(t, []string{"STOP_MARKET", "TAKE_PROFIT_MARKET"}, types)
+	assert.Contains(t, at.positionFirstSeenTime, "BTCUSDT_long")
+}
+
+func TestAutoTrader_LimitOrderTimeoutReplace(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+	at, pt := newPendingOrderTrader(t, feed, &now)
+
+	require.NoError(t, at.executeOpenLongWithRecord(limitLongDecision(decision.OrderTypePostOnly, 49000), &logger.DecisionAction{}))
+	first := at.pendingOrders["BTCUSDT_long"].OrderID
+
+	// 未超时：保持挂单
+	now = now.Add(5 * time.Minute)
+	at.syncPendingOrders()
+	assert.Equal(t, first, at.pendingOrders["BTCUSDT_long"].OrderID)
+
+	// 超时：撤单后按当前价重挂普通限价单，价格不变时立即吃单成交并设置止盈止损
+	now = now.Add(6 * time.Minute)
+	at.syncPendingOrders()
+	assert.False(t, at.hasPendingOrder("BTCUSDT", "long"))
+
+	positions, err := pt.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.InDelta(t, 4900/50000.0, positions[0].Quantity, 1e-9, "重挂保持名义价值不变")
+
+	orders, err := pt.GetOpenOrders("BTCUSDT")
+	require.NoError(t, err)
+	assert.Len(t, orders, 2, "原挂单已撤销，只剩止盈止损单")
+}
+
+func TestAutoTrader_LimitOrderGiveUp(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+	at, pt := newPendingOrderTrader(t, feed, &now)
+
+	require.NoError(t, at.executeOpenLongWithRecord(limitLongDecision(decision.OrderTypeLimit, 49000), &logger.DecisionAction{}))
+
+	// 超时时价格已越过止盈：撤单且不再重挂
+	feed["BTCUSDT"] = 56000
+	now = now.Add(11 * time.Minute)
+	at.syncPendingOrders()
+
+	assert.False(t, at.hasPendingOrder("BTCUSDT", "long"))
+	orders, err := pt.GetOpenOrders("BTCUSDT")
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+	positions, err := pt.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+}
+
+func TestAutoTrader_PendingOrderSurvivesRestart(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+	at, pt := newPendingOrderTrader(t, feed, &now)
+	at.pendingOrdersFile = filepath.Join(t.TempDir(), "pending_orders", "trader.json")
+
+	// 挂限价单 0.1 后，同方向另有人工开仓 0.05
+	require.NoError(t, at.executeOpenLongWithRecord(limitLongDecision(decision.OrderTypePostOnly, 49000), &logger.DecisionAction{}))
+	_, err := pt.OpenLong("BTCUSDT", 0.05, 10)
+	require.NoError(t, err)
+
+	// 重启：新实例从文件恢复挂单（hasPendingOrder 继续阻止重复挂单）
+	restarted := &AutoTrader{
+		trader:                pt,
+		positionFirstSeenTime: make(map[string]int64),
+		config:                at.config,
+		clock:                 at.clock,
+		marketDataFunc:        at.marketDataFunc,
+		pendingOrdersFile:     at.pendingOrdersFile,
+	}
+	require.NoError(t, restarted.loadPendingOrders())
+	require.True(t, restarted.hasPendingOrder("BTCUSDT", "long"))
+
+	// 成交后按挂单成交数量（而非合并后的持仓数量）设置止盈止损
+	pt.OnPrice("BTCUSDT", 48900)
+	now = now.Add(3 * time.Minute)
+	restarted.syncPendingOrders()
+	assert.False(t, restarted.hasPendingOrder("BTCUSDT", "long"))
+
+	orders, err := pt.GetOpenOrders("BTCUSDT")
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	for _, order := range orders {
+		assert.InDelta(t, 0.1, order.Quantity, 1e-9, order.Type)
+	}
+
+	// 挂单已清空并写回文件
+	again := &AutoTrader{pendingOrdersFile: at.pendingOrdersFile}
+	require.NoError(t, again.loadPendingOrders())
+	assert.Empty(t, again.pendingOrders)
+}
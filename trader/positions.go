@@ -0,0 +1,103 @@
+package trader
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"nofx/config"
+	"nofx/logger"
+)
+
+// positionSettleWindow 持仓结束后仍可能被修正的时长（账本首次对账最多回溯这么久，补齐的成交会替换推断的退出）
+const positionSettleWindow = ledgerInitialLookback
+
+// PositionHistory 持仓生命周期（按开仓时间排序）
+// 开仓理由、止损止盈调整与AI/持仓保护平仓来自决策日志，交易所止损止盈、强平和人工平仓来自订单账本；
+// 结束超过 positionSettleWindow 的持仓持久化到决策日志存储，之后只回放仍可能变化的时间段（含已归档的天数）
+func (at *AutoTrader) PositionHistory() ([]*logger.PositionLifecycle, error) {
+	at.positionHistoryMu.Lock()
+	defer at.positionHistoryMu.Unlock()
+
+	history, err := at.decisionLogger.LoadPositionHistory()
+	if err != nil {
+		return nil, err
+	}
+	records, err := logger.CollectRecordsSince(at.decisionLogger, history.Since)
+	if err != nil {
+		return nil, err
+	}
+	exits, err := at.ledgerExits(history.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按小时取整，回放起点最多每小时推进一次，避免每个请求都写入
+	settleBefore := at.now().Add(-positionSettleWindow).Truncate(time.Hour)
+	positions, settled, since := logger.SettlePositions(history, logger.BuildPositionLifecycles(records, exits), settleBefore)
+	if len(settled) > 0 || since.After(history.Since) {
+		if err := at.decisionLogger.SavePositionHistory(settled, since); err != nil {
+			log.Printf("⚠️ [%s] 保存持仓生命周期失败: %v", at.name, err)
+		}
+	}
+	return positions, nil
+}
+
+// ledgerExits 账本中 since 之后决策日志之外的平仓成交（交易所条件单、强平与人工操作）
+func (at *AutoTrader) ledgerExits(since time.Time) ([]logger.PositionExit, error) {
+	if at.ledger == nil {
+		return nil, nil
+	}
+
+	orders, err := at.ledger.GetLedgerOrders(at.id, since)
+	if err != nil {
+		return nil, err
+	}
+	fills, err := at.ledger.GetLedgerFills(at.id, since)
+	if err != nil {
+		return nil, err
+	}
+	if len(fills) == 0 {
+		fills = ordersAsFills(orders)
+	}
+
+	orderTypes := make(map[string]string, len(orders))
+	for _, order := range orders {
+		if order.ExchangeOrderID != "" {
+			orderTypes[order.ExchangeOrderID] = order.OrderType
+		}
+	}
+
+	var exits []logger.PositionExit
+	for _, fill := range fills {
+		if !fill.ReduceOnly || (fill.Origin != config.OrderOriginStop && fill.Origin != config.OrderOriginManual) {
+			continue
+		}
+		exits = append(exits, logger.PositionExit{
+			Symbol:   fill.Symbol,
+			Side:     strings.ToLower(fill.PositionSide),
+			Quantity: fill.Quantity,
+			Price:    fill.Price,
+			Time:     fill.FillTime,
+			Cause:    exitCause(fill.Origin, orderTypes[fill.ExchangeOrderID]),
+			OrderID:  fill.ExchangeOrderID,
+		})
+	}
+	return exits, nil
+}
+
+// exitCause 交易所侧平仓成交的退出原因：人工操作，或按条件单类型区分止盈、强平与止损
+func exitCause(origin, orderType string) string {
+	if origin == config.OrderOriginManual {
+		return logger.ExitCauseManual
+	}
+	orderType = strings.ToUpper(orderType)
+	switch {
+	case strings.Contains(orderType, "LIQUIDATION"):
+		return logger.ExitCauseLiquidation
+	case strings.Contains(orderType, "TAKE_PROFIT"):
+		return logger.ExitCauseTakeProfit
+	default:
+		return logger.ExitCauseStopLoss
+	}
+}
@@ -0,0 +1,83 @@
+package trader
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nofx/config"
+	"nofx/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitCause(t *testing.T) {
+	assert.Equal(t, logger.ExitCauseStopLoss, exitCause(config.OrderOriginStop, "STOP_MARKET"))
+	assert.Equal(t, logger.ExitCauseTakeProfit, exitCause(config.OrderOriginStop, "TAKE_PROFIT_MARKET"))
+	assert.Equal(t, logger.ExitCauseLiquidation, exitCause(config.OrderOriginStop, "LIQUIDATION"))
+	assert.Equal(t, logger.ExitCauseManual, exitCause(config.OrderOriginManual, "MARKET"))
+}
+
+func TestAutoTrader_PositionHistory(t *testing.T) {
+	db, err := config.NewDatabase(filepath.Join(t.TempDir(), "config.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+	pt := newTestPaperTrader(t, feed, "")
+	at := &AutoTrader{
+		id:             "trader-1",
+		trader:         pt,
+		ledger:         db,
+		decisionLogger: logger.NewDecisionLogger(t.TempDir()),
+		tradingCoins:   []string{"BTCUSDT"},
+	}
+	// 成交时间精确到毫秒，开仓动作时间固定在成交之前
+	opened := time.Now().Add(-time.Minute)
+	at.clock = func() time.Time { return opened }
+
+	// AI开仓（决策日志记录开仓理由），随后被交易所侧止损平仓
+	order, err := pt.OpenLong("BTCUSDT", 0.1, 10)
+	require.NoError(t, err)
+	at.recordOrder(&config.LedgerOrder{Symbol: "BTCUSDT", PositionSide: "LONG", Quantity: 0.1, Price: 50000,
+		Leverage: 10, Origin: config.OrderOriginAI}, order)
+	require.NoError(t, at.decisionLogger.LogDecision(&logger.DecisionRecord{
+		Success: true,
+		Decisions: []logger.DecisionAction{{Action: "open_long", Symbol: "BTCUSDT", Quantity: 0.1, Leverage: 10,
+			Price: 50000, OrderID: order.OrderID, StopLoss: 49000, Timestamp: at.now(), Success: true, Reasoning: "突破前高"}},
+	}))
+	require.NoError(t, pt.SetStopLoss("BTCUSDT", "LONG", 0.1, 49000))
+	pt.OnPrice("BTCUSDT", 48900)
+
+	_, err = at.reconcileLedger()
+	require.NoError(t, err)
+
+	positions, err := at.PositionHistory()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	pos := positions[0]
+	assert.Equal(t, "突破前高", pos.EntryReasoning)
+	assert.Equal(t, logger.PositionStatusClosed, pos.Status)
+	assert.Equal(t, logger.ExitCauseStopLoss, pos.ExitCause)
+	assert.Equal(t, 49000.0, pos.StopLoss)
+	assert.Less(t, pos.RealizedPnL, 0.0)
+	require.Len(t, pos.Events, 2)
+	assert.False(t, pos.Events[1].Inferred, "平仓原因来自账本成交")
+
+	// 平仓超过结算窗口后持久化，之后的请求不再回放该持仓且不重复
+	at.clock = func() time.Time { return opened.Add(2 * positionSettleWindow) }
+	positions, err = at.PositionHistory()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	history, err := at.decisionLogger.LoadPositionHistory()
+	require.NoError(t, err)
+	require.Len(t, history.Positions, 1)
+	assert.Equal(t, pos.ID, history.Positions[0].ID)
+	assert.True(t, history.Since.After(opened))
+
+	positions, err = at.PositionHistory()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.Equal(t, logger.ExitCauseStopLoss, positions[0].ExitCause)
+}
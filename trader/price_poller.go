@@ -0,0 +1,177 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"nofx/market"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPricePollInterval 非币安交易所轮询价格的默认间隔
+const DefaultPricePollInterval = 5 * time.Second
+
+// pricePollLogInterval 轮询失败日志的最小间隔（交易所故障时避免每次轮询都刷屏）
+const pricePollLogInterval = time.Minute
+
+// batchPriceFetcher 可以一次请求获取多个币种价格的交易器（Hyperliquid allMids、Aster ticker）
+type batchPriceFetcher interface {
+	GetMarketPrices(symbols []string) (map[string]float64, error)
+}
+
+// pollingPriceStream 按固定间隔向交易员所在交易所查询已关注币种的价格
+// 币安 WebSocket 的价格不适用于 Hyperliquid/Aster 等交易所（价格不同，部分币种未在币安上线），此时使用该价格源
+type pollingPriceStream struct {
+	trader   Trader
+	interval time.Duration
+
+	mu      sync.RWMutex
+	symbols map[string]bool
+	subs    map[int]chan market.PriceUpdate
+	nextID  int
+	stopCh  chan struct{} // 有订阅者时运行轮询，最后一个订阅者取消时关闭
+
+	lastFailureLog time.Time // 仅由轮询协程访问
+	suppressed     int       // 上次失败日志之后未输出的失败次数
+}
+
+// newPollingPriceStream 创建轮询价格源（interval<=0 时使用默认间隔）
+func newPollingPriceStream(trader Trader, interval time.Duration) *pollingPriceStream {
+	if interval <= 0 {
+		interval = DefaultPricePollInterval
+	}
+	return &pollingPriceStream{
+		trader:   trader,
+		interval: interval,
+		symbols:  make(map[string]bool),
+		subs:     make(map[int]chan market.PriceUpdate),
+	}
+}
+
+// SubscribePrices 订阅已关注币种的价格，第一个订阅者出现时开始轮询
+// 通道满时丢弃新的更新，不阻塞轮询
+func (s *pollingPriceStream) SubscribePrices(bufferSize int) (<-chan market.PriceUpdate, func()) {
+	ch := make(chan market.PriceUpdate, bufferSize)
+
+	s.mu.Lock()
+	id := s.nextID
+	s.nextID++
+	s.subs[id] = ch
+	if len(s.subs) == 1 {
+		s.stopCh = make(chan struct{})
+		go s.run(s.stopCh)
+	}
+	s.mu.Unlock()
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if sub, ok := s.subs[id]; ok {
+			delete(s.subs, id)
+			close(sub)
+			if len(s.subs) == 0 {
+				close(s.stopCh)
+			}
+		}
+	}
+	return ch, unsubscribe
+}
+
+// WatchSymbol 将币种加入轮询列表
+func (s *pollingPriceStream) WatchSymbol(symbol string) error {
+	s.mu.Lock()
+	s.symbols[strings.ToUpper(symbol)] = true
+	s.mu.Unlock()
+	return nil
+}
+
+// run 每个间隔查询一次所有已关注币种的价格并推送给订阅者
+func (s *pollingPriceStream) run(stopCh chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.poll()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// poll 查询价格（网络请求不持有锁），失败的币种等待下次轮询
+// 交易器支持批量查询时每次轮询只发一次请求，否则逐个币种查询
+func (s *pollingPriceStream) poll() {
+	s.mu.RLock()
+	symbols := make([]string, 0, len(s.symbols))
+	for symbol := range s.symbols {
+		symbols = append(symbols, symbol)
+	}
+	s.mu.RUnlock()
+	if len(symbols) == 0 {
+		return
+	}
+	sort.Strings(symbols)
+
+	now := time.Now()
+	if fetcher, ok := s.trader.(batchPriceFetcher); ok {
+		prices, err := fetcher.GetMarketPrices(symbols)
+		if err != nil {
+			s.logFailure(now, "⚠️ 轮询 %d 个币种价格失败: %v", len(symbols), err)
+			return
+		}
+		var missing []string
+		for _, symbol := range symbols {
+			price, ok := prices[symbol]
+			if !ok {
+				missing = append(missing, symbol)
+				continue
+			}
+			s.publish(market.PriceUpdate{Symbol: symbol, Price: price, Time: now})
+		}
+		if len(missing) > 0 {
+			s.logFailure(now, "⚠️ 轮询价格未返回: %s", strings.Join(missing, ", "))
+		}
+		return
+	}
+
+	for _, symbol := range symbols {
+		price, err := s.trader.GetMarketPrice(symbol)
+		if err != nil {
+			s.logFailure(now, "⚠️ 轮询 %s 价格失败: %v", symbol, err)
+			continue
+		}
+		s.publish(market.PriceUpdate{Symbol: symbol, Price: price, Time: now})
+	}
+}
+
+// logFailure 记录轮询失败，每个 pricePollLogInterval 最多输出一条，并附带期间被省略的失败次数
+func (s *pollingPriceStream) logFailure(now time.Time, format string, args ...any) {
+	if !s.lastFailureLog.IsZero() && now.Sub(s.lastFailureLog) < pricePollLogInterval {
+		s.suppressed++
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if s.suppressed > 0 {
+		msg += fmt.Sprintf("（此前 %v 内另有 %d 次失败未输出）", pricePollLogInterval, s.suppressed)
+	}
+	log.Print(msg)
+	s.lastFailureLog = now
+	s.suppressed = 0
+}
+
+// publish 向所有订阅者推送价格
+func (s *pollingPriceStream) publish(update market.PriceUpdate) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, ch := range s.subs {
+		select {
+		case ch <- update:
+		default:
+		}
+	}
+}
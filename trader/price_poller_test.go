@@ -0,0 +1,93 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"nofx/market"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollingPriceStream(t *testing.T) {
+	stream := newPollingPriceStream(&MockTrader{}, 10*time.Millisecond)
+	require.NoError(t, stream.WatchSymbol("btcusdt"))
+
+	updates, unsubscribe := stream.SubscribePrices(10)
+	select {
+	case update := <-updates:
+		assert.Equal(t, "BTCUSDT", update.Symbol)
+		assert.Equal(t, 50000.0, update.Price, "价格来自交易员所在交易所")
+	case <-time.After(time.Second):
+		t.Fatal("未收到轮询价格")
+	}
+
+	unsubscribe()
+	unsubscribe()
+	for range updates {
+	}
+}
+
+// batchPriceTrader 支持批量查价的模拟交易器，记录两种查价方式的调用次数
+type batchPriceTrader struct {
+	MockTrader
+	prices      map[string]float64
+	batchCalls  int
+	singleCalls int
+}
+
+func (m *batchPriceTrader) GetMarketPrice(symbol string) (float64, error) {
+	m.singleCalls++
+	return m.MockTrader.GetMarketPrice(symbol)
+}
+
+func (m *batchPriceTrader) GetMarketPrices(symbols []string) (map[string]float64, error) {
+	m.batchCalls++
+	return m.prices, nil
+}
+
+func TestPollingPriceStream_BatchFetch(t *testing.T) {
+	trader := &batchPriceTrader{prices: map[string]float64{"BTCUSDT": 50000, "ETHUSDT": 3000}}
+	stream := newPollingPriceStream(trader, time.Hour)
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT", "DOGEUSDT"} {
+		require.NoError(t, stream.WatchSymbol(symbol))
+	}
+	updates := make(chan market.PriceUpdate, 10)
+	stream.subs[0] = updates
+
+	stream.poll()
+	assert.Equal(t, 1, trader.batchCalls, "每次轮询只发一次批量请求")
+	assert.Zero(t, trader.singleCalls)
+	require.Len(t, updates, 2)
+	assert.Equal(t, "BTCUSDT", (<-updates).Symbol)
+	assert.Equal(t, "ETHUSDT", (<-updates).Symbol)
+	assert.Equal(t, 0, stream.suppressed, "第一次失败直接输出日志")
+
+	// 同一间隔内的失败只计数，超过间隔后输出并清零
+	stream.poll()
+	assert.Equal(t, 1, stream.suppressed)
+	stream.lastFailureLog = stream.lastFailureLog.Add(-pricePollLogInterval)
+	stream.poll()
+	assert.Equal(t, 0, stream.suppressed)
+}
+
+func TestResolvePriceStream_NonBinanceVenue(t *testing.T) {
+	original := market.WSMonitorCli
+	market.WSMonitorCli = &market.WSMonitor{}
+	defer func() { market.WSMonitorCli = original }()
+
+	binance := &AutoTrader{marketProvider: market.DefaultProvider()}
+	assert.Equal(t, PriceStream(market.WSMonitorCli), binance.resolvePriceStream())
+	assert.Equal(t, AlertStream(market.WSMonitorCli), binance.resolveAlertStream())
+
+	// 其他交易所不使用币安价格与警报
+	hyperliquid := &AutoTrader{marketProvider: market.NewHyperliquidProvider(false)}
+	assert.Nil(t, hyperliquid.resolvePriceStream())
+	assert.Nil(t, hyperliquid.resolveAlertStream())
+
+	poller := newPollingPriceStream(&MockTrader{}, 0)
+	hyperliquid.SetPriceStream(poller)
+	assert.Equal(t, PriceStream(poller), hyperliquid.resolvePriceStream())
+	assert.Equal(t, DefaultPricePollInterval, poller.interval)
+}
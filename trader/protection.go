@@ -0,0 +1,329 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 持仓保护规则类型
+const (
+	ProtectionPercentTrail     = "percent_trail"     // 收益回撤百分比追踪（原硬编码 5%/40% 规则）
+	ProtectionATRTrail         = "atr_trail"         // 基于4小时ATR的价格追踪止损
+	ProtectionSteppedBreakeven = "stepped_breakeven" // 阶梯式保本/锁利
+)
+
+// DefaultProtectionInterval 默认持仓保护检查间隔
+const DefaultProtectionInterval = time.Minute
+
+// ProtectionConfig 持仓保护配置（按交易员存储为JSON）
+type ProtectionConfig struct {
+	IntervalSeconds int                    `json:"interval_seconds,omitempty"` // 检查间隔（秒），0表示默认60秒
+	Rules           []ProtectionRuleConfig `json:"rules"`                      // 规则列表，为空数组时关闭保护
+}
+
+// ProtectionRuleConfig 单条保护规则配置
+type ProtectionRuleConfig struct {
+	Type        string   `json:"type"`                   // 规则类型：percent_trail / atr_trail / stepped_breakeven
+	Symbols     []string `json:"symbols,omitempty"`      // 适用币种（为空表示不限）
+	SymbolClass string   `json:"symbol_class,omitempty"` // 适用币种类别：btc_eth / altcoin（为空表示不限）
+
+	// percent_trail
+	MinProfitPct float64 `json:"min_profit_pct,omitempty"` // 启动追踪的最低收益率（%）
+	RetracePct   float64 `json:"retrace_pct,omitempty"`    // 从峰值收益回撤的比例（%）
+
+	// atr_trail
+	ATRMultiplier float64 `json:"atr_multiplier,omitempty"` // 止损距离 = ATR × 倍数
+	ActivationPct float64 `json:"activation_pct,omitempty"` // 峰值收益率达到该值后才启动追踪（%）
+
+	// stepped_breakeven
+	Steps []BreakevenStep `json:"steps,omitempty"`
+}
+
+// BreakevenStep 阶梯锁利：峰值收益率达到 TriggerPct 后，收益率回落到 LockPct 即平仓
+type BreakevenStep struct {
+	TriggerPct float64 `json:"trigger_pct"`
+	LockPct    float64 `json:"lock_pct"`
+}
+
+// DefaultProtectionConfig 默认保护配置：收益超过5%且从峰值回撤40%时平仓
+func DefaultProtectionConfig() ProtectionConfig {
+	return ProtectionConfig{
+		IntervalSeconds: int(DefaultProtectionInterval / time.Second),
+		Rules: []ProtectionRuleConfig{
+			{Type: ProtectionPercentTrail, MinProfitPct: 5, RetracePct: 40},
+		},
+	}
+}
+
+// ParseProtectionConfig 解析持仓保护配置JSON，空字符串返回默认配置
+func ParseProtectionConfig(raw string) (ProtectionConfig, error) {
+	if strings.TrimSpace(raw) == "" {
+		return DefaultProtectionConfig(), nil
+	}
+
+	var cfg ProtectionConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return ProtectionConfig{}, fmt.Errorf("解析持仓保护配置失败: %w", err)
+	}
+	if cfg.IntervalSeconds < 0 {
+		return ProtectionConfig{}, fmt.Errorf("持仓保护检查间隔不能为负数")
+	}
+	if _, err := BuildProtectionRules(cfg.Rules); err != nil {
+		return ProtectionConfig{}, err
+	}
+	return cfg, nil
+}
+
+// Interval 返回检查间隔
+func (c ProtectionConfig) Interval() time.Duration {
+	if c.IntervalSeconds <= 0 {
+		return DefaultProtectionInterval
+	}
+	return time.Duration(c.IntervalSeconds) * time.Second
+}
+
+// ProtectedPosition 规则评估时的持仓视图
+type ProtectedPosition struct {
+	Symbol     string
+	Side       string // long / short
+	EntryPrice float64
+	MarkPrice  float64
+	Quantity   float64
+	Leverage   int
+	PnLPct     float64 // 当前收益率（含杠杆，%）
+	PeakPnLPct float64 // 历史峰值收益率（%）
+
+	atr float64 // 同步持仓时缓存的4小时ATR14（未缓存为0）
+}
+
+// ATR 返回该币种4小时ATR14（来自持仓同步时的缓存，评估过程不请求网络，缺失返回0）
+func (p *ProtectedPosition) ATR() float64 {
+	return p.atr
+}
+
+// DrawdownPct 从峰值收益的回撤比例（%），峰值非正时为0
+func (p *ProtectedPosition) DrawdownPct() float64 {
+	if p.PeakPnLPct > 0 && p.PnLPct < p.PeakPnLPct {
+		return (p.PeakPnLPct - p.PnLPct) / p.PeakPnLPct * 100
+	}
+	return 0
+}
+
+// ProtectionRule 持仓保护规则
+type ProtectionRule interface {
+	// Name 规则名称（用于日志）
+	Name() string
+	// Applies 规则是否适用于该币种
+	Applies(symbol string) bool
+	// ShouldClose 判断是否需要平仓，返回原因
+	ShouldClose(pos *ProtectedPosition) (bool, string)
+}
+
+// ProtectionRuleFactory 根据配置创建规则
+type ProtectionRuleFactory func(cfg ProtectionRuleConfig) (ProtectionRule, error)
+
+var (
+	protectionRuleFactories   = map[string]ProtectionRuleFactory{}
+	protectionRuleFactoriesMu sync.RWMutex
+)
+
+// RegisterProtectionRule 注册自定义保护规则类型
+func RegisterProtectionRule(ruleType string, factory ProtectionRuleFactory) {
+	protectionRuleFactoriesMu.Lock()
+	defer protectionRuleFactoriesMu.Unlock()
+	protectionRuleFactories[ruleType] = factory
+}
+
+func init() {
+	RegisterProtectionRule(ProtectionPercentTrail, newPercentTrailRule)
+	RegisterProtectionRule(ProtectionATRTrail, newATRTrailRule)
+	RegisterProtectionRule(ProtectionSteppedBreakeven, newSteppedBreakevenRule)
+}
+
+// BuildProtectionRules 根据配置创建规则列表
+func BuildProtectionRules(configs []ProtectionRuleConfig) ([]ProtectionRule, error) {
+	protectionRuleFactoriesMu.RLock()
+	defer protectionRuleFactoriesMu.RUnlock()
+
+	rules := make([]ProtectionRule, 0, len(configs))
+	for i, cfg := range configs {
+		factory, ok := protectionRuleFactories[cfg.Type]
+		if !ok {
+			return nil, fmt.Errorf("第%d条持仓保护规则类型不支持: %s", i+1, cfg.Type)
+		}
+		rule, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("第%d条持仓保护规则(%s)配置无效: %w", i+1, cfg.Type, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// symbolFilter 规则适用币种过滤
+type symbolFilter struct {
+	symbols map[string]bool
+	class   string
+}
+
+func newSymbolFilter(cfg ProtectionRuleConfig) (symbolFilter, error) {
+	f := symbolFilter{class: cfg.SymbolClass}
+	switch f.class {
+	case "", "btc_eth", "altcoin":
+	default:
+		return f, fmt.Errorf("未知的币种类别: %s", f.class)
+	}
+	if len(cfg.Symbols) > 0 {
+		f.symbols = make(map[string]bool, len(cfg.Symbols))
+		for _, s := range cfg.Symbols {
+			f.symbols[normalizeSymbol(s)] = true
+		}
+	}
+	return f, nil
+}
+
+// Applies 判断币种是否匹配
+func (f symbolFilter) Applies(symbol string) bool {
+	if f.symbols != nil && !f.symbols[symbol] {
+		return false
+	}
+	isBTCETH := symbol == "BTCUSDT" || symbol == "ETHUSDT"
+	switch f.class {
+	case "btc_eth":
+		return isBTCETH
+	case "altcoin":
+		return !isBTCETH
+	}
+	return true
+}
+
+// percentTrailRule 收益回撤追踪：收益率超过阈值且从峰值回撤超过比例时平仓
+type percentTrailRule struct {
+	symbolFilter
+	minProfitPct float64
+	retracePct   float64
+}
+
+func newPercentTrailRule(cfg ProtectionRuleConfig) (ProtectionRule, error) {
+	filter, err := newSymbolFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RetracePct <= 0 || cfg.RetracePct > 100 {
+		return nil, fmt.Errorf("retrace_pct 必须在 (0, 100] 之间")
+	}
+	return &percentTrailRule{symbolFilter: filter, minProfitPct: cfg.MinProfitPct, retracePct: cfg.RetracePct}, nil
+}
+
+func (r *percentTrailRule) Name() string { return ProtectionPercentTrail }
+
+func (r *percentTrailRule) ShouldClose(pos *ProtectedPosition) (bool, string) {
+	drawdown := pos.DrawdownPct()
+	if pos.PnLPct > r.minProfitPct && drawdown >= r.retracePct {
+		return true, fmt.Sprintf("收益 %.2f%% 从峰值 %.2f%% 回撤 %.2f%% (阈值: 收益>%.2f%%, 回撤≥%.2f%%)",
+			pos.PnLPct, pos.PeakPnLPct, drawdown, r.minProfitPct, r.retracePct)
+	}
+	return false, ""
+}
+
+// atrTrailRule ATR追踪止损：以峰值价格为基准，价格反向运行超过 ATR×倍数 时平仓
+type atrTrailRule struct {
+	symbolFilter
+	multiplier    float64
+	activationPct float64
+}
+
+func newATRTrailRule(cfg ProtectionRuleConfig) (ProtectionRule, error) {
+	filter, err := newSymbolFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ATRMultiplier <= 0 {
+		return nil, fmt.Errorf("atr_multiplier 必须大于0")
+	}
+	return &atrTrailRule{symbolFilter: filter, multiplier: cfg.ATRMultiplier, activationPct: cfg.ActivationPct}, nil
+}
+
+func (r *atrTrailRule) Name() string { return ProtectionATRTrail }
+
+func (r *atrTrailRule) ShouldClose(pos *ProtectedPosition) (bool, string) {
+	if pos.PeakPnLPct <= 0 || pos.PeakPnLPct < r.activationPct || pos.EntryPrice <= 0 || pos.Leverage <= 0 {
+		return false, ""
+	}
+	atr := pos.ATR()
+	if atr <= 0 {
+		return false, ""
+	}
+
+	// 由峰值收益率反推峰值价格
+	peakMove := pos.PeakPnLPct / float64(pos.Leverage) / 100
+	distance := atr * r.multiplier
+	if pos.Side == "long" {
+		stop := pos.EntryPrice*(1+peakMove) - distance
+		if pos.MarkPrice <= stop {
+			return true, fmt.Sprintf("价格 %.4f 跌破ATR追踪止损 %.4f (ATR %.4f × %.2f)", pos.MarkPrice, stop, atr, r.multiplier)
+		}
+	} else {
+		stop := pos.EntryPrice*(1-peakMove) + distance
+		if pos.MarkPrice >= stop {
+			return true, fmt.Sprintf("价格 %.4f 突破ATR追踪止损 %.4f (ATR %.4f × %.2f)", pos.MarkPrice, stop, atr, r.multiplier)
+		}
+	}
+	return false, ""
+}
+
+// steppedBreakevenRule 阶梯锁利：峰值收益达到某一档后，收益回落到该档锁定值即平仓
+type steppedBreakevenRule struct {
+	symbolFilter
+	steps []BreakevenStep // 按 TriggerPct 升序
+}
+
+func newSteppedBreakevenRule(cfg ProtectionRuleConfig) (ProtectionRule, error) {
+	filter, err := newSymbolFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Steps) == 0 {
+		return nil, fmt.Errorf("steps 不能为空")
+	}
+	steps := append([]BreakevenStep(nil), cfg.Steps...)
+	for _, step := range steps {
+		if step.LockPct >= step.TriggerPct {
+			return nil, fmt.Errorf("lock_pct(%.2f) 必须小于 trigger_pct(%.2f)", step.LockPct, step.TriggerPct)
+		}
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].TriggerPct < steps[j].TriggerPct })
+	return &steppedBreakevenRule{symbolFilter: filter, steps: steps}, nil
+}
+
+func (r *steppedBreakevenRule) Name() string { return ProtectionSteppedBreakeven }
+
+func (r *steppedBreakevenRule) ShouldClose(pos *ProtectedPosition) (bool, string) {
+	lock := math.Inf(-1)
+	for _, step := range r.steps {
+		if pos.PeakPnLPct >= step.TriggerPct {
+			lock = step.LockPct
+		}
+	}
+	if !math.IsInf(lock, -1) && pos.PnLPct <= lock {
+		return true, fmt.Sprintf("峰值收益 %.2f%% 已锁定 %.2f%%，当前收益 %.2f%% 跌破锁定线", pos.PeakPnLPct, lock, pos.PnLPct)
+	}
+	return false, ""
+}
+
+// resolveProtection 解析持仓保护配置并创建规则（Rules 为 nil 时使用默认配置）
+func resolveProtection(cfg ProtectionConfig) (ProtectionConfig, []ProtectionRule, error) {
+	if cfg.Rules == nil {
+		cfg = DefaultProtectionConfig()
+	}
+	rules, err := BuildProtectionRules(cfg.Rules)
+	if err != nil {
+		return ProtectionConfig{}, nil, fmt.Errorf("初始化持仓保护规则失败: %w", err)
+	}
+	return cfg, rules, nil
+}
@@ -0,0 +1,145 @@
+package trader
+
+import (
+	"log"
+	"nofx/market"
+	"time"
+)
+
+// PriceStream 实时价格源（通常为 market.WSMonitorCli）
+type PriceStream interface {
+	// SubscribePrices 订阅实时价格，返回价格通道和取消订阅函数
+	SubscribePrices(bufferSize int) (<-chan market.PriceUpdate, func())
+	// WatchSymbol 确保币种已订阅实时行情
+	WatchSymbol(symbol string) error
+}
+
+// trackedPosition 持仓保护使用的持仓快照（行情推送时只更新价格，不请求交易所）
+type trackedPosition struct {
+	Symbol     string
+	Side       string
+	EntryPrice float64
+	Quantity   float64
+	Leverage   int
+}
+
+// key 持仓唯一标识（区分多空）
+func (p trackedPosition) key() string {
+	return p.Symbol + "_" + p.Side
+}
+
+// SetPriceStream 设置实时价格源（默认：币安行情使用 market.WSMonitorCli，其他交易所轮询本交易所价格）
+func (at *AutoTrader) SetPriceStream(stream PriceStream) {
+	at.priceStream = stream
+}
+
+// resolvePriceStream 返回可用的实时价格源，没有时返回nil（退回轮询模式）
+// market.WSMonitorCli 推送的是币安价格，只用于使用币安行情的交易员
+func (at *AutoTrader) resolvePriceStream() PriceStream {
+	if at.priceStream != nil {
+		return at.priceStream
+	}
+	if at.usesBinanceMarket() && market.WSMonitorCli != nil {
+		return market.WSMonitorCli
+	}
+	return nil
+}
+
+// usesBinanceMarket 交易员是否使用币安行情（币安与模拟盘；未设置行情源时视为币安）
+func (at *AutoTrader) usesBinanceMarket() bool {
+	return at.marketProvider == nil || at.marketProvider.Name() == market.ProviderBinance
+}
+
+// requestProtectionRefresh 请求持仓保护重新同步持仓（不阻塞，已有待处理请求时忽略）
+func (at *AutoTrader) requestProtectionRefresh() {
+	select {
+	case at.protectionRefreshCh <- struct{}{}:
+	default:
+	}
+}
+
+// runStreamProtection 行情驱动的持仓保护：每次价格推送都在本地评估规则，只有需要平仓时才请求交易所
+// 持仓快照按 interval 定期从交易所同步，并在AI执行决策后立即同步
+func (at *AutoTrader) runStreamProtection(stream PriceStream, interval time.Duration) {
+	updates, unsubscribe := stream.SubscribePrices(1024)
+	defer unsubscribe()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("📊 启动行情驱动持仓保护（逐笔价格评估，每 %v 同步持仓，%d 条规则）", interval, len(at.protectionRules))
+	at.syncProtectedPositions(stream)
+
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				log.Println("⚠️ 实时价格流已关闭，持仓保护退回轮询模式")
+				at.runPollingProtection(ticker)
+				return
+			}
+			at.onPriceUpdate(update)
+		case <-ticker.C:
+			at.syncProtectedPositions(stream)
+		case <-at.protectionRefreshCh:
+			at.syncProtectedPositions(stream)
+		case <-at.stopMonitorCh:
+			log.Println("⏹ 停止持仓保护监控")
+			return
+		}
+	}
+}
+
+// runPollingProtection 按固定间隔轮询持仓（价格流不可用时的兜底）
+func (at *AutoTrader) runPollingProtection(ticker *time.Ticker) {
+	for {
+		select {
+		case <-ticker.C:
+			at.checkPositionDrawdown()
+		case <-at.stopMonitorCh:
+			log.Println("⏹ 停止持仓保护监控")
+			return
+		}
+	}
+}
+
+// syncProtectedPositions 从交易所同步持仓快照，并确保持仓币种都已订阅实时行情
+func (at *AutoTrader) syncProtectedPositions(stream PriceStream) {
+	at.checkPositionDrawdown()
+
+	at.protectionMu.Lock()
+	symbols := make(map[string]bool, len(at.protectedPositions))
+	for _, tp := range at.protectedPositions {
+		symbols[tp.Symbol] = true
+	}
+	at.protectionMu.Unlock()
+
+	for symbol := range symbols {
+		if err := stream.WatchSymbol(symbol); err != nil {
+			log.Printf("⚠️ 持仓保护：订阅 %s 实时行情失败: %v", symbol, err)
+		}
+	}
+}
+
+// onPriceUpdate 用推送价格评估该币种的持仓
+func (at *AutoTrader) onPriceUpdate(update market.PriceUpdate) {
+	at.protectionMu.Lock()
+	defer at.protectionMu.Unlock()
+
+	record := newProtectionRecord()
+	for posKey, tp := range at.protectedPositions {
+		if tp.Symbol != update.Symbol {
+			continue
+		}
+		if attempted, _ := at.protectPosition(tp, update.Price, record); attempted {
+			// 无论成功与否都移出快照，避免每次推送重复下单；下次同步时以交易所为准
+			delete(at.protectedPositions, posKey)
+			at.requestProtectionRefresh()
+		}
+	}
+
+	// 峰值只在内存中更新，定期同步时落盘；有平仓动作时立即落盘并写入决策日志
+	if len(record.Decisions) > 0 {
+		at.finishProtectionRecord(record)
+	}
+}
@@ -0,0 +1,139 @@
+package trader
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nofx/logger"
+	"nofx/market"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePriceStream 测试用价格流
+type fakePriceStream struct {
+	ch      chan market.PriceUpdate
+	watched []string
+}
+
+func (f *fakePriceStream) SubscribePrices(bufferSize int) (<-chan market.PriceUpdate, func()) {
+	return f.ch, func() {}
+}
+
+func (f *fakePriceStream) WatchSymbol(symbol string) error {
+	f.watched = append(f.watched, symbol)
+	return nil
+}
+
+// countingTrader 统计持仓查询次数
+type countingTrader struct {
+	*MockTrader
+	positionCalls atomic.Int32
+}
+
+func (c *countingTrader) GetPositions() ([]Position, error) {
+	c.positionCalls.Add(1)
+	return c.MockTrader.GetPositions()
+}
+
+func newStreamProtectedTrader(t *testing.T, mock *countingTrader) *AutoTrader {
+	rules, err := BuildProtectionRules(DefaultProtectionConfig().Rules)
+	require.NoError(t, err)
+	return &AutoTrader{
+		trader:              mock,
+		decisionLogger:      logger.NewDecisionLogger(t.TempDir()),
+		peakPnLCache:        make(map[string]float64),
+		protectionRules:     rules,
+		protectionRefreshCh: make(chan struct{}, 1),
+		stopMonitorCh:       make(chan struct{}),
+	}
+}
+
+func TestOnPriceUpdate_ClosesOnTickWithoutPolling(t *testing.T) {
+	mock := &countingTrader{MockTrader: &MockTrader{}}
+	at := newStreamProtectedTrader(t, mock)
+	at.protectedPositions = map[string]trackedPosition{
+		"BTCUSDT_long": {Symbol: "BTCUSDT", Side: "long", EntryPrice: 50000, Quantity: 0.1, Leverage: 10},
+	}
+
+	// 价格上涨：峰值收益10%，不触发
+	at.onPriceUpdate(market.PriceUpdate{Symbol: "BTCUSDT", Price: 50500})
+	assert.InDelta(t, 10, at.GetPeakPnLCache()["BTCUSDT_long"], 1e-9)
+	assert.Len(t, at.protectedPositions, 1)
+
+	// 其他币种的推送不影响
+	at.onPriceUpdate(market.PriceUpdate{Symbol: "ETHUSDT", Price: 1})
+	assert.Len(t, at.protectedPositions, 1)
+
+	// 回落到收益6%：回撤40%，触发平仓
+	at.onPriceUpdate(market.PriceUpdate{Symbol: "BTCUSDT", Price: 50300})
+	assert.Empty(t, at.protectedPositions)
+	assert.Zero(t, mock.positionCalls.Load(), "逐笔评估不应查询交易所持仓")
+
+	records, err := at.decisionLogger.GetLatestRecords(1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "auto_close_long", records[0].Decisions[0].Action)
+	assert.InDelta(t, 50300, records[0].Decisions[0].Price, 1e-9)
+
+	select {
+	case <-at.protectionRefreshCh:
+	default:
+		t.Fatal("平仓后应请求同步持仓")
+	}
+}
+
+func TestRunStreamProtection(t *testing.T) {
+	mock := &countingTrader{MockTrader: &MockTrader{positions: []Position{
+		{Symbol: "SOLUSDT", Side: "short", Quantity: 10.0, EntryPrice: 100.0, MarkPrice: 100.0, Leverage: 5},
+	}}}
+	at := newStreamProtectedTrader(t, mock)
+	stream := &fakePriceStream{ch: make(chan market.PriceUpdate, 10)}
+
+	done := make(chan struct{})
+	go func() {
+		at.runStreamProtection(stream, time.Hour)
+		close(done)
+	}()
+
+	stream.ch <- market.PriceUpdate{Symbol: "SOLUSDT", Price: 98}
+	require.Eventually(t, func() bool {
+		return at.GetPeakPnLCache()["SOLUSDT_short"] > 9
+	}, time.Second, 10*time.Millisecond)
+
+	close(at.stopMonitorCh)
+	<-done
+
+	assert.Equal(t, int32(1), mock.positionCalls.Load(), "启动时同步一次持仓")
+	assert.Equal(t, []string{"SOLUSDT"}, stream.watched)
+}
+
+func TestOnPriceUpdate_UsesCachedATR(t *testing.T) {
+	mock := &countingTrader{MockTrader: &MockTrader{positions: []Position{
+		{Symbol: "BTCUSDT", Side: "long", Quantity: 0.1, EntryPrice: 100000, MarkPrice: 100000, Leverage: 10},
+	}}}
+	at := newStreamProtectedTrader(t, mock)
+	rules, err := BuildProtectionRules([]ProtectionRuleConfig{{Type: ProtectionATRTrail, ATRMultiplier: 2, ActivationPct: 5}})
+	require.NoError(t, err)
+	at.protectionRules = rules
+	var fetches atomic.Int32
+	at.marketDataFunc = func(symbol string) (*market.Data, error) {
+		fetches.Add(1)
+		return &market.Data{Symbol: symbol, LongerTermContext: &market.LongerTermData{ATR14: 100}}, nil
+	}
+
+	// 同步持仓时获取一次ATR，同一根4小时K线内不重复获取
+	at.checkPositionDrawdown()
+	at.checkPositionDrawdown()
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// 峰值价格 101000，ATR止损 = 101000 - 200；逐笔评估只读缓存
+	at.onPriceUpdate(market.PriceUpdate{Symbol: "BTCUSDT", Price: 101000})
+	at.onPriceUpdate(market.PriceUpdate{Symbol: "BTCUSDT", Price: 100900})
+	assert.Len(t, at.protectedPositions, 1)
+	at.onPriceUpdate(market.PriceUpdate{Symbol: "BTCUSDT", Price: 100750})
+	assert.Empty(t, at.protectedPositions)
+	assert.Equal(t, int32(1), fetches.Load(), "行情推送路径不应获取市场数据")
+}
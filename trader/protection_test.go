@@ -0,0 +1,161 @@
+package trader
+
+import (
+	"path/filepath"
+	"testing"
+
+	"nofx/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProtectionConfig(t *testing.T) {
+	cfg, err := ParseProtectionConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultProtectionConfig(), cfg)
+
+	cfg, err = ParseProtectionConfig(`{"interval_seconds":15,"rules":[{"type":"atr_trail","atr_multiplier":2,"symbol_class":"btc_eth"}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, 15, cfg.IntervalSeconds)
+	require.Len(t, cfg.Rules, 1)
+
+	cfg, err = ParseProtectionConfig(`{"rules":[]}`)
+	require.NoError(t, err)
+	assert.NotNil(t, cfg.Rules, "空数组表示关闭保护，不能回退到默认规则")
+	assert.Equal(t, DefaultProtectionInterval, cfg.Interval())
+
+	invalid := []string{
+		`not json`,
+		`{"rules":[{"type":"unknown"}]}`,
+		`{"rules":[{"type":"percent_trail","retrace_pct":0}]}`,
+		`{"rules":[{"type":"atr_trail"}]}`,
+		`{"rules":[{"type":"stepped_breakeven","steps":[{"trigger_pct":5,"lock_pct":6}]}]}`,
+		`{"rules":[{"type":"percent_trail","retrace_pct":40,"symbol_class":"meme"}]}`,
+	}
+	for _, raw := range invalid {
+		_, err := ParseProtectionConfig(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestProtectionRules(t *testing.T) {
+	build := func(cfg ProtectionRuleConfig) ProtectionRule {
+		rules, err := BuildProtectionRules([]ProtectionRuleConfig{cfg})
+		require.NoError(t, err)
+		return rules[0]
+	}
+
+	t.Run("percent_trail", func(t *testing.T) {
+		rule := build(ProtectionRuleConfig{Type: ProtectionPercentTrail, MinProfitPct: 5, RetracePct: 40})
+		closed, _ := rule.ShouldClose(&ProtectedPosition{PnLPct: 6, PeakPnLPct: 10})
+		assert.True(t, closed)
+		closed, _ = rule.ShouldClose(&ProtectedPosition{PnLPct: 7, PeakPnLPct: 10})
+		assert.False(t, closed, "回撤30%未达到阈值")
+		closed, _ = rule.ShouldClose(&ProtectedPosition{PnLPct: 4, PeakPnLPct: 10})
+		assert.False(t, closed, "收益低于5%不触发")
+	})
+
+	t.Run("atr_trail", func(t *testing.T) {
+		rule := build(ProtectionRuleConfig{Type: ProtectionATRTrail, ATRMultiplier: 2, ActivationPct: 5})
+
+		// 多头：峰值收益10%（10倍杠杆 → 峰值价格 101000），止损 = 101000 - 200
+		long := &ProtectedPosition{Side: "long", EntryPrice: 100000, Leverage: 10, PeakPnLPct: 10, atr: 100}
+		long.MarkPrice = 100900
+		closed, _ := rule.ShouldClose(long)
+		assert.False(t, closed)
+		long.MarkPrice = 100800
+		closed, reason := rule.ShouldClose(long)
+		assert.True(t, closed)
+		assert.Contains(t, reason, "ATR")
+
+		// 空头：峰值价格 99000，止损 = 99000 + 200
+		short := &ProtectedPosition{Side: "short", EntryPrice: 100000, Leverage: 10, PeakPnLPct: 10, MarkPrice: 99200, atr: 100}
+		closed, _ = rule.ShouldClose(short)
+		assert.True(t, closed)
+
+		// 未达到启动收益
+		closed, _ = rule.ShouldClose(&ProtectedPosition{Side: "long", EntryPrice: 100000, Leverage: 10, PeakPnLPct: 3, MarkPrice: 90000, atr: 100})
+		assert.False(t, closed)
+
+		// ATR未缓存（获取失败）时不触发
+		closed, _ = rule.ShouldClose(&ProtectedPosition{Side: "long", EntryPrice: 100000, Leverage: 10, PeakPnLPct: 10, MarkPrice: 90000})
+		assert.False(t, closed)
+	})
+
+	t.Run("stepped_breakeven", func(t *testing.T) {
+		rule := build(ProtectionRuleConfig{Type: ProtectionSteppedBreakeven, Steps: []BreakevenStep{
+			{TriggerPct: 10, LockPct: 5},
+			{TriggerPct: 3, LockPct: 0},
+		}})
+		closed, _ := rule.ShouldClose(&ProtectedPosition{PeakPnLPct: 2, PnLPct: -1})
+		assert.False(t, closed, "未达到第一档")
+		closed, _ = rule.ShouldClose(&ProtectedPosition{PeakPnLPct: 4, PnLPct: 0})
+		assert.True(t, closed, "保本档")
+		closed, _ = rule.ShouldClose(&ProtectedPosition{PeakPnLPct: 12, PnLPct: 6})
+		assert.False(t, closed)
+		closed, _ = rule.ShouldClose(&ProtectedPosition{PeakPnLPct: 12, PnLPct: 5})
+		assert.True(t, closed, "锁利5%档")
+	})
+
+	t.Run("symbol filter", func(t *testing.T) {
+		major := build(ProtectionRuleConfig{Type: ProtectionPercentTrail, RetracePct: 40, SymbolClass: "btc_eth"})
+		alt := build(ProtectionRuleConfig{Type: ProtectionPercentTrail, RetracePct: 40, SymbolClass: "altcoin"})
+		listed := build(ProtectionRuleConfig{Type: ProtectionPercentTrail, RetracePct: 40, Symbols: []string{"sol"}})
+
+		assert.True(t, major.Applies("BTCUSDT"))
+		assert.False(t, major.Applies("SOLUSDT"))
+		assert.True(t, alt.Applies("SOLUSDT"))
+		assert.False(t, alt.Applies("ETHUSDT"))
+		assert.True(t, listed.Applies("SOLUSDT"))
+		assert.False(t, listed.Applies("XRPUSDT"))
+	})
+}
+
+func TestPeakPnLCachePersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "peak_pnl", "trader.json")
+
+	at := &AutoTrader{peakPnLCache: make(map[string]float64), peakPnLFile: file}
+	at.UpdatePeakPnL("BTCUSDT", "long", 12.5)
+	at.UpdatePeakPnL("ETHUSDT", "short", 3)
+	require.NoError(t, at.savePeakPnLCache())
+
+	restored := &AutoTrader{peakPnLCache: make(map[string]float64), peakPnLFile: file}
+	require.NoError(t, restored.loadPeakPnLCache())
+	assert.Equal(t, map[string]float64{"BTCUSDT_long": 12.5, "ETHUSDT_short": 3}, restored.GetPeakPnLCache())
+
+	restored.prunePeakPnLCache(map[string]bool{"BTCUSDT_long": true})
+	assert.Equal(t, map[string]float64{"BTCUSDT_long": 12.5}, restored.GetPeakPnLCache())
+
+	missing := &AutoTrader{peakPnLCache: make(map[string]float64), peakPnLFile: filepath.Join(t.TempDir(), "none.json")}
+	assert.NoError(t, missing.loadPeakPnLCache(), "文件不存在时视为空缓存")
+}
+
+func TestCheckPositionDrawdown_LogsProtectionClose(t *testing.T) {
+	rules, err := BuildProtectionRules([]ProtectionRuleConfig{
+		{Type: ProtectionSteppedBreakeven, Steps: []BreakevenStep{{TriggerPct: 3, LockPct: 0}}},
+	})
+	require.NoError(t, err)
+
+	decisionLogger := logger.NewDecisionLogger(t.TempDir())
+	at := &AutoTrader{
+		trader: &MockTrader{positions: []Position{
+			{Symbol: "SOLUSDT", Side: "long", Quantity: 10.0, EntryPrice: 100.0, MarkPrice: 99.9, Leverage: 5},
+		}},
+		decisionLogger:  decisionLogger,
+		peakPnLCache:    map[string]float64{"SOLUSDT_long": 8, "BTCUSDT_short": 4},
+		protectionRules: rules,
+	}
+
+	at.checkPositionDrawdown()
+
+	records, err := decisionLogger.GetLatestRecords(1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Len(t, records[0].Decisions, 1)
+	action := records[0].Decisions[0]
+	assert.Equal(t, "auto_close_long", action.Action)
+	assert.Equal(t, "SOLUSDT", action.Symbol)
+	assert.True(t, action.Success)
+	assert.Empty(t, at.GetPeakPnLCache(), "已平仓与已不存在的持仓峰值都应清理")
+}
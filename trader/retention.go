@@ -0,0 +1,47 @@
+package trader
+
+import (
+	"log"
+	"time"
+)
+
+// DecisionRetentionInterval 决策日志保留任务的执行间隔
+const DecisionRetentionInterval = 6 * time.Hour
+
+// startDecisionRetention 定期对决策日志执行保留策略（启动时先执行一次）
+func (at *AutoTrader) startDecisionRetention() {
+	if at.decisionLogger == nil {
+		return
+	}
+
+	at.monitorWg.Add(1)
+	go func() {
+		defer at.monitorWg.Done()
+
+		ticker := time.NewTicker(DecisionRetentionInterval)
+		defer ticker.Stop()
+
+		for {
+			at.applyDecisionRetention()
+
+			select {
+			case <-ticker.C:
+			case <-at.stopMonitorCh:
+				return
+			}
+		}
+	}()
+}
+
+// applyDecisionRetention 执行一次保留策略并记录结果
+func (at *AutoTrader) applyDecisionRetention() {
+	result, err := at.decisionLogger.ApplyRetention(at.config.DecisionRetention)
+	if err != nil {
+		log.Printf("⚠️ [%s] 决策日志保留任务失败: %v", at.name, err)
+		return
+	}
+	if result.PromptsDeduplicated > 0 || result.RecordsArchived > 0 || result.RecordsDeleted > 0 || result.ArchivesDeleted > 0 {
+		log.Printf("🗄️ [%s] 决策日志保留: 提示词去重 %d 条 | 归档 %d 条 | 删除 %d 条 | 删除归档 %d 个 | 保留净值快照 %d 个",
+			at.name, result.PromptsDeduplicated, result.RecordsArchived, result.RecordsDeleted, result.ArchivesDeleted, result.SnapshotsKept)
+	}
+}
@@ -0,0 +1,222 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/decision"
+	"nofx/logger"
+	"os"
+	"strings"
+	"time"
+)
+
+// RiskLimits 组合风控阈值（百分比，0表示不启用该项）
+type RiskLimits struct {
+	MaxDailyLoss    float64       // 最大日亏损百分比（相对日初净值）
+	MaxDrawdown     float64       // 最大回撤百分比（相对峰值净值）
+	StopTradingTime time.Duration // 触发后暂停交易时长
+	FlattenOnBreach bool          // 触发后是否强制平掉所有持仓（否则仅拦截开仓）
+}
+
+// RiskCheck 单次风控评估结果
+type RiskCheck struct {
+	Equity         float64 // 当前净值（已实现+未实现）
+	DayStartEquity float64 // 日初净值
+	DailyPnL       float64 // 日内盈亏（USDT）
+	DailyPnLPct    float64 // 日内盈亏百分比
+	PeakEquity     float64 // 峰值净值
+	DrawdownPct    float64 // 距峰值回撤百分比
+	Breached       bool    // 是否触发风控
+	Reason         string  // 触发原因
+}
+
+// RiskState 需要跨重启保留的风控状态（日初基准、峰值净值与暂停截止时间）
+type RiskState struct {
+	DayStart       time.Time `json:"day_start"`
+	DayStartEquity float64   `json:"day_start_equity"`
+	PeakEquity     float64   `json:"peak_equity"`
+	StopUntil      time.Time `json:"stop_until"`
+}
+
+// RiskEngine 组合风控引擎：跟踪日内盈亏与峰值净值，触发阈值后拦截开仓或强制平仓
+type RiskEngine struct {
+	limits         RiskLimits
+	dayStart       time.Time
+	dayStartEquity float64
+	peakEquity     float64
+}
+
+// NewRiskEngine 创建风控引擎
+func NewRiskEngine(limits RiskLimits) *RiskEngine {
+	return &RiskEngine{limits: limits}
+}
+
+// newRiskEngineFromConfig 根据交易器配置创建风控引擎
+func newRiskEngineFromConfig(config AutoTraderConfig) *RiskEngine {
+	return NewRiskEngine(RiskLimits{
+		MaxDailyLoss:    config.MaxDailyLoss,
+		MaxDrawdown:     config.MaxDrawdown,
+		StopTradingTime: config.StopTradingTime,
+		FlattenOnBreach: config.FlattenOnRiskBreach,
+	})
+}
+
+// Limits 返回风控阈值
+func (r *RiskEngine) Limits() RiskLimits {
+	return r.limits
+}
+
+// DayStart 返回当前统计日的起始时间
+func (r *RiskEngine) DayStart() time.Time {
+	return r.dayStart
+}
+
+// State 返回当前风控状态（StopUntil 由交易器维护）
+func (r *RiskEngine) State() RiskState {
+	return RiskState{DayStart: r.dayStart, DayStartEquity: r.dayStartEquity, PeakEquity: r.peakEquity}
+}
+
+// Restore 恢复持久化的风控状态
+func (r *RiskEngine) Restore(state RiskState) {
+	r.dayStart = state.DayStart
+	r.dayStartEquity = state.DayStartEquity
+	r.peakEquity = state.PeakEquity
+}
+
+// Evaluate 用当前净值更新日初基准与峰值，并判断是否触发风控
+// 统计日以UTC零点为界，每天首次评估时的净值作为日初基准；净值非正时不做判断（通常是获取余额异常）
+func (r *RiskEngine) Evaluate(now time.Time, equity float64) RiskCheck {
+	if equity <= 0 {
+		return RiskCheck{Equity: equity, DayStartEquity: r.dayStartEquity, PeakEquity: r.peakEquity}
+	}
+
+	if day := now.UTC().Truncate(24 * time.Hour); !r.dayStart.Equal(day) || r.dayStartEquity <= 0 {
+		r.dayStart = day
+		r.dayStartEquity = equity
+	}
+	if equity > r.peakEquity {
+		r.peakEquity = equity
+	}
+
+	check := RiskCheck{
+		Equity:         equity,
+		DayStartEquity: r.dayStartEquity,
+		DailyPnL:       equity - r.dayStartEquity,
+		PeakEquity:     r.peakEquity,
+	}
+	check.DailyPnLPct = check.DailyPnL / r.dayStartEquity * 100
+	check.DrawdownPct = (r.peakEquity - equity) / r.peakEquity * 100
+
+	var reasons []string
+	if r.limits.MaxDailyLoss > 0 && -check.DailyPnLPct >= r.limits.MaxDailyLoss {
+		reasons = append(reasons, fmt.Sprintf("日亏损 %.2f%% 达到上限 %.2f%%", -check.DailyPnLPct, r.limits.MaxDailyLoss))
+	}
+	if r.limits.MaxDrawdown > 0 && check.DrawdownPct >= r.limits.MaxDrawdown {
+		reasons = append(reasons, fmt.Sprintf("回撤 %.2f%% 达到上限 %.2f%%", check.DrawdownPct, r.limits.MaxDrawdown))
+	}
+	if len(reasons) > 0 {
+		check.Breached = true
+		check.Reason = strings.Join(reasons, "；")
+	}
+	return check
+}
+
+// ResetPeak 将峰值重置为当前净值
+// 回撤触发并暂停后调用，避免恢复交易时被同一笔回撤立即再次触发
+func (r *RiskEngine) ResetPeak(equity float64) {
+	if equity > 0 {
+		r.peakEquity = equity
+	}
+}
+
+// Apply 按评估结果过滤决策，返回允许执行与被拦截的决策
+// 未触发时原样放行；触发后拦截所有开仓，启用强平时还会拦截调整类决策并为剩余持仓补充平仓决策
+func (r *RiskEngine) Apply(check RiskCheck, decisions []decision.Decision, positions []decision.PositionInfo) (allowed, blocked []decision.Decision) {
+	if !check.Breached {
+		return decisions, nil
+	}
+
+	closing := make(map[string]bool)
+	for _, d := range decisions {
+		switch d.Action {
+		case "open_long", "open_short":
+			blocked = append(blocked, d)
+		case "close_long":
+			closing[d.Symbol+"_long"] = true
+			allowed = append(allowed, d)
+		case "close_short":
+			closing[d.Symbol+"_short"] = true
+			allowed = append(allowed, d)
+		case "hold", "wait":
+			allowed = append(allowed, d)
+		default:
+			if r.limits.FlattenOnBreach {
+				blocked = append(blocked, d)
+			} else {
+				allowed = append(allowed, d)
+			}
+		}
+	}
+
+	if r.limits.FlattenOnBreach {
+		for _, pos := range positions {
+			if closing[pos.Symbol+"_"+pos.Side] {
+				continue
+			}
+			allowed = append(allowed, decision.Decision{
+				Symbol:    pos.Symbol,
+				Action:    "close_" + pos.Side,
+				Reasoning: "风控强制平仓: " + check.Reason,
+			})
+		}
+	}
+	return allowed, blocked
+}
+
+// loadRiskState 从文件恢复风控状态（重启后保留进行中的暂停与回撤峰值）
+func (at *AutoTrader) loadRiskState() error {
+	if at.riskStateFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(at.riskStateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取风控状态文件失败: %w", err)
+	}
+
+	var state RiskState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("解析风控状态文件失败: %w", err)
+	}
+	at.riskEngine.Restore(state)
+	at.stopUntil = state.StopUntil
+	if !state.DayStart.IsZero() {
+		at.lastResetTime = state.DayStart
+	}
+	log.Printf("📂 [%s] 已恢复风控状态: 日初净值 %.2f | 峰值净值 %.2f", at.name, state.DayStartEquity, state.PeakEquity)
+	if at.now().Before(state.StopUntil) {
+		log.Printf("⏸ [%s] 风控暂停仍在进行，持续至 %s", at.name, state.StopUntil.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// saveRiskState 将风控状态写入文件
+func (at *AutoTrader) saveRiskState() error {
+	if at.riskStateFile == "" {
+		return nil
+	}
+
+	state := at.riskEngine.State()
+	state.StopUntil = at.stopUntil
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化风控状态失败: %w", err)
+	}
+	if err := logger.WriteFileAtomic(at.riskStateFile, data); err != nil {
+		return fmt.Errorf("写入风控状态失败: %w", err)
+	}
+	return nil
+}
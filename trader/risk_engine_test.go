@@ -0,0 +1,247 @@
+package trader
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+	"nofx/mcp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiskEngine_Evaluate(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	engine := NewRiskEngine(RiskLimits{MaxDailyLoss: 5, MaxDrawdown: 10})
+
+	check := engine.Evaluate(start, 1000)
+	assert.False(t, check.Breached)
+	assert.Equal(t, 1000.0, check.DayStartEquity)
+
+	// 日内亏损 4%，未达到上限
+	check = engine.Evaluate(start.Add(time.Hour), 960)
+	assert.False(t, check.Breached)
+	assert.InDelta(t, -40, check.DailyPnL, 1e-9)
+	assert.InDelta(t, -4, check.DailyPnLPct, 1e-9)
+
+	// 日内亏损 5%，触发日亏损上限
+	check = engine.Evaluate(start.Add(2*time.Hour), 950)
+	assert.True(t, check.Breached)
+	assert.Contains(t, check.Reason, "日亏损")
+
+	// 跨过UTC零点后日初基准滚动，日亏损清零，但回撤仍从峰值计算
+	check = engine.Evaluate(start.Add(25*time.Hour), 920)
+	assert.Equal(t, 920.0, check.DayStartEquity)
+	assert.InDelta(t, 0, check.DailyPnL, 1e-9)
+	assert.InDelta(t, 8, check.DrawdownPct, 1e-9)
+	assert.False(t, check.Breached)
+
+	check = engine.Evaluate(start.Add(26*time.Hour), 900)
+	assert.True(t, check.Breached)
+	assert.Contains(t, check.Reason, "回撤")
+	assert.NotContains(t, check.Reason, "日亏损")
+
+	// 重置峰值后回撤重新累计
+	engine.ResetPeak(900)
+	check = engine.Evaluate(start.Add(27*time.Hour), 895)
+	assert.False(t, check.Breached)
+}
+
+func TestRiskEngine_UTCDayBoundary(t *testing.T) {
+	engine := NewRiskEngine(RiskLimits{MaxDailyLoss: 5})
+	lateNight := time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC)
+
+	engine.Evaluate(lateNight, 1000)
+	check := engine.Evaluate(lateNight.Add(50*time.Minute), 960)
+	assert.Equal(t, 1000.0, check.DayStartEquity, "同一UTC日内不滚动")
+
+	// 首次评估仅2小时后即跨过UTC零点，按日历日滚动而非24小时
+	check = engine.Evaluate(lateNight.Add(2*time.Hour), 950)
+	assert.Equal(t, 950.0, check.DayStartEquity)
+	assert.Equal(t, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), engine.DayStart())
+}
+
+func TestAutoTrader_RiskStatePersists(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	config := AutoTraderConfig{MaxDailyLoss: 5, MaxDrawdown: 10, StopTradingTime: time.Hour}
+	file := filepath.Join(t.TempDir(), "risk.json")
+	newTrader := func() *AutoTrader {
+		return &AutoTrader{
+			config:        config,
+			riskEngine:    newRiskEngineFromConfig(config),
+			riskStateFile: file,
+			clock:         func() time.Time { return now },
+		}
+	}
+
+	at := newTrader()
+	at.evaluateRisk(1200)
+	check := at.evaluateRisk(1100)
+	require.True(t, check.Breached)
+	at.applyRiskControl(check, nil, nil, &logger.DecisionRecord{})
+
+	// 重启后暂停仍然生效，日初基准与（重置后的）峰值保留
+	now = now.Add(10 * time.Minute)
+	restored := newTrader()
+	require.NoError(t, restored.loadRiskState())
+	assert.Equal(t, at.stopUntil, restored.stopUntil)
+	check = restored.evaluateRisk(1100)
+	assert.Equal(t, 1200.0, check.DayStartEquity)
+	assert.Equal(t, 1100.0, check.PeakEquity)
+}
+
+func TestRiskEngine_EvaluateDisabled(t *testing.T) {
+	engine := NewRiskEngine(RiskLimits{})
+	now := time.Now()
+
+	engine.Evaluate(now, 1000)
+	check := engine.Evaluate(now.Add(time.Minute), 100)
+	assert.False(t, check.Breached, "阈值为0时不启用风控")
+	assert.InDelta(t, 90, check.DrawdownPct, 1e-9)
+
+	check = engine.Evaluate(now.Add(2*time.Minute), 0)
+	assert.False(t, check.Breached, "净值异常时不做判断")
+}
+
+func TestRiskEngine_Apply(t *testing.T) {
+	decisions := []decision.Decision{
+		{Symbol: "BTCUSDT", Action: "close_long"},
+		{Symbol: "ETHUSDT", Action: "update_stop_loss", NewStopLoss: 3000},
+		{Symbol: "SOLUSDT", Action: "open_short", Leverage: 5, PositionSizeUSD: 100},
+		{Symbol: "XRPUSDT", Action: "wait"},
+	}
+	positions := []decision.PositionInfo{
+		{Symbol: "BTCUSDT", Side: "long"},
+		{Symbol: "ETHUSDT", Side: "short"},
+	}
+	breached := RiskCheck{Breached: true, Reason: "日亏损 6.00% 达到上限 5.00%"}
+
+	t.Run("未触发原样放行", func(t *testing.T) {
+		allowed, blocked := NewRiskEngine(RiskLimits{MaxDailyLoss: 5}).Apply(RiskCheck{}, decisions, positions)
+		assert.Equal(t, decisions, allowed)
+		assert.Empty(t, blocked)
+	})
+
+	t.Run("仅拦截开仓", func(t *testing.T) {
+		allowed, blocked := NewRiskEngine(RiskLimits{MaxDailyLoss: 5}).Apply(breached, decisions, positions)
+		require.Len(t, blocked, 1)
+		assert.Equal(t, "open_short", blocked[0].Action)
+		assert.Len(t, allowed, 3)
+	})
+
+	t.Run("强制平仓", func(t *testing.T) {
+		allowed, blocked := NewRiskEngine(RiskLimits{MaxDailyLoss: 5, FlattenOnBreach: true}).Apply(breached, decisions, positions)
+		require.Len(t, blocked, 2)
+		assert.Equal(t, "update_stop_loss", blocked[0].Action)
+		assert.Equal(t, "open_short", blocked[1].Action)
+
+		var actions []string
+		for _, d := range allowed {
+			actions = append(actions, d.Symbol+" "+d.Action)
+		}
+		// BTC 多仓已由AI平仓，不重复生成；ETH 空仓由风控补充平仓
+		assert.Equal(t, []string{"BTCUSDT close_long", "XRPUSDT wait", "ETHUSDT close_short"}, actions)
+		assert.Contains(t, allowed[2].Reasoning, "风控强制平仓")
+	})
+}
+
+func TestAutoTrader_ApplyRiskControl(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	config := AutoTraderConfig{MaxDailyLoss: 5, MaxDrawdown: 10, StopTradingTime: time.Hour}
+	at := &AutoTrader{
+		config:     config,
+		riskEngine: newRiskEngineFromConfig(config),
+		clock:      func() time.Time { return now },
+	}
+
+	at.evaluateRisk(1000)
+	check := at.evaluateRisk(940)
+	require.True(t, check.Breached)
+	assert.InDelta(t, -60, at.dailyPnL, 1e-9)
+
+	record := &logger.DecisionRecord{}
+	allowed := at.applyRiskControl(check, []decision.Decision{
+		{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5},
+		{Symbol: "ETHUSDT", Action: "hold"},
+	}, nil, record)
+
+	require.Len(t, allowed, 1)
+	assert.Equal(t, "hold", allowed[0].Action)
+	assert.Equal(t, check.Reason, record.RiskReason)
+	require.Len(t, record.Decisions, 1)
+	assert.False(t, record.Decisions[0].Success)
+	assert.Contains(t, record.Decisions[0].Error, "风控拦截")
+	assert.Equal(t, now.Add(time.Hour), at.stopUntil)
+}
+
+// failingAIClient 每次调用都失败的AI客户端（记录调用次数）
+type failingAIClient struct {
+	calls int
+}
+
+func (c *failingAIClient) SetAPIKey(apiKey string, customURL string, customModel string) {}
+func (c *failingAIClient) SetTimeout(timeout time.Duration)                              {}
+func (c *failingAIClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	c.calls++
+	return "", errors.New("AI服务不可用")
+}
+func (c *failingAIClient) CallWithRequest(req *mcp.Request) (string, error) {
+	c.calls++
+	return "", errors.New("AI服务不可用")
+}
+
+func TestAutoTrader_RiskBreachHandledWithoutAI(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	feed := paperPriceFeed{"BTCUSDT": 50000}
+	pt := newTestPaperTrader(t, feed, "")
+	ai := &failingAIClient{}
+	decisionLogger := logger.NewDecisionLogger(t.TempDir())
+	at, err := NewBacktestAutoTrader(AutoTraderConfig{
+		InitialBalance:      10000,
+		TradingCoins:        []string{"BTCUSDT"},
+		MaxDailyLoss:        5,
+		StopTradingTime:     time.Hour,
+		FlattenOnRiskBreach: true,
+	}, BacktestDeps{
+		Trader:         pt,
+		AIClient:       ai,
+		DecisionLogger: decisionLogger,
+		MarketDataFunc: func(symbol string) (*market.Data, error) {
+			return &market.Data{Symbol: symbol, CurrentPrice: feed[symbol]}, nil
+		},
+		Clock: func() time.Time { return now },
+	})
+	require.NoError(t, err)
+
+	_, err = pt.OpenLong("BTCUSDT", 1, 10)
+	require.NoError(t, err)
+
+	// 未触发风控：请求AI，AI失败时周期返回错误
+	require.Error(t, at.RunCycle())
+	calls := ai.calls
+	require.Positive(t, calls)
+
+	// 价格下跌使日亏损超过上限：不再请求AI，直接强制平仓并暂停交易
+	feed["BTCUSDT"] = 49000
+	now = now.Add(3 * time.Minute)
+	require.NoError(t, at.RunCycle())
+	assert.Equal(t, calls, ai.calls, "风控触发时不应请求AI")
+	assert.Equal(t, now.Add(time.Hour), at.stopUntil)
+
+	positions, err := pt.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+
+	records, err := decisionLogger.GetLatestRecords(1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.NotEmpty(t, records[0].RiskReason)
+	require.Len(t, records[0].Decisions, 1)
+	assert.Equal(t, "close_long", records[0].Decisions[0].Action)
+	assert.True(t, records[0].Decisions[0].Success)
+}
@@ -1,7 +1,9 @@
 package trader
 
 import (
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/agiledragon/gomonkey/v2"
 	"github.com/stretchr/testify/assert"
@@ -54,6 +56,7 @@ func (s *TraderTestSuite) RunAllTests() {
 	s.T.Run("OpenShort", func(t *testing.T) { s.TestOpenShort() })
 	s.T.Run("CloseLong", func(t *testing.T) { s.TestCloseLong() })
 	s.T.Run("CloseShort", func(t *testing.T) { s.TestCloseShort() })
+	s.T.Run("OpenLimitAndCancel", func(t *testing.T) { s.TestOpenLimitAndCancel() })
 
 	// 止损止盈
 	s.T.Run("SetStopLoss", func(t *testing.T) { s.TestSetStopLoss() })
@@ -64,6 +67,11 @@ func (s *TraderTestSuite) RunAllTests() {
 	s.T.Run("CancelStopOrders", func(t *testing.T) { s.TestCancelStopOrders() })
 	s.T.Run("CancelStopLossOrders", func(t *testing.T) { s.TestCancelStopLossOrders() })
 	s.T.Run("CancelTakeProfitOrders", func(t *testing.T) { s.TestCancelTakeProfitOrders() })
+
+	// 成交与资金流水
+	s.T.Run("GetFills", func(t *testing.T) { s.TestGetFills() })
+	s.T.Run("GetIncome", func(t *testing.T) { s.TestGetIncome() })
+	s.T.Run("GetOpenOrders", func(t *testing.T) { s.TestGetOpenOrders() })
 }
 
 // TestGetBalance 测试获取账户余额
@@ -71,15 +79,15 @@ func (s *TraderTestSuite) TestGetBalance() {
 	tests := []struct {
 		name      string
 		wantError bool
-		validate  func(*testing.T, map[string]interface{})
+		validate  func(*testing.T, *Balance)
 	}{
 		{
 			name:      "成功获取余额",
 			wantError: false,
-			validate: func(t *testing.T, result map[string]interface{}) {
+			validate: func(t *testing.T, result *Balance) {
 				assert.NotNil(t, result)
-				assert.Contains(t, result, "totalWalletBalance")
-				assert.Contains(t, result, "availableBalance")
+				assert.GreaterOrEqual(t, result.TotalWalletBalance, 0.0)
+				assert.GreaterOrEqual(t, result.AvailableBalance, 0.0)
 			},
 		},
 	}
@@ -104,18 +112,18 @@ func (s *TraderTestSuite) TestGetPositions() {
 	tests := []struct {
 		name      string
 		wantError bool
-		validate  func(*testing.T, []map[string]interface{})
+		validate  func(*testing.T, []Position)
 	}{
 		{
 			name:      "成功获取持仓列表",
 			wantError: false,
-			validate: func(t *testing.T, positions []map[string]interface{}) {
+			validate: func(t *testing.T, positions []Position) {
 				assert.NotNil(t, positions)
 				// 持仓可以为空数组
 				for _, pos := range positions {
-					assert.Contains(t, pos, "symbol")
-					assert.Contains(t, pos, "side")
-					assert.Contains(t, pos, "positionAmt")
+					assert.NotEmpty(t, pos.Symbol)
+					assert.Contains(t, []string{"long", "short"}, pos.Side)
+					assert.GreaterOrEqual(t, pos.Quantity, 0.0, "持仓数量应为正数")
 				}
 			},
 		},
@@ -325,7 +333,7 @@ func (s *TraderTestSuite) TestOpenLong() {
 		quantity  float64
 		leverage  int
 		wantError bool
-		validate  func(*testing.T, map[string]interface{})
+		validate  func(*testing.T, *OrderResult)
 	}{
 		{
 			name:      "成功开多仓",
@@ -333,10 +341,9 @@ func (s *TraderTestSuite) TestOpenLong() {
 			quantity:  0.01,
 			leverage:  10,
 			wantError: false,
-			validate: func(t *testing.T, result map[string]interface{}) {
+			validate: func(t *testing.T, result *OrderResult) {
 				assert.NotNil(t, result)
-				assert.Contains(t, result, "symbol")
-				assert.Equal(t, "BTCUSDT", result["symbol"])
+				assert.Equal(t, "BTCUSDT", result.Symbol)
 			},
 		},
 		{
@@ -345,7 +352,7 @@ func (s *TraderTestSuite) TestOpenLong() {
 			quantity:  0.004, // 增加到 0.004 以满足 Binance Futures 的 10 USDT 最小订单金额要求 (0.004 * 3000 = 12 USDT)
 			leverage:  5,
 			wantError: false,
-			validate: func(t *testing.T, result map[string]interface{}) {
+			validate: func(t *testing.T, result *OrderResult) {
 				assert.NotNil(t, result)
 			},
 		},
@@ -374,7 +381,7 @@ func (s *TraderTestSuite) TestOpenShort() {
 		quantity  float64
 		leverage  int
 		wantError bool
-		validate  func(*testing.T, map[string]interface{})
+		validate  func(*testing.T, *OrderResult)
 	}{
 		{
 			name:      "成功开空仓",
@@ -382,10 +389,9 @@ func (s *TraderTestSuite) TestOpenShort() {
 			quantity:  0.01,
 			leverage:  10,
 			wantError: false,
-			validate: func(t *testing.T, result map[string]interface{}) {
+			validate: func(t *testing.T, result *OrderResult) {
 				assert.NotNil(t, result)
-				assert.Contains(t, result, "symbol")
-				assert.Equal(t, "BTCUSDT", result["symbol"])
+				assert.Equal(t, "BTCUSDT", result.Symbol)
 			},
 		},
 		{
@@ -394,7 +400,7 @@ func (s *TraderTestSuite) TestOpenShort() {
 			quantity:  0.004, // 增加到 0.004 以满足 Binance Futures 的 10 USDT 最小订单金额要求 (0.004 * 3000 = 12 USDT)
 			leverage:  5,
 			wantError: false,
-			validate: func(t *testing.T, result map[string]interface{}) {
+			validate: func(t *testing.T, result *OrderResult) {
 				assert.NotNil(t, result)
 			},
 		},
@@ -422,16 +428,16 @@ func (s *TraderTestSuite) TestCloseLong() {
 		symbol    string
 		quantity  float64
 		wantError bool
-		validate  func(*testing.T, map[string]interface{})
+		validate  func(*testing.T, *OrderResult)
 	}{
 		{
 			name:      "平指定数量",
 			symbol:    "BTCUSDT",
 			quantity:  0.01,
 			wantError: false,
-			validate: func(t *testing.T, result map[string]interface{}) {
+			validate: func(t *testing.T, result *OrderResult) {
 				assert.NotNil(t, result)
-				assert.Contains(t, result, "symbol")
+				assert.NotEmpty(t, result.Symbol)
 			},
 		},
 		{
@@ -465,16 +471,16 @@ func (s *TraderTestSuite) TestCloseShort() {
 		symbol    string
 		quantity  float64
 		wantError bool
-		validate  func(*testing.T, map[string]interface{})
+		validate  func(*testing.T, *OrderResult)
 	}{
 		{
 			name:      "平指定数量",
 			symbol:    "BTCUSDT",
 			quantity:  0.01,
 			wantError: false,
-			validate: func(t *testing.T, result map[string]interface{}) {
+			validate: func(t *testing.T, result *OrderResult) {
 				assert.NotNil(t, result)
-				assert.Contains(t, result, "symbol")
+				assert.NotEmpty(t, result.Symbol)
 			},
 		},
 		{
@@ -505,6 +511,35 @@ func (s *TraderTestSuite) TestCloseShort() {
 // 止损止盈测试
 // ============================================================
 
+// TestOpenLimitAndCancel 测试限价开仓并按订单ID撤单
+func (s *TraderTestSuite) TestOpenLimitAndCancel() {
+	tests := []struct {
+		name     string
+		open     func(symbol string, quantity float64, leverage int, price float64, postOnly bool) (*OrderResult, error)
+		price    float64
+		postOnly bool
+	}{
+		{name: "post-only 限价开多", open: s.Trader.OpenLongLimit, price: 49000, postOnly: true},
+		{name: "GTC 限价开空", open: s.Trader.OpenShortLimit, price: 51000, postOnly: false},
+	}
+
+	for _, tt := range tests {
+		s.T.Run(tt.name, func(t *testing.T) {
+			result, err := tt.open("BTCUSDT", 0.01, 10, tt.price, tt.postOnly)
+			assert.NoError(t, err)
+			if assert.NotNil(t, result) {
+				assert.Equal(t, "BTCUSDT", result.Symbol)
+				assert.Contains(t, []string{OrderStatusNew, OrderStatusFilled}, result.Status)
+				if result.Status == OrderStatusNew {
+					assert.NoError(t, s.Trader.CancelOrder("BTCUSDT", strconv.FormatInt(result.OrderID, 10)))
+				}
+			}
+		})
+	}
+
+	assert.Error(s.T, s.Trader.CancelOrder("BTCUSDT", "not-a-number"))
+}
+
 // TestSetStopLoss 测试设置止损
 func (s *TraderTestSuite) TestSetStopLoss() {
 	tests := []struct {
@@ -662,3 +697,49 @@ func (s *TraderTestSuite) TestCancelTakeProfitOrders() {
 		})
 	}
 }
+
+// ============================================================
+// 成交与资金流水测试
+// ============================================================
+
+// TestGetFills 测试获取成交明细
+func (s *TraderTestSuite) TestGetFills() {
+	fills, err := s.Trader.GetFills("BTCUSDT", time.Now().Add(-24*time.Hour))
+	assert.NoError(s.T, err)
+	assert.NotEmpty(s.T, fills)
+	for i, fill := range fills {
+		assert.Equal(s.T, "BTCUSDT", fill.Symbol)
+		assert.NotEmpty(s.T, fill.TradeID)
+		assert.Contains(s.T, []string{"BUY", "SELL"}, fill.Side)
+		assert.Contains(s.T, []string{"LONG", "SHORT"}, fill.PositionSide)
+		assert.Greater(s.T, fill.Quantity, 0.0)
+		assert.Greater(s.T, fill.Price, 0.0)
+		if i > 0 {
+			assert.False(s.T, fill.Time.Before(fills[i-1].Time), "成交应按时间正序")
+		}
+	}
+}
+
+// TestGetIncome 测试获取资金流水
+func (s *TraderTestSuite) TestGetIncome() {
+	incomes, err := s.Trader.GetIncome("", time.Now().Add(-24*time.Hour))
+	assert.NoError(s.T, err)
+	assert.NotEmpty(s.T, incomes)
+	for _, income := range incomes {
+		assert.NotEmpty(s.T, income.Type)
+		assert.NotEmpty(s.T, income.TranID)
+		assert.NotZero(s.T, income.Amount)
+	}
+}
+
+// TestGetOpenOrders 测试获取当前挂单
+func (s *TraderTestSuite) TestGetOpenOrders() {
+	orders, err := s.Trader.GetOpenOrders("BTCUSDT")
+	assert.NoError(s.T, err)
+	for _, order := range orders {
+		assert.Equal(s.T, "BTCUSDT", order.Symbol)
+		assert.NotEmpty(s.T, order.OrderID)
+		assert.NotEmpty(s.T, order.Type)
+		assert.Contains(s.T, []string{"BUY", "SELL"}, order.Side)
+	}
+}